@@ -0,0 +1,142 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"sigs.k8s.io/controller-runtime/pkg/conversion"
+
+	"github.com/metallb/metallb-operator/api/v1beta1"
+)
+
+var _ conversion.Convertible = &AddressPool{}
+
+// ConvertTo converts the AddressPool to the v1beta1 hub version. The
+// conversion is lossless, the fields missing from the AddressPool, e.g. one
+// submitted by a client older than the CRD, stay unset.
+func (addressPool *AddressPool) ConvertTo(dstRaw conversion.Hub) error {
+	dst := dstRaw.(*v1beta1.AddressPool)
+	dst.ObjectMeta = addressPool.ObjectMeta
+	src := &addressPool.Spec
+	dst.Spec = v1beta1.AddressPoolSpec{
+		Name:               src.Name,
+		Protocol:           src.Protocol,
+		Addresses:          copyStrings(src.Addresses),
+		AutoAssign:         copyBool(src.AutoAssign),
+		AvoidBuggyIPs:      src.AvoidBuggyIPs,
+		AllocationStrategy: src.AllocationStrategy,
+		AllowedNamespaces:  copyStrings(src.AllowedNamespaces),
+	}
+	if src.AutoExpand != nil {
+		dst.Spec.AutoExpand = &v1beta1.AutoExpandSpec{
+			Supernet:  src.AutoExpand.Supernet,
+			Increment: src.AutoExpand.Increment,
+		}
+	}
+	if src.BGPAdvertisements != nil {
+		dst.Spec.BGPAdvertisements = make([]v1beta1.BGPAdvertisement, len(src.BGPAdvertisements))
+		for i, adv := range src.BGPAdvertisements {
+			dst.Spec.BGPAdvertisements[i] = v1beta1.BGPAdvertisement{
+				AggregationLength: copyInt32(adv.AggregationLength),
+				LocalPref:         copyUint32(adv.LocalPref),
+			}
+			if adv.Communities != nil {
+				dst.Spec.BGPAdvertisements[i].Communities = make([]v1beta1.BGPCommunity, len(adv.Communities))
+				for j, c := range adv.Communities {
+					dst.Spec.BGPAdvertisements[i].Communities[j] = v1beta1.BGPCommunity{Name: c.Name, WellKnown: c.WellKnown, ASN: c.ASN, Value: c.Value}
+				}
+			}
+		}
+	}
+	dst.Status.Conditions = addressPool.Status.DeepCopy().Conditions
+	dst.Status.AllocatedAddresses = addressPool.Status.AllocatedAddresses
+	dst.Status.TotalAddresses = addressPool.Status.TotalAddresses
+	return nil
+}
+
+// ConvertFrom converts the v1beta1 hub version to this AddressPool.
+func (addressPool *AddressPool) ConvertFrom(srcRaw conversion.Hub) error {
+	src := srcRaw.(*v1beta1.AddressPool)
+	addressPool.ObjectMeta = src.ObjectMeta
+	spec := &src.Spec
+	addressPool.Spec = AddressPoolSpec{
+		Name:               spec.Name,
+		Protocol:           spec.Protocol,
+		Addresses:          copyStrings(spec.Addresses),
+		AutoAssign:         copyBool(spec.AutoAssign),
+		AvoidBuggyIPs:      spec.AvoidBuggyIPs,
+		AllocationStrategy: spec.AllocationStrategy,
+		AllowedNamespaces:  copyStrings(spec.AllowedNamespaces),
+	}
+	if spec.AutoExpand != nil {
+		addressPool.Spec.AutoExpand = &AutoExpandSpec{
+			Supernet:  spec.AutoExpand.Supernet,
+			Increment: spec.AutoExpand.Increment,
+		}
+	}
+	if spec.BGPAdvertisements != nil {
+		addressPool.Spec.BGPAdvertisements = make([]BGPAdvertisement, len(spec.BGPAdvertisements))
+		for i, adv := range spec.BGPAdvertisements {
+			addressPool.Spec.BGPAdvertisements[i] = BGPAdvertisement{
+				AggregationLength: copyInt32(adv.AggregationLength),
+				LocalPref:         copyUint32(adv.LocalPref),
+			}
+			if adv.Communities != nil {
+				addressPool.Spec.BGPAdvertisements[i].Communities = make([]BGPCommunity, len(adv.Communities))
+				for j, c := range adv.Communities {
+					addressPool.Spec.BGPAdvertisements[i].Communities[j] = BGPCommunity{Name: c.Name, WellKnown: c.WellKnown, ASN: c.ASN, Value: c.Value}
+				}
+			}
+		}
+	}
+	addressPool.Status.Conditions = src.Status.DeepCopy().Conditions
+	addressPool.Status.AllocatedAddresses = src.Status.AllocatedAddresses
+	addressPool.Status.TotalAddresses = src.Status.TotalAddresses
+	return nil
+}
+
+func copyStrings(s []string) []string {
+	if s == nil {
+		return nil
+	}
+	return append([]string{}, s...)
+}
+
+// copyBool keeps a nil AutoAssign nil, the ConfigMap rendering tells an
+// unset AutoAssign from a false one.
+func copyBool(b *bool) *bool {
+	if b == nil {
+		return nil
+	}
+	v := *b
+	return &v
+}
+
+func copyInt32(i *int32) *int32 {
+	if i == nil {
+		return nil
+	}
+	v := *i
+	return &v
+}
+
+func copyUint32(i *uint32) *uint32 {
+	if i == nil {
+		return nil
+	}
+	v := *i
+	return &v
+}
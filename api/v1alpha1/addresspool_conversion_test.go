@@ -0,0 +1,99 @@
+package v1alpha1
+
+import (
+	"math/rand"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"k8s.io/apimachinery/pkg/api/apitesting/fuzzer"
+	apiequality "k8s.io/apimachinery/pkg/api/equality"
+	metafuzzer "k8s.io/apimachinery/pkg/apis/meta/fuzzer"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/serializer"
+	"k8s.io/apimachinery/pkg/util/diff"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/conversion"
+
+	"github.com/metallb/metallb-operator/api/v1beta1"
+)
+
+func TestAddressPoolConversionRoundTrip(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	s := runtime.NewScheme()
+	g.Expect(AddToScheme(s)).To(Succeed())
+	g.Expect(v1beta1.AddToScheme(s)).To(Succeed())
+	// The webhook builder registers the conversion webhook for convertible types only
+	convertible, err := conversion.IsConvertible(s, &AddressPool{})
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(convertible).To(BeTrue())
+
+	seed := rand.Int63()
+	f := fuzzer.FuzzerFor(metafuzzer.Funcs, rand.NewSource(seed), serializer.NewCodecFactory(s))
+
+	for i := 0; i < 1000; i++ {
+		spoke := &AddressPool{}
+		f.Fuzz(spoke)
+		hub := &v1beta1.AddressPool{}
+		g.Expect(spoke.ConvertTo(hub)).To(Succeed())
+		back := &AddressPool{}
+		g.Expect(back.ConvertFrom(hub)).To(Succeed())
+		g.Expect(apiequality.Semantic.DeepEqual(spoke, back)).To(BeTrue(), "seed %d: %s", seed, diff.ObjectReflectDiff(spoke, back))
+
+		hub = &v1beta1.AddressPool{}
+		f.Fuzz(hub)
+		spoke = &AddressPool{}
+		g.Expect(spoke.ConvertFrom(hub)).To(Succeed())
+		hubBack := &v1beta1.AddressPool{}
+		g.Expect(spoke.ConvertTo(hubBack)).To(Succeed())
+		g.Expect(apiequality.Semantic.DeepEqual(hub, hubBack)).To(BeTrue(), "seed %d: %s", seed, diff.ObjectReflectDiff(hub, hubBack))
+	}
+}
+
+func TestAddressPoolConversionAutoAssign(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	autoAssign := false
+	for _, value := range []*bool{nil, &autoAssign} {
+		pool := &AddressPool{Spec: AddressPoolSpec{Protocol: "layer2", Addresses: []string{"10.0.0.0/24"}, AutoAssign: value}}
+		hub := &v1beta1.AddressPool{}
+		g.Expect(pool.ConvertTo(hub)).To(Succeed())
+		g.Expect(hub.Spec.AutoAssign).To(Equal(value))
+		back := &AddressPool{}
+		g.Expect(back.ConvertFrom(hub)).To(Succeed())
+		g.Expect(back.Spec.AutoAssign).To(Equal(value))
+	}
+
+	// The converted pool does not share the value of the original one
+	autoAssign = true
+	pool := &AddressPool{Spec: AddressPoolSpec{AutoAssign: &autoAssign}}
+	hub := &v1beta1.AddressPool{}
+	g.Expect(pool.ConvertTo(hub)).To(Succeed())
+	autoAssign = false
+	g.Expect(*hub.Spec.AutoAssign).To(BeTrue())
+}
+
+func TestAddressPoolConversionUnsetFields(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	// A bgp pool submitted by an older client, without any of the optional
+	// fields, e.g. the BGP advertisements
+	pool := &AddressPool{
+		ObjectMeta: metav1.ObjectMeta{Name: "gold", Namespace: "metallb-system", ResourceVersion: "42"},
+		Spec:       AddressPoolSpec{Protocol: ProtocolBGP, Addresses: []string{"10.0.0.0/24"}},
+	}
+	hub := &v1beta1.AddressPool{}
+	g.Expect(pool.ConvertTo(hub)).To(Succeed())
+	g.Expect(hub.ObjectMeta).To(Equal(pool.ObjectMeta))
+	g.Expect(hub.Spec).To(Equal(v1beta1.AddressPoolSpec{
+		Protocol:  ProtocolBGP,
+		Addresses: []string{"10.0.0.0/24"},
+	}))
+	g.Expect(hub.Status).To(Equal(v1beta1.AddressPoolStatus{}))
+
+	back := &AddressPool{}
+	g.Expect(back.ConvertFrom(hub)).To(Succeed())
+	g.Expect(back.Spec.BGPAdvertisements).To(BeNil())
+	g.Expect(back.Spec.AutoExpand).To(BeNil())
+	g.Expect(back.Spec.AutoAssign).To(BeNil())
+}
@@ -0,0 +1,92 @@
+package v1alpha1
+
+import (
+	"encoding/json"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"gopkg.in/yaml.v2"
+)
+
+func TestAllocationStrategySerialization(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	for _, strategy := range []string{AllocationSequential, AllocationRandom} {
+		spec := AddressPoolSpec{
+			Protocol:           "layer2",
+			Addresses:          []string{"10.0.0.0/24"},
+			AllocationStrategy: strategy,
+		}
+
+		j, err := json.Marshal(spec)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(string(j)).To(ContainSubstring(`"allocationStrategy":"` + strategy + `"`))
+		decoded := AddressPoolSpec{}
+		g.Expect(json.Unmarshal(j, &decoded)).To(Succeed())
+		g.Expect(decoded).To(Equal(spec))
+
+		// The strategy is not part of the MetalLB configuration
+		y, err := yaml.Marshal(spec)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(string(y)).ToNot(ContainSubstring(strategy))
+	}
+
+	j, err := json.Marshal(AddressPoolSpec{Protocol: "layer2"})
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(string(j)).ToNot(ContainSubstring("allocationStrategy"))
+}
+
+func TestAllowedNamespacesSerialization(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	spec := AddressPoolSpec{
+		Protocol:          "layer2",
+		Addresses:         []string{"10.0.0.0/24"},
+		AllowedNamespaces: []string{"team-a", "team-b"},
+	}
+
+	j, err := json.Marshal(spec)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(string(j)).To(ContainSubstring(`"allowedNamespaces":["team-a","team-b"]`))
+	decoded := AddressPoolSpec{}
+	g.Expect(json.Unmarshal(j, &decoded)).To(Succeed())
+	g.Expect(decoded).To(Equal(spec))
+
+	// MetalLB has no namespace restriction, the operator enforces it
+	y, err := yaml.Marshal(spec)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(string(y)).ToNot(ContainSubstring("team-a"))
+
+	j, err = json.Marshal(AddressPoolSpec{Protocol: "layer2"})
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(string(j)).ToNot(ContainSubstring("allowedNamespaces"))
+}
+
+func TestCommunitiesSerialization(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	adv := BGPAdvertisement{Communities: []BGPCommunity{{ASN: 64512, Value: 100}, {WellKnown: "no-export"}, {Name: "gold"}}}
+
+	j, err := json.Marshal(adv)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(string(j)).To(Equal(`{"communities":[{"asn":64512,"value":100},{"wellKnown":"no-export"},{"name":"gold"}]}`))
+	decoded := BGPAdvertisement{}
+	g.Expect(json.Unmarshal(j, &decoded)).To(Succeed())
+	g.Expect(decoded).To(Equal(adv))
+
+	// MetalLB reads the communities in the 16-bit:16-bit form
+	y, err := yaml.Marshal(adv)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(string(y)).To(Equal("communities:\n- 64512:100\n- 65535:65281\n- gold\n"))
+	decoded = BGPAdvertisement{}
+	g.Expect(yaml.Unmarshal(y, &decoded)).To(Succeed())
+	g.Expect(decoded.Communities).To(Equal([]BGPCommunity{{ASN: 64512, Value: 100}, {ASN: 65535, Value: 65281}, {Name: "gold"}}))
+
+	g.Expect(yaml.Unmarshal([]byte("communities:\n- no-peer\n"), &decoded)).To(Succeed())
+	g.Expect(decoded.Communities).To(Equal([]BGPCommunity{{WellKnown: "no-peer"}}))
+	g.Expect(yaml.Unmarshal([]byte("communities:\n- no-export-please\n"), &decoded)).To(Succeed())
+	g.Expect(decoded.Communities).To(Equal([]BGPCommunity{{Name: "no-export-please"}}))
+	for _, invalid := range []string{"64512:65536", "64512", "64512:100:1", "No_Export"} {
+		g.Expect(yaml.Unmarshal([]byte("communities:\n- "+invalid+"\n"), &decoded)).ToNot(Succeed(), invalid)
+	}
+}
@@ -0,0 +1,343 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+)
+
+// addressPoolClient lists the existing AddressPools the webhook checks the
+// incoming one against, the check is skipped when nil.
+var addressPoolClient client.Reader
+
+func (addressPool *AddressPool) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	addressPoolClient = mgr.GetClient()
+	return ctrl.NewWebhookManagedBy(mgr).
+		For(addressPool).
+		Complete()
+}
+
+// +kubebuilder:webhook:verbs=create;update,path=/validate-metallb-io-v1alpha1-addresspool,mutating=false,failurePolicy=fail,groups=metallb.io,resources=addresspools,versions=v1alpha1,name=addresspoolvalidationwebhook.metallb.io,sideEffects=None,matchPolicy=Equivalent
+
+var _ webhook.Validator = &AddressPool{}
+
+// ValidateCreate implements webhook.Validator so a webhook will be registered for the type
+func (addressPool *AddressPool) ValidateCreate() error {
+	if err := addressPool.Validate(); err != nil {
+		return err
+	}
+	return addressPool.validateOverlaps()
+}
+
+// ValidateUpdate implements webhook.Validator so a webhook will be registered for the type
+func (addressPool *AddressPool) ValidateUpdate(old runtime.Object) error {
+	if err := addressPool.Validate(); err != nil {
+		return err
+	}
+	return addressPool.validateOverlaps()
+}
+
+// ValidateDelete implements webhook.Validator so a webhook will be registered for the type
+func (addressPool *AddressPool) ValidateDelete() error {
+	return nil
+}
+
+// Validate checks the AddressPool can be rendered into the MetalLB configuration.
+// The webhook and the AddressPool reconciler both run it, so the pools are
+// checked the same way on clusters without the webhook.
+func (addressPool *AddressPool) Validate() error {
+	var errs field.ErrorList
+	errs = append(errs, validatePoolName(addressPool.Name, field.NewPath("metadata", "name"))...)
+	if addressPool.Spec.Name != "" {
+		errs = append(errs, validatePoolName(addressPool.Spec.Name, field.NewPath("spec", "name"))...)
+	}
+	errs = append(errs, validateProtocol(addressPool.Spec.Protocol, field.NewPath("spec", "protocol"))...)
+	errs = append(errs, validateAddresses(addressPool.Spec.Addresses, field.NewPath("spec", "addresses"))...)
+	for i, namespace := range addressPool.Spec.AllowedNamespaces {
+		for _, msg := range validation.IsDNS1123Label(namespace) {
+			errs = append(errs, field.Invalid(field.NewPath("spec", "allowedNamespaces").Index(i), namespace, "invalid namespace: "+msg))
+		}
+	}
+	errs = append(errs, validateBGPAdvertisements(addressPool.Spec, field.NewPath("spec", "bgpAdvertisements"))...)
+	if addressPool.Spec.AutoExpand != nil {
+		errs = append(errs, validateAutoExpand(addressPool.Spec.AutoExpand, field.NewPath("spec", "autoExpand"))...)
+	}
+	if len(errs) == 0 {
+		return nil
+	}
+	return apierrors.NewInvalid(schema.GroupKind{Group: GroupVersion.Group, Kind: "AddressPool"}, addressPool.Name, errs)
+}
+
+// validatePoolName checks the name is a DNS-1123 label, as the pool names end
+// up in the MetalLB configuration and in the service annotations selecting a pool.
+func validatePoolName(name string, path *field.Path) field.ErrorList {
+	var errs field.ErrorList
+	for _, msg := range validation.IsDNS1123Label(name) {
+		errs = append(errs, field.Invalid(path, name, "invalid pool name: "+msg))
+	}
+	return errs
+}
+
+// validateAddresses checks each entry is either a CIDR, e.g. 192.168.10.0/24,
+// or a start-end range of addresses of the same family, as MetalLB accepts.
+func validateAddresses(addresses []string, path *field.Path) field.ErrorList {
+	var errs field.ErrorList
+	for i, r := range addresses {
+		if _, _, err := parseAddressRange(r); err != nil {
+			errs = append(errs, field.Invalid(path.Index(i), r, err.Error()))
+		}
+	}
+	return errs
+}
+
+// validateOverlaps checks the addresses of the pool do not overlap with the
+// ones of the existing pools. The previous version of the pool is skipped, so
+// updating a pool never conflicts with itself.
+func (addressPool *AddressPool) validateOverlaps() error {
+	if addressPoolClient == nil {
+		return nil
+	}
+	existing := &AddressPoolList{}
+	if err := addressPoolClient.List(context.Background(), existing); err != nil {
+		return apierrors.NewInternalError(fmt.Errorf("failed to list the existing AddressPools: %w", err))
+	}
+
+	errs := addressPool.overlaps(existing.Items)
+	if len(errs) == 0 {
+		return nil
+	}
+	return apierrors.NewInvalid(schema.GroupKind{Group: GroupVersion.Group, Kind: "AddressPool"}, addressPool.Name, errs)
+}
+
+// overlaps returns an error for each range of the pool overlapping with a
+// range of the other pools, skipping the pool itself.
+func (addressPool *AddressPool) overlaps(others []AddressPool) field.ErrorList {
+	var errs field.ErrorList
+	path := field.NewPath("spec", "addresses")
+	for i, r := range addressPool.Spec.Addresses {
+		first, last, err := parseAddressRange(r)
+		if err != nil {
+			continue
+		}
+		for _, pool := range others {
+			if pool.Name == addressPool.Name && pool.Namespace == addressPool.Namespace {
+				continue
+			}
+			for _, other := range pool.Spec.Addresses {
+				otherFirst, otherLast, err := parseAddressRange(other)
+				if err != nil {
+					continue
+				}
+				if bytes.Compare(first, otherLast) <= 0 && bytes.Compare(otherFirst, last) <= 0 {
+					errs = append(errs, field.Invalid(path.Index(i), r,
+						fmt.Sprintf("overlaps with range %s of AddressPool %s/%s", other, pool.Namespace, pool.Name)))
+				}
+			}
+		}
+	}
+	return errs
+}
+
+// ValidateCombined checks the resources can be rendered together into the
+// MetalLB configuration: the AddressPools must have distinct names and must
+// not overlap, the Communities must have distinct names, the BFD profile of
+// each BGPPeer must be one of the BFDProfiles of its namespace, and the named
+// communities of the bgpAdvertisements must be some of the Communities.
+// All the errors are returned at once, each on the latter of the conflicting
+// resources.
+func ValidateCombined(pools []AddressPool, peers []BGPPeer, profiles []BFDProfile, communities []Community) error {
+	errs := []error{}
+	for i := range pools {
+		pool := &pools[i]
+		var poolErrs field.ErrorList
+		for _, other := range pools[:i] {
+			if other.Name == pool.Name {
+				poolErrs = append(poolErrs, field.Duplicate(field.NewPath("metadata", "name"), pool.Name))
+				break
+			}
+		}
+		poolErrs = append(poolErrs, pool.overlaps(pools[:i])...)
+		poolErrs = append(poolErrs, pool.unknownCommunities(communities)...)
+		if len(poolErrs) > 0 {
+			errs = append(errs, apierrors.NewInvalid(schema.GroupKind{Group: GroupVersion.Group, Kind: "AddressPool"}, pool.Name, poolErrs))
+		}
+	}
+
+	profileNames := map[types.NamespacedName]bool{}
+	for _, profile := range profiles {
+		profileNames[types.NamespacedName{Namespace: profile.Namespace, Name: profile.Name}] = true
+	}
+	for _, peer := range peers {
+		if peer.Spec.BFDProfile == "" || profileNames[types.NamespacedName{Namespace: peer.Namespace, Name: peer.Spec.BFDProfile}] {
+			continue
+		}
+		errs = append(errs, apierrors.NewInvalid(schema.GroupKind{Group: GroupVersion.Group, Kind: "BGPPeer"}, peer.Name,
+			field.ErrorList{field.NotFound(field.NewPath("spec", "bfdProfile"), peer.Spec.BFDProfile)}))
+	}
+
+	for i, community := range communities {
+		for _, other := range communities[:i] {
+			if other.Spec.Name == community.Spec.Name {
+				errs = append(errs, apierrors.NewInvalid(schema.GroupKind{Group: GroupVersion.Group, Kind: "Community"}, community.Name,
+					field.ErrorList{field.Duplicate(field.NewPath("spec", "name"), community.Spec.Name)}))
+				break
+			}
+		}
+	}
+	return utilerrors.NewAggregate(errs)
+}
+
+// unknownCommunities returns an error for each named community of the
+// bgpAdvertisements of the pool which is none of the communities.
+func (pool *AddressPool) unknownCommunities(communities []Community) field.ErrorList {
+	names := map[string]bool{}
+	for _, community := range communities {
+		names[community.Spec.Name] = true
+	}
+	var errs field.ErrorList
+	for i, adv := range pool.Spec.BGPAdvertisements {
+		for j, community := range adv.Communities {
+			if community.Name != "" && !names[community.Name] {
+				errs = append(errs, field.NotFound(field.NewPath("spec", "bgpAdvertisements").Index(i).Child("communities").Index(j).Child("name"), community.Name))
+			}
+		}
+	}
+	return errs
+}
+
+// parseAddressRange returns the first and last addresses, in their 16 bytes
+// form, of a CIDR or a start-end range.
+func parseAddressRange(r string) (net.IP, net.IP, error) {
+	if strings.Contains(r, "/") {
+		_, cidr, err := net.ParseCIDR(r)
+		if err != nil {
+			return nil, nil, errors.New("invalid CIDR")
+		}
+		last := make(net.IP, len(cidr.IP))
+		for i := range cidr.IP {
+			last[i] = cidr.IP[i] | ^cidr.Mask[i]
+		}
+		return cidr.IP.To16(), last.To16(), nil
+	}
+	parts := strings.Split(r, "-")
+	if len(parts) != 2 {
+		return nil, nil, errors.New("must be a CIDR or a start-end range")
+	}
+	start, end := net.ParseIP(strings.TrimSpace(parts[0])), net.ParseIP(strings.TrimSpace(parts[1]))
+	switch {
+	case start == nil || end == nil:
+		return nil, nil, errors.New("invalid IP in range")
+	case (start.To4() == nil) != (end.To4() == nil):
+		return nil, nil, errors.New("start and end of the range are of different IP families")
+	case bytes.Compare(start.To16(), end.To16()) > 0:
+		return nil, nil, errors.New("start of the range is after its end")
+	}
+	return start.To16(), end.To16(), nil
+}
+
+// validateProtocol checks the protocol is one MetalLB announces the pools with.
+// The CRD schema rejects the other values too, but the pools created before it
+// did would otherwise end up in the ConfigMap and be dropped by MetalLB.
+func validateProtocol(protocol string, path *field.Path) field.ErrorList {
+	for _, p := range Protocols {
+		if protocol == p {
+			return nil
+		}
+	}
+	return field.ErrorList{field.NotSupported(path, protocol, Protocols)}
+}
+
+// validateBGPAdvertisements checks the advertisements are only set on bgp
+// pools, and their communities are either well-known or numeric.
+func validateBGPAdvertisements(spec AddressPoolSpec, path *field.Path) field.ErrorList {
+	var errs field.ErrorList
+	if len(spec.BGPAdvertisements) > 0 && spec.Protocol != ProtocolBGP {
+		return append(errs, field.Forbidden(path, "only allowed with the bgp protocol"))
+	}
+	for i, adv := range spec.BGPAdvertisements {
+		if adv.AggregationLength != nil && (*adv.AggregationLength < 0 || *adv.AggregationLength > 32) {
+			errs = append(errs, field.Invalid(path.Index(i).Child("aggregationLength"), *adv.AggregationLength,
+				"must be between 0 and 32"))
+		}
+		for j, community := range adv.Communities {
+			errs = append(errs, validateCommunity(community, path.Index(i).Child("communities").Index(j))...)
+		}
+	}
+	return errs
+}
+
+// validateCommunity checks a well-known community is known, and has no ASN
+// nor value set along with its name, and a reference to a Community is only a
+// valid community name.
+func validateCommunity(community BGPCommunity, path *field.Path) field.ErrorList {
+	var errs field.ErrorList
+	if community.Name != "" {
+		errs = append(errs, validateCommunityName(community.Name, path.Child("name"))...)
+		if community.WellKnown != "" || community.ASN != 0 || community.Value != 0 {
+			errs = append(errs, field.Forbidden(path, "wellKnown, asn and value must not be set along with name"))
+		}
+		return errs
+	}
+	if community.WellKnown == "" {
+		return errs
+	}
+	if _, ok := wellKnownCommunities[community.WellKnown]; !ok {
+		names := make([]string, 0, len(wellKnownCommunities))
+		for name := range wellKnownCommunities {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return append(errs, field.NotSupported(path.Child("wellKnown"), community.WellKnown, names))
+	}
+	if community.ASN != 0 || community.Value != 0 {
+		errs = append(errs, field.Forbidden(path, "asn and value must not be set along with wellKnown"))
+	}
+	return errs
+}
+
+// validateAutoExpand checks the supernet is a CIDR holding CIDRs of the
+// increment prefix length.
+func validateAutoExpand(autoExpand *AutoExpandSpec, path *field.Path) field.ErrorList {
+	var errs field.ErrorList
+	_, supernet, err := net.ParseCIDR(autoExpand.Supernet)
+	if err != nil {
+		return append(errs, field.Invalid(path.Child("supernet"), autoExpand.Supernet, "invalid CIDR"))
+	}
+	ones, bits := supernet.Mask.Size()
+	if autoExpand.Increment < ones || autoExpand.Increment > bits {
+		errs = append(errs, field.Invalid(path.Child("increment"), autoExpand.Increment,
+			fmt.Sprintf("must be between the supernet prefix length %d and %d", ones, bits)))
+	}
+	return errs
+}
@@ -0,0 +1,388 @@
+package v1alpha1
+
+import (
+	"strings"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestValidatePoolName(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	tests := []struct {
+		name     string
+		specName string
+		valid    bool
+	}{
+		{name: "gold", valid: true},
+		{name: "pool-1", valid: true},
+		{name: "pool-1", specName: "pool-1", valid: true},
+		{name: "Gold", valid: false},
+		{name: "gold pool", valid: false},
+		{name: "gold.pool", valid: false},
+		{name: "gold_pool", valid: false},
+		{name: "-gold", valid: false},
+		{name: "gold", specName: "Gold Pool", valid: false},
+	}
+
+	for _, test := range tests {
+		pool := &AddressPool{
+			ObjectMeta: metav1.ObjectMeta{Name: test.name},
+			Spec: AddressPoolSpec{
+				Name:      test.specName,
+				Protocol:  "layer2",
+				Addresses: []string{"10.0.0.0/24"},
+			},
+		}
+
+		for _, err := range []error{pool.ValidateCreate(), pool.ValidateUpdate(pool.DeepCopy())} {
+			if test.valid {
+				g.Expect(err).ToNot(HaveOccurred(), test.name)
+				continue
+			}
+			g.Expect(apierrors.IsInvalid(err)).To(BeTrue(), "%s: %v", test.name, err)
+			g.Expect(err.Error()).To(ContainSubstring("invalid pool name"))
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	tests := []struct {
+		desc  string
+		pool  AddressPool
+		valid bool
+	}{
+		{
+			desc: "layer2 pool",
+			pool: AddressPool{
+				ObjectMeta: metav1.ObjectMeta{Name: "gold"},
+				Spec:       AddressPoolSpec{Protocol: "layer2", Addresses: []string{"10.0.0.0/24"}},
+			},
+			valid: true,
+		},
+		{
+			desc: "bgp pool with a range",
+			pool: AddressPool{
+				ObjectMeta: metav1.ObjectMeta{Name: "silver"},
+				Spec:       AddressPoolSpec{Name: "silver", Protocol: "bgp", Addresses: []string{"10.0.1.1-10.0.1.10"}},
+			},
+			valid: true,
+		},
+		{
+			desc: "invalid name",
+			pool: AddressPool{
+				ObjectMeta: metav1.ObjectMeta{Name: "Bronze"},
+				Spec:       AddressPoolSpec{Protocol: "layer2", Addresses: []string{"10.0.2.0/24"}},
+			},
+		},
+		{
+			desc: "invalid protocol",
+			pool: AddressPool{
+				ObjectMeta: metav1.ObjectMeta{Name: "gold"},
+				Spec:       AddressPoolSpec{Protocol: "layer3", Addresses: []string{"10.0.0.0/24"}},
+			},
+		},
+		{
+			desc: "missing protocol",
+			pool: AddressPool{
+				ObjectMeta: metav1.ObjectMeta{Name: "gold"},
+				Spec:       AddressPoolSpec{Addresses: []string{"10.0.0.0/24"}},
+			},
+		},
+		{
+			desc: "auto expanded pool",
+			pool: AddressPool{
+				ObjectMeta: metav1.ObjectMeta{Name: "gold"},
+				Spec: AddressPoolSpec{Protocol: "layer2", Addresses: []string{"10.0.0.0/28"},
+					AutoExpand: &AutoExpandSpec{Supernet: "10.0.0.0/16", Increment: 28}},
+			},
+			valid: true,
+		},
+		{
+			desc: "invalid auto expand supernet",
+			pool: AddressPool{
+				ObjectMeta: metav1.ObjectMeta{Name: "gold"},
+				Spec: AddressPoolSpec{Protocol: "layer2", Addresses: []string{"10.0.0.0/28"},
+					AutoExpand: &AutoExpandSpec{Supernet: "10.0.0.0-10.0.255.255", Increment: 28}},
+			},
+		},
+		{
+			desc: "auto expand increment larger than the supernet",
+			pool: AddressPool{
+				ObjectMeta: metav1.ObjectMeta{Name: "gold"},
+				Spec: AddressPoolSpec{Protocol: "layer2", Addresses: []string{"10.0.0.0/28"},
+					AutoExpand: &AutoExpandSpec{Supernet: "10.0.0.0/24", Increment: 16}},
+			},
+		},
+		{
+			desc: "auto expand increment longer than the addresses",
+			pool: AddressPool{
+				ObjectMeta: metav1.ObjectMeta{Name: "gold"},
+				Spec: AddressPoolSpec{Protocol: "layer2", Addresses: []string{"10.0.0.0/28"},
+					AutoExpand: &AutoExpandSpec{Supernet: "10.0.0.0/24", Increment: 33}},
+			},
+		},
+		{
+			desc: "cidrs and ranges",
+			pool: AddressPool{
+				ObjectMeta: metav1.ObjectMeta{Name: "gold"},
+				Spec: AddressPoolSpec{Protocol: "layer2",
+					Addresses: []string{"192.168.10.0/24", "10.0.0.1-10.0.0.10", "2001:db8::/120", "2001:db8:1::1-2001:db8:1::10"}},
+			},
+			valid: true,
+		},
+		{
+			desc: "invalid cidr",
+			pool: AddressPool{
+				ObjectMeta: metav1.ObjectMeta{Name: "gold"},
+				Spec:       AddressPoolSpec{Protocol: "layer2", Addresses: []string{"192.168.10.0/33"}},
+			},
+		},
+		{
+			desc: "single address",
+			pool: AddressPool{
+				ObjectMeta: metav1.ObjectMeta{Name: "gold"},
+				Spec:       AddressPoolSpec{Protocol: "layer2", Addresses: []string{"192.168.10.1"}},
+			},
+		},
+		{
+			desc: "invalid range address",
+			pool: AddressPool{
+				ObjectMeta: metav1.ObjectMeta{Name: "gold"},
+				Spec:       AddressPoolSpec{Protocol: "layer2", Addresses: []string{"10.0.0.0/24", "10.0.1.1-10.0.1.300"}},
+			},
+		},
+		{
+			desc: "reversed range",
+			pool: AddressPool{
+				ObjectMeta: metav1.ObjectMeta{Name: "gold"},
+				Spec:       AddressPoolSpec{Protocol: "layer2", Addresses: []string{"10.0.1.10-10.0.1.1"}},
+			},
+		},
+		{
+			desc: "mixed family range",
+			pool: AddressPool{
+				ObjectMeta: metav1.ObjectMeta{Name: "gold"},
+				Spec:       AddressPoolSpec{Protocol: "layer2", Addresses: []string{"10.0.1.1-2001:db8::1"}},
+			},
+		},
+		{
+			desc: "invalid spec name",
+			pool: AddressPool{
+				ObjectMeta: metav1.ObjectMeta{Name: "bronze"},
+				Spec:       AddressPoolSpec{Name: "bronze_pool", Protocol: "layer2", Addresses: []string{"10.0.2.0/24"}},
+			},
+		},
+		{
+			desc: "bgp advertisements",
+			pool: AddressPool{
+				ObjectMeta: metav1.ObjectMeta{Name: "gold"},
+				Spec: AddressPoolSpec{Protocol: "bgp", Addresses: []string{"10.0.1.0/24"},
+					BGPAdvertisements: []BGPAdvertisement{{AggregationLength: int32Ptr(24), Communities: []BGPCommunity{{ASN: 64512, Value: 100}, {Value: 65535}, {WellKnown: "no-export"}}}, {}}},
+			},
+			valid: true,
+		},
+		{
+			desc: "bgp advertisements of a layer2 pool",
+			pool: AddressPool{
+				ObjectMeta: metav1.ObjectMeta{Name: "gold"},
+				Spec: AddressPoolSpec{Protocol: "layer2", Addresses: []string{"10.0.1.0/24"},
+					BGPAdvertisements: []BGPAdvertisement{{Communities: []BGPCommunity{{ASN: 64512, Value: 100}}}}},
+			},
+		},
+		{
+			desc: "invalid aggregation length",
+			pool: AddressPool{
+				ObjectMeta: metav1.ObjectMeta{Name: "gold"},
+				Spec: AddressPoolSpec{Protocol: "bgp", Addresses: []string{"10.0.1.0/24"},
+					BGPAdvertisements: []BGPAdvertisement{{AggregationLength: int32Ptr(33)}}},
+			},
+		},
+		{
+			desc: "unknown well-known community",
+			pool: AddressPool{
+				ObjectMeta: metav1.ObjectMeta{Name: "gold"},
+				Spec: AddressPoolSpec{Protocol: "bgp", Addresses: []string{"10.0.1.0/24"},
+					BGPAdvertisements: []BGPAdvertisement{{Communities: []BGPCommunity{{WellKnown: "no-export-please"}}}}},
+			},
+		},
+		{
+			desc: "well-known community with a value",
+			pool: AddressPool{
+				ObjectMeta: metav1.ObjectMeta{Name: "gold"},
+				Spec: AddressPoolSpec{Protocol: "bgp", Addresses: []string{"10.0.1.0/24"},
+					BGPAdvertisements: []BGPAdvertisement{{Communities: []BGPCommunity{{WellKnown: "no-export", Value: 100}}}}},
+			},
+		},
+		{
+			desc: "allowed namespaces",
+			pool: AddressPool{
+				ObjectMeta: metav1.ObjectMeta{Name: "gold"},
+				Spec: AddressPoolSpec{Protocol: "layer2", Addresses: []string{"10.0.1.0/24"},
+					AllowedNamespaces: []string{"team-a", "team-b"}},
+			},
+			valid: true,
+		},
+		{
+			desc: "invalid allowed namespace",
+			pool: AddressPool{
+				ObjectMeta: metav1.ObjectMeta{Name: "gold"},
+				Spec: AddressPoolSpec{Protocol: "layer2", Addresses: []string{"10.0.1.0/24"},
+					AllowedNamespaces: []string{"team-a", "Team_B"}},
+			},
+		},
+		{
+			desc: "empty allowed namespace",
+			pool: AddressPool{
+				ObjectMeta: metav1.ObjectMeta{Name: "gold"},
+				Spec: AddressPoolSpec{Protocol: "layer2", Addresses: []string{"10.0.1.0/24"},
+					AllowedNamespaces: []string{""}},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		err := test.pool.Validate()
+		if test.valid {
+			g.Expect(err).ToNot(HaveOccurred(), test.desc)
+			continue
+		}
+		g.Expect(apierrors.IsInvalid(err)).To(BeTrue(), "%s: %v", test.desc, err)
+		// The webhook rejects the pool with the same error
+		g.Expect(test.pool.ValidateCreate()).To(Equal(err), test.desc)
+	}
+}
+
+func TestValidateProtocolMessage(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	pool := &AddressPool{
+		ObjectMeta: metav1.ObjectMeta{Name: "gold"},
+		Spec:       AddressPoolSpec{Protocol: "layer3", Addresses: []string{"10.0.0.0/24"}},
+	}
+	err := pool.ValidateCreate()
+	g.Expect(apierrors.IsInvalid(err)).To(BeTrue(), "%v", err)
+	g.Expect(err.Error()).To(ContainSubstring(`spec.protocol: Unsupported value: "layer3"`))
+}
+
+func TestValidateOverlaps(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	s := runtime.NewScheme()
+	g.Expect(AddToScheme(s)).To(Succeed())
+	gold := &AddressPool{
+		ObjectMeta: metav1.ObjectMeta{Name: "gold", Namespace: "metallb-system"},
+		Spec:       AddressPoolSpec{Protocol: "layer2", Addresses: []string{"10.0.0.0/24", "10.0.1.1-10.0.1.10"}},
+	}
+	addressPoolClient = fake.NewClientBuilder().WithScheme(s).WithObjects(gold).Build()
+	defer func() { addressPoolClient = nil }()
+
+	silver := &AddressPool{
+		ObjectMeta: metav1.ObjectMeta{Name: "silver", Namespace: "metallb-system"},
+		Spec:       AddressPoolSpec{Protocol: "layer2", Addresses: []string{"10.0.2.0/24", "10.0.1.10-10.0.1.20"}},
+	}
+	err := silver.ValidateCreate()
+	g.Expect(apierrors.IsInvalid(err)).To(BeTrue(), "%v", err)
+	g.Expect(err.Error()).To(ContainSubstring("spec.addresses[1]"))
+	g.Expect(err.Error()).To(ContainSubstring("overlaps with range 10.0.1.1-10.0.1.10 of AddressPool metallb-system/gold"))
+
+	silver.Spec.Addresses = []string{"10.0.2.0/24", "10.0.1.11-10.0.1.20"}
+	g.Expect(silver.ValidateCreate()).To(Succeed())
+
+	// A pool with the same name in another namespace is another pool
+	other := gold.DeepCopy()
+	other.Namespace = "other"
+	g.Expect(apierrors.IsInvalid(other.ValidateCreate())).To(BeTrue())
+
+	// Updating a pool does not conflict with its previous ranges
+	updated := gold.DeepCopy()
+	updated.Spec.Addresses = []string{"10.0.0.0/23"}
+	g.Expect(updated.ValidateUpdate(gold)).To(Succeed())
+	updated.Spec.Addresses = []string{"10.0.0.0/23", "2001:db8::/120"}
+	g.Expect(updated.ValidateUpdate(gold)).To(Succeed())
+	g.Expect(silver.ValidateUpdate(silver)).To(Succeed())
+	silver.Spec.Addresses = []string{"10.0.0.128/25"}
+	g.Expect(apierrors.IsInvalid(silver.ValidateUpdate(silver))).To(BeTrue())
+}
+
+func TestValidateCombined(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	pool := func(namespace, name string, addresses ...string) AddressPool {
+		return AddressPool{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace},
+			Spec:       AddressPoolSpec{Protocol: "layer2", Addresses: addresses},
+		}
+	}
+
+	g.Expect(ValidateCombined(nil, nil, nil, nil)).To(Succeed())
+	g.Expect(ValidateCombined([]AddressPool{
+		pool("ns", "gold", "10.0.0.0/24"),
+		pool("ns", "silver", "10.0.1.0/24", "2001:db8::/120"),
+	}, nil, nil, nil)).To(Succeed())
+
+	err := ValidateCombined([]AddressPool{
+		pool("ns", "gold", "10.0.0.0/24"),
+		pool("ns", "silver", "10.0.1.0/24", "10.0.0.200-10.0.0.210"),
+		pool("other", "gold", "10.0.2.0/24"),
+	}, nil, nil, nil)
+	g.Expect(err).To(HaveOccurred())
+	// Each conflict is reported once, on the latter pool
+	g.Expect(err.Error()).To(ContainSubstring("spec.addresses[1]: Invalid value: \"10.0.0.200-10.0.0.210\": overlaps with range 10.0.0.0/24 of AddressPool ns/gold"))
+	g.Expect(err.Error()).To(ContainSubstring("metadata.name: Duplicate value: \"gold\""))
+	g.Expect(strings.Count(err.Error(), "overlaps")).To(Equal(1))
+}
+
+func TestValidateCombinedReferences(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	pool := AddressPool{
+		ObjectMeta: metav1.ObjectMeta{Name: "gold", Namespace: "pools"},
+		Spec: AddressPoolSpec{
+			Protocol:  ProtocolBGP,
+			Addresses: []string{"10.0.0.0/24"},
+			BGPAdvertisements: []BGPAdvertisement{{
+				Communities: []BGPCommunity{{Name: "premium"}, {WellKnown: "no-export"}},
+			}},
+		},
+	}
+	peer := BGPPeer{
+		ObjectMeta: metav1.ObjectMeta{Name: "tor", Namespace: "metallb-system"},
+		Spec:       BGPPeerSpec{MyASN: 64512, PeerASN: 64513, PeerAddress: "10.0.0.1", BFDProfile: "fast"},
+	}
+	profile := BFDProfile{ObjectMeta: metav1.ObjectMeta{Name: "fast", Namespace: "metallb-system"}}
+	community := func(name, communityName string) Community {
+		return Community{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "metallb-system"},
+			Spec:       CommunitySpec{Name: communityName, Value: "64512:100"},
+		}
+	}
+
+	g.Expect(ValidateCombined([]AddressPool{pool}, []BGPPeer{peer}, []BFDProfile{profile},
+		[]Community{community("premium", "premium")})).To(Succeed())
+
+	// The BFD profile must be in the namespace of the peer
+	otherProfile := profile
+	otherProfile.Namespace = "other"
+	err := ValidateCombined([]AddressPool{pool}, []BGPPeer{peer}, []BFDProfile{otherProfile},
+		[]Community{community("premium", "premium"), community("gold", "premium")})
+	g.Expect(err).To(HaveOccurred())
+	g.Expect(err.Error()).To(ContainSubstring(`BGPPeer.metallb.io "tor" is invalid: spec.bfdProfile: Not found: "fast"`))
+	g.Expect(err.Error()).To(ContainSubstring(`Community.metallb.io "gold" is invalid: spec.name: Duplicate value: "premium"`))
+	g.Expect(err.Error()).ToNot(ContainSubstring("AddressPool"))
+
+	err = ValidateCombined([]AddressPool{pool}, nil, nil, []Community{community("silver", "silver")})
+	g.Expect(err).To(HaveOccurred())
+	g.Expect(err.Error()).To(Equal(`AddressPool.metallb.io "gold" is invalid: spec.bgpAdvertisements[0].communities[0].name: Not found: "premium"`))
+}
+
+func int32Ptr(i int32) *int32 {
+	return &i
+}
@@ -0,0 +1,100 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// BFDProfileSpec defines the desired state of BFDProfile. The unset fields
+// take the MetalLB defaults.
+type BFDProfileSpec struct {
+	// ReceiveInterval is the minimum interval this system is capable of
+	// receiving control packets, in milliseconds.
+	// +optional
+	// +kubebuilder:validation:Minimum=10
+	// +kubebuilder:validation:Maximum=60000
+	ReceiveInterval *uint32 `json:"receiveInterval,omitempty"`
+
+	// TransmitInterval is the minimum transmission interval, less jitter,
+	// this system wants to use to send BFD control packets, in milliseconds.
+	// +optional
+	// +kubebuilder:validation:Minimum=10
+	// +kubebuilder:validation:Maximum=60000
+	TransmitInterval *uint32 `json:"transmitInterval,omitempty"`
+
+	// DetectMultiplier is the number of packets lost before the session is
+	// considered down.
+	// +optional
+	// +kubebuilder:validation:Minimum=2
+	// +kubebuilder:validation:Maximum=255
+	DetectMultiplier *uint32 `json:"detectMultiplier,omitempty"`
+
+	// EchoInterval is the minimal echo receive transmission interval this
+	// system is capable of handling, in milliseconds.
+	// +optional
+	// +kubebuilder:validation:Minimum=10
+	// +kubebuilder:validation:Maximum=60000
+	EchoInterval *uint32 `json:"echoInterval,omitempty"`
+
+	// EchoMode enables the echo transmission mode.
+	// +optional
+	EchoMode bool `json:"echoMode,omitempty"`
+
+	// PassiveMode marks the session as passive: it waits for control
+	// packets from the peer before it starts replying.
+	// +optional
+	PassiveMode bool `json:"passiveMode,omitempty"`
+
+	// MinimumTTL is the minimum expected TTL of an incoming BFD control
+	// packet, only for multi hop sessions.
+	// +optional
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=254
+	MinimumTTL *uint32 `json:"minimumTtl,omitempty"`
+}
+
+// BFDProfileStatus defines the observed state of BFDProfile
+type BFDProfileStatus struct {
+	// Conditions show whether the BFDProfile was rendered into the MetalLB configuration
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+
+// BFDProfile is the Schema for the bfdprofiles API
+type BFDProfile struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   BFDProfileSpec   `json:"spec,omitempty"`
+	Status BFDProfileStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// BFDProfileList contains a list of BFDProfile
+type BFDProfileList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []BFDProfile `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&BFDProfile{}, &BFDProfileList{})
+}
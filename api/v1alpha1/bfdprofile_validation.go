@@ -0,0 +1,54 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"fmt"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+// Validate checks the BFDProfile is accepted by MetalLB, the values out of the
+// ranges MetalLB accepts are rejected. The BFDProfile reconciler runs it, and
+// leaves the profiles failing it out of the MetalLB configuration.
+func (profile *BFDProfile) Validate() error {
+	var errs field.ErrorList
+	for _, msg := range validation.IsDNS1123Label(profile.Name) {
+		errs = append(errs, field.Invalid(field.NewPath("metadata", "name"), profile.Name, "invalid profile name: "+msg))
+	}
+	spec := field.NewPath("spec")
+	errs = append(errs, validateRange(profile.Spec.ReceiveInterval, 10, 60000, spec.Child("receiveInterval"))...)
+	errs = append(errs, validateRange(profile.Spec.TransmitInterval, 10, 60000, spec.Child("transmitInterval"))...)
+	errs = append(errs, validateRange(profile.Spec.DetectMultiplier, 2, 255, spec.Child("detectMultiplier"))...)
+	errs = append(errs, validateRange(profile.Spec.EchoInterval, 10, 60000, spec.Child("echoInterval"))...)
+	errs = append(errs, validateRange(profile.Spec.MinimumTTL, 1, 254, spec.Child("minimumTtl"))...)
+	if len(errs) == 0 {
+		return nil
+	}
+	return apierrors.NewInvalid(schema.GroupKind{Group: GroupVersion.Group, Kind: "BFDProfile"}, profile.Name, errs)
+}
+
+// validateRange checks the value, when set, is between min and max included.
+func validateRange(value *uint32, min, max uint32, path *field.Path) field.ErrorList {
+	if value == nil || (*value >= min && *value <= max) {
+		return nil
+	}
+	return field.ErrorList{field.Invalid(path, *value, fmt.Sprintf("must be between %d and %d", min, max))}
+}
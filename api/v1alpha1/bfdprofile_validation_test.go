@@ -0,0 +1,50 @@
+package v1alpha1
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func uint32Ptr(v uint32) *uint32 {
+	return &v
+}
+
+func TestValidateBFDProfile(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	tests := []struct {
+		desc  string
+		name  string
+		spec  BFDProfileSpec
+		valid bool
+	}{
+		{desc: "defaults", name: "default", valid: true},
+		{
+			desc: "all fields at their bounds",
+			name: "fast",
+			spec: BFDProfileSpec{ReceiveInterval: uint32Ptr(10), TransmitInterval: uint32Ptr(60000),
+				DetectMultiplier: uint32Ptr(2), EchoInterval: uint32Ptr(10), EchoMode: true, PassiveMode: true,
+				MinimumTTL: uint32Ptr(254)},
+			valid: true,
+		},
+		{desc: "invalid name", name: "Fast", spec: BFDProfileSpec{}},
+		{desc: "receive interval too short", name: "fast", spec: BFDProfileSpec{ReceiveInterval: uint32Ptr(9)}},
+		{desc: "transmit interval too long", name: "fast", spec: BFDProfileSpec{TransmitInterval: uint32Ptr(60001)}},
+		{desc: "echo interval too short", name: "fast", spec: BFDProfileSpec{EchoInterval: uint32Ptr(0)}},
+		{desc: "detect multiplier too low", name: "fast", spec: BFDProfileSpec{DetectMultiplier: uint32Ptr(1)}},
+		{desc: "minimum ttl too high", name: "fast", spec: BFDProfileSpec{MinimumTTL: uint32Ptr(255)}},
+	}
+
+	for _, test := range tests {
+		profile := &BFDProfile{ObjectMeta: metav1.ObjectMeta{Name: test.name}, Spec: test.spec}
+		err := profile.Validate()
+		if test.valid {
+			g.Expect(err).ToNot(HaveOccurred(), test.desc)
+			continue
+		}
+		g.Expect(apierrors.IsInvalid(err)).To(BeTrue(), "%s: %v", test.desc, err)
+	}
+}
@@ -0,0 +1,128 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// PasswordSecretKey is the key of the password in the PasswordSecret of a BGPPeer.
+const PasswordSecretKey = "password"
+
+// BGPPeerSpec defines the desired state of BGPPeer
+type BGPPeerSpec struct {
+	// MyASN is the AS number MetalLB uses for its end of the session.
+	// +kubebuilder:validation:Minimum=1
+	MyASN uint32 `json:"myASN"`
+
+	// PeerASN is the AS number of the peer, the session is iBGP when it is
+	// the same as MyASN.
+	// +kubebuilder:validation:Minimum=1
+	PeerASN uint32 `json:"peerASN"`
+
+	// PeerAddress is the IP address MetalLB connects to.
+	PeerAddress string `json:"peerAddress"`
+
+	// PeerPort is the port MetalLB connects to, 179 when unset.
+	// +optional
+	// +kubebuilder:validation:Minimum=0
+	// +kubebuilder:validation:Maximum=65535
+	PeerPort uint16 `json:"peerPort,omitempty"`
+
+	// HoldTime is the hold time requested to the peer, at least 3s, e.g.
+	// 90s. MetalLB requests 90s when unset.
+	// +optional
+	HoldTime metav1.Duration `json:"holdTime,omitempty"`
+
+	// KeepaliveTime is the interval between the keepalive messages sent to the
+	// peer, at most one third of the HoldTime, e.g. 30s. MetalLB derives it from
+	// the hold time when unset. It is only read since MetalLB v0.12.
+	// +optional
+	KeepaliveTime metav1.Duration `json:"keepaliveTime,omitempty"`
+
+	// RouterID is the IPv4 address MetalLB uses as its BGP router ID on the
+	// session, the one of the node when unset.
+	// +optional
+	RouterID string `json:"routerID,omitempty"`
+
+	// EBGPMultiHop allows the eBGP session with a peer that is not directly
+	// connected, e.g. a route reflector a few hops away.
+	// +optional
+	EBGPMultiHop bool `json:"ebgpMultiHop,omitempty"`
+
+	// BFDProfile is the name of the BFDProfile of the operator namespace
+	// enabling BFD on the session. The peer is left out of the MetalLB
+	// configuration while the profile does not exist.
+	// +optional
+	BFDProfile string `json:"bfdProfile,omitempty"`
+
+	// PasswordSecret is a Secret of the BGPPeer namespace holding the password
+	// of the session, for TCP MD5 authentication, in its password key. The
+	// peer is left out of the MetalLB configuration while the Secret or its
+	// key does not exist.
+	// +optional
+	PasswordSecret *corev1.LocalObjectReference `json:"passwordSecret,omitempty"`
+
+	// GracefulRestart enables BGP graceful restart on the session, so the
+	// peer keeps the routes advertised by a restarting speaker. It is only
+	// supported with the frr BGP backend.
+	// +optional
+	GracefulRestart *bool `json:"gracefulRestart,omitempty"`
+
+	// ConnectTime is the interval between the attempts to connect to the peer,
+	// at least 1s, e.g. 30s for a slow peer. It is only supported with the frr
+	// BGP backend, which waits 120s when unset.
+	// +optional
+	ConnectTime *metav1.Duration `json:"connectTime,omitempty"`
+
+	// NodeSelectors limits the nodes peering with the peer to the ones
+	// matching any of the selectors. All the nodes peer with it when unset.
+	// +optional
+	NodeSelectors []metav1.LabelSelector `json:"nodeSelectors,omitempty"`
+}
+
+// BGPPeerStatus defines the observed state of BGPPeer
+type BGPPeerStatus struct {
+	// Conditions show whether the BGPPeer was rendered into the MetalLB configuration
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+
+// BGPPeer is the Schema for the bgppeers API
+type BGPPeer struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   BGPPeerSpec   `json:"spec"`
+	Status BGPPeerStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// BGPPeerList contains a list of BGPPeer
+type BGPPeerList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []BGPPeer `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&BGPPeer{}, &BGPPeerList{})
+}
@@ -0,0 +1,144 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"fmt"
+	"net"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+
+	"github.com/metallb/metallb-operator/api/v1beta1"
+)
+
+// minHoldTime is the shortest hold time accepted by MetalLB.
+const minHoldTime = 3 * time.Second
+
+// minConnectTime is the shortest connect time accepted by FRR.
+const minConnectTime = time.Second
+
+// defaultHoldTime is the hold time MetalLB requests when the peer has none.
+const defaultHoldTime = 90 * time.Second
+
+// Validate checks the BGPPeer is accepted by MetalLB. The webhook and the
+// BGPPeer reconciler both run it, the reconciler leaves the peers failing it
+// out of the MetalLB configuration.
+func (peer *BGPPeer) Validate() error {
+	var errs field.ErrorList
+	if peer.Spec.MyASN == 0 {
+		errs = append(errs, field.Required(field.NewPath("spec", "myASN"), "the local AS number must be set"))
+	}
+	if peer.Spec.PeerASN == 0 {
+		errs = append(errs, field.Required(field.NewPath("spec", "peerASN"), "the AS number of the peer must be set"))
+	}
+	if net.ParseIP(peer.Spec.PeerAddress) == nil {
+		errs = append(errs, field.Invalid(field.NewPath("spec", "peerAddress"), peer.Spec.PeerAddress, "invalid IP address"))
+	}
+	if holdTime := peer.Spec.HoldTime.Duration; holdTime != 0 && holdTime < minHoldTime {
+		errs = append(errs, field.Invalid(field.NewPath("spec", "holdTime"), holdTime.String(), "must be at least "+minHoldTime.String()))
+	}
+	errs = append(errs, peer.validateKeepaliveTime()...)
+	if peer.Spec.ConnectTime != nil && peer.Spec.ConnectTime.Duration < minConnectTime {
+		errs = append(errs, field.Invalid(field.NewPath("spec", "connectTime"), peer.Spec.ConnectTime.Duration.String(), "must be at least "+minConnectTime.String()))
+	}
+	if routerID := peer.Spec.RouterID; routerID != "" {
+		if ip := net.ParseIP(routerID); ip == nil || ip.To4() == nil {
+			errs = append(errs, field.Invalid(field.NewPath("spec", "routerID"), routerID, "invalid IPv4 address"))
+		}
+	}
+	if peer.Spec.PasswordSecret != nil && peer.Spec.PasswordSecret.Name == "" {
+		errs = append(errs, field.Required(field.NewPath("spec", "passwordSecret", "name"), "the name of the password Secret must be set"))
+	}
+	for i := range peer.Spec.NodeSelectors {
+		if _, err := metav1.LabelSelectorAsSelector(&peer.Spec.NodeSelectors[i]); err != nil {
+			errs = append(errs, field.Invalid(field.NewPath("spec", "nodeSelectors").Index(i), peer.Spec.NodeSelectors[i], err.Error()))
+		}
+	}
+	if len(errs) == 0 {
+		return nil
+	}
+	return apierrors.NewInvalid(schema.GroupKind{Group: GroupVersion.Group, Kind: "BGPPeer"}, peer.Name, errs)
+}
+
+// ValidateBackend checks the BGPPeer only sets the fields supported by the
+// given BGP backend of the MetalLB resource, the native one when empty. The
+// webhook and the BGPPeer reconciler both run it along with Validate.
+func (peer *BGPPeer) ValidateBackend(bgpBackend string) error {
+	if bgpBackend == v1beta1.BGPBackendFRR {
+		return nil
+	}
+	if bgpBackend == "" {
+		bgpBackend = v1beta1.BGPBackendNative
+	}
+	detail := fmt.Sprintf("only supported with the %s BGP backend, the MetalLB resource uses the %s one", v1beta1.BGPBackendFRR, bgpBackend)
+	var errs field.ErrorList
+	if peer.Spec.GracefulRestart != nil && *peer.Spec.GracefulRestart {
+		errs = append(errs, field.Forbidden(field.NewPath("spec", "gracefulRestart"), detail))
+	}
+	if peer.Spec.ConnectTime != nil {
+		errs = append(errs, field.Forbidden(field.NewPath("spec", "connectTime"), detail))
+	}
+	if len(errs) == 0 {
+		return nil
+	}
+	return apierrors.NewInvalid(schema.GroupKind{Group: GroupVersion.Group, Kind: "BGPPeer"}, peer.Name, errs)
+}
+
+// ValidateMyASN checks the BGPPeer uses the local AS number of the other given
+// peers, as the frr BGP backend runs a single BGP instance on each node. The
+// BGPPeer webhook runs it against the existing peers with that backend.
+func (peer *BGPPeer) ValidateMyASN(peers []BGPPeer) error {
+	for _, other := range peers {
+		if other.Namespace == peer.Namespace && other.Name == peer.Name {
+			continue
+		}
+		if other.Spec.MyASN != peer.Spec.MyASN {
+			errs := field.ErrorList{field.Invalid(field.NewPath("spec", "myASN"), int64(peer.Spec.MyASN),
+				fmt.Sprintf("must be the local AS number %d of BGPPeer %s/%s, the %s BGP backend uses a single one for all the peers",
+					other.Spec.MyASN, other.Namespace, other.Name, v1beta1.BGPBackendFRR))}
+			return apierrors.NewInvalid(schema.GroupKind{Group: GroupVersion.Group, Kind: "BGPPeer"}, peer.Name, errs)
+		}
+	}
+	return nil
+}
+
+// validateKeepaliveTime checks the keepalive time is at most one third of the
+// hold time, as BGP implementations expect, so that a couple of keepalive
+// messages can be lost before the session expires.
+func (peer *BGPPeer) validateKeepaliveTime() field.ErrorList {
+	keepaliveTime := peer.Spec.KeepaliveTime.Duration
+	if keepaliveTime == 0 {
+		return nil
+	}
+	path := field.NewPath("spec", "keepaliveTime")
+	if keepaliveTime < 0 {
+		return field.ErrorList{field.Invalid(path, keepaliveTime.String(), "must be positive")}
+	}
+	holdTime := peer.Spec.HoldTime.Duration
+	if holdTime == 0 {
+		holdTime = defaultHoldTime
+	}
+	if keepaliveTime*3 > holdTime {
+		return field.ErrorList{field.Invalid(path, keepaliveTime.String(),
+			fmt.Sprintf("must be at most one third of the hold time %s, i.e. %s", holdTime, holdTime/3))}
+	}
+	return nil
+}
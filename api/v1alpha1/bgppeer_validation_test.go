@@ -0,0 +1,277 @@
+package v1alpha1
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/metallb/metallb-operator/api/v1beta1"
+)
+
+func TestValidateBGPPeer(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	tests := []struct {
+		desc  string
+		spec  BGPPeerSpec
+		valid bool
+	}{
+		{
+			desc:  "ebgp peer",
+			spec:  BGPPeerSpec{MyASN: 64512, PeerASN: 64513, PeerAddress: "10.0.0.1"},
+			valid: true,
+		},
+		{
+			desc: "ipv6 peer with port and hold time",
+			spec: BGPPeerSpec{MyASN: 64512, PeerASN: 64512, PeerAddress: "2001:db8::1", PeerPort: 1179,
+				HoldTime: metav1.Duration{Duration: 30 * time.Second}},
+			valid: true,
+		},
+		{
+			desc: "missing local ASN",
+			spec: BGPPeerSpec{PeerASN: 64513, PeerAddress: "10.0.0.1"},
+		},
+		{
+			desc: "missing peer ASN",
+			spec: BGPPeerSpec{MyASN: 64512, PeerAddress: "10.0.0.1"},
+		},
+		{
+			desc: "peer address is a hostname",
+			spec: BGPPeerSpec{MyASN: 64512, PeerASN: 64513, PeerAddress: "router.example.com"},
+		},
+		{
+			desc: "password secret",
+			spec: BGPPeerSpec{MyASN: 64512, PeerASN: 64513, PeerAddress: "10.0.0.1",
+				PasswordSecret: &corev1.LocalObjectReference{Name: "bgp-password"}},
+			valid: true,
+		},
+		{
+			desc: "password secret without a name",
+			spec: BGPPeerSpec{MyASN: 64512, PeerASN: 64513, PeerAddress: "10.0.0.1",
+				PasswordSecret: &corev1.LocalObjectReference{}},
+		},
+		{
+			desc: "node selectors",
+			spec: BGPPeerSpec{MyASN: 64512, PeerASN: 64513, PeerAddress: "10.0.0.1",
+				NodeSelectors: []metav1.LabelSelector{
+					{MatchLabels: map[string]string{"rack": "r1"}},
+					{MatchExpressions: []metav1.LabelSelectorRequirement{{Key: "rack", Operator: metav1.LabelSelectorOpNotIn, Values: []string{"r2"}}}},
+				}},
+			valid: true,
+		},
+		{
+			desc: "node selector with an invalid label value",
+			spec: BGPPeerSpec{MyASN: 64512, PeerASN: 64513, PeerAddress: "10.0.0.1",
+				NodeSelectors: []metav1.LabelSelector{{MatchLabels: map[string]string{"rack": "r 1"}}}},
+		},
+		{
+			desc: "node selector with an unknown operator",
+			spec: BGPPeerSpec{MyASN: 64512, PeerASN: 64513, PeerAddress: "10.0.0.1",
+				NodeSelectors: []metav1.LabelSelector{{MatchExpressions: []metav1.LabelSelectorRequirement{{Key: "rack", Operator: "Near"}}}}},
+		},
+		{
+			desc: "multi-hop ebgp peer with router id",
+			spec: BGPPeerSpec{MyASN: 64512, PeerASN: 64513, PeerAddress: "2001:db8::1",
+				RouterID: "10.10.10.10", EBGPMultiHop: true},
+			valid: true,
+		},
+		{
+			desc: "router id is an ipv6 address",
+			spec: BGPPeerSpec{MyASN: 64512, PeerASN: 64513, PeerAddress: "10.0.0.1", RouterID: "2001:db8::1"},
+		},
+		{
+			desc: "router id is not an address",
+			spec: BGPPeerSpec{MyASN: 64512, PeerASN: 64513, PeerAddress: "10.0.0.1", RouterID: "router-1"},
+		},
+		{
+			desc: "hold time too short",
+			spec: BGPPeerSpec{MyASN: 64512, PeerASN: 64513, PeerAddress: "10.0.0.1",
+				HoldTime: metav1.Duration{Duration: time.Second}},
+		},
+		{
+			desc: "keepalive time of a third of the hold time",
+			spec: BGPPeerSpec{MyASN: 64512, PeerASN: 64513, PeerAddress: "10.0.0.1",
+				HoldTime: metav1.Duration{Duration: 9 * time.Second}, KeepaliveTime: metav1.Duration{Duration: 3 * time.Second}},
+			valid: true,
+		},
+		{
+			desc: "keepalive time of a third of the default hold time",
+			spec: BGPPeerSpec{MyASN: 64512, PeerASN: 64513, PeerAddress: "10.0.0.1",
+				KeepaliveTime: metav1.Duration{Duration: 30 * time.Second}},
+			valid: true,
+		},
+		{
+			desc: "keepalive time longer than a third of the hold time",
+			spec: BGPPeerSpec{MyASN: 64512, PeerASN: 64513, PeerAddress: "10.0.0.1",
+				HoldTime: metav1.Duration{Duration: 9 * time.Second}, KeepaliveTime: metav1.Duration{Duration: 4 * time.Second}},
+		},
+		{
+			desc: "keepalive time longer than a third of the default hold time",
+			spec: BGPPeerSpec{MyASN: 64512, PeerASN: 64513, PeerAddress: "10.0.0.1",
+				KeepaliveTime: metav1.Duration{Duration: time.Minute}},
+		},
+		{
+			desc: "connect time",
+			spec: BGPPeerSpec{MyASN: 64512, PeerASN: 64513, PeerAddress: "10.0.0.1",
+				ConnectTime: &metav1.Duration{Duration: 30 * time.Second}},
+			valid: true,
+		},
+		{
+			desc: "connect time too short",
+			spec: BGPPeerSpec{MyASN: 64512, PeerASN: 64513, PeerAddress: "10.0.0.1",
+				ConnectTime: &metav1.Duration{Duration: 500 * time.Millisecond}},
+		},
+		{
+			desc: "zero connect time",
+			spec: BGPPeerSpec{MyASN: 64512, PeerASN: 64513, PeerAddress: "10.0.0.1",
+				ConnectTime: &metav1.Duration{}},
+		},
+		{
+			desc: "negative keepalive time",
+			spec: BGPPeerSpec{MyASN: 64512, PeerASN: 64513, PeerAddress: "10.0.0.1",
+				KeepaliveTime: metav1.Duration{Duration: -time.Second}},
+		},
+	}
+
+	for _, test := range tests {
+		peer := &BGPPeer{ObjectMeta: metav1.ObjectMeta{Name: "peer"}, Spec: test.spec}
+		err := peer.Validate()
+		if test.valid {
+			g.Expect(err).ToNot(HaveOccurred(), test.desc)
+			continue
+		}
+		g.Expect(apierrors.IsInvalid(err)).To(BeTrue(), "%s: %v", test.desc, err)
+	}
+}
+
+func TestValidateBGPPeerKeepaliveTimeMessage(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	peer := &BGPPeer{
+		ObjectMeta: metav1.ObjectMeta{Name: "peer"},
+		Spec: BGPPeerSpec{MyASN: 64512, PeerASN: 64513, PeerAddress: "10.0.0.1",
+			HoldTime: metav1.Duration{Duration: 9 * time.Second}, KeepaliveTime: metav1.Duration{Duration: 4 * time.Second}},
+	}
+	err := peer.ValidateCreate()
+	g.Expect(apierrors.IsInvalid(err)).To(BeTrue())
+	g.Expect(err.Error()).To(ContainSubstring("spec.keepaliveTime"))
+	g.Expect(err.Error()).To(ContainSubstring("must be at most one third of the hold time 9s, i.e. 3s"))
+}
+
+func TestValidateBGPPeerBackend(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	enabled, disabled := true, false
+	peer := &BGPPeer{
+		ObjectMeta: metav1.ObjectMeta{Name: "peer"},
+		Spec:       BGPPeerSpec{MyASN: 64512, PeerASN: 64513, PeerAddress: "10.0.0.1"},
+	}
+	for _, backend := range []string{"", v1beta1.BGPBackendNative, v1beta1.BGPBackendFRR} {
+		g.Expect(peer.ValidateBackend(backend)).To(Succeed(), backend)
+	}
+
+	peer.Spec.GracefulRestart = &disabled
+	g.Expect(peer.ValidateBackend("")).To(Succeed())
+
+	peer.Spec.GracefulRestart = &enabled
+	g.Expect(peer.ValidateBackend(v1beta1.BGPBackendFRR)).To(Succeed())
+	for _, backend := range []string{"", v1beta1.BGPBackendNative} {
+		err := peer.ValidateBackend(backend)
+		g.Expect(apierrors.IsInvalid(err)).To(BeTrue(), "%s: %v", backend, err)
+		g.Expect(err.Error()).To(ContainSubstring("spec.gracefulRestart"))
+		g.Expect(err.Error()).To(ContainSubstring("only supported with the frr BGP backend, the MetalLB resource uses the native one"))
+	}
+
+	peer.Spec.GracefulRestart = nil
+	peer.Spec.ConnectTime = &metav1.Duration{Duration: 30 * time.Second}
+	g.Expect(peer.ValidateBackend(v1beta1.BGPBackendFRR)).To(Succeed())
+	err := peer.ValidateBackend("")
+	g.Expect(apierrors.IsInvalid(err)).To(BeTrue(), "%v", err)
+	g.Expect(err.Error()).To(ContainSubstring("spec.connectTime"))
+}
+
+func TestValidateBGPPeerConnectTimeMessage(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	peer := &BGPPeer{
+		ObjectMeta: metav1.ObjectMeta{Name: "peer"},
+		Spec: BGPPeerSpec{MyASN: 64512, PeerASN: 64513, PeerAddress: "10.0.0.1",
+			ConnectTime: &metav1.Duration{Duration: 500 * time.Millisecond}},
+	}
+	err := peer.ValidateCreate()
+	g.Expect(apierrors.IsInvalid(err)).To(BeTrue())
+	g.Expect(err.Error()).To(ContainSubstring(`spec.connectTime: Invalid value: "500ms": must be at least 1s`))
+}
+
+func TestValidateBGPPeerWebhookBackend(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	s := runtime.NewScheme()
+	g.Expect(AddToScheme(s)).To(Succeed())
+	g.Expect(v1beta1.AddToScheme(s)).To(Succeed())
+	enabled := true
+	peer := &BGPPeer{
+		ObjectMeta: metav1.ObjectMeta{Name: "peer", Namespace: "metallb-system"},
+		Spec:       BGPPeerSpec{MyASN: 64512, PeerASN: 64513, PeerAddress: "10.0.0.1", GracefulRestart: &enabled},
+	}
+
+	// Without a MetalLB resource the backend is not known yet
+	bgpPeerClient = fake.NewClientBuilder().WithScheme(s).Build()
+	defer func() { bgpPeerClient = nil }()
+	g.Expect(peer.ValidateCreate()).To(Succeed())
+
+	metallb := &v1beta1.MetalLB{ObjectMeta: metav1.ObjectMeta{Name: "metallb", Namespace: "metallb-system"}}
+	bgpPeerClient = fake.NewClientBuilder().WithScheme(s).WithObjects(metallb).Build()
+	err := peer.ValidateCreate()
+	g.Expect(apierrors.IsInvalid(err)).To(BeTrue(), "%v", err)
+	g.Expect(err.Error()).To(ContainSubstring("spec.gracefulRestart"))
+
+	metallb.Spec.BGPBackend = v1beta1.BGPBackendFRR
+	bgpPeerClient = fake.NewClientBuilder().WithScheme(s).WithObjects(metallb).Build()
+	g.Expect(peer.ValidateUpdate(peer)).To(Succeed())
+}
+
+func TestValidateBGPPeerMyASN(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	s := runtime.NewScheme()
+	g.Expect(AddToScheme(s)).To(Succeed())
+	g.Expect(v1beta1.AddToScheme(s)).To(Succeed())
+	spine := &BGPPeer{
+		ObjectMeta: metav1.ObjectMeta{Name: "spine", Namespace: "metallb-system"},
+		Spec:       BGPPeerSpec{MyASN: 64512, PeerASN: 64513, PeerAddress: "10.0.0.1"},
+	}
+	metallb := &v1beta1.MetalLB{
+		ObjectMeta: metav1.ObjectMeta{Name: "metallb", Namespace: "metallb-system"},
+		Spec:       v1beta1.MetalLBSpec{BGPBackend: v1beta1.BGPBackendFRR},
+	}
+	bgpPeerClient = fake.NewClientBuilder().WithScheme(s).WithObjects(metallb, spine).Build()
+	defer func() { bgpPeerClient = nil }()
+
+	tor := &BGPPeer{
+		ObjectMeta: metav1.ObjectMeta{Name: "tor", Namespace: "metallb-system"},
+		Spec:       BGPPeerSpec{MyASN: 64512, PeerASN: 64514, PeerAddress: "10.0.0.2"},
+	}
+	g.Expect(tor.ValidateCreate()).To(Succeed())
+
+	tor.Spec.MyASN = 64600
+	err := tor.ValidateCreate()
+	g.Expect(apierrors.IsInvalid(err)).To(BeTrue(), "%v", err)
+	g.Expect(err.Error()).To(ContainSubstring("spec.myASN: Invalid value: 64600: must be the local AS number 64512 of BGPPeer metallb-system/spine, the frr BGP backend uses a single one for all the peers"))
+
+	// Changing the local AS number of the only peer is fine
+	spine.Spec.MyASN = 64600
+	g.Expect(spine.ValidateUpdate(spine)).To(Succeed())
+
+	// The native backend runs a session per peer
+	metallb.Spec.BGPBackend = ""
+	bgpPeerClient = fake.NewClientBuilder().WithScheme(s).WithObjects(metallb, spine).Build()
+	tor.Spec.MyASN = 64512
+	g.Expect(tor.ValidateCreate()).To(Succeed())
+}
@@ -0,0 +1,96 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"context"
+	"fmt"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+
+	"github.com/metallb/metallb-operator/api/v1beta1"
+)
+
+// bgpPeerClient lists the MetalLB resource of the namespace of the incoming
+// peer, to check it against its BGP backend, and the existing peers. The
+// checks are skipped when nil.
+var bgpPeerClient client.Reader
+
+func (peer *BGPPeer) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	bgpPeerClient = mgr.GetClient()
+	return ctrl.NewWebhookManagedBy(mgr).
+		For(peer).
+		Complete()
+}
+
+// +kubebuilder:webhook:verbs=create;update,path=/validate-metallb-io-v1alpha1-bgppeer,mutating=false,failurePolicy=fail,groups=metallb.io,resources=bgppeers,versions=v1alpha1,name=bgppeervalidationwebhook.metallb.io,sideEffects=None
+
+var _ webhook.Validator = &BGPPeer{}
+
+// ValidateCreate implements webhook.Validator so a webhook will be registered for the type
+func (peer *BGPPeer) ValidateCreate() error {
+	if err := peer.Validate(); err != nil {
+		return err
+	}
+	return peer.validateMetalLBBackend()
+}
+
+// ValidateUpdate implements webhook.Validator so a webhook will be registered for the type
+func (peer *BGPPeer) ValidateUpdate(old runtime.Object) error {
+	if err := peer.Validate(); err != nil {
+		return err
+	}
+	return peer.validateMetalLBBackend()
+}
+
+// validateMetalLBBackend checks the peer against the BGP backend of the
+// MetalLB resource of its namespace, and against the existing peers with the
+// frr one. Any peer is accepted while there is no MetalLB resource, the
+// BGPPeer reconciler reports it once one is created.
+func (peer *BGPPeer) validateMetalLBBackend() error {
+	if bgpPeerClient == nil {
+		return nil
+	}
+	metallbs := &v1beta1.MetalLBList{}
+	if err := bgpPeerClient.List(context.Background(), metallbs, client.InNamespace(peer.Namespace)); err != nil {
+		return apierrors.NewInternalError(fmt.Errorf("failed to list the MetalLBs: %w", err))
+	}
+	if len(metallbs.Items) == 0 {
+		return nil
+	}
+	bgpBackend := metallbs.Items[0].Spec.BGPBackend
+	if err := peer.ValidateBackend(bgpBackend); err != nil {
+		return err
+	}
+	if bgpBackend != v1beta1.BGPBackendFRR {
+		return nil
+	}
+	peers := &BGPPeerList{}
+	if err := bgpPeerClient.List(context.Background(), peers, client.InNamespace(peer.Namespace)); err != nil {
+		return apierrors.NewInternalError(fmt.Errorf("failed to list the existing BGPPeers: %w", err))
+	}
+	return peer.ValidateMyASN(peers.Items)
+}
+
+// ValidateDelete implements webhook.Validator so a webhook will be registered for the type
+func (peer *BGPPeer) ValidateDelete() error {
+	return nil
+}
@@ -0,0 +1,92 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// wellKnownCommunities are the values of the well-known communities, by name
+// (RFC 1997 and RFC 3765).
+var wellKnownCommunities = map[string]BGPCommunity{
+	"no-export":           {ASN: 65535, Value: 65281},
+	"no-advertise":        {ASN: 65535, Value: 65282},
+	"no-export-subconfed": {ASN: 65535, Value: 65283},
+	"no-peer":             {ASN: 65535, Value: 65284},
+}
+
+// String returns the community in the 16-bit:16-bit form read by MetalLB,
+// resolving the well-known names. A reference to a Community is returned as
+// is, MetalLB resolves it from the bgp-communities of its configuration.
+func (c BGPCommunity) String() string {
+	if c.Name != "" {
+		return c.Name
+	}
+	if wellKnown, ok := wellKnownCommunities[c.WellKnown]; ok {
+		c = wellKnown
+	}
+	return fmt.Sprintf("%d:%d", c.ASN, c.Value)
+}
+
+// MarshalYAML marshals the community as in the MetalLB configuration.
+func (c BGPCommunity) MarshalYAML() (interface{}, error) {
+	return c.String(), nil
+}
+
+// UnmarshalYAML unmarshals a community of the MetalLB configuration, either
+// a well-known name, in the 16-bit:16-bit form or the name of a community of
+// the bgp-communities.
+func (c *BGPCommunity) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var community string
+	if err := unmarshal(&community); err != nil {
+		return err
+	}
+	parsed, err := parseCommunity(community)
+	if err != nil {
+		return err
+	}
+	*c = parsed
+	return nil
+}
+
+// parseCommunity parses a well-known community name, a community in the
+// 16-bit:16-bit form or the name of a Community.
+func parseCommunity(community string) (BGPCommunity, error) {
+	if _, ok := wellKnownCommunities[community]; ok {
+		return BGPCommunity{WellKnown: community}, nil
+	}
+	if len(validation.IsDNS1035Label(community)) == 0 {
+		return BGPCommunity{Name: community}, nil
+	}
+	parts := strings.Split(community, ":")
+	if len(parts) != 2 {
+		return BGPCommunity{}, fmt.Errorf("invalid community %q, must be in the 16-bit:16-bit form", community)
+	}
+	values := [2]uint16{}
+	for i, part := range parts {
+		value, err := strconv.ParseUint(part, 10, 16)
+		if err != nil {
+			return BGPCommunity{}, fmt.Errorf("invalid community %q, must be in the 16-bit:16-bit form", community)
+		}
+		values[i] = uint16(value)
+	}
+	return BGPCommunity{ASN: values[0], Value: values[1]}, nil
+}
@@ -0,0 +1,61 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// CommunitySpec defines the desired state of Community, a named BGP community
+// the AddressPools reference by name in their bgpAdvertisements.
+type CommunitySpec struct {
+	// Name is the name the bgpAdvertisements reference the community with.
+	// It must start with a letter, and must not be a well-known community.
+	Name string `json:"name"`
+
+	// Value is the community in the 16-bit:16-bit form, e.g. 65535:65282.
+	// +kubebuilder:validation:Pattern=`^[0-9]+:[0-9]+$`
+	Value string `json:"value"`
+}
+
+// CommunityStatus defines the observed state of Community
+type CommunityStatus struct {
+	// Conditions show whether the Community was rendered into the MetalLB configuration
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+
+// Community is the Schema for the communities API
+type Community struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   CommunitySpec   `json:"spec"`
+	Status CommunityStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// CommunityList contains a list of Community
+type CommunityList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []Community `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&Community{}, &CommunityList{})
+}
@@ -0,0 +1,55 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package v1alpha1
+
+import (
+	"strings"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+// Validate checks the Community is accepted by MetalLB. The Community
+// reconciler runs it, and leaves the communities failing it out of the
+// MetalLB configuration, along with the AddressPools referencing them.
+func (community *Community) Validate() error {
+	var errs field.ErrorList
+	spec := field.NewPath("spec")
+	errs = append(errs, validateCommunityName(community.Spec.Name, spec.Child("name"))...)
+	if !strings.Contains(community.Spec.Value, ":") {
+		errs = append(errs, field.Invalid(spec.Child("value"), community.Spec.Value, "must be in the 16-bit:16-bit form"))
+	} else if _, err := parseCommunity(community.Spec.Value); err != nil {
+		errs = append(errs, field.Invalid(spec.Child("value"), community.Spec.Value, err.Error()))
+	}
+	if len(errs) == 0 {
+		return nil
+	}
+	return apierrors.NewInvalid(schema.GroupKind{Group: GroupVersion.Group, Kind: "Community"}, community.Name, errs)
+}
+
+// validateCommunityName checks the name can't be mistaken for a well-known
+// community nor a community in the 16-bit:16-bit form, in the MetalLB
+// configuration they are all written the same way.
+func validateCommunityName(name string, path *field.Path) field.ErrorList {
+	var errs field.ErrorList
+	for _, msg := range validation.IsDNS1035Label(name) {
+		errs = append(errs, field.Invalid(path, name, "invalid community name: "+msg))
+	}
+	if _, ok := wellKnownCommunities[name]; ok {
+		errs = append(errs, field.Invalid(path, name, "invalid community name: reserved for the well-known community"))
+	}
+	return errs
+}
@@ -21,7 +21,9 @@ limitations under the License.
 package v1alpha1
 
 import (
-	runtime "k8s.io/apimachinery/pkg/runtime"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
 )
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
@@ -30,7 +32,7 @@ func (in *AddressPool) DeepCopyInto(out *AddressPool) {
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
 	in.Spec.DeepCopyInto(&out.Spec)
-	out.Status = in.Status
+	in.Status.DeepCopyInto(&out.Status)
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AddressPool.
@@ -96,6 +98,23 @@ func (in *AddressPoolSpec) DeepCopyInto(out *AddressPoolSpec) {
 		*out = new(bool)
 		**out = **in
 	}
+	if in.AutoExpand != nil {
+		in, out := &in.AutoExpand, &out.AutoExpand
+		*out = new(AutoExpandSpec)
+		**out = **in
+	}
+	if in.AllowedNamespaces != nil {
+		in, out := &in.AllowedNamespaces, &out.AllowedNamespaces
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.BGPAdvertisements != nil {
+		in, out := &in.BGPAdvertisements, &out.BGPAdvertisements
+		*out = make([]BGPAdvertisement, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AddressPoolSpec.
@@ -111,6 +130,13 @@ func (in *AddressPoolSpec) DeepCopy() *AddressPoolSpec {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *AddressPoolStatus) DeepCopyInto(out *AddressPoolStatus) {
 	*out = *in
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]v1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AddressPoolStatus.
@@ -122,3 +148,400 @@ func (in *AddressPoolStatus) DeepCopy() *AddressPoolStatus {
 	in.DeepCopyInto(out)
 	return out
 }
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AutoExpandSpec) DeepCopyInto(out *AutoExpandSpec) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AutoExpandSpec.
+func (in *AutoExpandSpec) DeepCopy() *AutoExpandSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(AutoExpandSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *BFDProfile) DeepCopyInto(out *BFDProfile) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BFDProfile.
+func (in *BFDProfile) DeepCopy() *BFDProfile {
+	if in == nil {
+		return nil
+	}
+	out := new(BFDProfile)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *BFDProfile) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *BFDProfileList) DeepCopyInto(out *BFDProfileList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]BFDProfile, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BFDProfileList.
+func (in *BFDProfileList) DeepCopy() *BFDProfileList {
+	if in == nil {
+		return nil
+	}
+	out := new(BFDProfileList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *BFDProfileList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *BFDProfileSpec) DeepCopyInto(out *BFDProfileSpec) {
+	*out = *in
+	if in.ReceiveInterval != nil {
+		in, out := &in.ReceiveInterval, &out.ReceiveInterval
+		*out = new(uint32)
+		**out = **in
+	}
+	if in.TransmitInterval != nil {
+		in, out := &in.TransmitInterval, &out.TransmitInterval
+		*out = new(uint32)
+		**out = **in
+	}
+	if in.DetectMultiplier != nil {
+		in, out := &in.DetectMultiplier, &out.DetectMultiplier
+		*out = new(uint32)
+		**out = **in
+	}
+	if in.EchoInterval != nil {
+		in, out := &in.EchoInterval, &out.EchoInterval
+		*out = new(uint32)
+		**out = **in
+	}
+	if in.MinimumTTL != nil {
+		in, out := &in.MinimumTTL, &out.MinimumTTL
+		*out = new(uint32)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BFDProfileSpec.
+func (in *BFDProfileSpec) DeepCopy() *BFDProfileSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(BFDProfileSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *BFDProfileStatus) DeepCopyInto(out *BFDProfileStatus) {
+	*out = *in
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]v1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BFDProfileStatus.
+func (in *BFDProfileStatus) DeepCopy() *BFDProfileStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(BFDProfileStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *BGPAdvertisement) DeepCopyInto(out *BGPAdvertisement) {
+	*out = *in
+	if in.AggregationLength != nil {
+		in, out := &in.AggregationLength, &out.AggregationLength
+		*out = new(int32)
+		**out = **in
+	}
+	if in.LocalPref != nil {
+		in, out := &in.LocalPref, &out.LocalPref
+		*out = new(uint32)
+		**out = **in
+	}
+	if in.Communities != nil {
+		in, out := &in.Communities, &out.Communities
+		*out = make([]BGPCommunity, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BGPAdvertisement.
+func (in *BGPAdvertisement) DeepCopy() *BGPAdvertisement {
+	if in == nil {
+		return nil
+	}
+	out := new(BGPAdvertisement)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *BGPCommunity) DeepCopyInto(out *BGPCommunity) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BGPCommunity.
+func (in *BGPCommunity) DeepCopy() *BGPCommunity {
+	if in == nil {
+		return nil
+	}
+	out := new(BGPCommunity)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *BGPPeer) DeepCopyInto(out *BGPPeer) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BGPPeer.
+func (in *BGPPeer) DeepCopy() *BGPPeer {
+	if in == nil {
+		return nil
+	}
+	out := new(BGPPeer)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *BGPPeer) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *BGPPeerList) DeepCopyInto(out *BGPPeerList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]BGPPeer, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BGPPeerList.
+func (in *BGPPeerList) DeepCopy() *BGPPeerList {
+	if in == nil {
+		return nil
+	}
+	out := new(BGPPeerList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *BGPPeerList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *BGPPeerSpec) DeepCopyInto(out *BGPPeerSpec) {
+	*out = *in
+	out.HoldTime = in.HoldTime
+	out.KeepaliveTime = in.KeepaliveTime
+	if in.PasswordSecret != nil {
+		in, out := &in.PasswordSecret, &out.PasswordSecret
+		*out = new(corev1.LocalObjectReference)
+		**out = **in
+	}
+	if in.GracefulRestart != nil {
+		in, out := &in.GracefulRestart, &out.GracefulRestart
+		*out = new(bool)
+		**out = **in
+	}
+	if in.ConnectTime != nil {
+		in, out := &in.ConnectTime, &out.ConnectTime
+		*out = new(v1.Duration)
+		**out = **in
+	}
+	if in.NodeSelectors != nil {
+		in, out := &in.NodeSelectors, &out.NodeSelectors
+		*out = make([]v1.LabelSelector, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BGPPeerSpec.
+func (in *BGPPeerSpec) DeepCopy() *BGPPeerSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(BGPPeerSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *BGPPeerStatus) DeepCopyInto(out *BGPPeerStatus) {
+	*out = *in
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]v1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BGPPeerStatus.
+func (in *BGPPeerStatus) DeepCopy() *BGPPeerStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(BGPPeerStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *Community) DeepCopyInto(out *Community) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	out.Spec = in.Spec
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Community.
+func (in *Community) DeepCopy() *Community {
+	if in == nil {
+		return nil
+	}
+	out := new(Community)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *Community) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CommunityList) DeepCopyInto(out *CommunityList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]Community, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CommunityList.
+func (in *CommunityList) DeepCopy() *CommunityList {
+	if in == nil {
+		return nil
+	}
+	out := new(CommunityList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *CommunityList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CommunitySpec) DeepCopyInto(out *CommunitySpec) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CommunitySpec.
+func (in *CommunitySpec) DeepCopy() *CommunitySpec {
+	if in == nil {
+		return nil
+	}
+	out := new(CommunitySpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CommunityStatus) DeepCopyInto(out *CommunityStatus) {
+	*out = *in
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]v1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CommunityStatus.
+func (in *CommunityStatus) DeepCopy() *CommunityStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(CommunityStatus)
+	in.DeepCopyInto(out)
+	return out
+}
@@ -0,0 +1,186 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// AddressPoolSpec defines the desired state of AddressPool
+type AddressPoolSpec struct {
+	// Address Pool Name
+	Name string `json:"name,omitempty"`
+
+	// Protocol can be used to select how the announcement is done,
+	// +kubebuilder:validation:Enum:=layer2; bgp
+	Protocol string `json:"protocol"`
+
+	// A list of IP address ranges over which MetalLB has authority.
+	// You can list multiple ranges in a single pool, they will all share the
+	// same settings. Each range can be either a CIDR prefix, or an explicit
+	// start-end range of IPs.
+	Addresses []string `json:"addresses"`
+
+	// AutoAssign flag used to prevent MetallB from automatic allocation
+	// for a pool.
+	// +optional
+	// +kubebuilder:default:=true
+	AutoAssign *bool `json:"autoAssign,omitempty"`
+
+	// AvoidBuggyIPs prevents MetalLB from assigning the addresses ending in
+	// .0 and .255 of the pool, dropped by some network equipment.
+	// +optional
+	AvoidBuggyIPs bool `json:"avoidBuggyIPs,omitempty"`
+
+	// AllocationStrategy is how MetalLB picks the addresses it assigns from
+	// the pool. MetalLB assigns them sequentially, from the first free one:
+	// Random is accepted but not supported yet, and reported as such in the
+	// AllocationStrategySupported condition.
+	// +optional
+	// +kubebuilder:validation:Enum=Sequential;Random
+	AllocationStrategy string `json:"allocationStrategy,omitempty"`
+
+	// AutoExpand grows the pool from a supernet when all its addresses are
+	// assigned to services.
+	// +optional
+	AutoExpand *AutoExpandSpec `json:"autoExpand,omitempty"`
+
+	// AllowedNamespaces restricts the pool to the services of the given
+	// namespaces. As the MetalLB configuration can't restrict a pool to some
+	// namespaces, a restricted pool is never auto assigned: the services
+	// request it with the metallb.universe.tf/address-pool annotation, and
+	// the services of other namespaces holding one of its addresses are
+	// reported with a NamespaceNotAllowed event.
+	// +optional
+	AllowedNamespaces []string `json:"allowedNamespaces,omitempty"`
+
+	// BGPAdvertisements configures how the addresses of a bgp pool are
+	// advertised to the BGP peers. When empty, each address is advertised
+	// with the MetalLB defaults. Only allowed with the bgp protocol.
+	// +optional
+	BGPAdvertisements []BGPAdvertisement `json:"bgpAdvertisements,omitempty"`
+}
+
+// BGPAdvertisement is an advertisement of the addresses of a bgp pool.
+type BGPAdvertisement struct {
+	// AggregationLength is the prefix length of the routes advertised for the
+	// assigned addresses, to aggregate them. When unset, each address is
+	// advertised on its own, as a /32.
+	// +optional
+	// +kubebuilder:validation:Minimum=0
+	// +kubebuilder:validation:Maximum=32
+	AggregationLength *int32 `json:"aggregationLength,omitempty"`
+
+	// LocalPref is the BGP LOCAL_PREF attribute of the advertised routes,
+	// only used with iBGP peers.
+	// +optional
+	LocalPref *uint32 `json:"localPref,omitempty"`
+
+	// Communities are the BGP communities attached to the advertised routes.
+	// +optional
+	Communities []BGPCommunity `json:"communities,omitempty"`
+}
+
+// BGPCommunity is a BGP community, either well-known, made of an AS number and
+// a value, e.g. 64512:100, or the name of a Community of the operator namespace.
+type BGPCommunity struct {
+	// Name is the name of a Community of the operator namespace. WellKnown,
+	// ASN and Value must not be set along with it.
+	// +optional
+	Name string `json:"name,omitempty"`
+
+	// WellKnown is the name of a well-known community: no-export,
+	// no-advertise, no-export-subconfed or no-peer. ASN and Value must not
+	// be set along with it.
+	// +optional
+	// +kubebuilder:validation:Enum=no-export;no-advertise;no-export-subconfed;no-peer
+	WellKnown string `json:"wellKnown,omitempty"`
+
+	// ASN is the first 16 bits of the community, usually the AS number of
+	// the network defining it.
+	// +optional
+	// +kubebuilder:validation:Minimum=0
+	// +kubebuilder:validation:Maximum=65535
+	ASN uint16 `json:"asn,omitempty"`
+
+	// Value is the last 16 bits of the community.
+	// +optional
+	// +kubebuilder:validation:Minimum=0
+	// +kubebuilder:validation:Maximum=65535
+	Value uint16 `json:"value,omitempty"`
+}
+
+// AutoExpandSpec defines how an exhausted AddressPool grows.
+type AutoExpandSpec struct {
+	// Supernet is the CIDR the ranges added to the pool are taken from.
+	Supernet string `json:"supernet"`
+
+	// Increment is the prefix length of the CIDR added to the pool each time
+	// it is exhausted, e.g. 28 to add 16 IPv4 addresses. It must be at least
+	// the prefix length of the supernet. The first CIDR of the supernet not
+	// overlapping with any pool is added.
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=128
+	Increment int `json:"increment"`
+}
+
+// AddressPoolStatus defines the observed state of AddressPool
+type AddressPoolStatus struct {
+	// Conditions show whether the AddressPool was rendered into the MetalLB configuration
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+
+	// AllocatedAddresses is the number of addresses of the pool assigned to
+	// a LoadBalancer service.
+	// +optional
+	AllocatedAddresses int `json:"allocatedAddresses,omitempty"`
+
+	// TotalAddresses is the number of addresses of the pool, capped to the
+	// largest int for the IPv6 pools larger than that.
+	// +optional
+	TotalAddresses int `json:"totalAddresses,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="Protocol",type=string,JSONPath=`.spec.protocol`
+// +kubebuilder:printcolumn:name="Allocated",type=integer,JSONPath=`.status.allocatedAddresses`
+// +kubebuilder:printcolumn:name="Total",type=integer,JSONPath=`.status.totalAddresses`
+// +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+// +kubebuilder:storageversion
+
+// AddressPool is the Schema for the addresspools API. It is the hub of the
+// AddressPool conversions, the v1alpha1 AddressPools are converted to and from it.
+type AddressPool struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   AddressPoolSpec   `json:"spec"`
+	Status AddressPoolStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// AddressPoolList contains a list of AddressPool
+type AddressPoolList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []AddressPool `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&AddressPool{}, &AddressPoolList{})
+}
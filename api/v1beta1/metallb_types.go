@@ -17,6 +17,7 @@ limitations under the License.
 package v1beta1
 
 import (
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
@@ -30,8 +31,262 @@ type MetalLBSpec struct {
 
 	// Foo is an example field of MetalLB. Edit MetalLB_types.go to remove/update
 	MetalLBImage string `json:"image,omitempty"`
+
+	// ReadOnlyRootFilesystem controls whether the speaker and controller
+	// containers run with a read-only root filesystem. When true, writable
+	// emptyDir volumes are mounted where the containers need them.
+	// When unset, the security context shipped with the MetalLB manifests is kept.
+	// +optional
+	ReadOnlyRootFilesystem *bool `json:"readOnlyRootFilesystem,omitempty"`
+
+	// PoolSortOrder controls the order of the address pools in the MetalLB
+	// ConfigMap. Pools are sorted by name, or by their first address.
+	// +optional
+	// +kubebuilder:validation:Enum=name;address
+	// +kubebuilder:default:=name
+	PoolSortOrder string `json:"poolSortOrder,omitempty"`
+
+	// SpeakerServiceAccountName is the name of an existing ServiceAccount
+	// the speaker pods run with, instead of the one shipped with the operator.
+	// +optional
+	SpeakerServiceAccountName string `json:"speakerServiceAccountName,omitempty"`
+
+	// ControllerServiceAccountName is the name of an existing ServiceAccount
+	// the controller pod runs with, instead of the one shipped with the operator.
+	// +optional
+	ControllerServiceAccountName string `json:"controllerServiceAccountName,omitempty"`
+
+	// SpeakerPriorityClassName is the PriorityClass of the speaker pods, e.g.
+	// system-node-critical so that they are not evicted before the workloads
+	// on node pressure. When unset, the one of the manifests is kept. The
+	// PriorityClassesFound condition reports whether it exists.
+	// +optional
+	SpeakerPriorityClassName string `json:"speakerPriorityClassName,omitempty"`
+
+	// ControllerPriorityClassName is the PriorityClass of the controller pod.
+	// When unset, the one of the manifests is kept.
+	// +optional
+	ControllerPriorityClassName string `json:"controllerPriorityClassName,omitempty"`
+
+	// ExportStats enables writing the total, used and available address
+	// counts of each pool to the metallb-stats ConfigMap, refreshed whenever
+	// the address pools are reconciled.
+	// +optional
+	ExportStats *bool `json:"exportStats,omitempty"`
+
+	// SpeakerHostAliases are added to the hosts file of the speaker pods,
+	// to resolve names where no DNS is reachable.
+	// +optional
+	SpeakerHostAliases []corev1.HostAlias `json:"speakerHostAliases,omitempty"`
+
+	// SpeakerNodeSelector restricts the speaker pods to the nodes with these
+	// labels, on top of the kubernetes.io/os selector of the manifests. When
+	// empty, the speakers run on all the Linux nodes.
+	// +optional
+	SpeakerNodeSelector map[string]string `json:"speakerNodeSelector,omitempty"`
+
+	// SpeakerTolerations are added to the tolerations of the speaker pods,
+	// e.g. to run them on tainted nodes. The tolerations of the manifests are
+	// kept.
+	// +optional
+	SpeakerTolerations []corev1.Toleration `json:"speakerTolerations,omitempty"`
+
+	// ControllerNodeSelector restricts the controller pod to the nodes with
+	// these labels, on top of the kubernetes.io/os selector of the manifests.
+	// The ControllerSchedulable condition reports whether a schedulable node
+	// matches it.
+	// +optional
+	ControllerNodeSelector map[string]string `json:"controllerNodeSelector,omitempty"`
+
+	// ControllerTolerations are added to the tolerations of the controller pod.
+	// +optional
+	ControllerTolerations []corev1.Toleration `json:"controllerTolerations,omitempty"`
+
+	// ControllerHostAliases are added to the hosts file of the controller pod.
+	// +optional
+	ControllerHostAliases []corev1.HostAlias `json:"controllerHostAliases,omitempty"`
+
+	// SpeakerResources are the compute resources of the speaker container.
+	// When unset, the resources of the manifests are kept.
+	// +optional
+	SpeakerResources *corev1.ResourceRequirements `json:"speakerResources,omitempty"`
+
+	// ControllerResources are the compute resources of the controller container.
+	// When unset, the resources of the manifests are kept.
+	// +optional
+	ControllerResources *corev1.ResourceRequirements `json:"controllerResources,omitempty"`
+
+	// SpeakerDNSPolicy is the DNS policy of the speaker pods. The speakers run
+	// with host networking, use ClusterFirstWithHostNet to resolve names with
+	// the cluster DNS. When unset, the policy of the MetalLB manifests is kept.
+	// +optional
+	// +kubebuilder:validation:Enum=ClusterFirstWithHostNet;ClusterFirst;Default
+	SpeakerDNSPolicy corev1.DNSPolicy `json:"speakerDNSPolicy,omitempty"`
+
+	// ControllerDNSPolicy is the DNS policy of the controller pod.
+	// When unset, the policy of the MetalLB manifests is kept.
+	// +optional
+	// +kubebuilder:validation:Enum=ClusterFirstWithHostNet;ClusterFirst;Default
+	ControllerDNSPolicy corev1.DNSPolicy `json:"controllerDNSPolicy,omitempty"`
+
+	// SpeakerImage replaces the speaker image the operator deploys, e.g. to pull
+	// it from a registry mirror. When empty, the operator's image is used.
+	// +optional
+	SpeakerImage string `json:"speakerImage,omitempty"`
+
+	// ControllerImage replaces the controller image the operator deploys.
+	// When empty, the operator's image is used.
+	// +optional
+	ControllerImage string `json:"controllerImage,omitempty"`
+
+	// BGPBackend is the BGP implementation of the speaker. The frr backend
+	// runs FRR in sidecar containers of the speaker pods.
+	// +optional
+	// +kubebuilder:validation:Enum=native;frr
+	// +kubebuilder:default:=native
+	BGPBackend string `json:"bgpBackend,omitempty"`
+
+	// FRRReadinessProbe tunes the readiness probe of the frr container, which
+	// checks the control sockets of the FRR daemons. It is only rendered with
+	// the frr BGP backend.
+	// +optional
+	FRRReadinessProbe *ProbeConfig `json:"frrReadinessProbe,omitempty"`
+
+	// LogLevel is the --log-level of the speaker and the controller, the
+	// most verbose being all.
+	// +optional
+	// +kubebuilder:validation:Enum=all;debug;info;warn;error;none
+	// +kubebuilder:default:=info
+	LogLevel string `json:"logLevel,omitempty"`
+
+	// MinMetalLBVersion is the minimum version of the MetalLB images, e.g. v0.9.6.
+	// When the tag of the speaker or controller image is an older version, the
+	// MetalLB resources are not deployed. Tags that are not a version are accepted.
+	// +optional
+	MinMetalLBVersion string `json:"minMetalLBVersion,omitempty"`
+
+	// ManageWorkloads controls whether the operator deploys the MetalLB speaker
+	// and controller. When false, as they are deployed by other means, the
+	// operator only manages the MetalLB configuration.
+	// +optional
+	// +kubebuilder:default:=true
+	ManageWorkloads *bool `json:"manageWorkloads,omitempty"`
+
+	// EnableRBACProxy fronts the metrics of the speaker and the controller with
+	// a kube-rbac-proxy sidecar, serving them over HTTPS to authorized clients
+	// only. When unset, the proxy is enabled on OpenShift only.
+	// +optional
+	EnableRBACProxy *bool `json:"enableRBACProxy,omitempty"`
+
+	// DegradedThreshold is how long the MetalLB workloads must stay unhealthy
+	// before the Degraded condition is set, they are reported as Progressing
+	// until then. When unset, Degraded is set as soon as they are unhealthy.
+	// +optional
+	DegradedThreshold metav1.Duration `json:"degradedThreshold,omitempty"`
+
+	// SpeakerSysctls are set in the security context of the speaker pods. As
+	// the speakers run with host networking, only the IPC namespaced sysctls
+	// can be set. Unsafe sysctls must be listed in the UnsafeSysctlsAnnotation.
+	// +optional
+	SpeakerSysctls []corev1.Sysctl `json:"speakerSysctls,omitempty"`
+
+	// EnablePrometheusRules deploys a PrometheusRule alerting on the speakers
+	// being down, a stale MetalLB configuration and the address pools running
+	// out of addresses. It requires the Prometheus Operator CRDs, the pool
+	// alert the operator to run with --pool-metrics.
+	// +optional
+	EnablePrometheusRules *bool `json:"enablePrometheusRules,omitempty"`
+
+	// EnableMetrics deploys ServiceMonitors scraping the metrics of the speaker
+	// and the controller. They are skipped when the Prometheus Operator API is
+	// not served. When unset, they are deployed on OpenShift only.
+	// +optional
+	EnableMetrics *bool `json:"enableMetrics,omitempty"`
+
+	// MetricsTLSSecret is a kubernetes.io/tls Secret of the MetalLB namespace
+	// the metrics of the speaker and the controller are served with. It is
+	// mounted into both workloads, whose metrics are then served over HTTPS by
+	// the kube-rbac-proxy, enabled unless EnableRBACProxy is false, which is
+	// invalid. It replaces the serving certificates of the service CA on OpenShift.
+	// +optional
+	MetricsTLSSecret *corev1.LocalObjectReference `json:"metricsTLSSecret,omitempty"`
+
+	// EnableCanary makes the operator continuously check MetalLB assigns
+	// addresses. It maintains the metallb-canary AddressPool of the
+	// CanaryAddresses and a LoadBalancer Service requesting an address from
+	// it, and reports the assignment in the CanaryHealthy condition. Both are
+	// deleted once the canary is disabled.
+	// +optional
+	EnableCanary *bool `json:"enableCanary,omitempty"`
+
+	// CanaryAddresses is the CIDR or start-end range of the canary AddressPool,
+	// reserved to the canary. A single address is enough.
+	// +optional
+	CanaryAddresses string `json:"canaryAddresses,omitempty"`
+
+	// TargetNamespace is the namespace the speaker, the controller and the
+	// MetalLB ConfigMaps are deployed to, defaulting to the namespace of the
+	// MetalLB resource. The operator must watch it, see --target-namespaces,
+	// and the MetalLB service accounts must exist there.
+	// +optional
+	TargetNamespace string `json:"targetNamespace,omitempty"`
+}
+
+// ProbeConfig tunes a probe of the MetalLB workloads, the unset fields keep
+// the defaults of the probe.
+type ProbeConfig struct {
+	// PeriodSeconds is how often the probe runs.
+	// +optional
+	// +kubebuilder:validation:Minimum=1
+	PeriodSeconds int32 `json:"periodSeconds,omitempty"`
+
+	// TimeoutSeconds is how long the probe may take.
+	// +optional
+	// +kubebuilder:validation:Minimum=1
+	TimeoutSeconds int32 `json:"timeoutSeconds,omitempty"`
+
+	// FailureThreshold is the number of consecutive failures after which the
+	// container is not ready anymore.
+	// +optional
+	// +kubebuilder:validation:Minimum=1
+	FailureThreshold int32 `json:"failureThreshold,omitempty"`
 }
 
+// WorkloadsNamespace returns the namespace the MetalLB workloads and their
+// configuration are deployed to.
+func (metallb *MetalLB) WorkloadsNamespace() string {
+	if metallb.Spec.TargetNamespace != "" {
+		return metallb.Spec.TargetNamespace
+	}
+	return metallb.Namespace
+}
+
+// UnsafeSysctlsAnnotation lists, comma separated, the unsafe sysctls the
+// SpeakerSysctls may set. It acknowledges they are allowed by the kubelets of
+// the speaker nodes, with --allowed-unsafe-sysctls.
+const UnsafeSysctlsAnnotation = "metallb.io/allowed-unsafe-sysctls"
+
+const (
+	// BGPBackendNative is the BGP implementation built in the speaker.
+	BGPBackendNative = "native"
+	// BGPBackendFRR is the BGP implementation of FRR.
+	BGPBackendFRR = "frr"
+)
+
+// LogLevelInfo is the log level of the speaker and the controller when the
+// MetalLB has none.
+const LogLevelInfo = "info"
+
+// LogLevels are the log levels of the speaker and the controller.
+var LogLevels = []string{"all", "debug", LogLevelInfo, "warn", "error", "none"}
+
+const (
+	// PoolSortByName sorts the address pools by name.
+	PoolSortByName = "name"
+	// PoolSortByAddress sorts the address pools by their first address.
+	PoolSortByAddress = "address"
+)
+
 // MetalLBStatus defines the observed state of MetalLB
 type MetalLBStatus struct {
 	// INSERT ADDITIONAL STATUS FIELD - define observed state of cluster
@@ -39,6 +294,21 @@ type MetalLBStatus struct {
 
 	// Conditions show the current state of the MetalLB Operator
 	Conditions []metav1.Condition `json:"conditions,omitempty"`
+
+	// LastError is the error returned by the last failed reconcile, cleared
+	// once a reconcile succeeds.
+	// +optional
+	LastError string `json:"lastError,omitempty"`
+
+	// LastErrorTime is when LastError was first returned, the reconciles
+	// failing again with the same error keep it.
+	// +optional
+	LastErrorTime metav1.Time `json:"lastErrorTime,omitempty"`
+
+	// UnhealthySince is when the MetalLB workloads were first seen unhealthy,
+	// cleared once they are healthy again.
+	// +optional
+	UnhealthySince metav1.Time `json:"unhealthySince,omitempty"`
 }
 
 // +kubebuilder:object:root=true
@@ -0,0 +1,138 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	"fmt"
+	"regexp"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+// maxImageNameLength is the longest repository name, without the tag and the
+// digest, accepted by the registries.
+const maxImageNameLength = 255
+
+// imageReferenceRegexp matches the image references, as per the grammar of
+// github.com/docker/distribution/reference: an optional registry host and
+// port, the path of the repository, an optional tag and an optional digest.
+var imageReferenceRegexp = regexp.MustCompile(`^` +
+	`((?:(?:[a-zA-Z0-9]|[a-zA-Z0-9][a-zA-Z0-9-]*[a-zA-Z0-9])(?:\.(?:[a-zA-Z0-9]|[a-zA-Z0-9][a-zA-Z0-9-]*[a-zA-Z0-9]))*(?::[0-9]+)?/)?` +
+	`[a-z0-9]+(?:(?:[._]|__|[-]*)[a-z0-9]+)*(?:/[a-z0-9]+(?:(?:[._]|__|[-]*)[a-z0-9]+)*)*)` +
+	`(?::[\w][\w.-]{0,127})?` +
+	`(?:@[A-Za-z][A-Za-z0-9]*(?:[-_+.][A-Za-z][A-Za-z0-9]*)*:[0-9A-Fa-f]{32,})?$`)
+
+// ValidateImage checks the image is a valid image reference, e.g.
+// quay.io/metallb/speaker:v0.9.6.
+func ValidateImage(image string) error {
+	match := imageReferenceRegexp.FindStringSubmatch(image)
+	if match == nil {
+		return fmt.Errorf("not a valid image reference, e.g. registry.example.com/metallb/speaker:v0.9.6")
+	}
+	if len(match[1]) > maxImageNameLength {
+		return fmt.Errorf("the repository name is longer than %d characters", maxImageNameLength)
+	}
+	return nil
+}
+
+// Validate checks the fields of the MetalLB the operator can't deploy: the
+// images that are not valid image references, the unknown log level, the
+// invalid target namespace and priority class names.
+func (metallb *MetalLB) Validate() error {
+	errs := metallb.validateImages()
+	errs = append(errs, metallb.validateLogLevel()...)
+	errs = append(errs, metallb.validateTargetNamespace()...)
+	errs = append(errs, metallb.validatePriorityClassNames()...)
+	if len(errs) == 0 {
+		return nil
+	}
+	return apierrors.NewInvalid(schema.GroupKind{Group: GroupVersion.Group, Kind: "MetalLB"}, metallb.Name, errs)
+}
+
+func (metallb *MetalLB) validateImages() field.ErrorList {
+	var errs field.ErrorList
+	images := []struct {
+		field string
+		image string
+	}{
+		{"speakerImage", metallb.Spec.SpeakerImage},
+		{"controllerImage", metallb.Spec.ControllerImage},
+	}
+	for _, i := range images {
+		if i.image == "" {
+			continue
+		}
+		if err := ValidateImage(i.image); err != nil {
+			errs = append(errs, field.Invalid(field.NewPath("spec", i.field), i.image, err.Error()))
+		}
+	}
+	return errs
+}
+
+func (metallb *MetalLB) validateLogLevel() field.ErrorList {
+	if metallb.Spec.LogLevel == "" {
+		return nil
+	}
+	for _, level := range LogLevels {
+		if metallb.Spec.LogLevel == level {
+			return nil
+		}
+	}
+	return field.ErrorList{field.NotSupported(field.NewPath("spec", "logLevel"), metallb.Spec.LogLevel, LogLevels)}
+}
+
+func (metallb *MetalLB) validateTargetNamespace() field.ErrorList {
+	if metallb.Spec.TargetNamespace == "" {
+		return nil
+	}
+	var errs field.ErrorList
+	for _, msg := range validation.IsDNS1123Label(metallb.Spec.TargetNamespace) {
+		errs = append(errs, field.Invalid(field.NewPath("spec", "targetNamespace"), metallb.Spec.TargetNamespace, msg))
+	}
+	return errs
+}
+
+func (metallb *MetalLB) validatePriorityClassNames() field.ErrorList {
+	var errs field.ErrorList
+	for _, p := range metallb.priorityClassNames() {
+		for _, msg := range validation.IsDNS1123Subdomain(p.name) {
+			errs = append(errs, field.Invalid(p.path, p.name, msg))
+		}
+	}
+	return errs
+}
+
+// priorityClassName is a PriorityClass set in the spec, along with its field.
+type priorityClassName struct {
+	path *field.Path
+	name string
+}
+
+// priorityClassNames returns the PriorityClasses set in the spec.
+func (metallb *MetalLB) priorityClassNames() []priorityClassName {
+	var names []priorityClassName
+	if metallb.Spec.SpeakerPriorityClassName != "" {
+		names = append(names, priorityClassName{field.NewPath("spec", "speakerPriorityClassName"), metallb.Spec.SpeakerPriorityClassName})
+	}
+	if metallb.Spec.ControllerPriorityClassName != "" {
+		names = append(names, priorityClassName{field.NewPath("spec", "controllerPriorityClassName"), metallb.Spec.ControllerPriorityClassName})
+	}
+	return names
+}
@@ -0,0 +1,116 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	"context"
+	"fmt"
+
+	schedulingv1 "k8s.io/api/scheduling/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+)
+
+// metalLBClient lists the existing MetalLBs the webhook checks the incoming
+// one against, and gets the PriorityClasses it references. The checks are
+// skipped when nil.
+var metalLBClient client.Reader
+
+func (metallb *MetalLB) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	metalLBClient = mgr.GetClient()
+	return ctrl.NewWebhookManagedBy(mgr).
+		For(metallb).
+		Complete()
+}
+
+// +kubebuilder:webhook:verbs=create;update,path=/validate-metallb-io-v1beta1-metallb,mutating=false,failurePolicy=fail,groups=metallb.io,resources=metallbs,versions=v1beta1,name=metallbvalidationwebhook.metallb.io,sideEffects=None
+
+var _ webhook.Validator = &MetalLB{}
+
+// ValidateCreate implements webhook.Validator so a webhook will be registered for the type
+func (metallb *MetalLB) ValidateCreate() error {
+	if err := metallb.Validate(); err != nil {
+		return err
+	}
+	if metalLBClient == nil {
+		return nil
+	}
+	existing := &MetalLBList{}
+	if err := metalLBClient.List(context.Background(), existing, client.InNamespace(metallb.Namespace)); err != nil {
+		return apierrors.NewInternalError(fmt.Errorf("failed to list the existing MetalLBs: %w", err))
+	}
+	if err := metallb.validateSingleton(existing.Items); err != nil {
+		return err
+	}
+	return metallb.validatePriorityClassesExist(metalLBClient)
+}
+
+// ValidateUpdate implements webhook.Validator so a webhook will be registered for the type
+func (metallb *MetalLB) ValidateUpdate(old runtime.Object) error {
+	if err := metallb.Validate(); err != nil {
+		return err
+	}
+	if metalLBClient == nil {
+		return nil
+	}
+	return metallb.validatePriorityClassesExist(metalLBClient)
+}
+
+// ValidateDelete implements webhook.Validator so a webhook will be registered for the type
+func (metallb *MetalLB) ValidateDelete() error {
+	return nil
+}
+
+// validateSingleton denies a MetalLB when another one exists in its
+// namespace, as the operator only deploys MetalLB once.
+func (metallb *MetalLB) validateSingleton(others []MetalLB) error {
+	for _, other := range others {
+		if other.Name == metallb.Name {
+			continue
+		}
+		return apierrors.NewForbidden(schema.GroupResource{Group: GroupVersion.Group, Resource: "metallbs"}, metallb.Name,
+			fmt.Errorf("only one MetalLB is allowed in namespace %s, %q already exists", metallb.Namespace, other.Name))
+	}
+	return nil
+}
+
+// validatePriorityClassesExist denies a MetalLB referencing a PriorityClass
+// that does not exist, as the pods referencing it are rejected. A PriorityClass
+// deleted afterwards is reported by the PriorityClassesFound condition.
+func (metallb *MetalLB) validatePriorityClassesExist(c client.Reader) error {
+	var errs field.ErrorList
+	for _, p := range metallb.priorityClassNames() {
+		err := c.Get(context.Background(), types.NamespacedName{Name: p.name}, &schedulingv1.PriorityClass{})
+		if apierrors.IsNotFound(err) {
+			errs = append(errs, field.NotFound(p.path, p.name))
+			continue
+		}
+		if err != nil {
+			return apierrors.NewInternalError(fmt.Errorf("failed to get PriorityClass %s: %w", p.name, err))
+		}
+	}
+	if len(errs) == 0 {
+		return nil
+	}
+	return apierrors.NewInvalid(schema.GroupKind{Group: GroupVersion.Group, Kind: "MetalLB"}, metallb.Name, errs)
+}
@@ -0,0 +1,143 @@
+package v1beta1
+
+import (
+	"strings"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	schedulingv1 "k8s.io/api/scheduling/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestValidateSingleton(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	s := runtime.NewScheme()
+	g.Expect(AddToScheme(s)).To(Succeed())
+	existing := &MetalLB{ObjectMeta: metav1.ObjectMeta{Name: "metallb", Namespace: "metallb-system"}}
+	metalLBClient = fake.NewClientBuilder().WithScheme(s).WithObjects(existing).Build()
+	defer func() { metalLBClient = nil }()
+
+	second := &MetalLB{ObjectMeta: metav1.ObjectMeta{Name: "metallb-2", Namespace: "metallb-system"}}
+	err := second.ValidateCreate()
+	g.Expect(apierrors.IsForbidden(err)).To(BeTrue(), "%v", err)
+	g.Expect(err.Error()).To(ContainSubstring(`only one MetalLB is allowed in namespace metallb-system, "metallb" already exists`))
+
+	// The existing instance can still be updated
+	g.Expect(existing.ValidateUpdate(existing.DeepCopy())).To(Succeed())
+
+	// A MetalLB in another namespace is not deployed by the operator
+	other := &MetalLB{ObjectMeta: metav1.ObjectMeta{Name: "metallb-2", Namespace: "other"}}
+	g.Expect(other.ValidateCreate()).To(Succeed())
+
+	// The first instance is allowed
+	metalLBClient = fake.NewClientBuilder().WithScheme(s).Build()
+	g.Expect(existing.ValidateCreate()).To(Succeed())
+}
+
+func TestValidateImage(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	for _, image := range []string{
+		"speaker",
+		"metallb/speaker",
+		"quay.io/metallb/speaker:v0.9.6",
+		"registry.example.com:5000/mirror/metallb/controller:main",
+		"localhost:5000/metallb/speaker@sha256:" + strings.Repeat("a", 64),
+		"quay.io/metallb/speaker:v0.9.6@sha256:" + strings.Repeat("0", 64),
+	} {
+		g.Expect(ValidateImage(image)).To(Succeed(), image)
+	}
+	for _, image := range []string{
+		"",
+		"quay.io/MetalLB/speaker",
+		"quay.io/metallb/speaker:",
+		"quay.io/metallb/speaker:v1:v2",
+		"quay.io/metallb/speaker v0.9.6",
+		"quay.io/metallb/speaker@sha256:abc",
+		"https://quay.io/metallb/speaker",
+		"quay.io/" + strings.Repeat("a", 256),
+	} {
+		g.Expect(ValidateImage(image)).ToNot(Succeed(), image)
+	}
+}
+
+func TestValidateImages(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	metallb := &MetalLB{ObjectMeta: metav1.ObjectMeta{Name: "metallb", Namespace: "metallb-system"}}
+	g.Expect(metallb.ValidateCreate()).To(Succeed())
+
+	metallb.Spec.SpeakerImage = "mirror.example.com/metallb/speaker:v0.9.6"
+	g.Expect(metallb.ValidateCreate()).To(Succeed())
+
+	metallb.Spec.ControllerImage = "mirror.example.com/metallb/controller:v0.9.6:latest"
+	for _, err := range []error{metallb.ValidateCreate(), metallb.ValidateUpdate(metallb.DeepCopy())} {
+		g.Expect(apierrors.IsInvalid(err)).To(BeTrue(), "%v", err)
+		g.Expect(err.Error()).To(ContainSubstring("spec.controllerImage"))
+	}
+}
+
+func TestValidateLogLevel(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	metallb := &MetalLB{ObjectMeta: metav1.ObjectMeta{Name: "metallb", Namespace: "metallb-system"}}
+	for _, level := range LogLevels {
+		metallb.Spec.LogLevel = level
+		g.Expect(metallb.ValidateCreate()).To(Succeed(), level)
+	}
+
+	metallb.Spec.LogLevel = "verbose"
+	for _, err := range []error{metallb.ValidateCreate(), metallb.ValidateUpdate(metallb.DeepCopy())} {
+		g.Expect(apierrors.IsInvalid(err)).To(BeTrue(), "%v", err)
+		g.Expect(err.Error()).To(ContainSubstring("spec.logLevel"))
+	}
+}
+
+func TestValidateTargetNamespace(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	metallb := &MetalLB{ObjectMeta: metav1.ObjectMeta{Name: "metallb", Namespace: "metallb-system"}}
+	metallb.Spec.TargetNamespace = "metallb-data"
+	g.Expect(metallb.ValidateCreate()).To(Succeed())
+	g.Expect(metallb.WorkloadsNamespace()).To(Equal("metallb-data"))
+
+	metallb.Spec.TargetNamespace = "MetalLB_Data"
+	err := metallb.ValidateCreate()
+	g.Expect(apierrors.IsInvalid(err)).To(BeTrue(), "%v", err)
+	g.Expect(err.Error()).To(ContainSubstring("spec.targetNamespace"))
+
+	metallb.Spec.TargetNamespace = ""
+	g.Expect(metallb.WorkloadsNamespace()).To(Equal("metallb-system"))
+}
+
+func TestValidatePriorityClasses(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	s := runtime.NewScheme()
+	g.Expect(AddToScheme(s)).To(Succeed())
+	g.Expect(schedulingv1.AddToScheme(s)).To(Succeed())
+	critical := &schedulingv1.PriorityClass{ObjectMeta: metav1.ObjectMeta{Name: "system-node-critical"}, Value: 2000001000}
+	metalLBClient = fake.NewClientBuilder().WithScheme(s).WithObjects(critical).Build()
+	defer func() { metalLBClient = nil }()
+
+	metallb := &MetalLB{ObjectMeta: metav1.ObjectMeta{Name: "metallb", Namespace: "metallb-system"}}
+	metallb.Spec.SpeakerPriorityClassName = "system-node-critical"
+	g.Expect(metallb.ValidateCreate()).To(Succeed())
+	g.Expect(metallb.ValidateUpdate(metallb.DeepCopy())).To(Succeed())
+
+	metallb.Spec.ControllerPriorityClassName = "metallb-controller"
+	err := metallb.ValidateCreate()
+	g.Expect(apierrors.IsInvalid(err)).To(BeTrue(), "%v", err)
+	g.Expect(err.Error()).To(ContainSubstring(`spec.controllerPriorityClassName: Not found: "metallb-controller"`))
+	err = metallb.ValidateUpdate(metallb.DeepCopy())
+	g.Expect(apierrors.IsInvalid(err)).To(BeTrue(), "%v", err)
+
+	metallb.Spec.ControllerPriorityClassName = "MetalLB_Controller"
+	err = metallb.Validate()
+	g.Expect(apierrors.IsInvalid(err)).To(BeTrue(), "%v", err)
+	g.Expect(err.Error()).To(ContainSubstring("spec.controllerPriorityClassName"))
+}
@@ -21,16 +21,200 @@ limitations under the License.
 package v1beta1
 
 import (
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1"
-	runtime "k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime"
 )
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AddressPool) DeepCopyInto(out *AddressPool) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AddressPool.
+func (in *AddressPool) DeepCopy() *AddressPool {
+	if in == nil {
+		return nil
+	}
+	out := new(AddressPool)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *AddressPool) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AddressPoolList) DeepCopyInto(out *AddressPoolList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]AddressPool, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AddressPoolList.
+func (in *AddressPoolList) DeepCopy() *AddressPoolList {
+	if in == nil {
+		return nil
+	}
+	out := new(AddressPoolList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *AddressPoolList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AddressPoolSpec) DeepCopyInto(out *AddressPoolSpec) {
+	*out = *in
+	if in.Addresses != nil {
+		in, out := &in.Addresses, &out.Addresses
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.AutoAssign != nil {
+		in, out := &in.AutoAssign, &out.AutoAssign
+		*out = new(bool)
+		**out = **in
+	}
+	if in.AutoExpand != nil {
+		in, out := &in.AutoExpand, &out.AutoExpand
+		*out = new(AutoExpandSpec)
+		**out = **in
+	}
+	if in.AllowedNamespaces != nil {
+		in, out := &in.AllowedNamespaces, &out.AllowedNamespaces
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.BGPAdvertisements != nil {
+		in, out := &in.BGPAdvertisements, &out.BGPAdvertisements
+		*out = make([]BGPAdvertisement, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AddressPoolSpec.
+func (in *AddressPoolSpec) DeepCopy() *AddressPoolSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(AddressPoolSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AddressPoolStatus) DeepCopyInto(out *AddressPoolStatus) {
+	*out = *in
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]v1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AddressPoolStatus.
+func (in *AddressPoolStatus) DeepCopy() *AddressPoolStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(AddressPoolStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AutoExpandSpec) DeepCopyInto(out *AutoExpandSpec) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AutoExpandSpec.
+func (in *AutoExpandSpec) DeepCopy() *AutoExpandSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(AutoExpandSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *BGPAdvertisement) DeepCopyInto(out *BGPAdvertisement) {
+	*out = *in
+	if in.AggregationLength != nil {
+		in, out := &in.AggregationLength, &out.AggregationLength
+		*out = new(int32)
+		**out = **in
+	}
+	if in.LocalPref != nil {
+		in, out := &in.LocalPref, &out.LocalPref
+		*out = new(uint32)
+		**out = **in
+	}
+	if in.Communities != nil {
+		in, out := &in.Communities, &out.Communities
+		*out = make([]BGPCommunity, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BGPAdvertisement.
+func (in *BGPAdvertisement) DeepCopy() *BGPAdvertisement {
+	if in == nil {
+		return nil
+	}
+	out := new(BGPAdvertisement)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *BGPCommunity) DeepCopyInto(out *BGPCommunity) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BGPCommunity.
+func (in *BGPCommunity) DeepCopy() *BGPCommunity {
+	if in == nil {
+		return nil
+	}
+	out := new(BGPCommunity)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *MetalLB) DeepCopyInto(out *MetalLB) {
 	*out = *in
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
-	out.Spec = in.Spec
+	in.Spec.DeepCopyInto(&out.Spec)
 	in.Status.DeepCopyInto(&out.Status)
 }
 
@@ -87,6 +271,109 @@ func (in *MetalLBList) DeepCopyObject() runtime.Object {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *MetalLBSpec) DeepCopyInto(out *MetalLBSpec) {
 	*out = *in
+	if in.ReadOnlyRootFilesystem != nil {
+		in, out := &in.ReadOnlyRootFilesystem, &out.ReadOnlyRootFilesystem
+		*out = new(bool)
+		**out = **in
+	}
+	if in.ExportStats != nil {
+		in, out := &in.ExportStats, &out.ExportStats
+		*out = new(bool)
+		**out = **in
+	}
+	if in.SpeakerHostAliases != nil {
+		in, out := &in.SpeakerHostAliases, &out.SpeakerHostAliases
+		*out = make([]corev1.HostAlias, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.SpeakerNodeSelector != nil {
+		in, out := &in.SpeakerNodeSelector, &out.SpeakerNodeSelector
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.SpeakerTolerations != nil {
+		in, out := &in.SpeakerTolerations, &out.SpeakerTolerations
+		*out = make([]corev1.Toleration, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.ControllerNodeSelector != nil {
+		in, out := &in.ControllerNodeSelector, &out.ControllerNodeSelector
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.ControllerTolerations != nil {
+		in, out := &in.ControllerTolerations, &out.ControllerTolerations
+		*out = make([]corev1.Toleration, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.ControllerHostAliases != nil {
+		in, out := &in.ControllerHostAliases, &out.ControllerHostAliases
+		*out = make([]corev1.HostAlias, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.SpeakerResources != nil {
+		in, out := &in.SpeakerResources, &out.SpeakerResources
+		*out = new(corev1.ResourceRequirements)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.ControllerResources != nil {
+		in, out := &in.ControllerResources, &out.ControllerResources
+		*out = new(corev1.ResourceRequirements)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.FRRReadinessProbe != nil {
+		in, out := &in.FRRReadinessProbe, &out.FRRReadinessProbe
+		*out = new(ProbeConfig)
+		**out = **in
+	}
+	if in.ManageWorkloads != nil {
+		in, out := &in.ManageWorkloads, &out.ManageWorkloads
+		*out = new(bool)
+		**out = **in
+	}
+	if in.EnableRBACProxy != nil {
+		in, out := &in.EnableRBACProxy, &out.EnableRBACProxy
+		*out = new(bool)
+		**out = **in
+	}
+	out.DegradedThreshold = in.DegradedThreshold
+	if in.SpeakerSysctls != nil {
+		in, out := &in.SpeakerSysctls, &out.SpeakerSysctls
+		*out = make([]corev1.Sysctl, len(*in))
+		copy(*out, *in)
+	}
+	if in.EnablePrometheusRules != nil {
+		in, out := &in.EnablePrometheusRules, &out.EnablePrometheusRules
+		*out = new(bool)
+		**out = **in
+	}
+	if in.EnableMetrics != nil {
+		in, out := &in.EnableMetrics, &out.EnableMetrics
+		*out = new(bool)
+		**out = **in
+	}
+	if in.MetricsTLSSecret != nil {
+		in, out := &in.MetricsTLSSecret, &out.MetricsTLSSecret
+		*out = new(corev1.LocalObjectReference)
+		**out = **in
+	}
+	if in.EnableCanary != nil {
+		in, out := &in.EnableCanary, &out.EnableCanary
+		*out = new(bool)
+		**out = **in
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MetalLBSpec.
@@ -109,6 +396,8 @@ func (in *MetalLBStatus) DeepCopyInto(out *MetalLBStatus) {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
+	in.LastErrorTime.DeepCopyInto(&out.LastErrorTime)
+	in.UnhealthySince.DeepCopyInto(&out.UnhealthySince)
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MetalLBStatus.
@@ -120,3 +409,18 @@ func (in *MetalLBStatus) DeepCopy() *MetalLBStatus {
 	in.DeepCopyInto(out)
 	return out
 }
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ProbeConfig) DeepCopyInto(out *ProbeConfig) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ProbeConfig.
+func (in *ProbeConfig) DeepCopy() *ProbeConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(ProbeConfig)
+	in.DeepCopyInto(out)
+	return out
+}
@@ -0,0 +1,186 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// metallb-validate validates a directory of MetalLB operator manifests offline,
+// before they are applied:
+//
+//	metallb-validate <dir>
+//
+// It exits non-zero, after reporting all the errors found, when a manifest is
+// invalid.
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	kjson "k8s.io/apimachinery/pkg/runtime/serializer/json"
+	utilyaml "k8s.io/apimachinery/pkg/util/yaml"
+
+	metallbv1alpha1 "github.com/metallb/metallb-operator/api/v1alpha1"
+	metallbv1beta1 "github.com/metallb/metallb-operator/api/v1beta1"
+)
+
+func main() {
+	if len(os.Args) != 2 {
+		fmt.Fprintf(os.Stderr, "usage: %s <dir>\n", filepath.Base(os.Args[0]))
+		os.Exit(2)
+	}
+	errs := validateDir(os.Args[1])
+	for _, err := range errs {
+		fmt.Fprintln(os.Stderr, err)
+	}
+	if len(errs) > 0 {
+		fmt.Fprintf(os.Stderr, "%d errors found\n", len(errs))
+		os.Exit(1)
+	}
+}
+
+var scheme = runtime.NewScheme()
+
+func init() {
+	_ = metallbv1alpha1.AddToScheme(scheme)
+	_ = metallbv1beta1.AddToScheme(scheme)
+}
+
+// validateDir validates the manifests of the YAML and JSON files of the
+// directory and its subdirectories. Each resource is validated on its own,
+// then the AddressPools are validated together. Resources of other API
+// groups are skipped.
+func validateDir(dir string) []error {
+	files := []string{}
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		switch filepath.Ext(path) {
+		case ".yaml", ".yml", ".json":
+			if !info.IsDir() {
+				files = append(files, path)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return []error{errors.Wrapf(err, "failed to read %s", dir)}
+	}
+	sort.Strings(files)
+
+	errs := []error{}
+	pools := []metallbv1alpha1.AddressPool{}
+	peers := []metallbv1alpha1.BGPPeer{}
+	profiles := []metallbv1alpha1.BFDProfile{}
+	communities := []metallbv1alpha1.Community{}
+	for _, file := range files {
+		objs, fileErrs := decodeFile(file)
+		errs = append(errs, fileErrs...)
+		for _, obj := range objs {
+			switch o := obj.(type) {
+			case *metallbv1alpha1.AddressPool:
+				if err := o.Validate(); err != nil {
+					errs = append(errs, errors.Wrap(err, file))
+				}
+				pools = append(pools, *o)
+			case *metallbv1beta1.AddressPool:
+				pool := metallbv1alpha1.AddressPool{}
+				if err := pool.ConvertFrom(o); err != nil {
+					errs = append(errs, errors.Wrap(err, file))
+					continue
+				}
+				if err := pool.Validate(); err != nil {
+					errs = append(errs, errors.Wrap(err, file))
+				}
+				pools = append(pools, pool)
+			case *metallbv1alpha1.BGPPeer:
+				if err := o.Validate(); err != nil {
+					errs = append(errs, errors.Wrap(err, file))
+				}
+				peers = append(peers, *o)
+			case *metallbv1alpha1.BFDProfile:
+				if err := o.Validate(); err != nil {
+					errs = append(errs, errors.Wrap(err, file))
+				}
+				profiles = append(profiles, *o)
+			case *metallbv1alpha1.Community:
+				if err := o.Validate(); err != nil {
+					errs = append(errs, errors.Wrap(err, file))
+				}
+				communities = append(communities, *o)
+			}
+		}
+	}
+	if err := metallbv1alpha1.ValidateCombined(pools, peers, profiles, communities); err != nil {
+		errs = append(errs, err)
+	}
+	return errs
+}
+
+// decodeFile strictly decodes the documents of the file into the resources
+// of the operator, rejecting unknown and duplicated fields.
+func decodeFile(file string) ([]runtime.Object, []error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, []error{err}
+	}
+	decoder := kjson.NewSerializerWithOptions(kjson.DefaultMetaFactory, scheme, scheme,
+		kjson.SerializerOptions{Yaml: true, Strict: true})
+
+	objs := []runtime.Object{}
+	errs := []error{}
+	reader := utilyaml.NewYAMLReader(bufio.NewReader(bytes.NewReader(data)))
+	for i := 0; ; i++ {
+		doc, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return objs, append(errs, errors.Wrapf(err, "%s: failed to read document %d", file, i))
+		}
+		if len(bytes.TrimSpace(doc)) == 0 {
+			continue
+		}
+
+		var meta runtime.TypeMeta
+		if err := utilyaml.Unmarshal(doc, &meta); err != nil {
+			errs = append(errs, errors.Wrapf(err, "%s: failed to decode document %d", file, i))
+			continue
+		}
+		gv, err := schema.ParseGroupVersion(meta.APIVersion)
+		if err != nil {
+			errs = append(errs, errors.Wrapf(err, "%s: document %d", file, i))
+			continue
+		}
+		if gv.Group != metallbv1alpha1.GroupVersion.Group {
+			continue
+		}
+		obj, _, err := decoder.Decode(doc, nil, nil)
+		if err != nil {
+			errs = append(errs, errors.Wrapf(err, "%s: failed to decode %s %d", file, meta.Kind, i))
+			continue
+		}
+		objs = append(objs, obj)
+	}
+	return objs, errs
+}
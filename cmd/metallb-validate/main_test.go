@@ -0,0 +1,41 @@
+package main
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestValidateDirValid(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	g.Expect(validateDir("testdata/valid")).To(BeEmpty())
+}
+
+func TestValidateDirInvalid(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	errs := validateDir("testdata/invalid")
+	messages := []string{}
+	for _, err := range errs {
+		messages = append(messages, err.Error())
+	}
+	g.Expect(messages).To(HaveLen(4), "%v", messages)
+	g.Expect(messages[0]).To(And(ContainSubstring("testdata/invalid/metallb.yaml"), ContainSubstring("unknownField")))
+	g.Expect(messages[1]).To(And(ContainSubstring("testdata/invalid/peers.yaml"), ContainSubstring("router.example.com")))
+	g.Expect(messages[2]).To(And(ContainSubstring("testdata/invalid/pools.yaml"), ContainSubstring("bronze"),
+		ContainSubstring("start of the range is after its end")))
+	// The resources are validated together
+	g.Expect(messages[3]).To(And(ContainSubstring("overlaps with range 10.0.0.0/24 of AddressPool metallb-system/gold"),
+		ContainSubstring("Duplicate value: \"gold\""),
+		ContainSubstring(`BGPPeer.metallb.io "spine" is invalid: spec.bfdProfile: Not found: "slow"`),
+		ContainSubstring(`AddressPool.metallb.io "platinum" is invalid: spec.bgpAdvertisements[0].communities[0].name: Not found: "premium"`)))
+}
+
+func TestValidateDirMissing(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	errs := validateDir("testdata/missing")
+	g.Expect(errs).To(HaveLen(1))
+	g.Expect(errs[0].Error()).To(ContainSubstring("failed to read testdata/missing"))
+}
@@ -0,0 +1,76 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+
+	metallbv1alpha1 "github.com/metallb/metallb-operator/api/v1alpha1"
+	"github.com/metallb/metallb-operator/pkg/addresses"
+)
+
+// disallowedServices returns the services outside of the AllowedNamespaces of
+// the pool holding one of its addresses, as <namespace>/<name>.
+func (r *AddressPoolReconciler) disallowedServices(ctx context.Context, pool *metallbv1alpha1.AddressPool) ([]string, error) {
+	if len(pool.Spec.AllowedNamespaces) == 0 {
+		return nil, nil
+	}
+	allowed := map[string]bool{}
+	for _, namespace := range pool.Spec.AllowedNamespaces {
+		allowed[namespace] = true
+	}
+
+	services := &corev1.ServiceList{}
+	if err := r.List(ctx, services); err != nil {
+		return nil, fmt.Errorf("Failed to list services %w", err)
+	}
+	res := []string{}
+	for _, svc := range services.Items {
+		if svc.Spec.Type != corev1.ServiceTypeLoadBalancer || allowed[svc.Namespace] {
+			continue
+		}
+		for _, ingress := range svc.Status.LoadBalancer.Ingress {
+			if _, ok := addresses.FindPool(net.ParseIP(ingress.IP), []metallbv1alpha1.AddressPool{*pool}); ok {
+				res = append(res, svc.Namespace+"/"+svc.Name)
+				break
+			}
+		}
+	}
+	sort.Strings(res)
+	return res, nil
+}
+
+// checkAllowedNamespaces emits a NamespaceNotAllowed event on the pool when
+// services outside of its AllowedNamespaces hold one of its addresses.
+func (r *AddressPoolReconciler) checkAllowedNamespaces(ctx context.Context, pool *metallbv1alpha1.AddressPool) error {
+	services, err := r.disallowedServices(ctx, pool)
+	if err != nil {
+		return err
+	}
+	if len(services) == 0 || r.Recorder == nil {
+		return nil
+	}
+	r.Recorder.Event(pool, corev1.EventTypeWarning, "NamespaceNotAllowed",
+		fmt.Sprintf("Services outside of the allowed namespaces hold addresses of the pool: %s", strings.Join(services, ", ")))
+	return nil
+}
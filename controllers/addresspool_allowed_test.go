@@ -0,0 +1,106 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	metallbv1alpha1 "github.com/metallb/metallb-operator/api/v1alpha1"
+	metallbv1beta1 "github.com/metallb/metallb-operator/api/v1beta1"
+	"github.com/metallb/metallb-operator/test/manifests"
+)
+
+func TestAddressPoolAllowedNamespaces(t *testing.T) {
+	g := NewGomegaWithT(t)
+	manifestPath := AddressPoolManifestPath
+	AddressPoolManifestPath = "../bindata/configuration/address-pool"
+	defer func() { AddressPoolManifestPath = manifestPath }()
+
+	loadBalancer := func(namespace, name, ip string) *corev1.Service {
+		return &corev1.Service{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace},
+			Spec:       corev1.ServiceSpec{Type: corev1.ServiceTypeLoadBalancer},
+			Status: corev1.ServiceStatus{LoadBalancer: corev1.LoadBalancerStatus{
+				Ingress: []corev1.LoadBalancerIngress{{IP: ip}},
+			}},
+		}
+	}
+	autoAssign := true
+	objs := []client.Object{
+		&metallbv1alpha1.AddressPool{
+			ObjectMeta: metav1.ObjectMeta{Name: "gold", Namespace: MetalLBTestNameSpace},
+			Spec: metallbv1alpha1.AddressPoolSpec{
+				Protocol:          "layer2",
+				Addresses:         []string{"10.0.0.0/24"},
+				AutoAssign:        &autoAssign,
+				AllowedNamespaces: []string{"team-a"},
+			},
+		},
+		loadBalancer("team-a", "web", "10.0.0.1"),
+		// Outside of the pool
+		loadBalancer("team-b", "web", "192.168.0.1"),
+	}
+	recorder := record.NewFakeRecorder(10)
+	reconciler := &AddressPoolReconciler{
+		Client:    newFakeClientBuilder().WithScheme(testScheme(g)).WithObjects(testMetalLB(metallbv1beta1.MetalLBSpec{})).WithObjects(objs...).Build(),
+		Log:       ctrl.Log.WithName("controllers").WithName("AddressPool"),
+		Namespace: MetalLBTestNameSpace,
+		Recorder:  poolRecorder{recorder},
+	}
+	key := types.NamespacedName{Name: "gold", Namespace: MetalLBTestNameSpace}
+	reconcile := func() {
+		_, err := reconciler.Reconcile(context.Background(), ctrl.Request{NamespacedName: key})
+		g.Expect(err).ToNot(HaveOccurred())
+	}
+
+	reconcile()
+	g.Expect(recorder.Events).ToNot(Receive())
+
+	// The restriction is not part of the MetalLB configuration, the pool is
+	// not assigned automatically instead
+	configMap := &corev1.ConfigMap{}
+	g.Expect(reconciler.Get(context.Background(), types.NamespacedName{Name: "config", Namespace: MetalLBTestNameSpace}, configMap)).To(Succeed())
+	g.Expect(configMap.Data["config"]).To(MatchYAML(`address-pools:
+- name: gold
+  protocol: layer2
+  addresses:
+  - 10.0.0.0/24
+  auto-assign: false
+`))
+	g.Expect(manifests.ValidateMetalLBConfig(configMap.Data["config"])).To(Succeed())
+
+	// Services of other namespaces requesting an address of the pool are reported
+	g.Expect(reconciler.Create(context.Background(), loadBalancer("team-c", "db", "10.0.0.3"))).To(Succeed())
+	g.Expect(reconciler.Create(context.Background(), loadBalancer("team-b", "db", "10.0.0.2"))).To(Succeed())
+	reconcile()
+	g.Expect(recorder.Events).To(Receive(Equal(
+		"Warning NamespaceNotAllowed Services outside of the allowed namespaces hold addresses of the pool: team-b/db, team-c/db")))
+}
+
+func TestValidateMetalLBConfig(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	g.Expect(manifests.ValidateMetalLBConfig(`address-pools:
+- name: gold
+  protocol: layer2
+  addresses:
+  - 10.0.0.0/24
+  auto-assign: false
+`)).To(Succeed())
+	// MetalLB has no namespace restriction in its configuration
+	g.Expect(manifests.ValidateMetalLBConfig(`address-pools:
+- name: gold
+  protocol: layer2
+  addresses:
+  - 10.0.0.0/24
+  allowed-namespaces:
+  - team-a
+`)).ToNot(Succeed())
+}
@@ -0,0 +1,79 @@
+package controllers
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	uns "k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	metallbv1alpha1 "github.com/metallb/metallb-operator/api/v1alpha1"
+	"github.com/metallb/metallb-operator/pkg/apply"
+	"github.com/metallb/metallb-operator/test/manifests"
+)
+
+func TestRenderBGPAdvertisements(t *testing.T) {
+	g := NewGomegaWithT(t)
+	manifestPath := AddressPoolManifestPath
+	AddressPoolManifestPath = "../bindata/configuration/address-pool"
+	defer func() { AddressPoolManifestPath = manifestPath }()
+
+	aggregationLength, noAggregation, localPref := int32(24), int32(0), uint32(100)
+	pools := []metallbv1alpha1.AddressPool{
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "gold", Namespace: MetalLBTestNameSpace},
+			Spec: metallbv1alpha1.AddressPoolSpec{
+				Protocol:  "bgp",
+				Addresses: []string{"10.0.0.0/24"},
+				BGPAdvertisements: []metallbv1alpha1.BGPAdvertisement{
+					{AggregationLength: &aggregationLength, LocalPref: &localPref, Communities: []metallbv1alpha1.BGPCommunity{{ASN: 64512, Value: 100}, {ASN: 64512, Value: 200}}},
+					{Communities: []metallbv1alpha1.BGPCommunity{{ASN: 64512, Value: 300}, {WellKnown: "no-advertise"}}},
+					{AggregationLength: &noAggregation},
+					{},
+				},
+			},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "silver", Namespace: MetalLBTestNameSpace},
+			Spec: metallbv1alpha1.AddressPoolSpec{
+				Protocol:  "layer2",
+				Addresses: []string{"10.0.1.0/24"},
+			},
+		},
+	}
+	reconciler := &AddressPoolReconciler{
+		Client:    newFakeClientBuilder().WithScheme(testScheme(g)).Build(),
+		Log:       ctrl.Log.WithName("controllers").WithName("AddressPool"),
+		Namespace: MetalLBTestNameSpace,
+	}
+	obj, poolErrs, err := reconciler.renderObject(pools)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(poolErrs).To(BeEmpty())
+
+	config, _, err := uns.NestedString(obj.Object, "data", apply.AddressPoolConfigMap)
+	g.Expect(err).ToNot(HaveOccurred())
+	// The layer2 pool has no bgp-advertisements key
+	g.Expect(config).To(Equal(`address-pools:
+- name: gold
+  protocol: bgp
+  addresses:
+  - 10.0.0.0/24
+  bgp-advertisements:
+  - aggregation-length: 24
+    localpref: 100
+    communities:
+    - 64512:100
+    - 64512:200
+  - communities:
+    - 64512:300
+    - 65535:65282
+  - aggregation-length: 0
+  - {}
+- name: silver
+  protocol: layer2
+  addresses:
+  - 10.0.1.0/24
+`))
+	g.Expect(manifests.ValidateMetalLBConfig(config)).To(Succeed())
+}
@@ -0,0 +1,180 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	metallbv1alpha1 "github.com/metallb/metallb-operator/api/v1alpha1"
+	metallbv1beta1 "github.com/metallb/metallb-operator/api/v1beta1"
+	"github.com/metallb/metallb-operator/pkg/render"
+)
+
+// listBGPPeers returns the BGPPeers of the operator namespace.
+func (r *AddressPoolReconciler) listBGPPeers() ([]metallbv1alpha1.BGPPeer, error) {
+	peerList := &metallbv1alpha1.BGPPeerList{}
+	if err := r.List(context.Background(), peerList, client.InNamespace(r.Namespace)); err != nil {
+		return nil, err
+	}
+	return peerList.Items, nil
+}
+
+// listBFDProfiles returns the BFDProfiles of the operator namespace.
+func (r *AddressPoolReconciler) listBFDProfiles() ([]metallbv1alpha1.BFDProfile, error) {
+	profileList := &metallbv1alpha1.BFDProfileList{}
+	if err := r.List(context.Background(), profileList, client.InNamespace(r.Namespace)); err != nil {
+		return nil, err
+	}
+	return profileList.Items, nil
+}
+
+// listCommunities returns the Communities of the operator namespace.
+func (r *AddressPoolReconciler) listCommunities() ([]metallbv1alpha1.Community, error) {
+	communityList := &metallbv1alpha1.CommunityList{}
+	if err := r.List(context.Background(), communityList, client.InNamespace(r.Namespace)); err != nil {
+		return nil, err
+	}
+	return communityList.Items, nil
+}
+
+// peerPasswords returns the passwords of the PasswordSecrets of the peers, by
+// Secret name. The Secrets missing, or missing their password key, are left
+// out.
+func (r *AddressPoolReconciler) peerPasswords(peers []metallbv1alpha1.BGPPeer) (map[string]string, error) {
+	passwords := map[string]string{}
+	for _, peer := range peers {
+		if peer.Spec.PasswordSecret == nil {
+			continue
+		}
+		secret := &corev1.Secret{}
+		err := r.Get(context.Background(), types.NamespacedName{Name: peer.Spec.PasswordSecret.Name, Namespace: peer.Namespace}, secret)
+		if errors.IsNotFound(err) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("Failed to get the password secret of bgppeer %s %w", peer.Name, err)
+		}
+		if password, ok := secret.Data[metallbv1alpha1.PasswordSecretKey]; ok {
+			passwords[secret.Name] = string(password)
+		}
+	}
+	return passwords, nil
+}
+
+// hasBGPConfig returns whether there are BGPPeers, BFDProfiles or Communities
+// to render.
+func (r *AddressPoolReconciler) hasBGPConfig() (bool, error) {
+	peers, err := r.listBGPPeers()
+	if err != nil {
+		return false, fmt.Errorf("Failed to get existing bgppeer objects %w", err)
+	}
+	profiles, err := r.listBFDProfiles()
+	if err != nil {
+		return false, fmt.Errorf("Failed to get existing bfdprofile objects %w", err)
+	}
+	communities, err := r.listCommunities()
+	if err != nil {
+		return false, fmt.Errorf("Failed to get existing community objects %w", err)
+	}
+	return len(peers) > 0 || len(profiles) > 0 || len(communities) > 0, nil
+}
+
+// mergeCommunities merges the Communities into the bgp-communities of the
+// configuration, the ones left out are logged.
+func (r *AddressPoolReconciler) mergeCommunities() ([]render.CommunityConfig, error) {
+	communities, err := r.listCommunities()
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get existing community objects %w", err)
+	}
+	configs, errs := render.MergeCommunities(communities)
+	for _, err := range errs {
+		r.Log.Info(fmt.Sprintf("Leaving out of the MetalLB configuration %s", err))
+	}
+	return configs, nil
+}
+
+// mergeBGPConfig merges the BGPPeers, with their passwords, and the
+// BFDProfiles into the configuration.
+// The ones left out are logged.
+func (r *AddressPoolReconciler) mergeBGPConfig(config *render.MetalLBConfig) error {
+	bgpBackend, err := r.bgpBackend()
+	if err != nil {
+		return err
+	}
+	peers, err := r.listBGPPeers()
+	if err != nil {
+		return fmt.Errorf("Failed to get existing bgppeer objects %w", err)
+	}
+	profiles, err := r.listBFDProfiles()
+	if err != nil {
+		return fmt.Errorf("Failed to get existing bfdprofile objects %w", err)
+	}
+
+	passwords, err := r.peerPasswords(peers)
+	if err != nil {
+		return err
+	}
+
+	var errs, peerErrs []error
+	config.BFDProfiles, errs = render.MergeBFDProfiles(profiles)
+	config.Peers, peerErrs = render.MergePeers(peers, config.BFDProfiles, passwords, bgpBackend)
+	for _, err := range append(errs, peerErrs...) {
+		r.Log.Info(fmt.Sprintf("Leaving out of the MetalLB configuration %s", err))
+	}
+	return nil
+}
+
+// bgpBackend returns the BGP backend of the MetalLB resource, empty for the
+// native one or when there is no MetalLB resource.
+func (r *AddressPoolReconciler) bgpBackend() (string, error) {
+	metallb := &metallbv1beta1.MetalLB{}
+	err := r.Get(context.Background(), types.NamespacedName{Name: defaultMetalLBCrName, Namespace: r.Namespace}, metallb)
+	if errors.IsNotFound(err) {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("Failed to get MetalLB resource %w", err)
+	}
+	return metallb.Spec.BGPBackend, nil
+}
+
+// syncBGPConfig renders the MetalLB ConfigMap after a change of the BGP
+// configuration. The peers and profiles not rendered anymore are dropped
+// from it, and it is deleted once there is nothing left to render, as when
+// the last AddressPool is deleted.
+func (r *AddressPoolReconciler) syncBGPConfig(req ctrl.Request) error {
+	pools, err := r.listAddressPools()
+	if err != nil {
+		return fmt.Errorf("Failed to get existing addresspool objects %w", err)
+	}
+	hasBGPConfig, err := r.hasBGPConfig()
+	if err != nil {
+		return err
+	}
+	if len(pools) == 0 && !hasBGPConfig {
+		return r.syncMetalLBAddressPools(req)
+	}
+	_, err = r.syncMetalLBAddressPool(nil)
+	return err
+}
@@ -18,21 +18,36 @@ package controllers
 
 import (
 	"context"
+	goerrors "errors"
 	"fmt"
+	"sort"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/go-logr/logr"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
 
 	metallbv1alpha1 "github.com/metallb/metallb-operator/api/v1alpha1"
+	metallbv1beta1 "github.com/metallb/metallb-operator/api/v1beta1"
 	"github.com/metallb/metallb-operator/pkg/apply"
+	metallbconfig "github.com/metallb/metallb-operator/pkg/config"
 	"github.com/metallb/metallb-operator/pkg/render"
+	"github.com/metallb/metallb-operator/pkg/status"
 )
 
 // AddressPoolReconciler reconciles a AddressPool object
@@ -41,8 +56,47 @@ type AddressPoolReconciler struct {
 	Log       logr.Logger
 	Scheme    *runtime.Scheme
 	Namespace string
+	// MaxAddressPools is the maximum number of AddressPools rendered into the
+	// MetalLB ConfigMap, 0 means no limit.
+	MaxAddressPools int
+	// PoolNamespaces are the namespaces the AddressPools are collected from,
+	// AllPoolNamespaces for all of them. Defaults to the operator namespace.
+	PoolNamespaces []string
+	// CheckPodCIDR enables checking the AddressPools against the pod CIDRs of the nodes.
+	CheckPodCIDR bool
+	// Recorder emits the AddressPool events, none are emitted when nil.
+	Recorder record.EventRecorder
+	// ConfigAppliedEvents enables emitting an event on the MetalLB resource
+	// each time the MetalLB configuration changes.
+	ConfigAppliedEvents bool
+	// PoolMetrics enables exporting the total and used addresses of each
+	// pool as metrics of the operator.
+	PoolMetrics bool
+	// ReservedRangesConfigMap is the ConfigMap of the operator namespace
+	// listing the reserved ranges, the AddressPools overlapping with one of
+	// them are left out. Empty means no range is reserved.
+	ReservedRangesConfigMap string
+	// ConfigWriteFailureThreshold is the number of consecutive failed writes
+	// of the MetalLB ConfigMaps setting the ConfigWriteUnstable condition of
+	// the MetalLB resource, 0 disables the condition.
+	ConfigWriteFailureThreshold int
+
+	// configWriteFailures counts the consecutive failed writes of the
+	// MetalLB ConfigMaps, the reconciler runs a single worker.
+	configWriteFailures int
+	// configLock serializes the syncs of the MetalLB ConfigMaps, run by both
+	// the AddressPool and the BGPPeer reconcilers.
+	configLock sync.Mutex
 }
 
+// AllPoolNamespaces makes the reconciler collect the AddressPools from all the namespaces
+const AllPoolNamespaces = "*"
+
+// InstanceAnnotation assigns an AddressPool to a MetalLB instance, either
+// <name> for an instance in the operator namespace, or <namespace>/<name>.
+// Pools without it belong to the MetalLB instance of the operator namespace.
+const InstanceAnnotation = "metallb.io/instance"
+
 const RetryPeriod = 5 * time.Minute
 
 var AddressPoolManifestPath = "./bindata/configuration/address-pool"
@@ -56,8 +110,9 @@ func (r *AddressPoolReconciler) Reconcile(ctx context.Context, req ctrl.Request)
 	instance := &metallbv1alpha1.AddressPool{}
 	defer r.Log.Info(fmt.Sprintf("Finish AddressPool reconcile loop for %v", req.NamespacedName))
 
-	if err := r.Get(ctx, req.NamespacedName, instance); err != nil {
+	if err := r.getAddressPool(ctx, req.NamespacedName, instance); err != nil {
 		if errors.IsNotFound(err) {
+			status.ForgetDegraded("AddressPool", req.Namespace, req.Name)
 			err = r.syncMetalLBAddressPools(req)
 			if err != nil {
 				return ctrl.Result{}, err
@@ -65,97 +120,487 @@ func (r *AddressPoolReconciler) Reconcile(ctx context.Context, req ctrl.Request)
 		}
 		return ctrl.Result{}, client.IgnoreNotFound(err)
 	}
-	err := r.syncMetalLBAddressPool(instance)
+	if !r.collectsNamespace(instance.Namespace) {
+		r.Log.Info(fmt.Sprintf("Ignoring AddressPool %v outside of the pool namespaces", req.NamespacedName))
+		return ctrl.Result{}, nil
+	}
+	if !r.ownsPool(instance) {
+		r.Log.Info(fmt.Sprintf("Ignoring AddressPool %v of MetalLB instance %s", req.NamespacedName, instance.Annotations[InstanceAnnotation]))
+		return ctrl.Result{}, nil
+	}
+	// The pools are reconciled again once the MetalLB resource is created
+	running, err := r.metalLBRunning(ctx)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	if !running {
+		return ctrl.Result{}, r.waitForMetalLB(ctx, instance)
+	}
+	poolErr, err := r.syncMetalLBAddressPool(instance)
 	if err != nil {
 		r.Log.Info(fmt.Sprintf("sync MetalLB addresspool failed %s", err))
+		if errors.IsForbidden(err) {
+			if err := status.UpdateAddressPool(ctx, r.Client, instance, status.ConditionDegraded, "InsufficientPermissions", apiErrorMessage(err)); err != nil {
+				r.Log.Info(fmt.Sprintf("Failed to update addresspool status %s", err))
+			}
+		}
 		return ctrl.Result{RequeueAfter: RetryPeriod}, err
 	}
 
+	if poolErr != nil && goerrors.Is(poolErr.Err, errTooManyPools) {
+		message := fmt.Sprintf("The number of AddressPools exceeds the limit of %d", r.MaxAddressPools)
+		if err := status.UpdateAddressPool(ctx, r.Client, instance, status.ConditionDegraded, "TooManyPools", message); err != nil {
+			return ctrl.Result{}, err
+		}
+		// Check again later, in case some pools were deleted in the meantime
+		return ctrl.Result{RequeueAfter: RetryPeriod}, nil
+	}
+	var reservedErr *reservedRangeError
+	if poolErr != nil && goerrors.As(poolErr.Err, &reservedErr) {
+		if err := status.UpdateAddressPool(ctx, r.Client, instance, status.ConditionDegraded, "ReservedRangeViolation", reservedErr.Error()); err != nil {
+			return ctrl.Result{}, err
+		}
+		return ctrl.Result{}, nil
+	}
+	if poolErr != nil && goerrors.Is(poolErr.Err, render.ErrUnknownCommunity) {
+		if err := status.UpdateAddressPool(ctx, r.Client, instance, status.ConditionDegraded, "UnknownCommunity", poolErr.Err.Error()); err != nil {
+			return ctrl.Result{}, err
+		}
+		return ctrl.Result{}, nil
+	}
+	if poolErr != nil && errors.IsInvalid(poolErr.Err) {
+		if err := status.UpdateAddressPool(ctx, r.Client, instance, status.ConditionDegraded, "InvalidPool", poolErr.Err.Error()); err != nil {
+			return ctrl.Result{}, err
+		}
+		return ctrl.Result{}, nil
+	}
+	if poolErr != nil {
+		if err := status.UpdateAddressPool(ctx, r.Client, instance, status.ConditionDegraded, "ConflictingPool", poolErr.Err.Error()); err != nil {
+			return ctrl.Result{}, err
+		}
+		return ctrl.Result{}, nil
+	}
+
+	if r.CheckPodCIDR {
+		overlap, err := r.podCIDROverlap(ctx, instance)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+		if overlap != "" {
+			if err := status.UpdateAddressPool(ctx, r.Client, instance, status.ConditionDegraded, "PoolOverlapsPodCIDR", overlap); err != nil {
+				return ctrl.Result{}, err
+			}
+			// Check again later, in case the pool or the nodes changed in the meantime
+			return ctrl.Result{RequeueAfter: RetryPeriod}, nil
+		}
+	}
+
+	if err := status.UpdateAddressPool(ctx, r.Client, instance, status.ConditionAvailable, "", ""); err != nil {
+		return ctrl.Result{}, err
+	}
+	if err := r.updateExhausted(ctx, instance); err != nil {
+		return ctrl.Result{}, err
+	}
+	if err := r.checkAllowedNamespaces(ctx, instance); err != nil {
+		return ctrl.Result{}, err
+	}
+	if instance.Spec.AutoExpand != nil && meta.IsStatusConditionTrue(instance.Status.Conditions, status.ConditionExhausted) {
+		if err := r.expandPool(ctx, instance); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+	if instance.Spec.AllocationStrategy != "" {
+		err := status.UpdateAddressPoolAllocation(ctx, r.Client, instance, checkAllocationStrategy(instance.Spec.AllocationStrategy))
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+	if err := r.updateIPFamilies(ctx, instance); err != nil {
+		return ctrl.Result{}, err
+	}
 	return ctrl.Result{}, nil
 }
 
-func (r *AddressPoolReconciler) renderObject(instance *metallbv1alpha1.AddressPool) ([]*unstructured.Unstructured, error) {
-	data := render.MakeRenderData()
-	data.Data["Name"] = instance.Name
-	data.Data["Protocol"] = instance.Spec.Protocol
-	data.Data["AutoAssign"] = *instance.Spec.AutoAssign
-	data.Data["Addresses"] = instance.Spec.Addresses
-	data.Data["NameSpace"] = r.Namespace
-	objs, err := render.RenderDir(AddressPoolManifestPath, &data)
+// checkAllocationStrategy returns an error when MetalLB does not support the
+// allocation strategy. MetalLB always assigns the first free address of a pool,
+// so the Sequential strategy needs nothing in its configuration.
+func checkAllocationStrategy(strategy string) error {
+	switch strategy {
+	case "", metallbv1alpha1.AllocationSequential:
+		return nil
+	}
+	return fmt.Errorf("MetalLB does not support the %s allocation strategy, addresses are assigned sequentially", strategy)
+}
+
+// apiErrorMessage returns the message sent by the apiserver, which for
+// authorization failures names the verb and the resource that were denied.
+func apiErrorMessage(err error) string {
+	var apiStatus errors.APIStatus
+	if goerrors.As(err, &apiStatus) {
+		return apiStatus.Status().Message
+	}
+	return err.Error()
+}
+
+// errTooManyPools is reported for the AddressPools left out past MaxAddressPools.
+var errTooManyPools = goerrors.New("too many address pools")
+
+// renderObject renders the MetalLB ConfigMap holding all the given pools, in the
+// order requested by the MetalLB resource, and the BGPPeers, BFDProfiles and
+// Communities. The pools that could not be merged into the configuration are
+// returned as render.PoolErrors, the peers, profiles and communities left out
+// are only logged as their own reconcilers report them.
+func (r *AddressPoolReconciler) renderObject(pools []metallbv1alpha1.AddressPool) (*unstructured.Unstructured, []error, error) {
+	sortOrder, err := r.poolSortOrder()
 	if err != nil {
-		return nil, fmt.Errorf("Fail to render address-pool manifest err %v", err)
+		return nil, nil, err
 	}
+	communities, err := r.mergeCommunities()
+	if err != nil {
+		return nil, nil, err
+	}
+	config, poolErrs := render.MergePools(pools, communities)
+	for _, poolErr := range poolErrs {
+		r.Log.Info(fmt.Sprintf("Leaving out of the MetalLB configuration %s", poolErr))
+	}
+	if err := r.mergeBGPConfig(&config); err != nil {
+		return nil, nil, err
+	}
+
+	metallbconfig.SortPools(config.Pools, sortOrder)
 
-	if len(objs) > 1 {
-		return nil, fmt.Errorf("Fail to render we are expecting only one object and get %d", len(objs))
+	namespace, err := r.configNamespace()
+	if err != nil {
+		return nil, nil, err
+	}
+	obj, err := metallbconfig.RenderConfigMap(AddressPoolManifestPath, config, namespace, apply.AddressPoolConfigMap)
+	if err != nil {
+		return nil, nil, err
 	}
 
-	return objs, err
+	return obj, poolErrs, nil
+}
+
+// poolSortOrder returns the pool ordering requested by the MetalLB resource,
+// falling back to sorting by name when there is none.
+func (r *AddressPoolReconciler) poolSortOrder() (string, error) {
+	metallb := &metallbv1beta1.MetalLB{}
+	err := r.Get(context.Background(), types.NamespacedName{Name: defaultMetalLBCrName, Namespace: r.Namespace}, metallb)
+	if errors.IsNotFound(err) {
+		return metallbv1beta1.PoolSortByName, nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("Failed to get MetalLB resource %w", err)
+	}
+	if metallb.Spec.PoolSortOrder == "" {
+		return metallbv1beta1.PoolSortByName, nil
+	}
+	return metallb.Spec.PoolSortOrder, nil
 }
 
-func (r *AddressPoolReconciler) syncMetalLBAddressPool(instance *metallbv1alpha1.AddressPool) error {
-	objs, err := r.renderObject(instance)
+// configNamespace returns the namespace the MetalLB ConfigMaps are rendered to,
+// the one MetalLB is deployed to.
+func (r *AddressPoolReconciler) configNamespace() (string, error) {
+	return workloadsNamespace(context.Background(), r.Client, r.Namespace)
+}
+
+// setConfigOwner makes the MetalLB resource the owner of the rendered
+// ConfigMap, so it is garbage collected along with it. A ConfigMap of another
+// namespace gets the OwnerAnnotation instead. Without a MetalLB resource the
+// current owners are kept, so that the render triggered by its deletion does
+// not orphan the ConfigMap before it is collected.
+func (r *AddressPoolReconciler) setConfigOwner(obj *unstructured.Unstructured) error {
+	metallb := &metallbv1beta1.MetalLB{}
+	err := r.Get(context.Background(), types.NamespacedName{Name: defaultMetalLBCrName, Namespace: r.Namespace}, metallb)
+	if errors.IsNotFound(err) {
+		current := &corev1.ConfigMap{}
+		err := r.Get(context.Background(), types.NamespacedName{Name: obj.GetName(), Namespace: obj.GetNamespace()}, current)
+		if errors.IsNotFound(err) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		obj.SetOwnerReferences(current.OwnerReferences)
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("Failed to get MetalLB resource %w", err)
+	}
+	if obj.GetNamespace() != metallb.Namespace {
+		setOwnerAnnotation(metallb, obj)
+		return nil
+	}
+	if err := controllerutil.SetOwnerReference(metallb, obj, r.Client.Scheme()); err != nil {
+		return fmt.Errorf("Failed to set owner reference to %s %s %w", obj.GetNamespace(), obj.GetName(), err)
+	}
+	return nil
+}
+
+// syncMetalLBAddressPool renders all the AddressPools and the BGP configuration
+// into the MetalLB ConfigMap, and returns why the given instance was left out of it, if
+// it was. A nil instance only renders the ConfigMap. Nothing is rendered while
+// there is no MetalLB instance.
+func (r *AddressPoolReconciler) syncMetalLBAddressPool(instance *metallbv1alpha1.AddressPool) (*render.PoolError, error) {
+	r.configLock.Lock()
+	defer r.configLock.Unlock()
+
+	running, err := r.metalLBRunning(context.Background())
+	if err != nil || !running {
+		return nil, err
+	}
+
+	pools, err := r.listAddressPools()
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get existing addresspool objects %w", err)
+	}
+
+	pools, rejected := admitAddressPools(pools, r.MaxAddressPools)
+	pools, reserved, err := r.rejectReservedRanges(context.Background(), pools)
+	if err != nil {
+		return nil, err
+	}
+	obj, poolErrs, err := r.renderObject(pools)
+
+	if err != nil {
+		return nil, fmt.Errorf("Fail to render address-pool manifest %v", err)
+	}
 
+	if err := r.setConfigOwner(obj); err != nil {
+		return nil, err
+	}
+	err = r.applyConfigMap(context.Background(), obj)
+	r.trackConfigWrite(context.Background(), err)
 	if err != nil {
-		return fmt.Errorf("Fail to render address-pool manifest %v", err)
+		return nil, err
 	}
 
-	for _, obj := range objs {
-		if err := apply.ApplyObject(context.Background(), r.Client, obj); err != nil {
-			return fmt.Errorf("could not apply (%s) %s/%s err %v", obj.GroupVersionKind(),
-				obj.GetNamespace(), obj.GetName(), err)
+	if err := r.exportStats(context.Background(), pools); err != nil {
+		r.Log.Info(fmt.Sprintf("Failed to export the addresspool stats %s", err))
+	}
+	if err := r.updatePoolMetrics(context.Background(), pools); err != nil {
+		r.Log.Info(fmt.Sprintf("Failed to update the addresspool metrics %s", err))
+	}
+
+	if instance == nil {
+		return nil, nil
+	}
+	if rejected[types.NamespacedName{Name: instance.Name, Namespace: instance.Namespace}] {
+		return &render.PoolError{Namespace: instance.Namespace, Name: instance.Name, Err: errTooManyPools}, nil
+	}
+	if err, ok := reserved[types.NamespacedName{Name: instance.Name, Namespace: instance.Namespace}]; ok {
+		return &render.PoolError{Namespace: instance.Namespace, Name: instance.Name, Err: err}, nil
+	}
+	for _, err := range poolErrs {
+		var poolErr *render.PoolError
+		if goerrors.As(err, &poolErr) && poolErr.Name == instance.Name && poolErr.Namespace == instance.Namespace {
+			return poolErr, nil
+		}
+	}
+	return nil, nil
+}
+
+// listAddressPools returns the AddressPools of all the pool namespaces which
+// belong to the MetalLB instance of the operator namespace. They are read
+// through the v1beta1 hub version, as getAddressPool does.
+func (r *AddressPoolReconciler) listAddressPools() ([]metallbv1alpha1.AddressPool, error) {
+	pools := []metallbv1alpha1.AddressPool{}
+	for _, namespace := range r.poolNamespaces() {
+		opts := []client.ListOption{}
+		if namespace != AllPoolNamespaces {
+			opts = append(opts, client.InNamespace(namespace))
+		}
+		hubList := &metallbv1beta1.AddressPoolList{}
+		if err := r.List(context.Background(), hubList, opts...); err != nil {
+			return nil, err
+		}
+		for i := range hubList.Items {
+			pool := metallbv1alpha1.AddressPool{}
+			if err := pool.ConvertFrom(&hubList.Items[i]); err != nil {
+				return nil, err
+			}
+			if r.ownsPool(&pool) {
+				pools = append(pools, pool)
+			}
 		}
 	}
+	return pools, nil
+}
 
-	return err
+// getAddressPool reads the AddressPool through the v1beta1 hub version, the
+// one the AddressPools are stored as, so that reading them does not depend on
+// the conversion webhook, e.g. while it is upgraded along with the CRDs. The
+// pool is converted to the v1alpha1 version the configuration is rendered from.
+func (r *AddressPoolReconciler) getAddressPool(ctx context.Context, key types.NamespacedName, pool *metallbv1alpha1.AddressPool) error {
+	hub := &metallbv1beta1.AddressPool{}
+	if err := r.Get(ctx, key, hub); err != nil {
+		return err
+	}
+	return pool.ConvertFrom(hub)
 }
 
-func (r *AddressPoolReconciler) syncMetalLBAddressPools(req ctrl.Request) error {
-	instanceList := &metallbv1alpha1.AddressPoolList{}
-	objs := make([]*unstructured.Unstructured, 0)
+// ownsPool returns whether the pool belongs to the MetalLB instance of the
+// operator namespace, as per its InstanceAnnotation.
+func (r *AddressPoolReconciler) ownsPool(pool *metallbv1alpha1.AddressPool) bool {
+	instance, ok := pool.Annotations[InstanceAnnotation]
+	if !ok {
+		return true
+	}
+	if !strings.Contains(instance, "/") {
+		instance = r.Namespace + "/" + instance
+	}
+	return instance == r.Namespace+"/"+defaultMetalLBCrName
+}
 
-	configMap := &corev1.ConfigMap{
-		ObjectMeta: metav1.ObjectMeta{
-			Name:      "config",
-			Namespace: req.Namespace,
-		},
+func (r *AddressPoolReconciler) poolNamespaces() []string {
+	if len(r.PoolNamespaces) == 0 {
+		return []string{r.Namespace}
 	}
+	return r.PoolNamespaces
+}
 
-	// Delete the exiting configMap
-	if err := r.Delete(context.Background(), configMap); err != nil {
-		// if we don't have ConfigMap then there is nothing to do
-		if errors.IsNotFound(err) {
-			return nil
+func (r *AddressPoolReconciler) collectsNamespace(namespace string) bool {
+	for _, ns := range r.poolNamespaces() {
+		if ns == AllPoolNamespaces || ns == namespace {
+			return true
 		}
+	}
+	return false
+}
+
+// admitAddressPools returns the pools fitting in the given limit, oldest first so
+// new pools never evict the existing ones, and the names of the rejected pools.
+func admitAddressPools(pools []metallbv1alpha1.AddressPool, max int) ([]metallbv1alpha1.AddressPool, map[types.NamespacedName]bool) {
+	rejected := map[types.NamespacedName]bool{}
+	if max <= 0 || len(pools) <= max {
+		return pools, rejected
+	}
+
+	sort.SliceStable(pools, func(i, j int) bool {
+		ti, tj := pools[i].CreationTimestamp, pools[j].CreationTimestamp
+		if !ti.Equal(&tj) {
+			return ti.Before(&tj)
+		}
+		return pools[i].Name < pools[j].Name
+	})
+	for _, pool := range pools[max:] {
+		rejected[types.NamespacedName{Name: pool.Name, Namespace: pool.Namespace}] = true
+	}
+	return pools[:max], rejected
+}
+
+func (r *AddressPoolReconciler) syncMetalLBAddressPools(req ctrl.Request) error {
+	r.configLock.Lock()
+	defer r.configLock.Unlock()
+
+	// Delete the exiting configMap
+	deleted, err := r.deleteConfigMap(context.Background(), apply.AddressPoolConfigMap)
+	if err != nil {
 		r.Log.Info(fmt.Sprintf("Failed to delete existing Configmap %s", err))
 		return err
 	}
+	// if we don't have ConfigMap then there is nothing to do
+	if !deleted {
+		return nil
+	}
+	running, err := r.metalLBRunning(context.Background())
+	if err != nil || !running {
+		return err
+	}
 
-	if err := r.List(context.Background(), instanceList); err != nil {
+	pools, err := r.listAddressPools()
+	if err != nil {
 		r.Log.Info(fmt.Sprintf("Failed to get existing addresspool objects %s", err))
 		return err
 	}
 
-	for _, instance := range instanceList.Items {
-		objslist, err := r.renderObject(&instance)
-		if err != nil {
-			return fmt.Errorf("Failed to render address-pool manifest %v", err)
-		}
+	pools, _ = admitAddressPools(pools, r.MaxAddressPools)
+	pools, _, err = r.rejectReservedRanges(context.Background(), pools)
+	if err != nil {
+		return err
+	}
+	if err := r.exportStats(context.Background(), pools); err != nil {
+		r.Log.Info(fmt.Sprintf("Failed to export the addresspool stats %s", err))
+	}
+	if err := r.updatePoolMetrics(context.Background(), pools); err != nil {
+		r.Log.Info(fmt.Sprintf("Failed to update the addresspool metrics %s", err))
+	}
 
-		objs = append(objs, objslist...)
+	hasBGPConfig, err := r.hasBGPConfig()
+	if err != nil {
+		r.Log.Info(fmt.Sprintf("Failed to get existing bgp configuration %s", err))
+		return err
+	}
+	if len(pools) == 0 && !hasBGPConfig {
+		return nil
 	}
 
-	if len(objs) > 0 {
-		if err := apply.ApplyObjects(context.Background(), r.Client, objs); err != nil {
-			return fmt.Errorf("Failed to ApplyObjects %v", err)
-		}
+	obj, _, err := r.renderObject(pools)
+	if err != nil {
+		return fmt.Errorf("Failed to render address-pool manifest %v", err)
+	}
+	if err := r.setConfigOwner(obj); err != nil {
+		return err
+	}
+
+	err = r.applyConfigMap(context.Background(), obj)
+	r.trackConfigWrite(context.Background(), err)
+	if err != nil {
+		return fmt.Errorf("Failed to ApplyObjects %v", err)
 	}
 
 	return nil
 }
 
+// addressPoolRequests maps a change of the MetalLB resource, ConfigMap, of a
+// LoadBalancer service or of a Community to all the AddressPools, so they are
+// reconciled again.
+func (r *AddressPoolReconciler) addressPoolRequests(obj client.Object) []reconcile.Request {
+	pools, err := r.listAddressPools()
+	if err != nil {
+		r.Log.Info(fmt.Sprintf("Failed to get existing addresspool objects %s", err))
+		return nil
+	}
+
+	requests := make([]reconcile.Request, 0, len(pools))
+	for _, instance := range pools {
+		requests = append(requests, reconcile.Request{
+			NamespacedName: types.NamespacedName{Name: instance.Name, Namespace: instance.Namespace},
+		})
+	}
+	return requests
+}
+
 func (r *AddressPoolReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	// The MetalLB ConfigMaps are rendered again on any change, so that the
+	// ones edited or deleted by hand are overwritten with the rendered ones.
+	// The operator's own writes render the same ConfigMaps again, which are
+	// then left alone as they already match.
+	isMetalLBConfig := predicate.NewPredicateFuncs(func(obj client.Object) bool {
+		_, ok := obj.(*corev1.ConfigMap)
+		if !ok || obj.GetName() != apply.AddressPoolConfigMap {
+			return false
+		}
+		namespace, err := r.configNamespace()
+		return err == nil && obj.GetNamespace() == namespace
+	})
+	// The pools are checked again when the reserved ranges change
+	isReservedRanges := predicate.NewPredicateFuncs(func(obj client.Object) bool {
+		return r.ReservedRangesConfigMap != "" && obj.GetNamespace() == r.Namespace && obj.GetName() == r.ReservedRangesConfigMap
+	})
+	// The usage of the pools changes with the addresses assigned to the services
+	isLoadBalancer := predicate.NewPredicateFuncs(func(obj client.Object) bool {
+		svc, ok := obj.(*corev1.Service)
+		return ok && svc.Spec.Type == corev1.ServiceTypeLoadBalancer
+	})
 	return ctrl.NewControllerManagedBy(mgr).
-		For(&metallbv1alpha1.AddressPool{}).
-		Complete(r)
+		For(&metallbv1beta1.AddressPool{}).
+		Watches(&source.Kind{Type: &metallbv1beta1.MetalLB{}}, handler.EnqueueRequestsFromMapFunc(r.addressPoolRequests)).
+		Watches(&source.Kind{Type: &corev1.ConfigMap{}}, handler.EnqueueRequestsFromMapFunc(r.addressPoolRequests),
+			builder.WithPredicates(predicate.Or(isMetalLBConfig, isReservedRanges))).
+		Watches(&source.Kind{Type: &corev1.Service{}}, handler.EnqueueRequestsFromMapFunc(r.addressPoolRequests),
+			builder.WithPredicates(isLoadBalancer)).
+		// The pools referencing a community are checked again when it changes
+		Watches(&source.Kind{Type: &metallbv1alpha1.Community{}}, handler.EnqueueRequestsFromMapFunc(r.addressPoolRequests)).
+		Complete(withReconcileMetrics("addresspool", r))
 }
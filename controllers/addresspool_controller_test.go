@@ -2,18 +2,40 @@ package controllers
 
 import (
 	"context"
+	"fmt"
 	"github.com/metallb/metallb-operator/api/v1alpha1"
+	metallbv1beta1 "github.com/metallb/metallb-operator/api/v1beta1"
+	"github.com/metallb/metallb-operator/pkg/status"
 	"github.com/metallb/metallb-operator/test/consts"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	"time"
 )
 
 var _ = Describe("AddressPool Controller", func() {
+	// The pools are only rendered once there is a MetalLB instance
+	BeforeEach(func() {
+		metallb := &metallbv1beta1.MetalLB{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      defaultMetalLBCrName,
+				Namespace: MetalLBTestNameSpace,
+			},
+		}
+		err := k8sClient.Create(context.Background(), metallb)
+		if err != nil && !apierrors.IsAlreadyExists(err) {
+			Fail(err.Error())
+		}
+	})
+
 	Context("Creating AddressPool object", func() {
 		autoAssign := false
 		addressPool := &v1alpha1.AddressPool{
@@ -67,5 +89,228 @@ var _ = Describe("AddressPool Controller", func() {
 
 `))
 		})
+
+		It("Should render the configuration again when its key is removed", func() {
+			By("Creating a AddressPool resource")
+			err := k8sClient.Create(context.Background(), addressPool)
+			Expect(err).ToNot(HaveOccurred())
+
+			key := types.NamespacedName{Name: consts.MetalLBConfigMapName, Namespace: MetalLBTestNameSpace}
+			Eventually(func() error {
+				return k8sClient.Get(context.Background(), key, &corev1.ConfigMap{})
+			}, 2*time.Second, 200*time.Millisecond).Should(Succeed())
+
+			By("Removing the configuration key from the ConfigMap")
+			Eventually(func() error {
+				configmap := &corev1.ConfigMap{}
+				if err := k8sClient.Get(context.Background(), key, configmap); err != nil {
+					return err
+				}
+				configmap.Data = map[string]string{}
+				return k8sClient.Update(context.Background(), configmap)
+			}, 2*time.Second, 200*time.Millisecond).Should(Succeed())
+
+			By("By checking the configuration key is restored")
+			Eventually(func() (string, error) {
+				configmap := &corev1.ConfigMap{}
+				err := k8sClient.Get(context.Background(), key, configmap)
+				if err != nil {
+					return "", err
+				}
+				return configmap.Data[consts.MetalLBConfigMapName], err
+			}, 2*time.Second, 200*time.Millisecond).Should(ContainSubstring("test-addresspool"))
+		})
+
+		It("Should overwrite the configuration edited by hand", func() {
+			By("Creating a AddressPool resource")
+			err := k8sClient.Create(context.Background(), addressPool)
+			Expect(err).ToNot(HaveOccurred())
+
+			key := types.NamespacedName{Name: consts.MetalLBConfigMapName, Namespace: MetalLBTestNameSpace}
+			Eventually(func() error {
+				return k8sClient.Get(context.Background(), key, &corev1.ConfigMap{})
+			}, 2*time.Second, 200*time.Millisecond).Should(Succeed())
+
+			By("Adding a pool to the ConfigMap by hand")
+			Eventually(func() error {
+				configmap := &corev1.ConfigMap{}
+				if err := k8sClient.Get(context.Background(), key, configmap); err != nil {
+					return err
+				}
+				configmap.Data[consts.MetalLBConfigMapName] += `- name: manual-addresspool
+  protocol: layer2
+  addresses:
+  - 2.2.2.2/32
+`
+				return k8sClient.Update(context.Background(), configmap)
+			}, 2*time.Second, 200*time.Millisecond).Should(Succeed())
+
+			By("By checking the pool added by hand is removed")
+			Eventually(func() (string, error) {
+				configmap := &corev1.ConfigMap{}
+				err := k8sClient.Get(context.Background(), key, configmap)
+				if err != nil {
+					return "", err
+				}
+				return configmap.Data[consts.MetalLBConfigMapName], err
+			}, 2*time.Second, 200*time.Millisecond).ShouldNot(ContainSubstring("manual-addresspool"))
+		})
+	})
+
+	Context("Creating more AddressPool objects than allowed", func() {
+		var addressPools []*v1alpha1.AddressPool
+
+		BeforeEach(func() {
+			autoAssign := true
+			addressPools = nil
+			for i := 1; i <= testMaxAddressPools+1; i++ {
+				addressPools = append(addressPools, &v1alpha1.AddressPool{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      fmt.Sprintf("test-addresspool-%d", i),
+						Namespace: MetalLBTestNameSpace,
+					},
+					Spec: v1alpha1.AddressPoolSpec{
+						Protocol:   "layer2",
+						Addresses:  []string{fmt.Sprintf("1.1.%d.1-1.1.%d.100", i, i)},
+						AutoAssign: &autoAssign,
+					},
+				})
+			}
+		})
+
+		AfterEach(func() {
+			for _, addressPool := range addressPools {
+				err := k8sClient.Delete(context.Background(), addressPool)
+				if err != nil && !apierrors.IsNotFound(err) {
+					Fail(err.Error())
+				}
+			}
+			err := cleanTestNamespace()
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		It("Should reject the AddressPools past the limit", func() {
+			By("Creating AddressPools up to the limit")
+			for _, addressPool := range addressPools[:testMaxAddressPools] {
+				Expect(k8sClient.Create(context.Background(), addressPool)).To(Succeed())
+			}
+			Eventually(func() (string, error) {
+				configmap := &corev1.ConfigMap{}
+				err := k8sClient.Get(context.Background(), types.NamespacedName{Name: consts.MetalLBConfigMapName, Namespace: MetalLBTestNameSpace}, configmap)
+				return configmap.Data[consts.MetalLBConfigMapName], err
+			}, 2*time.Second, 200*time.Millisecond).Should(ContainSubstring("test-addresspool-3"))
+
+			By("Creating one more AddressPool")
+			rejected := addressPools[testMaxAddressPools]
+			Expect(k8sClient.Create(context.Background(), rejected)).To(Succeed())
+
+			Eventually(func() string {
+				pool := &v1alpha1.AddressPool{}
+				err := k8sClient.Get(context.Background(), types.NamespacedName{Name: rejected.Name, Namespace: MetalLBTestNameSpace}, pool)
+				if err != nil {
+					return ""
+				}
+				condition := meta.FindStatusCondition(pool.Status.Conditions, status.ConditionDegraded)
+				if condition == nil || condition.Status != metav1.ConditionTrue {
+					return ""
+				}
+				return condition.Reason
+			}, 2*time.Second, 200*time.Millisecond).Should(Equal("TooManyPools"))
+
+			By("Checking the existing AddressPools are still configured")
+			configmap := &corev1.ConfigMap{}
+			err := k8sClient.Get(context.Background(), types.NamespacedName{Name: consts.MetalLBConfigMapName, Namespace: MetalLBTestNameSpace}, configmap)
+			Expect(err).ToNot(HaveOccurred())
+			for _, addressPool := range addressPools[:testMaxAddressPools] {
+				Expect(configmap.Data[consts.MetalLBConfigMapName]).To(ContainSubstring(addressPool.Name))
+			}
+			Expect(configmap.Data[consts.MetalLBConfigMapName]).ToNot(ContainSubstring(rejected.Name))
+		})
+	})
+
+	Context("Lacking the permissions to write the MetalLB ConfigMap", func() {
+		// The reconcilers of the suite ignore the pools of this namespace,
+		// and its MetalLB instance does not manage any workload
+		const namespace = "metallb-restricted-namespace"
+		manageWorkloads := false
+		metallb := &metallbv1beta1.MetalLB{
+			ObjectMeta: metav1.ObjectMeta{Name: defaultMetalLBCrName, Namespace: namespace},
+			Spec:       metallbv1beta1.MetalLBSpec{ManageWorkloads: &manageWorkloads},
+		}
+		autoAssign := true
+		addressPool := &v1alpha1.AddressPool{
+			ObjectMeta: metav1.ObjectMeta{Name: "test-addresspool", Namespace: namespace},
+			Spec: v1alpha1.AddressPoolSpec{
+				Protocol:   "layer2",
+				Addresses:  []string{"1.1.1.1-1.1.1.100"},
+				AutoAssign: &autoAssign,
+			},
+		}
+		// Everything the reconciler needs but writing the ConfigMaps
+		role := &rbacv1.ClusterRole{
+			ObjectMeta: metav1.ObjectMeta{Name: restrictedUser},
+			Rules: []rbacv1.PolicyRule{
+				{APIGroups: []string{"metallb.io", "apps", "events.k8s.io"}, Resources: []string{"*"}, Verbs: []string{"*"}},
+				{APIGroups: []string{""}, Resources: []string{"services", "pods", "secrets", "events", "namespaces", "nodes", "endpoints"}, Verbs: []string{"*"}},
+				{APIGroups: []string{""}, Resources: []string{"configmaps"}, Verbs: []string{"get", "list", "watch"}},
+			},
+		}
+		binding := &rbacv1.ClusterRoleBinding{
+			ObjectMeta: metav1.ObjectMeta{Name: restrictedUser},
+			RoleRef:    rbacv1.RoleRef{APIGroup: rbacv1.GroupName, Kind: "ClusterRole", Name: role.Name},
+			Subjects:   []rbacv1.Subject{{APIGroup: rbacv1.GroupName, Kind: rbacv1.UserKind, Name: restrictedUser}},
+		}
+
+		BeforeEach(func() {
+			err := k8sClient.Create(context.Background(), &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: namespace}})
+			if err != nil && !apierrors.IsAlreadyExists(err) {
+				Fail(err.Error())
+			}
+			for _, obj := range []client.Object{role, binding, metallb, addressPool} {
+				Expect(k8sClient.Create(context.Background(), obj)).To(Succeed())
+			}
+		})
+
+		AfterEach(func() {
+			for _, obj := range []client.Object{addressPool, metallb, binding, role} {
+				err := k8sClient.Delete(context.Background(), obj)
+				if err != nil && !apierrors.IsNotFound(err) {
+					Fail(err.Error())
+				}
+				obj.SetResourceVersion("")
+			}
+		})
+
+		It("Should report the missing permissions on the AddressPool", func() {
+			By("Reconciling the AddressPool as a user not allowed to write the ConfigMaps")
+			restrictedClient, err := client.New(restrictedCfg, client.Options{Scheme: scheme.Scheme})
+			Expect(err).ToNot(HaveOccurred())
+			reconciler := &AddressPoolReconciler{
+				Client:    restrictedClient,
+				Scheme:    scheme.Scheme,
+				Log:       ctrl.Log.WithName("controllers").WithName("AddressPool"),
+				Namespace: namespace,
+			}
+			key := types.NamespacedName{Name: addressPool.Name, Namespace: namespace}
+			// The RBAC authorizer may not have seen the binding yet
+			Eventually(func() error {
+				_, err := reconciler.Reconcile(context.Background(), ctrl.Request{NamespacedName: key})
+				return err
+			}, 5*time.Second, 200*time.Millisecond).Should(MatchError(ContainSubstring(`cannot create resource "configmaps"`)))
+
+			By("Checking the AddressPool is degraded")
+			pool := &v1alpha1.AddressPool{}
+			Expect(k8sClient.Get(context.Background(), key, pool)).To(Succeed())
+			degraded := meta.FindStatusCondition(pool.Status.Conditions, status.ConditionDegraded)
+			Expect(degraded).ToNot(BeNil())
+			Expect(degraded.Status).To(Equal(metav1.ConditionTrue))
+			Expect(degraded.Reason).To(Equal("InsufficientPermissions"))
+			Expect(degraded.Message).To(ContainSubstring(`cannot create resource "configmaps"`))
+			Expect(meta.IsStatusConditionFalse(pool.Status.Conditions, status.ConditionAvailable)).To(BeTrue())
+
+			By("Checking no ConfigMap was written")
+			err = k8sClient.Get(context.Background(), types.NamespacedName{Name: consts.MetalLBConfigMapName, Namespace: namespace}, &corev1.ConfigMap{})
+			Expect(apierrors.IsNotFound(err)).To(BeTrue())
+		})
 	})
 })
@@ -0,0 +1,173 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+
+	metallbv1beta1 "github.com/metallb/metallb-operator/api/v1beta1"
+	"github.com/metallb/metallb-operator/pkg/apply"
+	"github.com/metallb/metallb-operator/pkg/status"
+)
+
+// applyConfigMap applies the rendered MetalLB ConfigMap, overwriting the
+// current one, and emits a ConfigMapCreated or ConfigMapUpdated event on the
+// MetalLB resource when it is created or changed, as tracked by its generation
+// label. When
+// ConfigAppliedEvents is set, a ConfigApplied event is emitted as well if the
+// configuration changed.
+func (r *AddressPoolReconciler) applyConfigMap(ctx context.Context, obj *unstructured.Unstructured) error {
+	current := &corev1.ConfigMap{}
+	err := r.Get(ctx, types.NamespacedName{Name: obj.GetName(), Namespace: obj.GetNamespace()}, current)
+	if err != nil && !errors.IsNotFound(err) {
+		return err
+	}
+	created := errors.IsNotFound(err)
+	// A missing ConfigMap has no label either
+	generation, found := current.Labels[apply.ConfigGenerationLabel]
+
+	config, _, err := unstructured.NestedString(obj.Object, "data", apply.AddressPoolConfigMap)
+	if err != nil {
+		return err
+	}
+	names, err := apply.ConfigPoolNames(config)
+	if err != nil {
+		return err
+	}
+
+	// The rendered configuration is the whole of it, the pools added by hand
+	// are dropped
+	if err := apply.ReplaceObject(ctx, r.Client, obj); err != nil {
+		return fmt.Errorf("could not apply (%s) %s/%s err %w", obj.GroupVersionKind(),
+			obj.GetNamespace(), obj.GetName(), err)
+	}
+	changed := false
+	switch {
+	case created:
+		changed = true
+		r.recordConfigMapEvent(ctx, "ConfigMapCreated", fmt.Sprintf("Created ConfigMap %s with %s", obj.GetName(), poolNames(names)))
+	case !found || obj.GetLabels()[apply.ConfigGenerationLabel] != generation:
+		changed = true
+		r.recordConfigMapEvent(ctx, "ConfigMapUpdated", fmt.Sprintf("Updated ConfigMap %s with %s", obj.GetName(), poolNames(names)))
+	}
+	configMapPools.Set(float64(len(names)))
+
+	if changed && r.ConfigAppliedEvents && r.Recorder != nil {
+		metallb := &metallbv1beta1.MetalLB{}
+		err := r.Get(ctx, types.NamespacedName{Name: defaultMetalLBCrName, Namespace: r.Namespace}, metallb)
+		if errors.IsNotFound(err) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("Failed to get MetalLB resource %w", err)
+		}
+		r.Recorder.Event(metallb, corev1.EventTypeNormal, "ConfigApplied",
+			fmt.Sprintf("Applied the MetalLB configuration with %d address pools", len(names)))
+	}
+	return nil
+}
+
+// deleteConfigMap deletes the given MetalLB ConfigMap, emitting a
+// ConfigMapDeleted event on the MetalLB resource with the pools it held.
+// It returns whether the ConfigMap existed.
+func (r *AddressPoolReconciler) deleteConfigMap(ctx context.Context, name string) (bool, error) {
+	namespace, err := r.configNamespace()
+	if err != nil {
+		return false, err
+	}
+	configMap := &corev1.ConfigMap{}
+	err = r.Get(ctx, types.NamespacedName{Name: name, Namespace: namespace}, configMap)
+	if errors.IsNotFound(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if err := r.Delete(ctx, configMap); err != nil {
+		if errors.IsNotFound(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	// The pools are counted again when the remaining ones are applied
+	configMapPools.Set(0)
+
+	// An unparsable configuration is reported as holding no pool
+	names, _ := apply.ConfigPoolNames(configMap.Data[apply.AddressPoolConfigMap])
+	r.recordConfigMapEvent(ctx, "ConfigMapDeleted", fmt.Sprintf("Deleted ConfigMap %s with %s", name, poolNames(names)))
+	return true, nil
+}
+
+// recordConfigMapEvent emits a normal event on the MetalLB resource, if there
+// is one.
+func (r *AddressPoolReconciler) recordConfigMapEvent(ctx context.Context, reason, message string) {
+	if r.Recorder == nil {
+		return
+	}
+	metallb := &metallbv1beta1.MetalLB{}
+	err := r.Get(ctx, types.NamespacedName{Name: defaultMetalLBCrName, Namespace: r.Namespace}, metallb)
+	if err != nil {
+		if !errors.IsNotFound(err) {
+			r.Log.Info(fmt.Sprintf("Failed to get MetalLB resource %s", err))
+		}
+		return
+	}
+	r.Recorder.Event(metallb, corev1.EventTypeNormal, reason, message)
+}
+
+func poolNames(names []string) string {
+	if len(names) == 0 {
+		return "no address pools"
+	}
+	return "address pools " + strings.Join(names, ", ")
+}
+
+// trackConfigWrite counts the consecutive failed writes of the MetalLB
+// ConfigMaps, and reports them in the ConfigWriteUnstable condition of the
+// MetalLB resource once they reach ConfigWriteFailureThreshold. A successful
+// write clears the condition.
+func (r *AddressPoolReconciler) trackConfigWrite(ctx context.Context, writeErr error) {
+	if r.ConfigWriteFailureThreshold <= 0 {
+		return
+	}
+	if writeErr == nil {
+		r.configWriteFailures = 0
+	} else {
+		r.configWriteFailures++
+	}
+
+	metallb := &metallbv1beta1.MetalLB{}
+	err := r.Get(ctx, types.NamespacedName{Name: defaultMetalLBCrName, Namespace: r.Namespace}, metallb)
+	if errors.IsNotFound(err) {
+		return
+	}
+	if err != nil {
+		r.Log.Info(fmt.Sprintf("Failed to get MetalLB resource %s", err))
+		return
+	}
+	unstable := r.configWriteFailures >= r.ConfigWriteFailureThreshold
+	if err := status.UpdateConfigWriteUnstable(ctx, r.Client, metallb, unstable, r.configWriteFailures, writeErr); err != nil {
+		r.Log.Info(fmt.Sprintf("Failed to update metallb status %s", err))
+	}
+}
@@ -0,0 +1,304 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	metallbv1alpha1 "github.com/metallb/metallb-operator/api/v1alpha1"
+	metallbv1beta1 "github.com/metallb/metallb-operator/api/v1beta1"
+	"github.com/metallb/metallb-operator/pkg/apply"
+	"github.com/metallb/metallb-operator/pkg/status"
+)
+
+func TestAddressPoolConfigAppliedEvent(t *testing.T) {
+	g := NewGomegaWithT(t)
+	manifestPath := AddressPoolManifestPath
+	AddressPoolManifestPath = "../bindata/configuration/address-pool"
+	defer func() { AddressPoolManifestPath = manifestPath }()
+
+	pool := &metallbv1alpha1.AddressPool{
+		ObjectMeta: metav1.ObjectMeta{Name: "gold", Namespace: MetalLBTestNameSpace},
+		Spec:       metallbv1alpha1.AddressPoolSpec{Protocol: "layer2", Addresses: []string{"10.0.0.0/24"}},
+	}
+	metallb := &metallbv1beta1.MetalLB{ObjectMeta: metav1.ObjectMeta{Name: defaultMetalLBCrName, Namespace: MetalLBTestNameSpace}}
+	c := newFakeClientBuilder().WithScheme(testScheme(g)).WithObjects(pool, metallb).Build()
+
+	recorder := record.NewFakeRecorder(10)
+	reconciler := &AddressPoolReconciler{
+		Client:              c,
+		Log:                 ctrl.Log.WithName("controllers").WithName("AddressPool"),
+		Namespace:           MetalLBTestNameSpace,
+		Recorder:            recorder,
+		ConfigAppliedEvents: true,
+	}
+	reconcile := func(obj client.Object) {
+		key := types.NamespacedName{Name: obj.GetName(), Namespace: obj.GetNamespace()}
+		_, err := reconciler.Reconcile(context.Background(), ctrl.Request{NamespacedName: key})
+		g.Expect(err).ToNot(HaveOccurred())
+	}
+
+	reconcile(pool)
+	g.Expect(recorder.Events).To(Receive(Equal("Normal ConfigMapCreated Created ConfigMap config with address pools gold")))
+	g.Expect(recorder.Events).To(Receive(Equal("Normal ConfigApplied Applied the MetalLB configuration with 1 address pools")))
+
+	// Nothing changed
+	reconcile(pool)
+	g.Expect(recorder.Events).ToNot(Receive())
+
+	silver := &metallbv1alpha1.AddressPool{
+		ObjectMeta: metav1.ObjectMeta{Name: "silver", Namespace: MetalLBTestNameSpace},
+		Spec:       metallbv1alpha1.AddressPoolSpec{Protocol: "layer2", Addresses: []string{"10.0.1.0/24"}},
+	}
+	g.Expect(c.Create(context.Background(), silver)).To(Succeed())
+	reconcile(silver)
+	g.Expect(recorder.Events).To(Receive(Equal("Normal ConfigMapUpdated Updated ConfigMap config with address pools gold, silver")))
+	g.Expect(recorder.Events).To(Receive(Equal("Normal ConfigApplied Applied the MetalLB configuration with 2 address pools")))
+
+	g.Expect(c.Delete(context.Background(), silver)).To(Succeed())
+	reconcile(silver)
+	// The ConfigMap is rendered again from scratch on the deletion of a pool
+	g.Expect(recorder.Events).To(Receive(Equal("Normal ConfigMapDeleted Deleted ConfigMap config with address pools gold, silver")))
+	g.Expect(recorder.Events).To(Receive(Equal("Normal ConfigMapCreated Created ConfigMap config with address pools gold")))
+	g.Expect(recorder.Events).To(Receive(Equal("Normal ConfigApplied Applied the MetalLB configuration with 1 address pools")))
+
+	// Disabled, only the ConfigMap events are emitted
+	reconciler.ConfigAppliedEvents = false
+	silver.ResourceVersion = ""
+	g.Expect(c.Create(context.Background(), silver)).To(Succeed())
+	reconcile(silver)
+	g.Expect(recorder.Events).To(Receive(Equal("Normal ConfigMapUpdated Updated ConfigMap config with address pools gold, silver")))
+	g.Expect(recorder.Events).ToNot(Receive())
+}
+
+func TestAddressPoolConfigMapEvents(t *testing.T) {
+	g := NewGomegaWithT(t)
+	manifestPath := AddressPoolManifestPath
+	AddressPoolManifestPath = "../bindata/configuration/address-pool"
+	defer func() { AddressPoolManifestPath = manifestPath }()
+
+	v4 := &metallbv1alpha1.AddressPool{
+		ObjectMeta: metav1.ObjectMeta{Name: "b-v4", Namespace: MetalLBTestNameSpace},
+		Spec:       metallbv1alpha1.AddressPoolSpec{Protocol: "layer2", Addresses: []string{"10.0.0.0/24"}},
+	}
+	v6 := &metallbv1alpha1.AddressPool{
+		ObjectMeta: metav1.ObjectMeta{Name: "a-v6", Namespace: MetalLBTestNameSpace},
+		Spec:       metallbv1alpha1.AddressPoolSpec{Protocol: "layer2", Addresses: []string{"2001:db8::/120"}},
+	}
+	metallb := &metallbv1beta1.MetalLB{
+		ObjectMeta: metav1.ObjectMeta{Name: defaultMetalLBCrName, Namespace: MetalLBTestNameSpace},
+		Spec:       metallbv1beta1.MetalLBSpec{PoolSortOrder: metallbv1beta1.PoolSortByAddress},
+	}
+	c := newFakeClientBuilder().WithScheme(testScheme(g)).WithObjects(v4, v6, metallb).Build()
+
+	recorder := record.NewFakeRecorder(10)
+	reconciler := &AddressPoolReconciler{
+		Client:    c,
+		Log:       ctrl.Log.WithName("controllers").WithName("AddressPool"),
+		Namespace: MetalLBTestNameSpace,
+		Recorder:  recorder,
+	}
+	reconcilePool := func(obj client.Object) {
+		key := types.NamespacedName{Name: obj.GetName(), Namespace: obj.GetNamespace()}
+		_, err := reconciler.Reconcile(context.Background(), ctrl.Request{NamespacedName: key})
+		g.Expect(err).ToNot(HaveOccurred())
+	}
+
+	// Sorted by address, the IPv4 pool is listed first
+	reconcilePool(v4)
+	g.Expect(recorder.Events).To(Receive(Equal("Normal ConfigMapCreated Created ConfigMap config with address pools b-v4, a-v6")))
+	g.Expect(recorder.Events).ToNot(Receive())
+
+	// Back to the name order
+	g.Expect(c.Get(context.Background(), types.NamespacedName{Name: defaultMetalLBCrName, Namespace: MetalLBTestNameSpace}, metallb)).To(Succeed())
+	metallb.Spec.PoolSortOrder = ""
+	g.Expect(c.Update(context.Background(), metallb)).To(Succeed())
+	reconcilePool(v4)
+	g.Expect(recorder.Events).To(Receive(Equal("Normal ConfigMapUpdated Updated ConfigMap config with address pools a-v6, b-v4")))
+	g.Expect(recorder.Events).ToNot(Receive())
+
+	// Without a MetalLB resource there is nothing to emit the events on
+	g.Expect(c.Delete(context.Background(), metallb)).To(Succeed())
+	g.Expect(c.Delete(context.Background(), v6)).To(Succeed())
+	reconcilePool(v6)
+	g.Expect(recorder.Events).ToNot(Receive())
+}
+
+// failingConfigMapWritesClient fails the creations and updates of the
+// ConfigMaps while failing is set, as an unstable apiserver would.
+type failingConfigMapWritesClient struct {
+	client.Client
+	failing bool
+}
+
+func (c *failingConfigMapWritesClient) isFailingConfigMap(obj client.Object) bool {
+	return c.failing && obj.GetObjectKind().GroupVersionKind().Kind == "ConfigMap"
+}
+
+func (c *failingConfigMapWritesClient) Create(ctx context.Context, obj client.Object, opts ...client.CreateOption) error {
+	if c.isFailingConfigMap(obj) {
+		return errors.New("etcdserver: request timed out")
+	}
+	return c.Client.Create(ctx, obj, opts...)
+}
+
+func (c *failingConfigMapWritesClient) Update(ctx context.Context, obj client.Object, opts ...client.UpdateOption) error {
+	if c.isFailingConfigMap(obj) {
+		return errors.New("etcdserver: request timed out")
+	}
+	return c.Client.Update(ctx, obj, opts...)
+}
+
+func TestAddressPoolConfigWriteUnstable(t *testing.T) {
+	g := NewGomegaWithT(t)
+	manifestPath := AddressPoolManifestPath
+	AddressPoolManifestPath = "../bindata/configuration/address-pool"
+	defer func() { AddressPoolManifestPath = manifestPath }()
+
+	pool := &metallbv1alpha1.AddressPool{
+		ObjectMeta: metav1.ObjectMeta{Name: "gold", Namespace: MetalLBTestNameSpace},
+		Spec:       metallbv1alpha1.AddressPoolSpec{Protocol: "layer2", Addresses: []string{"10.0.0.0/24"}},
+	}
+	metallb := &metallbv1beta1.MetalLB{ObjectMeta: metav1.ObjectMeta{Name: defaultMetalLBCrName, Namespace: MetalLBTestNameSpace}}
+	c := &failingConfigMapWritesClient{Client: newFakeClientBuilder().WithScheme(testScheme(g)).WithObjects(pool, metallb).Build()}
+
+	reconciler := &AddressPoolReconciler{
+		Client:                      c,
+		Log:                         ctrl.Log.WithName("controllers").WithName("AddressPool"),
+		Namespace:                   MetalLBTestNameSpace,
+		ConfigWriteFailureThreshold: 2,
+	}
+	key := types.NamespacedName{Name: pool.Name, Namespace: pool.Namespace}
+	octet := 0
+	// reconcile changes the pool before reconciling it, so the MetalLB
+	// ConfigMap is written each time.
+	reconcile := func(failing bool) *metav1.Condition {
+		octet++
+		current := &metallbv1alpha1.AddressPool{}
+		g.Expect(c.Get(context.Background(), key, current)).To(Succeed())
+		current.Spec.Addresses = []string{fmt.Sprintf("10.0.%d.0/24", octet)}
+		g.Expect(c.Update(context.Background(), current)).To(Succeed())
+
+		c.failing = failing
+		_, err := reconciler.Reconcile(context.Background(), ctrl.Request{NamespacedName: key})
+		if failing {
+			g.Expect(err).To(HaveOccurred())
+		} else {
+			g.Expect(err).ToNot(HaveOccurred())
+		}
+
+		updated := &metallbv1beta1.MetalLB{}
+		g.Expect(c.Get(context.Background(), types.NamespacedName{Name: defaultMetalLBCrName, Namespace: MetalLBTestNameSpace}, updated)).To(Succeed())
+		return meta.FindStatusCondition(updated.Status.Conditions, status.ConditionConfigWriteUnstable)
+	}
+
+	condition := reconcile(false)
+	g.Expect(condition.Status).To(Equal(metav1.ConditionFalse))
+
+	// A single failure is below the threshold
+	condition = reconcile(true)
+	g.Expect(condition.Status).To(Equal(metav1.ConditionFalse))
+	condition = reconcile(true)
+	g.Expect(condition.Status).To(Equal(metav1.ConditionTrue))
+	g.Expect(condition.Reason).To(Equal("ConfigWritesFailing"))
+	g.Expect(condition.Message).To(ContainSubstring("2 consecutive writes"))
+	g.Expect(condition.Message).To(ContainSubstring("request timed out"))
+
+	// A successful write clears the condition and resets the count
+	condition = reconcile(false)
+	g.Expect(condition.Status).To(Equal(metav1.ConditionFalse))
+	condition = reconcile(true)
+	g.Expect(condition.Status).To(Equal(metav1.ConditionFalse))
+	condition = reconcile(true)
+	g.Expect(condition.Status).To(Equal(metav1.ConditionTrue))
+}
+
+func TestAddressPoolOverwritesEditedConfig(t *testing.T) {
+	g := NewGomegaWithT(t)
+	manifestPath := AddressPoolManifestPath
+	AddressPoolManifestPath = "../bindata/configuration/address-pool"
+	defer func() { AddressPoolManifestPath = manifestPath }()
+
+	pool := &metallbv1alpha1.AddressPool{
+		ObjectMeta: metav1.ObjectMeta{Name: "gold", Namespace: MetalLBTestNameSpace},
+		Spec:       metallbv1alpha1.AddressPoolSpec{Protocol: "layer2", Addresses: []string{"10.0.0.0/24"}},
+	}
+	metallb := &metallbv1beta1.MetalLB{ObjectMeta: metav1.ObjectMeta{Name: defaultMetalLBCrName, Namespace: MetalLBTestNameSpace}}
+	c := newFakeClientBuilder().WithScheme(testScheme(g)).WithObjects(pool, metallb).Build()
+	recorder := record.NewFakeRecorder(10)
+	reconciler := &AddressPoolReconciler{
+		Client:    c,
+		Log:       ctrl.Log.WithName("controllers").WithName("AddressPool"),
+		Namespace: MetalLBTestNameSpace,
+		Recorder:  recorder,
+	}
+	reconcile := func() {
+		key := types.NamespacedName{Name: pool.Name, Namespace: pool.Namespace}
+		_, err := reconciler.Reconcile(context.Background(), ctrl.Request{NamespacedName: key})
+		g.Expect(err).ToNot(HaveOccurred())
+	}
+	key := types.NamespacedName{Name: apply.AddressPoolConfigMap, Namespace: MetalLBTestNameSpace}
+	configMap := func() *corev1.ConfigMap {
+		configMap := &corev1.ConfigMap{}
+		g.Expect(c.Get(context.Background(), key, configMap)).To(Succeed())
+		return configMap
+	}
+
+	reconcile()
+	g.Expect(recorder.Events).To(Receive(Equal("Normal ConfigMapCreated Created ConfigMap config with address pools gold")))
+	rendered := configMap().Data[apply.AddressPoolConfigMap]
+
+	// A pool added and a pool edited by hand are overwritten
+	edited := configMap()
+	edited.Data[apply.AddressPoolConfigMap] = `address-pools:
+- name: gold
+  protocol: layer2
+  addresses:
+  - 10.0.0.0/16
+- name: manual
+  protocol: layer2
+  addresses:
+  - 10.0.1.0/24
+`
+	g.Expect(c.Update(context.Background(), edited)).To(Succeed())
+	reconcile()
+	g.Expect(recorder.Events).To(Receive(Equal("Normal ConfigMapUpdated Updated ConfigMap config with address pools gold")))
+	g.Expect(configMap().Data[apply.AddressPoolConfigMap]).To(Equal(rendered))
+
+	// The ConfigMap written by the operator is left alone
+	resourceVersion := configMap().ResourceVersion
+	reconcile()
+	g.Expect(recorder.Events).ToNot(Receive())
+	g.Expect(configMap().ResourceVersion).To(Equal(resourceVersion))
+}
+
+// poolRecorder records the events of the AddressPools only, leaving out the
+// ConfigMap ones emitted on the MetalLB resource.
+type poolRecorder struct {
+	*record.FakeRecorder
+}
+
+func (r poolRecorder) Event(object runtime.Object, eventtype, reason, message string) {
+	if _, ok := object.(*metallbv1alpha1.AddressPool); ok {
+		r.FakeRecorder.Event(object, eventtype, reason, message)
+	}
+}
+
+func (r poolRecorder) Eventf(object runtime.Object, eventtype, reason, messageFmt string, args ...interface{}) {
+	r.Event(object, eventtype, reason, fmt.Sprintf(messageFmt, args...))
+}
+
+func (r poolRecorder) AnnotatedEventf(object runtime.Object, annotations map[string]string, eventtype, reason, messageFmt string, args ...interface{}) {
+	r.Eventf(object, eventtype, reason, messageFmt, args...)
+}
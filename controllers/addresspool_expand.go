@@ -0,0 +1,68 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+
+	metallbv1alpha1 "github.com/metallb/metallb-operator/api/v1alpha1"
+	"github.com/metallb/metallb-operator/pkg/addresses"
+)
+
+// expandPool appends to the exhausted pool the first CIDR of its AutoExpand
+// supernet not overlapping with any pool nor reserved range. The update of
+// the pool renders it again into the MetalLB configuration.
+func (r *AddressPoolReconciler) expandPool(ctx context.Context, pool *metallbv1alpha1.AddressPool) error {
+	pools, err := r.listAddressPools()
+	if err != nil {
+		return fmt.Errorf("Failed to get existing addresspool objects %w", err)
+	}
+	reserved, err := r.reservedRanges(ctx)
+	if err != nil {
+		return err
+	}
+	used := []string{}
+	for _, p := range pools {
+		used = append(used, p.Spec.Addresses...)
+	}
+	for _, rr := range reserved {
+		used = append(used, rr.addresses)
+	}
+
+	autoExpand := pool.Spec.AutoExpand
+	cidr, err := addresses.NextCIDR(autoExpand.Supernet, autoExpand.Increment, used)
+	if err != nil {
+		r.Log.Info(fmt.Sprintf("Failed to expand AddressPool %s/%s %s", pool.Namespace, pool.Name, err))
+		if r.Recorder != nil {
+			r.Recorder.Event(pool, corev1.EventTypeWarning, "PoolExpansionFailed", err.Error())
+		}
+		return nil
+	}
+
+	pool.Spec.Addresses = append(pool.Spec.Addresses, cidr)
+	if err := r.Update(ctx, pool); err != nil {
+		return err
+	}
+	r.Log.Info(fmt.Sprintf("Expanded AddressPool %s/%s with %s", pool.Namespace, pool.Name, cidr))
+	if r.Recorder != nil {
+		r.Recorder.Event(pool, corev1.EventTypeNormal, "PoolExpanded", fmt.Sprintf("Added %s to the pool", cidr))
+	}
+	return nil
+}
@@ -0,0 +1,135 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	metallbv1alpha1 "github.com/metallb/metallb-operator/api/v1alpha1"
+	metallbv1beta1 "github.com/metallb/metallb-operator/api/v1beta1"
+	"github.com/metallb/metallb-operator/pkg/addresses"
+	"github.com/metallb/metallb-operator/pkg/status"
+)
+
+func TestAddressPoolAutoExpand(t *testing.T) {
+	g := NewGomegaWithT(t)
+	manifestPath := AddressPoolManifestPath
+	AddressPoolManifestPath = "../bindata/configuration/address-pool"
+	defer func() { AddressPoolManifestPath = manifestPath }()
+
+	loadBalancer := func(name, ip string) *corev1.Service {
+		return &corev1.Service{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+			Spec:       corev1.ServiceSpec{Type: corev1.ServiceTypeLoadBalancer},
+			Status: corev1.ServiceStatus{LoadBalancer: corev1.LoadBalancerStatus{
+				Ingress: []corev1.LoadBalancerIngress{{IP: ip}},
+			}},
+		}
+	}
+	objs := []client.Object{
+		&metallbv1alpha1.AddressPool{
+			ObjectMeta: metav1.ObjectMeta{Name: "gold", Namespace: MetalLBTestNameSpace},
+			Spec: metallbv1alpha1.AddressPoolSpec{
+				Protocol:   "layer2",
+				Addresses:  []string{"10.0.0.0/31"},
+				AutoExpand: &metallbv1alpha1.AutoExpandSpec{Supernet: "10.0.0.0/24", Increment: 31},
+			},
+		},
+		// The next block of the supernet is taken by another pool
+		&metallbv1alpha1.AddressPool{
+			ObjectMeta: metav1.ObjectMeta{Name: "silver", Namespace: MetalLBTestNameSpace},
+			Spec:       metallbv1alpha1.AddressPoolSpec{Protocol: "layer2", Addresses: []string{"10.0.0.2-10.0.0.3"}},
+		},
+		loadBalancer("web", "10.0.0.0"),
+	}
+	recorder := record.NewFakeRecorder(10)
+	reconciler := &AddressPoolReconciler{
+		Client:    newFakeClientBuilder().WithScheme(testScheme(g)).WithObjects(testMetalLB(metallbv1beta1.MetalLBSpec{})).WithObjects(objs...).Build(),
+		Log:       ctrl.Log.WithName("controllers").WithName("AddressPool"),
+		Namespace: MetalLBTestNameSpace,
+		Recorder:  poolRecorder{recorder},
+	}
+	key := types.NamespacedName{Name: "gold", Namespace: MetalLBTestNameSpace}
+	reconcile := func() *metallbv1alpha1.AddressPool {
+		_, err := reconciler.Reconcile(context.Background(), ctrl.Request{NamespacedName: key})
+		g.Expect(err).ToNot(HaveOccurred())
+		pool := &metallbv1alpha1.AddressPool{}
+		g.Expect(reconciler.Get(context.Background(), key, pool)).To(Succeed())
+		return pool
+	}
+	config := func() string {
+		configMap := &corev1.ConfigMap{}
+		g.Expect(reconciler.Get(context.Background(), types.NamespacedName{Name: "config", Namespace: MetalLBTestNameSpace}, configMap)).To(Succeed())
+		return configMap.Data["config"]
+	}
+
+	// Addresses are left, the pool is not expanded
+	gold := reconcile()
+	g.Expect(gold.Spec.Addresses).To(Equal([]string{"10.0.0.0/31"}))
+	g.Expect(meta.IsStatusConditionTrue(gold.Status.Conditions, status.ConditionExhausted)).To(BeFalse())
+
+	// The pool is exhausted, it grows past the silver pool
+	g.Expect(reconciler.Create(context.Background(), loadBalancer("db", "10.0.0.1"))).To(Succeed())
+	gold = reconcile()
+	g.Expect(gold.Spec.Addresses).To(Equal([]string{"10.0.0.0/31", "10.0.0.4/31"}))
+	overlap, err := addresses.Overlap("10.0.0.4/31", "10.0.0.2-10.0.0.3")
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(overlap).To(BeFalse())
+	g.Expect(recorder.Events).To(Receive(ContainSubstring("PoolExhausted")))
+	g.Expect(recorder.Events).To(Receive(Equal("Normal PoolExpanded Added 10.0.0.4/31 to the pool")))
+
+	// The update of the pool renders it again, with addresses available
+	gold = reconcile()
+	g.Expect(config()).To(ContainSubstring("10.0.0.4/31"))
+	g.Expect(meta.IsStatusConditionTrue(gold.Status.Conditions, status.ConditionExhausted)).To(BeFalse())
+	g.Expect(gold.Spec.Addresses).To(HaveLen(2))
+}
+
+func TestAddressPoolAutoExpandSupernetFull(t *testing.T) {
+	g := NewGomegaWithT(t)
+	manifestPath := AddressPoolManifestPath
+	AddressPoolManifestPath = "../bindata/configuration/address-pool"
+	defer func() { AddressPoolManifestPath = manifestPath }()
+
+	objs := []client.Object{
+		&metallbv1alpha1.AddressPool{
+			ObjectMeta: metav1.ObjectMeta{Name: "gold", Namespace: MetalLBTestNameSpace},
+			Spec: metallbv1alpha1.AddressPoolSpec{
+				Protocol:   "layer2",
+				Addresses:  []string{"10.0.0.0/32"},
+				AutoExpand: &metallbv1alpha1.AutoExpandSpec{Supernet: "10.0.0.0/32", Increment: 32},
+			},
+		},
+		&corev1.Service{
+			ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default"},
+			Spec:       corev1.ServiceSpec{Type: corev1.ServiceTypeLoadBalancer},
+			Status: corev1.ServiceStatus{LoadBalancer: corev1.LoadBalancerStatus{
+				Ingress: []corev1.LoadBalancerIngress{{IP: "10.0.0.0"}},
+			}},
+		},
+	}
+	recorder := record.NewFakeRecorder(10)
+	reconciler := &AddressPoolReconciler{
+		Client:    newFakeClientBuilder().WithScheme(testScheme(g)).WithObjects(testMetalLB(metallbv1beta1.MetalLBSpec{})).WithObjects(objs...).Build(),
+		Log:       ctrl.Log.WithName("controllers").WithName("AddressPool"),
+		Namespace: MetalLBTestNameSpace,
+		Recorder:  poolRecorder{recorder},
+	}
+	key := types.NamespacedName{Name: "gold", Namespace: MetalLBTestNameSpace}
+	_, err := reconciler.Reconcile(context.Background(), ctrl.Request{NamespacedName: key})
+	g.Expect(err).ToNot(HaveOccurred())
+
+	gold := &metallbv1alpha1.AddressPool{}
+	g.Expect(reconciler.Get(context.Background(), key, gold)).To(Succeed())
+	g.Expect(gold.Spec.Addresses).To(Equal([]string{"10.0.0.0/32"}))
+	g.Expect(recorder.Events).To(Receive(ContainSubstring("PoolExhausted")))
+	g.Expect(recorder.Events).To(Receive(ContainSubstring("PoolExpansionFailed")))
+}
@@ -0,0 +1,85 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+
+	metallbv1alpha1 "github.com/metallb/metallb-operator/api/v1alpha1"
+	metallbv1beta1 "github.com/metallb/metallb-operator/api/v1beta1"
+	"github.com/metallb/metallb-operator/pkg/addresses"
+	"github.com/metallb/metallb-operator/pkg/status"
+)
+
+const (
+	// FamilyIPv4 is the family of the pools with IPv4 ranges only.
+	FamilyIPv4 = "IPv4"
+	// FamilyIPv6 is the family of the pools with IPv6 ranges only.
+	FamilyIPv6 = "IPv6"
+	// FamilyDualStack is the family of the pools with ranges of both
+	// families, MetalLB assigns the dual-stack services an address of each.
+	FamilyDualStack = "DualStack"
+)
+
+// updateIPFamilies sets the IPFamiliesSupported condition of the pool, from
+// its ranges and the BGP backend of the MetalLB resource.
+func (r *AddressPoolReconciler) updateIPFamilies(ctx context.Context, pool *metallbv1alpha1.AddressPool) error {
+	metallb := &metallbv1beta1.MetalLB{}
+	err := r.Get(ctx, types.NamespacedName{Name: defaultMetalLBCrName, Namespace: r.Namespace}, metallb)
+	if errors.IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("Failed to get MetalLB resource %w", err)
+	}
+	family, familyErr := checkIPFamilies(pool.Spec, metallb.Spec.BGPBackend)
+	return status.UpdateAddressPoolIPFamilies(ctx, r.Client, pool, family, familyErr)
+}
+
+// checkIPFamilies returns the family of the pool, and an error when MetalLB
+// does not announce the addresses of one of its families. MetalLB announces
+// both families in layer2 mode, but the BGP implementation built in the
+// speaker only advertises IPv4 routes: the IPv6 addresses of a bgp pool are
+// only announced with the frr BGP backend. The pool is rendered anyway, so
+// its IPv4 addresses are still announced.
+func checkIPFamilies(spec metallbv1alpha1.AddressPoolSpec, bgpBackend string) (string, error) {
+	ipv4, ipv6 := addresses.Families(spec.Addresses)
+	family := FamilyIPv4
+	switch {
+	case ipv4 && ipv6:
+		family = FamilyDualStack
+	case ipv6:
+		family = FamilyIPv6
+	}
+	if ipv6 && spec.Protocol == metallbv1alpha1.ProtocolBGP && bgpBackend != metallbv1beta1.BGPBackendFRR {
+		return family, fmt.Errorf("the IPv6 addresses of a bgp pool are only announced with the %s BGP backend, the MetalLB resource uses the %s one",
+			metallbv1beta1.BGPBackendFRR, backendName(bgpBackend))
+	}
+	return family, nil
+}
+
+// backendName returns the BGP backend, the native one when unset.
+func backendName(bgpBackend string) string {
+	if bgpBackend == "" {
+		return metallbv1beta1.BGPBackendNative
+	}
+	return bgpBackend
+}
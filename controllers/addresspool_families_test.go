@@ -0,0 +1,132 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	metallbv1alpha1 "github.com/metallb/metallb-operator/api/v1alpha1"
+	metallbv1beta1 "github.com/metallb/metallb-operator/api/v1beta1"
+	"github.com/metallb/metallb-operator/pkg/apply"
+	"github.com/metallb/metallb-operator/pkg/status"
+	"github.com/metallb/metallb-operator/test/manifests"
+)
+
+func TestCheckIPFamilies(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	tests := []struct {
+		desc       string
+		protocol   string
+		addresses  []string
+		bgpBackend string
+		family     string
+		supported  bool
+	}{
+		{desc: "ipv4 layer2", protocol: "layer2", addresses: []string{"10.0.0.0/24"}, family: FamilyIPv4, supported: true},
+		{desc: "ipv6 layer2", protocol: "layer2", addresses: []string{"fc00::/64"}, family: FamilyIPv6, supported: true},
+		{desc: "dual-stack layer2", protocol: "layer2", addresses: []string{"10.0.0.0/24", "fc00::1-fc00::ff"}, family: FamilyDualStack, supported: true},
+		{desc: "ipv4 bgp", protocol: "bgp", addresses: []string{"10.0.0.0/24"}, family: FamilyIPv4, supported: true},
+		{desc: "ipv6 bgp", protocol: "bgp", addresses: []string{"fc00::/64"}, family: FamilyIPv6},
+		{desc: "dual-stack bgp", protocol: "bgp", addresses: []string{"10.0.0.0/24", "fc00::/64"}, bgpBackend: "native", family: FamilyDualStack},
+		{desc: "ipv6 bgp with frr", protocol: "bgp", addresses: []string{"fc00::/64"}, bgpBackend: "frr", family: FamilyIPv6, supported: true},
+		{desc: "dual-stack bgp with frr", protocol: "bgp", addresses: []string{"10.0.0.0/24", "fc00::/64"}, bgpBackend: "frr", family: FamilyDualStack, supported: true},
+	}
+	for _, test := range tests {
+		family, err := checkIPFamilies(metallbv1alpha1.AddressPoolSpec{Protocol: test.protocol, Addresses: test.addresses}, test.bgpBackend)
+		g.Expect(family).To(Equal(test.family), test.desc)
+		if test.supported {
+			g.Expect(err).ToNot(HaveOccurred(), test.desc)
+			continue
+		}
+		g.Expect(err).To(MatchError(ContainSubstring("only announced with the frr BGP backend")), test.desc)
+	}
+}
+
+func TestAddressPoolIPFamilies(t *testing.T) {
+	g := NewGomegaWithT(t)
+	manifestPath := AddressPoolManifestPath
+	AddressPoolManifestPath = "../bindata/configuration/address-pool"
+	defer func() { AddressPoolManifestPath = manifestPath }()
+
+	pools := []client.Object{}
+	for _, p := range []struct {
+		name      string
+		protocol  string
+		addresses []string
+	}{
+		{"v6", "layer2", []string{"fc00::/64"}},
+		{"dual", "layer2", []string{"10.0.3.0/24", "fc00:1::/64"}},
+		{"bgp-v6", "bgp", []string{"fc00:2::/64"}},
+	} {
+		pools = append(pools, &metallbv1alpha1.AddressPool{
+			ObjectMeta: metav1.ObjectMeta{Name: p.name, Namespace: MetalLBTestNameSpace},
+			Spec:       metallbv1alpha1.AddressPoolSpec{Protocol: p.protocol, Addresses: p.addresses},
+		})
+	}
+	metallb := &metallbv1beta1.MetalLB{ObjectMeta: metav1.ObjectMeta{Name: defaultMetalLBCrName, Namespace: MetalLBTestNameSpace}}
+	c := newFakeClientBuilder().WithScheme(testScheme(g)).WithObjects(append(pools, metallb)...).Build()
+	reconciler := &AddressPoolReconciler{
+		Client:    c,
+		Log:       ctrl.Log.WithName("controllers").WithName("AddressPool"),
+		Namespace: MetalLBTestNameSpace,
+	}
+
+	reconcile := func() {
+		for _, pool := range pools {
+			key := types.NamespacedName{Name: pool.GetName(), Namespace: pool.GetNamespace()}
+			_, err := reconciler.Reconcile(context.Background(), ctrl.Request{NamespacedName: key})
+			g.Expect(err).ToNot(HaveOccurred())
+		}
+	}
+	condition := func(name string) *metav1.Condition {
+		pool := &metallbv1alpha1.AddressPool{}
+		g.Expect(c.Get(context.Background(), types.NamespacedName{Name: name, Namespace: MetalLBTestNameSpace}, pool)).To(Succeed())
+		g.Expect(meta.IsStatusConditionTrue(pool.Status.Conditions, status.ConditionAvailable)).To(BeTrue(), name)
+		return meta.FindStatusCondition(pool.Status.Conditions, status.ConditionIPFamilies)
+	}
+
+	// The IPv6 and dual-stack pools are rendered as any other pool
+	reconcile()
+	configMap := &corev1.ConfigMap{}
+	g.Expect(c.Get(context.Background(), types.NamespacedName{Name: apply.AddressPoolConfigMap, Namespace: MetalLBTestNameSpace}, configMap)).To(Succeed())
+	g.Expect(configMap.Data["config"]).To(MatchYAML(`address-pools:
+- name: bgp-v6
+  protocol: bgp
+  addresses:
+  - fc00:2::/64
+- name: dual
+  protocol: layer2
+  addresses:
+  - 10.0.3.0/24
+  - fc00:1::/64
+- name: v6
+  protocol: layer2
+  addresses:
+  - fc00::/64
+`))
+	g.Expect(manifests.ValidateMetalLBConfig(configMap.Data["config"])).To(Succeed())
+
+	g.Expect(condition("v6").Status).To(Equal(metav1.ConditionTrue))
+	g.Expect(condition("v6").Reason).To(Equal(FamilyIPv6))
+	g.Expect(condition("dual").Status).To(Equal(metav1.ConditionTrue))
+	g.Expect(condition("dual").Reason).To(Equal(FamilyDualStack))
+	// The native BGP backend does not announce the IPv6 addresses
+	g.Expect(condition("bgp-v6").Status).To(Equal(metav1.ConditionFalse))
+	g.Expect(condition("bgp-v6").Reason).To(Equal("UnsupportedIPFamily"))
+	g.Expect(condition("bgp-v6").Message).To(ContainSubstring("the MetalLB resource uses the native one"))
+
+	g.Expect(c.Get(context.Background(), types.NamespacedName{Name: defaultMetalLBCrName, Namespace: MetalLBTestNameSpace}, metallb)).To(Succeed())
+	metallb.Spec.BGPBackend = metallbv1beta1.BGPBackendFRR
+	g.Expect(c.Update(context.Background(), metallb)).To(Succeed())
+	reconcile()
+	g.Expect(condition("bgp-v6").Status).To(Equal(metav1.ConditionTrue))
+	g.Expect(condition("bgp-v6").Reason).To(Equal(FamilyIPv6))
+}
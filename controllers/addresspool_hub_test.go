@@ -0,0 +1,132 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	metallbv1alpha1 "github.com/metallb/metallb-operator/api/v1alpha1"
+	metallbv1beta1 "github.com/metallb/metallb-operator/api/v1beta1"
+)
+
+// fakeClientBuilder builds a fake client serving the AddressPools in both
+// versions, see hubClient.
+type fakeClientBuilder struct {
+	*fake.ClientBuilder
+}
+
+func newFakeClientBuilder() fakeClientBuilder {
+	return fakeClientBuilder{fake.NewClientBuilder()}
+}
+
+func (b fakeClientBuilder) WithScheme(s *runtime.Scheme) fakeClientBuilder {
+	return fakeClientBuilder{b.ClientBuilder.WithScheme(s)}
+}
+
+func (b fakeClientBuilder) WithObjects(objs ...client.Object) fakeClientBuilder {
+	return fakeClientBuilder{b.ClientBuilder.WithObjects(objs...)}
+}
+
+func (b fakeClientBuilder) Build() client.Client {
+	return hubClient{b.ClientBuilder.Build()}
+}
+
+// hubClient serves the v1beta1 AddressPools from the v1alpha1 ones the tests
+// create, converting them as the API server does, since the fake client
+// stores each version apart.
+type hubClient struct {
+	client.Client
+}
+
+func (c hubClient) Get(ctx context.Context, key client.ObjectKey, obj client.Object) error {
+	hub, ok := obj.(*metallbv1beta1.AddressPool)
+	if !ok {
+		return c.Client.Get(ctx, key, obj)
+	}
+	pool := &metallbv1alpha1.AddressPool{}
+	if err := c.Client.Get(ctx, key, pool); err != nil {
+		return err
+	}
+	return pool.ConvertTo(hub)
+}
+
+func (c hubClient) List(ctx context.Context, list client.ObjectList, opts ...client.ListOption) error {
+	hubList, ok := list.(*metallbv1beta1.AddressPoolList)
+	if !ok {
+		return c.Client.List(ctx, list, opts...)
+	}
+	pools := &metallbv1alpha1.AddressPoolList{}
+	if err := c.Client.List(ctx, pools, opts...); err != nil {
+		return err
+	}
+	hubList.Items = make([]metallbv1beta1.AddressPool, len(pools.Items))
+	for i := range pools.Items {
+		if err := pools.Items[i].ConvertTo(&hubList.Items[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// spokeFailingClient fails the v1alpha1 AddressPool reads, as the API server
+// does while the conversion webhook is unavailable.
+type spokeFailingClient struct {
+	client.Client
+}
+
+func (c spokeFailingClient) Get(ctx context.Context, key client.ObjectKey, obj client.Object) error {
+	if _, ok := obj.(*metallbv1alpha1.AddressPool); ok {
+		return errors.New("conversion webhook unavailable")
+	}
+	return c.Client.Get(ctx, key, obj)
+}
+
+func (c spokeFailingClient) List(ctx context.Context, list client.ObjectList, opts ...client.ListOption) error {
+	if _, ok := list.(*metallbv1alpha1.AddressPoolList); ok {
+		return errors.New("conversion webhook unavailable")
+	}
+	return c.Client.List(ctx, list, opts...)
+}
+
+func TestAddressPoolReadThroughHub(t *testing.T) {
+	g := NewGomegaWithT(t)
+	manifestPath := AddressPoolManifestPath
+	AddressPoolManifestPath = "../bindata/configuration/address-pool"
+	defer func() { AddressPoolManifestPath = manifestPath }()
+
+	// A bgp pool without any of the optional fields
+	pool := &metallbv1alpha1.AddressPool{
+		ObjectMeta: metav1.ObjectMeta{Name: "gold", Namespace: MetalLBTestNameSpace},
+		Spec: metallbv1alpha1.AddressPoolSpec{
+			Protocol:  metallbv1alpha1.ProtocolBGP,
+			Addresses: []string{"10.0.0.0/24"},
+		},
+	}
+	c := spokeFailingClient{newFakeClientBuilder().WithScheme(testScheme(g)).WithObjects(pool, testMetalLB(metallbv1beta1.MetalLBSpec{})).Build()}
+	reconciler := &AddressPoolReconciler{
+		Client:    c,
+		Log:       ctrl.Log.WithName("controllers").WithName("AddressPool"),
+		Namespace: MetalLBTestNameSpace,
+	}
+	key := types.NamespacedName{Name: pool.Name, Namespace: pool.Namespace}
+	_, err := reconciler.Reconcile(context.Background(), ctrl.Request{NamespacedName: key})
+	g.Expect(err).ToNot(HaveOccurred())
+
+	configMap := &corev1.ConfigMap{}
+	g.Expect(c.Get(context.Background(), types.NamespacedName{Name: "config", Namespace: MetalLBTestNameSpace}, configMap)).To(Succeed())
+	g.Expect(configMap.Data["config"]).To(MatchYAML(`address-pools:
+- name: gold
+  protocol: bgp
+  addresses:
+  - 10.0.0.0/24
+`))
+}
@@ -0,0 +1,107 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	metallbv1alpha1 "github.com/metallb/metallb-operator/api/v1alpha1"
+	metallbv1beta1 "github.com/metallb/metallb-operator/api/v1beta1"
+)
+
+func TestAddressPoolsRoutedToInstances(t *testing.T) {
+	g := NewGomegaWithT(t)
+	manifestPath := AddressPoolManifestPath
+	AddressPoolManifestPath = "../bindata/configuration/address-pool"
+	defer func() { AddressPoolManifestPath = manifestPath }()
+
+	autoAssign := true
+	pools := []client.Object{}
+	for _, p := range []struct{ name, instance, addresses string }{
+		{"shared", "", "10.0.0.0/24"},
+		{"pool-a", "metallb-a/metallb", "10.0.1.0/24"},
+		{"pool-b", "metallb-b/metallb", "10.0.2.0/24"},
+		{"unknown", "metallb-a/other", "10.0.3.0/24"},
+	} {
+		pool := &metallbv1alpha1.AddressPool{
+			ObjectMeta: metav1.ObjectMeta{Name: p.name, Namespace: "pools"},
+			Spec: metallbv1alpha1.AddressPoolSpec{
+				Protocol:   "layer2",
+				Addresses:  []string{p.addresses},
+				AutoAssign: &autoAssign,
+			},
+		}
+		if p.instance != "" {
+			pool.Annotations = map[string]string{InstanceAnnotation: p.instance}
+		}
+		pools = append(pools, pool)
+	}
+	c := newFakeClientBuilder().WithScheme(testScheme(g)).WithObjects(pools...).Build()
+
+	configs := map[string]string{}
+	for _, namespace := range []string{"metallb-a", "metallb-b"} {
+		metallb := &metallbv1beta1.MetalLB{ObjectMeta: metav1.ObjectMeta{Name: defaultMetalLBCrName, Namespace: namespace}}
+		g.Expect(c.Create(context.Background(), metallb)).To(Succeed())
+		reconciler := &AddressPoolReconciler{
+			Client:         c,
+			Log:            ctrl.Log.WithName("controllers").WithName("AddressPool"),
+			Namespace:      namespace,
+			PoolNamespaces: []string{"pools"},
+		}
+		for _, pool := range pools {
+			key := types.NamespacedName{Name: pool.GetName(), Namespace: pool.GetNamespace()}
+			_, err := reconciler.Reconcile(context.Background(), ctrl.Request{NamespacedName: key})
+			g.Expect(err).ToNot(HaveOccurred())
+		}
+
+		configMap := &corev1.ConfigMap{}
+		g.Expect(c.Get(context.Background(), types.NamespacedName{Name: "config", Namespace: namespace}, configMap)).To(Succeed())
+		configs[namespace] = configMap.Data["config"]
+	}
+
+	g.Expect(configs["metallb-a"]).To(MatchYAML(`address-pools:
+- name: pool-a
+  protocol: layer2
+  addresses:
+  - 10.0.1.0/24
+- name: shared
+  protocol: layer2
+  addresses:
+  - 10.0.0.0/24
+`))
+	g.Expect(configs["metallb-b"]).To(MatchYAML(`address-pools:
+- name: pool-b
+  protocol: layer2
+  addresses:
+  - 10.0.2.0/24
+- name: shared
+  protocol: layer2
+  addresses:
+  - 10.0.0.0/24
+`))
+}
+
+func TestOwnsPool(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	reconciler := &AddressPoolReconciler{Namespace: "metallb-system"}
+	for instance, owned := range map[string]bool{
+		"metallb":                 true,
+		"metallb-system/metallb":  true,
+		"other":                   false,
+		"metallb-other/metallb":   false,
+		"metallb-system/metallb2": false,
+	} {
+		pool := &metallbv1alpha1.AddressPool{
+			ObjectMeta: metav1.ObjectMeta{Annotations: map[string]string{InstanceAnnotation: instance}},
+		}
+		g.Expect(reconciler.ownsPool(pool)).To(Equal(owned), instance)
+	}
+	g.Expect(reconciler.ownsPool(&metallbv1alpha1.AddressPool{})).To(BeTrue())
+}
@@ -0,0 +1,64 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"math/big"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+
+	metallbv1alpha1 "github.com/metallb/metallb-operator/api/v1alpha1"
+	"github.com/metallb/metallb-operator/pkg/addresses"
+)
+
+var (
+	poolTotalAddresses = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "metallb_pool_total_addresses",
+		Help: "Number of addresses of the address pool.",
+	}, []string{"pool"})
+	poolUsedAddresses = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "metallb_pool_used_addresses",
+		Help: "Number of addresses of the address pool assigned to a service.",
+	}, []string{"pool"})
+)
+
+func init() {
+	metrics.Registry.MustRegister(poolTotalAddresses, poolUsedAddresses)
+}
+
+// updatePoolMetrics sets the address usage gauges of the given pools, when
+// enabled, dropping the ones of the pools no longer rendered.
+func (r *AddressPoolReconciler) updatePoolMetrics(ctx context.Context, pools []metallbv1alpha1.AddressPool) error {
+	if !r.PoolMetrics {
+		return nil
+	}
+	used, err := r.poolUsage(ctx, pools)
+	if err != nil {
+		return err
+	}
+
+	poolTotalAddresses.Reset()
+	poolUsedAddresses.Reset()
+	for _, pool := range pools {
+		total, _ := new(big.Float).SetInt(addresses.PoolSize(pool)).Float64()
+		poolTotalAddresses.WithLabelValues(pool.Name).Set(total)
+		poolUsedAddresses.WithLabelValues(pool.Name).Set(float64(used[pool.Name]))
+	}
+	return nil
+}
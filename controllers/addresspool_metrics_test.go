@@ -0,0 +1,74 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	metallbv1alpha1 "github.com/metallb/metallb-operator/api/v1alpha1"
+	metallbv1beta1 "github.com/metallb/metallb-operator/api/v1beta1"
+)
+
+func TestAddressPoolMetrics(t *testing.T) {
+	g := NewGomegaWithT(t)
+	manifestPath := AddressPoolManifestPath
+	AddressPoolManifestPath = "../bindata/configuration/address-pool"
+	defer func() { AddressPoolManifestPath = manifestPath }()
+
+	loadBalancer := func(name, ip string) *corev1.Service {
+		return &corev1.Service{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+			Spec:       corev1.ServiceSpec{Type: corev1.ServiceTypeLoadBalancer},
+			Status: corev1.ServiceStatus{LoadBalancer: corev1.LoadBalancerStatus{
+				Ingress: []corev1.LoadBalancerIngress{{IP: ip}},
+			}},
+		}
+	}
+	gold := &metallbv1alpha1.AddressPool{
+		ObjectMeta: metav1.ObjectMeta{Name: "gold", Namespace: MetalLBTestNameSpace},
+		Spec:       metallbv1alpha1.AddressPoolSpec{Protocol: "layer2", Addresses: []string{"10.0.0.0/30", "10.0.1.10-10.0.1.13"}},
+	}
+	silver := &metallbv1alpha1.AddressPool{
+		ObjectMeta: metav1.ObjectMeta{Name: "silver", Namespace: MetalLBTestNameSpace},
+		Spec:       metallbv1alpha1.AddressPoolSpec{Protocol: "layer2", Addresses: []string{"10.0.2.0/24"}},
+	}
+	objs := []client.Object{
+		gold,
+		silver,
+		loadBalancer("web", "10.0.0.1"),
+		loadBalancer("db", "10.0.1.12"),
+		loadBalancer("cache", "10.0.2.100"),
+	}
+
+	reconciler := &AddressPoolReconciler{
+		Client:      newFakeClientBuilder().WithScheme(testScheme(g)).WithObjects(testMetalLB(metallbv1beta1.MetalLBSpec{})).WithObjects(objs...).Build(),
+		Log:         ctrl.Log.WithName("controllers").WithName("AddressPool"),
+		Namespace:   MetalLBTestNameSpace,
+		PoolMetrics: true,
+	}
+	reconcile := func(name string) {
+		_, err := reconciler.Reconcile(context.Background(), ctrl.Request{
+			NamespacedName: types.NamespacedName{Name: name, Namespace: MetalLBTestNameSpace},
+		})
+		g.Expect(err).ToNot(HaveOccurred())
+	}
+
+	reconcile("gold")
+	g.Expect(testutil.ToFloat64(poolTotalAddresses.WithLabelValues("gold"))).To(Equal(8.0))
+	g.Expect(testutil.ToFloat64(poolUsedAddresses.WithLabelValues("gold"))).To(Equal(2.0))
+	g.Expect(testutil.ToFloat64(poolTotalAddresses.WithLabelValues("silver"))).To(Equal(256.0))
+	g.Expect(testutil.ToFloat64(poolUsedAddresses.WithLabelValues("silver"))).To(Equal(1.0))
+
+	// The gauges of a deleted pool are dropped
+	g.Expect(reconciler.Delete(context.Background(), silver)).To(Succeed())
+	reconcile("silver")
+	g.Expect(testutil.CollectAndCount(poolTotalAddresses)).To(Equal(1))
+	g.Expect(testutil.CollectAndCount(poolUsedAddresses)).To(Equal(1))
+}
@@ -0,0 +1,90 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	metallbv1alpha1 "github.com/metallb/metallb-operator/api/v1alpha1"
+	metallbv1beta1 "github.com/metallb/metallb-operator/api/v1beta1"
+)
+
+func TestAddressPoolsFromPoolNamespaces(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	config := reconcilePoolNamespaces(g, []string{"team-a", "team-b"})
+	g.Expect(config).To(MatchYAML(`address-pools:
+- name: pool-a
+  protocol: layer2
+  addresses:
+  - 10.0.1.0/24
+- name: pool-b
+  protocol: layer2
+  addresses:
+  - 10.0.2.0/24
+`))
+}
+
+func TestAddressPoolsFromAllNamespaces(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	config := reconcilePoolNamespaces(g, []string{AllPoolNamespaces})
+	g.Expect(config).To(MatchYAML(`address-pools:
+- name: pool-a
+  protocol: layer2
+  addresses:
+  - 10.0.1.0/24
+- name: pool-b
+  protocol: layer2
+  addresses:
+  - 10.0.2.0/24
+- name: pool-c
+  protocol: layer2
+  addresses:
+  - 10.0.3.0/24
+`))
+}
+
+// reconcilePoolNamespaces reconciles pools living in three different namespaces,
+// collecting them from the given namespaces, and returns the rendered config.
+func reconcilePoolNamespaces(g *WithT, namespaces []string) string {
+	manifestPath := AddressPoolManifestPath
+	AddressPoolManifestPath = "../bindata/configuration/address-pool"
+	defer func() { AddressPoolManifestPath = manifestPath }()
+
+	autoAssign := true
+	pools := []client.Object{}
+	for _, p := range []struct{ name, namespace, addresses string }{
+		{"pool-a", "team-a", "10.0.1.0/24"},
+		{"pool-b", "team-b", "10.0.2.0/24"},
+		{"pool-c", "team-c", "10.0.3.0/24"},
+	} {
+		pools = append(pools, &metallbv1alpha1.AddressPool{
+			ObjectMeta: metav1.ObjectMeta{Name: p.name, Namespace: p.namespace},
+			Spec: metallbv1alpha1.AddressPoolSpec{
+				Protocol:   "layer2",
+				Addresses:  []string{p.addresses},
+				AutoAssign: &autoAssign,
+			},
+		})
+	}
+
+	reconciler := &AddressPoolReconciler{
+		Client:         newFakeClientBuilder().WithScheme(testScheme(g)).WithObjects(testMetalLB(metallbv1beta1.MetalLBSpec{})).WithObjects(pools...).Build(),
+		Log:            ctrl.Log.WithName("controllers").WithName("AddressPool"),
+		Namespace:      MetalLBTestNameSpace,
+		PoolNamespaces: namespaces,
+	}
+	_, err := reconciler.Reconcile(context.Background(), ctrl.Request{NamespacedName: types.NamespacedName{Name: "pool-a", Namespace: "team-a"}})
+	g.Expect(err).ToNot(HaveOccurred())
+
+	configMap := &corev1.ConfigMap{}
+	g.Expect(reconciler.Get(context.Background(), types.NamespacedName{Name: "config", Namespace: MetalLBTestNameSpace}, configMap)).To(Succeed())
+	return configMap.Data["config"]
+}
@@ -0,0 +1,101 @@
+package controllers
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	uns "k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	metallbv1alpha1 "github.com/metallb/metallb-operator/api/v1alpha1"
+	"github.com/metallb/metallb-operator/pkg/apply"
+	"github.com/metallb/metallb-operator/test/manifests"
+)
+
+func TestRenderAvoidBuggyIPs(t *testing.T) {
+	g := NewGomegaWithT(t)
+	manifestPath := AddressPoolManifestPath
+	AddressPoolManifestPath = "../bindata/configuration/address-pool"
+	defer func() { AddressPoolManifestPath = manifestPath }()
+
+	autoAssign := false
+	tests := []struct {
+		desc     string
+		spec     metallbv1alpha1.AddressPoolSpec
+		expected string
+	}{
+		{
+			desc: "avoid buggy ips",
+			spec: metallbv1alpha1.AddressPoolSpec{
+				Protocol:      "layer2",
+				Addresses:     []string{"10.0.0.0/24"},
+				AvoidBuggyIPs: true,
+			},
+			expected: `address-pools:
+- name: gold
+  protocol: layer2
+  addresses:
+  - 10.0.0.0/24
+  avoid-buggy-ips: true
+`,
+		},
+		{
+			desc: "avoid buggy ips and auto assign set to false",
+			spec: metallbv1alpha1.AddressPoolSpec{
+				Protocol:      "layer2",
+				Addresses:     []string{"10.0.0.0/24"},
+				AutoAssign:    &autoAssign,
+				AvoidBuggyIPs: true,
+			},
+			expected: `address-pools:
+- name: gold
+  protocol: layer2
+  auto-assign: false
+  avoid-buggy-ips: true
+  addresses:
+  - 10.0.0.0/24
+`,
+		},
+		{
+			desc: "buggy ips not avoided",
+			spec: metallbv1alpha1.AddressPoolSpec{
+				Protocol:  "layer2",
+				Addresses: []string{"10.0.0.0/24"},
+			},
+			expected: `address-pools:
+- name: gold
+  protocol: layer2
+  addresses:
+  - 10.0.0.0/24
+`,
+		},
+	}
+
+	reconciler := &AddressPoolReconciler{
+		Client:    newFakeClientBuilder().WithScheme(testScheme(g)).Build(),
+		Log:       ctrl.Log.WithName("controllers").WithName("AddressPool"),
+		Namespace: MetalLBTestNameSpace,
+	}
+	for _, test := range tests {
+		pools := []metallbv1alpha1.AddressPool{{
+			ObjectMeta: metav1.ObjectMeta{Name: "gold", Namespace: MetalLBTestNameSpace},
+			Spec:       test.spec,
+		}}
+		obj, poolErrs, err := reconciler.renderObject(pools)
+		g.Expect(err).ToNot(HaveOccurred(), test.desc)
+		g.Expect(poolErrs).To(BeEmpty(), test.desc)
+
+		config, _, err := uns.NestedString(obj.Object, "data", apply.AddressPoolConfigMap)
+		g.Expect(err).ToNot(HaveOccurred(), test.desc)
+		g.Expect(config).To(MatchYAML(test.expected), test.desc)
+		g.Expect(manifests.ValidateMetalLBConfig(config)).To(Succeed(), test.desc)
+
+		// The ConfigMap merge reads the pools back into their spec
+		upd := obj.DeepCopy()
+		g.Expect(apply.MergeObjectForUpdate(obj, upd)).To(Succeed(), test.desc)
+		merged, _, err := uns.NestedString(upd.Object, "data", apply.AddressPoolConfigMap)
+		g.Expect(err).ToNot(HaveOccurred(), test.desc)
+		g.Expect(merged).To(MatchYAML(test.expected), test.desc)
+	}
+}
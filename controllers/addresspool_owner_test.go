@@ -0,0 +1,110 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	metallbv1alpha1 "github.com/metallb/metallb-operator/api/v1alpha1"
+	metallbv1beta1 "github.com/metallb/metallb-operator/api/v1beta1"
+	"github.com/metallb/metallb-operator/pkg/apply"
+)
+
+func TestConfigMapOwnedByMetalLB(t *testing.T) {
+	g := NewGomegaWithT(t)
+	manifestPath := AddressPoolManifestPath
+	AddressPoolManifestPath = "../bindata/configuration/address-pool"
+	defer func() { AddressPoolManifestPath = manifestPath }()
+
+	gold := &metallbv1alpha1.AddressPool{
+		ObjectMeta: metav1.ObjectMeta{Name: "gold", Namespace: MetalLBTestNameSpace},
+		Spec:       metallbv1alpha1.AddressPoolSpec{Protocol: "layer2", Addresses: []string{"10.0.0.0/24"}},
+	}
+	c := newFakeClientBuilder().WithScheme(testScheme(g)).WithObjects(gold).Build()
+	reconciler := &AddressPoolReconciler{
+		Client:    c,
+		Log:       ctrl.Log.WithName("controllers").WithName("AddressPool"),
+		Namespace: MetalLBTestNameSpace,
+	}
+	key := types.NamespacedName{Name: apply.AddressPoolConfigMap, Namespace: MetalLBTestNameSpace}
+	reconcile := func() {
+		_, err := reconciler.Reconcile(context.Background(), ctrl.Request{NamespacedName: types.NamespacedName{Name: gold.Name, Namespace: gold.Namespace}})
+		g.Expect(err).ToNot(HaveOccurred())
+	}
+	ownerReferences := func() []metav1.OwnerReference {
+		reconcile()
+		configMap := &corev1.ConfigMap{}
+		g.Expect(c.Get(context.Background(), key, configMap)).To(Succeed())
+		return configMap.OwnerReferences
+	}
+
+	// Without a MetalLB resource there is no ConfigMap
+	reconcile()
+	g.Expect(errors.IsNotFound(c.Get(context.Background(), key, &corev1.ConfigMap{}))).To(BeTrue())
+
+	metallb := testMetalLB(metallbv1beta1.MetalLBSpec{})
+	metallb.UID = "metallb-uid"
+	g.Expect(c.Create(context.Background(), metallb)).To(Succeed())
+	owners := ownerReferences()
+	g.Expect(owners).To(HaveLen(1))
+	g.Expect(owners[0].Kind).To(Equal("MetalLB"))
+	g.Expect(owners[0].Name).To(Equal(defaultMetalLBCrName))
+	g.Expect(owners[0].UID).To(Equal(metallb.UID))
+
+	// Reconciling again keeps a single owner
+	g.Expect(ownerReferences()).To(Equal(owners))
+
+	// The ConfigMap is left alone once the MetalLB resource is deleted, for
+	// it to be garbage collected
+	g.Expect(c.Delete(context.Background(), metallb)).To(Succeed())
+	g.Expect(ownerReferences()).To(Equal(owners))
+}
+
+func TestConfigMapOwnedByMetalLBAfterPoolDeletion(t *testing.T) {
+	g := NewGomegaWithT(t)
+	manifestPath := AddressPoolManifestPath
+	AddressPoolManifestPath = "../bindata/configuration/address-pool"
+	defer func() { AddressPoolManifestPath = manifestPath }()
+
+	gold := &metallbv1alpha1.AddressPool{
+		ObjectMeta: metav1.ObjectMeta{Name: "gold", Namespace: MetalLBTestNameSpace},
+		Spec:       metallbv1alpha1.AddressPoolSpec{Protocol: "layer2", Addresses: []string{"10.0.0.0/24"}},
+	}
+	silver := &metallbv1alpha1.AddressPool{
+		ObjectMeta: metav1.ObjectMeta{Name: "silver", Namespace: MetalLBTestNameSpace},
+		Spec:       metallbv1alpha1.AddressPoolSpec{Protocol: "layer2", Addresses: []string{"10.0.1.0/24"}},
+	}
+	metallb := testMetalLB(metallbv1beta1.MetalLBSpec{})
+	metallb.UID = "metallb-uid"
+	c := newFakeClientBuilder().WithScheme(testScheme(g)).WithObjects(gold, silver, metallb).Build()
+	reconciler := &AddressPoolReconciler{
+		Client:    c,
+		Log:       ctrl.Log.WithName("controllers").WithName("AddressPool"),
+		Namespace: MetalLBTestNameSpace,
+	}
+	reconcile := func(name string) *corev1.ConfigMap {
+		_, err := reconciler.Reconcile(context.Background(), ctrl.Request{NamespacedName: types.NamespacedName{Name: name, Namespace: MetalLBTestNameSpace}})
+		g.Expect(err).ToNot(HaveOccurred())
+		configMap := &corev1.ConfigMap{}
+		g.Expect(c.Get(context.Background(), types.NamespacedName{Name: apply.AddressPoolConfigMap, Namespace: MetalLBTestNameSpace}, configMap)).To(Succeed())
+		return configMap
+	}
+
+	g.Expect(reconcile(gold.Name).OwnerReferences).To(HaveLen(1))
+
+	// The ConfigMap rendered again without the deleted pool is still owned
+	g.Expect(c.Delete(context.Background(), silver)).To(Succeed())
+	configMap := reconcile(silver.Name)
+	names, err := apply.ConfigPoolNames(configMap.Data[apply.AddressPoolConfigMap])
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(names).To(Equal([]string{"gold"}))
+	g.Expect(configMap.OwnerReferences).To(HaveLen(1))
+	g.Expect(configMap.OwnerReferences[0].Kind).To(Equal("MetalLB"))
+	g.Expect(configMap.OwnerReferences[0].UID).To(Equal(metallb.UID))
+}
@@ -0,0 +1,56 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+
+	metallbv1alpha1 "github.com/metallb/metallb-operator/api/v1alpha1"
+	"github.com/metallb/metallb-operator/pkg/addresses"
+)
+
+// +kubebuilder:rbac:groups="",resources=nodes,verbs=get;list;watch
+
+// podCIDROverlap returns a message describing the first range of the pool that
+// overlaps with the pod CIDR of a node, or an empty string when there is none.
+// Ranges that can't be parsed are ignored.
+func (r *AddressPoolReconciler) podCIDROverlap(ctx context.Context, pool *metallbv1alpha1.AddressPool) (string, error) {
+	nodes := &corev1.NodeList{}
+	if err := r.List(ctx, nodes); err != nil {
+		return "", fmt.Errorf("Failed to list nodes %w", err)
+	}
+
+	for _, node := range nodes.Items {
+		podCIDRs := node.Spec.PodCIDRs
+		if len(podCIDRs) == 0 && node.Spec.PodCIDR != "" {
+			podCIDRs = []string{node.Spec.PodCIDR}
+		}
+		for _, podCIDR := range podCIDRs {
+			for _, r := range pool.Spec.Addresses {
+				overlap, err := addresses.Overlap(r, podCIDR)
+				if err != nil || !overlap {
+					continue
+				}
+				return fmt.Sprintf("Range %s overlaps with the pod CIDR %s of node %s", r, podCIDR, node.Name), nil
+			}
+		}
+	}
+	return "", nil
+}
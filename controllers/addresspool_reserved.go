@@ -0,0 +1,126 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+
+	metallbv1alpha1 "github.com/metallb/metallb-operator/api/v1alpha1"
+	"github.com/metallb/metallb-operator/pkg/addresses"
+)
+
+// reservedRangeError is reported for the AddressPools left out as they
+// overlap with a reserved range.
+type reservedRangeError struct {
+	message string
+}
+
+func (e *reservedRangeError) Error() string {
+	return e.message
+}
+
+// reservedRange is a range of the reserved ranges ConfigMap, with the key
+// holding it.
+type reservedRange struct {
+	key       string
+	addresses string
+}
+
+// rejectReservedRanges leaves out the pools with a range overlapping with a
+// range of the ReservedRangesConfigMap, and returns why each was rejected.
+func (r *AddressPoolReconciler) rejectReservedRanges(ctx context.Context, pools []metallbv1alpha1.AddressPool) ([]metallbv1alpha1.AddressPool, map[types.NamespacedName]error, error) {
+	rejected := map[types.NamespacedName]error{}
+	reserved, err := r.reservedRanges(ctx)
+	if err != nil || len(reserved) == 0 {
+		return pools, rejected, err
+	}
+
+	admitted := []metallbv1alpha1.AddressPool{}
+	for _, pool := range pools {
+		if err := checkReservedRanges(pool, reserved); err != nil {
+			rejected[types.NamespacedName{Name: pool.Name, Namespace: pool.Namespace}] = err
+			continue
+		}
+		admitted = append(admitted, pool)
+	}
+	return admitted, rejected, nil
+}
+
+// reservedRanges returns the ranges of the ReservedRangesConfigMap. Each of
+// its keys holds ranges, one per line, as CIDRs or first-last ranges, the
+// lines starting with a # are ignored. A missing ConfigMap reserves nothing.
+func (r *AddressPoolReconciler) reservedRanges(ctx context.Context) ([]reservedRange, error) {
+	if r.ReservedRangesConfigMap == "" {
+		return nil, nil
+	}
+	configMap := &corev1.ConfigMap{}
+	err := r.Get(ctx, types.NamespacedName{Name: r.ReservedRangesConfigMap, Namespace: r.Namespace}, configMap)
+	if errors.IsNotFound(err) {
+		r.Log.Info(fmt.Sprintf("The reserved ranges ConfigMap %s does not exist, no range is reserved", r.ReservedRangesConfigMap))
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get the reserved ranges ConfigMap %w", err)
+	}
+
+	keys := make([]string, 0, len(configMap.Data))
+	for key := range configMap.Data {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	reserved := []reservedRange{}
+	for _, key := range keys {
+		scanner := bufio.NewScanner(strings.NewReader(configMap.Data[key]))
+		for scanner.Scan() {
+			line := strings.TrimSpace(scanner.Text())
+			if line == "" || strings.HasPrefix(line, "#") {
+				continue
+			}
+			if _, _, err := addresses.ParseRange(line); err != nil {
+				return nil, fmt.Errorf("Invalid range %q in the %s key of the reserved ranges ConfigMap %s: %w", line, key, r.ReservedRangesConfigMap, err)
+			}
+			reserved = append(reserved, reservedRange{key: key, addresses: line})
+		}
+	}
+	return reserved, nil
+}
+
+// checkReservedRanges returns an error describing the first range of the pool
+// overlapping with a reserved range. Ranges that can't be parsed are ignored.
+func checkReservedRanges(pool metallbv1alpha1.AddressPool, reserved []reservedRange) error {
+	for _, r := range pool.Spec.Addresses {
+		for _, res := range reserved {
+			overlap, err := addresses.Overlap(r, res.addresses)
+			if err != nil || !overlap {
+				continue
+			}
+			return &reservedRangeError{
+				message: fmt.Sprintf("Range %s overlaps with the reserved range %s of %s", r, res.addresses, res.key),
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,118 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	metallbv1alpha1 "github.com/metallb/metallb-operator/api/v1alpha1"
+	metallbv1beta1 "github.com/metallb/metallb-operator/api/v1beta1"
+	"github.com/metallb/metallb-operator/pkg/status"
+)
+
+func TestAddressPoolReservedRanges(t *testing.T) {
+	g := NewGomegaWithT(t)
+	manifestPath := AddressPoolManifestPath
+	AddressPoolManifestPath = "../bindata/configuration/address-pool"
+	defer func() { AddressPoolManifestPath = manifestPath }()
+
+	reserved := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "reserved-ranges", Namespace: MetalLBTestNameSpace},
+		Data: map[string]string{
+			"management": "# The management network\n10.0.0.128/25\n",
+			"storage":    "10.0.10.1-10.0.10.10",
+		},
+	}
+	objs := []client.Object{
+		reserved,
+		&metallbv1alpha1.AddressPool{
+			ObjectMeta: metav1.ObjectMeta{Name: "gold", Namespace: MetalLBTestNameSpace},
+			Spec:       metallbv1alpha1.AddressPoolSpec{Protocol: "layer2", Addresses: []string{"10.0.0.0/24"}},
+		},
+		&metallbv1alpha1.AddressPool{
+			ObjectMeta: metav1.ObjectMeta{Name: "silver", Namespace: MetalLBTestNameSpace},
+			Spec:       metallbv1alpha1.AddressPoolSpec{Protocol: "layer2", Addresses: []string{"10.0.1.0/24"}},
+		},
+	}
+	reconciler := &AddressPoolReconciler{
+		Client:                  newFakeClientBuilder().WithScheme(testScheme(g)).WithObjects(testMetalLB(metallbv1beta1.MetalLBSpec{})).WithObjects(objs...).Build(),
+		Log:                     ctrl.Log.WithName("controllers").WithName("AddressPool"),
+		Namespace:               MetalLBTestNameSpace,
+		ReservedRangesConfigMap: "reserved-ranges",
+	}
+	reconcile := func(name string) *metallbv1alpha1.AddressPool {
+		key := types.NamespacedName{Name: name, Namespace: MetalLBTestNameSpace}
+		_, err := reconciler.Reconcile(context.Background(), ctrl.Request{NamespacedName: key})
+		g.Expect(err).ToNot(HaveOccurred())
+		pool := &metallbv1alpha1.AddressPool{}
+		g.Expect(reconciler.Get(context.Background(), key, pool)).To(Succeed())
+		return pool
+	}
+	config := func() string {
+		configMap := &corev1.ConfigMap{}
+		g.Expect(reconciler.Get(context.Background(), types.NamespacedName{Name: "config", Namespace: MetalLBTestNameSpace}, configMap)).To(Succeed())
+		return configMap.Data["config"]
+	}
+
+	gold := reconcile("gold")
+	degraded := meta.FindStatusCondition(gold.Status.Conditions, status.ConditionDegraded)
+	g.Expect(degraded).ToNot(BeNil())
+	g.Expect(degraded.Status).To(Equal(metav1.ConditionTrue))
+	g.Expect(degraded.Reason).To(Equal("ReservedRangeViolation"))
+	g.Expect(degraded.Message).To(Equal("Range 10.0.0.0/24 overlaps with the reserved range 10.0.0.128/25 of management"))
+
+	silver := reconcile("silver")
+	g.Expect(meta.IsStatusConditionTrue(silver.Status.Conditions, status.ConditionAvailable)).To(BeTrue())
+	g.Expect(config()).To(ContainSubstring("silver"))
+	g.Expect(config()).ToNot(ContainSubstring("gold"))
+
+	// The management network moved, the pool complies with the policy
+	reserved.Data["management"] = "192.168.0.0/24"
+	g.Expect(reconciler.Update(context.Background(), reserved)).To(Succeed())
+	gold = reconcile("gold")
+	g.Expect(meta.IsStatusConditionTrue(gold.Status.Conditions, status.ConditionAvailable)).To(BeTrue())
+	g.Expect(config()).To(ContainSubstring("gold"))
+
+	// The pool already rendered is removed from the configuration
+	reserved.Data["storage"] = "10.0.1.0/28"
+	g.Expect(reconciler.Update(context.Background(), reserved)).To(Succeed())
+	silver = reconcile("silver")
+	g.Expect(meta.IsStatusConditionTrue(silver.Status.Conditions, status.ConditionDegraded)).To(BeTrue())
+	g.Expect(config()).To(ContainSubstring("gold"))
+	g.Expect(config()).ToNot(ContainSubstring("silver"))
+}
+
+func TestAddressPoolInvalidReservedRanges(t *testing.T) {
+	g := NewGomegaWithT(t)
+	manifestPath := AddressPoolManifestPath
+	AddressPoolManifestPath = "../bindata/configuration/address-pool"
+	defer func() { AddressPoolManifestPath = manifestPath }()
+
+	objs := []client.Object{
+		&corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: "reserved-ranges", Namespace: MetalLBTestNameSpace},
+			Data:       map[string]string{"management": "10.0.0.300/24"},
+		},
+		&metallbv1alpha1.AddressPool{
+			ObjectMeta: metav1.ObjectMeta{Name: "gold", Namespace: MetalLBTestNameSpace},
+			Spec:       metallbv1alpha1.AddressPoolSpec{Protocol: "layer2", Addresses: []string{"10.0.0.0/24"}},
+		},
+	}
+	reconciler := &AddressPoolReconciler{
+		Client:                  newFakeClientBuilder().WithScheme(testScheme(g)).WithObjects(testMetalLB(metallbv1beta1.MetalLBSpec{})).WithObjects(objs...).Build(),
+		Log:                     ctrl.Log.WithName("controllers").WithName("AddressPool"),
+		Namespace:               MetalLBTestNameSpace,
+		ReservedRangesConfigMap: "reserved-ranges",
+	}
+	_, err := reconciler.Reconcile(context.Background(), ctrl.Request{
+		NamespacedName: types.NamespacedName{Name: "gold", Namespace: MetalLBTestNameSpace},
+	})
+	g.Expect(err).To(MatchError(ContainSubstring(`Invalid range "10.0.0.300/24" in the management key`)))
+}
@@ -0,0 +1,208 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	uns "k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	metallbv1alpha1 "github.com/metallb/metallb-operator/api/v1alpha1"
+	metallbv1beta1 "github.com/metallb/metallb-operator/api/v1beta1"
+	"github.com/metallb/metallb-operator/pkg/apply"
+)
+
+func TestRenderPoolsSortedByName(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	config := renderSortedPools(g, metallbv1beta1.PoolSortByName)
+	g.Expect(config).To(MatchYAML(`address-pools:
+- name: alpha
+  protocol: layer2
+  addresses:
+  - 10.0.0.0/24
+- name: bravo
+  protocol: layer2
+  addresses:
+  - 192.168.10.1-192.168.10.100
+- name: charlie
+  protocol: layer2
+  addresses:
+  - 172.16.0.10-172.16.0.20
+  auto-assign: false
+`))
+}
+
+func TestRenderPoolsSortedByAddress(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	config := renderSortedPools(g, metallbv1beta1.PoolSortByAddress)
+	g.Expect(config).To(MatchYAML(`address-pools:
+- name: alpha
+  protocol: layer2
+  addresses:
+  - 10.0.0.0/24
+- name: charlie
+  protocol: layer2
+  addresses:
+  - 172.16.0.10-172.16.0.20
+  auto-assign: false
+- name: bravo
+  protocol: layer2
+  addresses:
+  - 192.168.10.1-192.168.10.100
+`))
+}
+
+func TestRenderPoolsDefaultOrder(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	g.Expect(renderSortedPools(g, "")).To(Equal(renderSortedPools(g, metallbv1beta1.PoolSortByName)))
+}
+
+// renderSortedPools renders the MetalLB ConfigMap of a fixed set of pools with
+// the given sort order, and returns its config.
+func renderSortedPools(g *WithT, order string) string {
+	manifestPath := AddressPoolManifestPath
+	AddressPoolManifestPath = "../bindata/configuration/address-pool"
+	defer func() { AddressPoolManifestPath = manifestPath }()
+
+	autoAssign, noAutoAssign := true, false
+	pools := []metallbv1alpha1.AddressPool{
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "bravo", Namespace: MetalLBTestNameSpace},
+			Spec: metallbv1alpha1.AddressPoolSpec{
+				Protocol:   "layer2",
+				Addresses:  []string{"192.168.10.1-192.168.10.100"},
+				AutoAssign: &autoAssign,
+			},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "charlie", Namespace: MetalLBTestNameSpace},
+			Spec: metallbv1alpha1.AddressPoolSpec{
+				Protocol:   "layer2",
+				Addresses:  []string{"172.16.0.10-172.16.0.20"},
+				AutoAssign: &noAutoAssign,
+			},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "alpha", Namespace: MetalLBTestNameSpace},
+			Spec: metallbv1alpha1.AddressPoolSpec{
+				Protocol:   "layer2",
+				Addresses:  []string{"10.0.0.0/24"},
+				AutoAssign: &autoAssign,
+			},
+		},
+	}
+	metallb := &metallbv1beta1.MetalLB{
+		ObjectMeta: metav1.ObjectMeta{Name: defaultMetalLBCrName, Namespace: MetalLBTestNameSpace},
+		Spec:       metallbv1beta1.MetalLBSpec{PoolSortOrder: order},
+	}
+
+	reconciler := &AddressPoolReconciler{
+		Client:    newFakeClientBuilder().WithScheme(testScheme(g)).WithObjects(metallb).Build(),
+		Log:       ctrl.Log.WithName("controllers").WithName("AddressPool"),
+		Namespace: MetalLBTestNameSpace,
+	}
+	obj, poolErrs, err := reconciler.renderObject(pools)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(poolErrs).To(BeEmpty())
+
+	config, _, err := uns.NestedString(obj.Object, "data", apply.AddressPoolConfigMap)
+	g.Expect(err).ToNot(HaveOccurred())
+	return config
+}
+
+func TestRenderPoolCIDRsAndRanges(t *testing.T) {
+	g := NewGomegaWithT(t)
+	manifestPath := AddressPoolManifestPath
+	AddressPoolManifestPath = "../bindata/configuration/address-pool"
+	defer func() { AddressPoolManifestPath = manifestPath }()
+
+	pools := []metallbv1alpha1.AddressPool{
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "gold", Namespace: MetalLBTestNameSpace},
+			Spec: metallbv1alpha1.AddressPoolSpec{
+				Protocol:  "layer2",
+				Addresses: []string{"192.168.10.0/24", "10.0.0.1-10.0.0.100", "172.16.0.0/28"},
+			},
+		},
+	}
+	reconciler := &AddressPoolReconciler{
+		Client:    newFakeClientBuilder().WithScheme(testScheme(g)).Build(),
+		Log:       ctrl.Log.WithName("controllers").WithName("AddressPool"),
+		Namespace: MetalLBTestNameSpace,
+	}
+	expected := `address-pools:
+- name: gold
+  protocol: layer2
+  addresses:
+  - 192.168.10.0/24
+  - 10.0.0.1-10.0.0.100
+  - 172.16.0.0/28
+`
+	for i := 0; i < 3; i++ {
+		obj, poolErrs, err := reconciler.renderObject(pools)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(poolErrs).To(BeEmpty())
+		config, _, err := uns.NestedString(obj.Object, "data", apply.AddressPoolConfigMap)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(config).To(Equal(expected))
+	}
+}
+
+func TestReconcilePoolsCreatedOutOfOrder(t *testing.T) {
+	g := NewGomegaWithT(t)
+	manifestPath := AddressPoolManifestPath
+	AddressPoolManifestPath = "../bindata/configuration/address-pool"
+	defer func() { AddressPoolManifestPath = manifestPath }()
+
+	expected := `address-pools:
+- name: alpha
+  protocol: layer2
+  addresses:
+  - 10.0.2.0/24
+- name: mike
+  protocol: layer2
+  addresses:
+  - 10.0.1.0/24
+- name: zulu
+  protocol: layer2
+  addresses:
+  - 10.0.0.0/24
+`
+	// Reconciling after each pool creation, the ConfigMap is the same
+	// whatever the order the pools were created in
+	for _, names := range [][]string{{"zulu", "mike", "alpha"}, {"mike", "alpha", "zulu"}} {
+		g.Expect(reconcilePoolsInOrder(g, names)).To(Equal(expected))
+	}
+}
+
+// reconcilePoolsInOrder creates the pools of the given names one after the
+// other, reconciling each, and returns the resulting MetalLB config.
+func reconcilePoolsInOrder(g *WithT, names []string) string {
+	addresses := map[string]string{"zulu": "10.0.0.0/24", "mike": "10.0.1.0/24", "alpha": "10.0.2.0/24"}
+	c := newFakeClientBuilder().WithScheme(testScheme(g)).WithObjects(testMetalLB(metallbv1beta1.MetalLBSpec{})).Build()
+	reconciler := &AddressPoolReconciler{
+		Client:    c,
+		Log:       ctrl.Log.WithName("controllers").WithName("AddressPool"),
+		Namespace: MetalLBTestNameSpace,
+	}
+	for _, name := range names {
+		pool := &metallbv1alpha1.AddressPool{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: MetalLBTestNameSpace},
+			Spec:       metallbv1alpha1.AddressPoolSpec{Protocol: "layer2", Addresses: []string{addresses[name]}},
+		}
+		g.Expect(c.Create(context.Background(), pool)).To(Succeed())
+		_, err := reconciler.Reconcile(context.Background(), ctrl.Request{NamespacedName: types.NamespacedName{Name: name, Namespace: MetalLBTestNameSpace}})
+		g.Expect(err).ToNot(HaveOccurred())
+	}
+
+	configMap := &corev1.ConfigMap{}
+	g.Expect(c.Get(context.Background(), types.NamespacedName{Name: apply.AddressPoolConfigMap, Namespace: MetalLBTestNameSpace}, configMap)).To(Succeed())
+	return configMap.Data[apply.AddressPoolConfigMap]
+}
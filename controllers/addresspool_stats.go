@@ -0,0 +1,145 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"net"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	uns "k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+
+	metallbv1alpha1 "github.com/metallb/metallb-operator/api/v1alpha1"
+	metallbv1beta1 "github.com/metallb/metallb-operator/api/v1beta1"
+	"github.com/metallb/metallb-operator/pkg/addresses"
+	"github.com/metallb/metallb-operator/pkg/apply"
+	"github.com/metallb/metallb-operator/pkg/status"
+)
+
+// StatsConfigMap is the name of the ConfigMap the pool statistics are exported to.
+// For each pool it holds the <pool>.total, <pool>.used and <pool>.available keys.
+const StatsConfigMap = "metallb-stats"
+
+// +kubebuilder:rbac:groups="",resources=services,verbs=list;watch
+
+// exportStats writes the address usage of the given pools to the stats ConfigMap,
+// when enabled on the MetalLB resource.
+func (r *AddressPoolReconciler) exportStats(ctx context.Context, pools []metallbv1alpha1.AddressPool) error {
+	metallb := &metallbv1beta1.MetalLB{}
+	err := r.Get(ctx, types.NamespacedName{Name: defaultMetalLBCrName, Namespace: r.Namespace}, metallb)
+	if errors.IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("Failed to get MetalLB resource %w", err)
+	}
+	if metallb.Spec.ExportStats == nil || !*metallb.Spec.ExportStats {
+		return nil
+	}
+
+	used, err := r.poolUsage(ctx, pools)
+	if err != nil {
+		return err
+	}
+
+	configMap := &corev1.ConfigMap{
+		TypeMeta: metav1.TypeMeta{APIVersion: "v1", Kind: "ConfigMap"},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      StatsConfigMap,
+			Namespace: r.Namespace,
+		},
+		Data: map[string]string{},
+	}
+	for _, pool := range pools {
+		total := addresses.PoolSize(pool)
+		inUse := big.NewInt(int64(used[pool.Name]))
+		configMap.Data[pool.Name+".total"] = total.String()
+		configMap.Data[pool.Name+".used"] = inUse.String()
+		configMap.Data[pool.Name+".available"] = new(big.Int).Sub(total, inUse).String()
+	}
+
+	obj := &uns.Unstructured{}
+	if err := toUnstructured(configMap, obj); err != nil {
+		return err
+	}
+	return apply.ApplyObject(ctx, r.Client, obj)
+}
+
+// poolUsage returns the number of addresses of each pool assigned to a service.
+// The assigned addresses are the LoadBalancer ingress IPs of the services
+// visible to the operator.
+func (r *AddressPoolReconciler) poolUsage(ctx context.Context, pools []metallbv1alpha1.AddressPool) (map[string]int, error) {
+	services := &corev1.ServiceList{}
+	if err := r.List(ctx, services); err != nil {
+		return nil, fmt.Errorf("Failed to list services %w", err)
+	}
+	assigned := map[string]map[string]bool{}
+	for _, svc := range services.Items {
+		if svc.Spec.Type != corev1.ServiceTypeLoadBalancer {
+			continue
+		}
+		for _, ingress := range svc.Status.LoadBalancer.Ingress {
+			pool, ok := addresses.FindPool(net.ParseIP(ingress.IP), pools)
+			if !ok {
+				continue
+			}
+			if assigned[pool] == nil {
+				assigned[pool] = map[string]bool{}
+			}
+			assigned[pool][ingress.IP] = true
+		}
+	}
+
+	used := make(map[string]int, len(assigned))
+	for pool, ips := range assigned {
+		used[pool] = len(ips)
+	}
+	return used, nil
+}
+
+// maxInt caps the address counts of the pools status, the IPv6 pools may
+// hold more addresses than an int.
+const maxInt = int(^uint(0) >> 1)
+
+// +kubebuilder:rbac:groups="",resources=events,verbs=create;patch
+
+// updateExhausted sets the Exhausted condition and the address counts of the
+// pool, and emits a PoolExhausted event when all its addresses become assigned.
+func (r *AddressPoolReconciler) updateExhausted(ctx context.Context, pool *metallbv1alpha1.AddressPool) error {
+	used, err := r.poolUsage(ctx, []metallbv1alpha1.AddressPool{*pool})
+	if err != nil {
+		return err
+	}
+	total := addresses.PoolSize(*pool)
+	exhausted := total.Sign() > 0 && total.Cmp(big.NewInt(int64(used[pool.Name]))) <= 0
+	message := fmt.Sprintf("%d of %s addresses assigned", used[pool.Name], total)
+
+	if exhausted && !meta.IsStatusConditionTrue(pool.Status.Conditions, status.ConditionExhausted) && r.Recorder != nil {
+		r.Recorder.Event(pool, corev1.EventTypeWarning, "PoolExhausted", message)
+	}
+	totalAddresses := maxInt
+	if total.IsInt64() && total.Int64() < int64(maxInt) {
+		totalAddresses = int(total.Int64())
+	}
+	return status.UpdateAddressPoolExhausted(ctx, r.Client, pool, used[pool.Name], totalAddresses, exhausted, message)
+}
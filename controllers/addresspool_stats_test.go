@@ -0,0 +1,218 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	metallbv1alpha1 "github.com/metallb/metallb-operator/api/v1alpha1"
+	metallbv1beta1 "github.com/metallb/metallb-operator/api/v1beta1"
+	"github.com/metallb/metallb-operator/pkg/status"
+)
+
+func TestAddressPoolExportStats(t *testing.T) {
+	g := NewGomegaWithT(t)
+	manifestPath := AddressPoolManifestPath
+	AddressPoolManifestPath = "../bindata/configuration/address-pool"
+	defer func() { AddressPoolManifestPath = manifestPath }()
+
+	autoAssign := true
+	exportStats := true
+	loadBalancer := func(name string, ips ...string) *corev1.Service {
+		svc := &corev1.Service{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+			Spec:       corev1.ServiceSpec{Type: corev1.ServiceTypeLoadBalancer},
+		}
+		for _, ip := range ips {
+			svc.Status.LoadBalancer.Ingress = append(svc.Status.LoadBalancer.Ingress, corev1.LoadBalancerIngress{IP: ip})
+		}
+		return svc
+	}
+	objs := []client.Object{
+		&metallbv1beta1.MetalLB{
+			ObjectMeta: metav1.ObjectMeta{Name: defaultMetalLBCrName, Namespace: MetalLBTestNameSpace},
+			Spec:       metallbv1beta1.MetalLBSpec{ExportStats: &exportStats},
+		},
+		&metallbv1alpha1.AddressPool{
+			ObjectMeta: metav1.ObjectMeta{Name: "gold", Namespace: MetalLBTestNameSpace},
+			Spec: metallbv1alpha1.AddressPoolSpec{
+				Protocol:   "layer2",
+				Addresses:  []string{"10.0.0.0/30", "10.0.1.10-10.0.1.13"},
+				AutoAssign: &autoAssign,
+			},
+		},
+		&metallbv1alpha1.AddressPool{
+			ObjectMeta: metav1.ObjectMeta{Name: "silver", Namespace: MetalLBTestNameSpace},
+			Spec: metallbv1alpha1.AddressPoolSpec{
+				Protocol:   "layer2",
+				Addresses:  []string{"10.0.2.0/24"},
+				AutoAssign: &autoAssign,
+			},
+		},
+		loadBalancer("web", "10.0.0.1"),
+		loadBalancer("db", "10.0.1.12"),
+		loadBalancer("shared", "10.0.1.12"),
+		loadBalancer("other", "192.168.0.1"),
+		&corev1.Service{
+			ObjectMeta: metav1.ObjectMeta{Name: "cluster-ip", Namespace: "default"},
+			Spec:       corev1.ServiceSpec{Type: corev1.ServiceTypeClusterIP},
+			Status: corev1.ServiceStatus{LoadBalancer: corev1.LoadBalancerStatus{
+				Ingress: []corev1.LoadBalancerIngress{{IP: "10.0.2.1"}},
+			}},
+		},
+	}
+
+	reconciler := &AddressPoolReconciler{
+		Client:    newFakeClientBuilder().WithScheme(testScheme(g)).WithObjects(objs...).Build(),
+		Log:       ctrl.Log.WithName("controllers").WithName("AddressPool"),
+		Namespace: MetalLBTestNameSpace,
+	}
+	_, err := reconciler.Reconcile(context.Background(), ctrl.Request{
+		NamespacedName: types.NamespacedName{Name: "gold", Namespace: MetalLBTestNameSpace},
+	})
+	g.Expect(err).ToNot(HaveOccurred())
+
+	stats := &corev1.ConfigMap{}
+	g.Expect(reconciler.Get(context.Background(), types.NamespacedName{Name: StatsConfigMap, Namespace: MetalLBTestNameSpace}, stats)).To(Succeed())
+	g.Expect(stats.Data).To(Equal(map[string]string{
+		"gold.total":       "8",
+		"gold.used":        "2",
+		"gold.available":   "6",
+		"silver.total":     "256",
+		"silver.used":      "0",
+		"silver.available": "256",
+	}))
+}
+
+func TestAddressPoolExportStatsDisabled(t *testing.T) {
+	g := NewGomegaWithT(t)
+	manifestPath := AddressPoolManifestPath
+	AddressPoolManifestPath = "../bindata/configuration/address-pool"
+	defer func() { AddressPoolManifestPath = manifestPath }()
+
+	autoAssign := true
+	pool := &metallbv1alpha1.AddressPool{
+		ObjectMeta: metav1.ObjectMeta{Name: "gold", Namespace: MetalLBTestNameSpace},
+		Spec: metallbv1alpha1.AddressPoolSpec{
+			Protocol:   "layer2",
+			Addresses:  []string{"10.0.0.0/30"},
+			AutoAssign: &autoAssign,
+		},
+	}
+	metallb := &metallbv1beta1.MetalLB{
+		ObjectMeta: metav1.ObjectMeta{Name: defaultMetalLBCrName, Namespace: MetalLBTestNameSpace},
+	}
+
+	reconciler := &AddressPoolReconciler{
+		Client:    newFakeClientBuilder().WithScheme(testScheme(g)).WithObjects(metallb, pool).Build(),
+		Log:       ctrl.Log.WithName("controllers").WithName("AddressPool"),
+		Namespace: MetalLBTestNameSpace,
+	}
+	_, err := reconciler.Reconcile(context.Background(), ctrl.Request{
+		NamespacedName: types.NamespacedName{Name: "gold", Namespace: MetalLBTestNameSpace},
+	})
+	g.Expect(err).ToNot(HaveOccurred())
+
+	stats := &corev1.ConfigMap{}
+	err = reconciler.Get(context.Background(), types.NamespacedName{Name: StatsConfigMap, Namespace: MetalLBTestNameSpace}, stats)
+	g.Expect(apierrors.IsNotFound(err)).To(BeTrue(), "%v", err)
+}
+
+func TestAddressPoolExhausted(t *testing.T) {
+	g := NewGomegaWithT(t)
+	manifestPath := AddressPoolManifestPath
+	AddressPoolManifestPath = "../bindata/configuration/address-pool"
+	defer func() { AddressPoolManifestPath = manifestPath }()
+
+	autoAssign := true
+	pool := &metallbv1alpha1.AddressPool{
+		ObjectMeta: metav1.ObjectMeta{Name: "tiny", Namespace: MetalLBTestNameSpace},
+		Spec: metallbv1alpha1.AddressPoolSpec{
+			Protocol:   "layer2",
+			Addresses:  []string{"10.0.0.0/31"},
+			AutoAssign: &autoAssign,
+		},
+	}
+	var services []client.Object
+	for name, ip := range map[string]string{"web": "10.0.0.0", "db": "10.0.0.1"} {
+		services = append(services, &corev1.Service{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+			Spec:       corev1.ServiceSpec{Type: corev1.ServiceTypeLoadBalancer},
+			Status: corev1.ServiceStatus{LoadBalancer: corev1.LoadBalancerStatus{
+				Ingress: []corev1.LoadBalancerIngress{{IP: ip}},
+			}},
+		})
+	}
+
+	recorder := record.NewFakeRecorder(10)
+	reconciler := &AddressPoolReconciler{
+		Client:    newFakeClientBuilder().WithScheme(testScheme(g)).WithObjects(testMetalLB(metallbv1beta1.MetalLBSpec{})).WithObjects(append(services, pool)...).Build(),
+		Log:       ctrl.Log.WithName("controllers").WithName("AddressPool"),
+		Namespace: MetalLBTestNameSpace,
+		Recorder:  poolRecorder{recorder},
+	}
+	key := types.NamespacedName{Name: "tiny", Namespace: MetalLBTestNameSpace}
+	reconcile := func() *metallbv1alpha1.AddressPool {
+		_, err := reconciler.Reconcile(context.Background(), ctrl.Request{NamespacedName: key})
+		g.Expect(err).ToNot(HaveOccurred())
+		updated := &metallbv1alpha1.AddressPool{}
+		g.Expect(reconciler.Get(context.Background(), key, updated)).To(Succeed())
+		return updated
+	}
+
+	updated := reconcile()
+	exhausted := meta.FindStatusCondition(updated.Status.Conditions, status.ConditionExhausted)
+	g.Expect(exhausted).ToNot(BeNil())
+	g.Expect(exhausted.Status).To(Equal(metav1.ConditionTrue))
+	g.Expect(exhausted.Reason).To(Equal("PoolExhausted"))
+	g.Expect(exhausted.Message).To(Equal("2 of 2 addresses assigned"))
+	g.Expect(updated.Status.AllocatedAddresses).To(Equal(2))
+	g.Expect(updated.Status.TotalAddresses).To(Equal(2))
+	g.Expect(meta.IsStatusConditionTrue(updated.Status.Conditions, status.ConditionAvailable)).To(BeTrue())
+	g.Expect(recorder.Events).To(Receive(Equal("Warning PoolExhausted 2 of 2 addresses assigned")))
+
+	// The event is emitted once, when the pool becomes exhausted
+	reconcile()
+	g.Expect(recorder.Events).ToNot(Receive())
+
+	g.Expect(reconciler.Delete(context.Background(), services[0])).To(Succeed())
+	updated = reconcile()
+	g.Expect(meta.IsStatusConditionFalse(updated.Status.Conditions, status.ConditionExhausted)).To(BeTrue())
+	g.Expect(updated.Status.AllocatedAddresses).To(Equal(1))
+	g.Expect(updated.Status.TotalAddresses).To(Equal(2))
+	g.Expect(recorder.Events).ToNot(Receive())
+}
+
+func TestAddressPoolTotalAddressesCapped(t *testing.T) {
+	g := NewGomegaWithT(t)
+	manifestPath := AddressPoolManifestPath
+	AddressPoolManifestPath = "../bindata/configuration/address-pool"
+	defer func() { AddressPoolManifestPath = manifestPath }()
+
+	pool := &metallbv1alpha1.AddressPool{
+		ObjectMeta: metav1.ObjectMeta{Name: "huge", Namespace: MetalLBTestNameSpace},
+		Spec:       metallbv1alpha1.AddressPoolSpec{Protocol: "layer2", Addresses: []string{"fd00::/64"}},
+	}
+	reconciler := &AddressPoolReconciler{
+		Client:    newFakeClientBuilder().WithScheme(testScheme(g)).WithObjects(testMetalLB(metallbv1beta1.MetalLBSpec{})).WithObjects(pool).Build(),
+		Log:       ctrl.Log.WithName("controllers").WithName("AddressPool"),
+		Namespace: MetalLBTestNameSpace,
+	}
+	key := types.NamespacedName{Name: "huge", Namespace: MetalLBTestNameSpace}
+	_, err := reconciler.Reconcile(context.Background(), ctrl.Request{NamespacedName: key})
+	g.Expect(err).ToNot(HaveOccurred())
+
+	updated := &metallbv1alpha1.AddressPool{}
+	g.Expect(reconciler.Get(context.Background(), key, updated)).To(Succeed())
+	g.Expect(updated.Status.AllocatedAddresses).To(BeZero())
+	g.Expect(updated.Status.TotalAddresses).To(Equal(maxInt))
+}
@@ -0,0 +1,394 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	metallbv1alpha1 "github.com/metallb/metallb-operator/api/v1alpha1"
+	metallbv1beta1 "github.com/metallb/metallb-operator/api/v1beta1"
+	"github.com/metallb/metallb-operator/pkg/status"
+)
+
+// forbiddenClient denies every write on the given kind, as the apiserver
+// does when the operator lacks the RBAC permissions.
+type forbiddenClient struct {
+	client.Client
+	kind     string
+	resource string
+}
+
+func (c forbiddenClient) Create(ctx context.Context, obj client.Object, opts ...client.CreateOption) error {
+	if obj.GetObjectKind().GroupVersionKind().Kind == c.kind {
+		return c.forbidden("create", obj)
+	}
+	return c.Client.Create(ctx, obj, opts...)
+}
+
+func (c forbiddenClient) Update(ctx context.Context, obj client.Object, opts ...client.UpdateOption) error {
+	if obj.GetObjectKind().GroupVersionKind().Kind == c.kind {
+		return c.forbidden("update", obj)
+	}
+	return c.Client.Update(ctx, obj, opts...)
+}
+
+func (c forbiddenClient) forbidden(verb string, obj client.Object) error {
+	return apierrors.NewForbidden(schema.GroupResource{Resource: c.resource}, obj.GetName(),
+		fmt.Errorf("User \"test\" cannot %s resource %q in the namespace %q", verb, c.resource, obj.GetNamespace()))
+}
+
+func testScheme(g *WithT) *runtime.Scheme {
+	s := runtime.NewScheme()
+	g.Expect(clientgoscheme.AddToScheme(s)).To(Succeed())
+	g.Expect(metallbv1alpha1.AddToScheme(s)).To(Succeed())
+	g.Expect(metallbv1beta1.AddToScheme(s)).To(Succeed())
+	return s
+}
+
+func TestAddressPoolTooManyPools(t *testing.T) {
+	g := NewGomegaWithT(t)
+	manifestPath := AddressPoolManifestPath
+	AddressPoolManifestPath = "../bindata/configuration/address-pool"
+	defer func() { AddressPoolManifestPath = manifestPath }()
+
+	autoAssign := true
+	now := time.Now()
+	var objs []client.Object
+	for i := 1; i <= 3; i++ {
+		objs = append(objs, &metallbv1alpha1.AddressPool{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:              fmt.Sprintf("pool-%d", i),
+				Namespace:         MetalLBTestNameSpace,
+				CreationTimestamp: metav1.NewTime(now.Add(time.Duration(-i) * time.Minute)),
+			},
+			Spec: metallbv1alpha1.AddressPoolSpec{
+				Protocol:   "layer2",
+				Addresses:  []string{fmt.Sprintf("1.1.%d.1-1.1.%d.100", i, i)},
+				AutoAssign: &autoAssign,
+			},
+		})
+	}
+
+	reconciler := &AddressPoolReconciler{
+		Client:          newFakeClientBuilder().WithScheme(testScheme(g)).WithObjects(testMetalLB(metallbv1beta1.MetalLBSpec{})).WithObjects(objs...).Build(),
+		Log:             ctrl.Log.WithName("controllers").WithName("AddressPool"),
+		Namespace:       MetalLBTestNameSpace,
+		MaxAddressPools: 2,
+	}
+
+	for _, obj := range objs {
+		key := types.NamespacedName{Name: obj.GetName(), Namespace: obj.GetNamespace()}
+		_, err := reconciler.Reconcile(context.Background(), ctrl.Request{NamespacedName: key})
+		g.Expect(err).ToNot(HaveOccurred())
+	}
+
+	// pool-1 is the most recent one, so it is the one left out
+	pool := &metallbv1alpha1.AddressPool{}
+	g.Expect(reconciler.Get(context.Background(), types.NamespacedName{Name: "pool-1", Namespace: MetalLBTestNameSpace}, pool)).To(Succeed())
+	degraded := meta.FindStatusCondition(pool.Status.Conditions, status.ConditionDegraded)
+	g.Expect(degraded).ToNot(BeNil())
+	g.Expect(degraded.Status).To(Equal(metav1.ConditionTrue))
+	g.Expect(degraded.Reason).To(Equal("TooManyPools"))
+
+	for _, name := range []string{"pool-2", "pool-3"} {
+		pool := &metallbv1alpha1.AddressPool{}
+		g.Expect(reconciler.Get(context.Background(), types.NamespacedName{Name: name, Namespace: MetalLBTestNameSpace}, pool)).To(Succeed())
+		g.Expect(meta.IsStatusConditionTrue(pool.Status.Conditions, status.ConditionAvailable)).To(BeTrue())
+	}
+
+	configMap := &corev1.ConfigMap{}
+	g.Expect(reconciler.Get(context.Background(), types.NamespacedName{Name: "config", Namespace: MetalLBTestNameSpace}, configMap)).To(Succeed())
+	g.Expect(configMap.Data["config"]).To(ContainSubstring("pool-2"))
+	g.Expect(configMap.Data["config"]).To(ContainSubstring("pool-3"))
+	g.Expect(configMap.Data["config"]).ToNot(ContainSubstring("pool-1"))
+}
+
+func TestAddressPoolConflictingPool(t *testing.T) {
+	g := NewGomegaWithT(t)
+	manifestPath := AddressPoolManifestPath
+	AddressPoolManifestPath = "../bindata/configuration/address-pool"
+	defer func() { AddressPoolManifestPath = manifestPath }()
+
+	autoAssign := true
+	objs := []client.Object{
+		&metallbv1alpha1.AddressPool{
+			ObjectMeta: metav1.ObjectMeta{Name: "gold", Namespace: MetalLBTestNameSpace},
+			Spec: metallbv1alpha1.AddressPoolSpec{
+				Protocol:   "layer2",
+				Addresses:  []string{"1.1.1.0/24"},
+				AutoAssign: &autoAssign,
+			},
+		},
+		&metallbv1alpha1.AddressPool{
+			ObjectMeta: metav1.ObjectMeta{Name: "silver", Namespace: MetalLBTestNameSpace},
+			Spec: metallbv1alpha1.AddressPoolSpec{
+				Protocol:   "layer2",
+				Addresses:  []string{"1.1.1.100-1.1.1.200"},
+				AutoAssign: &autoAssign,
+			},
+		},
+	}
+
+	reconciler := &AddressPoolReconciler{
+		Client:    newFakeClientBuilder().WithScheme(testScheme(g)).WithObjects(testMetalLB(metallbv1beta1.MetalLBSpec{})).WithObjects(objs...).Build(),
+		Log:       ctrl.Log.WithName("controllers").WithName("AddressPool"),
+		Namespace: MetalLBTestNameSpace,
+	}
+	for _, obj := range objs {
+		key := types.NamespacedName{Name: obj.GetName(), Namespace: obj.GetNamespace()}
+		_, err := reconciler.Reconcile(context.Background(), ctrl.Request{NamespacedName: key})
+		g.Expect(err).ToNot(HaveOccurred())
+	}
+
+	pool := &metallbv1alpha1.AddressPool{}
+	g.Expect(reconciler.Get(context.Background(), types.NamespacedName{Name: "silver", Namespace: MetalLBTestNameSpace}, pool)).To(Succeed())
+	degraded := meta.FindStatusCondition(pool.Status.Conditions, status.ConditionDegraded)
+	g.Expect(degraded).ToNot(BeNil())
+	g.Expect(degraded.Status).To(Equal(metav1.ConditionTrue))
+	g.Expect(degraded.Reason).To(Equal("ConflictingPool"))
+	g.Expect(degraded.Message).To(ContainSubstring("overlaps"))
+
+	g.Expect(reconciler.Get(context.Background(), types.NamespacedName{Name: "gold", Namespace: MetalLBTestNameSpace}, pool)).To(Succeed())
+	g.Expect(meta.IsStatusConditionTrue(pool.Status.Conditions, status.ConditionAvailable)).To(BeTrue())
+
+	configMap := &corev1.ConfigMap{}
+	g.Expect(reconciler.Get(context.Background(), types.NamespacedName{Name: "config", Namespace: MetalLBTestNameSpace}, configMap)).To(Succeed())
+	g.Expect(configMap.Data["config"]).To(ContainSubstring("gold"))
+	g.Expect(configMap.Data["config"]).ToNot(ContainSubstring("silver"))
+}
+
+func TestAddressPoolOverlapsPodCIDR(t *testing.T) {
+	g := NewGomegaWithT(t)
+	manifestPath := AddressPoolManifestPath
+	AddressPoolManifestPath = "../bindata/configuration/address-pool"
+	defer func() { AddressPoolManifestPath = manifestPath }()
+
+	autoAssign := true
+	objs := []client.Object{
+		&corev1.Node{
+			ObjectMeta: metav1.ObjectMeta{Name: "worker-0"},
+			Spec:       corev1.NodeSpec{PodCIDR: "10.244.0.0/24", PodCIDRs: []string{"10.244.0.0/24", "fd00:10:244::/64"}},
+		},
+		&corev1.Node{
+			ObjectMeta: metav1.ObjectMeta{Name: "worker-1"},
+			Spec:       corev1.NodeSpec{PodCIDR: "10.244.1.0/24"},
+		},
+		&metallbv1alpha1.AddressPool{
+			ObjectMeta: metav1.ObjectMeta{Name: "overlapping", Namespace: MetalLBTestNameSpace},
+			Spec: metallbv1alpha1.AddressPoolSpec{
+				Protocol:   "layer2",
+				Addresses:  []string{"192.168.10.0/24", "10.244.1.100-10.244.1.150"},
+				AutoAssign: &autoAssign,
+			},
+		},
+		&metallbv1alpha1.AddressPool{
+			ObjectMeta: metav1.ObjectMeta{Name: "distinct", Namespace: MetalLBTestNameSpace},
+			Spec: metallbv1alpha1.AddressPoolSpec{
+				Protocol:   "layer2",
+				Addresses:  []string{"10.245.0.0/24"},
+				AutoAssign: &autoAssign,
+			},
+		},
+	}
+
+	reconciler := &AddressPoolReconciler{
+		Client:       newFakeClientBuilder().WithScheme(testScheme(g)).WithObjects(testMetalLB(metallbv1beta1.MetalLBSpec{})).WithObjects(objs...).Build(),
+		Log:          ctrl.Log.WithName("controllers").WithName("AddressPool"),
+		Namespace:    MetalLBTestNameSpace,
+		CheckPodCIDR: true,
+	}
+	for _, name := range []string{"overlapping", "distinct"} {
+		key := types.NamespacedName{Name: name, Namespace: MetalLBTestNameSpace}
+		_, err := reconciler.Reconcile(context.Background(), ctrl.Request{NamespacedName: key})
+		g.Expect(err).ToNot(HaveOccurred())
+	}
+
+	pool := &metallbv1alpha1.AddressPool{}
+	g.Expect(reconciler.Get(context.Background(), types.NamespacedName{Name: "overlapping", Namespace: MetalLBTestNameSpace}, pool)).To(Succeed())
+	degraded := meta.FindStatusCondition(pool.Status.Conditions, status.ConditionDegraded)
+	g.Expect(degraded).ToNot(BeNil())
+	g.Expect(degraded.Status).To(Equal(metav1.ConditionTrue))
+	g.Expect(degraded.Reason).To(Equal("PoolOverlapsPodCIDR"))
+	g.Expect(degraded.Message).To(Equal("Range 10.244.1.100-10.244.1.150 overlaps with the pod CIDR 10.244.1.0/24 of node worker-1"))
+
+	g.Expect(reconciler.Get(context.Background(), types.NamespacedName{Name: "distinct", Namespace: MetalLBTestNameSpace}, pool)).To(Succeed())
+	g.Expect(meta.IsStatusConditionTrue(pool.Status.Conditions, status.ConditionAvailable)).To(BeTrue())
+}
+
+func TestAddressPoolAllocationStrategy(t *testing.T) {
+	g := NewGomegaWithT(t)
+	manifestPath := AddressPoolManifestPath
+	AddressPoolManifestPath = "../bindata/configuration/address-pool"
+	defer func() { AddressPoolManifestPath = manifestPath }()
+
+	autoAssign := true
+	objs := []client.Object{}
+	for i, strategy := range []string{"", metallbv1alpha1.AllocationSequential, metallbv1alpha1.AllocationRandom} {
+		objs = append(objs, &metallbv1alpha1.AddressPool{
+			ObjectMeta: metav1.ObjectMeta{Name: fmt.Sprintf("pool-%d", i), Namespace: MetalLBTestNameSpace},
+			Spec: metallbv1alpha1.AddressPoolSpec{
+				Protocol:           "layer2",
+				Addresses:          []string{fmt.Sprintf("1.1.%d.0/24", i)},
+				AutoAssign:         &autoAssign,
+				AllocationStrategy: strategy,
+			},
+		})
+	}
+
+	reconciler := &AddressPoolReconciler{
+		Client:    newFakeClientBuilder().WithScheme(testScheme(g)).WithObjects(testMetalLB(metallbv1beta1.MetalLBSpec{})).WithObjects(objs...).Build(),
+		Log:       ctrl.Log.WithName("controllers").WithName("AddressPool"),
+		Namespace: MetalLBTestNameSpace,
+	}
+	conditions := map[string]*metav1.Condition{}
+	for _, obj := range objs {
+		key := types.NamespacedName{Name: obj.GetName(), Namespace: obj.GetNamespace()}
+		_, err := reconciler.Reconcile(context.Background(), ctrl.Request{NamespacedName: key})
+		g.Expect(err).ToNot(HaveOccurred())
+
+		pool := &metallbv1alpha1.AddressPool{}
+		g.Expect(reconciler.Get(context.Background(), key, pool)).To(Succeed())
+		g.Expect(meta.IsStatusConditionTrue(pool.Status.Conditions, status.ConditionAvailable)).To(BeTrue())
+		conditions[pool.Name] = meta.FindStatusCondition(pool.Status.Conditions, status.ConditionAllocationStrategy)
+	}
+
+	g.Expect(conditions["pool-0"]).To(BeNil())
+	g.Expect(conditions["pool-1"].Status).To(Equal(metav1.ConditionTrue))
+	g.Expect(conditions["pool-2"].Status).To(Equal(metav1.ConditionFalse))
+	g.Expect(conditions["pool-2"].Reason).To(Equal("UnsupportedAllocationStrategy"))
+
+	// The strategy is not rendered into the MetalLB configuration
+	configMap := &corev1.ConfigMap{}
+	g.Expect(reconciler.Get(context.Background(), types.NamespacedName{Name: "config", Namespace: MetalLBTestNameSpace}, configMap)).To(Succeed())
+	g.Expect(configMap.Data["config"]).To(MatchYAML(`address-pools:
+- name: pool-0
+  protocol: layer2
+  addresses:
+  - 1.1.0.0/24
+- name: pool-1
+  protocol: layer2
+  addresses:
+  - 1.1.1.0/24
+- name: pool-2
+  protocol: layer2
+  addresses:
+  - 1.1.2.0/24
+`))
+}
+
+func TestAddressPoolAutoAssignDefault(t *testing.T) {
+	g := NewGomegaWithT(t)
+	manifestPath := AddressPoolManifestPath
+	AddressPoolManifestPath = "../bindata/configuration/address-pool"
+	defer func() { AddressPoolManifestPath = manifestPath }()
+
+	// The fake client does not apply the CRD default, autoAssign stays unset
+	noAutoAssign := false
+	objs := []client.Object{
+		&metallbv1alpha1.AddressPool{
+			ObjectMeta: metav1.ObjectMeta{Name: "unset", Namespace: MetalLBTestNameSpace},
+			Spec: metallbv1alpha1.AddressPoolSpec{
+				Protocol:  "layer2",
+				Addresses: []string{"1.1.1.0/24"},
+			},
+		},
+		&metallbv1alpha1.AddressPool{
+			ObjectMeta: metav1.ObjectMeta{Name: "manual", Namespace: MetalLBTestNameSpace},
+			Spec: metallbv1alpha1.AddressPoolSpec{
+				Protocol:   "layer2",
+				Addresses:  []string{"1.1.2.0/24"},
+				AutoAssign: &noAutoAssign,
+			},
+		},
+	}
+
+	reconciler := &AddressPoolReconciler{
+		Client:    newFakeClientBuilder().WithScheme(testScheme(g)).WithObjects(testMetalLB(metallbv1beta1.MetalLBSpec{})).WithObjects(objs...).Build(),
+		Log:       ctrl.Log.WithName("controllers").WithName("AddressPool"),
+		Namespace: MetalLBTestNameSpace,
+	}
+	for _, obj := range objs {
+		key := types.NamespacedName{Name: obj.GetName(), Namespace: obj.GetNamespace()}
+		_, err := reconciler.Reconcile(context.Background(), ctrl.Request{NamespacedName: key})
+		g.Expect(err).ToNot(HaveOccurred())
+	}
+
+	configMap := &corev1.ConfigMap{}
+	g.Expect(reconciler.Get(context.Background(), types.NamespacedName{Name: "config", Namespace: MetalLBTestNameSpace}, configMap)).To(Succeed())
+	g.Expect(configMap.Data["config"]).To(MatchYAML(`address-pools:
+- name: manual
+  protocol: layer2
+  addresses:
+  - 1.1.2.0/24
+  auto-assign: false
+- name: unset
+  protocol: layer2
+  addresses:
+  - 1.1.1.0/24
+`))
+}
+
+func TestAddressPoolInvalid(t *testing.T) {
+	g := NewGomegaWithT(t)
+	manifestPath := AddressPoolManifestPath
+	AddressPoolManifestPath = "../bindata/configuration/address-pool"
+	defer func() { AddressPoolManifestPath = manifestPath }()
+
+	// Created bypassing the webhook
+	objs := []client.Object{
+		&metallbv1alpha1.AddressPool{
+			ObjectMeta: metav1.ObjectMeta{Name: "gold", Namespace: MetalLBTestNameSpace},
+			Spec:       metallbv1alpha1.AddressPoolSpec{Protocol: "layer2", Addresses: []string{"1.1.1.0/24"}},
+		},
+		&metallbv1alpha1.AddressPool{
+			ObjectMeta: metav1.ObjectMeta{Name: "silver", Namespace: MetalLBTestNameSpace},
+			Spec:       metallbv1alpha1.AddressPoolSpec{Name: "Silver Pool", Protocol: "layer2", Addresses: []string{"1.1.2.0/24"}},
+		},
+		&metallbv1alpha1.AddressPool{
+			ObjectMeta: metav1.ObjectMeta{Name: "bronze", Namespace: MetalLBTestNameSpace},
+			Spec:       metallbv1alpha1.AddressPoolSpec{Protocol: "layer3", Addresses: []string{"1.1.3.0/24"}},
+		},
+	}
+	reconciler := &AddressPoolReconciler{
+		Client:    newFakeClientBuilder().WithScheme(testScheme(g)).WithObjects(testMetalLB(metallbv1beta1.MetalLBSpec{})).WithObjects(objs...).Build(),
+		Log:       ctrl.Log.WithName("controllers").WithName("AddressPool"),
+		Namespace: MetalLBTestNameSpace,
+	}
+	for _, obj := range objs {
+		key := types.NamespacedName{Name: obj.GetName(), Namespace: obj.GetNamespace()}
+		_, err := reconciler.Reconcile(context.Background(), ctrl.Request{NamespacedName: key})
+		g.Expect(err).ToNot(HaveOccurred())
+	}
+
+	for _, name := range []string{"silver", "bronze"} {
+		pool := &metallbv1alpha1.AddressPool{}
+		g.Expect(reconciler.Get(context.Background(), types.NamespacedName{Name: name, Namespace: MetalLBTestNameSpace}, pool)).To(Succeed())
+		degraded := meta.FindStatusCondition(pool.Status.Conditions, status.ConditionDegraded)
+		g.Expect(degraded).ToNot(BeNil(), name)
+		g.Expect(degraded.Status).To(Equal(metav1.ConditionTrue))
+		g.Expect(degraded.Reason).To(Equal("InvalidPool"))
+		g.Expect(degraded.Message).To(Equal(pool.Validate().Error()))
+	}
+
+	configMap := &corev1.ConfigMap{}
+	g.Expect(reconciler.Get(context.Background(), types.NamespacedName{Name: "config", Namespace: MetalLBTestNameSpace}, configMap)).To(Succeed())
+	g.Expect(configMap.Data["config"]).To(MatchYAML(`address-pools:
+- name: gold
+  protocol: layer2
+  addresses:
+  - 1.1.1.0/24
+`))
+}
@@ -0,0 +1,67 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	metallbv1alpha1 "github.com/metallb/metallb-operator/api/v1alpha1"
+	metallbv1beta1 "github.com/metallb/metallb-operator/api/v1beta1"
+	"github.com/metallb/metallb-operator/pkg/status"
+)
+
+// waitingForMetalLBReason is the reason of the Degraded condition of the
+// AddressPools while there is no MetalLB instance to consume them.
+const waitingForMetalLBReason = "WaitingForMetalLB"
+
+// metalLBRunning returns whether the MetalLB instance of the operator namespace
+// exists and is not being deleted. The MetalLB ConfigMaps are only rendered
+// then, as no speaker consumes them otherwise.
+func (r *AddressPoolReconciler) metalLBRunning(ctx context.Context) (bool, error) {
+	metallb := &metallbv1beta1.MetalLB{}
+	err := r.Get(ctx, types.NamespacedName{Name: defaultMetalLBCrName, Namespace: r.Namespace}, metallb)
+	if errors.IsNotFound(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("Failed to get MetalLB resource %w", err)
+	}
+	return metallb.DeletionTimestamp == nil, nil
+}
+
+// waitForMetalLB marks the pool degraded until a MetalLB instance is created,
+// emitting a MetalLBNotFound event on the pool when it starts waiting.
+func (r *AddressPoolReconciler) waitForMetalLB(ctx context.Context, pool *metallbv1alpha1.AddressPool) error {
+	degraded := meta.FindStatusCondition(pool.Status.Conditions, status.ConditionDegraded)
+	waiting := degraded != nil && degraded.Status == metav1.ConditionTrue && degraded.Reason == waitingForMetalLBReason
+
+	message := fmt.Sprintf("No MetalLB instance %s/%s, the pool is rendered once it is created", r.Namespace, defaultMetalLBCrName)
+	if err := status.UpdateAddressPool(ctx, r.Client, pool, status.ConditionDegraded, waitingForMetalLBReason, message); err != nil {
+		return err
+	}
+	if !waiting && r.Recorder != nil {
+		r.Recorder.Event(pool, corev1.EventTypeWarning, "MetalLBNotFound", message)
+	}
+	return nil
+}
@@ -0,0 +1,71 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	metallbv1alpha1 "github.com/metallb/metallb-operator/api/v1alpha1"
+	metallbv1beta1 "github.com/metallb/metallb-operator/api/v1beta1"
+	"github.com/metallb/metallb-operator/pkg/apply"
+	"github.com/metallb/metallb-operator/pkg/status"
+)
+
+func TestAddressPoolWaitsForMetalLB(t *testing.T) {
+	g := NewGomegaWithT(t)
+	manifestPath := AddressPoolManifestPath
+	AddressPoolManifestPath = "../bindata/configuration/address-pool"
+	defer func() { AddressPoolManifestPath = manifestPath }()
+
+	pool := &metallbv1alpha1.AddressPool{
+		ObjectMeta: metav1.ObjectMeta{Name: "gold", Namespace: MetalLBTestNameSpace},
+		Spec:       metallbv1alpha1.AddressPoolSpec{Protocol: "layer2", Addresses: []string{"10.0.0.0/24"}},
+	}
+	c := newFakeClientBuilder().WithScheme(testScheme(g)).WithObjects(pool).Build()
+	recorder := record.NewFakeRecorder(10)
+	reconciler := &AddressPoolReconciler{
+		Client:    c,
+		Log:       ctrl.Log.WithName("controllers").WithName("AddressPool"),
+		Namespace: MetalLBTestNameSpace,
+		Recorder:  poolRecorder{recorder},
+	}
+	key := types.NamespacedName{Name: pool.Name, Namespace: pool.Namespace}
+	reconcile := func() *metallbv1alpha1.AddressPool {
+		_, err := reconciler.Reconcile(context.Background(), ctrl.Request{NamespacedName: key})
+		g.Expect(err).ToNot(HaveOccurred())
+		updated := &metallbv1alpha1.AddressPool{}
+		g.Expect(c.Get(context.Background(), key, updated)).To(Succeed())
+		return updated
+	}
+	configMapKey := types.NamespacedName{Name: apply.AddressPoolConfigMap, Namespace: MetalLBTestNameSpace}
+
+	// Without a MetalLB resource, the pool waits and nothing is rendered
+	updated := reconcile()
+	degraded := meta.FindStatusCondition(updated.Status.Conditions, status.ConditionDegraded)
+	g.Expect(degraded).ToNot(BeNil())
+	g.Expect(degraded.Status).To(Equal(metav1.ConditionTrue))
+	g.Expect(degraded.Reason).To(Equal("WaitingForMetalLB"))
+	g.Expect(recorder.Events).To(Receive(ContainSubstring("Warning MetalLBNotFound")))
+	g.Expect(errors.IsNotFound(c.Get(context.Background(), configMapKey, &corev1.ConfigMap{}))).To(BeTrue())
+
+	// The event is emitted once, when the pool starts waiting
+	reconcile()
+	g.Expect(recorder.Events).ToNot(Receive())
+
+	// Once the MetalLB resource exists, the pool is rendered
+	g.Expect(c.Create(context.Background(), testMetalLB(metallbv1beta1.MetalLBSpec{}))).To(Succeed())
+	updated = reconcile()
+	g.Expect(meta.IsStatusConditionTrue(updated.Status.Conditions, status.ConditionDegraded)).To(BeFalse())
+	g.Expect(meta.IsStatusConditionTrue(updated.Status.Conditions, status.ConditionAvailable)).To(BeTrue())
+	configMap := &corev1.ConfigMap{}
+	g.Expect(c.Get(context.Background(), configMapKey, configMap)).To(Succeed())
+	g.Expect(configMap.Data[apply.AddressPoolConfigMap]).To(ContainSubstring("gold"))
+}
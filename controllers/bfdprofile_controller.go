@@ -0,0 +1,83 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/go-logr/logr"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	metallbv1alpha1 "github.com/metallb/metallb-operator/api/v1alpha1"
+	"github.com/metallb/metallb-operator/pkg/status"
+)
+
+// BFDProfileReconciler renders the BFDProfiles of the operator namespace into
+// the bfd-profiles of the MetalLB ConfigMap, referenced by the BGPPeers.
+type BFDProfileReconciler struct {
+	client.Client
+	Log       logr.Logger
+	Scheme    *runtime.Scheme
+	Namespace string
+	// Pools renders and applies the MetalLB ConfigMaps
+	Pools *AddressPoolReconciler
+}
+
+// +kubebuilder:rbac:groups=metallb.io,resources=bfdprofiles,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=metallb.io,resources=bfdprofiles/status,verbs=get;update;patch
+
+func (r *BFDProfileReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	r.Log.Info(fmt.Sprintf("Starting BFDProfile reconcile loop for %v", req.NamespacedName))
+	defer r.Log.Info(fmt.Sprintf("Finish BFDProfile reconcile loop for %v", req.NamespacedName))
+
+	if req.Namespace != r.Namespace {
+		r.Log.Info(fmt.Sprintf("Ignoring BFDProfile %v outside of the operator namespace", req.NamespacedName))
+		return ctrl.Result{}, nil
+	}
+	instance := &metallbv1alpha1.BFDProfile{}
+	if err := r.Get(ctx, req.NamespacedName, instance); err != nil {
+		if errors.IsNotFound(err) {
+			status.ForgetDegraded("BFDProfile", req.Namespace, req.Name)
+			return ctrl.Result{}, r.Pools.syncBGPConfig(req)
+		}
+		return ctrl.Result{}, err
+	}
+
+	if err := r.Pools.syncBGPConfig(req); err != nil {
+		r.Log.Info(fmt.Sprintf("sync MetalLB bfdprofile failed %s", err))
+		if errors.IsForbidden(err) {
+			if err := status.UpdateBFDProfile(ctx, r.Client, instance, status.ConditionDegraded, "InsufficientPermissions", apiErrorMessage(err)); err != nil {
+				r.Log.Info(fmt.Sprintf("Failed to update bfdprofile status %s", err))
+			}
+		}
+		return ctrl.Result{RequeueAfter: RetryPeriod}, err
+	}
+	if err := instance.Validate(); err != nil {
+		return ctrl.Result{}, status.UpdateBFDProfile(ctx, r.Client, instance, status.ConditionDegraded, "InvalidProfile", err.Error())
+	}
+	return ctrl.Result{}, status.UpdateBFDProfile(ctx, r.Client, instance, status.ConditionAvailable, "", "")
+}
+
+func (r *BFDProfileReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&metallbv1alpha1.BFDProfile{}).
+		Complete(withReconcileMetrics("bfdprofile", r))
+}
@@ -0,0 +1,133 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	metallbv1alpha1 "github.com/metallb/metallb-operator/api/v1alpha1"
+	metallbv1beta1 "github.com/metallb/metallb-operator/api/v1beta1"
+	"github.com/metallb/metallb-operator/pkg/status"
+	"github.com/metallb/metallb-operator/test/manifests"
+)
+
+func TestBFDProfileLifecycle(t *testing.T) {
+	g := NewGomegaWithT(t)
+	manifestPath := AddressPoolManifestPath
+	AddressPoolManifestPath = "../bindata/configuration/address-pool"
+	defer func() { AddressPoolManifestPath = manifestPath }()
+
+	c := newFakeClientBuilder().WithScheme(testScheme(g)).WithObjects(testMetalLB(metallbv1beta1.MetalLBSpec{})).Build()
+	pools := &AddressPoolReconciler{
+		Client:    c,
+		Log:       ctrl.Log.WithName("controllers").WithName("AddressPool"),
+		Namespace: MetalLBTestNameSpace,
+	}
+	peers := &BGPPeerReconciler{
+		Client:    c,
+		Log:       ctrl.Log.WithName("controllers").WithName("BGPPeer"),
+		Namespace: MetalLBTestNameSpace,
+		Pools:     pools,
+	}
+	profiles := &BFDProfileReconciler{
+		Client:    c,
+		Log:       ctrl.Log.WithName("controllers").WithName("BFDProfile"),
+		Namespace: MetalLBTestNameSpace,
+		Pools:     pools,
+	}
+	ctx := context.Background()
+	key := func(name string) types.NamespacedName {
+		return types.NamespacedName{Name: name, Namespace: MetalLBTestNameSpace}
+	}
+	reconcileObject := func(r reconcile.Reconciler, name string) {
+		_, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: key(name)})
+		g.Expect(err).ToNot(HaveOccurred())
+	}
+	config := func() string {
+		configMap := &corev1.ConfigMap{}
+		g.Expect(c.Get(ctx, key("config"), configMap)).To(Succeed())
+		g.Expect(manifests.ValidateMetalLBConfig(configMap.Data["config"])).To(Succeed())
+		return configMap.Data["config"]
+	}
+	degradedReason := func(conditions []metav1.Condition) string {
+		degraded := meta.FindStatusCondition(conditions, status.ConditionDegraded)
+		g.Expect(degraded).ToNot(BeNil())
+		if degraded.Status != metav1.ConditionTrue {
+			return ""
+		}
+		return degraded.Reason
+	}
+
+	// A peer referencing a missing profile is left out
+	g.Expect(c.Create(ctx, &metallbv1alpha1.BGPPeer{
+		ObjectMeta: metav1.ObjectMeta{Name: "tor", Namespace: MetalLBTestNameSpace},
+		Spec: metallbv1alpha1.BGPPeerSpec{
+			MyASN:       64512,
+			PeerASN:     64513,
+			PeerAddress: "10.0.0.1",
+			BFDProfile:  "fast",
+		},
+	})).To(Succeed())
+	reconcileObject(peers, "tor")
+	g.Expect(config()).To(MatchYAML(`address-pools:
+`))
+	peer := &metallbv1alpha1.BGPPeer{}
+	g.Expect(c.Get(ctx, key("tor"), peer)).To(Succeed())
+	g.Expect(degradedReason(peer.Status.Conditions)).To(Equal("BFDProfileNotFound"))
+
+	// Once the profile exists, the peer is rendered along with it
+	receiveInterval, multiplier := uint32(300), uint32(3)
+	profile := &metallbv1alpha1.BFDProfile{
+		ObjectMeta: metav1.ObjectMeta{Name: "fast", Namespace: MetalLBTestNameSpace},
+		Spec: metallbv1alpha1.BFDProfileSpec{
+			ReceiveInterval:  &receiveInterval,
+			DetectMultiplier: &multiplier,
+			EchoMode:         true,
+		},
+	}
+	g.Expect(c.Create(ctx, profile)).To(Succeed())
+	reconcileObject(profiles, "fast")
+	g.Expect(config()).To(MatchYAML(`peers:
+- my-asn: 64512
+  peer-asn: 64513
+  peer-address: 10.0.0.1
+  bfd-profile: fast
+bfd-profiles:
+- name: fast
+  receive-interval: 300
+  detect-multiplier: 3
+  echo-mode: true
+address-pools: []
+`))
+	g.Expect(peers.bfdProfilePeers(profile)).To(ConsistOf(reconcile.Request{NamespacedName: key("tor")}))
+	reconcileObject(peers, "tor")
+	peer = &metallbv1alpha1.BGPPeer{}
+	g.Expect(c.Get(ctx, key("tor"), peer)).To(Succeed())
+	g.Expect(degradedReason(peer.Status.Conditions)).To(BeEmpty())
+	g.Expect(meta.IsStatusConditionTrue(peer.Status.Conditions, status.ConditionAvailable)).To(BeTrue())
+
+	// An interval out of the range accepted by MetalLB degrades the profile,
+	// which is left out with the peers referencing it
+	profile = &metallbv1alpha1.BFDProfile{}
+	g.Expect(c.Get(ctx, key("fast"), profile)).To(Succeed())
+	receiveInterval = 5
+	profile.Spec.ReceiveInterval = &receiveInterval
+	g.Expect(c.Update(ctx, profile)).To(Succeed())
+	reconcileObject(profiles, "fast")
+	g.Expect(config()).To(MatchYAML(`address-pools: []
+`))
+	profile = &metallbv1alpha1.BFDProfile{}
+	g.Expect(c.Get(ctx, key("fast"), profile)).To(Succeed())
+	g.Expect(degradedReason(profile.Status.Conditions)).To(Equal("InvalidProfile"))
+	reconcileObject(peers, "tor")
+	peer = &metallbv1alpha1.BGPPeer{}
+	g.Expect(c.Get(ctx, key("tor"), peer)).To(Succeed())
+	g.Expect(degradedReason(peer.Status.Conditions)).To(Equal("BFDProfileNotFound"))
+}
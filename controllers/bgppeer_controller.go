@@ -0,0 +1,254 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	goerrors "errors"
+	"fmt"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	metallbv1alpha1 "github.com/metallb/metallb-operator/api/v1alpha1"
+	metallbv1beta1 "github.com/metallb/metallb-operator/api/v1beta1"
+	"github.com/metallb/metallb-operator/pkg/render"
+	"github.com/metallb/metallb-operator/pkg/status"
+)
+
+// BGPPeerReconciler renders the BGPPeers of the operator namespace into the
+// peers of the MetalLB ConfigMap, next to the address pools.
+type BGPPeerReconciler struct {
+	client.Client
+	Log       logr.Logger
+	Scheme    *runtime.Scheme
+	Namespace string
+	// Pools renders and applies the MetalLB ConfigMaps, holding both the
+	// AddressPools and the BGPPeers.
+	Pools *AddressPoolReconciler
+}
+
+// +kubebuilder:rbac:groups=metallb.io,resources=bgppeers,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=metallb.io,resources=bgppeers/status,verbs=get;update;patch
+
+func (r *BGPPeerReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	r.Log.Info(fmt.Sprintf("Starting BGPPeer reconcile loop for %v", req.NamespacedName))
+	defer r.Log.Info(fmt.Sprintf("Finish BGPPeer reconcile loop for %v", req.NamespacedName))
+
+	if req.Namespace != r.Namespace {
+		r.Log.Info(fmt.Sprintf("Ignoring BGPPeer %v outside of the operator namespace", req.NamespacedName))
+		return ctrl.Result{}, nil
+	}
+	instance := &metallbv1alpha1.BGPPeer{}
+	if err := r.Get(ctx, req.NamespacedName, instance); err != nil {
+		if errors.IsNotFound(err) {
+			status.ForgetDegraded("BGPPeer", req.Namespace, req.Name)
+			return ctrl.Result{}, r.Pools.syncBGPConfig(req)
+		}
+		return ctrl.Result{}, err
+	}
+
+	if err := r.Pools.syncBGPConfig(req); err != nil {
+		r.Log.Info(fmt.Sprintf("sync MetalLB bgppeer failed %s", err))
+		if errors.IsForbidden(err) {
+			if err := status.UpdateBGPPeer(ctx, r.Client, instance, status.ConditionDegraded, "InsufficientPermissions", apiErrorMessage(err)); err != nil {
+				r.Log.Info(fmt.Sprintf("Failed to update bgppeer status %s", err))
+			}
+		}
+		return ctrl.Result{RequeueAfter: RetryPeriod}, err
+	}
+	if err := instance.Validate(); err != nil {
+		return ctrl.Result{}, status.UpdateBGPPeer(ctx, r.Client, instance, status.ConditionDegraded, "InvalidPeer", err.Error())
+	}
+	bgpBackend, err := r.Pools.bgpBackend()
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	if err := instance.ValidateBackend(bgpBackend); err != nil {
+		return ctrl.Result{}, status.UpdateBGPPeer(ctx, r.Client, instance, status.ConditionDegraded, "InvalidPeer", err.Error())
+	}
+	if message, err := r.checkBFDProfile(ctx, instance); err != nil || message != "" {
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+		return ctrl.Result{}, status.UpdateBGPPeer(ctx, r.Client, instance, status.ConditionDegraded, "BFDProfileNotFound", message)
+	}
+	if message, err := r.checkPasswordSecret(ctx, instance); err != nil || message != "" {
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+		return ctrl.Result{}, status.UpdateBGPPeer(ctx, r.Client, instance, status.ConditionDegraded, "PasswordSecretNotFound", message)
+	}
+	if message, err := r.checkLocalASN(instance, bgpBackend); err != nil || message != "" {
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+		return ctrl.Result{}, status.UpdateBGPPeer(ctx, r.Client, instance, status.ConditionDegraded, "InconsistentASN", message)
+	}
+	return ctrl.Result{}, status.UpdateBGPPeer(ctx, r.Client, instance, status.ConditionAvailable, "", "")
+}
+
+// checkBFDProfile returns why the BFD profile of the peer is not part of the
+// MetalLB configuration, if it is not.
+func (r *BGPPeerReconciler) checkBFDProfile(ctx context.Context, peer *metallbv1alpha1.BGPPeer) (string, error) {
+	if peer.Spec.BFDProfile == "" {
+		return "", nil
+	}
+	profile := &metallbv1alpha1.BFDProfile{}
+	err := r.Get(ctx, types.NamespacedName{Name: peer.Spec.BFDProfile, Namespace: r.Namespace}, profile)
+	if errors.IsNotFound(err) {
+		return fmt.Sprintf("BFDProfile %s not found", peer.Spec.BFDProfile), nil
+	}
+	if err != nil {
+		return "", err
+	}
+	if err := profile.Validate(); err != nil {
+		return fmt.Sprintf("BFDProfile %s is invalid", peer.Spec.BFDProfile), nil
+	}
+	return "", nil
+}
+
+// checkPasswordSecret returns why the password of the peer is not part of the
+// MetalLB configuration, if it is not.
+func (r *BGPPeerReconciler) checkPasswordSecret(ctx context.Context, peer *metallbv1alpha1.BGPPeer) (string, error) {
+	if peer.Spec.PasswordSecret == nil {
+		return "", nil
+	}
+	name := peer.Spec.PasswordSecret.Name
+	secret := &corev1.Secret{}
+	err := r.Get(ctx, types.NamespacedName{Name: name, Namespace: peer.Namespace}, secret)
+	if errors.IsNotFound(err) {
+		return fmt.Sprintf("Secret %s not found", name), nil
+	}
+	if err != nil {
+		return "", err
+	}
+	if _, ok := secret.Data[metallbv1alpha1.PasswordSecretKey]; !ok {
+		return fmt.Sprintf("Secret %s has no %s key", name, metallbv1alpha1.PasswordSecretKey), nil
+	}
+	return "", nil
+}
+
+// checkLocalASN returns why the peer is left out of the MetalLB configuration
+// for using another local AS number than the other peers with the frr BGP
+// backend, if it is. The peers are merged the way they are rendered, so the
+// first one in canonical order sets the local AS number.
+func (r *BGPPeerReconciler) checkLocalASN(peer *metallbv1alpha1.BGPPeer, bgpBackend string) (string, error) {
+	if bgpBackend != metallbv1beta1.BGPBackendFRR {
+		return "", nil
+	}
+	peers, err := r.Pools.listBGPPeers()
+	if err != nil {
+		return "", fmt.Errorf("Failed to get existing bgppeer objects %w", err)
+	}
+	profiles, err := r.Pools.listBFDProfiles()
+	if err != nil {
+		return "", fmt.Errorf("Failed to get existing bfdprofile objects %w", err)
+	}
+	passwords, err := r.Pools.peerPasswords(peers)
+	if err != nil {
+		return "", err
+	}
+	profileConfigs, _ := render.MergeBFDProfiles(profiles)
+	_, errs := render.MergePeers(peers, profileConfigs, passwords, bgpBackend)
+	for _, err := range errs {
+		var peerErr *render.PeerError
+		if goerrors.As(err, &peerErr) && peerErr.Namespace == peer.Namespace && peerErr.Name == peer.Name &&
+			goerrors.Is(err, render.ErrInconsistentASN) {
+			return peerErr.Err.Error(), nil
+		}
+	}
+	return "", nil
+}
+
+// bfdProfilePeers maps a change of a BFDProfile to the BGPPeers referencing it.
+func (r *BGPPeerReconciler) bfdProfilePeers(obj client.Object) []reconcile.Request {
+	peers, err := r.Pools.listBGPPeers()
+	if err != nil {
+		r.Log.Info(fmt.Sprintf("Failed to get existing bgppeer objects %s", err))
+		return nil
+	}
+	requests := []reconcile.Request{}
+	for _, peer := range peers {
+		if obj.GetNamespace() == peer.Namespace && peer.Spec.BFDProfile == obj.GetName() {
+			requests = append(requests, reconcile.Request{
+				NamespacedName: types.NamespacedName{Name: peer.Name, Namespace: peer.Namespace},
+			})
+		}
+	}
+	return requests
+}
+
+// secretPeers maps a change of a Secret, e.g. a rotated password, to the
+// BGPPeers referencing it as their PasswordSecret.
+func (r *BGPPeerReconciler) secretPeers(obj client.Object) []reconcile.Request {
+	if obj.GetNamespace() != r.Namespace {
+		return nil
+	}
+	peers, err := r.Pools.listBGPPeers()
+	if err != nil {
+		r.Log.Info(fmt.Sprintf("Failed to get existing bgppeer objects %s", err))
+		return nil
+	}
+	requests := []reconcile.Request{}
+	for _, peer := range peers {
+		if peer.Spec.PasswordSecret != nil && peer.Spec.PasswordSecret.Name == obj.GetName() {
+			requests = append(requests, reconcile.Request{
+				NamespacedName: types.NamespacedName{Name: peer.Name, Namespace: peer.Namespace},
+			})
+		}
+	}
+	return requests
+}
+
+// allPeers maps a change of the MetalLB resource, e.g. of its BGP backend, or
+// of a BGPPeer, e.g. of its local AS number, to all the BGPPeers.
+func (r *BGPPeerReconciler) allPeers(obj client.Object) []reconcile.Request {
+	if obj.GetNamespace() != r.Namespace {
+		return nil
+	}
+	peers, err := r.Pools.listBGPPeers()
+	if err != nil {
+		r.Log.Info(fmt.Sprintf("Failed to get existing bgppeer objects %s", err))
+		return nil
+	}
+	requests := []reconcile.Request{}
+	for _, peer := range peers {
+		requests = append(requests, reconcile.Request{
+			NamespacedName: types.NamespacedName{Name: peer.Name, Namespace: peer.Namespace},
+		})
+	}
+	return requests
+}
+
+func (r *BGPPeerReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&metallbv1alpha1.BGPPeer{}).
+		Watches(&source.Kind{Type: &metallbv1alpha1.BFDProfile{}}, handler.EnqueueRequestsFromMapFunc(r.bfdProfilePeers)).
+		Watches(&source.Kind{Type: &corev1.Secret{}}, handler.EnqueueRequestsFromMapFunc(r.secretPeers)).
+		Watches(&source.Kind{Type: &metallbv1beta1.MetalLB{}}, handler.EnqueueRequestsFromMapFunc(r.allPeers)).
+		Watches(&source.Kind{Type: &metallbv1alpha1.BGPPeer{}}, handler.EnqueueRequestsFromMapFunc(r.allPeers)).
+		Complete(withReconcileMetrics("bgppeer", r))
+}
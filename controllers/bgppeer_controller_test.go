@@ -0,0 +1,463 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+	"gopkg.in/yaml.v2"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	metallbv1alpha1 "github.com/metallb/metallb-operator/api/v1alpha1"
+	metallbv1beta1 "github.com/metallb/metallb-operator/api/v1beta1"
+	"github.com/metallb/metallb-operator/pkg/status"
+	"github.com/metallb/metallb-operator/test/manifests"
+)
+
+func TestBGPPeerLifecycle(t *testing.T) {
+	g := NewGomegaWithT(t)
+	manifestPath := AddressPoolManifestPath
+	AddressPoolManifestPath = "../bindata/configuration/address-pool"
+	defer func() { AddressPoolManifestPath = manifestPath }()
+
+	c := newFakeClientBuilder().WithScheme(testScheme(g)).WithObjects(testMetalLB(metallbv1beta1.MetalLBSpec{})).Build()
+	pools := &AddressPoolReconciler{
+		Client:    c,
+		Log:       ctrl.Log.WithName("controllers").WithName("AddressPool"),
+		Namespace: MetalLBTestNameSpace,
+	}
+	peers := &BGPPeerReconciler{
+		Client:    c,
+		Log:       ctrl.Log.WithName("controllers").WithName("BGPPeer"),
+		Namespace: MetalLBTestNameSpace,
+		Pools:     pools,
+	}
+	ctx := context.Background()
+	reconcilePeer := func(name string) {
+		_, err := peers.Reconcile(ctx, ctrl.Request{NamespacedName: types.NamespacedName{Name: name, Namespace: MetalLBTestNameSpace}})
+		g.Expect(err).ToNot(HaveOccurred())
+	}
+	config := func() string {
+		configMap := &corev1.ConfigMap{}
+		g.Expect(c.Get(ctx, types.NamespacedName{Name: "config", Namespace: MetalLBTestNameSpace}, configMap)).To(Succeed())
+		g.Expect(manifests.ValidateMetalLBConfig(configMap.Data["config"])).To(Succeed())
+		return configMap.Data["config"]
+	}
+	peer := func(name, address string) *metallbv1alpha1.BGPPeer {
+		return &metallbv1alpha1.BGPPeer{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: MetalLBTestNameSpace},
+			Spec: metallbv1alpha1.BGPPeerSpec{
+				MyASN:       64512,
+				PeerASN:     64513,
+				PeerAddress: address,
+			},
+		}
+	}
+
+	// A peer is rendered even without any pool
+	tor := peer("tor", "10.0.0.2")
+	tor.Spec.PeerPort = 1179
+	tor.Spec.HoldTime = metav1.Duration{Duration: 90 * time.Second}
+	tor.Spec.KeepaliveTime = metav1.Duration{Duration: 30 * time.Second}
+	g.Expect(c.Create(ctx, tor)).To(Succeed())
+	reconcilePeer("tor")
+	g.Expect(config()).To(MatchYAML(`peers:
+- my-asn: 64512
+  peer-asn: 64513
+  peer-address: 10.0.0.2
+  peer-port: 1179
+  hold-time: 1m30s
+  keepalive-time: 30s
+address-pools:
+`))
+	updated := &metallbv1alpha1.BGPPeer{}
+	g.Expect(c.Get(ctx, types.NamespacedName{Name: "tor", Namespace: MetalLBTestNameSpace}, updated)).To(Succeed())
+	g.Expect(meta.IsStatusConditionTrue(updated.Status.Conditions, status.ConditionAvailable)).To(BeTrue())
+
+	// The peers are rendered next to the pools, by name
+	g.Expect(c.Create(ctx, &metallbv1alpha1.AddressPool{
+		ObjectMeta: metav1.ObjectMeta{Name: "gold", Namespace: MetalLBTestNameSpace},
+		Spec:       metallbv1alpha1.AddressPoolSpec{Protocol: "bgp", Addresses: []string{"172.20.0.0/24"}},
+	})).To(Succeed())
+	g.Expect(c.Create(ctx, peer("spine", "10.0.0.1"))).To(Succeed())
+	reconcilePeer("spine")
+	g.Expect(config()).To(MatchYAML(`peers:
+- my-asn: 64512
+  peer-asn: 64513
+  peer-address: 10.0.0.1
+- my-asn: 64512
+  peer-asn: 64513
+  peer-address: 10.0.0.2
+  peer-port: 1179
+  hold-time: 1m30s
+  keepalive-time: 30s
+address-pools:
+- name: gold
+  protocol: bgp
+  addresses:
+  - 172.20.0.0/24
+`))
+
+	// An invalid peer is left out and reported
+	g.Expect(c.Create(ctx, peer("invalid", "router.example.com"))).To(Succeed())
+	reconcilePeer("invalid")
+	g.Expect(config()).ToNot(ContainSubstring("router.example.com"))
+	updated = &metallbv1alpha1.BGPPeer{}
+	g.Expect(c.Get(ctx, types.NamespacedName{Name: "invalid", Namespace: MetalLBTestNameSpace}, updated)).To(Succeed())
+	degraded := meta.FindStatusCondition(updated.Status.Conditions, status.ConditionDegraded)
+	g.Expect(degraded).ToNot(BeNil())
+	g.Expect(degraded.Status).To(Equal(metav1.ConditionTrue))
+	g.Expect(degraded.Reason).To(Equal("InvalidPeer"))
+
+	// Once the last peer is deleted, the peers key is removed
+	for _, name := range []string{"invalid", "spine", "tor"} {
+		g.Expect(c.Delete(ctx, peer(name, ""))).To(Succeed())
+		reconcilePeer(name)
+	}
+	g.Expect(config()).To(MatchYAML(`address-pools:
+- name: gold
+  protocol: bgp
+  addresses:
+  - 172.20.0.0/24
+`))
+
+	// Without pools nor peers the ConfigMap is deleted
+	g.Expect(c.Create(ctx, peer("spine", "10.0.0.1"))).To(Succeed())
+	reconcilePeer("spine")
+	g.Expect(c.Delete(ctx, &metallbv1alpha1.AddressPool{ObjectMeta: metav1.ObjectMeta{Name: "gold", Namespace: MetalLBTestNameSpace}})).To(Succeed())
+	_, err := pools.Reconcile(ctx, ctrl.Request{NamespacedName: types.NamespacedName{Name: "gold", Namespace: MetalLBTestNameSpace}})
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(config()).To(ContainSubstring("10.0.0.1"))
+	g.Expect(c.Delete(ctx, peer("spine", ""))).To(Succeed())
+	reconcilePeer("spine")
+	err = c.Get(ctx, types.NamespacedName{Name: "config", Namespace: MetalLBTestNameSpace}, &corev1.ConfigMap{})
+	g.Expect(errors.IsNotFound(err)).To(BeTrue())
+}
+
+func TestBGPPeerNodeSelectors(t *testing.T) {
+	g := NewGomegaWithT(t)
+	manifestPath := AddressPoolManifestPath
+	AddressPoolManifestPath = "../bindata/configuration/address-pool"
+	defer func() { AddressPoolManifestPath = manifestPath }()
+
+	c := newFakeClientBuilder().WithScheme(testScheme(g)).WithObjects(testMetalLB(metallbv1beta1.MetalLBSpec{})).Build()
+	peers := &BGPPeerReconciler{
+		Client:    c,
+		Log:       ctrl.Log.WithName("controllers").WithName("BGPPeer"),
+		Namespace: MetalLBTestNameSpace,
+		Pools: &AddressPoolReconciler{
+			Client:    c,
+			Log:       ctrl.Log.WithName("controllers").WithName("AddressPool"),
+			Namespace: MetalLBTestNameSpace,
+		},
+	}
+	ctx := context.Background()
+	key := types.NamespacedName{Name: "tor", Namespace: MetalLBTestNameSpace}
+	tor := &metallbv1alpha1.BGPPeer{
+		ObjectMeta: metav1.ObjectMeta{Name: "tor", Namespace: MetalLBTestNameSpace},
+		Spec: metallbv1alpha1.BGPPeerSpec{
+			MyASN:       64512,
+			PeerASN:     64513,
+			PeerAddress: "10.0.0.1",
+			NodeSelectors: []metav1.LabelSelector{
+				{MatchLabels: map[string]string{"rack": "r1", "bgp": "true"}},
+				{MatchExpressions: []metav1.LabelSelectorRequirement{
+					{Key: "kubernetes.io/hostname", Operator: metav1.LabelSelectorOpIn, Values: []string{"node-1", "node-2"}},
+					{Key: "spine", Operator: metav1.LabelSelectorOpDoesNotExist},
+				}},
+				{
+					MatchLabels:      map[string]string{"rack": "r2"},
+					MatchExpressions: []metav1.LabelSelectorRequirement{{Key: "bgp", Operator: metav1.LabelSelectorOpExists}},
+				},
+			},
+		},
+	}
+	g.Expect(c.Create(ctx, tor)).To(Succeed())
+	_, err := peers.Reconcile(ctx, ctrl.Request{NamespacedName: key})
+	g.Expect(err).ToNot(HaveOccurred())
+
+	configMap := &corev1.ConfigMap{}
+	g.Expect(c.Get(ctx, types.NamespacedName{Name: "config", Namespace: MetalLBTestNameSpace}, configMap)).To(Succeed())
+	g.Expect(manifests.ValidateMetalLBConfig(configMap.Data["config"])).To(Succeed())
+	g.Expect(configMap.Data["config"]).To(MatchYAML(`peers:
+- my-asn: 64512
+  peer-asn: 64513
+  peer-address: 10.0.0.1
+  node-selectors:
+  - match-labels:
+      bgp: "true"
+      rack: r1
+  - match-expressions:
+    - key: kubernetes.io/hostname
+      operator: In
+      values:
+      - node-1
+      - node-2
+    - key: spine
+      operator: DoesNotExist
+  - match-labels:
+      rack: r2
+    match-expressions:
+    - key: bgp
+      operator: Exists
+address-pools:
+`))
+
+	// A selector that does not parse leaves the peer out
+	g.Expect(c.Get(ctx, key, tor)).To(Succeed())
+	tor.Spec.NodeSelectors = []metav1.LabelSelector{{MatchExpressions: []metav1.LabelSelectorRequirement{
+		{Key: "rack", Operator: metav1.LabelSelectorOpIn},
+	}}}
+	g.Expect(c.Update(ctx, tor)).To(Succeed())
+	_, err = peers.Reconcile(ctx, ctrl.Request{NamespacedName: key})
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(c.Get(ctx, key, tor)).To(Succeed())
+	degraded := meta.FindStatusCondition(tor.Status.Conditions, status.ConditionDegraded)
+	g.Expect(degraded).ToNot(BeNil())
+	g.Expect(degraded.Status).To(Equal(metav1.ConditionTrue))
+	g.Expect(degraded.Reason).To(Equal("InvalidPeer"))
+	g.Expect(degraded.Message).To(ContainSubstring("spec.nodeSelectors[0]"))
+}
+
+func TestBGPPeerMultiHop(t *testing.T) {
+	g := NewGomegaWithT(t)
+	manifestPath := AddressPoolManifestPath
+	AddressPoolManifestPath = "../bindata/configuration/address-pool"
+	defer func() { AddressPoolManifestPath = manifestPath }()
+
+	reflector := &metallbv1alpha1.BGPPeer{
+		ObjectMeta: metav1.ObjectMeta{Name: "reflector", Namespace: MetalLBTestNameSpace},
+		Spec: metallbv1alpha1.BGPPeerSpec{
+			MyASN:        64512,
+			PeerASN:      64513,
+			PeerAddress:  "10.1.0.1",
+			RouterID:     "10.0.0.100",
+			EBGPMultiHop: true,
+		},
+	}
+	c := newFakeClientBuilder().WithScheme(testScheme(g)).WithObjects(testMetalLB(metallbv1beta1.MetalLBSpec{}), reflector).Build()
+	peers := &BGPPeerReconciler{
+		Client:    c,
+		Log:       ctrl.Log.WithName("controllers").WithName("BGPPeer"),
+		Namespace: MetalLBTestNameSpace,
+		Pools: &AddressPoolReconciler{
+			Client:    c,
+			Log:       ctrl.Log.WithName("controllers").WithName("AddressPool"),
+			Namespace: MetalLBTestNameSpace,
+		},
+	}
+	_, err := peers.Reconcile(context.Background(), ctrl.Request{NamespacedName: types.NamespacedName{Name: "reflector", Namespace: MetalLBTestNameSpace}})
+	g.Expect(err).ToNot(HaveOccurred())
+
+	configMap := &corev1.ConfigMap{}
+	g.Expect(c.Get(context.Background(), types.NamespacedName{Name: "config", Namespace: MetalLBTestNameSpace}, configMap)).To(Succeed())
+	g.Expect(manifests.ValidateMetalLBConfig(configMap.Data["config"])).To(Succeed())
+	g.Expect(configMap.Data["config"]).To(MatchYAML(`peers:
+- my-asn: 64512
+  peer-asn: 64513
+  peer-address: 10.1.0.1
+  router-id: 10.0.0.100
+  ebgp-multihop: true
+address-pools:
+`))
+}
+
+func TestBGPPeerGracefulRestart(t *testing.T) {
+	g := NewGomegaWithT(t)
+	manifestPath := AddressPoolManifestPath
+	AddressPoolManifestPath = "../bindata/configuration/address-pool"
+	defer func() { AddressPoolManifestPath = manifestPath }()
+
+	enabled := true
+	tor := &metallbv1alpha1.BGPPeer{
+		ObjectMeta: metav1.ObjectMeta{Name: "tor", Namespace: MetalLBTestNameSpace},
+		Spec: metallbv1alpha1.BGPPeerSpec{
+			MyASN:           64512,
+			PeerASN:         64513,
+			PeerAddress:     "10.0.0.1",
+			GracefulRestart: &enabled,
+			ConnectTime:     &metav1.Duration{Duration: 30 * time.Second},
+		},
+	}
+	metallb := testMetalLB(metallbv1beta1.MetalLBSpec{BGPBackend: metallbv1beta1.BGPBackendFRR})
+	c := newFakeClientBuilder().WithScheme(testScheme(g)).WithObjects(metallb, tor).Build()
+	peers := &BGPPeerReconciler{
+		Client:    c,
+		Log:       ctrl.Log.WithName("controllers").WithName("BGPPeer"),
+		Namespace: MetalLBTestNameSpace,
+		Pools: &AddressPoolReconciler{
+			Client:    c,
+			Log:       ctrl.Log.WithName("controllers").WithName("AddressPool"),
+			Namespace: MetalLBTestNameSpace,
+		},
+	}
+	ctx := context.Background()
+	key := types.NamespacedName{Name: "tor", Namespace: MetalLBTestNameSpace}
+	_, err := peers.Reconcile(ctx, ctrl.Request{NamespacedName: key})
+	g.Expect(err).ToNot(HaveOccurred())
+
+	configMap := &corev1.ConfigMap{}
+	g.Expect(c.Get(ctx, types.NamespacedName{Name: "config", Namespace: MetalLBTestNameSpace}, configMap)).To(Succeed())
+	g.Expect(manifests.ValidateMetalLBConfig(configMap.Data["config"])).To(Succeed())
+	g.Expect(configMap.Data["config"]).To(MatchYAML(`peers:
+- my-asn: 64512
+  peer-asn: 64513
+  peer-address: 10.0.0.1
+  graceful-restart: true
+  connect-time: 30s
+address-pools:
+`))
+
+	// The native backend does not support it, the peer is left out
+	g.Expect(c.Get(ctx, types.NamespacedName{Name: metallb.Name, Namespace: metallb.Namespace}, metallb)).To(Succeed())
+	metallb.Spec.BGPBackend = metallbv1beta1.BGPBackendNative
+	g.Expect(c.Update(ctx, metallb)).To(Succeed())
+	_, err = peers.Reconcile(ctx, ctrl.Request{NamespacedName: key})
+	g.Expect(err).ToNot(HaveOccurred())
+
+	g.Expect(c.Get(ctx, types.NamespacedName{Name: "config", Namespace: MetalLBTestNameSpace}, configMap)).To(Succeed())
+	g.Expect(configMap.Data["config"]).ToNot(ContainSubstring("graceful-restart"))
+	g.Expect(c.Get(ctx, key, tor)).To(Succeed())
+	degraded := meta.FindStatusCondition(tor.Status.Conditions, status.ConditionDegraded)
+	g.Expect(degraded).ToNot(BeNil())
+	g.Expect(degraded.Reason).To(Equal("InvalidPeer"))
+	g.Expect(degraded.Message).To(ContainSubstring("spec.gracefulRestart"))
+}
+
+func TestBGPPeerLocalASN(t *testing.T) {
+	g := NewGomegaWithT(t)
+	manifestPath := AddressPoolManifestPath
+	AddressPoolManifestPath = "../bindata/configuration/address-pool"
+	defer func() { AddressPoolManifestPath = manifestPath }()
+
+	spine := &metallbv1alpha1.BGPPeer{
+		ObjectMeta: metav1.ObjectMeta{Name: "spine", Namespace: MetalLBTestNameSpace},
+		Spec:       metallbv1alpha1.BGPPeerSpec{MyASN: 64512, PeerASN: 64513, PeerAddress: "10.0.0.1"},
+	}
+	tor := &metallbv1alpha1.BGPPeer{
+		ObjectMeta: metav1.ObjectMeta{Name: "tor", Namespace: MetalLBTestNameSpace},
+		Spec:       metallbv1alpha1.BGPPeerSpec{MyASN: 64600, PeerASN: 64514, PeerAddress: "10.0.0.2"},
+	}
+	metallb := testMetalLB(metallbv1beta1.MetalLBSpec{BGPBackend: metallbv1beta1.BGPBackendFRR})
+	c := newFakeClientBuilder().WithScheme(testScheme(g)).WithObjects(metallb, spine, tor).Build()
+	peers := &BGPPeerReconciler{
+		Client:    c,
+		Log:       ctrl.Log.WithName("controllers").WithName("BGPPeer"),
+		Namespace: MetalLBTestNameSpace,
+		Pools: &AddressPoolReconciler{
+			Client:    c,
+			Log:       ctrl.Log.WithName("controllers").WithName("AddressPool"),
+			Namespace: MetalLBTestNameSpace,
+		},
+	}
+	ctx := context.Background()
+	reconcilePeers := func() {
+		for _, name := range []string{"spine", "tor"} {
+			_, err := peers.Reconcile(ctx, ctrl.Request{NamespacedName: types.NamespacedName{Name: name, Namespace: MetalLBTestNameSpace}})
+			g.Expect(err).ToNot(HaveOccurred())
+		}
+	}
+
+	// The spine comes first and sets the local AS number, the tor is left out
+	reconcilePeers()
+	configMap := &corev1.ConfigMap{}
+	g.Expect(c.Get(ctx, types.NamespacedName{Name: "config", Namespace: MetalLBTestNameSpace}, configMap)).To(Succeed())
+	g.Expect(configMap.Data["config"]).To(MatchYAML(`peers:
+- my-asn: 64512
+  peer-asn: 64513
+  peer-address: 10.0.0.1
+address-pools: []
+`))
+	g.Expect(c.Get(ctx, types.NamespacedName{Name: "spine", Namespace: MetalLBTestNameSpace}, spine)).To(Succeed())
+	g.Expect(meta.IsStatusConditionTrue(spine.Status.Conditions, status.ConditionAvailable)).To(BeTrue())
+	g.Expect(c.Get(ctx, types.NamespacedName{Name: "tor", Namespace: MetalLBTestNameSpace}, tor)).To(Succeed())
+	degraded := meta.FindStatusCondition(tor.Status.Conditions, status.ConditionDegraded)
+	g.Expect(degraded).ToNot(BeNil())
+	g.Expect(degraded.Status).To(Equal(metav1.ConditionTrue))
+	g.Expect(degraded.Reason).To(Equal("InconsistentASN"))
+	g.Expect(degraded.Message).To(ContainSubstring("the frr BGP backend uses the local AS number 64512 of bgppeer " + MetalLBTestNameSpace + "/spine"))
+
+	// Once consistent, both peers are rendered
+	tor.Spec.MyASN = 64512
+	g.Expect(c.Update(ctx, tor)).To(Succeed())
+	reconcilePeers()
+	g.Expect(c.Get(ctx, types.NamespacedName{Name: "config", Namespace: MetalLBTestNameSpace}, configMap)).To(Succeed())
+	g.Expect(configMap.Data["config"]).To(ContainSubstring("peer-address: 10.0.0.2"))
+	g.Expect(c.Get(ctx, types.NamespacedName{Name: "tor", Namespace: MetalLBTestNameSpace}, tor)).To(Succeed())
+	g.Expect(meta.IsStatusConditionTrue(tor.Status.Conditions, status.ConditionAvailable)).To(BeTrue())
+}
+
+func TestBGPPeerPasswordSecret(t *testing.T) {
+	g := NewGomegaWithT(t)
+	manifestPath := AddressPoolManifestPath
+	AddressPoolManifestPath = "../bindata/configuration/address-pool"
+	defer func() { AddressPoolManifestPath = manifestPath }()
+
+	tor := &metallbv1alpha1.BGPPeer{
+		ObjectMeta: metav1.ObjectMeta{Name: "tor", Namespace: MetalLBTestNameSpace},
+		Spec: metallbv1alpha1.BGPPeerSpec{MyASN: 64512, PeerASN: 64513, PeerAddress: "10.0.0.1",
+			PasswordSecret: &corev1.LocalObjectReference{Name: "tor-password"}},
+	}
+	c := newFakeClientBuilder().WithScheme(testScheme(g)).WithObjects(testMetalLB(metallbv1beta1.MetalLBSpec{}), tor).Build()
+	peers := &BGPPeerReconciler{
+		Client:    c,
+		Log:       ctrl.Log.WithName("controllers").WithName("BGPPeer"),
+		Namespace: MetalLBTestNameSpace,
+		Pools: &AddressPoolReconciler{
+			Client:    c,
+			Log:       ctrl.Log.WithName("controllers").WithName("AddressPool"),
+			Namespace: MetalLBTestNameSpace,
+		},
+	}
+	ctx := context.Background()
+	renderedPassword := func() string {
+		configMap := &corev1.ConfigMap{}
+		g.Expect(c.Get(ctx, types.NamespacedName{Name: "config", Namespace: MetalLBTestNameSpace}, configMap)).To(Succeed())
+		g.Expect(manifests.ValidateMetalLBConfig(configMap.Data["config"])).To(Succeed())
+		config := struct {
+			Peers []struct {
+				Password string `yaml:"password"`
+			} `yaml:"peers"`
+		}{}
+		g.Expect(yaml.Unmarshal([]byte(configMap.Data["config"]), &config)).To(Succeed())
+		g.Expect(config.Peers).To(HaveLen(1))
+		return config.Peers[0].Password
+	}
+
+	// The peer is left out until its password Secret exists
+	_, err := peers.Reconcile(ctx, ctrl.Request{NamespacedName: types.NamespacedName{Name: "tor", Namespace: MetalLBTestNameSpace}})
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(c.Get(ctx, types.NamespacedName{Name: "tor", Namespace: MetalLBTestNameSpace}, tor)).To(Succeed())
+	degraded := meta.FindStatusCondition(tor.Status.Conditions, status.ConditionDegraded)
+	g.Expect(degraded).ToNot(BeNil())
+	g.Expect(degraded.Reason).To(Equal("PasswordSecretNotFound"))
+	g.Expect(degraded.Message).To(Equal("Secret tor-password not found"))
+
+	// Creating and rotating the Secret reconciles the peer referencing it,
+	// which renders the current password
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "tor-password", Namespace: MetalLBTestNameSpace},
+		Data:       map[string][]byte{metallbv1alpha1.PasswordSecretKey: []byte(`s3cr:t "one"`)},
+	}
+	g.Expect(c.Create(ctx, secret)).To(Succeed())
+	other := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "other", Namespace: MetalLBTestNameSpace}}
+	g.Expect(peers.secretPeers(other)).To(BeEmpty())
+	for _, password := range []string{`s3cr:t "one"`, "two"} {
+		secret.Data[metallbv1alpha1.PasswordSecretKey] = []byte(password)
+		g.Expect(c.Update(ctx, secret)).To(Succeed())
+		requests := peers.secretPeers(secret)
+		g.Expect(requests).To(Equal([]reconcile.Request{{NamespacedName: types.NamespacedName{Name: "tor", Namespace: MetalLBTestNameSpace}}}))
+		_, err := peers.Reconcile(ctx, requests[0])
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(renderedPassword()).To(Equal(password))
+	}
+	g.Expect(c.Get(ctx, types.NamespacedName{Name: "tor", Namespace: MetalLBTestNameSpace}, tor)).To(Succeed())
+	g.Expect(meta.IsStatusConditionTrue(tor.Status.Conditions, status.ConditionAvailable)).To(BeTrue())
+}
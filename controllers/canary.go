@@ -0,0 +1,166 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	metallbv1alpha1 "github.com/metallb/metallb-operator/api/v1alpha1"
+	metallbv1beta1 "github.com/metallb/metallb-operator/api/v1beta1"
+	"github.com/metallb/metallb-operator/pkg/addresses"
+	"github.com/metallb/metallb-operator/pkg/selftest"
+	"github.com/metallb/metallb-operator/pkg/status"
+)
+
+// Canary maintains the canary AddressPool and Service of the MetalLB
+// resource enabling it, and reports every Interval whether MetalLB assigned an
+// address to the Service, as the self test does once.
+type Canary struct {
+	client.Client
+	Log       logr.Logger
+	Scheme    *runtime.Scheme
+	Namespace string
+	Interval  time.Duration
+}
+
+// Start implements manager.Runnable
+func (c *Canary) Start(ctx context.Context) error {
+	wait.UntilWithContext(ctx, func(ctx context.Context) {
+		if err := c.check(ctx); err != nil {
+			c.Log.Info(fmt.Sprintf("Canary check failed: %s", err))
+		}
+	}, c.Interval)
+	return nil
+}
+
+// check creates the canary resources and reports the address assigned to
+// the canary Service, or deletes them when the canary is disabled.
+func (c *Canary) check(ctx context.Context) error {
+	metallb := &metallbv1beta1.MetalLB{}
+	err := c.Get(ctx, types.NamespacedName{Name: defaultMetalLBCrName, Namespace: c.Namespace}, metallb)
+	if apierrors.IsNotFound(err) {
+		// The canary resources are owned by the MetalLB resource
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if metallb.Spec.EnableCanary == nil || !*metallb.Spec.EnableCanary {
+		return c.cleanup(ctx, metallb)
+	}
+	if _, _, err := addresses.ParseRange(metallb.Spec.CanaryAddresses); err != nil {
+		return status.UpdateCanaryHealthy(ctx, c.Client, metallb, metav1.ConditionFalse, "InvalidCanaryAddresses",
+			fmt.Sprintf("Invalid canaryAddresses %q: %s", metallb.Spec.CanaryAddresses, err))
+	}
+
+	pool, err := c.syncPool(ctx, metallb)
+	if err != nil {
+		return err
+	}
+	svc := &corev1.Service{}
+	err = c.Get(ctx, types.NamespacedName{Name: selftest.CanaryName, Namespace: c.Namespace}, svc)
+	if apierrors.IsNotFound(err) {
+		if err := c.createService(ctx, metallb); err != nil {
+			return err
+		}
+		return status.UpdateCanaryHealthy(ctx, c.Client, metallb, metav1.ConditionUnknown, "AddressPending",
+			"Waiting for an address to be assigned to the canary service")
+	}
+	if err != nil {
+		return err
+	}
+
+	ip := selftest.AssignedAddress(svc)
+	if ip == nil {
+		return status.UpdateCanaryHealthy(ctx, c.Client, metallb, metav1.ConditionFalse, "NoAddressAssigned",
+			fmt.Sprintf("No address assigned to the canary service from pool %s", pool.Name))
+	}
+	if _, ok := addresses.FindPool(ip, []metallbv1alpha1.AddressPool{*pool}); !ok {
+		// The service requests an address again once recreated
+		if err := client.IgnoreNotFound(c.Delete(ctx, svc)); err != nil {
+			return err
+		}
+		return status.UpdateCanaryHealthy(ctx, c.Client, metallb, metav1.ConditionFalse, "AddressOutOfPool",
+			fmt.Sprintf("Address %s assigned to the canary service is not part of pool %s", ip, pool.Name))
+	}
+	return status.UpdateCanaryHealthy(ctx, c.Client, metallb, metav1.ConditionTrue, "AddressAssigned",
+		fmt.Sprintf("Address %s assigned from pool %s", ip, pool.Name))
+}
+
+// syncPool creates the canary AddressPool, or updates its addresses to the
+// CanaryAddresses.
+func (c *Canary) syncPool(ctx context.Context, metallb *metallbv1beta1.MetalLB) (*metallbv1alpha1.AddressPool, error) {
+	autoAssign := false
+	pool := &metallbv1alpha1.AddressPool{}
+	err := c.Get(ctx, types.NamespacedName{Name: selftest.CanaryName, Namespace: c.Namespace}, pool)
+	if apierrors.IsNotFound(err) {
+		pool = &metallbv1alpha1.AddressPool{
+			ObjectMeta: metav1.ObjectMeta{Name: selftest.CanaryName, Namespace: c.Namespace},
+			Spec: metallbv1alpha1.AddressPoolSpec{
+				Protocol:   "layer2",
+				Addresses:  []string{metallb.Spec.CanaryAddresses},
+				AutoAssign: &autoAssign,
+			},
+		}
+		if err := controllerutil.SetControllerReference(metallb, pool, c.Scheme); err != nil {
+			return nil, err
+		}
+		return pool, c.Create(ctx, pool)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(pool.Spec.Addresses) == 1 && pool.Spec.Addresses[0] == metallb.Spec.CanaryAddresses {
+		return pool, nil
+	}
+	pool.Spec.Addresses = []string{metallb.Spec.CanaryAddresses}
+	return pool, c.Update(ctx, pool)
+}
+
+func (c *Canary) createService(ctx context.Context, metallb *metallbv1beta1.MetalLB) error {
+	svc := selftest.Service(selftest.CanaryName, c.Namespace, selftest.CanaryName)
+	if err := controllerutil.SetControllerReference(metallb, svc, c.Scheme); err != nil {
+		return err
+	}
+	return c.Create(ctx, svc)
+}
+
+// cleanup deletes the canary resources and removes the CanaryHealthy condition.
+func (c *Canary) cleanup(ctx context.Context, metallb *metallbv1beta1.MetalLB) error {
+	objs := []client.Object{
+		&corev1.Service{ObjectMeta: metav1.ObjectMeta{Name: selftest.CanaryName, Namespace: c.Namespace}},
+		&metallbv1alpha1.AddressPool{ObjectMeta: metav1.ObjectMeta{Name: selftest.CanaryName, Namespace: c.Namespace}},
+	}
+	for _, obj := range objs {
+		if err := client.IgnoreNotFound(c.Delete(ctx, obj)); err != nil {
+			return err
+		}
+	}
+	return status.RemoveCondition(ctx, c.Client, metallb, status.ConditionCanaryHealthy)
+}
@@ -0,0 +1,115 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	metallbv1alpha1 "github.com/metallb/metallb-operator/api/v1alpha1"
+	metallbv1beta1 "github.com/metallb/metallb-operator/api/v1beta1"
+	"github.com/metallb/metallb-operator/pkg/selftest"
+	"github.com/metallb/metallb-operator/pkg/status"
+)
+
+func TestCanaryLifecycle(t *testing.T) {
+	g := NewGomegaWithT(t)
+	ctx := context.Background()
+
+	enabled := true
+	metallb := testMetalLB(metallbv1beta1.MetalLBSpec{EnableCanary: &enabled, CanaryAddresses: "192.168.10.250/32"})
+	canary := &Canary{
+		Client:    newFakeClientBuilder().WithScheme(testScheme(g)).WithObjects(metallb).Build(),
+		Log:       ctrl.Log.WithName("canary"),
+		Scheme:    testScheme(g),
+		Namespace: MetalLBTestNameSpace,
+	}
+	key := types.NamespacedName{Name: selftest.CanaryName, Namespace: MetalLBTestNameSpace}
+	condition := func() *metav1.Condition {
+		metallb = &metallbv1beta1.MetalLB{}
+		g.Expect(canary.Get(ctx, types.NamespacedName{Name: defaultMetalLBCrName, Namespace: MetalLBTestNameSpace}, metallb)).To(Succeed())
+		return meta.FindStatusCondition(metallb.Status.Conditions, status.ConditionCanaryHealthy)
+	}
+	assign := func(ip string) {
+		svc := &corev1.Service{}
+		g.Expect(canary.Get(ctx, key, svc)).To(Succeed())
+		svc.Status.LoadBalancer.Ingress = []corev1.LoadBalancerIngress{{IP: ip}}
+		g.Expect(canary.Status().Update(ctx, svc)).To(Succeed())
+	}
+
+	// The pool and the service are created, owned by the MetalLB resource
+	g.Expect(canary.check(ctx)).To(Succeed())
+	pool := &metallbv1alpha1.AddressPool{}
+	g.Expect(canary.Get(ctx, key, pool)).To(Succeed())
+	g.Expect(pool.Spec.Addresses).To(Equal([]string{"192.168.10.250/32"}))
+	g.Expect(*pool.Spec.AutoAssign).To(BeFalse())
+	g.Expect(pool.OwnerReferences).To(HaveLen(1))
+	svc := &corev1.Service{}
+	g.Expect(canary.Get(ctx, key, svc)).To(Succeed())
+	g.Expect(svc.Annotations).To(HaveKeyWithValue(selftest.AddressPoolAnnotation, selftest.CanaryName))
+	g.Expect(svc.OwnerReferences).To(HaveLen(1))
+	g.Expect(condition().Status).To(Equal(metav1.ConditionUnknown))
+
+	// No address assigned since the last check
+	g.Expect(canary.check(ctx)).To(Succeed())
+	g.Expect(condition().Status).To(Equal(metav1.ConditionFalse))
+	g.Expect(condition().Reason).To(Equal("NoAddressAssigned"))
+
+	assign("192.168.10.250")
+	g.Expect(canary.check(ctx)).To(Succeed())
+	g.Expect(condition().Status).To(Equal(metav1.ConditionTrue))
+	g.Expect(condition().Message).To(Equal("Address 192.168.10.250 assigned from pool metallb-canary"))
+
+	// The addresses changed, the service is recreated to get a new one
+	metallb.Spec.CanaryAddresses = "192.168.10.251/32"
+	g.Expect(canary.Update(ctx, metallb)).To(Succeed())
+	g.Expect(canary.check(ctx)).To(Succeed())
+	g.Expect(canary.Get(ctx, key, pool)).To(Succeed())
+	g.Expect(pool.Spec.Addresses).To(Equal([]string{"192.168.10.251/32"}))
+	g.Expect(condition().Reason).To(Equal("AddressOutOfPool"))
+	g.Expect(apierrors.IsNotFound(canary.Get(ctx, key, &corev1.Service{}))).To(BeTrue())
+	g.Expect(canary.check(ctx)).To(Succeed())
+	assign("192.168.10.251")
+	g.Expect(canary.check(ctx)).To(Succeed())
+	g.Expect(condition().Status).To(Equal(metav1.ConditionTrue))
+
+	// Disabling the canary cleans up
+	*metallb.Spec.EnableCanary = false
+	g.Expect(canary.Update(ctx, metallb)).To(Succeed())
+	g.Expect(canary.check(ctx)).To(Succeed())
+	g.Expect(apierrors.IsNotFound(canary.Get(ctx, key, &metallbv1alpha1.AddressPool{}))).To(BeTrue())
+	g.Expect(apierrors.IsNotFound(canary.Get(ctx, key, &corev1.Service{}))).To(BeTrue())
+	g.Expect(condition()).To(BeNil())
+}
+
+func TestCanaryInvalidAddresses(t *testing.T) {
+	g := NewGomegaWithT(t)
+	ctx := context.Background()
+
+	enabled := true
+	metallb := testMetalLB(metallbv1beta1.MetalLBSpec{EnableCanary: &enabled, CanaryAddresses: "192.168.10.250"})
+	canary := &Canary{
+		Client:    newFakeClientBuilder().WithScheme(testScheme(g)).WithObjects(metallb).Build(),
+		Log:       ctrl.Log.WithName("canary"),
+		Scheme:    testScheme(g),
+		Namespace: MetalLBTestNameSpace,
+	}
+	g.Expect(canary.check(ctx)).To(Succeed())
+	g.Expect(canary.Get(ctx, types.NamespacedName{Name: defaultMetalLBCrName, Namespace: MetalLBTestNameSpace}, metallb)).To(Succeed())
+	condition := meta.FindStatusCondition(metallb.Status.Conditions, status.ConditionCanaryHealthy)
+	g.Expect(condition.Status).To(Equal(metav1.ConditionFalse))
+	g.Expect(condition.Reason).To(Equal("InvalidCanaryAddresses"))
+	key := types.NamespacedName{Name: selftest.CanaryName, Namespace: MetalLBTestNameSpace}
+	g.Expect(apierrors.IsNotFound(canary.Get(ctx, key, &metallbv1alpha1.AddressPool{}))).To(BeTrue())
+
+	// The MetalLB configuration is reported invalid too
+	r := &MetalLBReconciler{Namespace: MetalLBTestNameSpace}
+	_, err := r.renderMetalLBObjects(metallb)
+	g.Expect(err).To(MatchError(ContainSubstring("invalid canaryAddresses")))
+}
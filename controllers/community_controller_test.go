@@ -0,0 +1,126 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	metallbv1alpha1 "github.com/metallb/metallb-operator/api/v1alpha1"
+	metallbv1beta1 "github.com/metallb/metallb-operator/api/v1beta1"
+	"github.com/metallb/metallb-operator/pkg/status"
+	"github.com/metallb/metallb-operator/test/manifests"
+)
+
+func TestCommunityLifecycle(t *testing.T) {
+	g := NewGomegaWithT(t)
+	manifestPath := AddressPoolManifestPath
+	AddressPoolManifestPath = "../bindata/configuration/address-pool"
+	defer func() { AddressPoolManifestPath = manifestPath }()
+
+	c := newFakeClientBuilder().WithScheme(testScheme(g)).WithObjects(testMetalLB(metallbv1beta1.MetalLBSpec{})).Build()
+	pools := &AddressPoolReconciler{
+		Client:    c,
+		Log:       ctrl.Log.WithName("controllers").WithName("AddressPool"),
+		Namespace: MetalLBTestNameSpace,
+	}
+	communities := &CommunityReconciler{
+		Client:    c,
+		Log:       ctrl.Log.WithName("controllers").WithName("Community"),
+		Namespace: MetalLBTestNameSpace,
+		Pools:     pools,
+	}
+	ctx := context.Background()
+	key := func(name string) types.NamespacedName {
+		return types.NamespacedName{Name: name, Namespace: MetalLBTestNameSpace}
+	}
+	reconcileObject := func(r reconcile.Reconciler, name string) {
+		_, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: key(name)})
+		g.Expect(err).ToNot(HaveOccurred())
+	}
+	config := func() string {
+		configMap := &corev1.ConfigMap{}
+		g.Expect(c.Get(ctx, key("config"), configMap)).To(Succeed())
+		g.Expect(manifests.ValidateMetalLBConfig(configMap.Data["config"])).To(Succeed())
+		return configMap.Data["config"]
+	}
+	degradedReason := func(conditions []metav1.Condition) string {
+		degraded := meta.FindStatusCondition(conditions, status.ConditionDegraded)
+		g.Expect(degraded).ToNot(BeNil())
+		if degraded.Status != metav1.ConditionTrue {
+			return ""
+		}
+		return degraded.Reason
+	}
+	poolReason := func() string {
+		pool := &metallbv1alpha1.AddressPool{}
+		g.Expect(c.Get(ctx, key("gold"), pool)).To(Succeed())
+		return degradedReason(pool.Status.Conditions)
+	}
+	communityReason := func(name string) string {
+		community := &metallbv1alpha1.Community{}
+		g.Expect(c.Get(ctx, key(name), community)).To(Succeed())
+		return degradedReason(community.Status.Conditions)
+	}
+
+	// A pool referencing a missing community is left out
+	g.Expect(c.Create(ctx, &metallbv1alpha1.AddressPool{
+		ObjectMeta: metav1.ObjectMeta{Name: "gold", Namespace: MetalLBTestNameSpace},
+		Spec: metallbv1alpha1.AddressPoolSpec{
+			Protocol:  "bgp",
+			Addresses: []string{"10.0.0.0/24"},
+			BGPAdvertisements: []metallbv1alpha1.BGPAdvertisement{
+				{Communities: []metallbv1alpha1.BGPCommunity{{Name: "premium"}}},
+			},
+		},
+	})).To(Succeed())
+	reconcileObject(pools, "gold")
+	g.Expect(config()).To(MatchYAML(`address-pools:
+`))
+	g.Expect(poolReason()).To(Equal("UnknownCommunity"))
+
+	// Once the community exists, the pool is rendered along with it
+	g.Expect(c.Create(ctx, &metallbv1alpha1.Community{
+		ObjectMeta: metav1.ObjectMeta{Name: "premium", Namespace: MetalLBTestNameSpace},
+		Spec:       metallbv1alpha1.CommunitySpec{Name: "premium", Value: "64512:100"},
+	})).To(Succeed())
+	reconcileObject(communities, "premium")
+	g.Expect(communityReason("premium")).To(BeEmpty())
+	reconcileObject(pools, "gold")
+	g.Expect(config()).To(MatchYAML(`bgp-communities:
+  premium: "64512:100"
+address-pools:
+- name: gold
+  protocol: bgp
+  addresses:
+  - 10.0.0.0/24
+  bgp-advertisements:
+  - communities:
+    - premium
+`))
+	g.Expect(poolReason()).To(BeEmpty())
+
+	// A second community defining the same name is left out
+	g.Expect(c.Create(ctx, &metallbv1alpha1.Community{
+		ObjectMeta: metav1.ObjectMeta{Name: "premium-copy", Namespace: MetalLBTestNameSpace},
+		Spec:       metallbv1alpha1.CommunitySpec{Name: "premium", Value: "64512:200"},
+	})).To(Succeed())
+	reconcileObject(communities, "premium-copy")
+	g.Expect(communityReason("premium-copy")).To(Equal("InvalidCommunity"))
+	g.Expect(config()).To(ContainSubstring("64512:100"))
+	g.Expect(config()).ToNot(ContainSubstring("64512:200"))
+
+	// Deleting the community drops it and the pool referencing it
+	g.Expect(c.Delete(ctx, &metallbv1alpha1.Community{ObjectMeta: metav1.ObjectMeta{Name: "premium-copy", Namespace: MetalLBTestNameSpace}})).To(Succeed())
+	g.Expect(c.Delete(ctx, &metallbv1alpha1.Community{ObjectMeta: metav1.ObjectMeta{Name: "premium", Namespace: MetalLBTestNameSpace}})).To(Succeed())
+	reconcileObject(communities, "premium")
+	reconcileObject(pools, "gold")
+	g.Expect(config()).ToNot(ContainSubstring("premium"))
+	g.Expect(poolReason()).To(Equal("UnknownCommunity"))
+}
@@ -0,0 +1,268 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/go-logr/logr"
+	"gopkg.in/yaml.v2"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+
+	metallbv1alpha1 "github.com/metallb/metallb-operator/api/v1alpha1"
+	metallbv1beta1 "github.com/metallb/metallb-operator/api/v1beta1"
+	"github.com/metallb/metallb-operator/pkg/apply"
+)
+
+const (
+	// DefaultConfigExportAddr only serves the configuration export to the
+	// operator pod, e.g. through kubectl port-forward.
+	DefaultConfigExportAddr = "127.0.0.1:8089"
+	// ConfigExportPath is the path the configuration export is served at.
+	ConfigExportPath = "/config"
+	// redactedValue replaces the passwords in the configuration export.
+	redactedValue = "<redacted>"
+)
+
+// ConfigExport serves the MetalLB ConfigMaps rendered by the operator, along
+// with the resources they are rendered from, as a multi-document YAML file to
+// attach to support bundles.
+type ConfigExport struct {
+	client.Client
+	Log       logr.Logger
+	Scheme    *runtime.Scheme
+	Namespace string
+	// Addr is the address the export is served at
+	Addr string
+	// Pools collects the AddressPools, BGPPeers, BFDProfiles and Communities
+	// rendered into the ConfigMaps
+	Pools *AddressPoolReconciler
+}
+
+// Start implements manager.Runnable
+func (e *ConfigExport) Start(ctx context.Context) error {
+	mux := http.NewServeMux()
+	mux.Handle(ConfigExportPath, e)
+	server := &http.Server{Addr: e.Addr, Handler: mux}
+	go func() {
+		<-ctx.Done()
+		if err := server.Shutdown(context.Background()); err != nil {
+			e.Log.Info(fmt.Sprintf("Failed to stop the configuration export: %s", err))
+		}
+	}()
+	e.Log.Info(fmt.Sprintf("Serving the configuration export at %s%s", e.Addr, ConfigExportPath))
+	if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		return err
+	}
+	return nil
+}
+
+// NeedLeaderElection implements manager.LeaderElectionRunnable, the export
+// is served by every operator replica.
+func (e *ConfigExport) NeedLeaderElection() bool {
+	return false
+}
+
+func (e *ConfigExport) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		http.Error(w, "only GET is allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	export, err := e.export(req.Context())
+	if err != nil {
+		e.Log.Info(fmt.Sprintf("Failed to export the configuration: %s", err))
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/yaml")
+	w.Header().Set("Content-Disposition", `attachment; filename="metallb-config.yaml"`)
+	if _, err := w.Write(export); err != nil {
+		e.Log.Info(fmt.Sprintf("Failed to write the configuration export: %s", err))
+	}
+}
+
+// export returns the MetalLB ConfigMaps, the MetalLB resources, the
+// AddressPools, the BGPPeers with their password Secrets, the BFDProfiles and
+// the Communities as YAML documents. The passwords are redacted.
+func (e *ConfigExport) export(ctx context.Context) ([]byte, error) {
+	namespace, err := workloadsNamespace(ctx, e.Client, e.Namespace)
+	if err != nil {
+		return nil, err
+	}
+	objs := []client.Object{}
+	configMap := &corev1.ConfigMap{}
+	err = e.Get(ctx, types.NamespacedName{Name: apply.AddressPoolConfigMap, Namespace: namespace}, configMap)
+	if err != nil && !apierrors.IsNotFound(err) {
+		return nil, err
+	}
+	if err == nil {
+		if err := redactPasswords(configMap); err != nil {
+			return nil, err
+		}
+		objs = append(objs, configMap)
+	}
+
+	metallbs := &metallbv1beta1.MetalLBList{}
+	if err := e.List(ctx, metallbs, client.InNamespace(e.Namespace)); err != nil {
+		return nil, err
+	}
+	for i := range metallbs.Items {
+		objs = append(objs, &metallbs.Items[i])
+	}
+	pools, err := e.Pools.listAddressPools()
+	if err != nil {
+		return nil, err
+	}
+	for i := range pools {
+		objs = append(objs, &pools[i])
+	}
+	peers, err := e.Pools.listBGPPeers()
+	if err != nil {
+		return nil, err
+	}
+	for i := range peers {
+		objs = append(objs, &peers[i])
+	}
+	secrets, err := e.passwordSecrets(ctx, peers)
+	if err != nil {
+		return nil, err
+	}
+	objs = append(objs, secrets...)
+	profiles, err := e.Pools.listBFDProfiles()
+	if err != nil {
+		return nil, err
+	}
+	for i := range profiles {
+		objs = append(objs, &profiles[i])
+	}
+	communities, err := e.Pools.listCommunities()
+	if err != nil {
+		return nil, err
+	}
+	for i := range communities {
+		objs = append(objs, &communities[i])
+	}
+
+	var buf bytes.Buffer
+	for _, obj := range objs {
+		gvk, err := apiutil.GVKForObject(obj, e.Scheme)
+		if err != nil {
+			return nil, err
+		}
+		obj.GetObjectKind().SetGroupVersionKind(gvk)
+		obj.SetManagedFields(nil)
+		data, err := toYAML(obj)
+		if err != nil {
+			return nil, err
+		}
+		buf.WriteString("---\n")
+		buf.Write(data)
+	}
+	return buf.Bytes(), nil
+}
+
+// passwordSecrets returns the PasswordSecrets of the peers, each once, with
+// their values redacted. The missing ones are skipped.
+func (e *ConfigExport) passwordSecrets(ctx context.Context, peers []metallbv1alpha1.BGPPeer) ([]client.Object, error) {
+	objs := []client.Object{}
+	seen := map[types.NamespacedName]bool{}
+	for _, peer := range peers {
+		if peer.Spec.PasswordSecret == nil {
+			continue
+		}
+		key := types.NamespacedName{Name: peer.Spec.PasswordSecret.Name, Namespace: peer.Namespace}
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		secret := &corev1.Secret{}
+		if err := e.Get(ctx, key, secret); err != nil {
+			if apierrors.IsNotFound(err) {
+				continue
+			}
+			return nil, err
+		}
+		redacted := &corev1.Secret{
+			ObjectMeta: secret.ObjectMeta,
+			Type:       secret.Type,
+			StringData: map[string]string{},
+		}
+		for k := range secret.Data {
+			redacted.StringData[k] = redactedValue
+		}
+		objs = append(objs, redacted)
+	}
+	return objs, nil
+}
+
+// redactPasswords replaces the passwords of the peers of the MetalLB
+// configuration of the ConfigMap with redactedValue.
+func redactPasswords(configMap *corev1.ConfigMap) error {
+	data, ok := configMap.Data[apply.AddressPoolConfigMap]
+	if !ok || !strings.Contains(data, "password:") {
+		return nil
+	}
+	config := yaml.MapSlice{}
+	if err := yaml.Unmarshal([]byte(data), &config); err != nil {
+		return fmt.Errorf("invalid MetalLB configuration in ConfigMap %s: %w", configMap.Name, err)
+	}
+	for _, item := range config {
+		if item.Key != "peers" {
+			continue
+		}
+		peers, _ := item.Value.([]interface{})
+		for _, peer := range peers {
+			fields, _ := peer.(yaml.MapSlice)
+			for i := range fields {
+				if fields[i].Key == "password" {
+					fields[i].Value = redactedValue
+				}
+			}
+		}
+	}
+	redacted, err := yaml.Marshal(config)
+	if err != nil {
+		return err
+	}
+	configMap.Data[apply.AddressPoolConfigMap] = string(redacted)
+	return nil
+}
+
+// toYAML marshals the object to YAML as per its json tags, keeping the
+// order of the fields.
+func toYAML(obj client.Object) ([]byte, error) {
+	data, err := json.Marshal(obj)
+	if err != nil {
+		return nil, err
+	}
+	fields := yaml.MapSlice{}
+	if err := yaml.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+	return yaml.Marshal(fields)
+}
@@ -0,0 +1,190 @@
+package controllers
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/serializer"
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	metallbv1alpha1 "github.com/metallb/metallb-operator/api/v1alpha1"
+	metallbv1beta1 "github.com/metallb/metallb-operator/api/v1beta1"
+	"github.com/metallb/metallb-operator/pkg/apply"
+)
+
+func TestConfigExport(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	scheme := testScheme(g)
+	c := newFakeClientBuilder().WithScheme(scheme).WithObjects(
+		testMetalLB(metallbv1beta1.MetalLBSpec{}),
+		&corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: apply.AddressPoolConfigMap, Namespace: MetalLBTestNameSpace},
+			Data:       map[string]string{apply.AddressPoolConfigMap: "address-pools:\n- name: gold\n"},
+		},
+		&metallbv1alpha1.AddressPool{
+			ObjectMeta: metav1.ObjectMeta{Name: "gold", Namespace: MetalLBTestNameSpace},
+			Spec:       metallbv1alpha1.AddressPoolSpec{Protocol: "layer2", Addresses: []string{"10.0.0.0/24"}},
+		},
+		&metallbv1alpha1.AddressPool{
+			ObjectMeta: metav1.ObjectMeta{Name: "silver", Namespace: MetalLBTestNameSpace},
+			Spec:       metallbv1alpha1.AddressPoolSpec{Protocol: "layer2", Addresses: []string{"10.0.1.0/24"}},
+		},
+		&metallbv1alpha1.BGPPeer{
+			ObjectMeta: metav1.ObjectMeta{Name: "tor", Namespace: MetalLBTestNameSpace},
+			Spec:       metallbv1alpha1.BGPPeerSpec{MyASN: 64512, PeerASN: 64513, PeerAddress: "10.0.0.1"},
+		},
+		&metallbv1alpha1.Community{
+			ObjectMeta: metav1.ObjectMeta{Name: "premium", Namespace: MetalLBTestNameSpace},
+			Spec:       metallbv1alpha1.CommunitySpec{Name: "premium", Value: "64512:100"},
+		},
+	).Build()
+	export := &ConfigExport{
+		Client:    c,
+		Log:       ctrl.Log.WithName("configexport"),
+		Scheme:    scheme,
+		Namespace: MetalLBTestNameSpace,
+		Pools: &AddressPoolReconciler{
+			Client:    c,
+			Log:       ctrl.Log.WithName("controllers").WithName("AddressPool"),
+			Namespace: MetalLBTestNameSpace,
+		},
+	}
+	server := httptest.NewServer(export)
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + ConfigExportPath)
+	g.Expect(err).ToNot(HaveOccurred())
+	defer resp.Body.Close()
+	g.Expect(resp.StatusCode).To(Equal(http.StatusOK))
+	g.Expect(resp.Header.Get("Content-Type")).To(Equal("application/yaml"))
+	g.Expect(resp.Header.Get("Content-Disposition")).To(ContainSubstring("metallb-config.yaml"))
+
+	// Every document decodes back to the exported resource
+	body, err := ioutil.ReadAll(resp.Body)
+	g.Expect(err).ToNot(HaveOccurred())
+	decoder := serializer.NewCodecFactory(scheme).UniversalDeserializer()
+	kinds := []string{}
+	names := []string{}
+	for _, doc := range strings.Split(string(body), "---\n")[1:] {
+		obj, gvk, err := decoder.Decode([]byte(doc), nil, nil)
+		g.Expect(err).ToNot(HaveOccurred(), doc)
+		kinds = append(kinds, gvk.Kind)
+		if pool, ok := obj.(*metallbv1alpha1.AddressPool); ok {
+			names = append(names, pool.Name)
+			g.Expect(pool.Spec.Addresses).ToNot(BeEmpty())
+		}
+	}
+	g.Expect(kinds).To(Equal([]string{"ConfigMap", "MetalLB", "AddressPool", "AddressPool", "BGPPeer", "Community"}))
+	g.Expect(names).To(Equal([]string{"gold", "silver"}))
+	g.Expect(string(body)).To(ContainSubstring("- 10.0.1.0/24"))
+
+	resp, err = http.Post(server.URL+ConfigExportPath, "application/yaml", nil)
+	g.Expect(err).ToNot(HaveOccurred())
+	resp.Body.Close()
+	g.Expect(resp.StatusCode).To(Equal(http.StatusMethodNotAllowed))
+}
+
+func TestConfigExportRedactsPasswords(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	scheme := testScheme(g)
+	password := &corev1.LocalObjectReference{Name: "bgp-password"}
+	c := newFakeClientBuilder().WithScheme(scheme).WithObjects(
+		testMetalLB(metallbv1beta1.MetalLBSpec{}),
+		&corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: apply.AddressPoolConfigMap, Namespace: MetalLBTestNameSpace},
+			Data: map[string]string{apply.AddressPoolConfigMap: `peers:
+- my-asn: 64512
+  peer-asn: 64513
+  peer-address: 10.0.0.1
+  password: "s3cr3t"
+- my-asn: 64512
+  peer-asn: 64514
+  peer-address: 10.0.0.2
+  password: "s3cr3t"
+address-pools: []
+`},
+		},
+		&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "bgp-password", Namespace: MetalLBTestNameSpace},
+			Data:       map[string][]byte{metallbv1alpha1.PasswordSecretKey: []byte("s3cr3t")},
+		},
+		&metallbv1alpha1.BGPPeer{
+			ObjectMeta: metav1.ObjectMeta{Name: "spine", Namespace: MetalLBTestNameSpace},
+			Spec:       metallbv1alpha1.BGPPeerSpec{MyASN: 64512, PeerASN: 64513, PeerAddress: "10.0.0.1", PasswordSecret: password},
+		},
+		&metallbv1alpha1.BGPPeer{
+			ObjectMeta: metav1.ObjectMeta{Name: "tor", Namespace: MetalLBTestNameSpace},
+			Spec:       metallbv1alpha1.BGPPeerSpec{MyASN: 64512, PeerASN: 64514, PeerAddress: "10.0.0.2", PasswordSecret: password},
+		},
+	).Build()
+	export := &ConfigExport{
+		Client:    c,
+		Log:       ctrl.Log.WithName("configexport"),
+		Scheme:    scheme,
+		Namespace: MetalLBTestNameSpace,
+		Pools:     &AddressPoolReconciler{Client: c, Namespace: MetalLBTestNameSpace},
+	}
+
+	body, err := export.export(context.Background())
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(string(body)).ToNot(ContainSubstring("s3cr3t"))
+	decoder := serializer.NewCodecFactory(scheme).UniversalDeserializer()
+	kinds := []string{}
+	for _, doc := range strings.Split(string(body), "---\n")[1:] {
+		obj, gvk, err := decoder.Decode([]byte(doc), nil, nil)
+		g.Expect(err).ToNot(HaveOccurred(), doc)
+		kinds = append(kinds, gvk.Kind)
+		switch o := obj.(type) {
+		case *corev1.ConfigMap:
+			// The rest of the configuration is kept
+			g.Expect(o.Data[apply.AddressPoolConfigMap]).To(MatchYAML(`peers:
+- my-asn: 64512
+  peer-asn: 64513
+  peer-address: 10.0.0.1
+  password: <redacted>
+- my-asn: 64512
+  peer-asn: 64514
+  peer-address: 10.0.0.2
+  password: <redacted>
+address-pools: []
+`))
+		case *corev1.Secret:
+			// The Secret referenced by both peers is exported once, its keys kept
+			g.Expect(o.Name).To(Equal("bgp-password"))
+			g.Expect(o.Data).To(BeEmpty())
+			g.Expect(o.StringData).To(Equal(map[string]string{metallbv1alpha1.PasswordSecretKey: "<redacted>"}))
+		}
+	}
+	g.Expect(kinds).To(Equal([]string{"ConfigMap", "MetalLB", "BGPPeer", "BGPPeer", "Secret"}))
+}
+
+func TestConfigExportStart(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	c := newFakeClientBuilder().WithScheme(testScheme(g)).Build()
+	export := &ConfigExport{
+		Client:    c,
+		Log:       ctrl.Log.WithName("configexport"),
+		Scheme:    testScheme(g),
+		Namespace: MetalLBTestNameSpace,
+		Addr:      "127.0.0.1:0",
+		Pools:     &AddressPoolReconciler{Client: c, Namespace: MetalLBTestNameSpace},
+	}
+	g.Expect(export.NeedLeaderElection()).To(BeFalse())
+
+	// The server stops with the manager
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- export.Start(ctx) }()
+	cancel()
+	g.Eventually(done).Should(Receive(BeNil()))
+}
@@ -0,0 +1,121 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	metallbv1alpha1 "github.com/metallb/metallb-operator/api/v1alpha1"
+	metallbv1beta1 "github.com/metallb/metallb-operator/api/v1beta1"
+)
+
+// unstructuredServicesClient lists no services. The fake client stores the
+// services applied as unstructured objects, and fails to list them as typed ones.
+type unstructuredServicesClient struct {
+	client.Client
+}
+
+func (c unstructuredServicesClient) List(ctx context.Context, list client.ObjectList, opts ...client.ListOption) error {
+	if _, ok := list.(*corev1.ServiceList); ok {
+		return nil
+	}
+	return c.Client.List(ctx, list, opts...)
+}
+
+func TestAddPoolRestartsSpeakerOnly(t *testing.T) {
+	g := NewGomegaWithT(t)
+	manifestPath := AddressPoolManifestPath
+	AddressPoolManifestPath = "../bindata/configuration/address-pool"
+	defer func() { AddressPoolManifestPath = manifestPath }()
+
+	gold := &metallbv1alpha1.AddressPool{
+		ObjectMeta: metav1.ObjectMeta{Name: "gold", Namespace: MetalLBTestNameSpace},
+		Spec:       metallbv1alpha1.AddressPoolSpec{Protocol: "layer2", Addresses: []string{"10.0.0.0/24"}},
+	}
+	c := newFakeClientBuilder().WithScheme(testScheme(g)).WithObjects(gold, testMetalLB(metallbv1beta1.MetalLBSpec{})).Build()
+	pools := &AddressPoolReconciler{
+		Client:    unstructuredServicesClient{c},
+		Log:       ctrl.Log.WithName("controllers").WithName("AddressPool"),
+		Namespace: MetalLBTestNameSpace,
+	}
+	reconcilePool := func(obj client.Object) {
+		key := types.NamespacedName{Name: obj.GetName(), Namespace: obj.GetNamespace()}
+		_, err := pools.Reconcile(context.Background(), ctrl.Request{NamespacedName: key})
+		g.Expect(err).ToNot(HaveOccurred())
+	}
+
+	reconcilePool(gold)
+	reconcileTestMetalLB(g, c)
+	// The pod gets the checksum of the configuration from the pod template
+	pod := speakerPod("speaker-1", "v1", true)
+	pod.Annotations = map[string]string{ConfigChecksumAnnotation: speakerTemplateChecksum(g, c)}
+	g.Expect(c.Create(context.Background(), pod)).To(Succeed())
+
+	workloadVersions := func() (string, string) {
+		ds := &appsv1.DaemonSet{}
+		g.Expect(c.Get(context.Background(), types.NamespacedName{Name: "speaker", Namespace: MetalLBTestNameSpace}, ds)).To(Succeed())
+		deploy := &appsv1.Deployment{}
+		g.Expect(c.Get(context.Background(), types.NamespacedName{Name: "controller", Namespace: MetalLBTestNameSpace}, deploy)).To(Succeed())
+		return ds.ResourceVersion, deploy.ResourceVersion
+	}
+	speakerVersion, controllerVersion := workloadVersions()
+
+	silver := &metallbv1alpha1.AddressPool{
+		ObjectMeta: metav1.ObjectMeta{Name: "silver", Namespace: MetalLBTestNameSpace},
+		Spec:       metallbv1alpha1.AddressPoolSpec{Protocol: "layer2", Addresses: []string{"10.0.1.0/24"}},
+	}
+	g.Expect(c.Create(context.Background(), silver)).To(Succeed())
+	reconcilePool(silver)
+	reconcileTestMetalLB(g, c)
+
+	// The speaker pod did not load the new configuration, but it is left to
+	// the rollout of the DaemonSet rather than restarted
+	speakers := &SpeakerPodReconciler{
+		Client:         c,
+		Log:            ctrl.Log.WithName("controllers").WithName("SpeakerPod"),
+		Namespace:      MetalLBTestNameSpace,
+		SpeakerMetrics: fakeSpeakerMetrics{pod.Name: false},
+	}
+	_, err := speakers.Reconcile(context.Background(), ctrl.Request{NamespacedName: types.NamespacedName{Name: pod.Name, Namespace: pod.Namespace}})
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(speakerPodExists(g, c, pod.Name)).To(BeTrue())
+
+	// The speaker DaemonSet rolls with the new config checksum, the
+	// controller Deployment is not updated
+	newSpeakerVersion, newControllerVersion := workloadVersions()
+	g.Expect(newSpeakerVersion).ToNot(Equal(speakerVersion))
+	g.Expect(newControllerVersion).To(Equal(controllerVersion))
+}
+
+func TestApplyMetalLBChangedWorkloadOnly(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	c := newFakeClientBuilder().WithScheme(testScheme(g)).WithObjects(testMetalLB(metallbv1beta1.MetalLBSpec{})).Build()
+	reconcileTestMetalLB(g, c)
+
+	speaker := &appsv1.DaemonSet{}
+	g.Expect(c.Get(context.Background(), types.NamespacedName{Name: "speaker", Namespace: MetalLBTestNameSpace}, speaker)).To(Succeed())
+	controller := &appsv1.Deployment{}
+	g.Expect(c.Get(context.Background(), types.NamespacedName{Name: "controller", Namespace: MetalLBTestNameSpace}, controller)).To(Succeed())
+
+	metallb := &metallbv1beta1.MetalLB{}
+	g.Expect(c.Get(context.Background(), types.NamespacedName{Name: defaultMetalLBCrName, Namespace: MetalLBTestNameSpace}, metallb)).To(Succeed())
+	metallb.Spec.SpeakerDNSPolicy = corev1.DNSClusterFirstWithHostNet
+	g.Expect(c.Update(context.Background(), metallb)).To(Succeed())
+	reconcileTestMetalLB(g, c)
+
+	newSpeaker := &appsv1.DaemonSet{}
+	g.Expect(c.Get(context.Background(), types.NamespacedName{Name: "speaker", Namespace: MetalLBTestNameSpace}, newSpeaker)).To(Succeed())
+	g.Expect(newSpeaker.Spec.Template.Spec.DNSPolicy).To(Equal(corev1.DNSClusterFirstWithHostNet))
+	g.Expect(newSpeaker.ResourceVersion).ToNot(Equal(speaker.ResourceVersion))
+	newController := &appsv1.Deployment{}
+	g.Expect(c.Get(context.Background(), types.NamespacedName{Name: "controller", Namespace: MetalLBTestNameSpace}, newController)).To(Succeed())
+	g.Expect(newController.ResourceVersion).To(Equal(controller.ResourceVersion))
+}
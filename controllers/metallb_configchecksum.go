@@ -0,0 +1,102 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"sort"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	uns "k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	"github.com/metallb/metallb-operator/pkg/apply"
+)
+
+// ConfigChecksumAnnotation is set on the speaker pod template with the
+// checksum of the MetalLB ConfigMap, so that a changed configuration rolls
+// the speakers.
+const ConfigChecksumAnnotation = "metallb.io/config-checksum"
+
+// configMapChecksum returns the sha256 of the ConfigMap data. The keys are
+// hashed in order, so the same data always yields the same checksum.
+func configMapChecksum(data map[string]string) string {
+	keys := make([]string, 0, len(data))
+	for key := range data {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	hash := sha256.New()
+	for _, key := range keys {
+		hash.Write([]byte(key))
+		hash.Write([]byte{0})
+		hash.Write([]byte(data[key]))
+		hash.Write([]byte{0})
+	}
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// speakerConfigChecksum returns the checksum of the MetalLB ConfigMap the
+// speakers load, empty when there is no ConfigMap yet.
+func (r *MetalLBReconciler) speakerConfigChecksum(ctx context.Context, namespace string) (string, error) {
+	configMap := &corev1.ConfigMap{}
+	err := r.Get(ctx, types.NamespacedName{Name: apply.AddressPoolConfigMap, Namespace: namespace}, configMap)
+	if apierrors.IsNotFound(err) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return configMapChecksum(configMap.Data), nil
+}
+
+// setConfigChecksum stamps the checksum on the pod template of the speaker
+// DaemonSet.
+func setConfigChecksum(objs []*uns.Unstructured, checksum string) error {
+	for _, obj := range objs {
+		if obj.GetKind() != "DaemonSet" || obj.GetName() != "speaker" {
+			continue
+		}
+		annotations, _, err := uns.NestedStringMap(obj.Object, "spec", "template", "metadata", "annotations")
+		if err != nil {
+			return err
+		}
+		if annotations == nil {
+			annotations = map[string]string{}
+		}
+		annotations[ConfigChecksumAnnotation] = checksum
+		if err := uns.SetNestedStringMap(obj.Object, annotations, "spec", "template", "metadata", "annotations"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// configMapMetalLB maps a change of the MetalLB ConfigMap to the MetalLB
+// resource, so that the checksum of the speakers is updated.
+func (r *MetalLBReconciler) configMapMetalLB(obj client.Object) []reconcile.Request {
+	if obj.GetName() != apply.AddressPoolConfigMap || !r.isWorkloadsNamespace(obj.GetNamespace()) {
+		return nil
+	}
+	return []reconcile.Request{{NamespacedName: types.NamespacedName{Name: defaultMetalLBCrName, Namespace: r.Namespace}}}
+}
@@ -0,0 +1,66 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	metallbv1beta1 "github.com/metallb/metallb-operator/api/v1beta1"
+	"github.com/metallb/metallb-operator/pkg/apply"
+)
+
+func TestConfigMapChecksum(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	config := "address-pools:\n- name: gold\n  protocol: layer2\n  addresses:\n  - 10.0.0.0/24\n"
+	checksum := configMapChecksum(map[string]string{"config": config, "other": "value"})
+	g.Expect(checksum).To(HaveLen(64))
+
+	// The identical config yields the identical checksum
+	for i := 0; i < 10; i++ {
+		g.Expect(configMapChecksum(map[string]string{"other": "value", "config": config})).To(Equal(checksum))
+	}
+
+	g.Expect(configMapChecksum(map[string]string{"config": config + "- name: silver\n"})).ToNot(Equal(checksum))
+	// The keys and values are not mixed up
+	g.Expect(configMapChecksum(map[string]string{"a": "bc"})).ToNot(Equal(configMapChecksum(map[string]string{"ab": "c"})))
+}
+
+func TestSpeakerConfigChecksumAnnotation(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	configMap := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: apply.AddressPoolConfigMap, Namespace: MetalLBTestNameSpace},
+		Data:       map[string]string{apply.AddressPoolConfigMap: "address-pools:\n"},
+	}
+	c := newFakeClientBuilder().WithScheme(testScheme(g)).WithObjects(testMetalLB(metallbv1beta1.MetalLBSpec{}), configMap).Build()
+	speaker := func() *appsv1.DaemonSet {
+		ds := &appsv1.DaemonSet{}
+		g.Expect(c.Get(context.Background(), types.NamespacedName{Name: "speaker", Namespace: MetalLBTestNameSpace}, ds)).To(Succeed())
+		return ds
+	}
+
+	reconcileTestMetalLB(g, c)
+	ds := speaker()
+	g.Expect(ds.Spec.Template.Annotations).To(HaveKeyWithValue(ConfigChecksumAnnotation, configMapChecksum(configMap.Data)))
+
+	// The unchanged config does not update the speakers
+	reconcileTestMetalLB(g, c)
+	g.Expect(speaker().ResourceVersion).To(Equal(ds.ResourceVersion))
+
+	g.Expect(c.Get(context.Background(), types.NamespacedName{Name: apply.AddressPoolConfigMap, Namespace: MetalLBTestNameSpace}, configMap)).To(Succeed())
+	configMap.Data[apply.AddressPoolConfigMap] = "address-pools:\n- name: gold\n"
+	g.Expect(c.Update(context.Background(), configMap)).To(Succeed())
+	r := &MetalLBReconciler{Client: c, Namespace: MetalLBTestNameSpace}
+	g.Expect(r.configMapMetalLB(configMap)).To(HaveLen(1))
+	reconcileTestMetalLB(g, c)
+	g.Expect(speaker().Spec.Template.Annotations).To(HaveKeyWithValue(ConfigChecksumAnnotation, configMapChecksum(configMap.Data)))
+
+	// Only the MetalLB ConfigMap is mapped
+	g.Expect(r.configMapMetalLB(&corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "metallb-excludel2", Namespace: MetalLBTestNameSpace}})).To(BeEmpty())
+}
@@ -0,0 +1,139 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/metallb/metallb-operator/pkg/apply"
+	"github.com/metallb/metallb-operator/pkg/status"
+)
+
+const (
+	// speakerMetricsDeadline bounds the scrapes of all the speakers.
+	speakerMetricsDeadline = 3 * time.Second
+	// speakerMetricsConcurrency is how many speakers are scraped at once.
+	speakerMetricsConcurrency = 16
+	// speakerConfigUnverifiedRetry is how long until the speakers whose
+	// metrics could not be read are scraped again.
+	speakerConfigUnverifiedRetry = 30 * time.Second
+)
+
+// speakerConfigUnverifiedError is returned when no speaker is known to run a
+// previous configuration, but some could not be checked as their metrics
+// can't be read.
+type speakerConfigUnverifiedError struct {
+	Message string
+}
+
+func (e speakerConfigUnverifiedError) Error() string { return e.Message }
+
+// checkSpeakersConfigLoaded returns a MetalLBResourcesNotReadyError until the
+// ready speaker pods all loaded the current MetalLB ConfigMap. This keeps the
+// MetalLB from being Available while the speakers still announce the previous
+// pools. A pod has not loaded it while it carries the ConfigChecksumAnnotation
+// of a previous ConfigMap, i.e. it was not rolled yet, or while its
+// metallb_k8s_client_config_loaded_bool metric does not confirm the load. The
+// speakers are scraped concurrently within speakerMetricsDeadline, those whose
+// metrics can't be read are reported by a speakerConfigUnverifiedError.
+func (r *MetalLBReconciler) checkSpeakersConfigLoaded(ctx context.Context, namespace string) error {
+	configMap := &corev1.ConfigMap{}
+	err := r.Get(ctx, types.NamespacedName{Name: apply.AddressPoolConfigMap, Namespace: namespace}, configMap)
+	if apierrors.IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	pods := &corev1.PodList{}
+	if err := r.List(ctx, pods, client.InNamespace(namespace), client.MatchingLabels{"component": speakerComponentLabel}); err != nil {
+		return err
+	}
+	ready := []*corev1.Pod{}
+	for i := range pods.Items {
+		pod := &pods.Items[i]
+		if pod.DeletionTimestamp != nil || !isPodReady(pod) {
+			continue
+		}
+		ready = append(ready, pod)
+	}
+
+	current := configMapChecksum(configMap.Data)
+	loaded := make([]bool, len(ready))
+	errs := make([]error, len(ready))
+	scrapeCtx, cancel := context.WithTimeout(ctx, speakerMetricsDeadline)
+	defer cancel()
+	limit := make(chan struct{}, speakerMetricsConcurrency)
+	var wg sync.WaitGroup
+	for i, pod := range ready {
+		wg.Add(1)
+		go func(i int, pod *corev1.Pod) {
+			defer wg.Done()
+			limit <- struct{}{}
+			defer func() { <-limit }()
+			loaded[i], errs[i] = r.speakerConfigLoaded(scrapeCtx, pod, current)
+		}(i, pod)
+	}
+	wg.Wait()
+
+	stale, unverified := 0, []string{}
+	for i, pod := range ready {
+		if errs[i] != nil {
+			r.Log.Info(fmt.Sprintf("Could not read the metrics of speaker pod %s: %s", pod.Name, errs[i]))
+			unverified = append(unverified, pod.Name)
+			continue
+		}
+		if !loaded[i] {
+			stale++
+		}
+	}
+	if stale > 0 {
+		return status.MetalLBResourcesNotReadyError{Message: fmt.Sprintf(
+			"MetalLB configuration not loaded, %d of %d speakers did not load ConfigMap %s, resourceVersion %s",
+			stale, len(ready), configMap.Name, configMap.ResourceVersion)}
+	}
+	if len(unverified) > 0 {
+		sort.Strings(unverified)
+		return speakerConfigUnverifiedError{Message: fmt.Sprintf(
+			"Could not verify %d of %d speakers loaded ConfigMap %s, resourceVersion %s, their metrics can't be read: %s",
+			len(unverified), len(ready), configMap.Name, configMap.ResourceVersion, strings.Join(unverified, ", "))}
+	}
+	return nil
+}
+
+// speakerConfigLoaded returns whether the speaker pod loaded the
+// configuration with the given checksum, an error when its metrics can't be
+// read.
+func (r *MetalLBReconciler) speakerConfigLoaded(ctx context.Context, pod *corev1.Pod, checksum string) (bool, error) {
+	if podChecksum, ok := pod.Annotations[ConfigChecksumAnnotation]; ok && podChecksum != checksum {
+		return false, nil
+	}
+	if r.SpeakerMetrics == nil {
+		return true, nil
+	}
+	return r.SpeakerMetrics.ConfigLoaded(ctx, pod)
+}
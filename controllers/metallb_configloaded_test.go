@@ -0,0 +1,130 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	metallbv1beta1 "github.com/metallb/metallb-operator/api/v1beta1"
+	"github.com/metallb/metallb-operator/pkg/apply"
+	"github.com/metallb/metallb-operator/pkg/status"
+)
+
+// fakeSpeakerMetrics reports whether each speaker pod loaded its
+// configuration, by pod name. The pods not listed have no metrics.
+type fakeSpeakerMetrics map[string]bool
+
+func (m fakeSpeakerMetrics) ConfigLoaded(ctx context.Context, pod *corev1.Pod) (bool, error) {
+	loaded, ok := m[pod.Name]
+	if !ok {
+		return false, errors.New("connection refused")
+	}
+	return loaded, nil
+}
+
+func TestMetalLBSpeakersConfigLoaded(t *testing.T) {
+	g := NewGomegaWithT(t)
+	manifestPath := ManifestPath
+	ManifestPath = "../bindata/deployment"
+	defer func() { ManifestPath = manifestPath }()
+
+	oldConfig := map[string]string{apply.AddressPoolConfigMap: "address-pools:\n- name: gold\n  protocol: layer2\n  addresses:\n  - 172.20.0.0/24\n"}
+	newConfig := map[string]string{apply.AddressPoolConfigMap: oldConfig[apply.AddressPoolConfigMap] + "- name: silver\n  protocol: layer2\n  addresses:\n  - 172.21.0.0/24\n"}
+	speakerPod := func(name string, config map[string]string) *corev1.Pod {
+		return &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: name, Namespace: MetalLBTestNameSpace,
+				Labels:      map[string]string{"component": speakerComponentLabel},
+				Annotations: map[string]string{ConfigChecksumAnnotation: configMapChecksum(config)},
+			},
+			Status: corev1.PodStatus{Conditions: []corev1.PodCondition{{Type: corev1.PodReady, Status: corev1.ConditionTrue}}},
+		}
+	}
+	objs := append(readyWorkloads(),
+		testMetalLB(metallbv1beta1.MetalLBSpec{}),
+		&corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: apply.AddressPoolConfigMap, Namespace: MetalLBTestNameSpace},
+			Data:       newConfig,
+		},
+		speakerPod("speaker-a", newConfig),
+		speakerPod("speaker-b", newConfig),
+		speakerPod("speaker-c", oldConfig),
+	)
+	c := statusKeepingClient{newFakeClientBuilder().WithScheme(testScheme(g)).WithObjects(objs...).Build()}
+	metrics := fakeSpeakerMetrics{"speaker-a": true, "speaker-b": false, "speaker-c": true}
+	reconciler := &MetalLBReconciler{
+		Client:         c,
+		Scheme:         testScheme(g),
+		Log:            ctrl.Log.WithName("controllers").WithName("MetalLB"),
+		Namespace:      MetalLBTestNameSpace,
+		SpeakerMetrics: metrics,
+	}
+	reconcile := func() []metav1.Condition {
+		key := types.NamespacedName{Name: defaultMetalLBCrName, Namespace: MetalLBTestNameSpace}
+		_, _ = reconciler.Reconcile(context.Background(), ctrl.Request{NamespacedName: key})
+		metallb := &metallbv1beta1.MetalLB{}
+		g.Expect(c.Get(context.Background(), key, metallb)).To(Succeed())
+		return metallb.Status.Conditions
+	}
+
+	// speaker-b failed to load the current configuration, speaker-c was not
+	// rolled yet and still runs the previous one
+	conditions := reconcile()
+	progressing := meta.FindStatusCondition(conditions, status.ConditionProgressing)
+	g.Expect(progressing).ToNot(BeNil())
+	g.Expect(progressing.Status).To(Equal(metav1.ConditionTrue))
+	g.Expect(progressing.Reason).To(Equal("MetalLBResourcesNotReady"))
+	g.Expect(progressing.Message).To(HavePrefix("MetalLB configuration not loaded, 2 of 3 speakers did not load ConfigMap config, resourceVersion "))
+	g.Expect(meta.IsStatusConditionTrue(conditions, status.ConditionAvailable)).To(BeFalse())
+
+	// speaker-b loads it, speaker-c is replaced by a pod of the current
+	// template whose metrics can't be read
+	metrics["speaker-b"] = true
+	g.Expect(c.Delete(context.Background(), &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "speaker-c", Namespace: MetalLBTestNameSpace}})).To(Succeed())
+	g.Expect(c.Create(context.Background(), speakerPod("speaker-d", newConfig))).To(Succeed())
+	conditions = reconcile()
+	available := meta.FindStatusCondition(conditions, status.ConditionAvailable)
+	g.Expect(available).ToNot(BeNil())
+	g.Expect(available.Status).To(Equal(metav1.ConditionTrue))
+	g.Expect(available.Reason).To(Equal("SpeakerConfigUnverified"))
+	g.Expect(available.Message).To(HaveSuffix("their metrics can't be read: speaker-d"))
+	g.Expect(meta.IsStatusConditionTrue(conditions, status.ConditionProgressing)).To(BeFalse())
+
+	// Once its metrics are read, the MetalLB is Available as usual
+	metrics["speaker-d"] = true
+	conditions = reconcile()
+	available = meta.FindStatusCondition(conditions, status.ConditionAvailable)
+	g.Expect(available.Status).To(Equal(metav1.ConditionTrue))
+	g.Expect(available.Reason).ToNot(Equal("SpeakerConfigUnverified"))
+}
+
+func TestCheckSpeakersConfigLoaded(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	config := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: apply.AddressPoolConfigMap, Namespace: MetalLBTestNameSpace},
+		Data:       map[string]string{apply.AddressPoolConfigMap: "address-pools: []\n"},
+	}
+	stale := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{
+		Name: "speaker-a", Namespace: MetalLBTestNameSpace,
+		Labels:      map[string]string{"component": speakerComponentLabel},
+		Annotations: map[string]string{ConfigChecksumAnnotation: configMapChecksum(map[string]string{apply.AddressPoolConfigMap: "peers: []\n"})},
+	}}
+	// Nothing to wait for without the ConfigMap or a ready pod, the pods not
+	// ready being reported by the DaemonSet
+	for _, objs := range [][]client.Object{{stale}, {config}, {config, stale}} {
+		r := &MetalLBReconciler{
+			Client:         newFakeClientBuilder().WithScheme(testScheme(g)).WithObjects(objs...).Build(),
+			SpeakerMetrics: fakeSpeakerMetrics{"speaker-a": false},
+		}
+		g.Expect(r.checkSpeakersConfigLoaded(context.Background(), MetalLBTestNameSpace)).To(Succeed())
+	}
+}
@@ -19,46 +19,71 @@ package controllers
 import (
 	"context"
 	"fmt"
-	"os"
 	"time"
 
 	"github.com/go-logr/logr"
 	"github.com/pkg/errors"
 
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	schedulingv1 "k8s.io/api/scheduling/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	uns "k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
-	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
 
 	metallbv1beta1 "github.com/metallb/metallb-operator/api/v1beta1"
 	"github.com/metallb/metallb-operator/pkg/apply"
-	"github.com/metallb/metallb-operator/pkg/platform"
-	"github.com/metallb/metallb-operator/pkg/render"
 	"github.com/metallb/metallb-operator/pkg/status"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 )
 
 const defaultMetalLBCrName = "metallb"
 
+// PlatformInfo describes the platform the operator runs on. It is implemented
+// by platform.PlatformInfo, and by a fake in the tests.
+type PlatformInfo interface {
+	IsOpenShift() bool
+	HasServiceMonitors() bool
+}
+
 // MetalLBReconciler reconciles a MetalLB object
 type MetalLBReconciler struct {
 	client.Client
 	Log          logr.Logger
 	Scheme       *runtime.Scheme
-	PlatformInfo platform.PlatformInfo
+	PlatformInfo PlatformInfo
 	Namespace    string
+	// TargetNamespaces are the namespaces, besides Namespace, MetalLB may be
+	// deployed to, AllPoolNamespaces for all of them.
+	TargetNamespaces []string
+	// SpeakerMetrics reads whether the speakers loaded the configuration,
+	// nil to only rely on the config checksum of the speaker pods.
+	SpeakerMetrics SpeakerMetrics
 }
 
 var ManifestPath = "./bindata/deployment"
 
 // Namespace Scoped
+// +kubebuilder:rbac:groups="",namespace=metallb-system,resources=services,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=apps,namespace=metallb-system,resources=deployments;daemonsets,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups="",namespace=metallb-system,resources=secrets,verbs=get;list;watch
+// +kubebuilder:rbac:groups="",namespace=metallb-system,resources=configmaps,verbs=get;list;watch
+// +kubebuilder:rbac:groups=monitoring.coreos.com,namespace=metallb-system,resources=prometheusrules,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=monitoring.coreos.com,namespace=metallb-system,resources=servicemonitors,verbs=get;list;watch;create;update;patch;delete
 
 // Cluster Scoped
 // +kubebuilder:rbac:groups=metallb.io,resources=metallbs,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=metallb.io,resources=metallbs/status,verbs=get;update;patch
 // +kubebuilder:rbac:groups=policy,resources=podsecuritypolicies,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=metallb.io,resources=metallbs/finalizers,verbs=delete;get;update;patch
+// +kubebuilder:rbac:groups="",resources=namespaces,verbs=get;list;watch
 
 func (r *MetalLBReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
 	_ = context.Background()
@@ -69,78 +94,288 @@ func (r *MetalLBReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ct
 	if err != nil {
 		if apierrors.IsNotFound(err) {
 			// Request object not found, could have been deleted after reconcile request.
-			// Owned objects are automatically garbage collected. For additional cleanup logic use finalizers.
+			// The owned objects were deleted by the finalizer, or are garbage collected.
 			// Return and don't requeue
+			status.ForgetDegraded("MetalLB", req.Namespace, req.Name)
 			return ctrl.Result{}, nil
 		}
 		// Error reading the object - requeue the request.
 		return ctrl.Result{}, err
 	}
 
+	if !instance.DeletionTimestamp.IsZero() {
+		return r.finalizeMetalLB(ctx, instance)
+	}
+	if req.Name == defaultMetalLBCrName {
+		if err := r.addFinalizer(ctx, instance); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+
+	result, err := r.reconcileMetalLB(ctx, req, instance)
+	if err := status.UpdateLastError(context.TODO(), r.Client, instance, err); err != nil {
+		logger.Info("Failed to update metallb status", "Desired status", "lastError")
+	}
+	return result, err
+}
+
+// reconcileMetalLB reconciles an existing MetalLB resource, the error returned
+// is reported in its status.
+func (r *MetalLBReconciler) reconcileMetalLB(ctx context.Context, req ctrl.Request, instance *metallbv1beta1.MetalLB) (ctrl.Result, error) {
+	logger := r.Log.WithValues("metallb", req.NamespacedName)
+
 	if req.Name != defaultMetalLBCrName {
 		err := fmt.Errorf("MetalLB resource name must be '%s'", defaultMetalLBCrName)
 		logger.Error(err, "Invalid MetalLB resource name", "name", req.Name)
+		if err := status.UpdateConfigValid(context.TODO(), r.Client, instance, err); err != nil {
+			logger.Error(err, "Failed to update metallb status", "Desired status", status.ConditionConfigValid)
+		}
 		if err := status.Update(context.TODO(), r.Client, instance, status.ConditionDegraded, "IncorrectMetalLBResourceName", fmt.Sprintf("Incorrect MetalLB resource name: %s", req.Name)); err != nil {
 			logger.Error(err, "Failed to update metallb status", "Desired status", status.ConditionDegraded)
 		}
 		return ctrl.Result{}, nil // Return success to avoid requeue
 	}
 
-	result, condition, err := r.reconcileResource(ctx, req, instance)
+	// An invalid configuration is reported on its own condition, the
+	// health of the deployed workloads is still reported below.
+	objs, configErr := r.renderMetalLBObjects(instance)
+	if configErr == nil {
+		configErr = r.checkMetricsTLSSecret(ctx, instance.WorkloadsNamespace(), &instance.Spec)
+	}
+	if configErr != nil {
+		objs = nil
+		logger.Error(configErr, "Invalid MetalLB configuration")
+	}
+	if err := status.UpdateConfigValid(context.TODO(), r.Client, instance, configErr); err != nil {
+		logger.Info("Failed to update metallb status", "Desired status", status.ConditionConfigValid)
+	}
+
+	if instance.Spec.ManageWorkloads != nil && !*instance.Spec.ManageWorkloads {
+		// The deployed workloads are not ours to apply nor to check
+		err := status.Update(context.TODO(), r.Client, instance, status.ConditionAvailable, "WorkloadsNotManaged",
+			"The MetalLB speaker and controller are not managed by the operator, only the configuration is")
+		if err != nil {
+			logger.Info("Failed to update metallb status", "Desired status", status.ConditionAvailable)
+		}
+		return ctrl.Result{}, nil
+	}
+
+	if imageErr := validateImages(&instance.Spec); imageErr != nil {
+		message := fmt.Sprintf("%s, the MetalLB workloads keep their current images until it is fixed", imageErr)
+		if err := status.Update(context.TODO(), r.Client, instance, status.ConditionDegraded, "InvalidImage", message); err != nil {
+			logger.Error(err, "Failed to update metallb status", "Desired status", status.ConditionDegraded)
+		}
+		return ctrl.Result{}, nil // Fixing the image updates the MetalLB resource
+	}
+
+	if configErr == nil {
+		speakerImage, controllerImage := metalLBImages(&instance.Spec)
+		err := checkMetalLBVersion(instance.Spec.MinMetalLBVersion, speakerImage, controllerImage)
+		if err != nil {
+			logger.Error(err, "Unsupported MetalLB version")
+			if err := status.Update(context.TODO(), r.Client, instance, status.ConditionDegraded, "UnsupportedMetalLBVersion", err.Error()); err != nil {
+				logger.Error(err, "Failed to update metallb status", "Desired status", status.ConditionDegraded)
+			}
+			return ctrl.Result{}, nil // The images only change with the operator deployment
+		}
+		message, err := r.checkPodSecurity(ctx, instance.WorkloadsNamespace(), objs)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+		if message != "" {
+			logger.Info("The namespace does not admit the speaker", "reason", message)
+			if err := status.Update(context.TODO(), r.Client, instance, status.ConditionDegraded, "NamespaceNotPrivileged", message); err != nil {
+				logger.Error(err, "Failed to update metallb status", "Desired status", status.ConditionDegraded)
+			}
+			return ctrl.Result{}, nil // The namespace is watched
+		}
+	}
+
+	if objs != nil {
+		if err := r.checkControllerSchedulable(ctx, instance, objs); err != nil {
+			logger.Info("Failed to check the controller node selector", "error", err)
+		}
+		if err := r.checkPriorityClasses(ctx, instance); err != nil {
+			logger.Info("Failed to check the priority classes", "error", err)
+		}
+		checksum, err := r.speakerConfigChecksum(ctx, instance.WorkloadsNamespace())
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+		if checksum != "" {
+			if err := setConfigChecksum(objs, checksum); err != nil {
+				return ctrl.Result{}, err
+			}
+		}
+	}
+
+	result, condition, err := r.reconcileResource(ctx, req, instance, objs)
+	reason, message := "", ""
+	var notReady status.MetalLBResourcesNotReadyError
+	if errors.As(err, &notReady) {
+		// Still rolling out, reported as Progressing and requeued
+		reason, message = "MetalLBResourcesNotReady", notReady.Message
+		err = nil
+	}
+	var unverified speakerConfigUnverifiedError
+	if errors.As(err, &unverified) {
+		// Available, the speakers may still run a previous configuration
+		reason, message = "SpeakerConfigUnverified", unverified.Message
+		err = nil
+	}
+	if condition != status.ConditionDegraded {
+		if secretErr := r.checkMemberlistSecret(ctx, instance.WorkloadsNamespace()); secretErr != nil {
+			logger.Error(secretErr, "Invalid memberlist secret")
+			if err := status.Update(context.TODO(), r.Client, instance, status.ConditionDegraded, "InvalidMemberlistSecret", secretErr.Error()); err != nil {
+				logger.Error(err, "Failed to update metallb status", "Desired status", status.ConditionDegraded)
+			}
+			return result, err
+		}
+	}
+	if condition != status.ConditionDegraded && err == nil && instance.Spec.BGPBackend == metallbv1beta1.BGPBackendFRR {
+		frrMessage, err := r.checkFRRContainers(ctx, instance.WorkloadsNamespace())
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+		if frrMessage != "" {
+			logger.Info("The FRR sidecar of the speakers is unhealthy", "reason", frrMessage)
+			condition, reason, message = status.ConditionDegraded, "FRRUnhealthy", frrMessage
+			// The speaker pods are not watched
+			if result.RequeueAfter == 0 {
+				result.RequeueAfter = 5 * time.Second
+			}
+		}
+	}
+	if grace := r.degradedGrace(instance, condition == status.ConditionDegraded); grace > 0 {
+		logger.Info("MetalLB is unhealthy, not reporting it as degraded yet", "grace", grace)
+		condition = status.ConditionProgressing
+		if result.RequeueAfter == 0 || grace < result.RequeueAfter {
+			result.RequeueAfter = grace
+		}
+	}
 	if condition != "" {
-		errorMsg, wrappedErrMsg := "", ""
 		if err != nil {
 			if errors.Unwrap(err) != nil {
-				wrappedErrMsg = errors.Unwrap(err).Error()
+				message = errors.Unwrap(err).Error()
 			}
 		}
-		if err := status.Update(context.TODO(), r.Client, instance, condition, errorMsg, wrappedErrMsg); err != nil {
+		if err := status.Update(context.TODO(), r.Client, instance, condition, reason, message); err != nil {
 			logger.Info("Failed to update metallb status", "Desired status", status.ConditionAvailable)
 		}
 	}
 	return result, err
 }
 
-func (r *MetalLBReconciler) reconcileResource(ctx context.Context, req ctrl.Request, instance *metallbv1beta1.MetalLB) (ctrl.Result, string, error) {
-	err := r.syncMetalLBResources(instance)
-	if err != nil {
-		return ctrl.Result{}, status.ConditionDegraded, errors.Wrapf(err, "FailedToSyncMetalLBResources")
+// reconcileResource applies the rendered objects and reports the health of the
+// MetalLB workloads. When objs is nil, as the configuration is invalid, the
+// deployed workloads are left untouched.
+func (r *MetalLBReconciler) reconcileResource(ctx context.Context, req ctrl.Request, instance *metallbv1beta1.MetalLB, objs []*uns.Unstructured) (ctrl.Result, string, error) {
+	if objs != nil {
+		err := r.syncMetalLBResources(instance, objs)
+		if err != nil {
+			return ctrl.Result{}, status.ConditionDegraded, errors.Wrapf(err, "FailedToSyncMetalLBResources")
+		}
+		// The env was applied above, so it missing means something else, e.g.
+		// an admission webhook, removed it.
+		if err := r.checkSpeakerNodeName(ctx, instance.WorkloadsNamespace()); err != nil {
+			return ctrl.Result{RequeueAfter: 5 * time.Second}, status.ConditionDegraded, errors.Wrapf(err, "SpeakerNodeNameMissing")
+		}
+	}
+	err := status.IsMetalLBAvailable(context.TODO(), r.Client, instance.WorkloadsNamespace())
+	if err == nil {
+		err = r.checkSpeakersConfigLoaded(ctx, instance.WorkloadsNamespace())
+	}
+	if _, ok := err.(speakerConfigUnverifiedError); ok {
+		return ctrl.Result{RequeueAfter: speakerConfigUnverifiedRetry}, status.ConditionAvailable, err
 	}
-	err = status.IsMetalLBAvailable(context.TODO(), r.Client, req.NamespacedName.Namespace)
 	if err != nil {
 		if _, ok := err.(status.MetalLBResourcesNotReadyError); ok {
-			return ctrl.Result{RequeueAfter: 5 * time.Second}, status.ConditionProgressing, nil
+			return ctrl.Result{RequeueAfter: 5 * time.Second}, status.ConditionProgressing, err
 		}
 		return ctrl.Result{}, status.ConditionProgressing, err
 	}
 	return ctrl.Result{}, status.ConditionAvailable, nil
 }
 
+// degradedGrace records since when the MetalLB workloads are unhealthy, and
+// returns how long they are still reported as Progressing before being
+// reported as Degraded, as per the DegradedThreshold.
+func (r *MetalLBReconciler) degradedGrace(instance *metallbv1beta1.MetalLB, unhealthy bool) time.Duration {
+	since := metav1.Time{}
+	if unhealthy {
+		since = instance.Status.UnhealthySince
+		if since.IsZero() {
+			since = metav1.Now()
+		}
+	}
+	if err := status.UpdateUnhealthySince(context.TODO(), r.Client, instance, since); err != nil {
+		r.Log.Info("Failed to update metallb status", "Desired status", "unhealthySince")
+	}
+	if !unhealthy {
+		return 0
+	}
+	return instance.Spec.DegradedThreshold.Duration - time.Since(since.Time)
+}
+
+// checkSpeakerNodeName checks the deployed speaker DaemonSet passes the node
+// name to the speaker.
+func (r *MetalLBReconciler) checkSpeakerNodeName(ctx context.Context, namespace string) error {
+	ds := &appsv1.DaemonSet{}
+	if err := r.Get(ctx, types.NamespacedName{Name: "speaker", Namespace: namespace}, ds); err != nil {
+		return err
+	}
+	if !hasSpeakerNodeName(&ds.Spec.Template.Spec) {
+		return fmt.Errorf("the speaker DaemonSet does not set %s from spec.nodeName", speakerNodeNameEnv)
+	}
+	return nil
+}
+
+// secretMetalLB maps a change of a Secret referenced by the MetalLB
+// resource, the memberlist one or its MetricsTLSSecret, to the MetalLB
+// resource, so that a rotated Secret is checked again.
+func (r *MetalLBReconciler) secretMetalLB(obj client.Object) []reconcile.Request {
+	if !r.isWorkloadsNamespace(obj.GetNamespace()) {
+		return nil
+	}
+	key := types.NamespacedName{Name: defaultMetalLBCrName, Namespace: r.Namespace}
+	if obj.GetName() == memberlistSecret {
+		return []reconcile.Request{{NamespacedName: key}}
+	}
+	instance := &metallbv1beta1.MetalLB{}
+	if err := r.Get(context.TODO(), key, instance); err != nil {
+		if !apierrors.IsNotFound(err) {
+			r.Log.Info(fmt.Sprintf("Failed to get the metallb object %s", err))
+		}
+		return nil
+	}
+	if instance.Spec.MetricsTLSSecret != nil && instance.Spec.MetricsTLSSecret.Name == obj.GetName() {
+		return []reconcile.Request{{NamespacedName: key}}
+	}
+	return nil
+}
+
 func (r *MetalLBReconciler) SetupWithManager(mgr ctrl.Manager) error {
 	return ctrl.NewControllerManagedBy(mgr).
 		For(&metallbv1beta1.MetalLB{}).
-		Complete(r)
+		Watches(&source.Kind{Type: &corev1.Namespace{}}, handler.EnqueueRequestsFromMapFunc(r.namespaceMetalLB)).
+		Watches(&source.Kind{Type: &corev1.Secret{}}, handler.EnqueueRequestsFromMapFunc(r.secretMetalLB)).
+		Watches(&source.Kind{Type: &corev1.ConfigMap{}}, handler.EnqueueRequestsFromMapFunc(r.configMapMetalLB)).
+		Watches(&source.Kind{Type: &corev1.Node{}}, handler.EnqueueRequestsFromMapFunc(r.nodeMetalLB), builder.WithPredicates(nodeSchedulingChanged)).
+		Watches(&source.Kind{Type: &schedulingv1.PriorityClass{}}, handler.EnqueueRequestsFromMapFunc(r.priorityClassMetalLB)).
+		Complete(withReconcileMetrics("metallb", r))
 }
 
-func (r *MetalLBReconciler) syncMetalLBResources(config *metallbv1beta1.MetalLB) error {
+func (r *MetalLBReconciler) syncMetalLBResources(config *metallbv1beta1.MetalLB, objs []*uns.Unstructured) error {
 	logger := r.Log.WithName("syncMetalLBResources")
 	logger.Info("Start")
-	data := render.MakeRenderData()
-
-	data.Data["SpeakerImage"] = os.Getenv("SPEAKER_IMAGE")
-	data.Data["ControllerImage"] = os.Getenv("CONTROLLER_IMAGE")
-	data.Data["IsOpenShift"] = r.PlatformInfo.IsOpenShift()
-	data.Data["NameSpace"] = r.Namespace
-	objs, err := render.RenderDir(ManifestPath, &data)
-	if err != nil {
-		logger.Error(err, "Fail to render config daemon manifests")
-		return err
-	}
 
 	for _, obj := range objs {
-		if err := controllerutil.SetControllerReference(config, obj, r.Scheme); err != nil {
+		if err := r.setOwner(config, obj); err != nil {
 			return errors.Wrapf(err, "Failed to set controller reference to %s %s", obj.GetNamespace(), obj.GetName())
 		}
+		if err := apply.SetDesiredHash(obj); err != nil {
+			return err
+		}
 		if err := apply.ApplyObject(context.TODO(), r.Client, obj); err != nil {
 			return errors.Wrapf(err, "could not apply (%s) %s/%s", obj.GroupVersionKind(), obj.GetNamespace(), obj.GetName())
 		}
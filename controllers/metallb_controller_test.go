@@ -6,11 +6,14 @@ import (
 	"time"
 
 	metallbv1beta1 "github.com/metallb/metallb-operator/api/v1beta1"
+	"github.com/metallb/metallb-operator/pkg/status"
 	"github.com/metallb/metallb-operator/test/consts"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
 	"sigs.k8s.io/controller-runtime/pkg/client"
@@ -65,6 +68,66 @@ var _ = Describe("MetalLB Controller", func() {
 			Expect(len(speakerDaemonSet.Spec.Template.Spec.Containers)).To(BeNumerically(">", 0))
 			Expect(speakerDaemonSet.Spec.Template.Spec.Containers[0].Image).To(Equal(speakerImage))
 		})
+
+		It("Should report a malformed memberlist secret", func() {
+			secret := &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Name: "memberlist", Namespace: MetalLBTestNameSpace},
+				Data:       map[string][]byte{"secretkey": {}},
+			}
+			By("Creating the memberlist secret with an empty key")
+			Expect(k8sClient.Create(context.Background(), secret)).To(Succeed())
+			defer func() {
+				Expect(k8sClient.Delete(context.Background(), secret)).To(Succeed())
+			}()
+
+			By("Creating a MetalLB resource")
+			Expect(k8sClient.Create(context.Background(), metallb)).To(Succeed())
+
+			By("Validating the MetalLB resource is degraded")
+			Eventually(func() string {
+				instance := &metallbv1beta1.MetalLB{}
+				err := k8sClient.Get(context.Background(), types.NamespacedName{Name: metallb.Name, Namespace: metallb.Namespace}, instance)
+				if err != nil {
+					return ""
+				}
+				degraded := meta.FindStatusCondition(instance.Status.Conditions, status.ConditionDegraded)
+				if degraded == nil || degraded.Status != metav1.ConditionTrue {
+					return ""
+				}
+				return degraded.Reason
+			}, 10*time.Second, 200*time.Millisecond).Should(Equal("InvalidMemberlistSecret"))
+		})
+
+		It("Should report a namespace not admitting the speaker", func() {
+			By("Labeling the namespace with the restricted pod security level")
+			ns := &corev1.Namespace{}
+			Expect(k8sClient.Get(context.Background(), types.NamespacedName{Name: MetalLBTestNameSpace}, ns)).To(Succeed())
+			ns.Labels = map[string]string{"pod-security.kubernetes.io/enforce": "restricted"}
+			Expect(k8sClient.Update(context.Background(), ns)).To(Succeed())
+			defer func() {
+				ns := &corev1.Namespace{}
+				Expect(k8sClient.Get(context.Background(), types.NamespacedName{Name: MetalLBTestNameSpace}, ns)).To(Succeed())
+				delete(ns.Labels, "pod-security.kubernetes.io/enforce")
+				Expect(k8sClient.Update(context.Background(), ns)).To(Succeed())
+			}()
+
+			By("Creating a MetalLB resource")
+			Expect(k8sClient.Create(context.Background(), metallb)).To(Succeed())
+
+			By("Validating the MetalLB resource is degraded with the label to add")
+			Eventually(func() string {
+				instance := &metallbv1beta1.MetalLB{}
+				err := k8sClient.Get(context.Background(), types.NamespacedName{Name: metallb.Name, Namespace: metallb.Namespace}, instance)
+				if err != nil {
+					return ""
+				}
+				degraded := meta.FindStatusCondition(instance.Status.Conditions, status.ConditionDegraded)
+				if degraded == nil || degraded.Status != metav1.ConditionTrue || degraded.Reason != "NamespaceNotPrivileged" {
+					return ""
+				}
+				return degraded.Message
+			}, 10*time.Second, 200*time.Millisecond).Should(ContainSubstring("pod-security.kubernetes.io/enforce=privileged"))
+		})
 	})
 })
 
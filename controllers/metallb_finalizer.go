@@ -0,0 +1,120 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	metallbv1beta1 "github.com/metallb/metallb-operator/api/v1beta1"
+	"github.com/metallb/metallb-operator/pkg/apply"
+)
+
+// MetalLBFinalizer holds the deletion of the MetalLB resource until the
+// objects it manages are deleted.
+const MetalLBFinalizer = "metallb.io/finalizer"
+
+// addFinalizer adds the MetalLBFinalizer to the MetalLB resource, if missing.
+func (r *MetalLBReconciler) addFinalizer(ctx context.Context, instance *metallbv1beta1.MetalLB) error {
+	if controllerutil.ContainsFinalizer(instance, MetalLBFinalizer) {
+		return nil
+	}
+	controllerutil.AddFinalizer(instance, MetalLBFinalizer)
+	return r.Update(ctx, instance)
+}
+
+// finalizeMetalLB deletes the objects managed by the MetalLB resource being
+// deleted, one after the other: the speakers first so that they stop
+// announcing the services, then the controller, and the ConfigMaps last. Each
+// one is deleted once the previous one is gone, then the MetalLBFinalizer is
+// removed. The objects not owned by the MetalLB resource are left alone.
+func (r *MetalLBReconciler) finalizeMetalLB(ctx context.Context, instance *metallbv1beta1.MetalLB) (ctrl.Result, error) {
+	if !controllerutil.ContainsFinalizer(instance, MetalLBFinalizer) {
+		return ctrl.Result{}, nil
+	}
+
+	for _, obj := range []client.Object{
+		&appsv1.DaemonSet{ObjectMeta: metav1.ObjectMeta{Name: "speaker"}},
+		&appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: "controller"}},
+		&corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: apply.AddressPoolConfigMap}},
+	} {
+		gone, err := r.deleteOwned(ctx, instance, obj)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+		if !gone {
+			r.Log.Info("Waiting for the deletion", "name", obj.GetName())
+			return ctrl.Result{RequeueAfter: 5 * time.Second}, nil
+		}
+	}
+
+	controllerutil.RemoveFinalizer(instance, MetalLBFinalizer)
+	return ctrl.Result{}, r.Update(ctx, instance)
+}
+
+// deleteOwned deletes the given object of the namespace MetalLB is deployed to
+// if the MetalLB resource owns it, and returns whether it is gone. The deletion is in the
+// foreground, so the object is gone along with its pods.
+func (r *MetalLBReconciler) deleteOwned(ctx context.Context, instance *metallbv1beta1.MetalLB, obj client.Object) (bool, error) {
+	err := r.Get(ctx, types.NamespacedName{Name: obj.GetName(), Namespace: instance.WorkloadsNamespace()}, obj)
+	if apierrors.IsNotFound(err) {
+		return true, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if !isOwnedBy(obj, instance) {
+		return true, nil
+	}
+	if obj.GetDeletionTimestamp() != nil {
+		return false, nil
+	}
+
+	err = r.Delete(ctx, obj, client.PropagationPolicy(metav1.DeletePropagationForeground))
+	if apierrors.IsNotFound(err) {
+		return true, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	err = r.Get(ctx, types.NamespacedName{Name: obj.GetName(), Namespace: instance.WorkloadsNamespace()}, obj)
+	if apierrors.IsNotFound(err) {
+		return true, nil
+	}
+	return false, err
+}
+
+func isOwnedBy(obj client.Object, owner client.Object) bool {
+	if obj.GetAnnotations()[OwnerAnnotation] == owner.GetNamespace()+"/"+owner.GetName() {
+		return true
+	}
+	for _, ref := range obj.GetOwnerReferences() {
+		if ref.UID == owner.GetUID() {
+			return true
+		}
+	}
+	return false
+}
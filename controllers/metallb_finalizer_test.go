@@ -0,0 +1,114 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	metallbv1beta1 "github.com/metallb/metallb-operator/api/v1beta1"
+	"github.com/metallb/metallb-operator/pkg/apply"
+)
+
+// foregroundDeletingClient records the deletions, and only marks the objects
+// as being deleted while holding is set, as the API server does with a
+// foreground deletion until the dependents are gone.
+type foregroundDeletingClient struct {
+	client.Client
+	holding bool
+	deleted []string
+}
+
+func (c *foregroundDeletingClient) Delete(ctx context.Context, obj client.Object, opts ...client.DeleteOption) error {
+	c.deleted = append(c.deleted, obj.GetName())
+	if !c.holding {
+		return c.Client.Delete(ctx, obj, opts...)
+	}
+	now := metav1.Now()
+	obj.SetDeletionTimestamp(&now)
+	return c.Client.Update(ctx, obj)
+}
+
+func TestMetalLBFinalizerAdded(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	c := statusKeepingClient{newFakeClientBuilder().WithScheme(testScheme(g)).WithObjects(append(readyWorkloads(), testMetalLB(metallbv1beta1.MetalLBSpec{}))...).Build()}
+	for i := 0; i < 2; i++ {
+		reconcileTestMetalLB(g, c)
+		metallb := &metallbv1beta1.MetalLB{}
+		g.Expect(c.Get(context.Background(), types.NamespacedName{Name: defaultMetalLBCrName, Namespace: MetalLBTestNameSpace}, metallb)).To(Succeed())
+		g.Expect(metallb.Finalizers).To(Equal([]string{MetalLBFinalizer}))
+	}
+}
+
+func TestMetalLBFinalizer(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	now := metav1.Now()
+	metallb := testMetalLB(metallbv1beta1.MetalLBSpec{})
+	metallb.UID = "metallb-uid"
+	metallb.Finalizers = []string{MetalLBFinalizer}
+	metallb.DeletionTimestamp = &now
+	owner := []metav1.OwnerReference{{APIVersion: "metallb.io/v1beta1", Kind: "MetalLB", Name: defaultMetalLBCrName, UID: metallb.UID}}
+
+	objs := []client.Object{metallb}
+	for _, obj := range readyWorkloads() {
+		obj.SetOwnerReferences(owner)
+		objs = append(objs, obj)
+	}
+	objs = append(objs,
+		&corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: apply.AddressPoolConfigMap, Namespace: MetalLBTestNameSpace, OwnerReferences: owner}},
+	)
+	c := &foregroundDeletingClient{Client: newFakeClientBuilder().WithScheme(testScheme(g)).WithObjects(objs...).Build(), holding: true}
+	reconciler := &MetalLBReconciler{
+		Client:    c,
+		Scheme:    testScheme(g),
+		Log:       ctrl.Log.WithName("controllers").WithName("MetalLB"),
+		Namespace: MetalLBTestNameSpace,
+	}
+	finalize := func() ctrl.Result {
+		key := types.NamespacedName{Name: defaultMetalLBCrName, Namespace: MetalLBTestNameSpace}
+		result, err := reconciler.Reconcile(context.Background(), ctrl.Request{NamespacedName: key})
+		g.Expect(err).ToNot(HaveOccurred())
+		return result
+	}
+	exists := func(obj client.Object, name string) bool {
+		err := c.Get(context.Background(), types.NamespacedName{Name: name, Namespace: MetalLBTestNameSpace}, obj)
+		if apierrors.IsNotFound(err) {
+			return false
+		}
+		g.Expect(err).ToNot(HaveOccurred())
+		return true
+	}
+	finalizers := func() []string {
+		current := &metallbv1beta1.MetalLB{}
+		g.Expect(exists(current, defaultMetalLBCrName)).To(BeTrue())
+		return current.Finalizers
+	}
+
+	// The controller is not deleted until the speakers are gone
+	g.Expect(finalize().RequeueAfter).ToNot(BeZero())
+	g.Expect(finalize().RequeueAfter).ToNot(BeZero())
+	g.Expect(c.deleted).To(Equal([]string{"speaker"}))
+	g.Expect(exists(&appsv1.Deployment{}, "controller")).To(BeTrue())
+	g.Expect(finalizers()).To(Equal([]string{MetalLBFinalizer}))
+
+	c.holding = false
+	g.Expect(c.Client.Delete(context.Background(), &appsv1.DaemonSet{ObjectMeta: metav1.ObjectMeta{Name: "speaker", Namespace: MetalLBTestNameSpace}})).To(Succeed())
+	g.Expect(finalize()).To(Equal(ctrl.Result{}))
+	g.Expect(c.deleted).To(Equal([]string{"speaker", "controller", apply.AddressPoolConfigMap}))
+	g.Expect(exists(&appsv1.Deployment{}, "controller")).To(BeFalse())
+	g.Expect(exists(&corev1.ConfigMap{}, apply.AddressPoolConfigMap)).To(BeFalse())
+	g.Expect(finalizers()).To(BeEmpty())
+
+	// Finalizing again is a no-op
+	g.Expect(finalize()).To(Equal(ctrl.Result{}))
+	g.Expect(c.deleted).To(HaveLen(3))
+}
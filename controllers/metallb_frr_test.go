@@ -0,0 +1,139 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	metallbv1beta1 "github.com/metallb/metallb-operator/api/v1beta1"
+	"github.com/metallb/metallb-operator/pkg/platform"
+)
+
+func containerNames(containers []corev1.Container) []string {
+	names := []string{}
+	for _, c := range containers {
+		names = append(names, c.Name)
+	}
+	return names
+}
+
+func TestRenderFRRBackend(t *testing.T) {
+	g := NewGomegaWithT(t)
+	defer setEnv("FRR_IMAGE", "frr:test")()
+
+	objs := renderTestObjects(g, metallbv1beta1.MetalLBSpec{BGPBackend: metallbv1beta1.BGPBackendFRR})
+	speaker, controller := speakerAndController(g, objs)
+	podSpec := speaker.Spec.Template.Spec
+	g.Expect(containerNames(podSpec.Containers)).To(Equal([]string{speakerContainerName, "frr", "reloader"}))
+	g.Expect(podSpec.Containers[1].Image).To(Equal("frr:test"))
+	g.Expect(containerNames(podSpec.InitContainers)).To(Equal([]string{"cp-frr-files", "cp-reloader"}))
+	g.Expect(podSpec.Containers[0].Env).To(ContainElement(corev1.EnvVar{Name: "METALLB_BGP_TYPE", Value: "frr"}))
+	for _, volume := range []string{"frr-sockets", "frr-startup", "frr-conf", "reloader"} {
+		g.Expect(hasVolume(&podSpec, volume)).To(BeTrue(), volume)
+	}
+	g.Expect(containerNames(controller.Spec.Template.Spec.Containers)).To(Equal([]string{controllerContainerName}))
+
+	found := false
+	for _, obj := range objs {
+		if obj.GetKind() == "ConfigMap" && obj.GetName() == "frr-startup" {
+			found = true
+		}
+	}
+	g.Expect(found).To(BeTrue())
+
+	// The native backend is the default
+	objs = renderTestObjects(g, metallbv1beta1.MetalLBSpec{})
+	speaker, _ = speakerAndController(g, objs)
+	g.Expect(containerNames(speaker.Spec.Template.Spec.Containers)).To(Equal([]string{speakerContainerName}))
+	g.Expect(speaker.Spec.Template.Spec.InitContainers).To(BeEmpty())
+	g.Expect(speaker.Spec.Template.Spec.Volumes).To(BeEmpty())
+	for _, obj := range objs {
+		g.Expect(obj.GetName()).ToNot(Equal("frr-startup"))
+	}
+
+	err := validateMetalLBSpec(&metallbv1beta1.MetalLBSpec{BGPBackend: "bird"})
+	g.Expect(err).To(MatchError(ContainSubstring("invalid bgpBackend")))
+}
+
+func TestRenderFRRBackendWithoutImage(t *testing.T) {
+	g := NewGomegaWithT(t)
+	defer setEnv("FRR_IMAGE", "")()
+
+	r := &MetalLBReconciler{Namespace: MetalLBTestNameSpace, PlatformInfo: platform.PlatformInfo{Name: platform.Kubernetes}}
+	_, err := r.renderMetalLBObjects(testMetalLB(metallbv1beta1.MetalLBSpec{BGPBackend: metallbv1beta1.BGPBackendFRR}))
+	g.Expect(err).To(MatchError(ContainSubstring("FRR_IMAGE")))
+}
+
+func TestReconcileSwitchBGPBackend(t *testing.T) {
+	g := NewGomegaWithT(t)
+	defer setEnv("FRR_IMAGE", "frr:test")()
+
+	metallb := testMetalLB(metallbv1beta1.MetalLBSpec{BGPBackend: metallbv1beta1.BGPBackendFRR})
+	c := newFakeClientBuilder().WithScheme(testScheme(g)).WithObjects(metallb).Build()
+	speakerContainers := func() []string {
+		speaker := &appsv1.DaemonSet{}
+		key := types.NamespacedName{Name: "speaker", Namespace: MetalLBTestNameSpace}
+		g.Expect(c.Get(context.Background(), key, speaker)).To(Succeed())
+		return containerNames(speaker.Spec.Template.Spec.Containers)
+	}
+
+	reconcileTestMetalLB(g, c)
+	g.Expect(speakerContainers()).To(Equal([]string{speakerContainerName, "frr", "reloader"}))
+
+	// Switching back to native drops the FRR containers
+	key := types.NamespacedName{Name: defaultMetalLBCrName, Namespace: MetalLBTestNameSpace}
+	g.Expect(c.Get(context.Background(), key, metallb)).To(Succeed())
+	metallb.Spec.BGPBackend = metallbv1beta1.BGPBackendNative
+	g.Expect(c.Update(context.Background(), metallb)).To(Succeed())
+
+	reconcileTestMetalLB(g, c)
+	g.Expect(speakerContainers()).To(Equal([]string{speakerContainerName}))
+}
+
+func TestRenderFRRReadinessProbe(t *testing.T) {
+	g := NewGomegaWithT(t)
+	defer setEnv("FRR_IMAGE", "frr:test")()
+
+	readinessProbes := func(spec metallbv1beta1.MetalLBSpec) map[string]*corev1.Probe {
+		speaker, _ := speakerAndController(g, renderTestObjects(g, spec))
+		probes := map[string]*corev1.Probe{}
+		for _, c := range speaker.Spec.Template.Spec.Containers {
+			probes[c.Name] = c.ReadinessProbe
+		}
+		return probes
+	}
+
+	probes := readinessProbes(metallbv1beta1.MetalLBSpec{BGPBackend: metallbv1beta1.BGPBackendFRR})
+	g.Expect(probes["frr"]).ToNot(BeNil())
+	g.Expect(probes["frr"].Exec).ToNot(BeNil())
+	g.Expect(probes["frr"].Exec.Command).To(ContainElement("test -S /var/run/frr/zebra.vty && test -S /var/run/frr/bgpd.vty"))
+	g.Expect(probes["frr"].PeriodSeconds).To(Equal(int32(5)))
+	g.Expect(probes["frr"].TimeoutSeconds).To(Equal(int32(1)))
+	g.Expect(probes["frr"].FailureThreshold).To(Equal(int32(3)))
+	g.Expect(probes[speakerContainerName]).To(BeNil())
+
+	// The unset thresholds keep their default
+	probes = readinessProbes(metallbv1beta1.MetalLBSpec{
+		BGPBackend:        metallbv1beta1.BGPBackendFRR,
+		FRRReadinessProbe: &metallbv1beta1.ProbeConfig{PeriodSeconds: 10, FailureThreshold: 6},
+	})
+	g.Expect(probes["frr"].PeriodSeconds).To(Equal(int32(10)))
+	g.Expect(probes["frr"].TimeoutSeconds).To(Equal(int32(1)))
+	g.Expect(probes["frr"].FailureThreshold).To(Equal(int32(6)))
+
+	// Only the frr backend renders it
+	for _, backend := range []string{"", metallbv1beta1.BGPBackendNative} {
+		probes = readinessProbes(metallbv1beta1.MetalLBSpec{
+			BGPBackend:        backend,
+			FRRReadinessProbe: &metallbv1beta1.ProbeConfig{PeriodSeconds: 10},
+		})
+		g.Expect(probes).To(Equal(map[string]*corev1.Probe{speakerContainerName: nil}), backend)
+	}
+
+	err := validateMetalLBSpec(&metallbv1beta1.MetalLBSpec{FRRReadinessProbe: &metallbv1beta1.ProbeConfig{FailureThreshold: -1}})
+	g.Expect(err).To(MatchError(ContainSubstring("invalid frrReadinessProbe")))
+}
@@ -0,0 +1,75 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// frrContainer is the name of the FRR sidecar of the speaker pods deployed
+// with the frr BGP backend.
+const frrContainer = "frr"
+
+// checkFRRContainers returns why the FRR sidecar of the speaker pods is
+// unhealthy, if it is. The speakers only advertise the routes through FRR, so
+// a sidecar that is not ready, e.g. crashing, breaks the BGP sessions of its
+// node even while the speaker container runs. Only the pods scheduled to a
+// node and whose FRR container was created are checked.
+func (r *MetalLBReconciler) checkFRRContainers(ctx context.Context, namespace string) (string, error) {
+	pods := &corev1.PodList{}
+	if err := r.List(ctx, pods, client.InNamespace(namespace), client.MatchingLabels{"component": speakerComponentLabel}); err != nil {
+		return "", err
+	}
+	scheduled := 0
+	var unhealthy []string
+	for i := range pods.Items {
+		pod := &pods.Items[i]
+		if pod.DeletionTimestamp != nil || pod.Spec.NodeName == "" {
+			continue
+		}
+		scheduled++
+		for _, container := range pod.Status.ContainerStatuses {
+			if container.Name == frrContainer && !container.Ready {
+				unhealthy = append(unhealthy, fmt.Sprintf("%s (%s)", pod.Name, frrContainerState(container)))
+			}
+		}
+	}
+	if len(unhealthy) == 0 {
+		return "", nil
+	}
+	sort.Strings(unhealthy)
+	return fmt.Sprintf("The frr container is not ready in %d of %d speaker pods: %s",
+		len(unhealthy), scheduled, strings.Join(unhealthy, ", ")), nil
+}
+
+// frrContainerState describes the state of a container that is not ready.
+func frrContainerState(container corev1.ContainerStatus) string {
+	state := "not ready"
+	if container.State.Waiting != nil && container.State.Waiting.Reason != "" {
+		state = container.State.Waiting.Reason
+	}
+	if container.State.Terminated != nil && container.State.Terminated.Reason != "" {
+		state = container.State.Terminated.Reason
+	}
+	return fmt.Sprintf("%s, %d restarts", state, container.RestartCount)
+}
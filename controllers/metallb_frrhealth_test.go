@@ -0,0 +1,81 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	metallbv1beta1 "github.com/metallb/metallb-operator/api/v1beta1"
+	"github.com/metallb/metallb-operator/pkg/status"
+)
+
+// frrSpeakerPod returns a ready speaker pod scheduled to a node, with the
+// given status of its FRR container.
+func frrSpeakerPod(name string, frr corev1.ContainerStatus) *corev1.Pod {
+	frr.Name = frrContainer
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: name, Namespace: MetalLBTestNameSpace,
+			Labels: map[string]string{"component": speakerComponentLabel},
+		},
+		Spec: corev1.PodSpec{NodeName: "node-" + name},
+		Status: corev1.PodStatus{
+			Conditions: []corev1.PodCondition{{Type: corev1.PodReady, Status: corev1.ConditionTrue}},
+			ContainerStatuses: []corev1.ContainerStatus{
+				{Name: speakerContainerName, Ready: true},
+				frr,
+			},
+		},
+	}
+}
+
+func TestMetalLBFRRUnhealthy(t *testing.T) {
+	g := NewGomegaWithT(t)
+	defer setEnv("FRR_IMAGE", "frr:test")()
+
+	metallb := testMetalLB(metallbv1beta1.MetalLBSpec{BGPBackend: metallbv1beta1.BGPBackendFRR})
+	healthy := frrSpeakerPod("speaker-a", corev1.ContainerStatus{Ready: true})
+	crashing := frrSpeakerPod("speaker-b", corev1.ContainerStatus{
+		RestartCount: 4,
+		State:        corev1.ContainerState{Waiting: &corev1.ContainerStateWaiting{Reason: "CrashLoopBackOff"}},
+	})
+	// A pod not scheduled yet is reported by the DaemonSet
+	pending := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{
+		Name: "speaker-c", Namespace: MetalLBTestNameSpace,
+		Labels: map[string]string{"component": speakerComponentLabel},
+	}}
+	objs := append(readyWorkloads(), metallb, healthy, crashing, pending)
+	c := statusKeepingClient{newFakeClientBuilder().WithScheme(testScheme(g)).WithObjects(objs...).Build()}
+
+	conditions := reconcileTestMetalLB(g, c)
+	degraded := meta.FindStatusCondition(conditions, status.ConditionDegraded)
+	g.Expect(degraded).ToNot(BeNil())
+	g.Expect(degraded.Status).To(Equal(metav1.ConditionTrue))
+	g.Expect(degraded.Reason).To(Equal("FRRUnhealthy"))
+	g.Expect(degraded.Message).To(Equal("The frr container is not ready in 1 of 2 speaker pods: speaker-b (CrashLoopBackOff, 4 restarts)"))
+	g.Expect(meta.IsStatusConditionTrue(conditions, status.ConditionAvailable)).To(BeFalse())
+
+	// Once the sidecar recovers MetalLB is available again
+	crashing.Status.ContainerStatuses[1] = corev1.ContainerStatus{Name: frrContainer, Ready: true, RestartCount: 5}
+	g.Expect(c.Status().Update(context.Background(), crashing)).To(Succeed())
+	conditions = reconcileTestMetalLB(g, c)
+	g.Expect(meta.IsStatusConditionTrue(conditions, status.ConditionDegraded)).To(BeFalse())
+	g.Expect(meta.IsStatusConditionTrue(conditions, status.ConditionAvailable)).To(BeTrue())
+}
+
+func TestCheckFRRContainersNativeBackend(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	// The native backend has no FRR sidecar to check
+	crashing := frrSpeakerPod("speaker-a", corev1.ContainerStatus{})
+	objs := []client.Object{testMetalLB(metallbv1beta1.MetalLBSpec{}), crashing}
+	c := statusKeepingClient{newFakeClientBuilder().WithScheme(testScheme(g)).WithObjects(append(objs, readyWorkloads()...)...).Build()}
+	conditions := reconcileTestMetalLB(g, c)
+	g.Expect(meta.IsStatusConditionTrue(conditions, status.ConditionDegraded)).To(BeFalse())
+	g.Expect(meta.IsStatusConditionTrue(conditions, status.ConditionAvailable)).To(BeTrue())
+}
@@ -0,0 +1,61 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+const (
+	// memberlistSecret is the secret holding the key the speakers encrypt
+	// their memberlist traffic with, as referenced by the speaker DaemonSet.
+	memberlistSecret    = "memberlist"
+	memberlistSecretKey = "secretkey"
+	// minMemberlistKeyLength is the length of the AES-128 key the speakers
+	// derive from the secret key, a shorter secret key is easier to guess
+	// than the key itself.
+	minMemberlistKeyLength = 16
+)
+
+// checkMemberlistSecret checks the memberlist secret, when it exists, holds a
+// key the speakers can use. With a missing or empty key, the speakers fail to
+// join each other and the layer2 leader election silently breaks. The
+// secret not existing is not an error, as the MetalLB controller creates it.
+func (r *MetalLBReconciler) checkMemberlistSecret(ctx context.Context, namespace string) error {
+	secret := &corev1.Secret{}
+	err := r.Get(ctx, types.NamespacedName{Name: memberlistSecret, Namespace: namespace}, secret)
+	if apierrors.IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	key, ok := secret.Data[memberlistSecretKey]
+	if !ok {
+		return fmt.Errorf("the %s secret has no %s key", memberlistSecret, memberlistSecretKey)
+	}
+	if len(key) < minMemberlistKeyLength {
+		return fmt.Errorf("the %s key of the %s secret is %d bytes long, it must be at least %d bytes long",
+			memberlistSecretKey, memberlistSecret, len(key), minMemberlistKeyLength)
+	}
+	return nil
+}
@@ -0,0 +1,72 @@
+package controllers
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	metallbv1beta1 "github.com/metallb/metallb-operator/api/v1beta1"
+	"github.com/metallb/metallb-operator/pkg/status"
+)
+
+func TestMetalLBMemberlistSecret(t *testing.T) {
+	tests := []struct {
+		desc    string
+		secret  *corev1.Secret
+		invalid string
+	}{
+		{
+			desc: "no secret",
+		},
+		{
+			desc:   "valid key",
+			secret: memberlistTestSecret(map[string][]byte{"secretkey": []byte("q2BmSMtzSgP8cBKUjNOn0AXEB5iqwEV8")}),
+		},
+		{
+			desc:    "missing key",
+			secret:  memberlistTestSecret(map[string][]byte{"key": []byte("q2BmSMtzSgP8cBKUjNOn0AXEB5iqwEV8")}),
+			invalid: "has no secretkey key",
+		},
+		{
+			desc:    "empty key",
+			secret:  memberlistTestSecret(map[string][]byte{"secretkey": {}}),
+			invalid: "0 bytes long",
+		},
+		{
+			desc:    "short key",
+			secret:  memberlistTestSecret(map[string][]byte{"secretkey": []byte("secret")}),
+			invalid: "6 bytes long",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			g := NewGomegaWithT(t)
+			objs := append(readyWorkloads(), testMetalLB(metallbv1beta1.MetalLBSpec{}))
+			if test.secret != nil {
+				objs = append(objs, test.secret)
+			}
+			c := newFakeClientBuilder().WithScheme(testScheme(g)).WithObjects(objs...).Build()
+
+			conditions := reconcileTestMetalLB(g, c)
+			degraded := meta.FindStatusCondition(conditions, status.ConditionDegraded)
+			g.Expect(degraded).ToNot(BeNil())
+			if test.invalid == "" {
+				g.Expect(degraded.Status).To(Equal(metav1.ConditionFalse))
+				return
+			}
+			g.Expect(degraded.Status).To(Equal(metav1.ConditionTrue))
+			g.Expect(degraded.Reason).To(Equal("InvalidMemberlistSecret"))
+			g.Expect(degraded.Message).To(ContainSubstring(test.invalid))
+		})
+	}
+}
+
+func memberlistTestSecret(data map[string][]byte) *corev1.Secret {
+	return &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "memberlist", Namespace: MetalLBTestNameSpace},
+		Data:       data,
+	}
+}
@@ -0,0 +1,52 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+
+	metallbv1beta1 "github.com/metallb/metallb-operator/api/v1beta1"
+)
+
+// checkMetricsTLSSecret checks the MetricsTLSSecret, when set, exists and
+// holds the certificate and the key the kube-rbac-proxy serves. Otherwise the
+// pods mounting it would never start, or the proxy would fail to.
+func (r *MetalLBReconciler) checkMetricsTLSSecret(ctx context.Context, namespace string, spec *metallbv1beta1.MetalLBSpec) error {
+	if spec.MetricsTLSSecret == nil {
+		return nil
+	}
+	name := spec.MetricsTLSSecret.Name
+	secret := &corev1.Secret{}
+	err := r.Get(ctx, types.NamespacedName{Name: name, Namespace: namespace}, secret)
+	if apierrors.IsNotFound(err) {
+		return fmt.Errorf("the metricsTLSSecret %s does not exist", name)
+	}
+	if err != nil {
+		return err
+	}
+	for _, key := range []string{corev1.TLSCertKey, corev1.TLSPrivateKeyKey} {
+		if len(secret.Data[key]) == 0 {
+			return fmt.Errorf("the metricsTLSSecret %s has no %s key", name, key)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,121 @@
+package controllers
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	metallbv1beta1 "github.com/metallb/metallb-operator/api/v1beta1"
+	"github.com/metallb/metallb-operator/pkg/platform"
+	"github.com/metallb/metallb-operator/pkg/status"
+)
+
+func TestRenderMetricsTLSSecret(t *testing.T) {
+	g := NewGomegaWithT(t)
+	defer setEnv("KUBE_RBAC_PROXY_IMAGE", "kube-rbac-proxy:test")()
+	spec := metallbv1beta1.MetalLBSpec{MetricsTLSSecret: &corev1.LocalObjectReference{Name: "metrics-tls"}}
+
+	// The proxy is enabled by the secret, on every platform
+	for _, p := range []platform.PlatformType{platform.Kubernetes, platform.OpenShift} {
+		objs := renderPlatformTestObjects(g, spec, platform.PlatformInfo{Name: p})
+		speaker, controller := speakerAndController(g, objs)
+
+		for _, template := range []corev1.PodTemplateSpec{speaker.Spec.Template, controller.Spec.Template} {
+			g.Expect(template.Spec.Volumes).To(ContainElement(corev1.Volume{
+				Name:         rbacProxyCertsVolume,
+				VolumeSource: corev1.VolumeSource{Secret: &corev1.SecretVolumeSource{SecretName: "metrics-tls"}},
+			}))
+			containers := template.Spec.Containers
+			g.Expect(containers).To(HaveLen(2))
+			proxy := containers[1]
+			g.Expect(proxy.Name).To(Equal(rbacProxyContainerName))
+			g.Expect(proxy.Args).To(ContainElements(
+				"--tls-cert-file=/etc/metrics/tls.crt", "--tls-private-key-file=/etc/metrics/tls.key"))
+			g.Expect(proxy.VolumeMounts).To(ConsistOf(corev1.VolumeMount{Name: rbacProxyCertsVolume, MountPath: rbacProxyCertsPath, ReadOnly: true}))
+		}
+		// The service CA does not issue a certificate
+		for _, svc := range metricsServices(g, objs) {
+			g.Expect(svc.Annotations).To(BeEmpty())
+		}
+	}
+}
+
+func TestRenderMetricsTLSSecretInvalid(t *testing.T) {
+	g := NewGomegaWithT(t)
+	disabled := false
+
+	r := &MetalLBReconciler{Namespace: MetalLBTestNameSpace}
+	_, err := r.renderMetalLBObjects(testMetalLB(metallbv1beta1.MetalLBSpec{
+		MetricsTLSSecret: &corev1.LocalObjectReference{Name: "metrics-tls"},
+		EnableRBACProxy:  &disabled,
+	}))
+	g.Expect(err).To(MatchError(ContainSubstring("enableRBACProxy")))
+
+	_, err = r.renderMetalLBObjects(testMetalLB(metallbv1beta1.MetalLBSpec{
+		MetricsTLSSecret: &corev1.LocalObjectReference{Name: "Metrics_TLS"},
+	}))
+	g.Expect(err).To(MatchError(ContainSubstring("invalid metricsTLSSecret")))
+}
+
+func TestMetalLBMetricsTLSSecret(t *testing.T) {
+	defer setEnv("KUBE_RBAC_PROXY_IMAGE", "kube-rbac-proxy:test")()
+
+	tests := []struct {
+		desc    string
+		secret  *corev1.Secret
+		invalid string
+	}{
+		{
+			desc:    "missing secret",
+			invalid: "the metricsTLSSecret metrics-tls does not exist",
+		},
+		{
+			desc:   "valid secret",
+			secret: metricsTLSTestSecret(map[string][]byte{"tls.crt": []byte("cert"), "tls.key": []byte("key")}),
+		},
+		{
+			desc:    "missing key",
+			secret:  metricsTLSTestSecret(map[string][]byte{"tls.crt": []byte("cert")}),
+			invalid: "has no tls.key key",
+		},
+		{
+			desc:    "empty certificate",
+			secret:  metricsTLSTestSecret(map[string][]byte{"tls.crt": {}, "tls.key": []byte("key")}),
+			invalid: "has no tls.crt key",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			g := NewGomegaWithT(t)
+			metallb := testMetalLB(metallbv1beta1.MetalLBSpec{MetricsTLSSecret: &corev1.LocalObjectReference{Name: "metrics-tls"}})
+			objs := append(readyWorkloads(), metallb)
+			if test.secret != nil {
+				objs = append(objs, test.secret)
+			}
+			c := newFakeClientBuilder().WithScheme(testScheme(g)).WithObjects(objs...).Build()
+
+			conditions := reconcileTestMetalLB(g, c)
+			configValid := meta.FindStatusCondition(conditions, status.ConditionConfigValid)
+			g.Expect(configValid).ToNot(BeNil())
+			if test.invalid == "" {
+				g.Expect(configValid.Status).To(Equal(metav1.ConditionTrue))
+				return
+			}
+			g.Expect(configValid.Status).To(Equal(metav1.ConditionFalse))
+			g.Expect(configValid.Message).To(ContainSubstring(test.invalid))
+			// The deployed workloads are left untouched
+			g.Expect(meta.IsStatusConditionTrue(conditions, status.ConditionAvailable)).To(BeTrue())
+		})
+	}
+}
+
+func metricsTLSTestSecret(data map[string][]byte) *corev1.Secret {
+	return &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "metrics-tls", Namespace: MetalLBTestNameSpace},
+		Type:       corev1.SecretTypeTLS,
+		Data:       data,
+	}
+}
@@ -0,0 +1,94 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	metallbv1beta1 "github.com/metallb/metallb-operator/api/v1beta1"
+)
+
+// OwnerAnnotation records, as <namespace>/<name>, the MetalLB resource owning
+// an object deployed outside of its namespace, where it can't be its owner
+// reference.
+const OwnerAnnotation = "metallb.io/owner"
+
+// workloadsNamespace returns the namespace the MetalLB workloads and
+// ConfigMaps are deployed to: the one of the MetalLB resource of the given
+// operator namespace, or the operator namespace when there is none.
+func workloadsNamespace(ctx context.Context, c client.Client, namespace string) (string, error) {
+	metallb := &metallbv1beta1.MetalLB{}
+	err := c.Get(ctx, types.NamespacedName{Name: defaultMetalLBCrName, Namespace: namespace}, metallb)
+	if apierrors.IsNotFound(err) {
+		return namespace, nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("Failed to get MetalLB resource %w", err)
+	}
+	return metallb.WorkloadsNamespace(), nil
+}
+
+// checkTargetNamespace checks the operator watches the namespace MetalLB is
+// deployed to, as it can't track the objects of the other ones.
+func (r *MetalLBReconciler) checkTargetNamespace(instance *metallbv1beta1.MetalLB) error {
+	namespace := instance.WorkloadsNamespace()
+	if namespace == r.Namespace {
+		return nil
+	}
+	for _, ns := range r.TargetNamespaces {
+		if ns == AllPoolNamespaces || ns == namespace {
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid targetNamespace %q, the operator does not watch it, see --target-namespaces", namespace)
+}
+
+// isWorkloadsNamespace returns whether MetalLB is deployed to the given namespace.
+func (r *MetalLBReconciler) isWorkloadsNamespace(namespace string) bool {
+	workloads, err := workloadsNamespace(context.TODO(), r.Client, r.Namespace)
+	if err != nil {
+		r.Log.Info(err.Error())
+		return false
+	}
+	return namespace == workloads
+}
+
+// setOwner makes the MetalLB resource the controller of the given object. An
+// object of another namespace gets the OwnerAnnotation instead, it is then
+// only deleted by the finalizer.
+func (r *MetalLBReconciler) setOwner(instance *metallbv1beta1.MetalLB, obj client.Object) error {
+	if obj.GetNamespace() == instance.Namespace {
+		return controllerutil.SetControllerReference(instance, obj, r.Scheme)
+	}
+	setOwnerAnnotation(instance, obj)
+	return nil
+}
+
+func setOwnerAnnotation(owner client.Object, obj client.Object) {
+	annotations := obj.GetAnnotations()
+	if annotations == nil {
+		annotations = map[string]string{}
+	}
+	annotations[OwnerAnnotation] = owner.GetNamespace() + "/" + owner.GetName()
+	obj.SetAnnotations(annotations)
+}
@@ -0,0 +1,107 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	metallbv1alpha1 "github.com/metallb/metallb-operator/api/v1alpha1"
+	metallbv1beta1 "github.com/metallb/metallb-operator/api/v1beta1"
+	"github.com/metallb/metallb-operator/pkg/apply"
+	"github.com/metallb/metallb-operator/pkg/status"
+)
+
+const testTargetNamespace = "metallb-data"
+
+func TestMetalLBTargetNamespace(t *testing.T) {
+	g := NewGomegaWithT(t)
+	manifestPath := ManifestPath
+	ManifestPath = "../bindata/deployment"
+	defer func() { ManifestPath = manifestPath }()
+
+	objs := []client.Object{testMetalLB(metallbv1beta1.MetalLBSpec{TargetNamespace: testTargetNamespace})}
+	for _, obj := range readyWorkloads() {
+		obj.SetNamespace(testTargetNamespace)
+		objs = append(objs, obj)
+	}
+	c := statusKeepingClient{newFakeClientBuilder().WithScheme(testScheme(g)).WithObjects(objs...).Build()}
+	reconciler := &MetalLBReconciler{
+		Client:           c,
+		Scheme:           testScheme(g),
+		Log:              ctrl.Log.WithName("controllers").WithName("MetalLB"),
+		Namespace:        MetalLBTestNameSpace,
+		TargetNamespaces: []string{testTargetNamespace},
+	}
+	key := types.NamespacedName{Name: defaultMetalLBCrName, Namespace: MetalLBTestNameSpace}
+	_, err := reconciler.Reconcile(context.Background(), ctrl.Request{NamespacedName: key})
+	g.Expect(err).ToNot(HaveOccurred())
+
+	metallb := &metallbv1beta1.MetalLB{}
+	g.Expect(c.Get(context.Background(), key, metallb)).To(Succeed())
+	g.Expect(meta.IsStatusConditionTrue(metallb.Status.Conditions, status.ConditionConfigValid)).To(BeTrue())
+	g.Expect(meta.IsStatusConditionTrue(metallb.Status.Conditions, status.ConditionAvailable)).To(BeTrue())
+
+	// The workloads can't be owned across namespaces
+	for name, obj := range map[string]client.Object{"speaker": &appsv1.DaemonSet{}, "controller": &appsv1.Deployment{}} {
+		g.Expect(c.Get(context.Background(), types.NamespacedName{Name: name, Namespace: testTargetNamespace}, obj)).To(Succeed())
+		g.Expect(obj.GetOwnerReferences()).To(BeEmpty())
+		g.Expect(obj.GetAnnotations()).To(HaveKeyWithValue(OwnerAnnotation, MetalLBTestNameSpace+"/"+defaultMetalLBCrName))
+		g.Expect(isOwnedBy(obj, metallb)).To(BeTrue())
+	}
+}
+
+func TestMetalLBTargetNamespaceNotWatched(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	metallb := testMetalLB(metallbv1beta1.MetalLBSpec{TargetNamespace: testTargetNamespace})
+	c := statusKeepingClient{newFakeClientBuilder().WithScheme(testScheme(g)).WithObjects(metallb).Build()}
+	conditions := reconcileTestMetalLB(g, c)
+	configValid := meta.FindStatusCondition(conditions, status.ConditionConfigValid)
+	g.Expect(configValid).ToNot(BeNil())
+	g.Expect(configValid.Status).To(Equal(metav1.ConditionFalse))
+	g.Expect(configValid.Message).To(ContainSubstring("--target-namespaces"))
+
+	// Nothing is deployed to the namespace
+	speaker := &appsv1.DaemonSet{}
+	err := c.Get(context.Background(), types.NamespacedName{Name: "speaker", Namespace: testTargetNamespace}, speaker)
+	g.Expect(err).To(HaveOccurred())
+}
+
+func TestAddressPoolTargetNamespace(t *testing.T) {
+	g := NewGomegaWithT(t)
+	manifestPath := AddressPoolManifestPath
+	AddressPoolManifestPath = "../bindata/configuration/address-pool"
+	defer func() { AddressPoolManifestPath = manifestPath }()
+
+	pool := &metallbv1alpha1.AddressPool{
+		ObjectMeta: metav1.ObjectMeta{Name: "gold", Namespace: MetalLBTestNameSpace},
+		Spec:       metallbv1alpha1.AddressPoolSpec{Protocol: "layer2", Addresses: []string{"10.0.0.0/30"}},
+	}
+	reconciler := &AddressPoolReconciler{
+		Client: newFakeClientBuilder().WithScheme(testScheme(g)).
+			WithObjects(testMetalLB(metallbv1beta1.MetalLBSpec{TargetNamespace: testTargetNamespace}), pool).Build(),
+		Log:       ctrl.Log.WithName("controllers").WithName("AddressPool"),
+		Namespace: MetalLBTestNameSpace,
+	}
+	_, err := reconciler.Reconcile(context.Background(), ctrl.Request{
+		NamespacedName: types.NamespacedName{Name: "gold", Namespace: MetalLBTestNameSpace},
+	})
+	g.Expect(err).ToNot(HaveOccurred())
+
+	configMap := &corev1.ConfigMap{}
+	g.Expect(reconciler.Get(context.Background(), types.NamespacedName{Name: apply.AddressPoolConfigMap, Namespace: testTargetNamespace}, configMap)).To(Succeed())
+	g.Expect(configMap.Data[apply.AddressPoolConfigMap]).To(ContainSubstring("10.0.0.0/30"))
+	g.Expect(configMap.OwnerReferences).To(BeEmpty())
+	g.Expect(configMap.Annotations).To(HaveKeyWithValue(OwnerAnnotation, MetalLBTestNameSpace+"/"+defaultMetalLBCrName))
+
+	err = reconciler.Get(context.Background(), types.NamespacedName{Name: apply.AddressPoolConfigMap, Namespace: MetalLBTestNameSpace}, configMap)
+	g.Expect(err).To(HaveOccurred())
+}
@@ -0,0 +1,143 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	uns "k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	metallbv1beta1 "github.com/metallb/metallb-operator/api/v1beta1"
+	"github.com/metallb/metallb-operator/pkg/status"
+)
+
+// checkControllerSchedulable reports in the ControllerSchedulable condition
+// whether a node the rendered controller pod can be scheduled on matches the
+// ControllerNodeSelector: the controller being a single replica, a selector
+// matching no node leaves MetalLB without a controller. The condition is
+// removed when there is no ControllerNodeSelector.
+func (r *MetalLBReconciler) checkControllerSchedulable(ctx context.Context, instance *metallbv1beta1.MetalLB, objs []*uns.Unstructured) error {
+	if len(instance.Spec.ControllerNodeSelector) == 0 {
+		return status.RemoveCondition(ctx, r.Client, instance, status.ConditionControllerSchedulable)
+	}
+
+	podSpec, err := controllerPodSpec(objs)
+	if err != nil || podSpec == nil {
+		return err
+	}
+	nodes := &corev1.NodeList{}
+	if err := r.List(ctx, nodes, client.MatchingLabels(podSpec.NodeSelector)); err != nil {
+		return err
+	}
+	for i := range nodes.Items {
+		if nodeSchedulable(&nodes.Items[i], podSpec.Tolerations) {
+			return status.UpdateControllerSchedulable(ctx, r.Client, instance, true, "")
+		}
+	}
+	message := fmt.Sprintf("No schedulable node matches the controller node selector %s, the controller pod can't run",
+		labels.SelectorFromSet(podSpec.NodeSelector))
+	return status.UpdateControllerSchedulable(ctx, r.Client, instance, false, message)
+}
+
+// controllerPodSpec returns the pod spec of the rendered controller Deployment.
+func controllerPodSpec(objs []*uns.Unstructured) (*corev1.PodSpec, error) {
+	for _, obj := range objs {
+		if obj.GetKind() != "Deployment" || obj.GetName() != "controller" {
+			continue
+		}
+		deployment := &appsv1.Deployment{}
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(obj.Object, deployment); err != nil {
+			return nil, err
+		}
+		return &deployment.Spec.Template.Spec, nil
+	}
+	return nil, nil
+}
+
+// nodeSchedulable returns whether new pods with the given tolerations can be
+// scheduled on the node, i.e. it is not cordoned and they tolerate its
+// NoSchedule and NoExecute taints.
+func nodeSchedulable(node *corev1.Node, tolerations []corev1.Toleration) bool {
+	if node.Spec.Unschedulable {
+		return false
+	}
+	for i := range node.Spec.Taints {
+		taint := &node.Spec.Taints[i]
+		if taint.Effect == corev1.TaintEffectPreferNoSchedule {
+			continue
+		}
+		tolerated := false
+		for j := range tolerations {
+			if tolerations[j].ToleratesTaint(taint) {
+				tolerated = true
+				break
+			}
+		}
+		if !tolerated {
+			return false
+		}
+	}
+	return true
+}
+
+// nodeMetalLB maps a change of a node to the MetalLB resource, if it has a
+// ControllerNodeSelector, so that its ControllerSchedulable condition is
+// checked again.
+func (r *MetalLBReconciler) nodeMetalLB(obj client.Object) []reconcile.Request {
+	key := types.NamespacedName{Name: defaultMetalLBCrName, Namespace: r.Namespace}
+	instance := &metallbv1beta1.MetalLB{}
+	if err := r.Get(context.TODO(), key, instance); err != nil {
+		if !apierrors.IsNotFound(err) {
+			r.Log.Info(fmt.Sprintf("Failed to get the metallb object %s", err))
+		}
+		return nil
+	}
+	if len(instance.Spec.ControllerNodeSelector) == 0 {
+		return nil
+	}
+	return []reconcile.Request{{NamespacedName: key}}
+}
+
+// nodeSchedulingChanged filters out the updates of the nodes not changing
+// where pods can be scheduled, e.g. the status heartbeats.
+var nodeSchedulingChanged = predicate.Funcs{
+	UpdateFunc: func(e event.UpdateEvent) bool {
+		oldNode, ok := e.ObjectOld.(*corev1.Node)
+		if !ok {
+			return false
+		}
+		newNode, ok := e.ObjectNew.(*corev1.Node)
+		if !ok {
+			return false
+		}
+		return !equality.Semantic.DeepEqual(oldNode.Labels, newNode.Labels) ||
+			oldNode.Spec.Unschedulable != newNode.Spec.Unschedulable ||
+			!equality.Semantic.DeepEqual(oldNode.Spec.Taints, newNode.Spec.Taints)
+	},
+}
@@ -0,0 +1,91 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	metallbv1beta1 "github.com/metallb/metallb-operator/api/v1beta1"
+	"github.com/metallb/metallb-operator/pkg/status"
+)
+
+func TestControllerSchedulable(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	infraLabels := map[string]string{"kubernetes.io/os": "linux", "node-role.kubernetes.io/infra": ""}
+	objs := append(readyWorkloads(),
+		testMetalLB(metallbv1beta1.MetalLBSpec{ControllerNodeSelector: map[string]string{"node-role.kubernetes.io/infra": ""}}),
+		&corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "worker", Labels: map[string]string{"kubernetes.io/os": "linux"}}},
+		&corev1.Node{
+			ObjectMeta: metav1.ObjectMeta{Name: "cordoned", Labels: infraLabels},
+			Spec:       corev1.NodeSpec{Unschedulable: true},
+		},
+		&corev1.Node{
+			ObjectMeta: metav1.ObjectMeta{Name: "tainted", Labels: infraLabels},
+			Spec:       corev1.NodeSpec{Taints: []corev1.Taint{{Key: "node-role.kubernetes.io/infra", Effect: corev1.TaintEffectNoSchedule}}},
+		},
+	)
+	c := statusKeepingClient{newFakeClientBuilder().WithScheme(testScheme(g)).WithObjects(objs...).Build()}
+	updateSpec := func(update func(*metallbv1beta1.MetalLBSpec)) {
+		metallb := &metallbv1beta1.MetalLB{}
+		g.Expect(c.Get(context.Background(), types.NamespacedName{Name: defaultMetalLBCrName, Namespace: MetalLBTestNameSpace}, metallb)).To(Succeed())
+		update(&metallb.Spec)
+		g.Expect(c.Update(context.Background(), metallb)).To(Succeed())
+	}
+
+	// Only the worker is schedulable, but it does not match
+	condition := meta.FindStatusCondition(reconcileTestMetalLB(g, c), status.ConditionControllerSchedulable)
+	g.Expect(condition).ToNot(BeNil())
+	g.Expect(condition.Status).To(Equal(metav1.ConditionFalse))
+	g.Expect(condition.Reason).To(Equal("NoNodeMatchesSelector"))
+	g.Expect(condition.Message).To(ContainSubstring("node-role.kubernetes.io/infra"))
+	// Not blocking the reconcile
+	g.Expect(meta.IsStatusConditionTrue(reconcileTestMetalLB(g, c), status.ConditionAvailable)).To(BeTrue())
+
+	// Tolerating the taint of the infra node
+	updateSpec(func(spec *metallbv1beta1.MetalLBSpec) {
+		spec.ControllerTolerations = []corev1.Toleration{{Key: "node-role.kubernetes.io/infra", Operator: corev1.TolerationOpExists}}
+	})
+	condition = meta.FindStatusCondition(reconcileTestMetalLB(g, c), status.ConditionControllerSchedulable)
+	g.Expect(condition).ToNot(BeNil())
+	g.Expect(condition.Status).To(Equal(metav1.ConditionTrue))
+
+	// Uncordoning the infra node
+	updateSpec(func(spec *metallbv1beta1.MetalLBSpec) { spec.ControllerTolerations = nil })
+	g.Expect(meta.IsStatusConditionFalse(reconcileTestMetalLB(g, c), status.ConditionControllerSchedulable)).To(BeTrue())
+	cordoned := &corev1.Node{}
+	g.Expect(c.Get(context.Background(), types.NamespacedName{Name: "cordoned"}, cordoned)).To(Succeed())
+	cordoned.Spec.Unschedulable = false
+	g.Expect(c.Update(context.Background(), cordoned)).To(Succeed())
+	g.Expect(meta.IsStatusConditionTrue(reconcileTestMetalLB(g, c), status.ConditionControllerSchedulable)).To(BeTrue())
+
+	// No condition without a selector
+	updateSpec(func(spec *metallbv1beta1.MetalLBSpec) { spec.ControllerNodeSelector = nil })
+	g.Expect(meta.FindStatusCondition(reconcileTestMetalLB(g, c), status.ConditionControllerSchedulable)).To(BeNil())
+}
+
+func TestNodeMetalLB(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	node := &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "worker"}}
+	for _, c := range []struct {
+		objs     []client.Object
+		expected int
+	}{
+		{nil, 0},
+		{[]client.Object{testMetalLB(metallbv1beta1.MetalLBSpec{})}, 0},
+		{[]client.Object{testMetalLB(metallbv1beta1.MetalLBSpec{ControllerNodeSelector: map[string]string{"infra": "true"}})}, 1},
+	} {
+		r := &MetalLBReconciler{
+			Client:    newFakeClientBuilder().WithScheme(testScheme(g)).WithObjects(c.objs...).Build(),
+			Namespace: MetalLBTestNameSpace,
+		}
+		g.Expect(r.nodeMetalLB(node)).To(HaveLen(c.expected))
+	}
+}
@@ -0,0 +1,124 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	uns "k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+const (
+	// podSecurityEnforceLabel is the namespace label setting the Pod Security
+	// level the pods of the namespace must meet to be admitted.
+	podSecurityEnforceLabel = "pod-security.kubernetes.io/enforce"
+	podSecurityPrivileged   = "privileged"
+)
+
+// baselineCapabilities are the capabilities the baseline Pod Security level
+// allows containers to add.
+var baselineCapabilities = map[corev1.Capability]bool{
+	"AUDIT_WRITE": true, "CHOWN": true, "DAC_OVERRIDE": true, "FOWNER": true, "FSETID": true,
+	"KILL": true, "MKNOD": true, "NET_BIND_SERVICE": true, "SETFCAP": true, "SETGID": true,
+	"SETPCAP": true, "SETUID": true, "SYS_CHROOT": true,
+}
+
+// checkPodSecurity returns why the namespace does not admit the rendered
+// speaker pods, if it does not. The speaker runs in the host network, which
+// only the privileged level allows, and its pods being rejected only shows in
+// the events of the DaemonSet. A namespace without the enforce label gets
+// the level of the cluster, privileged unless configured otherwise, so only
+// a namespace enforcing another level is reported.
+func (r *MetalLBReconciler) checkPodSecurity(ctx context.Context, namespace string, objs []*uns.Unstructured) (string, error) {
+	needsPrivileged, err := speakerNeedsPrivileged(objs)
+	if err != nil || !needsPrivileged {
+		return "", err
+	}
+	ns := &corev1.Namespace{}
+	err = r.Get(ctx, types.NamespacedName{Name: namespace}, ns)
+	if apierrors.IsNotFound(err) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	level, ok := ns.Labels[podSecurityEnforceLabel]
+	if !ok || level == podSecurityPrivileged {
+		return "", nil
+	}
+	return fmt.Sprintf("The %s namespace enforces the %s pod security level, the speaker needs the %s one: label the namespace with %s=%s",
+		namespace, level, podSecurityPrivileged, podSecurityEnforceLabel, podSecurityPrivileged), nil
+}
+
+// speakerNeedsPrivileged returns whether the rendered speaker pods need the
+// privileged Pod Security level, i.e. the baseline level rejects them.
+func speakerNeedsPrivileged(objs []*uns.Unstructured) (bool, error) {
+	for _, obj := range objs {
+		if obj.GetKind() != "DaemonSet" || obj.GetName() != "speaker" {
+			continue
+		}
+		ds := &appsv1.DaemonSet{}
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(obj.Object, ds); err != nil {
+			return false, err
+		}
+		return podNeedsPrivileged(&ds.Spec.Template.Spec), nil
+	}
+	return false, nil
+}
+
+// podNeedsPrivileged returns whether the pod shares a host namespace, or has
+// a container that is privileged or adds a capability beyond the baseline ones.
+func podNeedsPrivileged(podSpec *corev1.PodSpec) bool {
+	if podSpec.HostNetwork || podSpec.HostPID || podSpec.HostIPC {
+		return true
+	}
+	containers := append(append([]corev1.Container{}, podSpec.InitContainers...), podSpec.Containers...)
+	for _, c := range containers {
+		if c.SecurityContext == nil {
+			continue
+		}
+		if c.SecurityContext.Privileged != nil && *c.SecurityContext.Privileged {
+			return true
+		}
+		if c.SecurityContext.Capabilities == nil {
+			continue
+		}
+		for _, capability := range c.SecurityContext.Capabilities.Add {
+			if !baselineCapabilities[capability] {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// namespaceMetalLB maps a change of the namespace MetalLB is deployed to, e.g.
+// of its Pod Security labels, to the MetalLB resource.
+func (r *MetalLBReconciler) namespaceMetalLB(obj client.Object) []reconcile.Request {
+	if !r.isWorkloadsNamespace(obj.GetName()) {
+		return nil
+	}
+	return []reconcile.Request{{NamespacedName: types.NamespacedName{Name: defaultMetalLBCrName, Namespace: r.Namespace}}}
+}
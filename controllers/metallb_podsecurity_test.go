@@ -0,0 +1,119 @@
+package controllers
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	metallbv1beta1 "github.com/metallb/metallb-operator/api/v1beta1"
+	"github.com/metallb/metallb-operator/pkg/status"
+)
+
+func TestMetalLBNamespacePodSecurity(t *testing.T) {
+	tests := []struct {
+		desc     string
+		labels   map[string]string
+		degraded bool
+	}{
+		{
+			desc: "no enforce label",
+		},
+		{
+			desc:   "privileged namespace",
+			labels: map[string]string{podSecurityEnforceLabel: "privileged"},
+		},
+		{
+			desc:     "baseline namespace",
+			labels:   map[string]string{podSecurityEnforceLabel: "baseline"},
+			degraded: true,
+		},
+		{
+			desc:     "restricted namespace",
+			labels:   map[string]string{podSecurityEnforceLabel: "restricted", "pod-security.kubernetes.io/warn": "privileged"},
+			degraded: true,
+		},
+	}
+	for _, test := range tests {
+		g := NewGomegaWithT(t)
+		ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: MetalLBTestNameSpace, Labels: test.labels}}
+		metallb := testMetalLB(metallbv1beta1.MetalLBSpec{})
+		c := newFakeClientBuilder().WithScheme(testScheme(g)).WithObjects(append(readyWorkloads(), ns, metallb)...).Build()
+
+		conditions := reconcileTestMetalLB(g, c)
+		degraded := meta.FindStatusCondition(conditions, status.ConditionDegraded)
+		g.Expect(degraded).ToNot(BeNil(), test.desc)
+		if !test.degraded {
+			g.Expect(degraded.Status).To(Equal(metav1.ConditionFalse), test.desc)
+			continue
+		}
+		g.Expect(degraded.Status).To(Equal(metav1.ConditionTrue), test.desc)
+		g.Expect(degraded.Reason).To(Equal("NamespaceNotPrivileged"), test.desc)
+		g.Expect(degraded.Message).To(ContainSubstring("label the namespace with pod-security.kubernetes.io/enforce=privileged"), test.desc)
+		g.Expect(degraded.Message).To(ContainSubstring(test.labels[podSecurityEnforceLabel]), test.desc)
+	}
+}
+
+func TestPodNeedsPrivileged(t *testing.T) {
+	g := NewGomegaWithT(t)
+	privileged := true
+
+	tests := []struct {
+		desc    string
+		podSpec corev1.PodSpec
+		needs   bool
+	}{
+		{
+			desc:    "restricted pod",
+			podSpec: corev1.PodSpec{Containers: []corev1.Container{{Name: "c"}}},
+		},
+		{
+			desc:    "host network",
+			podSpec: corev1.PodSpec{HostNetwork: true},
+			needs:   true,
+		},
+		{
+			desc: "privileged init container",
+			podSpec: corev1.PodSpec{InitContainers: []corev1.Container{{Name: "c",
+				SecurityContext: &corev1.SecurityContext{Privileged: &privileged}}}},
+			needs: true,
+		},
+		{
+			desc: "baseline capability",
+			podSpec: corev1.PodSpec{Containers: []corev1.Container{{Name: "c",
+				SecurityContext: &corev1.SecurityContext{Capabilities: &corev1.Capabilities{Add: []corev1.Capability{"NET_BIND_SERVICE"}}}}}},
+		},
+		{
+			desc: "NET_RAW capability",
+			podSpec: corev1.PodSpec{Containers: []corev1.Container{{Name: "c",
+				SecurityContext: &corev1.SecurityContext{Capabilities: &corev1.Capabilities{Add: []corev1.Capability{"NET_RAW"}}}}}},
+			needs: true,
+		},
+	}
+	for _, test := range tests {
+		g.Expect(podNeedsPrivileged(&test.podSpec)).To(Equal(test.needs), test.desc)
+	}
+}
+
+func TestNamespaceMetalLB(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	r := &MetalLBReconciler{
+		Client:    newFakeClientBuilder().WithScheme(testScheme(g)).WithObjects(testMetalLB(metallbv1beta1.MetalLBSpec{})).Build(),
+		Namespace: MetalLBTestNameSpace,
+	}
+	g.Expect(r.namespaceMetalLB(&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: MetalLBTestNameSpace}})).To(ConsistOf(
+		reconcile.Request{NamespacedName: types.NamespacedName{Name: defaultMetalLBCrName, Namespace: MetalLBTestNameSpace}}))
+	g.Expect(r.namespaceMetalLB(&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "default"}})).To(BeEmpty())
+
+	// The namespace MetalLB is deployed to is the one checked
+	r.Client = newFakeClientBuilder().WithScheme(testScheme(g)).
+		WithObjects(testMetalLB(metallbv1beta1.MetalLBSpec{TargetNamespace: "default"})).Build()
+	g.Expect(r.namespaceMetalLB(&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "default"}})).To(ConsistOf(
+		reconcile.Request{NamespacedName: types.NamespacedName{Name: defaultMetalLBCrName, Namespace: MetalLBTestNameSpace}}))
+	g.Expect(r.namespaceMetalLB(&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: MetalLBTestNameSpace}})).To(BeEmpty())
+}
@@ -0,0 +1,90 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	schedulingv1 "k8s.io/api/scheduling/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	metallbv1beta1 "github.com/metallb/metallb-operator/api/v1beta1"
+	"github.com/metallb/metallb-operator/pkg/status"
+)
+
+// +kubebuilder:rbac:groups=scheduling.k8s.io,resources=priorityclasses,verbs=get;list;watch
+
+// checkPriorityClasses reports in the PriorityClassesFound condition whether
+// the PriorityClasses of the speaker and the controller exist: the pods
+// referencing a missing one are rejected, e.g. when it is deleted after the
+// webhook admitted the MetalLB. The condition is removed when none is set.
+func (r *MetalLBReconciler) checkPriorityClasses(ctx context.Context, instance *metallbv1beta1.MetalLB) error {
+	priorityClasses := []struct {
+		workload string
+		name     string
+	}{
+		{"speaker", instance.Spec.SpeakerPriorityClassName},
+		{"controller", instance.Spec.ControllerPriorityClassName},
+	}
+	set := false
+	var missing []string
+	for _, p := range priorityClasses {
+		if p.name == "" {
+			continue
+		}
+		set = true
+		err := r.Get(ctx, types.NamespacedName{Name: p.name}, &schedulingv1.PriorityClass{})
+		if apierrors.IsNotFound(err) {
+			missing = append(missing, fmt.Sprintf("PriorityClass %s of the %s not found", p.name, p.workload))
+			continue
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if !set {
+		return status.RemoveCondition(ctx, r.Client, instance, status.ConditionPriorityClassesFound)
+	}
+	if len(missing) > 0 {
+		message := strings.Join(missing, ", ") + ", the pods referencing a missing PriorityClass can't be created"
+		return status.UpdatePriorityClassesFound(ctx, r.Client, instance, false, message)
+	}
+	return status.UpdatePriorityClassesFound(ctx, r.Client, instance, true, "")
+}
+
+// priorityClassMetalLB maps a change of a PriorityClass to the MetalLB
+// resource referencing it, so that its PriorityClassesFound condition is
+// checked again.
+func (r *MetalLBReconciler) priorityClassMetalLB(obj client.Object) []reconcile.Request {
+	key := types.NamespacedName{Name: defaultMetalLBCrName, Namespace: r.Namespace}
+	instance := &metallbv1beta1.MetalLB{}
+	if err := r.Get(context.TODO(), key, instance); err != nil {
+		if !apierrors.IsNotFound(err) {
+			r.Log.Info(fmt.Sprintf("Failed to get the metallb object %s", err))
+		}
+		return nil
+	}
+	if obj.GetName() != instance.Spec.SpeakerPriorityClassName && obj.GetName() != instance.Spec.ControllerPriorityClassName {
+		return nil
+	}
+	return []reconcile.Request{{NamespacedName: key}}
+}
@@ -0,0 +1,55 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	schedulingv1 "k8s.io/api/scheduling/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	metallbv1beta1 "github.com/metallb/metallb-operator/api/v1beta1"
+	"github.com/metallb/metallb-operator/pkg/status"
+)
+
+func TestPriorityClassesFound(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	objs := append(readyWorkloads(),
+		testMetalLB(metallbv1beta1.MetalLBSpec{SpeakerPriorityClassName: "system-node-critical", ControllerPriorityClassName: "metallb-controller"}),
+		&schedulingv1.PriorityClass{ObjectMeta: metav1.ObjectMeta{Name: "system-node-critical"}, Value: 2000001000},
+	)
+	c := statusKeepingClient{newFakeClientBuilder().WithScheme(testScheme(g)).WithObjects(objs...).Build()}
+
+	// The PriorityClass of the controller is missing
+	condition := meta.FindStatusCondition(reconcileTestMetalLB(g, c), status.ConditionPriorityClassesFound)
+	g.Expect(condition).ToNot(BeNil())
+	g.Expect(condition.Status).To(Equal(metav1.ConditionFalse))
+	g.Expect(condition.Reason).To(Equal("PriorityClassNotFound"))
+	g.Expect(condition.Message).To(Equal("PriorityClass metallb-controller of the controller not found, the pods referencing a missing PriorityClass can't be created"))
+
+	g.Expect(c.Create(context.Background(), &schedulingv1.PriorityClass{ObjectMeta: metav1.ObjectMeta{Name: "metallb-controller"}, Value: 1000})).To(Succeed())
+	g.Expect(meta.IsStatusConditionTrue(reconcileTestMetalLB(g, c), status.ConditionPriorityClassesFound)).To(BeTrue())
+
+	// No condition without a PriorityClass
+	metallb := &metallbv1beta1.MetalLB{}
+	g.Expect(c.Get(context.Background(), types.NamespacedName{Name: defaultMetalLBCrName, Namespace: MetalLBTestNameSpace}, metallb)).To(Succeed())
+	metallb.Spec.SpeakerPriorityClassName = ""
+	metallb.Spec.ControllerPriorityClassName = ""
+	g.Expect(c.Update(context.Background(), metallb)).To(Succeed())
+	g.Expect(meta.FindStatusCondition(reconcileTestMetalLB(g, c), status.ConditionPriorityClassesFound)).To(BeNil())
+}
+
+func TestPriorityClassMetalLB(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	metallb := testMetalLB(metallbv1beta1.MetalLBSpec{SpeakerPriorityClassName: "system-node-critical"})
+	r := &MetalLBReconciler{
+		Client:    newFakeClientBuilder().WithScheme(testScheme(g)).WithObjects(metallb).Build(),
+		Namespace: MetalLBTestNameSpace,
+	}
+	g.Expect(r.priorityClassMetalLB(&schedulingv1.PriorityClass{ObjectMeta: metav1.ObjectMeta{Name: "system-node-critical"}})).To(HaveLen(1))
+	g.Expect(r.priorityClassMetalLB(&schedulingv1.PriorityClass{ObjectMeta: metav1.ObjectMeta{Name: "other"}})).To(BeEmpty())
+}
@@ -0,0 +1,197 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"fmt"
+	"strconv"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	uns "k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	metallbv1beta1 "github.com/metallb/metallb-operator/api/v1beta1"
+)
+
+const (
+	rbacProxyContainerName = "kube-rbac-proxy"
+	// metricsPortName is the name of the port MetalLB serves its metrics on.
+	metricsPortName = "monitoring"
+	// rbacProxyPortName is the name of the port the proxy serves the metrics on.
+	rbacProxyPortName = "metricshttps"
+	rbacProxyPort     = 9120
+	// rbacProxyUpstreamPort is the port MetalLB serves its metrics on when
+	// they are fronted by the proxy.
+	rbacProxyUpstreamPort = 29150
+	rbacProxyCertsVolume  = "metrics-certs"
+	rbacProxyCertsPath    = "/etc/metrics"
+)
+
+// rbacProxyEnabled returns whether the metrics are fronted by the kube-rbac-proxy,
+// by default only on OpenShift where the serving certificates are provided.
+func rbacProxyEnabled(spec *metallbv1beta1.MetalLBSpec, isOpenShift bool) bool {
+	if spec.EnableRBACProxy != nil {
+		return *spec.EnableRBACProxy
+	}
+	return isOpenShift || spec.MetricsTLSSecret != nil
+}
+
+// addRBACProxy moves the metrics of the speaker or the controller behind a
+// kube-rbac-proxy sidecar. The proxy serves the certificate of the
+// MetricsTLSSecret when set. Otherwise, on OpenShift it serves the certificate
+// the service CA issues for the metrics Service, elsewhere a self signed one.
+func addRBACProxy(spec *metallbv1beta1.MetalLBSpec, obj *uns.Unstructured, image string, isOpenShift bool) error {
+	switch obj.GetKind() {
+	case "DaemonSet":
+		ds := &appsv1.DaemonSet{}
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(obj.Object, ds); err != nil {
+			return err
+		}
+		addRBACProxyToPod(&ds.Spec.Template, image, certsSecret(spec, ds.Name, isOpenShift))
+		return toUnstructured(ds, obj)
+	case "Deployment":
+		deployment := &appsv1.Deployment{}
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(obj.Object, deployment); err != nil {
+			return err
+		}
+		addRBACProxyToPod(&deployment.Spec.Template, image, certsSecret(spec, deployment.Name, isOpenShift))
+		return toUnstructured(deployment, obj)
+	case "PodSecurityPolicy":
+		if obj.GetName() != "speaker" {
+			return nil
+		}
+		// The speaker runs with the host network, its ports are host ports
+		return addHostPort(obj, rbacProxyPort)
+	}
+	return nil
+}
+
+// certsSecret returns the name of the secret holding the serving certificate
+// of the metrics Service of the component: the MetricsTLSSecret, or the one
+// issued by the service CA, see metrics-service.yaml.
+func certsSecret(spec *metallbv1beta1.MetalLBSpec, component string, isOpenShift bool) string {
+	if spec.MetricsTLSSecret != nil {
+		return spec.MetricsTLSSecret.Name
+	}
+	if !isOpenShift {
+		return ""
+	}
+	return fmt.Sprintf("%s-metrics-certs", component)
+}
+
+func addRBACProxyToPod(template *corev1.PodTemplateSpec, image, secret string) {
+	podSpec := &template.Spec
+	for i := range podSpec.Containers {
+		c := &podSpec.Containers[i]
+		port := metricsPort(c)
+		if c.Name == rbacProxyContainerName || port == 0 {
+			continue
+		}
+		for j, arg := range c.Args {
+			if arg == fmt.Sprintf("--port=%d", port) {
+				c.Args[j] = fmt.Sprintf("--port=%d", rbacProxyUpstreamPort)
+			}
+		}
+		ports := []corev1.ContainerPort{}
+		for _, p := range c.Ports {
+			if p.Name != metricsPortName {
+				ports = append(ports, p)
+			}
+		}
+		c.Ports = ports
+	}
+	if template.Annotations != nil {
+		if _, ok := template.Annotations["prometheus.io/port"]; ok {
+			template.Annotations["prometheus.io/port"] = strconv.Itoa(rbacProxyPort)
+			template.Annotations["prometheus.io/scheme"] = "https"
+		}
+	}
+
+	for _, c := range podSpec.Containers {
+		if c.Name == rbacProxyContainerName {
+			return
+		}
+	}
+	readOnly := true
+	allowPrivilegeEscalation := false
+	proxy := corev1.Container{
+		Name:  rbacProxyContainerName,
+		Image: image,
+		Args: []string{
+			"--logtostderr",
+			fmt.Sprintf("--secure-listen-address=0.0.0.0:%d", rbacProxyPort),
+			fmt.Sprintf("--upstream=http://127.0.0.1:%d/", rbacProxyUpstreamPort),
+		},
+		Ports: []corev1.ContainerPort{{Name: rbacProxyPortName, ContainerPort: rbacProxyPort}},
+		Resources: corev1.ResourceRequirements{
+			Requests: corev1.ResourceList{
+				corev1.ResourceCPU:    resource.MustParse("10m"),
+				corev1.ResourceMemory: resource.MustParse("20Mi"),
+			},
+		},
+		SecurityContext: &corev1.SecurityContext{
+			AllowPrivilegeEscalation: &allowPrivilegeEscalation,
+			ReadOnlyRootFilesystem:   &readOnly,
+			Capabilities:             &corev1.Capabilities{Drop: []corev1.Capability{"ALL"}},
+		},
+	}
+	if secret != "" {
+		proxy.Args = append(proxy.Args,
+			fmt.Sprintf("--tls-cert-file=%s/tls.crt", rbacProxyCertsPath),
+			fmt.Sprintf("--tls-private-key-file=%s/tls.key", rbacProxyCertsPath))
+		proxy.VolumeMounts = []corev1.VolumeMount{{Name: rbacProxyCertsVolume, MountPath: rbacProxyCertsPath, ReadOnly: true}}
+		podSpec.Volumes = append(podSpec.Volumes, corev1.Volume{
+			Name:         rbacProxyCertsVolume,
+			VolumeSource: corev1.VolumeSource{Secret: &corev1.SecretVolumeSource{SecretName: secret}},
+		})
+	}
+	podSpec.Containers = append(podSpec.Containers, proxy)
+}
+
+// metricsPort returns the port MetalLB serves its metrics on, 0 if the
+// container does not serve them.
+func metricsPort(c *corev1.Container) int32 {
+	for _, p := range c.Ports {
+		if p.Name == metricsPortName {
+			return p.ContainerPort
+		}
+	}
+	return 0
+}
+
+// addHostPort adds the given port to the host ports a PodSecurityPolicy allows.
+func addHostPort(obj *uns.Unstructured, port int64) error {
+	ranges, _, err := uns.NestedSlice(obj.Object, "spec", "hostPorts")
+	if err != nil {
+		return err
+	}
+	for _, r := range ranges {
+		portRange, ok := r.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		min, _, _ := uns.NestedInt64(portRange, "min")
+		max, _, _ := uns.NestedInt64(portRange, "max")
+		if min <= port && port <= max {
+			return nil
+		}
+	}
+	ranges = append(ranges, map[string]interface{}{"min": port, "max": port})
+	return uns.SetNestedSlice(obj.Object, ranges, "spec", "hostPorts")
+}
@@ -0,0 +1,588 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"net"
+	"os"
+	"strings"
+
+	"github.com/pkg/errors"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	uns "k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/version"
+
+	metallbv1beta1 "github.com/metallb/metallb-operator/api/v1beta1"
+	"github.com/metallb/metallb-operator/pkg/addresses"
+	"github.com/metallb/metallb-operator/pkg/render"
+)
+
+const (
+	tmpVolumeName = "tmp"
+	tmpMountPath  = "/tmp"
+
+	speakerContainerName    = "speaker"
+	controllerContainerName = "controller"
+	// speakerNodeNameEnv passes the node name to the speaker through the
+	// downward API, the speaker can't announce the services without it.
+	speakerNodeNameEnv = "METALLB_NODE_NAME"
+)
+
+// renderMetalLBObjects renders the MetalLB manifests and applies on top of them
+// the overrides requested in the MetalLB spec.
+func (r *MetalLBReconciler) renderMetalLBObjects(config *metallbv1beta1.MetalLB) ([]*uns.Unstructured, error) {
+	if err := validateMetalLBSpec(&config.Spec); err != nil {
+		return nil, err
+	}
+	if err := validateSpeakerSysctls(config); err != nil {
+		return nil, err
+	}
+	if err := r.checkTargetNamespace(config); err != nil {
+		return nil, err
+	}
+
+	isOpenShift := r.isOpenShift()
+	rbacProxy := rbacProxyEnabled(&config.Spec, isOpenShift)
+	rbacProxyImage := os.Getenv("KUBE_RBAC_PROXY_IMAGE")
+	if rbacProxy && rbacProxyImage == "" {
+		return nil, errors.New("the kube-rbac-proxy is enabled but KUBE_RBAC_PROXY_IMAGE is not set")
+	}
+
+	frr := config.Spec.BGPBackend == metallbv1beta1.BGPBackendFRR
+	frrImage := os.Getenv("FRR_IMAGE")
+	if frr && frrImage == "" {
+		return nil, errors.New("the frr BGP backend is selected but FRR_IMAGE is not set")
+	}
+
+	data := render.MakeRenderData()
+
+	data.Data["SpeakerImage"], data.Data["ControllerImage"] = metalLBImages(&config.Spec)
+	data.Data["IsOpenShift"] = isOpenShift
+	data.Data["NameSpace"] = config.WorkloadsNamespace()
+	data.Data["RBACProxy"] = rbacProxy
+	data.Data["MetricsTLSSecret"] = config.Spec.MetricsTLSSecret != nil
+	data.Data["PrometheusRules"] = config.Spec.EnablePrometheusRules != nil && *config.Spec.EnablePrometheusRules
+	data.Data["ServiceMonitors"] = r.serviceMonitorsEnabled(&config.Spec, isOpenShift)
+	data.Data["FRR"] = frr
+	data.Data["FRRImage"] = frrImage
+	data.Data["FRRReadinessProbe"] = frrReadinessProbe(&config.Spec)
+	objs, err := render.RenderDir(ManifestPath, &data)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, obj := range objs {
+		if err := customizeObject(&config.Spec, obj); err != nil {
+			return nil, errors.Wrapf(err, "failed to customize (%s) %s/%s", obj.GroupVersionKind(), obj.GetNamespace(), obj.GetName())
+		}
+		if !rbacProxy {
+			continue
+		}
+		if err := addRBACProxy(&config.Spec, obj, rbacProxyImage, isOpenShift); err != nil {
+			return nil, errors.Wrapf(err, "failed to add the kube-rbac-proxy to (%s) %s/%s", obj.GroupVersionKind(), obj.GetNamespace(), obj.GetName())
+		}
+	}
+	return objs, nil
+}
+
+// frrReadinessProbe returns the settings of the readiness probe of the frr
+// container, the ones of the spec over the defaults.
+func frrReadinessProbe(spec *metallbv1beta1.MetalLBSpec) metallbv1beta1.ProbeConfig {
+	probe := metallbv1beta1.ProbeConfig{PeriodSeconds: 5, TimeoutSeconds: 1, FailureThreshold: 3}
+	if spec.FRRReadinessProbe == nil {
+		return probe
+	}
+	if spec.FRRReadinessProbe.PeriodSeconds != 0 {
+		probe.PeriodSeconds = spec.FRRReadinessProbe.PeriodSeconds
+	}
+	if spec.FRRReadinessProbe.TimeoutSeconds != 0 {
+		probe.TimeoutSeconds = spec.FRRReadinessProbe.TimeoutSeconds
+	}
+	if spec.FRRReadinessProbe.FailureThreshold != 0 {
+		probe.FailureThreshold = spec.FRRReadinessProbe.FailureThreshold
+	}
+	return probe
+}
+
+// metalLBImages returns the speaker and controller images to deploy, the ones
+// of the spec, or else the ones the operator is deployed with.
+func metalLBImages(spec *metallbv1beta1.MetalLBSpec) (string, string) {
+	speakerImage, controllerImage := spec.SpeakerImage, spec.ControllerImage
+	if speakerImage == "" {
+		speakerImage = os.Getenv("SPEAKER_IMAGE")
+	}
+	if controllerImage == "" {
+		controllerImage = os.Getenv("CONTROLLER_IMAGE")
+	}
+	return speakerImage, controllerImage
+}
+
+// validateImages checks the images of the spec are valid image references.
+func validateImages(spec *metallbv1beta1.MetalLBSpec) error {
+	images := []struct {
+		field string
+		image string
+	}{
+		{"speakerImage", spec.SpeakerImage},
+		{"controllerImage", spec.ControllerImage},
+	}
+	for _, i := range images {
+		if i.image == "" {
+			continue
+		}
+		if err := metallbv1beta1.ValidateImage(i.image); err != nil {
+			return errors.Errorf("invalid %s %q: %v", i.field, i.image, err)
+		}
+	}
+	return nil
+}
+
+// isOpenShift returns whether the operator runs on OpenShift, a reconciler
+// without platform runs on Kubernetes.
+func (r *MetalLBReconciler) isOpenShift() bool {
+	return r.PlatformInfo != nil && r.PlatformInfo.IsOpenShift()
+}
+
+// serviceMonitorsEnabled returns whether the ServiceMonitors are deployed, as
+// requested by EnableMetrics or by default on OpenShift, when the platform
+// serves the Prometheus Operator API.
+func (r *MetalLBReconciler) serviceMonitorsEnabled(spec *metallbv1beta1.MetalLBSpec, isOpenShift bool) bool {
+	enabled := isOpenShift
+	if spec.EnableMetrics != nil {
+		enabled = *spec.EnableMetrics
+	}
+	return enabled && r.PlatformInfo != nil && r.PlatformInfo.HasServiceMonitors()
+}
+
+// validateMetalLBSpec checks the fields of the spec the CRD schema can't validate,
+// or that could have been set bypassing it.
+func validateMetalLBSpec(spec *metallbv1beta1.MetalLBSpec) error {
+	if spec.MinMetalLBVersion != "" {
+		if _, err := version.ParseGeneric(spec.MinMetalLBVersion); err != nil {
+			return errors.Wrapf(err, "invalid minMetalLBVersion %q", spec.MinMetalLBVersion)
+		}
+	}
+	if err := validateImages(spec); err != nil {
+		return err
+	}
+	if spec.DegradedThreshold.Duration < 0 {
+		return errors.Errorf("invalid degradedThreshold %q, must not be negative", spec.DegradedThreshold.Duration)
+	}
+	if probe := spec.FRRReadinessProbe; probe != nil && (probe.PeriodSeconds < 0 || probe.TimeoutSeconds < 0 || probe.FailureThreshold < 0) {
+		return errors.Errorf("invalid frrReadinessProbe %+v, must not be negative", *probe)
+	}
+	if spec.LogLevel != "" && logLevel(spec) != spec.LogLevel {
+		return errors.Errorf("invalid logLevel %q, must be one of %q", spec.LogLevel, metallbv1beta1.LogLevels)
+	}
+	switch spec.BGPBackend {
+	case "", metallbv1beta1.BGPBackendNative, metallbv1beta1.BGPBackendFRR:
+	default:
+		return errors.Errorf("invalid bgpBackend %q, must be one of %q, %q", spec.BGPBackend,
+			metallbv1beta1.BGPBackendNative, metallbv1beta1.BGPBackendFRR)
+	}
+	switch spec.PoolSortOrder {
+	case "", metallbv1beta1.PoolSortByName, metallbv1beta1.PoolSortByAddress:
+	default:
+		return errors.Errorf("invalid poolSortOrder %q, must be one of %q, %q", spec.PoolSortOrder,
+			metallbv1beta1.PoolSortByName, metallbv1beta1.PoolSortByAddress)
+	}
+	if spec.TargetNamespace != "" {
+		if errs := validation.IsDNS1123Label(spec.TargetNamespace); len(errs) > 0 {
+			return errors.Errorf("invalid targetNamespace %q: %s", spec.TargetNamespace, strings.Join(errs, ", "))
+		}
+	}
+	serviceAccounts := []struct{ field, name string }{
+		{"speakerServiceAccountName", spec.SpeakerServiceAccountName},
+		{"controllerServiceAccountName", spec.ControllerServiceAccountName},
+	}
+	for _, sa := range serviceAccounts {
+		if sa.name == "" {
+			continue
+		}
+		if errs := validation.IsDNS1123Subdomain(sa.name); len(errs) > 0 {
+			return errors.Errorf("invalid %s %q: %s", sa.field, sa.name, strings.Join(errs, ", "))
+		}
+	}
+	hostAliases := []struct {
+		field   string
+		aliases []corev1.HostAlias
+	}{
+		{"speakerHostAliases", spec.SpeakerHostAliases},
+		{"controllerHostAliases", spec.ControllerHostAliases},
+	}
+	for _, h := range hostAliases {
+		for _, alias := range h.aliases {
+			if net.ParseIP(alias.IP) == nil {
+				return errors.Errorf("invalid %s ip %q", h.field, alias.IP)
+			}
+		}
+	}
+	nodeSelectors := []struct {
+		field    string
+		selector map[string]string
+	}{
+		{"speakerNodeSelector", spec.SpeakerNodeSelector},
+		{"controllerNodeSelector", spec.ControllerNodeSelector},
+	}
+	for _, n := range nodeSelectors {
+		for key, value := range n.selector {
+			if errs := validation.IsQualifiedName(key); len(errs) > 0 {
+				return errors.Errorf("invalid %s key %q: %s", n.field, key, strings.Join(errs, ", "))
+			}
+			if errs := validation.IsValidLabelValue(value); len(errs) > 0 {
+				return errors.Errorf("invalid %s value %q: %s", n.field, value, strings.Join(errs, ", "))
+			}
+		}
+	}
+	tolerations := []struct {
+		field       string
+		tolerations []corev1.Toleration
+	}{
+		{"speakerTolerations", spec.SpeakerTolerations},
+		{"controllerTolerations", spec.ControllerTolerations},
+	}
+	for _, t := range tolerations {
+		for _, toleration := range t.tolerations {
+			switch {
+			case toleration.Operator != "" && toleration.Operator != corev1.TolerationOpEqual && toleration.Operator != corev1.TolerationOpExists:
+				return errors.Errorf("invalid %s operator %q, must be one of %q, %q", t.field, toleration.Operator,
+					corev1.TolerationOpEqual, corev1.TolerationOpExists)
+			case toleration.Operator == corev1.TolerationOpExists && toleration.Value != "":
+				return errors.Errorf("invalid %s value %q, must be empty with the %q operator", t.field, toleration.Value,
+					corev1.TolerationOpExists)
+			}
+		}
+	}
+	resources := []struct {
+		field     string
+		resources *corev1.ResourceRequirements
+	}{
+		{"speakerResources", spec.SpeakerResources},
+		{"controllerResources", spec.ControllerResources},
+	}
+	for _, r := range resources {
+		if r.resources == nil {
+			continue
+		}
+		for name, request := range r.resources.Requests {
+			limit, ok := r.resources.Limits[name]
+			if ok && request.Cmp(limit) > 0 {
+				return errors.Errorf("invalid %s, the %s request %s exceeds the limit %s", r.field, name, request.String(), limit.String())
+			}
+		}
+	}
+	if spec.MetricsTLSSecret != nil {
+		if errs := validation.IsDNS1123Subdomain(spec.MetricsTLSSecret.Name); len(errs) > 0 {
+			return errors.Errorf("invalid metricsTLSSecret %q: %s", spec.MetricsTLSSecret.Name, strings.Join(errs, ", "))
+		}
+		if spec.EnableRBACProxy != nil && !*spec.EnableRBACProxy {
+			return errors.New("the metricsTLSSecret is served by the kube-rbac-proxy, it can't be set with enableRBACProxy false")
+		}
+	}
+	if spec.EnableCanary != nil && *spec.EnableCanary {
+		if spec.CanaryAddresses == "" {
+			return errors.New("the canaryAddresses must be set to enable the canary")
+		}
+		if _, _, err := addresses.ParseRange(spec.CanaryAddresses); err != nil {
+			return errors.Wrapf(err, "invalid canaryAddresses %q", spec.CanaryAddresses)
+		}
+	}
+	dnsPolicies := []struct {
+		field  string
+		policy corev1.DNSPolicy
+	}{
+		{"speakerDNSPolicy", spec.SpeakerDNSPolicy},
+		{"controllerDNSPolicy", spec.ControllerDNSPolicy},
+	}
+	for _, d := range dnsPolicies {
+		switch d.policy {
+		case "", corev1.DNSClusterFirstWithHostNet, corev1.DNSClusterFirst, corev1.DNSDefault:
+		default:
+			return errors.Errorf("invalid %s %q, must be one of %q, %q, %q", d.field, d.policy,
+				corev1.DNSClusterFirstWithHostNet, corev1.DNSClusterFirst, corev1.DNSDefault)
+		}
+	}
+	return nil
+}
+
+// customizeObject applies the MetalLB spec to a rendered object. The manifests
+// are generated from the upstream MetalLB ones, so the spec driven changes are
+// applied here rather than in the templates.
+func customizeObject(spec *metallbv1beta1.MetalLBSpec, obj *uns.Unstructured) error {
+	switch obj.GetKind() {
+	case "DaemonSet":
+		ds := &appsv1.DaemonSet{}
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(obj.Object, ds); err != nil {
+			return err
+		}
+		customizeSpeaker(spec, ds)
+		return toUnstructured(ds, obj)
+	case "Deployment":
+		deployment := &appsv1.Deployment{}
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(obj.Object, deployment); err != nil {
+			return err
+		}
+		customizeController(spec, deployment)
+		return toUnstructured(deployment, obj)
+	case "PodSecurityPolicy":
+		return customizePodSecurityPolicy(spec, obj)
+	}
+	return nil
+}
+
+func customizeSpeaker(spec *metallbv1beta1.MetalLBSpec, ds *appsv1.DaemonSet) {
+	if spec.SpeakerServiceAccountName != "" {
+		ds.Spec.Template.Spec.ServiceAccountName = spec.SpeakerServiceAccountName
+	}
+	if len(spec.SpeakerHostAliases) > 0 {
+		ds.Spec.Template.Spec.HostAliases = spec.SpeakerHostAliases
+	}
+	if spec.SpeakerDNSPolicy != "" {
+		ds.Spec.Template.Spec.DNSPolicy = spec.SpeakerDNSPolicy
+	}
+	if spec.SpeakerPriorityClassName != "" {
+		ds.Spec.Template.Spec.PriorityClassName = spec.SpeakerPriorityClassName
+	}
+	ds.Spec.Template.Spec.Tolerations = mergeTolerations(ds.Spec.Template.Spec.Tolerations, spec.SpeakerTolerations)
+	mergeNodeSelector(&ds.Spec.Template.Spec, spec.SpeakerNodeSelector)
+	if len(spec.SpeakerSysctls) > 0 {
+		if ds.Spec.Template.Spec.SecurityContext == nil {
+			ds.Spec.Template.Spec.SecurityContext = &corev1.PodSecurityContext{}
+		}
+		ds.Spec.Template.Spec.SecurityContext.Sysctls = spec.SpeakerSysctls
+	}
+	if spec.SpeakerResources != nil {
+		setContainerResources(&ds.Spec.Template.Spec, speakerContainerName, *spec.SpeakerResources)
+	}
+	setContainerArg(&ds.Spec.Template.Spec, speakerContainerName, "--log-level", logLevel(spec))
+	setSpeakerNodeName(&ds.Spec.Template.Spec)
+	customizePodSpec(spec, &ds.Spec.Template.Spec)
+}
+
+func customizeController(spec *metallbv1beta1.MetalLBSpec, deployment *appsv1.Deployment) {
+	if spec.ControllerServiceAccountName != "" {
+		deployment.Spec.Template.Spec.ServiceAccountName = spec.ControllerServiceAccountName
+	}
+	if len(spec.ControllerHostAliases) > 0 {
+		deployment.Spec.Template.Spec.HostAliases = spec.ControllerHostAliases
+	}
+	if spec.ControllerDNSPolicy != "" {
+		deployment.Spec.Template.Spec.DNSPolicy = spec.ControllerDNSPolicy
+	}
+	if spec.ControllerPriorityClassName != "" {
+		deployment.Spec.Template.Spec.PriorityClassName = spec.ControllerPriorityClassName
+	}
+	deployment.Spec.Template.Spec.Tolerations = mergeTolerations(deployment.Spec.Template.Spec.Tolerations, spec.ControllerTolerations)
+	mergeNodeSelector(&deployment.Spec.Template.Spec, spec.ControllerNodeSelector)
+	if spec.ControllerResources != nil {
+		setContainerResources(&deployment.Spec.Template.Spec, controllerContainerName, *spec.ControllerResources)
+	}
+	setContainerArg(&deployment.Spec.Template.Spec, controllerContainerName, "--log-level", logLevel(spec))
+	customizePodSpec(spec, &deployment.Spec.Template.Spec)
+}
+
+// mergeNodeSelector adds the labels of the spec to the node selector of the
+// manifests.
+func mergeNodeSelector(podSpec *corev1.PodSpec, selector map[string]string) {
+	if len(selector) == 0 {
+		return
+	}
+	if podSpec.NodeSelector == nil {
+		podSpec.NodeSelector = map[string]string{}
+	}
+	for key, value := range selector {
+		podSpec.NodeSelector[key] = value
+	}
+}
+
+// mergeTolerations appends the tolerations of the spec to the ones of the
+// manifests, skipping the ones with the same key, operator and value as a
+// previous one.
+func mergeTolerations(tolerations, added []corev1.Toleration) []corev1.Toleration {
+	type tolerationKey struct {
+		key      string
+		operator corev1.TolerationOperator
+		value    string
+	}
+	seen := map[tolerationKey]bool{}
+	for _, t := range tolerations {
+		seen[tolerationKey{t.Key, t.Operator, t.Value}] = true
+	}
+	for _, t := range added {
+		key := tolerationKey{t.Key, t.Operator, t.Value}
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		tolerations = append(tolerations, t)
+	}
+	return tolerations
+}
+
+// setContainerResources replaces the resources of the named container.
+func setContainerResources(podSpec *corev1.PodSpec, name string, resources corev1.ResourceRequirements) {
+	for i := range podSpec.Containers {
+		if podSpec.Containers[i].Name == name {
+			podSpec.Containers[i].Resources = resources
+		}
+	}
+}
+
+// setContainerArg sets the flag of the named container to the value,
+// replacing the value of the manifests if any.
+func setContainerArg(podSpec *corev1.PodSpec, name, flag, value string) {
+	arg := flag + "=" + value
+	for i := range podSpec.Containers {
+		c := &podSpec.Containers[i]
+		if c.Name != name {
+			continue
+		}
+		found := false
+		for j := range c.Args {
+			if c.Args[j] == flag || strings.HasPrefix(c.Args[j], flag+"=") {
+				c.Args[j] = arg
+				found = true
+			}
+		}
+		if !found {
+			c.Args = append(c.Args, arg)
+		}
+	}
+}
+
+// logLevel returns the log level of the speaker and the controller, info
+// when the spec has none or an unknown one.
+func logLevel(spec *metallbv1beta1.MetalLBSpec) string {
+	for _, level := range metallbv1beta1.LogLevels {
+		if spec.LogLevel == level {
+			return level
+		}
+	}
+	return metallbv1beta1.LogLevelInfo
+}
+
+// setSpeakerNodeName makes the speaker container get the node name from the
+// downward API, replacing any other value.
+func setSpeakerNodeName(podSpec *corev1.PodSpec) {
+	nodeName := corev1.EnvVar{
+		Name: speakerNodeNameEnv,
+		ValueFrom: &corev1.EnvVarSource{
+			FieldRef: &corev1.ObjectFieldSelector{FieldPath: "spec.nodeName"},
+		},
+	}
+	for i := range podSpec.Containers {
+		c := &podSpec.Containers[i]
+		if c.Name != speakerContainerName {
+			continue
+		}
+		for j := range c.Env {
+			if c.Env[j].Name == speakerNodeNameEnv {
+				c.Env[j] = nodeName
+				return
+			}
+		}
+		c.Env = append([]corev1.EnvVar{nodeName}, c.Env...)
+	}
+}
+
+// hasSpeakerNodeName returns whether the speaker container gets the node name
+// from the downward API.
+func hasSpeakerNodeName(podSpec *corev1.PodSpec) bool {
+	for _, c := range podSpec.Containers {
+		if c.Name != speakerContainerName {
+			continue
+		}
+		for _, env := range c.Env {
+			if env.Name == speakerNodeNameEnv && env.ValueFrom != nil && env.ValueFrom.FieldRef != nil {
+				return env.ValueFrom.FieldRef.FieldPath == "spec.nodeName"
+			}
+		}
+	}
+	return false
+}
+
+// customizePodSpec applies the settings shared by the speaker and the controller.
+func customizePodSpec(spec *metallbv1beta1.MetalLBSpec, podSpec *corev1.PodSpec) {
+	if spec.ReadOnlyRootFilesystem != nil {
+		setReadOnlyRootFilesystem(podSpec, *spec.ReadOnlyRootFilesystem)
+	}
+}
+
+func customizePodSecurityPolicy(spec *metallbv1beta1.MetalLBSpec, obj *uns.Unstructured) error {
+	if spec.ReadOnlyRootFilesystem != nil {
+		if err := uns.SetNestedField(obj.Object, *spec.ReadOnlyRootFilesystem, "spec", "readOnlyRootFilesystem"); err != nil {
+			return err
+		}
+	}
+	if unsafe := unsafeSysctlNames(spec.SpeakerSysctls); obj.GetName() == "speaker" && len(unsafe) > 0 {
+		return uns.SetNestedStringSlice(obj.Object, unsafe, "spec", "allowedUnsafeSysctls")
+	}
+	return nil
+}
+
+// setReadOnlyRootFilesystem sets the root filesystem of all the containers of the pod
+// as read-only or writable. When read-only, an emptyDir is mounted on /tmp so the
+// containers still have a writable scratch location.
+func setReadOnlyRootFilesystem(podSpec *corev1.PodSpec, readOnly bool) {
+	for i := range podSpec.Containers {
+		c := &podSpec.Containers[i]
+		if c.SecurityContext == nil {
+			c.SecurityContext = &corev1.SecurityContext{}
+		}
+		c.SecurityContext.ReadOnlyRootFilesystem = &readOnly
+		if readOnly && !hasVolumeMount(c, tmpVolumeName) {
+			c.VolumeMounts = append(c.VolumeMounts, corev1.VolumeMount{Name: tmpVolumeName, MountPath: tmpMountPath})
+		}
+	}
+	if readOnly && !hasVolume(podSpec, tmpVolumeName) {
+		podSpec.Volumes = append(podSpec.Volumes, corev1.Volume{
+			Name:         tmpVolumeName,
+			VolumeSource: corev1.VolumeSource{EmptyDir: &corev1.EmptyDirVolumeSource{}},
+		})
+	}
+}
+
+func hasVolume(podSpec *corev1.PodSpec, name string) bool {
+	for _, v := range podSpec.Volumes {
+		if v.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+func hasVolumeMount(c *corev1.Container, name string) bool {
+	for _, m := range c.VolumeMounts {
+		if m.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// toUnstructured replaces the content of obj with the given typed object.
+func toUnstructured(typed interface{}, obj *uns.Unstructured) error {
+	content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(typed)
+	if err != nil {
+		return err
+	}
+	obj.Object = content
+	return nil
+}
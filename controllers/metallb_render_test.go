@@ -0,0 +1,658 @@
+package controllers
+
+import (
+	"os"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	uns "k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/intstr"
+
+	metallbv1beta1 "github.com/metallb/metallb-operator/api/v1beta1"
+	"github.com/metallb/metallb-operator/pkg/platform"
+	"github.com/metallb/metallb-operator/pkg/platform/fake"
+	"github.com/metallb/metallb-operator/test/manifests"
+)
+
+func TestRenderReadOnlyRootFilesystem(t *testing.T) {
+	g := NewGomegaWithT(t)
+	readOnly := true
+
+	objs := renderTestObjects(g, metallbv1beta1.MetalLBSpec{ReadOnlyRootFilesystem: &readOnly})
+	speaker, controller := speakerAndController(g, objs)
+
+	for _, podSpec := range []corev1.PodSpec{speaker.Spec.Template.Spec, controller.Spec.Template.Spec} {
+		g.Expect(podSpec.Volumes).To(ContainElement(corev1.Volume{
+			Name:         tmpVolumeName,
+			VolumeSource: corev1.VolumeSource{EmptyDir: &corev1.EmptyDirVolumeSource{}},
+		}))
+		for _, c := range podSpec.Containers {
+			g.Expect(c.SecurityContext.ReadOnlyRootFilesystem).To(Equal(&readOnly))
+			g.Expect(c.VolumeMounts).To(ContainElement(corev1.VolumeMount{Name: tmpVolumeName, MountPath: tmpMountPath}))
+		}
+	}
+}
+
+func TestRenderWritableRootFilesystem(t *testing.T) {
+	g := NewGomegaWithT(t)
+	readOnly := false
+
+	objs := renderTestObjects(g, metallbv1beta1.MetalLBSpec{ReadOnlyRootFilesystem: &readOnly})
+	speaker, controller := speakerAndController(g, objs)
+
+	for _, podSpec := range []corev1.PodSpec{speaker.Spec.Template.Spec, controller.Spec.Template.Spec} {
+		g.Expect(podSpec.Volumes).To(BeEmpty())
+		for _, c := range podSpec.Containers {
+			g.Expect(c.SecurityContext.ReadOnlyRootFilesystem).To(Equal(&readOnly))
+			g.Expect(c.VolumeMounts).To(BeEmpty())
+		}
+	}
+	for _, obj := range objs {
+		if obj.GetKind() != "PodSecurityPolicy" {
+			continue
+		}
+		value, _, err := uns.NestedBool(obj.Object, "spec", "readOnlyRootFilesystem")
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(value).To(BeFalse())
+	}
+}
+
+func TestRenderDefaultRootFilesystem(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	objs := renderTestObjects(g, metallbv1beta1.MetalLBSpec{})
+	speaker, controller := speakerAndController(g, objs)
+
+	for _, podSpec := range []corev1.PodSpec{speaker.Spec.Template.Spec, controller.Spec.Template.Spec} {
+		g.Expect(podSpec.Volumes).To(BeEmpty())
+		for _, c := range podSpec.Containers {
+			g.Expect(*c.SecurityContext.ReadOnlyRootFilesystem).To(BeTrue())
+		}
+	}
+}
+
+// renderTestObjects renders the MetalLB manifests for a MetalLB resource with the given spec.
+func renderTestObjects(g *WithT, spec metallbv1beta1.MetalLBSpec) []*uns.Unstructured {
+	return renderPlatformTestObjects(g, spec, platform.PlatformInfo{Name: platform.Kubernetes})
+}
+
+// renderPlatformTestObjects renders the MetalLB manifests on the given platform.
+func renderPlatformTestObjects(g *WithT, spec metallbv1beta1.MetalLBSpec, platformInfo PlatformInfo) []*uns.Unstructured {
+	manifestPath := ManifestPath
+	ManifestPath = "../bindata/deployment"
+	defer func() { ManifestPath = manifestPath }()
+
+	r := &MetalLBReconciler{Namespace: MetalLBTestNameSpace, PlatformInfo: platformInfo}
+	objs, err := r.renderMetalLBObjects(&metallbv1beta1.MetalLB{
+		ObjectMeta: metav1.ObjectMeta{Name: defaultMetalLBCrName, Namespace: MetalLBTestNameSpace},
+		Spec:       spec,
+	})
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(manifests.Validate(objs)).To(BeEmpty())
+	return objs
+}
+
+func TestRenderValidationCatchesBrokenManifests(t *testing.T) {
+	g := NewGomegaWithT(t)
+	manifestPath := ManifestPath
+	ManifestPath = "testdata/broken-manifests"
+	defer func() { ManifestPath = manifestPath }()
+
+	r := &MetalLBReconciler{Namespace: MetalLBTestNameSpace}
+	objs, err := r.renderMetalLBObjects(&metallbv1beta1.MetalLB{
+		ObjectMeta: metav1.ObjectMeta{Name: defaultMetalLBCrName, Namespace: MetalLBTestNameSpace},
+	})
+	g.Expect(err).ToNot(HaveOccurred())
+
+	errs := manifests.Validate(objs)
+	messages := []string{}
+	for _, err := range errs {
+		messages = append(messages, err.Error())
+	}
+	g.Expect(messages).To(ContainElement(ContainSubstring(`unknown field "hostPort"`)))
+	g.Expect(messages).To(ContainElement(ContainSubstring("hostNetwork")))
+}
+
+func speakerAndController(g *WithT, objs []*uns.Unstructured) (*appsv1.DaemonSet, *appsv1.Deployment) {
+	speaker := &appsv1.DaemonSet{}
+	controller := &appsv1.Deployment{}
+	for _, obj := range objs {
+		switch obj.GetKind() {
+		case "DaemonSet":
+			g.Expect(runtime.DefaultUnstructuredConverter.FromUnstructured(obj.Object, speaker)).To(Succeed())
+		case "Deployment":
+			g.Expect(runtime.DefaultUnstructuredConverter.FromUnstructured(obj.Object, controller)).To(Succeed())
+		}
+	}
+	g.Expect(speaker.Name).To(Equal("speaker"))
+	g.Expect(controller.Name).To(Equal("controller"))
+	return speaker, controller
+}
+
+func TestRenderServiceAccountNames(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	objs := renderTestObjects(g, metallbv1beta1.MetalLBSpec{
+		SpeakerServiceAccountName:    "speaker-iam",
+		ControllerServiceAccountName: "controller-iam",
+	})
+	speaker, controller := speakerAndController(g, objs)
+	g.Expect(speaker.Spec.Template.Spec.ServiceAccountName).To(Equal("speaker-iam"))
+	g.Expect(controller.Spec.Template.Spec.ServiceAccountName).To(Equal("controller-iam"))
+
+	// The ServiceAccounts are provided by the user, the operator must not create any.
+	for _, obj := range objs {
+		g.Expect(obj.GetKind()).ToNot(Equal("ServiceAccount"))
+	}
+}
+
+func TestRenderDefaultServiceAccountNames(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	objs := renderTestObjects(g, metallbv1beta1.MetalLBSpec{})
+	speaker, controller := speakerAndController(g, objs)
+	g.Expect(speaker.Spec.Template.Spec.ServiceAccountName).To(Equal("speaker"))
+	g.Expect(controller.Spec.Template.Spec.ServiceAccountName).To(Equal("controller"))
+}
+
+func TestRenderHostAliases(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	speakerAliases := []corev1.HostAlias{
+		{IP: "192.168.10.1", Hostnames: []string{"peer1.example.com", "peer1"}},
+		{IP: "192.168.10.2", Hostnames: []string{"peer2.example.com"}},
+	}
+	controllerAliases := []corev1.HostAlias{
+		{IP: "10.0.0.1", Hostnames: []string{"registry.example.com"}},
+	}
+	objs := renderTestObjects(g, metallbv1beta1.MetalLBSpec{
+		SpeakerHostAliases:    speakerAliases,
+		ControllerHostAliases: controllerAliases,
+	})
+	speaker, controller := speakerAndController(g, objs)
+	g.Expect(speaker.Spec.Template.Spec.HostAliases).To(Equal(speakerAliases))
+	g.Expect(controller.Spec.Template.Spec.HostAliases).To(Equal(controllerAliases))
+
+	objs = renderTestObjects(g, metallbv1beta1.MetalLBSpec{})
+	speaker, controller = speakerAndController(g, objs)
+	g.Expect(speaker.Spec.Template.Spec.HostAliases).To(BeEmpty())
+	g.Expect(controller.Spec.Template.Spec.HostAliases).To(BeEmpty())
+	err := validateMetalLBSpec(&metallbv1beta1.MetalLBSpec{
+		SpeakerHostAliases: []corev1.HostAlias{{IP: "peer1", Hostnames: []string{"peer1.example.com"}}},
+	})
+	g.Expect(err).To(MatchError(ContainSubstring("speakerHostAliases")))
+}
+
+func TestRenderSpeakerNodeSelector(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	objs := renderTestObjects(g, metallbv1beta1.MetalLBSpec{
+		SpeakerNodeSelector: map[string]string{"node-role.kubernetes.io/worker": "", "metallb.io/speaker": "true"},
+	})
+	speaker, controller := speakerAndController(g, objs)
+	g.Expect(speaker.Spec.Template.Spec.NodeSelector).To(Equal(map[string]string{
+		"kubernetes.io/os":               "linux",
+		"node-role.kubernetes.io/worker": "",
+		"metallb.io/speaker":             "true",
+	}))
+	g.Expect(controller.Spec.Template.Spec.NodeSelector).To(Equal(map[string]string{"kubernetes.io/os": "linux"}))
+
+	// The selector of the manifests is kept when unset
+	objs = renderTestObjects(g, metallbv1beta1.MetalLBSpec{})
+	speaker, _ = speakerAndController(g, objs)
+	g.Expect(speaker.Spec.Template.Spec.NodeSelector).To(Equal(map[string]string{"kubernetes.io/os": "linux"}))
+
+	err := validateMetalLBSpec(&metallbv1beta1.MetalLBSpec{SpeakerNodeSelector: map[string]string{"metallb io/speaker": "true"}})
+	g.Expect(err).To(MatchError(ContainSubstring("invalid speakerNodeSelector key")))
+	err = validateMetalLBSpec(&metallbv1beta1.MetalLBSpec{SpeakerNodeSelector: map[string]string{"metallb.io/speaker": "yes please"}})
+	g.Expect(err).To(MatchError(ContainSubstring("invalid speakerNodeSelector value")))
+}
+
+func TestRenderControllerNodeSelector(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	objs := renderTestObjects(g, metallbv1beta1.MetalLBSpec{
+		ControllerNodeSelector: map[string]string{"node-role.kubernetes.io/infra": ""},
+	})
+	speaker, controller := speakerAndController(g, objs)
+	g.Expect(controller.Spec.Template.Spec.NodeSelector).To(Equal(map[string]string{
+		"kubernetes.io/os":              "linux",
+		"node-role.kubernetes.io/infra": "",
+	}))
+	g.Expect(speaker.Spec.Template.Spec.NodeSelector).To(Equal(map[string]string{"kubernetes.io/os": "linux"}))
+
+	err := validateMetalLBSpec(&metallbv1beta1.MetalLBSpec{ControllerNodeSelector: map[string]string{"metallb io/controller": "true"}})
+	g.Expect(err).To(MatchError(ContainSubstring("invalid controllerNodeSelector key")))
+	err = validateMetalLBSpec(&metallbv1beta1.MetalLBSpec{ControllerNodeSelector: map[string]string{"metallb.io/controller": "yes please"}})
+	g.Expect(err).To(MatchError(ContainSubstring("invalid controllerNodeSelector value")))
+}
+
+func TestRenderLogLevel(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	objs := renderTestObjects(g, metallbv1beta1.MetalLBSpec{LogLevel: "debug"})
+	speaker, controller := speakerAndController(g, objs)
+	g.Expect(speaker.Spec.Template.Spec.Containers[0].Args).To(ContainElement("--log-level=debug"))
+	g.Expect(controller.Spec.Template.Spec.Containers[0].Args).To(ContainElement("--log-level=debug"))
+
+	// info is the default
+	objs = renderTestObjects(g, metallbv1beta1.MetalLBSpec{})
+	speaker, controller = speakerAndController(g, objs)
+	g.Expect(speaker.Spec.Template.Spec.Containers[0].Args).To(ContainElement("--log-level=info"))
+	g.Expect(controller.Spec.Template.Spec.Containers[0].Args).To(ContainElement("--log-level=info"))
+
+	err := validateMetalLBSpec(&metallbv1beta1.MetalLBSpec{LogLevel: "verbose"})
+	g.Expect(err).To(MatchError(ContainSubstring("invalid logLevel")))
+}
+
+func TestRenderTolerations(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	master := corev1.Toleration{Key: "node-role.kubernetes.io/master", Operator: corev1.TolerationOpExists, Effect: corev1.TaintEffectNoSchedule}
+	edge := corev1.Toleration{Key: "edge", Operator: corev1.TolerationOpEqual, Value: "true", Effect: corev1.TaintEffectNoSchedule}
+	infra := corev1.Toleration{Key: "node-role.kubernetes.io/infra", Operator: corev1.TolerationOpExists}
+	objs := renderTestObjects(g, metallbv1beta1.MetalLBSpec{
+		// The master toleration of the manifests and edge are only added once
+		SpeakerTolerations:    []corev1.Toleration{edge, master, edge},
+		ControllerTolerations: []corev1.Toleration{infra},
+	})
+	speaker, controller := speakerAndController(g, objs)
+	g.Expect(speaker.Spec.Template.Spec.Tolerations).To(Equal([]corev1.Toleration{master, edge}))
+	g.Expect(controller.Spec.Template.Spec.Tolerations).To(Equal([]corev1.Toleration{infra}))
+
+	// The tolerations of the manifests are kept when unset
+	objs = renderTestObjects(g, metallbv1beta1.MetalLBSpec{})
+	speaker, controller = speakerAndController(g, objs)
+	g.Expect(speaker.Spec.Template.Spec.Tolerations).To(Equal([]corev1.Toleration{master}))
+	g.Expect(controller.Spec.Template.Spec.Tolerations).To(BeEmpty())
+
+	// The same key with another value is another toleration
+	g.Expect(mergeTolerations([]corev1.Toleration{edge}, []corev1.Toleration{{Key: "edge", Operator: corev1.TolerationOpEqual, Value: "false"}})).To(HaveLen(2))
+
+	err := validateMetalLBSpec(&metallbv1beta1.MetalLBSpec{SpeakerTolerations: []corev1.Toleration{{Key: "edge", Operator: "In"}}})
+	g.Expect(err).To(MatchError(ContainSubstring("invalid speakerTolerations operator")))
+	err = validateMetalLBSpec(&metallbv1beta1.MetalLBSpec{ControllerTolerations: []corev1.Toleration{{Key: "edge", Operator: corev1.TolerationOpExists, Value: "true"}}})
+	g.Expect(err).To(MatchError(ContainSubstring("invalid controllerTolerations value")))
+}
+
+func TestRenderResources(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	speakerResources := corev1.ResourceRequirements{
+		Requests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("100m"), corev1.ResourceMemory: resource.MustParse("100Mi")},
+		Limits:   corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("200Mi")},
+	}
+	controllerResources := corev1.ResourceRequirements{
+		Requests: corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("50Mi")},
+	}
+	objs := renderTestObjects(g, metallbv1beta1.MetalLBSpec{
+		SpeakerResources:    &speakerResources,
+		ControllerResources: &controllerResources,
+	})
+	speaker, controller := speakerAndController(g, objs)
+	for _, c := range speaker.Spec.Template.Spec.Containers {
+		if c.Name == speakerContainerName {
+			g.Expect(c.Resources).To(Equal(speakerResources))
+		}
+	}
+	for _, c := range controller.Spec.Template.Spec.Containers {
+		if c.Name == controllerContainerName {
+			g.Expect(c.Resources).To(Equal(controllerResources))
+		}
+	}
+
+	// The resources of the manifests are kept when unset
+	objs = renderTestObjects(g, metallbv1beta1.MetalLBSpec{})
+	speaker, controller = speakerAndController(g, objs)
+	for _, c := range append(speaker.Spec.Template.Spec.Containers, controller.Spec.Template.Spec.Containers...) {
+		g.Expect(c.Resources).To(Equal(corev1.ResourceRequirements{}))
+	}
+
+	err := validateMetalLBSpec(&metallbv1beta1.MetalLBSpec{SpeakerResources: &corev1.ResourceRequirements{
+		Requests: corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("300Mi")},
+		Limits:   corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("200Mi")},
+	}})
+	g.Expect(err).To(MatchError(ContainSubstring("invalid speakerResources, the memory request 300Mi exceeds the limit 200Mi")))
+}
+
+func TestRenderDNSPolicy(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	objs := renderTestObjects(g, metallbv1beta1.MetalLBSpec{
+		SpeakerDNSPolicy:    corev1.DNSClusterFirstWithHostNet,
+		ControllerDNSPolicy: corev1.DNSDefault,
+	})
+	speaker, controller := speakerAndController(g, objs)
+	g.Expect(speaker.Spec.Template.Spec.DNSPolicy).To(Equal(corev1.DNSClusterFirstWithHostNet))
+	g.Expect(controller.Spec.Template.Spec.DNSPolicy).To(Equal(corev1.DNSDefault))
+
+	// The policies of the manifests are kept when unset
+	objs = renderTestObjects(g, metallbv1beta1.MetalLBSpec{})
+	speaker, controller = speakerAndController(g, objs)
+	g.Expect(speaker.Spec.Template.Spec.DNSPolicy).To(BeEmpty())
+	g.Expect(controller.Spec.Template.Spec.DNSPolicy).To(BeEmpty())
+
+	err := validateMetalLBSpec(&metallbv1beta1.MetalLBSpec{SpeakerDNSPolicy: corev1.DNSNone})
+	g.Expect(err).To(MatchError(ContainSubstring("invalid speakerDNSPolicy")))
+	err = validateMetalLBSpec(&metallbv1beta1.MetalLBSpec{ControllerDNSPolicy: "ClusterLast"})
+	g.Expect(err).To(MatchError(ContainSubstring("invalid controllerDNSPolicy")))
+}
+
+func TestRenderPriorityClassName(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	objs := renderTestObjects(g, metallbv1beta1.MetalLBSpec{
+		SpeakerPriorityClassName:    "system-node-critical",
+		ControllerPriorityClassName: "system-cluster-critical",
+	})
+	speaker, controller := speakerAndController(g, objs)
+	g.Expect(speaker.Spec.Template.Spec.PriorityClassName).To(Equal("system-node-critical"))
+	g.Expect(controller.Spec.Template.Spec.PriorityClassName).To(Equal("system-cluster-critical"))
+
+	// The priority classes of the manifests are kept when unset
+	objs = renderTestObjects(g, metallbv1beta1.MetalLBSpec{})
+	speaker, controller = speakerAndController(g, objs)
+	g.Expect(speaker.Spec.Template.Spec.PriorityClassName).To(BeEmpty())
+	g.Expect(controller.Spec.Template.Spec.PriorityClassName).To(BeEmpty())
+}
+
+func TestRenderSpeakerNodeName(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	objs := renderTestObjects(g, metallbv1beta1.MetalLBSpec{})
+	speaker, _ := speakerAndController(g, objs)
+	g.Expect(hasSpeakerNodeName(&speaker.Spec.Template.Spec)).To(BeTrue())
+	nodeName := corev1.EnvVar{
+		Name:      speakerNodeNameEnv,
+		ValueFrom: &corev1.EnvVarSource{FieldRef: &corev1.ObjectFieldSelector{FieldPath: "spec.nodeName"}},
+	}
+	g.Expect(speaker.Spec.Template.Spec.Containers[0].Env).To(ContainElement(nodeName))
+
+	// A missing or wrong env is set again
+	podSpec := corev1.PodSpec{Containers: []corev1.Container{
+		{Name: speakerContainerName, Env: []corev1.EnvVar{{Name: "METALLB_HOST", Value: "1.2.3.4"}}},
+	}}
+	g.Expect(hasSpeakerNodeName(&podSpec)).To(BeFalse())
+	setSpeakerNodeName(&podSpec)
+	g.Expect(podSpec.Containers[0].Env).To(Equal([]corev1.EnvVar{nodeName, {Name: "METALLB_HOST", Value: "1.2.3.4"}}))
+
+	podSpec.Containers[0].Env[0] = corev1.EnvVar{Name: speakerNodeNameEnv, Value: "worker-0"}
+	g.Expect(hasSpeakerNodeName(&podSpec)).To(BeFalse())
+	setSpeakerNodeName(&podSpec)
+	g.Expect(podSpec.Containers[0].Env).To(Equal([]corev1.EnvVar{nodeName, {Name: "METALLB_HOST", Value: "1.2.3.4"}}))
+}
+
+func TestRenderRBACProxy(t *testing.T) {
+	g := NewGomegaWithT(t)
+	defer setEnv("KUBE_RBAC_PROXY_IMAGE", "kube-rbac-proxy:test")()
+	enabled := true
+
+	for _, p := range []platform.PlatformType{platform.Kubernetes, platform.OpenShift} {
+		objs := renderPlatformTestObjects(g, metallbv1beta1.MetalLBSpec{EnableRBACProxy: &enabled}, platform.PlatformInfo{Name: p})
+		speaker, controller := speakerAndController(g, objs)
+
+		for _, template := range []corev1.PodTemplateSpec{speaker.Spec.Template, controller.Spec.Template} {
+			g.Expect(template.Annotations).To(HaveKeyWithValue("prometheus.io/port", "9120"))
+			containers := template.Spec.Containers
+			g.Expect(containers).To(HaveLen(2))
+			g.Expect(containers[0].Args).To(ContainElement("--port=29150"))
+			g.Expect(metricsPort(&containers[0])).To(BeZero())
+
+			proxy := containers[1]
+			g.Expect(proxy.Name).To(Equal(rbacProxyContainerName))
+			g.Expect(proxy.Image).To(Equal("kube-rbac-proxy:test"))
+			g.Expect(proxy.Args).To(ContainElement("--upstream=http://127.0.0.1:29150/"))
+			g.Expect(proxy.Ports).To(ConsistOf(corev1.ContainerPort{Name: "metricshttps", ContainerPort: 9120}))
+			if p == platform.OpenShift {
+				g.Expect(proxy.Args).To(ContainElement("--tls-cert-file=/etc/metrics/tls.crt"))
+				g.Expect(hasVolume(&template.Spec, rbacProxyCertsVolume)).To(BeTrue())
+			} else {
+				g.Expect(proxy.Args).ToNot(ContainElement(ContainSubstring("--tls-cert-file")))
+				g.Expect(template.Spec.Volumes).To(BeEmpty())
+			}
+		}
+
+		for _, svc := range metricsServices(g, objs) {
+			g.Expect(svc.Spec.Ports).To(ConsistOf(corev1.ServicePort{Name: "metricshttps", Port: 9120, TargetPort: intstr.FromString("metricshttps")}))
+			if p == platform.OpenShift {
+				g.Expect(svc.Annotations).To(HaveKey("service.beta.openshift.io/serving-cert-secret-name"))
+			} else {
+				g.Expect(svc.Annotations).To(BeEmpty())
+			}
+		}
+		g.Expect(speakerHostPorts(g, objs)).To(ContainElement(int64(9120)))
+	}
+}
+
+func TestRenderNoRBACProxy(t *testing.T) {
+	g := NewGomegaWithT(t)
+	disabled := false
+
+	// Enabled by default on OpenShift only
+	for _, c := range []struct {
+		spec     metallbv1beta1.MetalLBSpec
+		platform platform.PlatformType
+	}{
+		{metallbv1beta1.MetalLBSpec{}, platform.Kubernetes},
+		{metallbv1beta1.MetalLBSpec{EnableRBACProxy: &disabled}, platform.OpenShift},
+	} {
+		objs := renderPlatformTestObjects(g, c.spec, platform.PlatformInfo{Name: c.platform})
+		speaker, controller := speakerAndController(g, objs)
+
+		for _, template := range []corev1.PodTemplateSpec{speaker.Spec.Template, controller.Spec.Template} {
+			g.Expect(template.Annotations).To(HaveKeyWithValue("prometheus.io/port", "7472"))
+			g.Expect(template.Spec.Containers).To(HaveLen(1))
+			g.Expect(template.Spec.Containers[0].Args).To(ContainElement("--port=7472"))
+			g.Expect(metricsPort(&template.Spec.Containers[0])).To(Equal(int32(7472)))
+		}
+		for _, svc := range metricsServices(g, objs) {
+			g.Expect(svc.Spec.Ports).To(ConsistOf(corev1.ServicePort{Name: "monitoring", Port: 7472, TargetPort: intstr.FromString("monitoring")}))
+			g.Expect(svc.Annotations).To(BeEmpty())
+		}
+		g.Expect(speakerHostPorts(g, objs)).ToNot(ContainElement(int64(9120)))
+	}
+}
+
+func TestRenderRBACProxyWithoutImage(t *testing.T) {
+	g := NewGomegaWithT(t)
+	defer setEnv("KUBE_RBAC_PROXY_IMAGE", "")()
+
+	r := &MetalLBReconciler{Namespace: MetalLBTestNameSpace, PlatformInfo: platform.PlatformInfo{Name: platform.OpenShift}}
+	_, err := r.renderMetalLBObjects(testMetalLB(metallbv1beta1.MetalLBSpec{}))
+	g.Expect(err).To(MatchError(ContainSubstring("KUBE_RBAC_PROXY_IMAGE")))
+}
+
+func metricsServices(g *WithT, objs []*uns.Unstructured) []*corev1.Service {
+	services := []*corev1.Service{}
+	for _, obj := range objs {
+		if obj.GetKind() != "Service" {
+			continue
+		}
+		svc := &corev1.Service{}
+		g.Expect(runtime.DefaultUnstructuredConverter.FromUnstructured(obj.Object, svc)).To(Succeed())
+		services = append(services, svc)
+	}
+	g.Expect(services).To(HaveLen(2))
+	return services
+}
+
+func speakerHostPorts(g *WithT, objs []*uns.Unstructured) []int64 {
+	ports := []int64{}
+	for _, obj := range objs {
+		if obj.GetKind() != "PodSecurityPolicy" || obj.GetName() != "speaker" {
+			continue
+		}
+		ranges, _, err := uns.NestedSlice(obj.Object, "spec", "hostPorts")
+		g.Expect(err).ToNot(HaveOccurred())
+		for _, r := range ranges {
+			min, _, _ := uns.NestedInt64(r.(map[string]interface{}), "min")
+			ports = append(ports, min)
+		}
+	}
+	return ports
+}
+
+// setEnv sets an environment variable and returns a function restoring it.
+func setEnv(name, value string) func() {
+	previous, ok := os.LookupEnv(name)
+	os.Setenv(name, value)
+	return func() {
+		if ok {
+			os.Setenv(name, previous)
+		} else {
+			os.Unsetenv(name)
+		}
+	}
+}
+
+func TestRenderPrometheusRule(t *testing.T) {
+	g := NewGomegaWithT(t)
+	manifestPath := ManifestPath
+	ManifestPath = "../bindata/deployment"
+	defer func() { ManifestPath = manifestPath }()
+
+	render := func(enabled *bool) *uns.Unstructured {
+		r := &MetalLBReconciler{Namespace: MetalLBTestNameSpace, PlatformInfo: platform.PlatformInfo{Name: platform.Kubernetes}}
+		objs, err := r.renderMetalLBObjects(&metallbv1beta1.MetalLB{
+			ObjectMeta: metav1.ObjectMeta{Name: defaultMetalLBCrName, Namespace: MetalLBTestNameSpace},
+			Spec:       metallbv1beta1.MetalLBSpec{EnablePrometheusRules: enabled},
+		})
+		g.Expect(err).ToNot(HaveOccurred())
+		for _, obj := range objs {
+			if obj.GetKind() == "PrometheusRule" {
+				return obj
+			}
+		}
+		return nil
+	}
+
+	disabled := false
+	g.Expect(render(nil)).To(BeNil())
+	g.Expect(render(&disabled)).To(BeNil())
+
+	enabled := true
+	rule := render(&enabled)
+	g.Expect(rule).ToNot(BeNil())
+	g.Expect(rule.GetAPIVersion()).To(Equal("monitoring.coreos.com/v1"))
+	g.Expect(rule.GetNamespace()).To(Equal(MetalLBTestNameSpace))
+
+	groups, _, err := uns.NestedSlice(rule.Object, "spec", "groups")
+	g.Expect(err).ToNot(HaveOccurred())
+	names := []string{}
+	alerts := map[string]string{}
+	for _, group := range groups {
+		group := group.(map[string]interface{})
+		names = append(names, group["name"].(string))
+		rules, _, err := uns.NestedSlice(group, "rules")
+		g.Expect(err).ToNot(HaveOccurred())
+		for _, rule := range rules {
+			rule := rule.(map[string]interface{})
+			alerts[rule["alert"].(string)] = rule["expr"].(string)
+		}
+	}
+	g.Expect(names).To(Equal([]string{"metallb.rules", "metallb-pools.rules"}))
+	g.Expect(alerts).To(HaveKey("MetalLBSpeakerDown"))
+	g.Expect(alerts).To(HaveKey("MetalLBConfigStale"))
+	g.Expect(alerts["MetalLBSpeakerDown"]).To(ContainSubstring(`namespace="` + MetalLBTestNameSpace + `"`))
+	g.Expect(alerts["MetalLBAddressPoolNearExhaustion"]).To(ContainSubstring("metallb_pool_used_addresses"))
+	g.Expect(alerts["MetalLBAddressPoolNearExhaustion"]).To(ContainSubstring("metallb_pool_total_addresses"))
+}
+
+func TestRenderFakePlatform(t *testing.T) {
+	g := NewGomegaWithT(t)
+	defer setEnv("KUBE_RBAC_PROXY_IMAGE", "kube-rbac-proxy:test")()
+
+	// The kube-rbac-proxy and the serving certificates default to OpenShift
+	for _, c := range []struct {
+		platform   *fake.PlatformInfo
+		containers int
+		annotated  bool
+	}{
+		{fake.NewKubernetes(), 1, false},
+		{fake.NewOpenShift(), 2, true},
+	} {
+		objs := renderPlatformTestObjects(g, metallbv1beta1.MetalLBSpec{}, c.platform)
+		g.Expect(c.platform.Checks).ToNot(BeZero())
+
+		speaker, controller := speakerAndController(g, objs)
+		g.Expect(speaker.Spec.Template.Spec.Containers).To(HaveLen(c.containers))
+		g.Expect(controller.Spec.Template.Spec.Containers).To(HaveLen(c.containers))
+		for _, svc := range metricsServices(g, objs) {
+			_, annotated := svc.Annotations["service.beta.openshift.io/serving-cert-secret-name"]
+			g.Expect(annotated).To(Equal(c.annotated))
+		}
+	}
+}
+
+func TestRenderServiceMonitors(t *testing.T) {
+	g := NewGomegaWithT(t)
+	defer setEnv("KUBE_RBAC_PROXY_IMAGE", "kube-rbac-proxy:test")()
+
+	manifestPath := ManifestPath
+	ManifestPath = "../bindata/deployment"
+	defer func() { ManifestPath = manifestPath }()
+
+	enabled, disabled := true, false
+	for _, c := range []struct {
+		platform      platform.PlatformInfo
+		enableMetrics *bool
+		expected      bool
+	}{
+		// Enabled by default on OpenShift only
+		{platform.PlatformInfo{Name: platform.OpenShift, ServiceMonitors: true}, nil, true},
+		{platform.PlatformInfo{Name: platform.Kubernetes, ServiceMonitors: true}, nil, false},
+		{platform.PlatformInfo{Name: platform.OpenShift, ServiceMonitors: true}, &disabled, false},
+		{platform.PlatformInfo{Name: platform.Kubernetes, ServiceMonitors: true}, &enabled, true},
+		// Skipped without the Prometheus Operator API
+		{platform.PlatformInfo{Name: platform.OpenShift}, nil, false},
+		{platform.PlatformInfo{Name: platform.Kubernetes}, &enabled, false},
+	} {
+		// The ServiceMonitor is not a kind of the schema renderPlatformTestObjects validates against
+		r := &MetalLBReconciler{Namespace: MetalLBTestNameSpace, PlatformInfo: c.platform}
+		objs, err := r.renderMetalLBObjects(&metallbv1beta1.MetalLB{
+			ObjectMeta: metav1.ObjectMeta{Name: defaultMetalLBCrName, Namespace: MetalLBTestNameSpace},
+			Spec:       metallbv1beta1.MetalLBSpec{EnableMetrics: c.enableMetrics},
+		})
+		g.Expect(err).ToNot(HaveOccurred())
+		monitors := map[string]*uns.Unstructured{}
+		for _, obj := range objs {
+			if obj.GetKind() == "ServiceMonitor" {
+				monitors[obj.GetName()] = obj
+			}
+		}
+		if !c.expected {
+			g.Expect(monitors).To(BeEmpty(), c.platform.String())
+			continue
+		}
+		g.Expect(monitors).To(HaveLen(2), c.platform.String())
+
+		for _, component := range []string{"speaker", "controller"} {
+			monitor := monitors[component+"-monitor"]
+			g.Expect(monitor).ToNot(BeNil())
+			g.Expect(monitor.GetAPIVersion()).To(Equal("monitoring.coreos.com/v1"))
+			g.Expect(monitor.GetNamespace()).To(Equal(MetalLBTestNameSpace))
+			selector, _, err := uns.NestedStringMap(monitor.Object, "spec", "selector", "matchLabels")
+			g.Expect(err).ToNot(HaveOccurred())
+			g.Expect(selector).To(Equal(map[string]string{"app": "metallb", "component": component}))
+
+			endpoints, _, err := uns.NestedSlice(monitor.Object, "spec", "endpoints")
+			g.Expect(err).ToNot(HaveOccurred())
+			g.Expect(endpoints).To(HaveLen(1))
+			endpoint := endpoints[0].(map[string]interface{})
+			if c.platform.IsOpenShift() {
+				// Scraped through the kube-rbac-proxy, with the service CA
+				g.Expect(endpoint["port"]).To(Equal("metricshttps"))
+				g.Expect(endpoint["scheme"]).To(Equal("https"))
+				serverName, _, _ := uns.NestedString(endpoint, "tlsConfig", "serverName")
+				g.Expect(serverName).To(Equal(component + "-monitor-service." + MetalLBTestNameSpace + ".svc"))
+			} else {
+				g.Expect(endpoint["port"]).To(Equal("monitoring"))
+			}
+		}
+	}
+}
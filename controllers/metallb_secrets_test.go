@@ -0,0 +1,87 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	metallbv1beta1 "github.com/metallb/metallb-operator/api/v1beta1"
+	"github.com/metallb/metallb-operator/pkg/status"
+)
+
+func TestSecretMetalLB(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	metallb := testMetalLB(metallbv1beta1.MetalLBSpec{MetricsTLSSecret: &corev1.LocalObjectReference{Name: "metrics-tls"}})
+	r := &MetalLBReconciler{
+		Client:    newFakeClientBuilder().WithScheme(testScheme(g)).WithObjects(metallb).Build(),
+		Log:       ctrl.Log.WithName("controllers").WithName("MetalLB"),
+		Namespace: MetalLBTestNameSpace,
+	}
+	request := reconcile.Request{NamespacedName: types.NamespacedName{Name: defaultMetalLBCrName, Namespace: MetalLBTestNameSpace}}
+	secret := func(name, namespace string) *corev1.Secret {
+		return &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace}}
+	}
+
+	g.Expect(r.secretMetalLB(secret("memberlist", MetalLBTestNameSpace))).To(ConsistOf(request))
+	g.Expect(r.secretMetalLB(secret("metrics-tls", MetalLBTestNameSpace))).To(ConsistOf(request))
+	g.Expect(r.secretMetalLB(secret("unrelated", MetalLBTestNameSpace))).To(BeEmpty())
+	g.Expect(r.secretMetalLB(secret("memberlist", "default"))).To(BeEmpty())
+
+	// Without a MetalLB resource, only the memberlist secret is referenced
+	r.Client = newFakeClientBuilder().WithScheme(testScheme(g)).Build()
+	g.Expect(r.secretMetalLB(secret("memberlist", MetalLBTestNameSpace))).To(ConsistOf(request))
+	g.Expect(r.secretMetalLB(secret("metrics-tls", MetalLBTestNameSpace))).To(BeEmpty())
+}
+
+func TestMetalLBSecretRotation(t *testing.T) {
+	g := NewGomegaWithT(t)
+	defer setEnv("KUBE_RBAC_PROXY_IMAGE", "kube-rbac-proxy:test")()
+
+	metallb := testMetalLB(metallbv1beta1.MetalLBSpec{MetricsTLSSecret: &corev1.LocalObjectReference{Name: "metrics-tls"}})
+	memberlist := memberlistTestSecret(map[string][]byte{"secretkey": []byte("q2BmSMtzSgP8cBKUjNOn0AXEB5iqwEV8")})
+	metricsTLS := metricsTLSTestSecret(map[string][]byte{"tls.crt": []byte("cert"), "tls.key": []byte("key")})
+	c := newFakeClientBuilder().WithScheme(testScheme(g)).WithObjects(append(readyWorkloads(), metallb, memberlist, metricsTLS)...).Build()
+	r := &MetalLBReconciler{Client: c, Log: ctrl.Log.WithName("controllers").WithName("MetalLB"), Namespace: MetalLBTestNameSpace}
+	ctx := context.Background()
+	degradedReason := func(conditions []metav1.Condition) string {
+		degraded := meta.FindStatusCondition(conditions, status.ConditionDegraded)
+		g.Expect(degraded).ToNot(BeNil())
+		if degraded.Status != metav1.ConditionTrue {
+			return ""
+		}
+		return degraded.Reason
+	}
+	// rotate updates the secret and reconciles the requests it is mapped to,
+	// as the Secret watch does.
+	rotate := func(secret *corev1.Secret, data map[string][]byte) []metav1.Condition {
+		updated := &corev1.Secret{}
+		g.Expect(c.Get(ctx, types.NamespacedName{Name: secret.Name, Namespace: secret.Namespace}, updated)).To(Succeed())
+		updated.Data = data
+		g.Expect(c.Update(ctx, updated)).To(Succeed())
+		requests := r.secretMetalLB(updated)
+		g.Expect(requests).To(HaveLen(1))
+		g.Expect(requests[0].Name).To(Equal(defaultMetalLBCrName))
+		return reconcileTestMetalLB(g, c)
+	}
+
+	g.Expect(degradedReason(reconcileTestMetalLB(g, c))).To(BeEmpty())
+
+	// A memberlist key rotated to a short one, then back to a valid one
+	g.Expect(degradedReason(rotate(memberlist, map[string][]byte{"secretkey": []byte("secret")}))).To(Equal("InvalidMemberlistSecret"))
+	g.Expect(degradedReason(rotate(memberlist, map[string][]byte{"secretkey": []byte("Vn7bKqXhmJRbsdL2Y0B1Q3bB9aT8x1eI")}))).To(BeEmpty())
+
+	// A metrics certificate rotated without its key
+	conditions := rotate(metricsTLS, map[string][]byte{"tls.crt": []byte("rotated")})
+	g.Expect(meta.IsStatusConditionFalse(conditions, status.ConditionConfigValid)).To(BeTrue())
+	g.Expect(meta.FindStatusCondition(conditions, status.ConditionConfigValid).Message).To(ContainSubstring("has no tls.key key"))
+	conditions = rotate(metricsTLS, map[string][]byte{"tls.crt": []byte("rotated"), "tls.key": []byte("rotated")})
+	g.Expect(meta.IsStatusConditionTrue(conditions, status.ConditionConfigValid)).To(BeTrue())
+}
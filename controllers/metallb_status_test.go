@@ -0,0 +1,299 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+	appsv1 "k8s.io/api/apps/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	uns "k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	metallbv1beta1 "github.com/metallb/metallb-operator/api/v1beta1"
+	"github.com/metallb/metallb-operator/pkg/status"
+)
+
+func TestMetalLBInvalidConfigHealthyWorkloads(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	metallb := testMetalLB(metallbv1beta1.MetalLBSpec{SpeakerServiceAccountName: "Not_A_Valid_Name"})
+	c := newFakeClientBuilder().WithScheme(testScheme(g)).WithObjects(append(readyWorkloads(), metallb)...).Build()
+
+	conditions := reconcileTestMetalLB(g, c)
+	g.Expect(meta.IsStatusConditionFalse(conditions, status.ConditionConfigValid)).To(BeTrue())
+	g.Expect(meta.FindStatusCondition(conditions, status.ConditionConfigValid).Message).To(ContainSubstring("speakerServiceAccountName"))
+	g.Expect(meta.IsStatusConditionTrue(conditions, status.ConditionAvailable)).To(BeTrue())
+	g.Expect(meta.IsStatusConditionTrue(conditions, status.ConditionDegraded)).To(BeFalse())
+}
+
+func TestMetalLBValidConfigDegradedWorkloads(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	metallb := testMetalLB(metallbv1beta1.MetalLBSpec{})
+	c := forbiddenClient{
+		Client:   newFakeClientBuilder().WithScheme(testScheme(g)).WithObjects(metallb).Build(),
+		kind:     "DaemonSet",
+		resource: "daemonsets",
+	}
+
+	conditions := reconcileTestMetalLB(g, c)
+	g.Expect(meta.IsStatusConditionTrue(conditions, status.ConditionConfigValid)).To(BeTrue())
+	g.Expect(meta.IsStatusConditionTrue(conditions, status.ConditionDegraded)).To(BeTrue())
+	g.Expect(meta.IsStatusConditionTrue(conditions, status.ConditionAvailable)).To(BeFalse())
+}
+
+func testMetalLB(spec metallbv1beta1.MetalLBSpec) *metallbv1beta1.MetalLB {
+	return &metallbv1beta1.MetalLB{
+		ObjectMeta: metav1.ObjectMeta{Name: defaultMetalLBCrName, Namespace: MetalLBTestNameSpace},
+		Spec:       spec,
+	}
+}
+
+// readyWorkloads returns a speaker and a controller reporting all their pods as ready.
+func readyWorkloads() []client.Object {
+	replicas := int32(1)
+	return []client.Object{
+		&appsv1.DaemonSet{
+			ObjectMeta: metav1.ObjectMeta{Name: "speaker", Namespace: MetalLBTestNameSpace},
+			Status:     appsv1.DaemonSetStatus{DesiredNumberScheduled: 2, CurrentNumberScheduled: 2, NumberReady: 2},
+		},
+		&appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{Name: "controller", Namespace: MetalLBTestNameSpace},
+			Spec:       appsv1.DeploymentSpec{Replicas: &replicas},
+			Status:     appsv1.DeploymentStatus{ReadyReplicas: 1},
+		},
+	}
+}
+
+// reconcileTestMetalLB reconciles the MetalLB resource and returns its conditions.
+func reconcileTestMetalLB(g *WithT, c client.Client) []metav1.Condition {
+	manifestPath := ManifestPath
+	ManifestPath = "../bindata/deployment"
+	defer func() { ManifestPath = manifestPath }()
+
+	reconciler := &MetalLBReconciler{
+		Client:    c,
+		Scheme:    testScheme(g),
+		Log:       ctrl.Log.WithName("controllers").WithName("MetalLB"),
+		Namespace: MetalLBTestNameSpace,
+	}
+	key := types.NamespacedName{Name: defaultMetalLBCrName, Namespace: MetalLBTestNameSpace}
+	_, _ = reconciler.Reconcile(context.Background(), ctrl.Request{NamespacedName: key})
+
+	metallb := &metallbv1beta1.MetalLB{}
+	g.Expect(c.Get(context.Background(), key, metallb)).To(Succeed())
+	return metallb.Status.Conditions
+}
+
+// statusKeepingClient keeps the status of the updated objects, as the API
+// server does for the resources with a status subresource.
+type statusKeepingClient struct {
+	client.Client
+}
+
+func (c statusKeepingClient) Update(ctx context.Context, obj client.Object, opts ...client.UpdateOption) error {
+	if u, ok := obj.(*uns.Unstructured); ok {
+		existing := &uns.Unstructured{}
+		existing.SetGroupVersionKind(u.GroupVersionKind())
+		if err := c.Get(ctx, client.ObjectKeyFromObject(u), existing); err == nil && existing.Object["status"] != nil {
+			u.Object["status"] = existing.Object["status"]
+		}
+	}
+	return c.Client.Update(ctx, obj, opts...)
+}
+
+func TestMetalLBProgressing(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	metallb := testMetalLB(metallbv1beta1.MetalLBSpec{})
+	workloads := readyWorkloads()
+	speaker := workloads[0].(*appsv1.DaemonSet)
+	speaker.Status.NumberReady = 1
+	c := statusKeepingClient{newFakeClientBuilder().WithScheme(testScheme(g)).WithObjects(append(workloads, metallb)...).Build()}
+
+	// The speakers are scheduled but not all ready yet
+	conditions := reconcileTestMetalLB(g, c)
+	progressing := meta.FindStatusCondition(conditions, status.ConditionProgressing)
+	g.Expect(progressing).ToNot(BeNil())
+	g.Expect(progressing.Status).To(Equal(metav1.ConditionTrue))
+	g.Expect(progressing.Reason).To(Equal("MetalLBResourcesNotReady"))
+	g.Expect(progressing.Message).To(Equal("MetalLB speaker daemonset not ready, 1 of 2 pods ready"))
+	g.Expect(meta.IsStatusConditionTrue(conditions, status.ConditionAvailable)).To(BeFalse())
+	g.Expect(meta.IsStatusConditionTrue(conditions, status.ConditionDegraded)).To(BeFalse())
+
+	updated := &metallbv1beta1.MetalLB{}
+	g.Expect(c.Get(context.Background(), types.NamespacedName{Name: defaultMetalLBCrName, Namespace: MetalLBTestNameSpace}, updated)).To(Succeed())
+	g.Expect(updated.Status.LastError).To(BeEmpty())
+
+	// The rollout completes
+	g.Expect(c.Get(context.Background(), types.NamespacedName{Name: "speaker", Namespace: MetalLBTestNameSpace}, speaker)).To(Succeed())
+	speaker.Status.NumberReady = 2
+	g.Expect(c.Status().Update(context.Background(), speaker)).To(Succeed())
+	conditions = reconcileTestMetalLB(g, c)
+	g.Expect(meta.IsStatusConditionTrue(conditions, status.ConditionAvailable)).To(BeTrue())
+	g.Expect(meta.IsStatusConditionTrue(conditions, status.ConditionProgressing)).To(BeFalse())
+}
+
+func TestMetalLBConfigOnly(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	manageWorkloads := false
+	metallb := testMetalLB(metallbv1beta1.MetalLBSpec{ManageWorkloads: &manageWorkloads})
+	// A speaker deployed by other means, which is not ready
+	speaker := &appsv1.DaemonSet{
+		ObjectMeta: metav1.ObjectMeta{Name: "speaker", Namespace: MetalLBTestNameSpace, Labels: map[string]string{"app": "helm"}},
+	}
+	c := newFakeClientBuilder().WithScheme(testScheme(g)).WithObjects(metallb, speaker).Build()
+
+	conditions := reconcileTestMetalLB(g, c)
+	available := meta.FindStatusCondition(conditions, status.ConditionAvailable)
+	g.Expect(available).ToNot(BeNil())
+	g.Expect(available.Status).To(Equal(metav1.ConditionTrue))
+	g.Expect(available.Reason).To(Equal("WorkloadsNotManaged"))
+	g.Expect(meta.IsStatusConditionTrue(conditions, status.ConditionConfigValid)).To(BeTrue())
+	g.Expect(meta.IsStatusConditionTrue(conditions, status.ConditionDegraded)).To(BeFalse())
+
+	// The workloads are neither created nor updated
+	g.Expect(c.Get(context.Background(), types.NamespacedName{Name: "speaker", Namespace: MetalLBTestNameSpace}, speaker)).To(Succeed())
+	g.Expect(speaker.Labels).To(Equal(map[string]string{"app": "helm"}))
+	g.Expect(speaker.Spec.Template.Spec.Containers).To(BeEmpty())
+	err := c.Get(context.Background(), types.NamespacedName{Name: "controller", Namespace: MetalLBTestNameSpace}, &appsv1.Deployment{})
+	g.Expect(apierrors.IsNotFound(err)).To(BeTrue(), "%v", err)
+}
+
+// nodeNameStrippingClient removes the node name env from the speaker
+// DaemonSet, as a misbehaving admission webhook would.
+type nodeNameStrippingClient struct {
+	client.Client
+}
+
+func (c nodeNameStrippingClient) Create(ctx context.Context, obj client.Object, opts ...client.CreateOption) error {
+	c.strip(obj)
+	return c.Client.Create(ctx, obj, opts...)
+}
+
+func (c nodeNameStrippingClient) Update(ctx context.Context, obj client.Object, opts ...client.UpdateOption) error {
+	c.strip(obj)
+	return c.Client.Update(ctx, obj, opts...)
+}
+
+func (c nodeNameStrippingClient) strip(obj client.Object) {
+	u, ok := obj.(*uns.Unstructured)
+	if !ok || u.GetKind() != "DaemonSet" {
+		return
+	}
+	containers, _, _ := uns.NestedSlice(u.Object, "spec", "template", "spec", "containers")
+	for _, c := range containers {
+		container := c.(map[string]interface{})
+		env, _, _ := uns.NestedSlice(container, "env")
+		kept := []interface{}{}
+		for _, e := range env {
+			if e.(map[string]interface{})["name"] != speakerNodeNameEnv {
+				kept = append(kept, e)
+			}
+		}
+		container["env"] = kept
+	}
+	_ = uns.SetNestedSlice(u.Object, containers, "spec", "template", "spec", "containers")
+}
+
+func TestMetalLBSpeakerNodeNameStripped(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	metallb := testMetalLB(metallbv1beta1.MetalLBSpec{})
+	c := nodeNameStrippingClient{newFakeClientBuilder().WithScheme(testScheme(g)).WithObjects(metallb).Build()}
+
+	conditions := reconcileTestMetalLB(g, c)
+	degraded := meta.FindStatusCondition(conditions, status.ConditionDegraded)
+	g.Expect(degraded).ToNot(BeNil())
+	g.Expect(degraded.Status).To(Equal(metav1.ConditionTrue))
+	g.Expect(degraded.Message).To(ContainSubstring(speakerNodeNameEnv))
+}
+
+func TestMetalLBLastError(t *testing.T) {
+	g := NewGomegaWithT(t)
+	manifestPath := ManifestPath
+	ManifestPath = "../bindata/deployment"
+	defer func() { ManifestPath = manifestPath }()
+
+	metallb := testMetalLB(metallbv1beta1.MetalLBSpec{})
+	c := newFakeClientBuilder().WithScheme(testScheme(g)).WithObjects(append(readyWorkloads(), metallb)...).Build()
+	reconciler := &MetalLBReconciler{
+		Client:    forbiddenClient{Client: c, kind: "DaemonSet", resource: "daemonsets"},
+		Scheme:    testScheme(g),
+		Log:       ctrl.Log.WithName("controllers").WithName("MetalLB"),
+		Namespace: MetalLBTestNameSpace,
+	}
+	key := types.NamespacedName{Name: defaultMetalLBCrName, Namespace: MetalLBTestNameSpace}
+
+	_, err := reconciler.Reconcile(context.Background(), ctrl.Request{NamespacedName: key})
+	g.Expect(err).To(HaveOccurred())
+	g.Expect(c.Get(context.Background(), key, metallb)).To(Succeed())
+	g.Expect(metallb.Status.LastError).To(Equal(err.Error()))
+	g.Expect(metallb.Status.LastError).To(ContainSubstring("daemonsets"))
+	g.Expect(metallb.Status.LastErrorTime.IsZero()).To(BeFalse())
+
+	// The same error is not written again, which would trigger another reconcile
+	failed := metallb.DeepCopy()
+	_, err = reconciler.Reconcile(context.Background(), ctrl.Request{NamespacedName: key})
+	g.Expect(err).To(HaveOccurred())
+	g.Expect(c.Get(context.Background(), key, metallb)).To(Succeed())
+	g.Expect(metallb.ResourceVersion).To(Equal(failed.ResourceVersion))
+	g.Expect(metallb.Status.LastErrorTime).To(Equal(failed.Status.LastErrorTime))
+
+	// The DaemonSet can be applied again
+	reconciler.Client = c
+	_, err = reconciler.Reconcile(context.Background(), ctrl.Request{NamespacedName: key})
+	g.Expect(err).ToNot(HaveOccurred())
+	metallb = &metallbv1beta1.MetalLB{}
+	g.Expect(c.Get(context.Background(), key, metallb)).To(Succeed())
+	g.Expect(metallb.Status.LastError).To(BeEmpty())
+	g.Expect(metallb.Status.LastErrorTime.IsZero()).To(BeTrue())
+}
+
+func TestMetalLBDegradedThreshold(t *testing.T) {
+	g := NewGomegaWithT(t)
+	manifestPath := ManifestPath
+	ManifestPath = "../bindata/deployment"
+	defer func() { ManifestPath = manifestPath }()
+
+	metallb := testMetalLB(metallbv1beta1.MetalLBSpec{DegradedThreshold: metav1.Duration{Duration: time.Hour}})
+	c := newFakeClientBuilder().WithScheme(testScheme(g)).WithObjects(append(readyWorkloads(), metallb)...).Build()
+	reconciler := &MetalLBReconciler{
+		Client:    forbiddenClient{Client: c, kind: "DaemonSet", resource: "daemonsets"},
+		Scheme:    testScheme(g),
+		Log:       ctrl.Log.WithName("controllers").WithName("MetalLB"),
+		Namespace: MetalLBTestNameSpace,
+	}
+	key := types.NamespacedName{Name: defaultMetalLBCrName, Namespace: MetalLBTestNameSpace}
+	reconcile := func() *metallbv1beta1.MetalLB {
+		_, _ = reconciler.Reconcile(context.Background(), ctrl.Request{NamespacedName: key})
+		metallb := &metallbv1beta1.MetalLB{}
+		g.Expect(c.Get(context.Background(), key, metallb)).To(Succeed())
+		return metallb
+	}
+
+	// Unhealthy for less than the threshold
+	metallb = reconcile()
+	g.Expect(metallb.Status.UnhealthySince.IsZero()).To(BeFalse())
+	g.Expect(meta.IsStatusConditionTrue(metallb.Status.Conditions, status.ConditionProgressing)).To(BeTrue())
+	g.Expect(meta.IsStatusConditionTrue(metallb.Status.Conditions, status.ConditionDegraded)).To(BeFalse())
+
+	// Unhealthy for longer than the threshold
+	metallb.Status.UnhealthySince = metav1.NewTime(time.Now().Add(-2 * time.Hour))
+	g.Expect(c.Status().Update(context.Background(), metallb)).To(Succeed())
+	metallb = reconcile()
+	g.Expect(meta.IsStatusConditionTrue(metallb.Status.Conditions, status.ConditionDegraded)).To(BeTrue())
+
+	// Healthy again
+	reconciler.Client = c
+	metallb = reconcile()
+	g.Expect(metallb.Status.UnhealthySince.IsZero()).To(BeTrue())
+	g.Expect(meta.IsStatusConditionTrue(metallb.Status.Conditions, status.ConditionDegraded)).To(BeFalse())
+}
@@ -0,0 +1,101 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+
+	metallbv1beta1 "github.com/metallb/metallb-operator/api/v1beta1"
+)
+
+// safeSysctls are the sysctls the kubelet allows by default.
+var safeSysctls = map[string]bool{
+	"kernel.shm_rmid_forced":       true,
+	"net.ipv4.ip_local_port_range": true,
+	"net.ipv4.tcp_syncookies":      true,
+	"net.ipv4.ping_group_range":    true,
+}
+
+// sysctlNameRegexp matches the sysctl names accepted by the API server.
+var sysctlNameRegexp = regexp.MustCompile(`^([a-z0-9]([-_a-z0-9]*[a-z0-9])?[\./])*[a-z0-9]([-_a-z0-9]*[a-z0-9])?$`)
+
+const sysctlMaxLength = 253
+
+// validateSpeakerSysctls checks the kubelet can set the sysctls in the speaker
+// pods. It rejects the sysctls that are not namespaced, as they would apply to
+// the whole node, and the network ones, as the speakers share the network
+// namespace of the node. The unsafe sysctls must be listed in the
+// UnsafeSysctlsAnnotation of the MetalLB resource.
+func validateSpeakerSysctls(config *metallbv1beta1.MetalLB) error {
+	allowedUnsafe := map[string]bool{}
+	for _, name := range strings.Split(config.Annotations[metallbv1beta1.UnsafeSysctlsAnnotation], ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			allowedUnsafe[name] = true
+		}
+	}
+
+	seen := map[string]bool{}
+	for _, sysctl := range config.Spec.SpeakerSysctls {
+		if len(sysctl.Name) > sysctlMaxLength || !sysctlNameRegexp.MatchString(sysctl.Name) {
+			return errors.Errorf("invalid speakerSysctls name %q", sysctl.Name)
+		}
+		if seen[sysctl.Name] {
+			return errors.Errorf("duplicate speakerSysctls name %q", sysctl.Name)
+		}
+		seen[sysctl.Name] = true
+
+		if strings.HasPrefix(sysctl.Name, "net.") {
+			return errors.Errorf("invalid speakerSysctls name %q, network sysctls can't be set as the speaker runs with host networking", sysctl.Name)
+		}
+		if !isIPCSysctl(sysctl.Name) {
+			return errors.Errorf("invalid speakerSysctls name %q, only namespaced sysctls can be set", sysctl.Name)
+		}
+		if !safeSysctls[sysctl.Name] && !allowedUnsafe[sysctl.Name] {
+			return errors.Errorf("unsafe speakerSysctls name %q must be listed in the %s annotation", sysctl.Name, metallbv1beta1.UnsafeSysctlsAnnotation)
+		}
+	}
+	return nil
+}
+
+// isIPCSysctl tells whether the sysctl belongs to the IPC namespace.
+func isIPCSysctl(name string) bool {
+	if name == "kernel.sem" {
+		return true
+	}
+	for _, prefix := range []string{"kernel.shm", "kernel.msg", "fs.mqueue."} {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// unsafeSysctlNames returns the names of the unsafe sysctls, which the speaker
+// PodSecurityPolicy must allow.
+func unsafeSysctlNames(sysctls []corev1.Sysctl) []string {
+	names := []string{}
+	for _, sysctl := range sysctls {
+		if !safeSysctls[sysctl.Name] {
+			names = append(names, sysctl.Name)
+		}
+	}
+	return names
+}
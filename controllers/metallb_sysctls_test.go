@@ -0,0 +1,119 @@
+package controllers
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	uns "k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	metallbv1beta1 "github.com/metallb/metallb-operator/api/v1beta1"
+	"github.com/metallb/metallb-operator/test/manifests"
+)
+
+func TestRenderSpeakerSysctls(t *testing.T) {
+	g := NewGomegaWithT(t)
+	manifestPath := ManifestPath
+	ManifestPath = "../bindata/deployment"
+	defer func() { ManifestPath = manifestPath }()
+
+	sysctls := []corev1.Sysctl{
+		{Name: "kernel.shm_rmid_forced", Value: "1"},
+		{Name: "kernel.msgmax", Value: "65536"},
+	}
+	metallb := testMetalLB(metallbv1beta1.MetalLBSpec{SpeakerSysctls: sysctls})
+	metallb.Annotations = map[string]string{metallbv1beta1.UnsafeSysctlsAnnotation: "kernel.msgmax"}
+	r := &MetalLBReconciler{Namespace: MetalLBTestNameSpace}
+	objs, err := r.renderMetalLBObjects(metallb)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(manifests.Validate(objs)).To(BeEmpty())
+
+	speaker, controller := speakerAndController(g, objs)
+	g.Expect(speaker.Spec.Template.Spec.SecurityContext.Sysctls).To(Equal(sysctls))
+	if controller.Spec.Template.Spec.SecurityContext != nil {
+		g.Expect(controller.Spec.Template.Spec.SecurityContext.Sysctls).To(BeEmpty())
+	}
+
+	for _, obj := range objs {
+		if obj.GetKind() != "PodSecurityPolicy" {
+			continue
+		}
+		allowed, _, err := uns.NestedStringSlice(obj.Object, "spec", "allowedUnsafeSysctls")
+		g.Expect(err).ToNot(HaveOccurred())
+		if obj.GetName() == "speaker" {
+			g.Expect(allowed).To(Equal([]string{"kernel.msgmax"}))
+		} else {
+			g.Expect(allowed).To(BeEmpty())
+		}
+	}
+}
+
+func TestValidateSpeakerSysctls(t *testing.T) {
+	tests := []struct {
+		desc          string
+		sysctls       []corev1.Sysctl
+		allowedUnsafe string
+		err           string
+	}{
+		{
+			desc:    "safe sysctl",
+			sysctls: []corev1.Sysctl{{Name: "kernel.shm_rmid_forced", Value: "1"}},
+		},
+		{
+			desc:          "allowed unsafe sysctl",
+			sysctls:       []corev1.Sysctl{{Name: "kernel.msgmax", Value: "65536"}},
+			allowedUnsafe: "kernel.msgmnb, kernel.msgmax",
+		},
+		{
+			desc:    "unsafe sysctl without the annotation",
+			sysctls: []corev1.Sysctl{{Name: "kernel.msgmax", Value: "65536"}},
+			err:     metallbv1beta1.UnsafeSysctlsAnnotation,
+		},
+		{
+			desc:          "unsafe sysctl not in the annotation",
+			sysctls:       []corev1.Sysctl{{Name: "kernel.msgmax", Value: "65536"}},
+			allowedUnsafe: "kernel.msgmnb",
+			err:           metallbv1beta1.UnsafeSysctlsAnnotation,
+		},
+		{
+			desc:          "network sysctl",
+			sysctls:       []corev1.Sysctl{{Name: "net.ipv4.ip_forward", Value: "1"}},
+			allowedUnsafe: "net.ipv4.ip_forward",
+			err:           "host networking",
+		},
+		{
+			desc:          "node wide sysctl",
+			sysctls:       []corev1.Sysctl{{Name: "vm.swappiness", Value: "10"}},
+			allowedUnsafe: "vm.swappiness",
+			err:           "only namespaced sysctls",
+		},
+		{
+			desc:    "invalid name",
+			sysctls: []corev1.Sysctl{{Name: "kernel..shm_rmid_forced", Value: "1"}},
+			err:     "invalid speakerSysctls name",
+		},
+		{
+			desc: "duplicate name",
+			sysctls: []corev1.Sysctl{
+				{Name: "kernel.shm_rmid_forced", Value: "1"},
+				{Name: "kernel.shm_rmid_forced", Value: "0"},
+			},
+			err: "duplicate",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			g := NewGomegaWithT(t)
+			metallb := testMetalLB(metallbv1beta1.MetalLBSpec{SpeakerSysctls: test.sysctls})
+			if test.allowedUnsafe != "" {
+				metallb.Annotations = map[string]string{metallbv1beta1.UnsafeSysctlsAnnotation: test.allowedUnsafe}
+			}
+			err := validateSpeakerSysctls(metallb)
+			if test.err == "" {
+				g.Expect(err).ToNot(HaveOccurred())
+				return
+			}
+			g.Expect(err).To(MatchError(ContainSubstring(test.err)))
+		})
+	}
+}
@@ -0,0 +1,61 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"strings"
+
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/util/version"
+)
+
+// checkMetalLBVersion returns an error when the tag of one of the images is a
+// version older than minVersion. Images whose tag is not a version, such as
+// latest or a digest, are accepted as their version can't be told.
+func checkMetalLBVersion(minVersion string, images ...string) error {
+	if minVersion == "" {
+		return nil
+	}
+	min, err := version.ParseGeneric(minVersion)
+	if err != nil {
+		return errors.Wrapf(err, "invalid minMetalLBVersion %q", minVersion)
+	}
+	for _, image := range images {
+		v := imageVersion(image)
+		if v != nil && v.LessThan(min) {
+			return errors.Errorf("image %s is older than the minimum MetalLB version %s", image, minVersion)
+		}
+	}
+	return nil
+}
+
+// imageVersion returns the version of the image tag, or nil when the image
+// has no tag or its tag is not a version.
+func imageVersion(image string) *version.Version {
+	if i := strings.Index(image, "@"); i >= 0 {
+		image = image[:i]
+	}
+	i := strings.LastIndex(image, ":")
+	if i < 0 || strings.Contains(image[i:], "/") {
+		return nil
+	}
+	v, err := version.ParseGeneric(image[i+1:])
+	if err != nil {
+		return nil
+	}
+	return v
+}
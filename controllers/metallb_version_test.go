@@ -0,0 +1,127 @@
+package controllers
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	appsv1 "k8s.io/api/apps/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	metallbv1beta1 "github.com/metallb/metallb-operator/api/v1beta1"
+	"github.com/metallb/metallb-operator/pkg/status"
+)
+
+func TestCheckMetalLBVersion(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	tests := []struct {
+		image     string
+		supported bool
+	}{
+		{image: "quay.io/metallb/speaker:v0.9.6", supported: true},
+		{image: "quay.io/metallb/speaker:v0.10.0", supported: true},
+		{image: "quay.io/metallb/speaker:0.9.7", supported: true},
+		{image: "quay.io/metallb/speaker:v0.9.5", supported: false},
+		{image: "quay.io/metallb/speaker:v0.8", supported: false},
+		{image: "localhost:5000/metallb/speaker:v0.9.3", supported: false},
+		{image: "quay.io/metallb/speaker:v0.9.3@sha256:0123456789abcdef", supported: false},
+		// Tags that are not a version can't be checked
+		{image: "quay.io/metallb/speaker:main", supported: true},
+		{image: "quay.io/metallb/speaker:latest", supported: true},
+		{image: "quay.io/metallb/speaker@sha256:0123456789abcdef", supported: true},
+		{image: "localhost:5000/metallb/speaker", supported: true},
+		{image: "", supported: true},
+	}
+	for _, test := range tests {
+		err := checkMetalLBVersion("v0.9.6", test.image)
+		if test.supported {
+			g.Expect(err).ToNot(HaveOccurred(), test.image)
+		} else {
+			g.Expect(err).To(MatchError(ContainSubstring("older than the minimum MetalLB version v0.9.6")), test.image)
+		}
+	}
+
+	g.Expect(checkMetalLBVersion("", "quay.io/metallb/speaker:v0.1.0")).To(Succeed())
+	g.Expect(checkMetalLBVersion("not-a-version", "quay.io/metallb/speaker:v0.9.6")).To(MatchError(ContainSubstring("invalid minMetalLBVersion")))
+}
+
+func TestMetalLBUnsupportedVersion(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	for name, value := range map[string]string{
+		"SPEAKER_IMAGE":    "quay.io/metallb/speaker:v0.9.3",
+		"CONTROLLER_IMAGE": "quay.io/metallb/controller:v0.9.3",
+	} {
+		previous, isSet := os.LookupEnv(name)
+		os.Setenv(name, value)
+		if isSet {
+			defer os.Setenv(name, previous)
+		} else {
+			defer os.Unsetenv(name)
+		}
+	}
+
+	metallb := testMetalLB(metallbv1beta1.MetalLBSpec{MinMetalLBVersion: "v0.9.6"})
+	c := newFakeClientBuilder().WithScheme(testScheme(g)).WithObjects(metallb).Build()
+
+	conditions := reconcileTestMetalLB(g, c)
+	degraded := meta.FindStatusCondition(conditions, status.ConditionDegraded)
+	g.Expect(degraded).ToNot(BeNil())
+	g.Expect(degraded.Reason).To(Equal("UnsupportedMetalLBVersion"))
+	g.Expect(degraded.Message).To(ContainSubstring("older than the minimum MetalLB version v0.9.6"))
+
+	// The MetalLB resources are not deployed
+	err := c.Get(context.Background(), types.NamespacedName{Name: "speaker", Namespace: MetalLBTestNameSpace}, &appsv1.DaemonSet{})
+	g.Expect(apierrors.IsNotFound(err)).To(BeTrue(), "%v", err)
+}
+
+func TestMetalLBImageOverrides(t *testing.T) {
+	g := NewGomegaWithT(t)
+	defer setEnv("SPEAKER_IMAGE", "quay.io/metallb/speaker:v0.9.6")()
+	defer setEnv("CONTROLLER_IMAGE", "quay.io/metallb/controller:v0.9.6")()
+
+	objs := renderTestObjects(g, metallbv1beta1.MetalLBSpec{SpeakerImage: "mirror.example.com/metallb/speaker:v0.9.6"})
+	speaker, controller := speakerAndController(g, objs)
+	g.Expect(speaker.Spec.Template.Spec.Containers[0].Image).To(Equal("mirror.example.com/metallb/speaker:v0.9.6"))
+	g.Expect(controller.Spec.Template.Spec.Containers[0].Image).To(Equal("quay.io/metallb/controller:v0.9.6"))
+
+	objs = renderTestObjects(g, metallbv1beta1.MetalLBSpec{ControllerImage: "mirror.example.com/metallb/controller:v0.9.6"})
+	speaker, controller = speakerAndController(g, objs)
+	g.Expect(speaker.Spec.Template.Spec.Containers[0].Image).To(Equal("quay.io/metallb/speaker:v0.9.6"))
+	g.Expect(controller.Spec.Template.Spec.Containers[0].Image).To(Equal("mirror.example.com/metallb/controller:v0.9.6"))
+
+	// The overridden images are the ones checked against the minimum version
+	metallb := testMetalLB(metallbv1beta1.MetalLBSpec{MinMetalLBVersion: "v0.9.6", SpeakerImage: "mirror.example.com/metallb/speaker:v0.9.3"})
+	c := newFakeClientBuilder().WithScheme(testScheme(g)).WithObjects(metallb).Build()
+	degraded := meta.FindStatusCondition(reconcileTestMetalLB(g, c), status.ConditionDegraded)
+	g.Expect(degraded).ToNot(BeNil())
+	g.Expect(degraded.Reason).To(Equal("UnsupportedMetalLBVersion"))
+	g.Expect(degraded.Message).To(ContainSubstring("mirror.example.com/metallb/speaker:v0.9.3"))
+}
+
+func TestMetalLBInvalidImage(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	err := validateMetalLBSpec(&metallbv1beta1.MetalLBSpec{SpeakerImage: "mirror.example.com/MetalLB/speaker"})
+	g.Expect(err).To(MatchError(ContainSubstring(`invalid speakerImage "mirror.example.com/MetalLB/speaker"`)))
+
+	metallb := testMetalLB(metallbv1beta1.MetalLBSpec{ControllerImage: "mirror.example.com/metallb/controller:"})
+	c := newFakeClientBuilder().WithScheme(testScheme(g)).WithObjects(metallb).Build()
+
+	conditions := reconcileTestMetalLB(g, c)
+	g.Expect(meta.IsStatusConditionFalse(conditions, status.ConditionConfigValid)).To(BeTrue())
+	degraded := meta.FindStatusCondition(conditions, status.ConditionDegraded)
+	g.Expect(degraded).ToNot(BeNil())
+	g.Expect(degraded.Status).To(Equal(metav1.ConditionTrue))
+	g.Expect(degraded.Reason).To(Equal("InvalidImage"))
+	g.Expect(degraded.Message).To(ContainSubstring(`invalid controllerImage "mirror.example.com/metallb/controller:"`))
+
+	// The MetalLB resources are not deployed
+	err = c.Get(context.Background(), types.NamespacedName{Name: "controller", Namespace: MetalLBTestNameSpace}, &appsv1.Deployment{})
+	g.Expect(apierrors.IsNotFound(err)).To(BeTrue(), "%v", err)
+}
@@ -0,0 +1,64 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+
+	"github.com/prometheus/client_golang/prometheus"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+const (
+	reconcileSuccess = "success"
+	reconcileError   = "error"
+	reconcileRequeue = "requeue"
+)
+
+var (
+	reconcileTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "metallb_operator_reconcile_total",
+		Help: "Number of reconciles of the operator controllers, by result.",
+	}, []string{"controller", "result"})
+	configMapPools = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "metallb_operator_configmap_pools",
+		Help: "Number of address pools rendered into the MetalLB ConfigMaps.",
+	})
+)
+
+func init() {
+	metrics.Registry.MustRegister(reconcileTotal, configMapPools)
+}
+
+// withReconcileMetrics counts the reconciles of the given reconciler by result,
+// under the given controller name.
+func withReconcileMetrics(controller string, r reconcile.Reconciler) reconcile.Reconciler {
+	return reconcile.Func(func(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+		result, err := r.Reconcile(ctx, req)
+		switch {
+		case err != nil:
+			reconcileTotal.WithLabelValues(controller, reconcileError).Inc()
+		case result.Requeue || result.RequeueAfter > 0:
+			reconcileTotal.WithLabelValues(controller, reconcileRequeue).Inc()
+		default:
+			reconcileTotal.WithLabelValues(controller, reconcileSuccess).Inc()
+		}
+		return result, err
+	})
+}
@@ -0,0 +1,103 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+
+	metallbv1alpha1 "github.com/metallb/metallb-operator/api/v1alpha1"
+	metallbv1beta1 "github.com/metallb/metallb-operator/api/v1beta1"
+)
+
+// scrapeMetric returns the value of the metric with the given name and labels
+// exposed by the controller-runtime registry, and whether it was found.
+func scrapeMetric(g *GomegaWithT, name string, labels map[string]string) (float64, bool) {
+	families, err := metrics.Registry.Gather()
+	g.Expect(err).ToNot(HaveOccurred())
+	for _, family := range families {
+		if family.GetName() != name {
+			continue
+		}
+		for _, metric := range family.GetMetric() {
+			matched := 0
+			for _, pair := range metric.GetLabel() {
+				if value, ok := labels[pair.GetName()]; ok && value == pair.GetValue() {
+					matched++
+				}
+			}
+			if matched != len(labels) {
+				continue
+			}
+			if metric.GetCounter() != nil {
+				return metric.GetCounter().GetValue(), true
+			}
+			return metric.GetGauge().GetValue(), true
+		}
+	}
+	return 0, false
+}
+
+func TestOperatorMetrics(t *testing.T) {
+	g := NewGomegaWithT(t)
+	manifestPath := AddressPoolManifestPath
+	AddressPoolManifestPath = "../bindata/configuration/address-pool"
+	defer func() { AddressPoolManifestPath = manifestPath }()
+
+	gold := &metallbv1alpha1.AddressPool{
+		ObjectMeta: metav1.ObjectMeta{Name: "gold", Namespace: MetalLBTestNameSpace},
+		Spec:       metallbv1alpha1.AddressPoolSpec{Protocol: "layer2", Addresses: []string{"10.0.0.0/30"}},
+	}
+	silver := &metallbv1alpha1.AddressPool{
+		ObjectMeta: metav1.ObjectMeta{Name: "silver", Namespace: MetalLBTestNameSpace},
+		Spec:       metallbv1alpha1.AddressPoolSpec{Protocol: "layer2", Addresses: []string{"10.0.2.0/24"}},
+	}
+	pools := &AddressPoolReconciler{
+		Client:          newFakeClientBuilder().WithScheme(testScheme(g)).WithObjects(testMetalLB(metallbv1beta1.MetalLBSpec{}), gold, silver).Build(),
+		Log:             ctrl.Log.WithName("controllers").WithName("AddressPool"),
+		Namespace:       MetalLBTestNameSpace,
+		MaxAddressPools: 1,
+	}
+	reconciler := withReconcileMetrics("addresspool", pools)
+	reconcile := func(name string) {
+		_, err := reconciler.Reconcile(context.Background(), ctrl.Request{
+			NamespacedName: types.NamespacedName{Name: name, Namespace: MetalLBTestNameSpace},
+		})
+		g.Expect(err).ToNot(HaveOccurred())
+	}
+	reconciles := func(result string) float64 {
+		value, _ := scrapeMetric(g, "metallb_operator_reconcile_total", map[string]string{"controller": "addresspool", "result": result})
+		return value
+	}
+	degraded := func(name string) (float64, bool) {
+		return scrapeMetric(g, "metallb_operator_degraded", map[string]string{"kind": "AddressPool", "namespace": MetalLBTestNameSpace, "name": name})
+	}
+	successes, requeues := reconciles(reconcileSuccess), reconciles(reconcileRequeue)
+
+	reconcile("gold")
+	g.Expect(reconciles(reconcileSuccess)).To(Equal(successes + 1))
+	value, found := scrapeMetric(g, "metallb_operator_configmap_pools", nil)
+	g.Expect(found).To(BeTrue())
+	g.Expect(value).To(Equal(1.0))
+	value, found = degraded("gold")
+	g.Expect(found).To(BeTrue())
+	g.Expect(value).To(Equal(0.0))
+
+	// The pool over the limit is degraded and checked again later
+	reconcile("silver")
+	g.Expect(reconciles(reconcileRequeue)).To(Equal(requeues + 1))
+	value, found = degraded("silver")
+	g.Expect(found).To(BeTrue())
+	g.Expect(value).To(Equal(1.0))
+
+	// The gauge of a deleted pool is dropped
+	g.Expect(pools.Delete(context.Background(), silver)).To(Succeed())
+	reconcile("silver")
+	g.Expect(reconciles(reconcileSuccess)).To(Equal(successes + 2))
+	_, found = degraded("silver")
+	g.Expect(found).To(BeFalse())
+}
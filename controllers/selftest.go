@@ -0,0 +1,90 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/go-logr/logr"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	metallbv1alpha1 "github.com/metallb/metallb-operator/api/v1alpha1"
+	metallbv1beta1 "github.com/metallb/metallb-operator/api/v1beta1"
+	"github.com/metallb/metallb-operator/pkg/selftest"
+	"github.com/metallb/metallb-operator/pkg/status"
+)
+
+const (
+	selfTestInterval = 5 * time.Second
+	// selfTestTimeout bounds both the wait for MetalLB to be available and the
+	// wait for an address to be assigned.
+	selfTestTimeout = 5 * time.Minute
+)
+
+// SelfTest runs the self test once MetalLB is available, and reports its
+// outcome on the MetalLB resource.
+type SelfTest struct {
+	client.Client
+	Log       logr.Logger
+	Namespace string
+	// Pool is the name of the AddressPool the self test service requests an address from
+	Pool string
+}
+
+// +kubebuilder:rbac:groups="",namespace=metallb-system,resources=services,verbs=get;list;watch;create;delete
+
+// Start implements manager.Runnable
+func (t *SelfTest) Start(ctx context.Context) error {
+	metallb := &metallbv1beta1.MetalLB{}
+	key := types.NamespacedName{Name: defaultMetalLBCrName, Namespace: t.Namespace}
+	err := wait.PollImmediate(selfTestInterval, selfTestTimeout, func() (bool, error) {
+		if err := t.Get(ctx, key, metallb); err != nil {
+			return false, client.IgnoreNotFound(err)
+		}
+		return meta.IsStatusConditionTrue(metallb.Status.Conditions, status.ConditionAvailable), nil
+	})
+	if err != nil {
+		t.Log.Info(fmt.Sprintf("Skipping the self test, MetalLB is not available: %s", err))
+		return nil
+	}
+
+	message, testErr := t.run(ctx)
+	if testErr != nil {
+		t.Log.Info(fmt.Sprintf("Self test failed: %s", testErr))
+	}
+	if err := status.UpdateSelfTest(ctx, t.Client, metallb, message, testErr); err != nil {
+		t.Log.Info(fmt.Sprintf("Failed to update metallb status %s", err))
+	}
+	return nil
+}
+
+func (t *SelfTest) run(ctx context.Context) (string, error) {
+	pool := &metallbv1alpha1.AddressPool{}
+	if err := t.Get(ctx, types.NamespacedName{Name: t.Pool, Namespace: t.Namespace}, pool); err != nil {
+		return "", fmt.Errorf("could not get the self test AddressPool %s: %w", t.Pool, err)
+	}
+	ip, err := selftest.Run(ctx, t.Client, t.Namespace, pool, selfTestInterval, selfTestTimeout)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("Address %s assigned from pool %s", ip, t.Pool), nil
+}
@@ -0,0 +1,205 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"time"
+
+	"github.com/pkg/errors"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/util/flowcontrol"
+	policyv1beta1 "k8s.io/kubernetes/pkg/apis/policy/v1beta1"
+	rbacv1 "k8s.io/kubernetes/pkg/apis/rbac/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	metallbv1alpha1 "github.com/metallb/metallb-operator/api/v1alpha1"
+	metallbv1beta1 "github.com/metallb/metallb-operator/api/v1beta1"
+)
+
+var schemeBuilder = runtime.NewSchemeBuilder(
+	clientgoscheme.AddToScheme,
+	metallbv1alpha1.AddToScheme,
+	metallbv1beta1.AddToScheme,
+	corev1.AddToScheme,
+	appsv1.AddToScheme,
+	policyv1beta1.AddToScheme,
+	rbacv1.AddToScheme,
+)
+
+// AddToScheme registers all the types the reconcilers work with.
+var AddToScheme = schemeBuilder.AddToScheme
+
+// SetupOptions configures the reconcilers set up by SetupAll.
+type SetupOptions struct {
+	// Namespace is the namespace MetalLB is deployed to
+	Namespace    string
+	PlatformInfo PlatformInfo
+
+	MaxAddressPools int
+	PoolNamespaces  []string
+	// TargetNamespaces are the namespaces, besides Namespace, the MetalLB
+	// resource may deploy MetalLB to, AllPoolNamespaces for all of them.
+	TargetNamespaces        []string
+	CheckPodCIDR            bool
+	ConfigAppliedEvents     bool
+	PoolMetrics             bool
+	ReservedRangesConfigMap string
+	// ConfigWriteFailureThreshold is the number of consecutive failed writes
+	// of the MetalLB configuration setting the ConfigWriteUnstable condition
+	ConfigWriteFailureThreshold int
+
+	// SpeakerRestartGracePeriod is how long a speaker is given to load a new configuration
+	SpeakerRestartGracePeriod time.Duration
+	// SpeakerRestartQPS is the rate the stuck speaker pods are restarted at
+	SpeakerRestartQPS float32
+
+	// SelfTestPool is the AddressPool the self test requests an address from,
+	// the self test is not run when empty.
+	SelfTestPool string
+	// EnableWebhook serves the validating webhooks
+	EnableWebhook bool
+	// CanaryInterval is how often the canary of the MetalLB resource enabling
+	// it is checked, the canary is not run when zero.
+	CanaryInterval time.Duration
+	// ConfigExportAddr is the address the configuration export is served
+	// at, it is not served when empty.
+	ConfigExportAddr string
+}
+
+// SetupAll sets up all the reconcilers, the self test and the webhooks with
+// the manager. Every component is set up, the errors are aggregated.
+func SetupAll(mgr ctrl.Manager, opts SetupOptions) error {
+	errs := []error{}
+	speakerMetrics := NewHTTPSpeakerMetrics()
+
+	if err := (&MetalLBReconciler{
+		Client:           mgr.GetClient(),
+		Log:              ctrl.Log.WithName("controllers").WithName("MetalLB"),
+		Scheme:           mgr.GetScheme(),
+		PlatformInfo:     opts.PlatformInfo,
+		Namespace:        opts.Namespace,
+		TargetNamespaces: opts.TargetNamespaces,
+		SpeakerMetrics:   speakerMetrics,
+	}).SetupWithManager(mgr); err != nil {
+		errs = append(errs, errors.Wrap(err, "unable to create the MetalLB controller"))
+	}
+	pools := &AddressPoolReconciler{
+		Client:                      mgr.GetClient(),
+		Log:                         ctrl.Log.WithName("controllers").WithName("AddressPool"),
+		Scheme:                      mgr.GetScheme(),
+		Namespace:                   opts.Namespace,
+		MaxAddressPools:             opts.MaxAddressPools,
+		PoolNamespaces:              opts.PoolNamespaces,
+		CheckPodCIDR:                opts.CheckPodCIDR,
+		Recorder:                    mgr.GetEventRecorderFor("addresspool-controller"),
+		ConfigAppliedEvents:         opts.ConfigAppliedEvents,
+		PoolMetrics:                 opts.PoolMetrics,
+		ReservedRangesConfigMap:     opts.ReservedRangesConfigMap,
+		ConfigWriteFailureThreshold: opts.ConfigWriteFailureThreshold,
+	}
+	if err := pools.SetupWithManager(mgr); err != nil {
+		errs = append(errs, errors.Wrap(err, "unable to create the AddressPool controller"))
+	}
+	if err := (&BGPPeerReconciler{
+		Client:    mgr.GetClient(),
+		Log:       ctrl.Log.WithName("controllers").WithName("BGPPeer"),
+		Scheme:    mgr.GetScheme(),
+		Namespace: opts.Namespace,
+		Pools:     pools,
+	}).SetupWithManager(mgr); err != nil {
+		errs = append(errs, errors.Wrap(err, "unable to create the BGPPeer controller"))
+	}
+	if err := (&BFDProfileReconciler{
+		Client:    mgr.GetClient(),
+		Log:       ctrl.Log.WithName("controllers").WithName("BFDProfile"),
+		Scheme:    mgr.GetScheme(),
+		Namespace: opts.Namespace,
+		Pools:     pools,
+	}).SetupWithManager(mgr); err != nil {
+		errs = append(errs, errors.Wrap(err, "unable to create the BFDProfile controller"))
+	}
+	if err := (&CommunityReconciler{
+		Client:    mgr.GetClient(),
+		Log:       ctrl.Log.WithName("controllers").WithName("Community"),
+		Scheme:    mgr.GetScheme(),
+		Namespace: opts.Namespace,
+		Pools:     pools,
+	}).SetupWithManager(mgr); err != nil {
+		errs = append(errs, errors.Wrap(err, "unable to create the Community controller"))
+	}
+	if err := (&SpeakerPodReconciler{
+		Client:         mgr.GetClient(),
+		SpeakerMetrics: speakerMetrics,
+		Log:            ctrl.Log.WithName("controllers").WithName("SpeakerPod"),
+		Namespace:      opts.Namespace,
+		GracePeriod:    opts.SpeakerRestartGracePeriod,
+		RestartLimiter: flowcontrol.NewTokenBucketRateLimiter(opts.SpeakerRestartQPS, 1),
+	}).SetupWithManager(mgr); err != nil {
+		errs = append(errs, errors.Wrap(err, "unable to create the SpeakerPod controller"))
+	}
+	if opts.SelfTestPool != "" {
+		if err := mgr.Add(&SelfTest{
+			Client:    mgr.GetClient(),
+			Log:       ctrl.Log.WithName("selftest"),
+			Namespace: opts.Namespace,
+			Pool:      opts.SelfTestPool,
+		}); err != nil {
+			errs = append(errs, errors.Wrap(err, "unable to add the self test"))
+		}
+	}
+	if opts.CanaryInterval > 0 {
+		if err := mgr.Add(&Canary{
+			Client:    mgr.GetClient(),
+			Log:       ctrl.Log.WithName("canary"),
+			Scheme:    mgr.GetScheme(),
+			Namespace: opts.Namespace,
+			Interval:  opts.CanaryInterval,
+		}); err != nil {
+			errs = append(errs, errors.Wrap(err, "unable to add the canary"))
+		}
+	}
+	if opts.ConfigExportAddr != "" {
+		if err := mgr.Add(&ConfigExport{
+			Client:    mgr.GetClient(),
+			Log:       ctrl.Log.WithName("configexport"),
+			Scheme:    mgr.GetScheme(),
+			Namespace: opts.Namespace,
+			Addr:      opts.ConfigExportAddr,
+			Pools:     pools,
+		}); err != nil {
+			errs = append(errs, errors.Wrap(err, "unable to add the configuration export"))
+		}
+	}
+	if opts.EnableWebhook {
+		if err := (&metallbv1alpha1.AddressPool{}).SetupWebhookWithManager(mgr); err != nil {
+			errs = append(errs, errors.Wrap(err, "unable to create the AddressPool webhook"))
+		}
+		if err := (&metallbv1beta1.MetalLB{}).SetupWebhookWithManager(mgr); err != nil {
+			errs = append(errs, errors.Wrap(err, "unable to create the MetalLB webhook"))
+		}
+		if err := (&metallbv1alpha1.BGPPeer{}).SetupWebhookWithManager(mgr); err != nil {
+			errs = append(errs, errors.Wrap(err, "unable to create the BGPPeer webhook"))
+		}
+	}
+	// +kubebuilder:scaffold:builder
+
+	return utilerrors.NewAggregate(errs)
+}
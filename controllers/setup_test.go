@@ -0,0 +1,126 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+	. "github.com/onsi/gomega"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+)
+
+// fakeManager records the runnables added to it, without starting them.
+type fakeManager struct {
+	manager.Manager
+	scheme    *runtime.Scheme
+	client    client.Client
+	webhooks  *webhook.Server
+	runnables []manager.Runnable
+	addErr    error
+}
+
+func (m *fakeManager) Add(r manager.Runnable) error {
+	if m.addErr != nil {
+		return m.addErr
+	}
+	m.runnables = append(m.runnables, r)
+	return nil
+}
+func (m *fakeManager) SetFields(interface{}) error { return nil }
+func (m *fakeManager) GetScheme() *runtime.Scheme  { return m.scheme }
+func (m *fakeManager) GetClient() client.Client    { return m.client }
+func (m *fakeManager) GetConfig() *rest.Config     { return &rest.Config{} }
+func (m *fakeManager) GetLogger() logr.Logger      { return log.Log }
+func (m *fakeManager) GetEventRecorderFor(string) record.EventRecorder {
+	return record.NewFakeRecorder(10)
+}
+func (m *fakeManager) GetWebhookServer() *webhook.Server { return m.webhooks }
+
+func newFakeManager(g *WithT) *fakeManager {
+	s := runtime.NewScheme()
+	g.Expect(AddToScheme(s)).To(Succeed())
+	return &fakeManager{
+		scheme:   s,
+		client:   newFakeClientBuilder().WithScheme(s).Build(),
+		webhooks: &webhook.Server{},
+	}
+}
+
+// controllerNames returns the names of the controllers added to the manager,
+// and the other runnables by type.
+func (m *fakeManager) controllerNames() []string {
+	names := []string{}
+	for _, r := range m.runnables {
+		v := reflect.Indirect(reflect.ValueOf(r))
+		if name := v.FieldByName("Name"); name.IsValid() && name.Kind() == reflect.String {
+			names = append(names, name.String())
+			continue
+		}
+		names = append(names, v.Type().Name())
+	}
+	sort.Strings(names)
+	return names
+}
+
+func TestSetupAll(t *testing.T) {
+	g := NewGomegaWithT(t)
+	mgr := newFakeManager(g)
+
+	err := SetupAll(mgr, SetupOptions{Namespace: MetalLBTestNameSpace})
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(mgr.controllerNames()).To(Equal([]string{"addresspool", "bfdprofile", "bgppeer", "community", "metallb", "speakerpod"}))
+	g.Expect(mgr.webhooks.WebhookMux).To(BeNil())
+}
+
+func TestSetupAllOptionalComponents(t *testing.T) {
+	g := NewGomegaWithT(t)
+	mgr := newFakeManager(g)
+
+	err := SetupAll(mgr, SetupOptions{
+		Namespace:        MetalLBTestNameSpace,
+		SelfTestPool:     "selftest",
+		EnableWebhook:    true,
+		CanaryInterval:   time.Minute,
+		ConfigExportAddr: DefaultConfigExportAddr,
+	})
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(mgr.controllerNames()).To(Equal([]string{"Canary", "ConfigExport", "SelfTest", "addresspool", "bfdprofile", "bgppeer", "community", "metallb", "speakerpod"}))
+	g.Expect(mgr.webhooks.WebhookMux).NotTo(BeNil())
+	handler, _ := mgr.webhooks.WebhookMux.Handler(
+		&http.Request{URL: &url.URL{Path: "/validate-metallb-io-v1alpha1-addresspool"}})
+	g.Expect(handler).NotTo(BeNil())
+	handler, pattern := mgr.webhooks.WebhookMux.Handler(
+		&http.Request{URL: &url.URL{Path: "/validate-metallb-io-v1alpha1-bgppeer"}})
+	g.Expect(handler).NotTo(BeNil())
+	g.Expect(pattern).To(Equal("/validate-metallb-io-v1alpha1-bgppeer"))
+}
+
+func TestSetupAllAggregatesErrors(t *testing.T) {
+	g := NewGomegaWithT(t)
+	mgr := newFakeManager(g)
+	mgr.addErr = errors.New("add failed")
+
+	err := SetupAll(mgr, SetupOptions{Namespace: MetalLBTestNameSpace, SelfTestPool: "selftest",
+		CanaryInterval: time.Minute, ConfigExportAddr: DefaultConfigExportAddr})
+	g.Expect(err).To(HaveOccurred())
+	g.Expect(err.Error()).To(ContainSubstring("MetalLB controller"))
+	g.Expect(err.Error()).To(ContainSubstring("AddressPool controller"))
+	g.Expect(err.Error()).To(ContainSubstring("BGPPeer controller"))
+	g.Expect(err.Error()).To(ContainSubstring("BFDProfile controller"))
+	g.Expect(err.Error()).To(ContainSubstring("Community controller"))
+	g.Expect(err.Error()).To(ContainSubstring("SpeakerPod controller"))
+	g.Expect(err.Error()).To(ContainSubstring("self test"))
+	g.Expect(err.Error()).To(ContainSubstring("canary"))
+	g.Expect(err.Error()).To(ContainSubstring("configuration export"))
+}
@@ -0,0 +1,243 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/go-logr/logr"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/flowcontrol"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	"github.com/metallb/metallb-operator/pkg/apply"
+)
+
+const speakerComponentLabel = "speaker"
+
+// restartRetryPeriod is how long a restart denied by the rate limit is delayed.
+const restartRetryPeriod = 10 * time.Second
+
+// SpeakerPodReconciler restarts the ready speaker pods which failed to load
+// the current configuration, as reported by their metrics a GracePeriod after
+// it changed. Only the pods of the current revision of the speaker DaemonSet
+// are checked: the pods of a previous one are being replaced by its rollout,
+// within its maxUnavailable. The pods whose metrics can't be read are left
+// alone.
+type SpeakerPodReconciler struct {
+	client.Client
+	Log       logr.Logger
+	Namespace string
+	// SpeakerMetrics reads whether a speaker loaded its configuration, the
+	// pods are not restarted when nil.
+	SpeakerMetrics SpeakerMetrics
+	// GracePeriod is how long a pod is given to load a new configuration
+	// before being checked.
+	GracePeriod time.Duration
+	// RestartLimiter bounds the rate of the restarts, nil means no limit.
+	RestartLimiter flowcontrol.RateLimiter
+
+	// pending tracks since when each pod has been given the configuration
+	// it was not verified to load yet
+	pending map[types.UID]pendingConfig
+	// verified is the checksum of the configuration each pod was verified to
+	// load
+	verified map[types.UID]string
+}
+
+// pendingConfig is a configuration given to a speaker pod.
+type pendingConfig struct {
+	checksum string
+	since    time.Time
+}
+
+// +kubebuilder:rbac:groups="",namespace=metallb-system,resources=pods,verbs=get;list;watch;delete
+// +kubebuilder:rbac:groups="",namespace=metallb-system,resources=configmaps,verbs=get;list;watch
+// +kubebuilder:rbac:groups=apps,namespace=metallb-system,resources=controllerrevisions,verbs=get;list;watch
+
+func (r *SpeakerPodReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	if r.SpeakerMetrics == nil {
+		return ctrl.Result{}, nil
+	}
+	if r.pending == nil {
+		r.pending = map[types.UID]pendingConfig{}
+		r.verified = map[types.UID]string{}
+	}
+
+	pod := &corev1.Pod{}
+	if err := r.Get(ctx, req.NamespacedName, pod); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	if pod.DeletionTimestamp != nil || !isPodReady(pod) {
+		r.forget(pod.UID)
+		return ctrl.Result{}, nil
+	}
+	current, err := r.isCurrentRevision(ctx, pod)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	if !current {
+		// The rollout of the DaemonSet replaces the pod
+		r.forget(pod.UID)
+		return ctrl.Result{}, nil
+	}
+
+	configMap := &corev1.ConfigMap{}
+	err = r.Get(ctx, types.NamespacedName{Name: apply.AddressPoolConfigMap, Namespace: req.Namespace}, configMap)
+	if errors.IsNotFound(err) {
+		return ctrl.Result{}, nil
+	}
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	checksum := configMapChecksum(configMap.Data)
+	if r.verified[pod.UID] == checksum {
+		return ctrl.Result{}, nil
+	}
+	pending, ok := r.pending[pod.UID]
+	if !ok || pending.checksum != checksum {
+		pending = pendingConfig{checksum: checksum, since: time.Now()}
+		r.pending[pod.UID] = pending
+	}
+	if wait := r.GracePeriod - time.Since(pending.since); wait > 0 {
+		return ctrl.Result{RequeueAfter: wait}, nil
+	}
+
+	loaded, err := r.SpeakerMetrics.ConfigLoaded(ctx, pod)
+	if err != nil {
+		r.Log.Info(fmt.Sprintf("Could not verify speaker pod %v loaded the current configuration: %s", req.NamespacedName, err))
+		return ctrl.Result{RequeueAfter: r.GracePeriod}, nil
+	}
+	if loaded {
+		delete(r.pending, pod.UID)
+		r.verified[pod.UID] = checksum
+		return ctrl.Result{}, nil
+	}
+	if r.RestartLimiter != nil && !r.RestartLimiter.TryAccept() {
+		r.Log.Info(fmt.Sprintf("Delaying the restart of speaker pod %v, too many restarts", req.NamespacedName))
+		return ctrl.Result{RequeueAfter: restartRetryPeriod}, nil
+	}
+
+	r.Log.Info(fmt.Sprintf("Restarting speaker pod %v, it did not load the current configuration", req.NamespacedName))
+	if err := r.Delete(ctx, pod); err != nil && !errors.IsNotFound(err) {
+		return ctrl.Result{}, err
+	}
+	r.forget(pod.UID)
+	return ctrl.Result{}, nil
+}
+
+func (r *SpeakerPodReconciler) forget(uid types.UID) {
+	delete(r.pending, uid)
+	delete(r.verified, uid)
+}
+
+// isCurrentRevision returns whether the pod was created from the current
+// revision of the speaker DaemonSet, as per its controller-revision-hash
+// label. It is not while the revision can't be found.
+func (r *SpeakerPodReconciler) isCurrentRevision(ctx context.Context, pod *corev1.Pod) (bool, error) {
+	ds := &appsv1.DaemonSet{}
+	err := r.Get(ctx, types.NamespacedName{Name: "speaker", Namespace: pod.Namespace}, ds)
+	if errors.IsNotFound(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if ds.Spec.Selector == nil {
+		return false, nil
+	}
+	revisions := &appsv1.ControllerRevisionList{}
+	if err := r.List(ctx, revisions, client.InNamespace(ds.Namespace), client.MatchingLabels(ds.Spec.Selector.MatchLabels)); err != nil {
+		return false, err
+	}
+	var current *appsv1.ControllerRevision
+	for i := range revisions.Items {
+		revision := &revisions.Items[i]
+		if metav1.IsControlledBy(revision, ds) && (current == nil || revision.Revision > current.Revision) {
+			current = revision
+		}
+	}
+	if current == nil {
+		return false, nil
+	}
+	hash := current.Labels[appsv1.DefaultDaemonSetUniqueLabelKey]
+	return hash != "" && pod.Labels[appsv1.DefaultDaemonSetUniqueLabelKey] == hash, nil
+}
+
+// speakerPodRequests maps a change of the MetalLB ConfigMap to all the speaker pods.
+func (r *SpeakerPodReconciler) speakerPodRequests(obj client.Object) []reconcile.Request {
+	pods := &corev1.PodList{}
+	err := r.List(context.Background(), pods, client.InNamespace(obj.GetNamespace()), client.MatchingLabels{"component": speakerComponentLabel})
+	if err != nil {
+		r.Log.Info(fmt.Sprintf("Failed to list speaker pods %s", err))
+		return nil
+	}
+
+	requests := make([]reconcile.Request, 0, len(pods.Items))
+	for _, pod := range pods.Items {
+		requests = append(requests, reconcile.Request{
+			NamespacedName: types.NamespacedName{Name: pod.Name, Namespace: pod.Namespace},
+		})
+	}
+	return requests
+}
+
+// isWorkloadsNamespace returns whether the speakers are deployed to the given namespace.
+func (r *SpeakerPodReconciler) isWorkloadsNamespace(namespace string) bool {
+	workloads, err := workloadsNamespace(context.Background(), r.Client, r.Namespace)
+	if err != nil {
+		r.Log.Info(err.Error())
+		return false
+	}
+	return namespace == workloads
+}
+
+func (r *SpeakerPodReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	isSpeakerPod := predicate.NewPredicateFuncs(func(obj client.Object) bool {
+		return obj.GetLabels()["component"] == speakerComponentLabel && r.isWorkloadsNamespace(obj.GetNamespace())
+	})
+	isMetalLBConfig := predicate.NewPredicateFuncs(func(obj client.Object) bool {
+		return obj.GetName() == apply.AddressPoolConfigMap && r.isWorkloadsNamespace(obj.GetNamespace())
+	})
+	return ctrl.NewControllerManagedBy(mgr).
+		Named("speakerpod").
+		For(&corev1.Pod{}, builder.WithPredicates(isSpeakerPod)).
+		Watches(&source.Kind{Type: &corev1.ConfigMap{}}, handler.EnqueueRequestsFromMapFunc(r.speakerPodRequests),
+			builder.WithPredicates(isMetalLBConfig)).
+		Complete(withReconcileMetrics("speakerpod", r))
+}
+
+func isPodReady(pod *corev1.Pod) bool {
+	for _, condition := range pod.Status.Conditions {
+		if condition.Type == corev1.PodReady {
+			return condition.Status == corev1.ConditionTrue
+		}
+	}
+	return false
+}
@@ -0,0 +1,116 @@
+package controllers
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/metallb/metallb-operator/test/consts"
+)
+
+var _ = Describe("SpeakerPod Controller", func() {
+	Context("Speaker pods failing to load the configuration", func() {
+		labels := map[string]string{"component": "speaker"}
+		configMap := &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      consts.MetalLBConfigMapName,
+				Namespace: MetalLBTestNameSpace,
+			},
+			Data: map[string]string{
+				consts.MetalLBConfigMapName: "address-pools: []\n",
+			},
+		}
+		ds := &appsv1.DaemonSet{
+			ObjectMeta: metav1.ObjectMeta{Name: "speaker", Namespace: MetalLBTestNameSpace},
+			Spec: appsv1.DaemonSetSpec{
+				Selector: &metav1.LabelSelector{MatchLabels: labels},
+				Template: corev1.PodTemplateSpec{
+					ObjectMeta: metav1.ObjectMeta{Labels: labels},
+					Spec: corev1.PodSpec{
+						Containers: []corev1.Container{{Name: "speaker", Image: "speaker"}},
+					},
+				},
+			},
+		}
+		revision := &appsv1.ControllerRevision{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "speaker-current",
+				Namespace: MetalLBTestNameSpace,
+				Labels:    map[string]string{"component": "speaker", appsv1.DefaultDaemonSetUniqueLabelKey: "current"},
+			},
+			Revision: 2,
+		}
+		speakerPod := func(name, hash string) *corev1.Pod {
+			return &corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      name,
+					Namespace: MetalLBTestNameSpace,
+					Labels:    map[string]string{"component": "speaker", appsv1.DefaultDaemonSetUniqueLabelKey: hash},
+				},
+				Spec: corev1.PodSpec{
+					Containers: []corev1.Container{{Name: "speaker", Image: "speaker"}},
+				},
+			}
+		}
+		previous := speakerPod("speaker-previous", "previous")
+		current := speakerPod("speaker-current", "current")
+
+		createReadyPod := func(pod *corev1.Pod) {
+			Expect(k8sClient.Create(context.Background(), pod)).To(Succeed())
+			pod.Status.Conditions = []corev1.PodCondition{{Type: corev1.PodReady, Status: corev1.ConditionTrue}}
+			Expect(k8sClient.Status().Update(context.Background(), pod)).To(Succeed())
+		}
+		podExists := func(pod *corev1.Pod) func() bool {
+			return func() bool {
+				err := k8sClient.Get(context.Background(), types.NamespacedName{Name: pod.Name, Namespace: pod.Namespace}, &corev1.Pod{})
+				return !apierrors.IsNotFound(err)
+			}
+		}
+
+		BeforeEach(func() {
+			By("Creating the MetalLB ConfigMap and the current revision of the speaker DaemonSet")
+			Expect(k8sClient.Create(context.Background(), configMap)).To(Succeed())
+			Expect(k8sClient.Create(context.Background(), ds)).To(Succeed())
+			controller := true
+			revision.OwnerReferences = []metav1.OwnerReference{{
+				APIVersion: "apps/v1", Kind: "DaemonSet", Name: ds.Name, UID: ds.UID, Controller: &controller,
+			}}
+			Expect(k8sClient.Create(context.Background(), revision)).To(Succeed())
+		})
+
+		AfterEach(func() {
+			for _, obj := range []client.Object{previous, current, revision, ds, configMap} {
+				err := k8sClient.Delete(context.Background(), obj)
+				if err != nil && !apierrors.IsNotFound(err) {
+					Fail(err.Error())
+				}
+				obj.SetResourceVersion("")
+				obj.SetUID("")
+			}
+		})
+
+		It("Should leave the pods of a previous revision to the rollout", func() {
+			By("Creating a ready speaker pod of a previous revision")
+			createReadyPod(previous)
+
+			By("Checking the pod is not deleted")
+			Consistently(podExists(previous), 3*time.Second, 200*time.Millisecond).Should(BeTrue())
+		})
+
+		It("Should delete the pods of the current revision", func() {
+			By("Creating a ready speaker pod of the current revision")
+			createReadyPod(current)
+
+			By("Checking the pod is deleted")
+			Eventually(podExists(current), 5*time.Second, 200*time.Millisecond).Should(BeFalse())
+		})
+	})
+})
@@ -0,0 +1,255 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+const (
+	// speakerConfigLoadedMetric is 1 once the speaker loaded a configuration.
+	speakerConfigLoadedMetric = "metallb_k8s_client_config_loaded_bool"
+	// speakerConfigStaleMetric is 1 while the speaker runs a previous
+	// configuration, as it failed to load the last one.
+	speakerConfigStaleMetric = "metallb_k8s_client_config_stale_bool"
+
+	speakerMetricsTimeout = 2 * time.Second
+
+	serviceAccountTokenFile = "/var/run/secrets/kubernetes.io/serviceaccount/token"
+	// serviceCAFile is the CA of the OpenShift service CA, mounted into the
+	// pods on OpenShift.
+	serviceCAFile = "/var/run/secrets/kubernetes.io/serviceaccount/service-ca.crt"
+)
+
+// The kube-rbac-proxy authorizes the scrapes of the operator
+// +kubebuilder:rbac:urls=/metrics,verbs=get
+
+// SpeakerMetrics reads the metrics a speaker pod serves.
+type SpeakerMetrics interface {
+	// ConfigLoaded returns whether the speaker loaded the last configuration
+	// it was given.
+	ConfigLoaded(ctx context.Context, pod *corev1.Pod) (bool, error)
+}
+
+// HTTPSpeakerMetrics scrapes the metrics port of the speaker pods, on the
+// address of their node as they run with the host network. When the metrics
+// are fronted by the kube-rbac-proxy, it scrapes the proxy over HTTPS with
+// the token of the operator.
+type HTTPSpeakerMetrics struct {
+	Client *http.Client
+	// TokenFile holds the bearer token authenticating to the kube-rbac-proxy.
+	TokenFile string
+	// ServiceCAFile holds the CA verifying the certificates the OpenShift
+	// service CA issues for the metrics Services.
+	ServiceCAFile string
+
+	lock sync.Mutex
+	// tlsClients are the clients scraping the kube-rbac-proxy, by the server
+	// name of the certificate they verify
+	tlsClients map[string]*http.Client
+}
+
+// NewHTTPSpeakerMetrics returns an HTTPSpeakerMetrics bounding each scrape.
+func NewHTTPSpeakerMetrics() *HTTPSpeakerMetrics {
+	return &HTTPSpeakerMetrics{
+		Client:        &http.Client{Timeout: speakerMetricsTimeout},
+		TokenFile:     serviceAccountTokenFile,
+		ServiceCAFile: serviceCAFile,
+	}
+}
+
+func (m *HTTPSpeakerMetrics) ConfigLoaded(ctx context.Context, pod *corev1.Pod) (bool, error) {
+	if pod.Status.HostIP == "" {
+		return false, fmt.Errorf("no metrics address for speaker pod %s", pod.Name)
+	}
+	if proxy := rbacProxyContainer(pod); proxy != nil {
+		return m.scrapeRBACProxy(ctx, pod, proxy)
+	}
+	port := speakerMetricsPort(pod)
+	if port == "" {
+		return false, fmt.Errorf("no metrics address for speaker pod %s", pod.Name)
+	}
+	return m.scrape(ctx, m.Client, fmt.Sprintf("http://%s/metrics", net.JoinHostPort(pod.Status.HostIP, port)), "")
+}
+
+// scrapeRBACProxy scrapes the metrics the kube-rbac-proxy of the pod serves.
+// Like the ServiceMonitor, it verifies the certificate issued by the service
+// CA for the metrics Service, and skips the verification of the other ones,
+// i.e. the MetricsTLSSecret or a self signed one.
+func (m *HTTPSpeakerMetrics) scrapeRBACProxy(ctx context.Context, pod *corev1.Pod, proxy *corev1.Container) (bool, error) {
+	var port int32
+	for _, p := range proxy.Ports {
+		if p.Name == rbacProxyPortName {
+			port = p.ContainerPort
+		}
+	}
+	if port == 0 {
+		return false, fmt.Errorf("no metrics address for speaker pod %s", pod.Name)
+	}
+	token, err := ioutil.ReadFile(m.TokenFile)
+	if err != nil {
+		return false, fmt.Errorf("reading the metrics token: %w", err)
+	}
+	serverName := ""
+	if podCertsSecret(pod) == fmt.Sprintf("%s-metrics-certs", speakerComponentLabel) {
+		serverName = fmt.Sprintf("%s-monitor-service.%s.svc", speakerComponentLabel, pod.Namespace)
+	}
+	client, err := m.tlsClient(serverName)
+	if err != nil {
+		return false, err
+	}
+	url := fmt.Sprintf("https://%s/metrics", net.JoinHostPort(pod.Status.HostIP, strconv.Itoa(int(port))))
+	return m.scrape(ctx, client, url, strings.TrimSpace(string(token)))
+}
+
+// tlsClient returns the client verifying the certificates of the given
+// server name against the service CA, or none when empty.
+func (m *HTTPSpeakerMetrics) tlsClient(serverName string) (*http.Client, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	if client, ok := m.tlsClients[serverName]; ok {
+		return client, nil
+	}
+
+	config := &tls.Config{InsecureSkipVerify: true}
+	if serverName != "" {
+		ca, err := ioutil.ReadFile(m.ServiceCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("reading the service CA: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(ca) {
+			return nil, fmt.Errorf("no certificate in the service CA %s", m.ServiceCAFile)
+		}
+		config = &tls.Config{ServerName: serverName, RootCAs: pool}
+	}
+	client := &http.Client{
+		Timeout:   m.Client.Timeout,
+		Transport: &http.Transport{TLSClientConfig: config},
+	}
+	if m.tlsClients == nil {
+		m.tlsClients = map[string]*http.Client{}
+	}
+	m.tlsClients[serverName] = client
+	return client, nil
+}
+
+func (m *HTTPSpeakerMetrics) scrape(ctx context.Context, client *http.Client, url, token string) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return false, err
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return false, fmt.Errorf("scraping %s: %s", url, resp.Status)
+	}
+	return parseConfigLoaded(resp.Body)
+}
+
+// rbacProxyContainer returns the kube-rbac-proxy container of the pod, nil
+// when its metrics are not fronted by the proxy.
+func rbacProxyContainer(pod *corev1.Pod) *corev1.Container {
+	for i := range pod.Spec.Containers {
+		if pod.Spec.Containers[i].Name == rbacProxyContainerName {
+			return &pod.Spec.Containers[i]
+		}
+	}
+	return nil
+}
+
+// podCertsSecret returns the secret the kube-rbac-proxy of the pod serves the
+// certificate of, see addRBACProxyToPod.
+func podCertsSecret(pod *corev1.Pod) string {
+	for _, v := range pod.Spec.Volumes {
+		if v.Name == rbacProxyCertsVolume && v.Secret != nil {
+			return v.Secret.SecretName
+		}
+	}
+	return ""
+}
+
+// speakerMetricsPort returns the port the speaker container serves its
+// metrics on, as set by its --port argument.
+func speakerMetricsPort(pod *corev1.Pod) string {
+	for _, c := range pod.Spec.Containers {
+		if c.Name != "speaker" {
+			continue
+		}
+		for _, arg := range c.Args {
+			if strings.HasPrefix(arg, "--port=") {
+				return strings.TrimPrefix(arg, "--port=")
+			}
+		}
+	}
+	return ""
+}
+
+// parseConfigLoaded reads the speaker metrics in the Prometheus text format:
+// the configuration is loaded when speakerConfigLoadedMetric is 1 and
+// speakerConfigStaleMetric is not.
+func parseConfigLoaded(r io.Reader) (bool, error) {
+	values := map[string]float64{}
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+		name := strings.SplitN(fields[0], "{", 2)[0]
+		if name != speakerConfigLoadedMetric && name != speakerConfigStaleMetric {
+			continue
+		}
+		value, err := strconv.ParseFloat(fields[1], 64)
+		if err != nil {
+			return false, fmt.Errorf("invalid %s value %q", name, fields[1])
+		}
+		values[name] = value
+	}
+	if err := scanner.Err(); err != nil {
+		return false, err
+	}
+	loaded, ok := values[speakerConfigLoadedMetric]
+	if !ok {
+		return false, fmt.Errorf("metric %s not found", speakerConfigLoadedMetric)
+	}
+	return loaded == 1 && values[speakerConfigStaleMetric] != 1, nil
+}
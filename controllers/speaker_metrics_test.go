@@ -0,0 +1,131 @@
+package controllers
+
+import (
+	"context"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestParseConfigLoaded(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	for _, tc := range []struct {
+		metrics string
+		loaded  bool
+	}{
+		{"# TYPE metallb_k8s_client_config_loaded_bool gauge\nmetallb_k8s_client_config_loaded_bool 1\nmetallb_k8s_client_config_stale_bool 0\n", true},
+		{"metallb_k8s_client_config_loaded_bool 1\n", true},
+		{"metallb_k8s_client_config_loaded_bool 0\nmetallb_k8s_client_config_stale_bool 0\n", false},
+		// Loaded once, the last configuration failed to load
+		{"metallb_k8s_client_config_loaded_bool 1\nmetallb_k8s_client_config_stale_bool 1\n", false},
+	} {
+		loaded, err := parseConfigLoaded(strings.NewReader(tc.metrics))
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(loaded).To(Equal(tc.loaded), tc.metrics)
+	}
+
+	_, err := parseConfigLoaded(strings.NewReader("go_goroutines 10\n"))
+	g.Expect(err).To(MatchError(ContainSubstring("metallb_k8s_client_config_loaded_bool not found")))
+	_, err = parseConfigLoaded(strings.NewReader("metallb_k8s_client_config_loaded_bool yes\n"))
+	g.Expect(err).To(HaveOccurred())
+}
+
+func TestHTTPSpeakerMetrics(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/metrics" {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprint(w, "metallb_k8s_client_config_loaded_bool 1\n")
+	}))
+	defer server.Close()
+	host, port, err := net.SplitHostPort(strings.TrimPrefix(server.URL, "http://"))
+	g.Expect(err).ToNot(HaveOccurred())
+
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "speaker-a"},
+		Spec: corev1.PodSpec{Containers: []corev1.Container{
+			{Name: "speaker", Args: []string{"--port=" + port, "--config=config"}},
+		}},
+		Status: corev1.PodStatus{HostIP: host},
+	}
+	metrics := NewHTTPSpeakerMetrics()
+	loaded, err := metrics.ConfigLoaded(context.Background(), pod)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(loaded).To(BeTrue())
+
+	// Not scheduled yet
+	pod.Status.HostIP = ""
+	_, err = metrics.ConfigLoaded(context.Background(), pod)
+	g.Expect(err).To(HaveOccurred())
+}
+
+func TestHTTPSpeakerMetricsRBACProxy(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer operator-token" {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		fmt.Fprint(w, "metallb_k8s_client_config_loaded_bool 1\nmetallb_k8s_client_config_stale_bool 1\n")
+	}))
+	defer server.Close()
+	host, port, err := net.SplitHostPort(strings.TrimPrefix(server.URL, "https://"))
+	g.Expect(err).ToNot(HaveOccurred())
+	proxyPort, err := strconv.Atoi(port)
+	g.Expect(err).ToNot(HaveOccurred())
+
+	dir, err := ioutil.TempDir("", "speaker-metrics")
+	g.Expect(err).ToNot(HaveOccurred())
+	defer os.RemoveAll(dir)
+	tokenFile := filepath.Join(dir, "token")
+	g.Expect(ioutil.WriteFile(tokenFile, []byte("operator-token\n"), 0600)).To(Succeed())
+
+	// The speaker only listens on the loopback, behind the proxy
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "speaker-a", Namespace: MetalLBTestNameSpace},
+		Spec: corev1.PodSpec{Containers: []corev1.Container{
+			{Name: "speaker", Args: []string{"--port=29150"}},
+			{Name: rbacProxyContainerName, Ports: []corev1.ContainerPort{{Name: rbacProxyPortName, ContainerPort: int32(proxyPort)}}},
+		}},
+		Status: corev1.PodStatus{HostIP: host},
+	}
+	metrics := NewHTTPSpeakerMetrics()
+	metrics.TokenFile = tokenFile
+	loaded, err := metrics.ConfigLoaded(context.Background(), pod)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(loaded).To(BeFalse())
+
+	// The certificate issued by the service CA is verified
+	pod.Spec.Volumes = []corev1.Volume{{
+		Name:         rbacProxyCertsVolume,
+		VolumeSource: corev1.VolumeSource{Secret: &corev1.SecretVolumeSource{SecretName: "speaker-metrics-certs"}},
+	}}
+	metrics.ServiceCAFile = filepath.Join(dir, "service-ca.crt")
+	g.Expect(ioutil.WriteFile(metrics.ServiceCAFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw}), 0600)).To(Succeed())
+	_, err = metrics.ConfigLoaded(context.Background(), pod)
+	g.Expect(err).To(MatchError(ContainSubstring("speaker-monitor-service." + MetalLBTestNameSpace + ".svc")))
+
+	// Nor is the scrape authorized without the token
+	metrics = NewHTTPSpeakerMetrics()
+	metrics.TokenFile = filepath.Join(dir, "missing")
+	pod.Spec.Volumes = nil
+	_, err = metrics.ConfigLoaded(context.Background(), pod)
+	g.Expect(err).To(HaveOccurred())
+}
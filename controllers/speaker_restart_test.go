@@ -0,0 +1,198 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/flowcontrol"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/metallb/metallb-operator/pkg/apply"
+)
+
+func TestSpeakerPodRestart(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	objs := append(speakerRevisions("v1"),
+		&corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: apply.AddressPoolConfigMap, Namespace: MetalLBTestNameSpace},
+			Data:       map[string]string{apply.AddressPoolConfigMap: "address-pools: []\n"},
+		},
+		speakerPod("failed-1", "v1", true),
+		speakerPod("failed-2", "v1", true),
+		speakerPod("loaded", "v1", true),
+		speakerPod("not-ready", "v1", false),
+		speakerPod("no-metrics", "v1", true),
+	)
+	reconciler := &SpeakerPodReconciler{
+		Client:         newFakeClientBuilder().WithScheme(testScheme(g)).WithObjects(objs...).Build(),
+		Log:            ctrl.Log.WithName("controllers").WithName("SpeakerPod"),
+		Namespace:      MetalLBTestNameSpace,
+		SpeakerMetrics: fakeSpeakerMetrics{"failed-1": false, "failed-2": false, "loaded": true, "not-ready": false},
+		// Allow a single restart
+		RestartLimiter: flowcontrol.NewTokenBucketRateLimiter(0.0001, 1),
+	}
+	for _, name := range []string{"failed-1", "failed-2", "loaded", "not-ready", "no-metrics"} {
+		reconcileSpeakerPod(g, reconciler, name)
+	}
+
+	g.Expect(speakerPodExists(g, reconciler, "failed-1")).To(BeFalse())
+	// The second pod is held back by the rate limit
+	g.Expect(speakerPodExists(g, reconciler, "failed-2")).To(BeTrue())
+	g.Expect(speakerPodExists(g, reconciler, "loaded")).To(BeTrue())
+	g.Expect(speakerPodExists(g, reconciler, "not-ready")).To(BeTrue())
+	// A pod whose load can't be verified is not restarted
+	g.Expect(speakerPodExists(g, reconciler, "no-metrics")).To(BeTrue())
+}
+
+func TestSpeakerPodRestartRollout(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	// The rollout of the v2 revision replaces the v1 pods, which still run the
+	// previous configuration
+	objs := append(speakerRevisions("v1", "v2"),
+		&corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: apply.AddressPoolConfigMap, Namespace: MetalLBTestNameSpace},
+			Data:       map[string]string{apply.AddressPoolConfigMap: "address-pools: []\n"},
+		},
+		speakerPod("speaker-1", "v2", true),
+		speakerPod("speaker-2", "v1", true),
+		speakerPod("speaker-3", "v1", true),
+	)
+	reconciler := &SpeakerPodReconciler{
+		Client:         newFakeClientBuilder().WithScheme(testScheme(g)).WithObjects(objs...).Build(),
+		Log:            ctrl.Log.WithName("controllers").WithName("SpeakerPod"),
+		Namespace:      MetalLBTestNameSpace,
+		SpeakerMetrics: fakeSpeakerMetrics{"speaker-1": true, "speaker-2": false, "speaker-3": false},
+	}
+	for _, name := range []string{"speaker-1", "speaker-2", "speaker-3"} {
+		reconcileSpeakerPod(g, reconciler, name)
+	}
+	for _, name := range []string{"speaker-1", "speaker-2", "speaker-3"} {
+		g.Expect(speakerPodExists(g, reconciler, name)).To(BeTrue(), name)
+	}
+
+	// Nor are the pods restarted while the revision is unknown
+	reconciler.Client = newFakeClientBuilder().WithScheme(testScheme(g)).WithObjects(objs[2:]...).Build()
+	reconcileSpeakerPod(g, reconciler, "speaker-1")
+	g.Expect(speakerPodExists(g, reconciler, "speaker-1")).To(BeTrue())
+}
+
+func TestSpeakerPodRestartConfigChange(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	configMap := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: apply.AddressPoolConfigMap, Namespace: MetalLBTestNameSpace},
+		Data:       map[string]string{apply.AddressPoolConfigMap: "address-pools: []\n"},
+	}
+	objs := append(speakerRevisions("v1"), configMap, speakerPod("speaker-1", "v1", true))
+	metrics := fakeSpeakerMetrics{"speaker-1": true}
+	reconciler := &SpeakerPodReconciler{
+		Client:         newFakeClientBuilder().WithScheme(testScheme(g)).WithObjects(objs...).Build(),
+		Log:            ctrl.Log.WithName("controllers").WithName("SpeakerPod"),
+		Namespace:      MetalLBTestNameSpace,
+		SpeakerMetrics: metrics,
+		GracePeriod:    time.Hour,
+	}
+
+	// The pod is only checked once given the grace period
+	key := types.NamespacedName{Name: "speaker-1", Namespace: MetalLBTestNameSpace}
+	result, err := reconciler.Reconcile(context.Background(), ctrl.Request{NamespacedName: key})
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(result.RequeueAfter).To(BeNumerically(">", 59*time.Minute))
+	reconciler.GracePeriod = 0
+	reconcileSpeakerPod(g, reconciler, "speaker-1")
+
+	// Once verified, the configuration is not checked again
+	metrics["speaker-1"] = false
+	reconcileSpeakerPod(g, reconciler, "speaker-1")
+	g.Expect(speakerPodExists(g, reconciler, "speaker-1")).To(BeTrue())
+
+	// The pod fails to load a new configuration it was given in place
+	g.Expect(reconciler.Get(context.Background(), types.NamespacedName{Name: configMap.Name, Namespace: configMap.Namespace}, configMap)).To(Succeed())
+	configMap.Data[apply.AddressPoolConfigMap] = "address-pools:\n- name: gold\n  protocol: layer2\n  addresses:\n  - 10.0.0.0/24\n"
+	g.Expect(reconciler.Update(context.Background(), configMap)).To(Succeed())
+	reconcileSpeakerPod(g, reconciler, "speaker-1")
+	g.Expect(speakerPodExists(g, reconciler, "speaker-1")).To(BeFalse())
+}
+
+func reconcileSpeakerPod(g *WithT, r *SpeakerPodReconciler, name string) {
+	_, err := r.Reconcile(context.Background(), ctrl.Request{
+		NamespacedName: types.NamespacedName{Name: name, Namespace: MetalLBTestNameSpace},
+	})
+	g.Expect(err).ToNot(HaveOccurred())
+}
+
+func speakerPodExists(g *WithT, c client.Client, name string) bool {
+	err := c.Get(context.Background(), types.NamespacedName{Name: name, Namespace: MetalLBTestNameSpace}, &corev1.Pod{})
+	g.Expect(client.IgnoreNotFound(err)).ToNot(HaveOccurred())
+	return !apierrors.IsNotFound(err)
+}
+
+// speakerTemplateChecksum returns the config checksum the operator set on the
+// pod template of the speaker DaemonSet.
+func speakerTemplateChecksum(g *WithT, c client.Client) string {
+	ds := &appsv1.DaemonSet{}
+	g.Expect(c.Get(context.Background(), types.NamespacedName{Name: "speaker", Namespace: MetalLBTestNameSpace}, ds)).To(Succeed())
+	checksum := ds.Spec.Template.Annotations[ConfigChecksumAnnotation]
+	g.Expect(checksum).ToNot(BeEmpty())
+	return checksum
+}
+
+// speakerRevisions returns the speaker DaemonSet and its ControllerRevisions
+// of the given hashes, the last one being the current revision.
+func speakerRevisions(hashes ...string) []client.Object {
+	ds := &appsv1.DaemonSet{
+		ObjectMeta: metav1.ObjectMeta{Name: "speaker", Namespace: MetalLBTestNameSpace, UID: "speaker-uid"},
+		Spec: appsv1.DaemonSetSpec{
+			Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"component": speakerComponentLabel}},
+		},
+	}
+	controller := true
+	objs := []client.Object{ds}
+	for i, hash := range hashes {
+		objs = append(objs, &appsv1.ControllerRevision{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "speaker-" + hash,
+				Namespace: MetalLBTestNameSpace,
+				Labels: map[string]string{
+					"component":                           speakerComponentLabel,
+					appsv1.DefaultDaemonSetUniqueLabelKey: hash,
+				},
+				OwnerReferences: []metav1.OwnerReference{{
+					APIVersion: "apps/v1", Kind: "DaemonSet", Name: ds.Name, UID: ds.UID, Controller: &controller,
+				}},
+			},
+			Revision: int64(i + 1),
+		})
+	}
+	return objs
+}
+
+func speakerPod(name, revision string, ready bool) *corev1.Pod {
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: MetalLBTestNameSpace,
+			UID:       types.UID(name),
+			Labels: map[string]string{
+				"component":                           speakerComponentLabel,
+				appsv1.DefaultDaemonSetUniqueLabelKey: revision,
+			},
+		},
+	}
+	status := corev1.ConditionFalse
+	if ready {
+		status = corev1.ConditionTrue
+	}
+	pod.Status.Conditions = []corev1.PodCondition{{Type: corev1.PodReady, Status: status}}
+	return pod
+}
@@ -18,7 +18,12 @@ package controllers
 
 import (
 	"context"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
 	"path/filepath"
+	"strconv"
 	"testing"
 
 	. "github.com/onsi/ginkgo"
@@ -41,12 +46,27 @@ import (
 
 const MetalLBTestNameSpace = "metallb-test-namespace"
 
+// testMaxAddressPools is the maximum number of AddressPools accepted by the reconciler under test
+const testMaxAddressPools = 3
+
+// restrictedUser is authenticated by restrictedToken on the secure port of the
+// test apiserver, where it is authorized by RBAC. The clients of the suite use
+// the insecure port, which skips the authorization.
+const (
+	restrictedUser  = "metallb-restricted"
+	restrictedToken = "metallb-restricted-token"
+)
+
 // These tests use Ginkgo (BDD-style Go testing framework). Refer to
 // http://onsi.github.io/ginkgo/ to learn more about Ginkgo.
 
 var cfg *rest.Config
 var k8sClient client.Client
 var testEnv *envtest.Environment
+var tokenDir string
+
+// restrictedCfg connects to the test apiserver as the restrictedUser.
+var restrictedCfg *rest.Config
 
 func TestAPIs(t *testing.T) {
 	RegisterFailHandler(Fail)
@@ -60,16 +80,30 @@ var _ = BeforeSuite(func() {
 	logf.SetLogger(zap.New(zap.WriteTo(GinkgoWriter), zap.UseDevMode(true)))
 
 	By("bootstrapping test environment")
+	var err error
+	tokenDir, err = ioutil.TempDir("", "metallb-envtest")
+	Expect(err).ToNot(HaveOccurred())
+	tokenFile := filepath.Join(tokenDir, "tokens.csv")
+	err = ioutil.WriteFile(tokenFile, []byte(fmt.Sprintf("%s,%s,%s\n", restrictedToken, restrictedUser, restrictedUser)), 0600)
+	Expect(err).ToNot(HaveOccurred())
+
 	testEnv = &envtest.Environment{
 		CRDDirectoryPaths:     []string{filepath.Join("..", "config", "crd", "bases")},
 		ErrorIfCRDPathMissing: true,
+		KubeAPIServerFlags: append(append([]string{}, envtest.DefaultKubeAPIServerFlags...),
+			"--authorization-mode=RBAC", "--token-auth-file="+tokenFile),
 	}
 
-	var err error
 	cfg, err = testEnv.Start()
 	Expect(err).ToNot(HaveOccurred())
 	Expect(cfg).ToNot(BeNil())
 
+	restrictedCfg = &rest.Config{
+		Host:            "https://" + net.JoinHostPort("127.0.0.1", strconv.Itoa(testEnv.ControlPlane.APIServer.SecurePort)),
+		BearerToken:     restrictedToken,
+		TLSClientConfig: rest.TLSClientConfig{Insecure: true},
+	}
+
 	err = metallbv1alpha1.AddToScheme(scheme.Scheme)
 	Expect(err).NotTo(HaveOccurred())
 
@@ -107,10 +141,20 @@ var _ = BeforeSuite(func() {
 
 	AddressPoolManifestPath = "../bindata/configuration/address-pool" // This is needed as the tests need to reference a directory backward
 	err = (&AddressPoolReconciler{
+		Client:          k8sClient,
+		Scheme:          scheme.Scheme,
+		Log:             ctrl.Log.WithName("controller").WithName("AddressPool"),
+		Namespace:       MetalLBTestNameSpace,
+		MaxAddressPools: testMaxAddressPools,
+	}).SetupWithManager(k8sManager)
+	Expect(err).ToNot(HaveOccurred())
+
+	err = (&SpeakerPodReconciler{
 		Client:    k8sClient,
-		Scheme:    scheme.Scheme,
-		Log:       ctrl.Log.WithName("controller").WithName("AddressPool"),
+		Log:       ctrl.Log.WithName("controller").WithName("SpeakerPod"),
 		Namespace: MetalLBTestNameSpace,
+		// The speakers of the tests failed to load their configuration
+		SpeakerMetrics: fakeSpeakerMetrics{"speaker-previous": false, "speaker-current": false},
 	}).SetupWithManager(k8sManager)
 	Expect(err).ToNot(HaveOccurred())
 
@@ -127,4 +171,5 @@ var _ = AfterSuite(func() {
 	AddressPoolManifestPath = "./bindata/configuration/address-pool"
 	err := testEnv.Stop()
 	Expect(err).ToNot(HaveOccurred())
+	Expect(os.RemoveAll(tokenDir)).To(Succeed())
 })
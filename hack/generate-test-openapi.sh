@@ -0,0 +1,21 @@
+#!/bin/bash
+
+. $(dirname "$0")/common.sh
+
+KUBERNETES_VERSION="v1.20.4"
+OPENAPI_URL="https://raw.githubusercontent.com/kubernetes/kubernetes/${KUBERNETES_VERSION}/api/openapi-spec/swagger.json"
+OPENAPI_FILE="test/manifests/testdata/swagger.json"
+# The kinds of the MetalLB manifests, the schema is trimmed to their definitions
+ROOT_DEFINITIONS='["io.k8s.api.apps.v1.DaemonSet", "io.k8s.api.apps.v1.Deployment", "io.k8s.api.policy.v1beta1.PodSecurityPolicy", "io.k8s.api.core.v1.Service", "io.k8s.api.core.v1.ConfigMap"]'
+
+curl ${OPENAPI_URL} -o _cache/swagger.json
+
+jq --argjson roots "${ROOT_DEFINITIONS}" '
+  .definitions as $defs
+  | def refs: [.. | objects | .["$ref"]? // empty | ltrimstr("#/definitions/")];
+    def closure: . as $names
+      | ([$names[] | $defs[.] | refs] | add + $names | unique) as $next
+      | if $next == $names then $names else $next | closure end;
+  ($roots | closure) as $names
+  | {swagger, info, paths: {}, definitions: ($defs | with_entries(select(.key as $k | $names | index($k))))}
+' _cache/swagger.json > ${OPENAPI_FILE}
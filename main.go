@@ -19,21 +19,17 @@ package main
 import (
 	"flag"
 	"os"
+	"strings"
+	"time"
 
-	appsv1 "k8s.io/api/apps/v1"
-	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
-	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
-	policyv1beta1 "k8s.io/kubernetes/pkg/apis/policy/v1beta1"
-	rbacv1 "k8s.io/kubernetes/pkg/apis/rbac/v1"
 
 	_ "k8s.io/client-go/plugin/pkg/client/auth/gcp"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
 
-	metallbv1alpha1 "github.com/metallb/metallb-operator/api/v1alpha1"
-	metallbv1beta1 "github.com/metallb/metallb-operator/api/v1beta1"
 	"github.com/metallb/metallb-operator/controllers"
 	"github.com/metallb/metallb-operator/pkg/platform"
 	// +kubebuilder:scaffold:imports
@@ -45,28 +41,72 @@ var (
 )
 
 func init() {
-	utilruntime.Must(clientgoscheme.AddToScheme(scheme))
-
-	utilruntime.Must(metallbv1alpha1.AddToScheme(scheme))
-	utilruntime.Must(metallbv1beta1.AddToScheme(scheme))
-	utilruntime.Must(corev1.AddToScheme(scheme))
-	utilruntime.Must(appsv1.AddToScheme(scheme))
-	utilruntime.Must(policyv1beta1.AddToScheme(scheme))
-	utilruntime.Must(rbacv1.AddToScheme(scheme))
+	utilruntime.Must(controllers.AddToScheme(scheme))
 
 	// +kubebuilder:scaffold:scheme
 }
 
+const (
+	// speakerRestartGracePeriod is how long a speaker is given to load a new configuration
+	speakerRestartGracePeriod = 2 * time.Minute
+	// speakerRestartQPS allows restarting one stuck speaker pod per minute
+	speakerRestartQPS = 1.0 / 60
+)
+
 // build is the git version of this program. It is set using build flags in the makefile.
 var build = "develop"
 
 func main() {
 	var metricsAddr string
 	var enableLeaderElection bool
+	var maxAddressPools int
+	var poolNamespaces string
+	var targetNamespaces string
+	var selfTest bool
+	var selfTestPool string
+	var enableWebhook bool
+	var checkPodCIDR bool
+	var configAppliedEvents bool
+	var poolMetrics bool
+	var reservedRangesConfigMap string
+	var configWriteFailureThreshold int
+	var canaryInterval time.Duration
+	var enableConfigExport bool
+	var configExportAddr string
 	flag.StringVar(&metricsAddr, "metrics-addr", ":0", "The address the metric endpoint binds to.")
 	flag.BoolVar(&enableLeaderElection, "enable-leader-election", false,
 		"Enable leader election for controller manager. "+
 			"Enabling this will ensure there is only one active controller manager.")
+	flag.IntVar(&maxAddressPools, "max-address-pools", 0,
+		"The maximum number of AddressPools rendered into the MetalLB configuration. "+
+			"Pools created past the limit are rejected, 0 means no limit.")
+	flag.StringVar(&poolNamespaces, "pool-namespaces", "",
+		"Comma separated list of the namespaces the AddressPools are collected from, or '*' for all the namespaces. "+
+			"Defaults to the operator namespace.")
+	flag.StringVar(&targetNamespaces, "target-namespaces", "",
+		"Comma separated list of the namespaces, besides the operator namespace, the MetalLB resource may deploy MetalLB to "+
+			"with its targetNamespace, or '*' for all the namespaces.")
+	flag.BoolVar(&selfTest, "self-test", false,
+		"Once MetalLB is available, check it assigns an address from the --self-test-pool AddressPool to a LoadBalancer service.")
+	flag.StringVar(&selfTestPool, "self-test-pool", "", "The AddressPool the self test requests an address from.")
+	flag.BoolVar(&checkPodCIDR, "check-pod-cidr", false,
+		"Report the AddressPools overlapping with the pod CIDR of a node as degraded.")
+	flag.BoolVar(&configAppliedEvents, "config-applied-events", false,
+		"Emit a ConfigApplied event on the MetalLB resource each time the MetalLB configuration changes.")
+	flag.BoolVar(&poolMetrics, "pool-metrics", false,
+		"Export the total and used addresses of each AddressPool as the metallb_pool_total_addresses and metallb_pool_used_addresses metrics.")
+	flag.StringVar(&reservedRangesConfigMap, "reserved-ranges-configmap", "",
+		"The ConfigMap of the operator namespace listing the reserved ranges, one per line in each key. The AddressPools overlapping with them are left out.")
+	flag.IntVar(&configWriteFailureThreshold, "config-write-failure-threshold", 3,
+		"The number of consecutive failed writes of the MetalLB configuration setting the ConfigWriteUnstable condition of the MetalLB resource, 0 disables it.")
+	flag.DurationVar(&canaryInterval, "canary-interval", 0,
+		"How often the canary enabled in the MetalLB resource is checked, e.g. 1m. The canary is not run when 0.")
+	flag.BoolVar(&enableConfigExport, "enable-config-export", false,
+		"Serve the MetalLB ConfigMaps and the resources they are rendered from as a YAML file at "+controllers.ConfigExportPath+", for support bundles.")
+	flag.StringVar(&configExportAddr, "config-export-addr", controllers.DefaultConfigExportAddr,
+		"The address the configuration export binds to. The default only serves it to the operator pod.")
+	flag.BoolVar(&enableWebhook, "enable-webhook", false,
+		"Serve the validating webhooks, this requires a serving certificate and the webhook configuration from config/webhook.")
 	flag.Parse()
 
 	ctrl.SetLogger(zap.New(zap.UseDevMode(true)))
@@ -77,14 +117,24 @@ func main() {
 	checkEnvVar("SPEAKER_IMAGE")
 	checkEnvVar("CONTROLLER_IMAGE")
 
-	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), ctrl.Options{
+	namespaces := parseNamespaces(poolNamespaces)
+	targets := parseNamespaces(targetNamespaces)
+	options := ctrl.Options{
 		Scheme:             scheme,
 		MetricsBindAddress: metricsAddr,
 		Port:               9443,
 		LeaderElection:     enableLeaderElection,
 		LeaderElectionID:   "metallb.io.metallboperator",
 		Namespace:          watchNamepace,
-	})
+	}
+	watched := append(append([]string{}, namespaces...), targets...)
+	if containsAllNamespaces(namespaces) || containsAllNamespaces(targets) {
+		options.Namespace = ""
+	} else if len(watched) > 0 {
+		options.NewCache = cache.MultiNamespacedCacheBuilder(append([]string{watchNamepace}, watched...))
+	}
+
+	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), options)
 	if err != nil {
 		setupLog.Error(err, "unable to start manager")
 		os.Exit(1)
@@ -96,27 +146,41 @@ func main() {
 		setupLog.Error(err, "unable to get platform name")
 		os.Exit(1)
 	}
+	if platformInfo.IsK3s() {
+		setupLog.Info("running on k3s, its ServiceLB must be disabled (--disable servicelb) for MetalLB to announce the services")
+	}
 
-	if err = (&controllers.MetalLBReconciler{
-		Client:       mgr.GetClient(),
-		Log:          ctrl.Log.WithName("controllers").WithName("MetalLB"),
-		Scheme:       mgr.GetScheme(),
-		PlatformInfo: platformInfo,
-		Namespace:    watchNamepace,
-	}).SetupWithManager(mgr); err != nil {
-		setupLog.Error(err, "unable to create controller", "controller", "MetalLB")
+	if selfTest && selfTestPool == "" {
+		setupLog.Error(nil, "--self-test-pool must be set to run the self test")
 		os.Exit(1)
 	}
-	if err = (&controllers.AddressPoolReconciler{
-		Client:    mgr.GetClient(),
-		Log:       ctrl.Log.WithName("controllers").WithName("AddressPool"),
-		Scheme:    mgr.GetScheme(),
-		Namespace: watchNamepace,
-	}).SetupWithManager(mgr); err != nil {
-		setupLog.Error(err, "unable to create controller", "controller", "AddressPool")
+	if !selfTest {
+		selfTestPool = ""
+	}
+	if !enableConfigExport {
+		configExportAddr = ""
+	}
+	if err = controllers.SetupAll(mgr, controllers.SetupOptions{
+		Namespace:                   watchNamepace,
+		PlatformInfo:                platformInfo,
+		MaxAddressPools:             maxAddressPools,
+		PoolNamespaces:              namespaces,
+		TargetNamespaces:            targets,
+		CheckPodCIDR:                checkPodCIDR,
+		ConfigAppliedEvents:         configAppliedEvents,
+		PoolMetrics:                 poolMetrics,
+		ReservedRangesConfigMap:     reservedRangesConfigMap,
+		ConfigWriteFailureThreshold: configWriteFailureThreshold,
+		SpeakerRestartGracePeriod:   speakerRestartGracePeriod,
+		SpeakerRestartQPS:           speakerRestartQPS,
+		SelfTestPool:                selfTestPool,
+		EnableWebhook:               enableWebhook,
+		CanaryInterval:              canaryInterval,
+		ConfigExportAddr:            configExportAddr,
+	}); err != nil {
+		setupLog.Error(err, "unable to set up the controllers")
 		os.Exit(1)
 	}
-	// +kubebuilder:scaffold:builder
 
 	setupLog.Info("starting manager")
 	if err := mgr.Start(ctrl.SetupSignalHandler()); err != nil {
@@ -133,3 +197,24 @@ func checkEnvVar(name string) string {
 	}
 	return value
 }
+
+// parseNamespaces parses the --pool-namespaces and --target-namespaces values.
+// A '*' anywhere in the list stands for all the namespaces.
+func parseNamespaces(value string) []string {
+	namespaces := []string{}
+	for _, ns := range strings.Split(value, ",") {
+		ns = strings.TrimSpace(ns)
+		if ns == "" {
+			continue
+		}
+		if ns == controllers.AllPoolNamespaces {
+			return []string{controllers.AllPoolNamespaces}
+		}
+		namespaces = append(namespaces, ns)
+	}
+	return namespaces
+}
+
+func containsAllNamespaces(namespaces []string) bool {
+	return len(namespaces) == 1 && namespaces[0] == controllers.AllPoolNamespaces
+}
@@ -0,0 +1,173 @@
+package addresses
+
+import (
+	"bytes"
+	"fmt"
+	"math/big"
+	"net"
+	"strings"
+
+	metallbv1alpha1 "github.com/metallb/metallb-operator/api/v1alpha1"
+)
+
+// ParseRange parses an AddressPool range, either a CIDR prefix or an explicit
+// start-end range, and returns its first and last addresses.
+func ParseRange(r string) (net.IP, net.IP, error) {
+	r = strings.TrimSpace(r)
+	if strings.Contains(r, "/") {
+		_, cidr, err := net.ParseCIDR(r)
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid CIDR %q: %v", r, err)
+		}
+		first := cidr.IP.To16()
+		last := make(net.IP, len(cidr.IP))
+		for i := range cidr.IP {
+			last[i] = cidr.IP[i] | ^cidr.Mask[i]
+		}
+		return first, last.To16(), nil
+	}
+
+	parts := strings.Split(r, "-")
+	if len(parts) != 2 {
+		return nil, nil, fmt.Errorf("invalid IP range %q", r)
+	}
+	first := net.ParseIP(strings.TrimSpace(parts[0]))
+	if first == nil {
+		return nil, nil, fmt.Errorf("invalid start IP %q in range %q", parts[0], r)
+	}
+	last := net.ParseIP(strings.TrimSpace(parts[1]))
+	if last == nil {
+		return nil, nil, fmt.Errorf("invalid end IP %q in range %q", parts[1], r)
+	}
+	if bytes.Compare(first.To16(), last.To16()) > 0 {
+		return nil, nil, fmt.Errorf("invalid IP range %q: start is after end", r)
+	}
+	return first.To16(), last.To16(), nil
+}
+
+// Families returns whether the ranges hold IPv4 and IPv6 addresses. The
+// ranges that can't be parsed are skipped.
+func Families(ranges []string) (ipv4 bool, ipv6 bool) {
+	for _, r := range ranges {
+		first, _, err := ParseRange(r)
+		if err != nil {
+			continue
+		}
+		if first.To4() != nil {
+			ipv4 = true
+		} else {
+			ipv6 = true
+		}
+	}
+	return ipv4, ipv6
+}
+
+// Overlap returns whether the two ranges, CIDR prefixes or start-end ranges,
+// have addresses in common.
+func Overlap(a, b string) (bool, error) {
+	firstA, lastA, err := ParseRange(a)
+	if err != nil {
+		return false, err
+	}
+	firstB, lastB, err := ParseRange(b)
+	if err != nil {
+		return false, err
+	}
+	return bytes.Compare(firstA, lastB) <= 0 && bytes.Compare(firstB, lastA) <= 0, nil
+}
+
+// FindPool returns the name of the pool the given IP belongs to. Ranges that
+// can't be parsed are ignored.
+func FindPool(ip net.IP, pools []metallbv1alpha1.AddressPool) (string, bool) {
+	ip = ip.To16()
+	if ip == nil {
+		return "", false
+	}
+	for _, pool := range pools {
+		for _, r := range pool.Spec.Addresses {
+			first, last, err := ParseRange(r)
+			if err != nil {
+				continue
+			}
+			if bytes.Compare(ip, first) >= 0 && bytes.Compare(ip, last) <= 0 {
+				return pool.Name, true
+			}
+		}
+	}
+	return "", false
+}
+
+// PoolSize returns the number of addresses of the given pool. Ranges that
+// can't be parsed are ignored.
+func PoolSize(pool metallbv1alpha1.AddressPool) *big.Int {
+	size := big.NewInt(0)
+	for _, r := range pool.Spec.Addresses {
+		first, last, err := ParseRange(r)
+		if err != nil {
+			continue
+		}
+		n := new(big.Int).Sub(new(big.Int).SetBytes(last), new(big.Int).SetBytes(first))
+		size.Add(size, n.Add(n, big.NewInt(1)))
+	}
+	return size
+}
+
+// NextCIDR returns the first CIDR of the given prefix length within the
+// supernet which does not overlap with any of the used ranges. Used ranges
+// that can't be parsed are ignored.
+func NextCIDR(supernet string, prefixLen int, used []string) (string, error) {
+	_, super, err := net.ParseCIDR(strings.TrimSpace(supernet))
+	if err != nil {
+		return "", fmt.Errorf("invalid supernet %q: %v", supernet, err)
+	}
+	ones, bits := super.Mask.Size()
+	if prefixLen < ones || prefixLen > bits {
+		return "", fmt.Errorf("invalid prefix length %d for the supernet %s", prefixLen, super)
+	}
+
+	first, last, err := ParseRange(super.String())
+	if err != nil {
+		return "", err
+	}
+	type bounds struct{ first, last *big.Int }
+	ranges := []bounds{}
+	for _, r := range used {
+		f, l, err := ParseRange(r)
+		if err != nil {
+			continue
+		}
+		ranges = append(ranges, bounds{new(big.Int).SetBytes(f), new(big.Int).SetBytes(l)})
+	}
+
+	size := new(big.Int).Lsh(big.NewInt(1), uint(bits-prefixLen))
+	start := new(big.Int).SetBytes(first)
+	end := new(big.Int).SetBytes(last)
+	one := big.NewInt(1)
+	for {
+		candidateLast := new(big.Int).Sub(new(big.Int).Add(start, size), one)
+		if candidateLast.Cmp(end) > 0 {
+			return "", fmt.Errorf("no free /%d left in the supernet %s", prefixLen, super)
+		}
+		overlap := false
+		for _, r := range ranges {
+			if r.first.Cmp(candidateLast) <= 0 && start.Cmp(r.last) <= 0 {
+				// Move past the used range, to the next CIDR boundary
+				start = new(big.Int).Add(r.last, one)
+				if rem := new(big.Int).Mod(start, size); rem.Sign() != 0 {
+					start.Add(start, new(big.Int).Sub(size, rem))
+				}
+				overlap = true
+				break
+			}
+		}
+		if !overlap {
+			break
+		}
+	}
+
+	ip := net.IP(start.FillBytes(make([]byte, net.IPv6len)))
+	if super.IP.To4() != nil {
+		ip = ip.To4()
+	}
+	return (&net.IPNet{IP: ip, Mask: net.CIDRMask(prefixLen, bits)}).String(), nil
+}
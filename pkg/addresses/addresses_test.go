@@ -0,0 +1,169 @@
+package addresses
+
+import (
+	"net"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	metallbv1alpha1 "github.com/metallb/metallb-operator/api/v1alpha1"
+)
+
+func TestFindPool(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	pools := []metallbv1alpha1.AddressPool{
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "cidr"},
+			Spec:       metallbv1alpha1.AddressPoolSpec{Addresses: []string{"10.0.0.0/24"}},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "range"},
+			Spec:       metallbv1alpha1.AddressPoolSpec{Addresses: []string{"not-an-ip", "192.168.1.10-192.168.1.20"}},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "v6"},
+			Spec:       metallbv1alpha1.AddressPoolSpec{Addresses: []string{"2001:db8::/120"}},
+		},
+	}
+
+	tests := []struct {
+		ip       string
+		pool     string
+		expected bool
+	}{
+		{ip: "10.0.0.42", pool: "cidr", expected: true},
+		{ip: "10.0.0.0", pool: "cidr", expected: true},
+		{ip: "10.0.0.255", pool: "cidr", expected: true},
+		{ip: "10.0.1.0", expected: false},
+		{ip: "192.168.1.10", pool: "range", expected: true},
+		{ip: "192.168.1.20", pool: "range", expected: true},
+		{ip: "192.168.1.9", expected: false},
+		{ip: "192.168.1.21", expected: false},
+		{ip: "2001:db8::ff", pool: "v6", expected: true},
+		{ip: "2001:db8::100", expected: false},
+	}
+
+	for _, test := range tests {
+		pool, found := FindPool(net.ParseIP(test.ip), pools)
+		g.Expect(found).To(Equal(test.expected), test.ip)
+		g.Expect(pool).To(Equal(test.pool), test.ip)
+	}
+}
+
+func TestParseRange(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	first, last, err := ParseRange("10.0.0.0/30")
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(first.String()).To(Equal("10.0.0.0"))
+	g.Expect(last.String()).To(Equal("10.0.0.3"))
+
+	first, last, err = ParseRange("192.168.1.10 - 192.168.1.20")
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(first.String()).To(Equal("192.168.1.10"))
+	g.Expect(last.String()).To(Equal("192.168.1.20"))
+
+	for _, invalid := range []string{"10.0.0.0/33", "192.168.1.20-192.168.1.10", "192.168.1.10", "a-b"} {
+		_, _, err := ParseRange(invalid)
+		g.Expect(err).To(HaveOccurred(), invalid)
+	}
+}
+
+func TestPoolSize(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	pool := metallbv1alpha1.AddressPool{
+		Spec: metallbv1alpha1.AddressPoolSpec{Addresses: []string{"10.0.0.0/30", "192.168.1.10-192.168.1.20", "not-an-ip"}},
+	}
+	g.Expect(PoolSize(pool).String()).To(Equal("15"))
+
+	pool.Spec.Addresses = []string{"2001:db8::/64"}
+	g.Expect(PoolSize(pool).String()).To(Equal("18446744073709551616"))
+}
+
+func TestOverlap(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	tests := []struct {
+		a, b     string
+		expected bool
+	}{
+		{a: "10.244.0.0/16", b: "10.244.1.10-10.244.1.20", expected: true},
+		{a: "10.244.0.0/16", b: "10.245.0.0/24", expected: false},
+		{a: "10.0.0.1-10.0.0.10", b: "10.0.0.10-10.0.0.20", expected: true},
+		{a: "10.0.0.1-10.0.0.10", b: "10.0.0.11-10.0.0.20", expected: false},
+		{a: "fd00:10:244::/56", b: "fd00:10:244:1::/64", expected: true},
+		{a: "fd00:10:244::/56", b: "10.244.0.0/16", expected: false},
+	}
+	for _, test := range tests {
+		overlap, err := Overlap(test.a, test.b)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(overlap).To(Equal(test.expected), "%s %s", test.a, test.b)
+	}
+
+	_, err := Overlap("10.0.0.0/33", "10.0.0.0/24")
+	g.Expect(err).To(HaveOccurred())
+}
+
+func TestFamilies(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	tests := []struct {
+		ranges     []string
+		ipv4, ipv6 bool
+	}{
+		{ranges: []string{"10.0.0.0/24", "10.0.1.1-10.0.1.10"}, ipv4: true},
+		{ranges: []string{"fc00::/64", "fc00:1::1-fc00:1::10"}, ipv6: true},
+		{ranges: []string{"10.0.0.0/24", "fc00::/64"}, ipv4: true, ipv6: true},
+		{ranges: []string{"not-a-range", "fc00::/64"}, ipv6: true},
+		{ranges: nil},
+	}
+	for _, test := range tests {
+		ipv4, ipv6 := Families(test.ranges)
+		g.Expect(ipv4).To(Equal(test.ipv4), "%v", test.ranges)
+		g.Expect(ipv6).To(Equal(test.ipv6), "%v", test.ranges)
+	}
+}
+
+func TestNextCIDR(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	tests := []struct {
+		desc      string
+		supernet  string
+		prefixLen int
+		used      []string
+		expected  string
+	}{
+		{desc: "empty supernet", supernet: "10.0.0.0/24", prefixLen: 28, expected: "10.0.0.0/28"},
+		{desc: "first block used", supernet: "10.0.0.0/24", prefixLen: 28, used: []string{"10.0.0.0/28"}, expected: "10.0.0.16/28"},
+		{desc: "unaligned range", supernet: "10.0.0.0/24", prefixLen: 28, used: []string{"10.0.0.5-10.0.0.20"}, expected: "10.0.0.32/28"},
+		{
+			desc: "several ranges", supernet: "10.0.0.0/24", prefixLen: 28,
+			used:     []string{"10.0.0.32/27", "10.0.0.0/28", "192.168.0.0/16", "10.0.0.16-10.0.0.17"},
+			expected: "10.0.0.64/28",
+		},
+		{desc: "ipv6", supernet: "2001:db8::/64", prefixLen: 120, used: []string{"2001:db8::/121"}, expected: "2001:db8::100/120"},
+		{desc: "unparsable used range", supernet: "10.0.0.0/24", prefixLen: 30, used: []string{"not-a-range"}, expected: "10.0.0.0/30"},
+	}
+	for _, test := range tests {
+		cidr, err := NextCIDR(test.supernet, test.prefixLen, test.used)
+		g.Expect(err).ToNot(HaveOccurred(), test.desc)
+		g.Expect(cidr).To(Equal(test.expected), test.desc)
+		for _, used := range test.used {
+			overlap, err := Overlap(cidr, used)
+			if err == nil {
+				g.Expect(overlap).To(BeFalse(), test.desc)
+			}
+		}
+	}
+
+	_, err := NextCIDR("10.0.0.0/30", 31, []string{"10.0.0.0/31", "10.0.0.2-10.0.0.3"})
+	g.Expect(err).To(MatchError(ContainSubstring("no free /31")))
+	_, err = NextCIDR("10.0.0.0/24", 16, nil)
+	g.Expect(err).To(HaveOccurred())
+	_, err = NextCIDR("10.0.0.0", 28, nil)
+	g.Expect(err).To(HaveOccurred())
+}
@@ -2,6 +2,9 @@ package apply
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"fmt"
 	"log"
 
@@ -51,21 +54,34 @@ func findOrCreateObject(ctx context.Context, client k8sclient.Client, obj *uns.U
 // ApplyObject applies the desired object against the apiserver,
 // merging it with any existing objects if already present.
 func ApplyObject(ctx context.Context, client k8sclient.Client, obj *uns.Unstructured) error {
+	return applyObject(ctx, client, obj, MergeObjectForUpdate)
+}
 
-	existing, objDesc, err := findOrCreateObject(ctx, client, obj)
+// ReplaceObject applies the desired object like ApplyObject, except that the
+// MetalLB configuration of a ConfigMap replaces the existing one, see
+// MergeObjectForOverwrite.
+func ReplaceObject(ctx context.Context, client k8sclient.Client, obj *uns.Unstructured) error {
+	return applyObject(ctx, client, obj, MergeObjectForOverwrite)
+}
 
-	if existing == nil {
-		return nil
-	}
+func applyObject(ctx context.Context, client k8sclient.Client, obj *uns.Unstructured, merge func(current, updated *uns.Unstructured) error) error {
+	existing, objDesc, err := findOrCreateObject(ctx, client, obj)
 
 	if err != nil {
 		return errors.Wrapf(err, "could not retrieve existing %s", objDesc)
 	}
 
+	if existing == nil {
+		return nil
+	}
+
 	// Merge the desired object with what actually exists
-	if err := MergeObjectForUpdate(existing, obj); err != nil {
+	if err := merge(existing, obj); err != nil {
 		return errors.Wrapf(err, "could not merge object %s with existing", objDesc)
 	}
+	if isUpToDate(existing, obj) {
+		return nil
+	}
 	if !equality.Semantic.DeepEqual(existing, obj) {
 		if err := client.Update(ctx, obj); err != nil {
 			return errors.Wrapf(err, "could not update object %s", objDesc)
@@ -85,12 +101,12 @@ func ApplyObjects(ctx context.Context, client k8sclient.Client, objs []*uns.Unst
 	var err error
 
 	existing, objDesc, err = findOrCreateObject(ctx, client, objs[0])
-	if existing == nil {
-		return nil
-	}
 	if err != nil {
 		return errors.Wrapf(err, "could not retrieve existing %s", objDesc)
 	}
+	if existing == nil {
+		return nil
+	}
 
 	for _, obj := range objs {
 		if lastObj != nil {
@@ -112,3 +128,92 @@ func ApplyObjects(ctx context.Context, client k8sclient.Client, objs []*uns.Unst
 
 	return nil
 }
+
+// DesiredHashAnnotation holds the hash of an object as rendered by the
+// operator, see SetDesiredHash.
+const DesiredHashAnnotation = "metallb.io/desired-hash"
+
+// SetDesiredHash annotates the desired object with its hash. ApplyObject then
+// leaves the existing object alone when it was applied from the same desired
+// state and still holds all of its fields, instead of updating it to drop the
+// fields the API server defaulted.
+func SetDesiredHash(obj *uns.Unstructured) error {
+	annotations := obj.GetAnnotations()
+	delete(annotations, DesiredHashAnnotation)
+	obj.SetAnnotations(annotations)
+
+	data, err := json.Marshal(obj.Object)
+	if err != nil {
+		return errors.Wrapf(err, "could not hash %s/%s", obj.GetNamespace(), obj.GetName())
+	}
+	sum := sha256.Sum256(data)
+	if annotations == nil {
+		annotations = map[string]string{}
+	}
+	annotations[DesiredHashAnnotation] = hex.EncodeToString(sum[:])
+	obj.SetAnnotations(annotations)
+	return nil
+}
+
+// isUpToDate tells whether the existing object was applied from the same
+// desired state as the merged one, and none of the fields it sets drifted.
+func isUpToDate(existing, merged *uns.Unstructured) bool {
+	hash, ok := merged.GetAnnotations()[DesiredHashAnnotation]
+	if !ok || existing.GetAnnotations()[DesiredHashAnnotation] != hash {
+		return false
+	}
+	return isSubset(merged.Object, existing.Object)
+}
+
+// isSubset tells whether all the fields set in desired have the same value in
+// current. Lists must have the same length, their items are compared in order.
+func isSubset(desired, current interface{}) bool {
+	switch d := desired.(type) {
+	case nil:
+		return true
+	case map[string]interface{}:
+		c, ok := current.(map[string]interface{})
+		if !ok {
+			return len(d) == 0 && current == nil
+		}
+		for k, v := range d {
+			if !isSubset(v, c[k]) {
+				return false
+			}
+		}
+		return true
+	case []interface{}:
+		c, ok := current.([]interface{})
+		if !ok {
+			return len(d) == 0 && current == nil
+		}
+		if len(c) != len(d) {
+			return false
+		}
+		for i := range d {
+			if !isSubset(d[i], c[i]) {
+				return false
+			}
+		}
+		return true
+	}
+	if d, ok := toFloat(desired); ok {
+		c, ok := toFloat(current)
+		return ok && c == d
+	}
+	return equality.Semantic.DeepEqual(desired, current)
+}
+
+// toFloat converts the numbers of an unstructured object, which are decoded
+// as int64 or float64 depending on the decoder.
+func toFloat(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case int64:
+		return float64(n), true
+	case int:
+		return float64(n), true
+	case float64:
+		return n, true
+	}
+	return 0, false
+}
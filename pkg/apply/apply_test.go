@@ -0,0 +1,83 @@
+package apply
+
+import (
+	"context"
+	"testing"
+
+	uns "k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+const desiredDeployment = `
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: controller
+  namespace: metallb-system
+spec:
+  template:
+    spec:
+      hostAliases:
+      - ip: 10.0.0.1
+        hostnames:
+        - metallb.local
+      containers:
+      - name: controller
+        image: metallb/controller
+`
+
+func TestApplyObjectDesiredHash(t *testing.T) {
+	c := fake.NewClientBuilder().WithScheme(scheme.Scheme).Build()
+	apply := func(obj *uns.Unstructured) string {
+		if err := SetDesiredHash(obj); err != nil {
+			t.Fatal(err)
+		}
+		if err := ApplyObject(context.Background(), c, obj); err != nil {
+			t.Fatal(err)
+		}
+		existing := &uns.Unstructured{}
+		existing.SetGroupVersionKind(obj.GroupVersionKind())
+		if err := c.Get(context.Background(), types.NamespacedName{Name: "controller", Namespace: "metallb-system"}, existing); err != nil {
+			t.Fatal(err)
+		}
+		return existing.GetResourceVersion()
+	}
+
+	version := apply(UnstructuredFromYaml(t, desiredDeployment))
+	if newVersion := apply(UnstructuredFromYaml(t, desiredDeployment)); newVersion != version {
+		t.Errorf("unchanged object updated, resource version %s, expected %s", newVersion, version)
+	}
+
+	// Removing a field changes the hash, the object is updated
+	changed := UnstructuredFromYaml(t, desiredDeployment)
+	uns.RemoveNestedField(changed.Object, "spec", "template", "spec", "hostAliases")
+	if newVersion := apply(changed); newVersion == version {
+		t.Errorf("changed object not updated")
+	}
+}
+
+func TestIsSubset(t *testing.T) {
+	tests := []struct {
+		desc     string
+		desired  interface{}
+		current  interface{}
+		expected bool
+	}{
+		{"same", map[string]interface{}{"a": "b"}, map[string]interface{}{"a": "b"}, true},
+		{"defaulted field", map[string]interface{}{"a": "b"}, map[string]interface{}{"a": "b", "c": "d"}, true},
+		{"changed field", map[string]interface{}{"a": "b"}, map[string]interface{}{"a": "c"}, false},
+		{"missing field", map[string]interface{}{"a": "b"}, map[string]interface{}{}, false},
+		{"null field", map[string]interface{}{"a": nil}, map[string]interface{}{"a": "b"}, true},
+		{"empty map", map[string]interface{}{"a": map[string]interface{}{}}, map[string]interface{}{}, true},
+		{"number types", map[string]interface{}{"a": int64(1)}, map[string]interface{}{"a": float64(1)}, true},
+		{"list length", []interface{}{"a"}, []interface{}{"a", "b"}, false},
+		{"list items", []interface{}{map[string]interface{}{"a": "b"}}, []interface{}{map[string]interface{}{"a": "b", "c": "d"}}, true},
+	}
+	for _, test := range tests {
+		if res := isSubset(test.desired, test.current); res != test.expected {
+			t.Errorf("%s: isSubset returned %v, expected %v", test.desc, res, test.expected)
+		}
+	}
+}
@@ -1,6 +1,10 @@
 package apply
 
 import (
+	"fmt"
+	"reflect"
+	"strconv"
+
 	metallbv1alpha "github.com/metallb/metallb-operator/api/v1alpha1"
 	"github.com/pkg/errors"
 	"gopkg.in/yaml.v2"
@@ -27,12 +31,27 @@ func mergeMetadataForUpdate(current, updated *uns.Unstructured) error {
 
 const (
 	AddressPoolConfigMap = "config"
+	// ConfigGenerationLabel is incremented each time the content of the
+	// MetalLB ConfigMap changes.
+	ConfigGenerationLabel = "metallb.io/config-generation"
 )
 
 // MergeObjectForUpdate prepares a "desired" object to be updated.
 // Some objects, such as Deployments and Services require
 // some semantic-aware updates
 func MergeObjectForUpdate(current, updated *uns.Unstructured) error {
+	return mergeObject(current, updated, true)
+}
+
+// MergeObjectForOverwrite prepares a "desired" object to be updated like
+// MergeObjectForUpdate, except that the MetalLB configuration of a ConfigMap
+// replaces the current one: the pools only present in the current ConfigMap
+// are dropped rather than kept.
+func MergeObjectForOverwrite(current, updated *uns.Unstructured) error {
+	return mergeObject(current, updated, false)
+}
+
+func mergeObject(current, updated *uns.Unstructured, keepCurrentPools bool) error {
 	if err := mergeDeploymentForUpdate(current, updated); err != nil {
 		return err
 	}
@@ -45,7 +64,7 @@ func MergeObjectForUpdate(current, updated *uns.Unstructured) error {
 		return err
 	}
 
-	if err := mergeConfigMapForUpdate(current, updated); err != nil {
+	if err := mergeConfigMapForUpdate(current, updated, keepCurrentPools); err != nil {
 		return err
 	}
 
@@ -209,17 +228,40 @@ func mergeLabels(current, updated *uns.Unstructured) {
 	}
 }
 
-func mergeConfigMapForUpdate(current, updated *uns.Unstructured) error {
-	type configMapData struct {
-		AddressPools []metallbv1alpha.AddressPoolSpec `yaml:"address-pools"`
+// configMapData is the MetalLB configuration held by the ConfigMap. The peers,
+// the BFD profiles and the communities are kept as rendered.
+type configMapData struct {
+	Peers          []yaml.MapSlice                  `yaml:"peers,omitempty"`
+	BFDProfiles    []yaml.MapSlice                  `yaml:"bfd-profiles,omitempty"`
+	BGPCommunities yaml.MapSlice                    `yaml:"bgp-communities,omitempty"`
+	AddressPools   []metallbv1alpha.AddressPoolSpec `yaml:"address-pools"`
+}
+
+// ConfigPoolNames returns the names of the pools of a MetalLB configuration.
+func ConfigPoolNames(config string) ([]string, error) {
+	data := configMapData{}
+	if err := yaml.Unmarshal([]byte(config), &data); err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(data.AddressPools))
+	for _, pool := range data.AddressPools {
+		names = append(names, pool.Name)
 	}
+	return names, nil
+}
 
+// mergeConfigMapForUpdate merges the MetalLB configuration of the current
+// ConfigMap into the updated one, keeping the pools only present in the
+// current one when keepCurrentPools is set.
+func mergeConfigMapForUpdate(current, updated *uns.Unstructured, keepCurrentPools bool) error {
 	if gvk := updated.GroupVersionKind(); gvk.Kind != "ConfigMap" || gvk.Group != "" {
 		return nil
 	}
 
-	s1, ok, err := uns.NestedString(current.Object, "data", AddressPoolConfigMap)
-	if !ok || err != nil {
+	// A current ConfigMap without the configuration, e.g. removed by an editor,
+	// is merged as an empty one so the whole configuration is rendered again.
+	s1, _, err := uns.NestedString(current.Object, "data", AddressPoolConfigMap)
+	if err != nil {
 		return err
 	}
 
@@ -238,18 +280,36 @@ func mergeConfigMapForUpdate(current, updated *uns.Unstructured) error {
 		return err
 	}
 
+	normalizeAutoAssign(st1.AddressPools)
+	normalizeAutoAssign(st2.AddressPools)
+
 	var mergedConfigMap configMapData
 
-	for i, a1 := range st1.AddressPools {
-		for j := len(st2.AddressPools) - 1; j >= 0; j-- {
-			if st2.AddressPools[j].Name == a1.Name {
-				st1.AddressPools[i] = *st2.AddressPools[j].DeepCopy()
-				st2.AddressPools = append(st2.AddressPools[:j], st2.AddressPools[j+1:]...)
+	// Pools only present in the current ConfigMap are kept first, unless they
+	// reference a community no longer rendered, the updated pools follow in
+	// the order they were rendered.
+	if keepCurrentPools {
+		updatedPools := make(map[string]bool, len(st2.AddressPools))
+		for _, a2 := range st2.AddressPools {
+			updatedPools[a2.Name] = true
+		}
+		communities := make(map[string]bool, len(st2.BGPCommunities))
+		for _, c := range st2.BGPCommunities {
+			communities[fmt.Sprint(c.Key)] = true
+		}
+		for _, a1 := range st1.AddressPools {
+			if !updatedPools[a1.Name] && referencesCommunities(a1, communities) {
+				mergedConfigMap.AddressPools = append(mergedConfigMap.AddressPools, a1)
 			}
 		}
 	}
 
-	mergedConfigMap.AddressPools = append(st1.AddressPools, st2.AddressPools...)
+	mergedConfigMap.AddressPools = append(mergedConfigMap.AddressPools, st2.AddressPools...)
+	// The rendered peers, profiles and communities are all of them, the ones
+	// only present in the current ConfigMap were deleted.
+	mergedConfigMap.Peers = st2.Peers
+	mergedConfigMap.BFDProfiles = st2.BFDProfiles
+	mergedConfigMap.BGPCommunities = st2.BGPCommunities
 
 	resData, err := yaml.Marshal(mergedConfigMap)
 	if err != nil {
@@ -258,8 +318,37 @@ func mergeConfigMapForUpdate(current, updated *uns.Unstructured) error {
 
 	data := make(map[string]string)
 	data[AddressPoolConfigMap] = string(resData)
-	err = uns.SetNestedStringMap(updated.Object, data, "data")
-	return err
+	if err := uns.SetNestedStringMap(updated.Object, data, "data"); err != nil {
+		return err
+	}
+
+	generation, ok := current.GetLabels()[ConfigGenerationLabel]
+	if !reflect.DeepEqual(st1.AddressPools, mergedConfigMap.AddressPools) ||
+		!reflect.DeepEqual(st1.Peers, mergedConfigMap.Peers) || !reflect.DeepEqual(st1.BFDProfiles, mergedConfigMap.BFDProfiles) ||
+		!reflect.DeepEqual(st1.BGPCommunities, mergedConfigMap.BGPCommunities) {
+		// A missing or invalid label restarts the count
+		value, _ := strconv.Atoi(generation)
+		generation = strconv.Itoa(value + 1)
+	} else if !ok {
+		return nil
+	}
+	labels := updated.GetLabels()
+	if labels == nil {
+		labels = map[string]string{}
+	}
+	labels[ConfigGenerationLabel] = generation
+	updated.SetLabels(labels)
+	return nil
+}
+
+// normalizeAutoAssign drops an explicit auto-assign: true, the MetalLB default
+// an unset autoAssign renders to, so both compare and render the same.
+func normalizeAutoAssign(pools []metallbv1alpha.AddressPoolSpec) {
+	for i := range pools {
+		if autoAssign := pools[i].AutoAssign; autoAssign != nil && *autoAssign {
+			pools[i].AutoAssign = nil
+		}
+	}
 }
 
 // IsObjectSupported rejects objects with configurations we don't support.
@@ -284,3 +373,16 @@ func IsObjectSupported(obj *uns.Unstructured) error {
 
 	return nil
 }
+
+// referencesCommunities returns whether all the communities referenced by name
+// by the given pool are in the given ones.
+func referencesCommunities(pool metallbv1alpha.AddressPoolSpec, communities map[string]bool) bool {
+	for _, adv := range pool.BGPAdvertisements {
+		for _, c := range adv.Communities {
+			if c.Name != "" && !communities[c.Name] {
+				return false
+			}
+		}
+	}
+	return true
+}
@@ -419,3 +419,527 @@ data:
   auto-assign: false
 `))
 }
+
+func TestMergeConfigMapKeepsUpdatedOrder(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	cur := UnstructuredFromYaml(t, `
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: config
+  namespace: metallb-system
+data:
+  config: |
+    address-pools:
+    - name: red
+      protocol: layer2
+      addresses:
+      - 172.40.0.100/24
+    - name: blue
+      protocol: layer2
+      addresses:
+      - 172.20.0.100/24
+    - name: green
+      protocol: layer2
+      addresses:
+      - 172.10.0.100/24`)
+
+	upd := UnstructuredFromYaml(t, `
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: config
+  namespace: metallb-system
+data:
+  config: |
+    address-pools:
+    - name: green
+      protocol: layer2
+      addresses:
+      - 172.10.0.100/24
+    - name: blue
+      protocol: layer2
+      addresses:
+      - 172.20.0.100/24`)
+
+	err := MergeObjectForUpdate(cur, upd)
+	g.Expect(err).NotTo(HaveOccurred())
+	configmap, _, err := uns.NestedStringMap(upd.Object, "data")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(configmap[AddressPoolConfigMap]).Should(MatchYAML(`address-pools:
+- name: red
+  protocol: layer2
+  addresses:
+  - 172.40.0.100/24
+- name: green
+  protocol: layer2
+  addresses:
+  - 172.10.0.100/24
+- name: blue
+  protocol: layer2
+  addresses:
+  - 172.20.0.100/24
+`))
+}
+
+func TestMergeConfigMapGeneration(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	cur := UnstructuredFromYaml(t, `
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: config
+  namespace: metallb-system
+  labels:
+    metallb.io/config-generation: "3"
+data:
+  config: |
+    address-pools:
+    - name: gold
+      protocol: layer2
+      addresses:
+      - 172.20.0.100/24`)
+
+	upd := UnstructuredFromYaml(t, `
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: config
+  namespace: metallb-system
+  labels:
+    metallb.io/config-generation: "1"
+data:
+  config: |
+    address-pools:
+    - name: gold
+      protocol: layer2
+      addresses:
+      - 172.20.0.100/24`)
+
+	err := MergeObjectForUpdate(cur, upd)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(upd.GetLabels()).To(HaveKeyWithValue(ConfigGenerationLabel, "3"))
+
+	upd = UnstructuredFromYaml(t, `
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: config
+  namespace: metallb-system
+  labels:
+    metallb.io/config-generation: "1"
+data:
+  config: |
+    address-pools:
+    - name: gold
+      protocol: layer2
+      addresses:
+      - 172.20.0.100/28`)
+	err = MergeObjectForUpdate(cur, upd)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(upd.GetLabels()).To(HaveKeyWithValue(ConfigGenerationLabel, "4"))
+
+	// Applying the same content again must not bump the generation
+	cur = upd
+	upd = UnstructuredFromYaml(t, `
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: config
+  namespace: metallb-system
+  labels:
+    metallb.io/config-generation: "1"
+data:
+  config: |
+    address-pools:
+    - name: gold
+      protocol: layer2
+      addresses:
+      - 172.20.0.100/28`)
+	err = MergeObjectForUpdate(cur, upd)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(upd.GetLabels()).To(HaveKeyWithValue(ConfigGenerationLabel, "4"))
+}
+
+func TestMergeConfigMapMissingKey(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	cur := UnstructuredFromYaml(t, `
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: config
+  namespace: metallb-system
+  labels:
+    metallb.io/config-generation: "3"
+data: {}`)
+
+	upd := UnstructuredFromYaml(t, `
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: config
+  namespace: metallb-system
+  labels:
+    metallb.io/config-generation: "1"
+data:
+  config: |
+    address-pools:
+    - name: gold
+      protocol: layer2
+      addresses:
+      - 172.20.0.100/24`)
+
+	err := MergeObjectForUpdate(cur, upd)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	config, ok, err := uns.NestedString(upd.Object, "data", AddressPoolConfigMap)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(ok).To(BeTrue())
+	g.Expect(config).To(MatchYAML(`address-pools:
+- name: gold
+  protocol: layer2
+  addresses:
+  - 172.20.0.100/24
+`))
+	// Restoring the configuration is a change of configuration
+	g.Expect(upd.GetLabels()).To(HaveKeyWithValue(ConfigGenerationLabel, "4"))
+}
+
+func TestMergeConfigMapExplicitAutoAssign(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	cur := UnstructuredFromYaml(t, `
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: config
+  namespace: metallb-system
+  labels:
+    metallb.io/config-generation: "3"
+data:
+  config: |
+    address-pools:
+    - name: gold
+      protocol: layer2
+      addresses:
+      - 172.20.0.100/24
+      auto-assign: true
+    - name: silver
+      protocol: layer2
+      addresses:
+      - 172.30.0.100/24
+      auto-assign: false`)
+
+	upd := UnstructuredFromYaml(t, `
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: config
+  namespace: metallb-system
+  labels:
+    metallb.io/config-generation: "1"
+data:
+  config: |
+    address-pools:
+    - name: gold
+      protocol: layer2
+      addresses:
+      - 172.20.0.100/24
+    - name: silver
+      protocol: layer2
+      addresses:
+      - 172.30.0.100/24
+      auto-assign: false`)
+
+	err := MergeObjectForUpdate(cur, upd)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	config, ok, err := uns.NestedString(upd.Object, "data", AddressPoolConfigMap)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(ok).To(BeTrue())
+	g.Expect(config).To(MatchYAML(`address-pools:
+- name: gold
+  protocol: layer2
+  addresses:
+  - 172.20.0.100/24
+- name: silver
+  protocol: layer2
+  addresses:
+  - 172.30.0.100/24
+  auto-assign: false
+`))
+	// An explicit auto-assign: true is the default, not a change of configuration
+	g.Expect(upd.GetLabels()).To(HaveKeyWithValue(ConfigGenerationLabel, "3"))
+}
+
+func TestMergeConfigMapBGPAdvertisements(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	cur := UnstructuredFromYaml(t, `
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: config
+  namespace: metallb-system
+  labels:
+    metallb.io/config-generation: "2"
+data:
+  config: |
+    address-pools:
+    - name: gold
+      protocol: bgp
+      addresses:
+      - 172.20.0.100/24`)
+
+	upd := UnstructuredFromYaml(t, `
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: config
+  namespace: metallb-system
+  labels:
+    metallb.io/config-generation: "1"
+data:
+  config: |
+    address-pools:
+    - name: gold
+      protocol: bgp
+      addresses:
+      - 172.20.0.100/24
+      bgp-advertisements:
+      - aggregation-length: 24
+        localpref: 100
+        communities:
+        - 64512:100
+      - {}
+    - name: silver
+      protocol: layer2
+      addresses:
+      - 172.30.0.100/24`)
+
+	err := MergeObjectForUpdate(cur, upd)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	config, ok, err := uns.NestedString(upd.Object, "data", AddressPoolConfigMap)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(ok).To(BeTrue())
+	// The advertisements survive the merge, the layer2 pool is left without
+	g.Expect(config).To(MatchYAML(`address-pools:
+- name: gold
+  protocol: bgp
+  addresses:
+  - 172.20.0.100/24
+  bgp-advertisements:
+  - aggregation-length: 24
+    localpref: 100
+    communities:
+    - 64512:100
+  - {}
+- name: silver
+  protocol: layer2
+  addresses:
+  - 172.30.0.100/24
+`))
+	g.Expect(upd.GetLabels()).To(HaveKeyWithValue(ConfigGenerationLabel, "3"))
+}
+
+func TestMergeConfigMapPeers(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	configMap := func(generation, config string) *uns.Unstructured {
+		obj := UnstructuredFromYaml(t, `
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: config
+  namespace: metallb-system
+  labels:
+    metallb.io/config-generation: "`+generation+`"`)
+		g.Expect(uns.SetNestedField(obj.Object, config, "data", AddressPoolConfigMap)).To(Succeed())
+		return obj
+	}
+	pools := `address-pools:
+- name: gold
+  protocol: bgp
+  addresses:
+  - 172.20.0.100/24
+`
+	peers := `peers:
+- my-asn: 64512
+  peer-asn: 64513
+  peer-address: 10.0.0.1
+  peer-port: 1179
+  hold-time: 1m30s
+`
+
+	// The rendered peers replace the current ones
+	cur := configMap("2", pools)
+	upd := configMap("1", peers+pools)
+	g.Expect(MergeObjectForUpdate(cur, upd)).To(Succeed())
+	config, _, err := uns.NestedString(upd.Object, "data", AddressPoolConfigMap)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(config).To(MatchYAML(peers + pools))
+	g.Expect(upd.GetLabels()).To(HaveKeyWithValue(ConfigGenerationLabel, "3"))
+
+	// Unchanged peers keep the generation
+	cur = configMap("3", peers+pools)
+	upd = configMap("1", peers+pools)
+	g.Expect(MergeObjectForUpdate(cur, upd)).To(Succeed())
+	g.Expect(upd.GetLabels()).To(HaveKeyWithValue(ConfigGenerationLabel, "3"))
+
+	// Once the last peer is deleted, the peers key is removed
+	cur = configMap("3", peers+pools)
+	upd = configMap("1", pools)
+	g.Expect(MergeObjectForUpdate(cur, upd)).To(Succeed())
+	config, _, err = uns.NestedString(upd.Object, "data", AddressPoolConfigMap)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(config).To(MatchYAML(pools))
+	g.Expect(config).NotTo(ContainSubstring("peers"))
+	g.Expect(upd.GetLabels()).To(HaveKeyWithValue(ConfigGenerationLabel, "4"))
+}
+
+func TestMergeConfigMapCommunities(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	configMap := func(config string) *uns.Unstructured {
+		obj := UnstructuredFromYaml(t, `
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: config
+  namespace: metallb-system`)
+		g.Expect(uns.SetNestedField(obj.Object, config, "data", AddressPoolConfigMap)).To(Succeed())
+		return obj
+	}
+	communities := `bgp-communities:
+  premium: "64512:100"
+`
+	gold := `- name: gold
+  protocol: bgp
+  addresses:
+  - 172.20.0.100/24
+  bgp-advertisements:
+  - communities:
+    - premium
+`
+	silver := `- name: silver
+  protocol: layer2
+  addresses:
+  - 172.20.1.100/24
+`
+
+	// The rendered communities replace the current ones
+	cur := configMap("address-pools:\n" + gold)
+	upd := configMap(communities + "address-pools:\n" + gold)
+	g.Expect(MergeObjectForUpdate(cur, upd)).To(Succeed())
+	config, _, err := uns.NestedString(upd.Object, "data", AddressPoolConfigMap)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(config).To(MatchYAML(communities + "address-pools:\n" + gold))
+
+	// A current pool referencing a community no longer rendered is dropped
+	cur = configMap(communities + "address-pools:\n" + gold + silver)
+	upd = configMap("address-pools:\n" + silver)
+	g.Expect(MergeObjectForUpdate(cur, upd)).To(Succeed())
+	config, _, err = uns.NestedString(upd.Object, "data", AddressPoolConfigMap)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(config).To(MatchYAML("address-pools:\n" + silver))
+}
+
+func TestOverwriteConfigMap(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	cur := UnstructuredFromYaml(t, `
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: config
+  namespace: metallb-system
+  labels:
+    metallb.io/config-generation: "1"
+data:
+  config: |
+    address-pools:
+    - name: red
+      protocol: layer2
+      addresses:
+      - 172.40.0.100/24
+    - name: blue
+      protocol: layer2
+      addresses:
+      - 172.20.0.100/24
+    - name: green
+      protocol: layer2
+      addresses:
+      - 172.10.0.100/24`)
+
+	upd := UnstructuredFromYaml(t, `
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: config
+  namespace: metallb-system
+data:
+  config: |
+    address-pools:
+    - name: green
+      protocol: layer2
+      addresses:
+      - 172.10.0.100/24
+    - name: blue
+      protocol: layer2
+      addresses:
+      - 172.20.0.100/24`)
+
+	// The pools only present in the current ConfigMap are dropped
+	err := MergeObjectForOverwrite(cur, upd)
+	g.Expect(err).NotTo(HaveOccurred())
+	configmap, _, err := uns.NestedStringMap(upd.Object, "data")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(configmap[AddressPoolConfigMap]).Should(MatchYAML(`address-pools:
+- name: green
+  protocol: layer2
+  addresses:
+  - 172.10.0.100/24
+- name: blue
+  protocol: layer2
+  addresses:
+  - 172.20.0.100/24
+`))
+	g.Expect(upd.GetLabels()).To(HaveKeyWithValue(ConfigGenerationLabel, "2"))
+}
+
+func TestOverwriteConfigMapUnchanged(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	config := `address-pools:
+- name: gold
+  protocol: layer2
+  addresses:
+  - 172.20.0.100/24
+  auto-assign: false
+`
+	configMap := func() *uns.Unstructured {
+		obj := UnstructuredFromYaml(t, `
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: config
+  namespace: metallb-system
+  labels:
+    metallb.io/config-generation: "3"`)
+		g.Expect(uns.SetNestedField(obj.Object, config, "data", AddressPoolConfigMap)).To(Succeed())
+		return obj
+	}
+
+	// An unchanged configuration keeps the current generation
+	upd := configMap()
+	upd.SetLabels(nil)
+	g.Expect(MergeObjectForOverwrite(configMap(), upd)).To(Succeed())
+	merged, _, err := uns.NestedString(upd.Object, "data", AddressPoolConfigMap)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(merged).To(MatchYAML(config))
+	g.Expect(upd.GetLabels()).To(HaveKeyWithValue(ConfigGenerationLabel, "3"))
+}
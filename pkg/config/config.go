@@ -0,0 +1,68 @@
+package config
+
+import (
+	"fmt"
+
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+
+	metallbv1alpha1 "github.com/metallb/metallb-operator/api/v1alpha1"
+	metallbv1beta1 "github.com/metallb/metallb-operator/api/v1beta1"
+	"github.com/metallb/metallb-operator/pkg/apply"
+	"github.com/metallb/metallb-operator/pkg/render"
+)
+
+// ManifestPath is the directory of the MetalLB ConfigMap template used by Render.
+var ManifestPath = "./bindata/configuration/address-pool"
+
+// Render returns the MetalLB configuration the operator renders into the
+// ConfigMap for the given pools and peers, without a cluster. The pools are
+// sorted by name, and the peers are checked against the native BGP backend.
+// The pools and peers the operator would leave out are returned as an error
+// instead, as are the peers with a passwordSecret, the Secrets not being read.
+func Render(pools []metallbv1alpha1.AddressPool, peers []metallbv1alpha1.BGPPeer) (string, error) {
+	config, errs := render.MergePools(pools, nil)
+	var peerErrs []error
+	config.Peers, peerErrs = render.MergePeers(peers, nil, nil, "")
+	if err := utilerrors.NewAggregate(append(errs, peerErrs...)); err != nil {
+		return "", err
+	}
+	SortPools(config.Pools, metallbv1beta1.PoolSortByName)
+
+	obj, err := RenderConfigMap(ManifestPath, config, "", apply.AddressPoolConfigMap)
+	if err != nil {
+		return "", err
+	}
+	data, _, err := unstructured.NestedString(obj.Object, "data", apply.AddressPoolConfigMap)
+	if err != nil {
+		return "", errors.Wrap(err, "invalid rendered ConfigMap")
+	}
+	return data, nil
+}
+
+// RenderConfigMap renders the given configuration into the MetalLB ConfigMap
+// of the given namespace and name, from the template of the given directory.
+// The pools are rendered in the order they are given. A configuration with
+// dangling references, which MetalLB would reject, is not rendered and all
+// the references are returned as an error.
+func RenderConfigMap(manifestDir string, config render.MetalLBConfig, namespace, name string) (*unstructured.Unstructured, error) {
+	if err := utilerrors.NewAggregate(render.ValidateCombined(config.Pools, config.Peers, config.BFDProfiles, config.Communities)); err != nil {
+		return nil, errors.Wrap(err, "invalid MetalLB configuration")
+	}
+	data := render.MakeRenderData()
+	data.Data["Peers"] = config.Peers
+	data.Data["BFDProfiles"] = config.BFDProfiles
+	data.Data["Communities"] = config.Communities
+	data.Data["Pools"] = config.Pools
+	data.Data["NameSpace"] = namespace
+	data.Data["ConfigMapName"] = name
+	rendered, err := render.RenderDir(manifestDir, &data)
+	if err != nil {
+		return nil, fmt.Errorf("Fail to render address-pool manifest err %v", err)
+	}
+	if len(rendered) != 1 {
+		return nil, fmt.Errorf("Fail to render we are expecting only one object and get %d", len(rendered))
+	}
+	return rendered[0], nil
+}
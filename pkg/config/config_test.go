@@ -0,0 +1,99 @@
+package config
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	metallbv1alpha1 "github.com/metallb/metallb-operator/api/v1alpha1"
+	"github.com/metallb/metallb-operator/pkg/render"
+	"github.com/metallb/metallb-operator/test/manifests"
+)
+
+func TestRender(t *testing.T) {
+	g := NewGomegaWithT(t)
+	manifestPath := ManifestPath
+	ManifestPath = "../../bindata/configuration/address-pool"
+	defer func() { ManifestPath = manifestPath }()
+
+	autoAssign := false
+	pools := []metallbv1alpha1.AddressPool{
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "silver", Namespace: "metallb-system"},
+			Spec: metallbv1alpha1.AddressPoolSpec{
+				Protocol:   "layer2",
+				Addresses:  []string{"10.0.1.0/24"},
+				AutoAssign: &autoAssign,
+			},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "gold", Namespace: "metallb-system"},
+			Spec: metallbv1alpha1.AddressPoolSpec{
+				Protocol:  "bgp",
+				Addresses: []string{"10.0.0.0/24"},
+			},
+		},
+	}
+	peers := []metallbv1alpha1.BGPPeer{
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "tor", Namespace: "metallb-system"},
+			Spec:       metallbv1alpha1.BGPPeerSpec{MyASN: 64512, PeerASN: 64513, PeerAddress: "10.0.0.1"},
+		},
+	}
+
+	config, err := Render(pools, peers)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(config).To(MatchYAML(`peers:
+- my-asn: 64512
+  peer-asn: 64513
+  peer-address: 10.0.0.1
+address-pools:
+- name: gold
+  protocol: bgp
+  addresses:
+  - 10.0.0.0/24
+- name: silver
+  protocol: layer2
+  addresses:
+  - 10.0.1.0/24
+  auto-assign: false
+`))
+	g.Expect(manifests.ValidateMetalLBConfig(config)).To(Succeed())
+
+	config, err = Render(nil, nil)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(config).To(MatchYAML("address-pools:\n"))
+
+	// The pools the operator would leave out are reported
+	pools = append(pools, metallbv1alpha1.AddressPool{
+		ObjectMeta: metav1.ObjectMeta{Name: "bronze", Namespace: "metallb-system"},
+		Spec: metallbv1alpha1.AddressPoolSpec{
+			Protocol:  "layer2",
+			Addresses: []string{"10.0.0.128/25"},
+		},
+	})
+	_, err = Render(pools, peers)
+	g.Expect(err).To(MatchError(ContainSubstring("bronze")))
+}
+
+func TestRenderConfigMapDanglingReferences(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	// The merged configurations never hold dangling references, the check
+	// keeps a bug from writing a configuration MetalLB would reject
+	config := render.MetalLBConfig{
+		Peers: []render.PeerConfig{{MyASN: 64512, PeerASN: 64513, PeerAddress: "10.0.0.1", BFDProfile: "fast"}},
+		Pools: []render.PoolConfig{{Name: "gold", Protocol: "bgp", Addresses: []string{"10.0.0.0/24"}, AutoAssign: true,
+			BGPAdvertisements: []metallbv1alpha1.BGPAdvertisement{{Communities: []metallbv1alpha1.BGPCommunity{{Name: "premium"}}}}}},
+	}
+	_, err := RenderConfigMap("../../bindata/configuration/address-pool", config, "metallb-system", "config")
+	g.Expect(err).To(MatchError(ContainSubstring(`peer 10.0.0.1: unknown bfd profile "fast"`)))
+	g.Expect(err).To(MatchError(ContainSubstring(`address pool gold: unknown community "premium"`)))
+
+	config.BFDProfiles = []render.BFDProfileConfig{{Name: "fast"}}
+	config.Communities = []render.CommunityConfig{{Name: "premium", Value: "64512:100"}}
+	obj, err := RenderConfigMap("../../bindata/configuration/address-pool", config, "metallb-system", "config")
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(obj.GetName()).To(Equal("config"))
+}
@@ -0,0 +1,44 @@
+package config
+
+import (
+	"bytes"
+	"net"
+	"sort"
+
+	metallbv1beta1 "github.com/metallb/metallb-operator/api/v1beta1"
+	"github.com/metallb/metallb-operator/pkg/addresses"
+	"github.com/metallb/metallb-operator/pkg/render"
+)
+
+// SortPools sorts the pools in the given order. Pools sharing the same
+// first address, or whose first address can't be parsed, are sorted by name.
+func SortPools(pools []render.PoolConfig, order string) {
+	sort.SliceStable(pools, func(i, j int) bool {
+		if order == metallbv1beta1.PoolSortByAddress {
+			ipI, ipJ := firstAddress(pools[i].Addresses), firstAddress(pools[j].Addresses)
+			switch {
+			case ipI == nil && ipJ != nil:
+				return false
+			case ipI != nil && ipJ == nil:
+				return true
+			case ipI != nil && ipJ != nil:
+				if c := bytes.Compare(ipI, ipJ); c != 0 {
+					return c < 0
+				}
+			}
+		}
+		return pools[i].Name < pools[j].Name
+	})
+}
+
+// firstAddress returns the first IP of the first range of the pool.
+func firstAddress(ranges []string) net.IP {
+	if len(ranges) == 0 {
+		return nil
+	}
+	first, _, err := addresses.ParseRange(ranges[0])
+	if err != nil {
+		return nil
+	}
+	return first
+}
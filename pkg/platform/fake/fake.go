@@ -0,0 +1,53 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package fake provides a platform for the tests, without discovering it from
+// a cluster.
+package fake
+
+import (
+	"github.com/metallb/metallb-operator/pkg/platform"
+)
+
+// PlatformInfo is a fake platform, it records how many times it was checked.
+type PlatformInfo struct {
+	Name platform.PlatformType
+	// ServiceMonitors is whether the platform serves the Prometheus Operator API
+	ServiceMonitors bool
+	// Checks is the number of calls to IsOpenShift
+	Checks int
+}
+
+// NewOpenShift returns a fake OpenShift platform.
+func NewOpenShift() *PlatformInfo {
+	return &PlatformInfo{Name: platform.OpenShift}
+}
+
+// NewKubernetes returns a fake Kubernetes platform.
+func NewKubernetes() *PlatformInfo {
+	return &PlatformInfo{Name: platform.Kubernetes}
+}
+
+func (p *PlatformInfo) IsOpenShift() bool {
+	p.Checks++
+	return p.Name == platform.OpenShift
+}
+
+func (p *PlatformInfo) HasServiceMonitors() bool {
+	return p.ServiceMonitors
+}
+
+func (p *PlatformInfo) String() string {
+	return "FakePlatformInfo [Name: " + string(p.Name) + "]"
+}
@@ -15,7 +15,14 @@ limitations under the License.
 package platform
 
 import (
+	"context"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/rest"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client/config"
@@ -23,13 +30,25 @@ import (
 
 var log = ctrl.Log.WithName("platform")
 
-type k8SBasedPlatformVersioner struct{}
+// clusterVersionResource is the OpenShift ClusterVersion, its "version"
+// instance holds the version of the cluster.
+var clusterVersionResource = schema.GroupVersionResource{Group: "config.openshift.io", Version: "v1", Resource: "clusterversions"}
+
+// +kubebuilder:rbac:groups=config.openshift.io,resources=clusterversions,verbs=get
+
+type k8SBasedPlatformVersioner struct {
+	// dynamicClient gets the ClusterVersion, it is created from the rest
+	// config when nil.
+	dynamicClient dynamic.Interface
+}
 
 /*
 GetPlatformInfo examines the Kubernetes-based environment and determines the running platform, version, & OS.
 Accepts <nil> or instantiated 'cfg' rest config parameter.
 
-Result: PlatformInfo{ Name: OpenShift, K8SVersion: 1.13+, OS: linux/amd64 }
+Result: PlatformInfo{ Name: OpenShift, K8SVersion: 1.13+, OCPVersion: 4.7.0, OS: linux/amd64 }
+
+The Name is one of OpenShift, K3s, RKE2 or Kubernetes.
 */
 func GetPlatformInfo(cfg *rest.Config) (PlatformInfo, error) {
 	return k8SBasedPlatformVersioner{}.getPlatformInfo(nil, cfg)
@@ -71,7 +90,7 @@ func (pv k8SBasedPlatformVersioner) getPlatformInfo(client discovery.DiscoveryIn
 	info := PlatformInfo{Name: Kubernetes}
 
 	var err error
-	client, _, err = pv.defaultArgs(client, cfg)
+	client, cfg, err = pv.defaultArgs(client, cfg)
 	if err != nil {
 		log.Info("issue occurred while defaulting client/cfg args")
 		return info, err
@@ -99,6 +118,60 @@ func (pv k8SBasedPlatformVersioner) getPlatformInfo(client discovery.DiscoveryIn
 			break
 		}
 	}
+	for _, v := range apiList.Groups {
+		if v.Name == "monitoring.coreos.com" {
+			log.Info("monitoring.coreos.com found in apis, ServiceMonitors are supported")
+			info.ServiceMonitors = true
+			break
+		}
+	}
+
+	if info.Name == OpenShift {
+		info.OCPVersion, err = pv.getOCPVersion(cfg)
+		if err != nil {
+			log.Info("issue occurred while fetching ClusterVersion")
+			return info, err
+		}
+	}
+
+	// The k3s and RKE2 builds tag their version, k3s.cattle.io is served by both
+	if info.Name == Kubernetes {
+		switch {
+		case strings.Contains(k8sVersion.GitVersion, "+rke2"):
+			log.Info("rke2 found in the server version, platform is RKE2")
+			info.Name = RKE2
+		case strings.Contains(k8sVersion.GitVersion, "+k3s"):
+			log.Info("k3s found in the server version, platform is K3s")
+			info.Name = K3s
+		default:
+			for _, v := range apiList.Groups {
+				if v.Name == "k3s.cattle.io" {
+					log.Info("k3s.cattle.io found in apis, platform is K3s")
+					info.Name = K3s
+					break
+				}
+			}
+		}
+	}
 	log.Info(info.String())
 	return info, nil
 }
+
+// getOCPVersion returns the OpenShift version the cluster runs, as reported
+// by the ClusterVersion.
+func (pv k8SBasedPlatformVersioner) getOCPVersion(cfg *rest.Config) (string, error) {
+	client := pv.dynamicClient
+	if client == nil {
+		var err error
+		client, err = dynamic.NewForConfig(cfg)
+		if err != nil {
+			return "", err
+		}
+	}
+	clusterVersion, err := client.Resource(clusterVersionResource).Get(context.Background(), "version", metav1.GetOptions{})
+	if err != nil {
+		return "", err
+	}
+	version, _, err := unstructured.NestedString(clusterVersion.Object, "status", "desired", "version")
+	return version, err
+}
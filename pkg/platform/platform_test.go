@@ -0,0 +1,78 @@
+package platform
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/version"
+	fakediscovery "k8s.io/client-go/discovery/fake"
+	fakedynamic "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/rest"
+	clienttesting "k8s.io/client-go/testing"
+)
+
+func TestGetPlatformInfo(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	for _, tc := range []struct {
+		gitVersion string
+		groups     []string
+		expected   PlatformType
+	}{
+		{"v1.20.4", []string{"apps"}, Kubernetes},
+		{"v1.20.0+bd9e442", []string{"apps", "route.openshift.io"}, OpenShift},
+		{"v1.20.4+k3s1", []string{"apps", "k3s.cattle.io"}, K3s},
+		{"v1.20.4", []string{"apps", "k3s.cattle.io", "helm.cattle.io"}, K3s},
+		{"v1.20.4+rke2r1", []string{"apps", "k3s.cattle.io", "helm.cattle.io"}, RKE2},
+	} {
+		resources := []*metav1.APIResourceList{}
+		for _, group := range tc.groups {
+			resources = append(resources, &metav1.APIResourceList{GroupVersion: group + "/v1"})
+		}
+		client := &fakediscovery.FakeDiscovery{
+			Fake:               &clienttesting.Fake{Resources: resources},
+			FakedServerVersion: &version.Info{Major: "1", Minor: "20", GitVersion: tc.gitVersion, Platform: "linux/amd64"},
+		}
+
+		clusterVersion := &unstructured.Unstructured{Object: map[string]interface{}{
+			"apiVersion": "config.openshift.io/v1",
+			"kind":       "ClusterVersion",
+			"metadata":   map[string]interface{}{"name": "version"},
+			"status":     map[string]interface{}{"desired": map[string]interface{}{"version": "4.7.0"}},
+		}}
+		versioner := k8SBasedPlatformVersioner{dynamicClient: fakedynamic.NewSimpleDynamicClient(runtime.NewScheme(), clusterVersion)}
+
+		info, err := versioner.getPlatformInfo(client, &rest.Config{})
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(info.Name).To(Equal(tc.expected), tc.gitVersion)
+		g.Expect(info.K8SVersion).To(Equal("1.20"))
+		if tc.expected == OpenShift {
+			g.Expect(info.OCPVersion).To(Equal("4.7.0"))
+		} else {
+			g.Expect(info.OCPVersion).To(BeEmpty())
+		}
+		g.Expect(info.IsK3s()).To(Equal(tc.expected == K3s))
+		g.Expect(info.IsRKE2()).To(Equal(tc.expected == RKE2))
+		g.Expect(info.IsOpenShift()).To(Equal(tc.expected == OpenShift))
+		g.Expect(info.HasServiceMonitors()).To(BeFalse())
+	}
+}
+
+func TestGetPlatformInfoServiceMonitors(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	client := &fakediscovery.FakeDiscovery{
+		Fake: &clienttesting.Fake{Resources: []*metav1.APIResourceList{
+			{GroupVersion: "apps/v1"},
+			{GroupVersion: "monitoring.coreos.com/v1"},
+		}},
+		FakedServerVersion: &version.Info{Major: "1", Minor: "20", GitVersion: "v1.20.4"},
+	}
+	info, err := k8SBasedPlatformVersioner{}.getPlatformInfo(client, &rest.Config{})
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(info.Name).To(Equal(Kubernetes))
+	g.Expect(info.HasServiceMonitors()).To(BeTrue())
+}
@@ -21,21 +21,43 @@ type PlatformType string
 const (
 	OpenShift  PlatformType = "OpenShift"
 	Kubernetes PlatformType = "Kubernetes"
+	// K3s has its own service load balancer, ServiceLB, which clashes with MetalLB
+	// unless disabled.
+	K3s  PlatformType = "K3s"
+	RKE2 PlatformType = "RKE2"
 )
 
+// PlatformInfo describes the platform, the OCPVersion is set on OpenShift only.
+// ServiceMonitors is set when the Prometheus Operator API is served.
 type PlatformInfo struct {
-	Name       PlatformType `json:"name"`
-	K8SVersion string       `json:"k8sVersion"`
-	OS         string       `json:"os"`
+	Name            PlatformType `json:"name"`
+	K8SVersion      string       `json:"k8sVersion"`
+	OCPVersion      string       `json:"ocpVersion,omitempty"`
+	OS              string       `json:"os"`
+	ServiceMonitors bool         `json:"serviceMonitors"`
 }
 
 func (info PlatformInfo) IsOpenShift() bool {
 	return info.Name == OpenShift
 }
 
+func (info PlatformInfo) HasServiceMonitors() bool {
+	return info.ServiceMonitors
+}
+
+func (info PlatformInfo) IsK3s() bool {
+	return info.Name == K3s
+}
+
+func (info PlatformInfo) IsRKE2() bool {
+	return info.Name == RKE2
+}
+
 func (info PlatformInfo) String() string {
 	return "PlatformInfo [" +
 		"Name: " + fmt.Sprintf("%v", info.Name) +
 		", K8SVersion: " + info.K8SVersion +
-		", OS: " + info.OS + "]"
+		", OCPVersion: " + info.OCPVersion +
+		", OS: " + info.OS +
+		", ServiceMonitors: " + fmt.Sprintf("%v", info.ServiceMonitors) + "]"
 }
@@ -0,0 +1,67 @@
+package render
+
+import (
+	"fmt"
+	"sort"
+
+	metallbv1alpha1 "github.com/metallb/metallb-operator/api/v1alpha1"
+)
+
+// BFDProfileConfig is a BFD profile of the MetalLB configuration, the unset
+// fields are not rendered.
+type BFDProfileConfig struct {
+	Name             string
+	ReceiveInterval  *uint32
+	TransmitInterval *uint32
+	DetectMultiplier *uint32
+	EchoInterval     *uint32
+	EchoMode         bool
+	PassiveMode      bool
+	MinimumTTL       *uint32
+}
+
+// BFDProfileError reports a BFDProfile left out of the MetalLB configuration.
+type BFDProfileError struct {
+	Namespace string
+	Name      string
+	Err       error
+}
+
+func (e *BFDProfileError) Error() string {
+	return fmt.Sprintf("bfdprofile %s/%s: %v", e.Namespace, e.Name, e.Err)
+}
+
+func (e *BFDProfileError) Unwrap() error {
+	return e.Err
+}
+
+// MergeBFDProfiles merges the BFDProfiles into the profiles of a MetalLB
+// configuration, by name. A profile failing its validation is left out and
+// reported with a BFDProfileError.
+func MergeBFDProfiles(profiles []metallbv1alpha1.BFDProfile) ([]BFDProfileConfig, []error) {
+	sorted := make([]metallbv1alpha1.BFDProfile, len(profiles))
+	copy(sorted, profiles)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Name < sorted[j].Name
+	})
+
+	configs := []BFDProfileConfig{}
+	var errs []error
+	for _, profile := range sorted {
+		if err := profile.Validate(); err != nil {
+			errs = append(errs, &BFDProfileError{Namespace: profile.Namespace, Name: profile.Name, Err: err})
+			continue
+		}
+		configs = append(configs, BFDProfileConfig{
+			Name:             profile.Name,
+			ReceiveInterval:  profile.Spec.ReceiveInterval,
+			TransmitInterval: profile.Spec.TransmitInterval,
+			DetectMultiplier: profile.Spec.DetectMultiplier,
+			EchoInterval:     profile.Spec.EchoInterval,
+			EchoMode:         profile.Spec.EchoMode,
+			PassiveMode:      profile.Spec.PassiveMode,
+			MinimumTTL:       profile.Spec.MinimumTTL,
+		})
+	}
+	return configs, errs
+}
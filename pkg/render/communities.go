@@ -0,0 +1,81 @@
+package render
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	metallbv1alpha1 "github.com/metallb/metallb-operator/api/v1alpha1"
+)
+
+// CommunityConfig is a named community of the bgp-communities of the MetalLB
+// configuration.
+type CommunityConfig struct {
+	Name  string
+	Value string
+}
+
+// ErrUnknownCommunity is reported for the pools referencing a community missing
+// from the configuration, which MetalLB would reject as a whole.
+var ErrUnknownCommunity = errors.New("unknown community")
+
+// CommunityError reports a Community left out of the MetalLB configuration.
+type CommunityError struct {
+	Namespace string
+	Name      string
+	Err       error
+}
+
+func (e *CommunityError) Error() string {
+	return fmt.Sprintf("community %s/%s: %v", e.Namespace, e.Name, e.Err)
+}
+
+func (e *CommunityError) Unwrap() error {
+	return e.Err
+}
+
+// MergeCommunities merges the Communities into the bgp-communities of a MetalLB
+// configuration, in canonical order, by community name and then resource name.
+// A community failing its validation, or whose name is already used by a
+// community merged before it, is left out and reported with a CommunityError.
+func MergeCommunities(communities []metallbv1alpha1.Community) ([]CommunityConfig, []error) {
+	sorted := make([]metallbv1alpha1.Community, len(communities))
+	copy(sorted, communities)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if sorted[i].Spec.Name != sorted[j].Spec.Name {
+			return sorted[i].Spec.Name < sorted[j].Spec.Name
+		}
+		return sorted[i].Name < sorted[j].Name
+	})
+
+	configs := []CommunityConfig{}
+	var errs []error
+	names := map[string]string{}
+	for _, community := range sorted {
+		if err := community.Validate(); err != nil {
+			errs = append(errs, &CommunityError{Namespace: community.Namespace, Name: community.Name, Err: err})
+			continue
+		}
+		if name, ok := names[community.Spec.Name]; ok {
+			errs = append(errs, &CommunityError{Namespace: community.Namespace, Name: community.Name,
+				Err: fmt.Errorf("duplicate community name %q, already used by community %s", community.Spec.Name, name)})
+			continue
+		}
+		names[community.Spec.Name] = community.Name
+		configs = append(configs, CommunityConfig{Name: community.Spec.Name, Value: community.Spec.Value})
+	}
+	return configs, errs
+}
+
+// unknownCommunity returns the first community of the pool advertisements
+// referencing a name not part of the given ones, empty when there is none.
+func unknownCommunity(pool metallbv1alpha1.AddressPool, names map[string]bool) string {
+	for _, adv := range pool.Spec.BGPAdvertisements {
+		for _, community := range adv.Communities {
+			if community.Name != "" && !names[community.Name] {
+				return community.Name
+			}
+		}
+	}
+	return ""
+}
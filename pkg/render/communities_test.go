@@ -0,0 +1,71 @@
+package render
+
+import (
+	"errors"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	metallbv1alpha1 "github.com/metallb/metallb-operator/api/v1alpha1"
+)
+
+func testCommunity(name, communityName, value string) metallbv1alpha1.Community {
+	return metallbv1alpha1.Community{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "ns"},
+		Spec:       metallbv1alpha1.CommunitySpec{Name: communityName, Value: value},
+	}
+}
+
+func TestMergeCommunities(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	communities, errs := MergeCommunities([]metallbv1alpha1.Community{
+		testCommunity("silver", "silver", "64512:200"),
+		testCommunity("gold", "gold", "64512:100"),
+		testCommunity("invalid", "no-export", "64512:300"),
+		testCommunity("gold-copy", "gold", "64512:400"),
+	})
+	g.Expect(communities).To(Equal([]CommunityConfig{
+		{Name: "gold", Value: "64512:100"},
+		{Name: "silver", Value: "64512:200"},
+	}))
+	g.Expect(errs).To(HaveLen(2))
+	names := []string{}
+	for _, err := range errs {
+		var communityErr *CommunityError
+		g.Expect(errors.As(err, &communityErr)).To(BeTrue())
+		names = append(names, communityErr.Name)
+	}
+	g.Expect(names).To(ConsistOf("invalid", "gold-copy"))
+
+	communities, errs = MergeCommunities(nil)
+	g.Expect(communities).To(BeEmpty())
+	g.Expect(errs).To(BeEmpty())
+}
+
+func TestMergePoolsUnknownCommunity(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	bgpPool := func(name, address string, communities ...metallbv1alpha1.BGPCommunity) metallbv1alpha1.AddressPool {
+		pool := testPool("ns", name, address)
+		pool.Spec.Protocol = "bgp"
+		pool.Spec.BGPAdvertisements = []metallbv1alpha1.BGPAdvertisement{{Communities: communities}}
+		return pool
+	}
+	communities := []CommunityConfig{{Name: "gold", Value: "64512:100"}}
+	config, errs := MergePools([]metallbv1alpha1.AddressPool{
+		bgpPool("gold", "10.0.1.0/24", metallbv1alpha1.BGPCommunity{Name: "gold"}, metallbv1alpha1.BGPCommunity{ASN: 64512, Value: 200}),
+		bgpPool("silver", "10.0.2.0/24", metallbv1alpha1.BGPCommunity{Name: "silver"}),
+	}, communities)
+
+	g.Expect(config.Communities).To(Equal(communities))
+	g.Expect(config.Pools).To(HaveLen(1))
+	g.Expect(config.Pools[0].Name).To(Equal("gold"))
+	g.Expect(errs).To(HaveLen(1))
+	var poolErr *PoolError
+	g.Expect(errors.As(errs[0], &poolErr)).To(BeTrue())
+	g.Expect(poolErr.Name).To(Equal("silver"))
+	g.Expect(errors.Is(errs[0], ErrUnknownCommunity)).To(BeTrue())
+	g.Expect(errs[0].Error()).To(ContainSubstring(`unknown community "silver"`))
+}
@@ -0,0 +1,153 @@
+package render
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	metallbv1alpha1 "github.com/metallb/metallb-operator/api/v1alpha1"
+	metallbv1beta1 "github.com/metallb/metallb-operator/api/v1beta1"
+)
+
+// PeerConfig is a BGP peer of the MetalLB configuration.
+type PeerConfig struct {
+	MyASN       uint32
+	PeerASN     uint32
+	PeerAddress string
+	// PeerPort is only rendered when set
+	PeerPort uint16
+	// HoldTime is only rendered when set
+	HoldTime string
+	// KeepaliveTime is only rendered when set
+	KeepaliveTime string
+	// RouterID is only rendered when set
+	RouterID string
+	// EBGPMultiHop is only rendered when set
+	EBGPMultiHop bool
+	// BFDProfile is only rendered when set
+	BFDProfile string
+	// GracefulRestart is only rendered when set
+	GracefulRestart bool
+	// ConnectTime is only rendered when set
+	ConnectTime string
+	// Password is only rendered when set
+	Password string
+	// NodeSelectors are only rendered when set
+	NodeSelectors []metav1.LabelSelector
+}
+
+// ErrUnknownBFDProfile is reported for the peers referencing a BFD profile
+// missing from the configuration, which MetalLB would reject as a whole.
+var ErrUnknownBFDProfile = errors.New("unknown bfd profile")
+
+// ErrMissingPassword is reported for the peers whose password Secret, or its
+// password key, does not exist.
+var ErrMissingPassword = errors.New("missing bgp password")
+
+// ErrInconsistentASN is reported with the frr BGP backend for the peers using
+// another local AS number than the first peer rendered, as FRR runs a single
+// BGP instance.
+var ErrInconsistentASN = errors.New("inconsistent local AS number")
+
+// PeerError reports a BGPPeer left out of the MetalLB configuration.
+type PeerError struct {
+	Namespace string
+	Name      string
+	Err       error
+}
+
+func (e *PeerError) Error() string {
+	return fmt.Sprintf("bgppeer %s/%s: %v", e.Namespace, e.Name, e.Err)
+}
+
+func (e *PeerError) Unwrap() error {
+	return e.Err
+}
+
+// MergePeers merges the BGPPeers into the peers of a MetalLB configuration,
+// in canonical order, by name and then namespace. The passwords are the ones
+// of the PasswordSecrets of the peers, by Secret name. A peer failing its
+// validation, setting fields not supported by the given BGP backend,
+// referencing a BFD profile not part of the given ones, or a password not part
+// of the given ones, or, with the frr backend, using another local AS number
+// than the first peer rendered, is left out and reported with a PeerError.
+func MergePeers(peers []metallbv1alpha1.BGPPeer, profiles []BFDProfileConfig, passwords map[string]string, bgpBackend string) ([]PeerConfig, []error) {
+	profileNames := map[string]bool{}
+	for _, profile := range profiles {
+		profileNames[profile.Name] = true
+	}
+
+	sorted := make([]metallbv1alpha1.BGPPeer, len(peers))
+	copy(sorted, peers)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if sorted[i].Name != sorted[j].Name {
+			return sorted[i].Name < sorted[j].Name
+		}
+		return sorted[i].Namespace < sorted[j].Namespace
+	})
+
+	configs := []PeerConfig{}
+	var errs []error
+	var first *metallbv1alpha1.BGPPeer
+	for i := range sorted {
+		peer := sorted[i]
+		if err := peer.Validate(); err != nil {
+			errs = append(errs, &PeerError{Namespace: peer.Namespace, Name: peer.Name, Err: err})
+			continue
+		}
+		if err := peer.ValidateBackend(bgpBackend); err != nil {
+			errs = append(errs, &PeerError{Namespace: peer.Namespace, Name: peer.Name, Err: err})
+			continue
+		}
+		if profile := peer.Spec.BFDProfile; profile != "" && !profileNames[profile] {
+			errs = append(errs, &PeerError{Namespace: peer.Namespace, Name: peer.Name,
+				Err: fmt.Errorf("%w %q", ErrUnknownBFDProfile, profile)})
+			continue
+		}
+		var password string
+		if secret := peer.Spec.PasswordSecret; secret != nil {
+			var ok bool
+			if password, ok = passwords[secret.Name]; !ok {
+				errs = append(errs, &PeerError{Namespace: peer.Namespace, Name: peer.Name,
+					Err: fmt.Errorf("%w, secret %q not found or without a %s key", ErrMissingPassword, secret.Name, metallbv1alpha1.PasswordSecretKey)})
+				continue
+			}
+		}
+		if bgpBackend == metallbv1beta1.BGPBackendFRR && first != nil && peer.Spec.MyASN != first.Spec.MyASN {
+			errs = append(errs, &PeerError{Namespace: peer.Namespace, Name: peer.Name,
+				Err: fmt.Errorf("%w %d, the %s BGP backend uses the local AS number %d of bgppeer %s/%s for all the peers",
+					ErrInconsistentASN, peer.Spec.MyASN, metallbv1beta1.BGPBackendFRR, first.Spec.MyASN, first.Namespace, first.Name)})
+			continue
+		}
+		if first == nil {
+			first = &sorted[i]
+		}
+		config := PeerConfig{
+			MyASN:         peer.Spec.MyASN,
+			PeerASN:       peer.Spec.PeerASN,
+			PeerAddress:   peer.Spec.PeerAddress,
+			PeerPort:      peer.Spec.PeerPort,
+			RouterID:      peer.Spec.RouterID,
+			EBGPMultiHop:  peer.Spec.EBGPMultiHop,
+			BFDProfile:    peer.Spec.BFDProfile,
+			Password:      password,
+			NodeSelectors: peer.Spec.NodeSelectors,
+		}
+		if peer.Spec.GracefulRestart != nil {
+			config.GracefulRestart = *peer.Spec.GracefulRestart
+		}
+		if peer.Spec.ConnectTime != nil {
+			config.ConnectTime = peer.Spec.ConnectTime.Duration.String()
+		}
+		if peer.Spec.HoldTime.Duration != 0 {
+			config.HoldTime = peer.Spec.HoldTime.Duration.String()
+		}
+		if peer.Spec.KeepaliveTime.Duration != 0 {
+			config.KeepaliveTime = peer.Spec.KeepaliveTime.Duration.String()
+		}
+		configs = append(configs, config)
+	}
+	return configs, errs
+}
@@ -0,0 +1,184 @@
+package render
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	metallbv1alpha1 "github.com/metallb/metallb-operator/api/v1alpha1"
+	metallbv1beta1 "github.com/metallb/metallb-operator/api/v1beta1"
+)
+
+func testPeer(name, address string) metallbv1alpha1.BGPPeer {
+	return metallbv1alpha1.BGPPeer{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "ns"},
+		Spec: metallbv1alpha1.BGPPeerSpec{
+			MyASN:       64512,
+			PeerASN:     64513,
+			PeerAddress: address,
+		},
+	}
+}
+
+func TestMergePeers(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	tor := testPeer("tor", "10.0.0.2")
+	tor.Spec.PeerPort = 1179
+	tor.Spec.HoldTime = metav1.Duration{Duration: 90 * time.Second}
+	tor.Spec.KeepaliveTime = metav1.Duration{Duration: 30 * time.Second}
+	tor.Spec.NodeSelectors = []metav1.LabelSelector{{MatchLabels: map[string]string{"rack": "r1"}}}
+	invalid := testPeer("invalid", "router.example.com")
+	reflector := testPeer("reflector", "10.1.0.1")
+	reflector.Spec.RouterID = "10.0.0.100"
+	reflector.Spec.EBGPMultiHop = true
+
+	peers, errs := MergePeers([]metallbv1alpha1.BGPPeer{tor, invalid, testPeer("spine", "10.0.0.1"), reflector}, nil, nil, "")
+	g.Expect(peers).To(Equal([]PeerConfig{
+		{MyASN: 64512, PeerASN: 64513, PeerAddress: "10.1.0.1", RouterID: "10.0.0.100", EBGPMultiHop: true},
+		{MyASN: 64512, PeerASN: 64513, PeerAddress: "10.0.0.1"},
+		{MyASN: 64512, PeerASN: 64513, PeerAddress: "10.0.0.2", PeerPort: 1179, HoldTime: "1m30s", KeepaliveTime: "30s",
+			NodeSelectors: []metav1.LabelSelector{{MatchLabels: map[string]string{"rack": "r1"}}}},
+	}))
+	g.Expect(errs).To(HaveLen(1))
+	var peerErr *PeerError
+	g.Expect(errors.As(errs[0], &peerErr)).To(BeTrue())
+	g.Expect(peerErr.Name).To(Equal("invalid"))
+	g.Expect(errs[0].Error()).To(ContainSubstring("bgppeer ns/invalid"))
+
+	peers, errs = MergePeers(nil, nil, nil, "")
+	g.Expect(peers).To(BeEmpty())
+	g.Expect(errs).To(BeEmpty())
+}
+
+func TestMergePeersBFDProfiles(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	multiplier := uint32(3)
+	profiles, errs := MergeBFDProfiles([]metallbv1alpha1.BFDProfile{
+		{ObjectMeta: metav1.ObjectMeta{Name: "slow", Namespace: "ns"}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "fast", Namespace: "ns"},
+			Spec: metallbv1alpha1.BFDProfileSpec{DetectMultiplier: &multiplier, EchoMode: true}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "invalid", Namespace: "ns"},
+			Spec: metallbv1alpha1.BFDProfileSpec{DetectMultiplier: new(uint32)}},
+	})
+	g.Expect(profiles).To(Equal([]BFDProfileConfig{
+		{Name: "fast", DetectMultiplier: &multiplier, EchoMode: true},
+		{Name: "slow"},
+	}))
+	g.Expect(errs).To(HaveLen(1))
+	var profileErr *BFDProfileError
+	g.Expect(errors.As(errs[0], &profileErr)).To(BeTrue())
+	g.Expect(profileErr.Name).To(Equal("invalid"))
+
+	tor := testPeer("tor", "10.0.0.2")
+	tor.Spec.BFDProfile = "fast"
+	// The invalid profile is not part of the configuration either
+	spine := testPeer("spine", "10.0.0.1")
+	spine.Spec.BFDProfile = "invalid"
+	peers, errs := MergePeers([]metallbv1alpha1.BGPPeer{tor, spine}, profiles, nil, "")
+	g.Expect(peers).To(Equal([]PeerConfig{
+		{MyASN: 64512, PeerASN: 64513, PeerAddress: "10.0.0.2", BFDProfile: "fast"},
+	}))
+	g.Expect(errs).To(HaveLen(1))
+	g.Expect(errors.Is(errs[0], ErrUnknownBFDProfile)).To(BeTrue())
+	g.Expect(errs[0].Error()).To(ContainSubstring(`bgppeer ns/spine: unknown bfd profile "invalid"`))
+}
+
+func TestMergePeersGracefulRestart(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	enabled, disabled := true, false
+	tor := testPeer("tor", "10.0.0.2")
+	tor.Spec.GracefulRestart = &enabled
+	spine := testPeer("spine", "10.0.0.1")
+	spine.Spec.GracefulRestart = &disabled
+
+	peers, errs := MergePeers([]metallbv1alpha1.BGPPeer{tor, spine}, nil, nil, metallbv1beta1.BGPBackendFRR)
+	g.Expect(errs).To(BeEmpty())
+	g.Expect(peers).To(Equal([]PeerConfig{
+		{MyASN: 64512, PeerASN: 64513, PeerAddress: "10.0.0.1"},
+		{MyASN: 64512, PeerASN: 64513, PeerAddress: "10.0.0.2", GracefulRestart: true},
+	}))
+
+	// The native backend does not support it, the peer is left out
+	peers, errs = MergePeers([]metallbv1alpha1.BGPPeer{tor, spine}, nil, nil, "")
+	g.Expect(peers).To(Equal([]PeerConfig{
+		{MyASN: 64512, PeerASN: 64513, PeerAddress: "10.0.0.1"},
+	}))
+	g.Expect(errs).To(HaveLen(1))
+	g.Expect(errs[0].Error()).To(ContainSubstring("bgppeer ns/tor"))
+	g.Expect(errs[0].Error()).To(ContainSubstring("spec.gracefulRestart"))
+}
+
+func TestMergePeersConnectTime(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	tor := testPeer("tor", "10.0.0.2")
+	tor.Spec.ConnectTime = &metav1.Duration{Duration: 90 * time.Second}
+	slow := testPeer("slow", "10.0.0.3")
+	slow.Spec.ConnectTime = &metav1.Duration{Duration: 100 * time.Millisecond}
+
+	peers, errs := MergePeers([]metallbv1alpha1.BGPPeer{tor, slow}, nil, nil, metallbv1beta1.BGPBackendFRR)
+	g.Expect(peers).To(Equal([]PeerConfig{
+		{MyASN: 64512, PeerASN: 64513, PeerAddress: "10.0.0.2", ConnectTime: "1m30s"},
+	}))
+	g.Expect(errs).To(HaveLen(1))
+	g.Expect(errs[0].Error()).To(ContainSubstring("bgppeer ns/slow"))
+	g.Expect(errs[0].Error()).To(ContainSubstring("spec.connectTime"))
+
+	peers, errs = MergePeers([]metallbv1alpha1.BGPPeer{tor}, nil, nil, metallbv1beta1.BGPBackendNative)
+	g.Expect(peers).To(BeEmpty())
+	g.Expect(errs).To(HaveLen(1))
+}
+
+func TestMergePeersPassword(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	tor := testPeer("tor", "10.0.0.2")
+	tor.Spec.PasswordSecret = &corev1.LocalObjectReference{Name: "tor-password"}
+	spine := testPeer("spine", "10.0.0.1")
+	spine.Spec.PasswordSecret = &corev1.LocalObjectReference{Name: "spine-password"}
+
+	peers, errs := MergePeers([]metallbv1alpha1.BGPPeer{tor, spine}, nil, map[string]string{"tor-password": "s3cr3t"}, "")
+	g.Expect(peers).To(Equal([]PeerConfig{
+		{MyASN: 64512, PeerASN: 64513, PeerAddress: "10.0.0.2", Password: "s3cr3t"},
+	}))
+	g.Expect(errs).To(HaveLen(1))
+	g.Expect(errors.Is(errs[0], ErrMissingPassword)).To(BeTrue())
+	g.Expect(errs[0].Error()).To(Equal(`bgppeer ns/spine: missing bgp password, secret "spine-password" not found or without a password key`))
+}
+
+func TestMergePeersLocalASN(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	spine := testPeer("spine", "10.0.0.1")
+	tor := testPeer("tor", "10.0.0.2")
+	other := testPeer("other", "10.0.0.3")
+	other.Spec.MyASN = 64600
+
+	// Consistent local AS numbers
+	peers, errs := MergePeers([]metallbv1alpha1.BGPPeer{tor, spine}, nil, nil, metallbv1beta1.BGPBackendFRR)
+	g.Expect(errs).To(BeEmpty())
+	g.Expect(peers).To(HaveLen(2))
+
+	// The first peer in canonical order sets the local AS number
+	peers, errs = MergePeers([]metallbv1alpha1.BGPPeer{tor, spine, other}, nil, nil, metallbv1beta1.BGPBackendFRR)
+	g.Expect(peers).To(Equal([]PeerConfig{
+		{MyASN: 64600, PeerASN: 64513, PeerAddress: "10.0.0.3"},
+	}))
+	g.Expect(errs).To(HaveLen(2))
+	for _, err := range errs {
+		g.Expect(errors.Is(err, ErrInconsistentASN)).To(BeTrue())
+	}
+	g.Expect(errs[0].Error()).To(ContainSubstring("bgppeer ns/spine: inconsistent local AS number 64512, the frr BGP backend uses the local AS number 64600 of bgppeer ns/other for all the peers"))
+
+	// The native backend runs a session per peer
+	peers, errs = MergePeers([]metallbv1alpha1.BGPPeer{tor, spine, other}, nil, nil, "")
+	g.Expect(errs).To(BeEmpty())
+	g.Expect(peers).To(HaveLen(3))
+}
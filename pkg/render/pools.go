@@ -0,0 +1,149 @@
+package render
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"sort"
+
+	metallbv1alpha1 "github.com/metallb/metallb-operator/api/v1alpha1"
+	"github.com/metallb/metallb-operator/pkg/addresses"
+)
+
+// MetalLBConfig is the MetalLB configuration rendered into the ConfigMap.
+type MetalLBConfig struct {
+	Peers       []PeerConfig
+	BFDProfiles []BFDProfileConfig
+	Communities []CommunityConfig
+	Pools       []PoolConfig
+}
+
+// PoolConfig is an address pool of the MetalLB configuration.
+type PoolConfig struct {
+	Name       string
+	Protocol   string
+	Addresses  []string
+	AutoAssign bool
+	// AvoidBuggyIPs is only rendered when set
+	AvoidBuggyIPs bool
+	// BGPAdvertisements are only rendered when set
+	BGPAdvertisements []metallbv1alpha1.BGPAdvertisement
+}
+
+// PoolError reports an AddressPool left out of the MetalLB configuration.
+type PoolError struct {
+	Namespace string
+	Name      string
+	Err       error
+}
+
+func (e *PoolError) Error() string {
+	return fmt.Sprintf("addresspool %s/%s: %v", e.Namespace, e.Name, e.Err)
+}
+
+func (e *PoolError) Unwrap() error {
+	return e.Err
+}
+
+type addressRange struct {
+	pool        string
+	text        string
+	first, last net.IP
+}
+
+// MergePools merges the AddressPools into a MetalLB configuration. The pools
+// are merged in canonical order, by name and then namespace, and the merged
+// configuration keeps that order. A pool failing its validation, referencing
+// a community not part of the given ones, or whose name or addresses conflict
+// with a pool merged before it, is left out and reported with a PoolError. The
+// addresses of a pool, CIDRs or start-end ranges, are passed through verbatim
+// and in their order. The given communities are the ones of the configuration.
+func MergePools(pools []metallbv1alpha1.AddressPool, communities []CommunityConfig) (MetalLBConfig, []error) {
+	communityNames := map[string]bool{}
+	for _, community := range communities {
+		communityNames[community.Name] = true
+	}
+
+	sorted := make([]metallbv1alpha1.AddressPool, len(pools))
+	copy(sorted, pools)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if sorted[i].Name != sorted[j].Name {
+			return sorted[i].Name < sorted[j].Name
+		}
+		return sorted[i].Namespace < sorted[j].Namespace
+	})
+
+	config := MetalLBConfig{Communities: communities, Pools: []PoolConfig{}}
+	var errs []error
+	names := map[string]string{}
+	merged := []addressRange{}
+	for _, pool := range sorted {
+		if err := pool.Validate(); err != nil {
+			errs = append(errs, &PoolError{Namespace: pool.Namespace, Name: pool.Name, Err: err})
+			continue
+		}
+		if name := unknownCommunity(pool, communityNames); name != "" {
+			errs = append(errs, &PoolError{Namespace: pool.Namespace, Name: pool.Name,
+				Err: fmt.Errorf("%w %q", ErrUnknownCommunity, name)})
+			continue
+		}
+		if err := mergePool(pool, names, merged); err != nil {
+			errs = append(errs, &PoolError{Namespace: pool.Namespace, Name: pool.Name, Err: err})
+			continue
+		}
+		names[pool.Name] = pool.Namespace
+		merged = append(merged, parseRanges(pool)...)
+
+		autoAssign := true
+		if pool.Spec.AutoAssign != nil {
+			autoAssign = *pool.Spec.AutoAssign
+		}
+		// MetalLB would assign the addresses of a restricted pool to any namespace
+		if len(pool.Spec.AllowedNamespaces) > 0 {
+			autoAssign = false
+		}
+		config.Pools = append(config.Pools, PoolConfig{
+			Name:       pool.Name,
+			Protocol:   pool.Spec.Protocol,
+			Addresses:  pool.Spec.Addresses,
+			AutoAssign: autoAssign,
+
+			AvoidBuggyIPs:     pool.Spec.AvoidBuggyIPs,
+			BGPAdvertisements: pool.Spec.BGPAdvertisements,
+		})
+	}
+	return config, errs
+}
+
+// mergePool checks the pool against the names and address ranges already merged.
+func mergePool(pool metallbv1alpha1.AddressPool, names map[string]string, merged []addressRange) error {
+	if namespace, ok := names[pool.Name]; ok {
+		return fmt.Errorf("duplicate pool name, already used by addresspool %s/%s", namespace, pool.Name)
+	}
+
+	ranges := parseRanges(pool)
+	for i, r := range ranges {
+		for _, other := range append(merged, ranges[:i]...) {
+			if bytes.Compare(r.first, other.last) <= 0 && bytes.Compare(other.first, r.last) <= 0 {
+				if other.pool == pool.Name {
+					return fmt.Errorf("range %q overlaps with range %q of the same pool", r.text, other.text)
+				}
+				return fmt.Errorf("range %q overlaps with range %q of pool %s", r.text, other.text, other.pool)
+			}
+		}
+	}
+	return nil
+}
+
+// parseRanges returns the address ranges of the pool that can be parsed.
+func parseRanges(pool metallbv1alpha1.AddressPool) []addressRange {
+	ranges := []addressRange{}
+	for _, r := range pool.Spec.Addresses {
+		first, last, err := addresses.ParseRange(r)
+		if err != nil {
+			continue
+		}
+		ranges = append(ranges, addressRange{pool: pool.Name, text: r, first: first, last: last})
+	}
+	return ranges
+}
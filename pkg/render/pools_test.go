@@ -0,0 +1,116 @@
+package render
+
+import (
+	"errors"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	metallbv1alpha1 "github.com/metallb/metallb-operator/api/v1alpha1"
+)
+
+func testPool(namespace, name string, addresses ...string) metallbv1alpha1.AddressPool {
+	return metallbv1alpha1.AddressPool{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace},
+		Spec: metallbv1alpha1.AddressPoolSpec{
+			Protocol:  "layer2",
+			Addresses: addresses,
+		},
+	}
+}
+
+func TestMergePoolsEmpty(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	config, errs := MergePools(nil, nil)
+	g.Expect(errs).To(BeEmpty())
+	g.Expect(config.Pools).To(BeEmpty())
+}
+
+func TestMergePoolsOrdering(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	autoAssign := false
+	charlie := testPool("ns", "charlie", "10.0.3.0/24")
+	charlie.Spec.AutoAssign = &autoAssign
+	pools := []metallbv1alpha1.AddressPool{
+		charlie,
+		testPool("ns", "alpha", "10.0.1.0/24"),
+		testPool("ns", "bravo", "10.0.2.1-10.0.2.100", "2001:db8::/120"),
+	}
+
+	config, errs := MergePools(pools, nil)
+	g.Expect(errs).To(BeEmpty())
+	g.Expect(config.Pools).To(Equal([]PoolConfig{
+		{Name: "alpha", Protocol: "layer2", Addresses: []string{"10.0.1.0/24"}, AutoAssign: true},
+		{Name: "bravo", Protocol: "layer2", Addresses: []string{"10.0.2.1-10.0.2.100", "2001:db8::/120"}, AutoAssign: true},
+		{Name: "charlie", Protocol: "layer2", Addresses: []string{"10.0.3.0/24"}, AutoAssign: false},
+	}))
+	// The input is left untouched
+	g.Expect(pools[0].Name).To(Equal("charlie"))
+}
+
+func TestMergePoolsConflicts(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	pools := []metallbv1alpha1.AddressPool{
+		testPool("ns2", "gold", "10.0.9.0/24"),
+		testPool("ns1", "gold", "10.0.1.0/24"),
+		testPool("ns1", "silver", "10.0.1.100-10.0.1.200"),
+		testPool("ns1", "bronze", "10.0.2.0/24", "10.0.2.10-10.0.2.20"),
+		testPool("ns1", "copper", "10.0.3.0/24"),
+		testPool("ns1", "iron", "10.0.4.0/24", "10.0.3.10-10.0.3.20"),
+		testPool("ns1", "tin", "10.0.1.1", "10.0.5.0/24"),
+	}
+
+	config, errs := MergePools(pools, nil)
+	g.Expect(config.Pools).To(HaveLen(2))
+	g.Expect(config.Pools[0].Name).To(Equal("copper"))
+	g.Expect(config.Pools[1].Name).To(Equal("gold"))
+	g.Expect(config.Pools[1].Addresses).To(Equal([]string{"10.0.1.0/24"}))
+
+	rejected := map[string]string{}
+	for _, err := range errs {
+		var poolErr *PoolError
+		g.Expect(errors.As(err, &poolErr)).To(BeTrue())
+		rejected[poolErr.Namespace+"/"+poolErr.Name] = poolErr.Err.Error()
+	}
+	g.Expect(rejected).To(HaveLen(5))
+	// A single address is neither a CIDR nor a range
+	g.Expect(rejected["ns1/tin"]).To(ContainSubstring("must be a CIDR or a start-end range"))
+	g.Expect(rejected["ns1/iron"]).To(ContainSubstring(`overlaps with range "10.0.3.0/24" of pool copper`))
+	g.Expect(rejected["ns2/gold"]).To(ContainSubstring("duplicate pool name, already used by addresspool ns1/gold"))
+	g.Expect(rejected["ns1/silver"]).To(ContainSubstring(`overlaps with range "10.0.1.0/24" of pool gold`))
+	g.Expect(rejected["ns1/bronze"]).To(ContainSubstring("of the same pool"))
+}
+
+func TestMergePoolsCIDRsAndRanges(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	addresses := []string{"192.168.10.0/24", "10.0.0.1-10.0.0.10", "172.16.0.0/28", "2001:db8::1-2001:db8::10"}
+	for i := 0; i < 3; i++ {
+		config, errs := MergePools([]metallbv1alpha1.AddressPool{testPool("ns", "gold", addresses...)}, nil)
+		g.Expect(errs).To(BeEmpty())
+		// The entries are passed through verbatim, in the order of the pool
+		g.Expect(config.Pools).To(HaveLen(1))
+		g.Expect(config.Pools[0].Addresses).To(Equal(addresses))
+	}
+}
+
+func TestMergePoolsAllowedNamespaces(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	autoAssign := true
+	restricted := testPool("ns", "gold", "10.0.1.0/24")
+	restricted.Spec.AutoAssign = &autoAssign
+	restricted.Spec.AllowedNamespaces = []string{"team-a"}
+
+	config, errs := MergePools([]metallbv1alpha1.AddressPool{restricted, testPool("ns", "silver", "10.0.2.0/24")}, nil)
+	g.Expect(errs).To(BeEmpty())
+	// A restricted pool is never assigned automatically
+	g.Expect(config.Pools).To(Equal([]PoolConfig{
+		{Name: "gold", Protocol: "layer2", Addresses: []string{"10.0.1.0/24"}, AutoAssign: false},
+		{Name: "silver", Protocol: "layer2", Addresses: []string{"10.0.2.0/24"}, AutoAssign: true},
+	}))
+}
@@ -0,0 +1,37 @@
+package render
+
+import (
+	"fmt"
+)
+
+// ValidateCombined checks the references between the parts of a MetalLB
+// configuration, which MetalLB would reject as a whole: the BFD profile of
+// each peer, and the named communities of the BGP advertisements of each
+// pool, must be part of it. All the dangling references are returned at once.
+func ValidateCombined(pools []PoolConfig, peers []PeerConfig, profiles []BFDProfileConfig, communities []CommunityConfig) []error {
+	profileNames := map[string]bool{}
+	for _, profile := range profiles {
+		profileNames[profile.Name] = true
+	}
+	communityNames := map[string]bool{}
+	for _, community := range communities {
+		communityNames[community.Name] = true
+	}
+
+	var errs []error
+	for _, peer := range peers {
+		if peer.BFDProfile != "" && !profileNames[peer.BFDProfile] {
+			errs = append(errs, fmt.Errorf("peer %s: %w %q", peer.PeerAddress, ErrUnknownBFDProfile, peer.BFDProfile))
+		}
+	}
+	for _, pool := range pools {
+		for _, adv := range pool.BGPAdvertisements {
+			for _, community := range adv.Communities {
+				if community.Name != "" && !communityNames[community.Name] {
+					errs = append(errs, fmt.Errorf("address pool %s: %w %q", pool.Name, ErrUnknownCommunity, community.Name))
+				}
+			}
+		}
+	}
+	return errs
+}
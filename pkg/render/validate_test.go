@@ -0,0 +1,78 @@
+package render
+
+import (
+	"errors"
+	"testing"
+
+	. "github.com/onsi/gomega"
+
+	metallbv1alpha1 "github.com/metallb/metallb-operator/api/v1alpha1"
+)
+
+func TestValidateCombined(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	profiles := []BFDProfileConfig{{Name: "fast"}}
+	communities := []CommunityConfig{{Name: "premium", Value: "64512:100"}}
+	advertise := func(names ...string) []metallbv1alpha1.BGPAdvertisement {
+		adv := metallbv1alpha1.BGPAdvertisement{}
+		for _, name := range names {
+			adv.Communities = append(adv.Communities, metallbv1alpha1.BGPCommunity{Name: name})
+		}
+		return []metallbv1alpha1.BGPAdvertisement{adv}
+	}
+
+	tests := []struct {
+		desc    string
+		pools   []PoolConfig
+		peers   []PeerConfig
+		errs    []error
+		message []string
+	}{
+		{
+			desc: "consistent configuration",
+			pools: []PoolConfig{
+				{Name: "gold", Protocol: "bgp", BGPAdvertisements: advertise("premium")},
+				{Name: "silver", Protocol: "bgp", BGPAdvertisements: []metallbv1alpha1.BGPAdvertisement{{
+					Communities: []metallbv1alpha1.BGPCommunity{{WellKnown: "no-export"}},
+				}}},
+				{Name: "bronze", Protocol: "layer2"},
+			},
+			peers: []PeerConfig{
+				{PeerAddress: "10.0.0.1", BFDProfile: "fast"},
+				{PeerAddress: "10.0.0.2"},
+			},
+		},
+		{
+			desc:    "peer referencing a missing bfd profile",
+			peers:   []PeerConfig{{PeerAddress: "10.0.0.1", BFDProfile: "slow"}},
+			errs:    []error{ErrUnknownBFDProfile},
+			message: []string{`peer 10.0.0.1: unknown bfd profile "slow"`},
+		},
+		{
+			desc:    "pool referencing a missing community",
+			pools:   []PoolConfig{{Name: "gold", Protocol: "bgp", BGPAdvertisements: advertise("premium", "basic")}},
+			errs:    []error{ErrUnknownCommunity},
+			message: []string{`address pool gold: unknown community "basic"`},
+		},
+		{
+			desc:  "all the dangling references at once",
+			pools: []PoolConfig{{Name: "gold", Protocol: "bgp", BGPAdvertisements: advertise("basic", "best-effort")}},
+			peers: []PeerConfig{{PeerAddress: "10.0.0.1", BFDProfile: "slow"}, {PeerAddress: "10.0.0.2", BFDProfile: "fast"}},
+			errs:  []error{ErrUnknownBFDProfile, ErrUnknownCommunity, ErrUnknownCommunity},
+			message: []string{
+				`peer 10.0.0.1: unknown bfd profile "slow"`,
+				`address pool gold: unknown community "basic"`,
+				`address pool gold: unknown community "best-effort"`,
+			},
+		},
+	}
+	for _, test := range tests {
+		errs := ValidateCombined(test.pools, test.peers, profiles, communities)
+		g.Expect(errs).To(HaveLen(len(test.errs)), test.desc)
+		for i := range errs {
+			g.Expect(errors.Is(errs[i], test.errs[i])).To(BeTrue(), test.desc)
+			g.Expect(errs[i].Error()).To(Equal(test.message[i]), test.desc)
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package selftest
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+	k8sclient "sigs.k8s.io/controller-runtime/pkg/client"
+
+	metallbv1alpha1 "github.com/metallb/metallb-operator/api/v1alpha1"
+	"github.com/metallb/metallb-operator/pkg/addresses"
+)
+
+const (
+	// ServiceName is the name of the LoadBalancer Service created by the self test
+	ServiceName = "metallb-self-test"
+	// CanaryName is the name of the AddressPool and the LoadBalancer Service
+	// maintained by the canary
+	CanaryName = "metallb-canary"
+	// AddressPoolAnnotation requests MetalLB to assign an address from a given pool
+	AddressPoolAnnotation = "metallb.universe.tf/address-pool"
+)
+
+// Service returns a LoadBalancer Service requesting an address from the given pool.
+func Service(name, namespace, pool string) *corev1.Service {
+	return &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        name,
+			Namespace:   namespace,
+			Annotations: map[string]string{AddressPoolAnnotation: pool},
+		},
+		Spec: corev1.ServiceSpec{
+			Type:  corev1.ServiceTypeLoadBalancer,
+			Ports: []corev1.ServicePort{{Name: "http", Protocol: corev1.ProtocolTCP, Port: 80}},
+		},
+	}
+}
+
+// AssignedAddress returns the address assigned to the Service, nil when none is.
+func AssignedAddress(svc *corev1.Service) net.IP {
+	for _, ingress := range svc.Status.LoadBalancer.Ingress {
+		if ingress.IP != "" {
+			return net.ParseIP(ingress.IP)
+		}
+	}
+	return nil
+}
+
+// Run creates a LoadBalancer Service requesting an address from the given pool,
+// waits for MetalLB to assign one and checks it belongs to the pool. The Service
+// is deleted before returning.
+func Run(ctx context.Context, client k8sclient.Client, namespace string, pool *metallbv1alpha1.AddressPool, interval, timeout time.Duration) (net.IP, error) {
+	svc := Service(ServiceName, namespace, pool.Name)
+	if err := client.Create(ctx, svc); err != nil {
+		return nil, errors.Wrapf(err, "could not create the self test service %s/%s", namespace, ServiceName)
+	}
+	defer func() {
+		_ = k8sclient.IgnoreNotFound(client.Delete(context.Background(), svc))
+	}()
+
+	var ip net.IP
+	err := wait.PollImmediate(interval, timeout, func() (bool, error) {
+		current := &corev1.Service{}
+		err := client.Get(ctx, types.NamespacedName{Name: ServiceName, Namespace: namespace}, current)
+		if apierrors.IsNotFound(err) {
+			return false, nil
+		}
+		if err != nil {
+			return false, err
+		}
+		ip = AssignedAddress(current)
+		return ip != nil, nil
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "no address assigned to the self test service from pool %s", pool.Name)
+	}
+
+	if _, ok := addresses.FindPool(ip, []metallbv1alpha1.AddressPool{*pool}); !ok {
+		return ip, fmt.Errorf("address %s assigned to the self test service is not part of pool %s", ip, pool.Name)
+	}
+	return ip, nil
+}
@@ -0,0 +1,77 @@
+package selftest
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	k8sclient "sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	metallbv1alpha1 "github.com/metallb/metallb-operator/api/v1alpha1"
+)
+
+const testNamespace = "metallb-system"
+
+var testPool = &metallbv1alpha1.AddressPool{
+	ObjectMeta: metav1.ObjectMeta{Name: "self-test", Namespace: testNamespace},
+	Spec: metallbv1alpha1.AddressPoolSpec{
+		Protocol:  "layer2",
+		Addresses: []string{"192.168.10.0/24"},
+	},
+}
+
+func TestRunAddressFromPool(t *testing.T) {
+	g := NewGomegaWithT(t)
+	client := fake.NewClientBuilder().WithScheme(clientgoscheme.Scheme).Build()
+	go assignAddress(client, "192.168.10.5")
+
+	ip, err := Run(context.Background(), client, testNamespace, testPool, 10*time.Millisecond, 5*time.Second)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(ip.String()).To(Equal("192.168.10.5"))
+	expectServiceDeleted(g, client)
+}
+
+func TestRunAddressOutOfPool(t *testing.T) {
+	g := NewGomegaWithT(t)
+	client := fake.NewClientBuilder().WithScheme(clientgoscheme.Scheme).Build()
+	go assignAddress(client, "10.0.0.5")
+
+	_, err := Run(context.Background(), client, testNamespace, testPool, 10*time.Millisecond, 5*time.Second)
+	g.Expect(err).To(MatchError(ContainSubstring("is not part of pool self-test")))
+	expectServiceDeleted(g, client)
+}
+
+func TestRunNoAddress(t *testing.T) {
+	g := NewGomegaWithT(t)
+	client := fake.NewClientBuilder().WithScheme(clientgoscheme.Scheme).Build()
+
+	_, err := Run(context.Background(), client, testNamespace, testPool, 10*time.Millisecond, 50*time.Millisecond)
+	g.Expect(err).To(MatchError(ContainSubstring("no address assigned")))
+	expectServiceDeleted(g, client)
+}
+
+// assignAddress acts as the MetalLB controller, assigning the given IP to the self test service.
+func assignAddress(client k8sclient.Client, ip string) {
+	for {
+		svc := &corev1.Service{}
+		if err := client.Get(context.Background(), types.NamespacedName{Name: ServiceName, Namespace: testNamespace}, svc); err == nil {
+			svc.Status.LoadBalancer.Ingress = []corev1.LoadBalancerIngress{{IP: ip}}
+			if err := client.Status().Update(context.Background(), svc); err == nil {
+				return
+			}
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func expectServiceDeleted(g *WithT, client k8sclient.Client) {
+	err := client.Get(context.Background(), types.NamespacedName{Name: ServiceName, Namespace: testNamespace}, &corev1.Service{})
+	g.Expect(apierrors.IsNotFound(err)).To(BeTrue())
+}
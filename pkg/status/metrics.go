@@ -0,0 +1,30 @@
+package status
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+var degraded = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+	Name: "metallb_operator_degraded",
+	Help: "Whether the resource reports a Degraded condition (1) or not (0).",
+}, []string{"kind", "namespace", "name"})
+
+func init() {
+	metrics.Registry.MustRegister(degraded)
+}
+
+// ForgetDegraded drops the degraded gauge of a deleted resource.
+func ForgetDegraded(kind, namespace, name string) {
+	degraded.DeleteLabelValues(kind, namespace, name)
+}
+
+func recordDegraded(kind string, obj metav1.Object, conditions []metav1.Condition) {
+	value := 0.0
+	if meta.IsStatusConditionTrue(conditions, ConditionDegraded) {
+		value = 1
+	}
+	degraded.WithLabelValues(kind, obj.GetNamespace(), obj.GetName()).Set(value)
+}
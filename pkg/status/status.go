@@ -2,12 +2,15 @@ package status
 
 import (
 	"context"
+	"fmt"
 	"time"
 
+	metallbv1alpha1 "github.com/metallb/metallb-operator/api/v1alpha1"
 	metallbv1beta1 "github.com/metallb/metallb-operator/api/v1beta1"
 	"github.com/pkg/errors"
 	appsv1 "k8s.io/api/apps/v1"
 	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
 	k8sclient "sigs.k8s.io/controller-runtime/pkg/client"
@@ -31,14 +34,56 @@ const (
 	ConditionProgressing = "Progressing"
 	ConditionDegraded    = "Degraded"
 	ConditionUpgradeable = "Upgradeable"
+	// ConditionConfigValid reports whether the MetalLB configuration is valid,
+	// independently from the health of the MetalLB workloads.
+	ConditionConfigValid = "ConfigValid"
+	// ConditionSelfTest reports whether MetalLB assigned an address to the
+	// service created by the operator self test.
+	ConditionSelfTest = "SelfTestPassed"
+	// ConditionExhausted reports whether all the addresses of an AddressPool
+	// are assigned to services.
+	ConditionExhausted = "Exhausted"
+	// ConditionAllocationStrategy reports whether MetalLB supports the
+	// allocation strategy of an AddressPool.
+	ConditionAllocationStrategy = "AllocationStrategySupported"
+	// ConditionIPFamilies reports whether MetalLB announces the addresses of
+	// all the IP families of an AddressPool.
+	ConditionIPFamilies = "IPFamiliesSupported"
+	// ConditionConfigWriteUnstable reports whether the writes of the MetalLB
+	// ConfigMaps keep failing.
+	ConditionConfigWriteUnstable = "ConfigWriteUnstable"
+	// ConditionCanaryHealthy reports whether MetalLB assigned an address to
+	// the canary service.
+	ConditionCanaryHealthy = "CanaryHealthy"
+	// ConditionControllerSchedulable reports whether a schedulable node
+	// matches the ControllerNodeSelector.
+	ConditionControllerSchedulable = "ControllerSchedulable"
+	// ConditionPriorityClassesFound reports whether the PriorityClasses of
+	// the speaker and the controller exist.
+	ConditionPriorityClassesFound = "PriorityClassesFound"
 )
 
 func Update(ctx context.Context, client k8sclient.Client, metallb *metallbv1beta1.MetalLB, condition string, reason string, message string) error {
 	conditions := getConditions(condition, reason, message)
+	// An unchanged condition keeps its transition time, so that the status is
+	// not written again
+	for i := range conditions {
+		current := meta.FindStatusCondition(metallb.Status.Conditions, conditions[i].Type)
+		if current != nil && current.Status == conditions[i].Status {
+			conditions[i].LastTransitionTime = current.LastTransitionTime
+		}
+	}
+	// The conditions set by UpdateConfigValid and UpdateSelfTest are kept
+	for _, c := range metallb.Status.Conditions {
+		if meta.FindStatusCondition(conditions, c.Type) == nil {
+			conditions = append(conditions, c)
+		}
+	}
+	recordDegraded("MetalLB", metallb, conditions)
 	if equality.Semantic.DeepEqual(conditions, metallb.Status.Conditions) {
 		return nil
 	}
-	metallb.Status.Conditions = getConditions(condition, reason, message)
+	metallb.Status.Conditions = conditions
 
 	if err := client.Status().Update(ctx, metallb); err != nil {
 		return errors.Wrapf(err, "could not update status for object %+v", metallb)
@@ -52,9 +97,15 @@ func getConditions(condition string, reason string, message string) []metav1.Con
 	case ConditionAvailable:
 		conditions[0].Status = metav1.ConditionTrue
 		conditions[1].Status = metav1.ConditionTrue
+		if reason != "" {
+			conditions[0].Reason = reason
+			conditions[0].Message = message
+		}
 	case ConditionProgressing:
 		conditions[2].Status = metav1.ConditionTrue
-		conditions[2].Reason = reason
+		if reason != "" {
+			conditions[2].Reason = reason
+		}
 		conditions[2].Message = message
 	case ConditionDegraded:
 		conditions[3].Status = metav1.ConditionTrue
@@ -94,6 +145,363 @@ func getBaseConditions() []metav1.Condition {
 	}
 }
 
+// UpdateConfigValid sets the ConfigValid condition of the given MetalLB, from the
+// error returned when validating and rendering its configuration.
+func UpdateConfigValid(ctx context.Context, client k8sclient.Client, metallb *metallbv1beta1.MetalLB, configErr error) error {
+	return setCondition(ctx, client, metallb, getConfigValidCondition(configErr))
+}
+
+// UpdateSelfTest sets the SelfTestPassed condition of the given MetalLB, from the
+// outcome of the self test.
+func UpdateSelfTest(ctx context.Context, client k8sclient.Client, metallb *metallbv1beta1.MetalLB, message string, testErr error) error {
+	condition := metav1.Condition{
+		Type:    ConditionSelfTest,
+		Status:  metav1.ConditionTrue,
+		Reason:  "AddressAssigned",
+		Message: message,
+	}
+	if testErr != nil {
+		condition.Status = metav1.ConditionFalse
+		condition.Reason = "SelfTestFailed"
+		condition.Message = testErr.Error()
+	}
+	return setCondition(ctx, client, metallb, condition)
+}
+
+// UpdateLastError records the error returned by a reconcile of the given MetalLB
+// in its status, a nil error clears the one recorded. The same error returned
+// again is not written, keeping the time it was first returned: each write
+// triggers another reconcile of the MetalLB, failing the same way.
+func UpdateLastError(ctx context.Context, client k8sclient.Client, metallb *metallbv1beta1.MetalLB, reconcileErr error) error {
+	if reconcileErr == nil {
+		if metallb.Status.LastError == "" && metallb.Status.LastErrorTime.IsZero() {
+			return nil
+		}
+		metallb.Status.LastError = ""
+		metallb.Status.LastErrorTime = metav1.Time{}
+	} else {
+		if metallb.Status.LastError == reconcileErr.Error() {
+			return nil
+		}
+		metallb.Status.LastError = reconcileErr.Error()
+		metallb.Status.LastErrorTime = metav1.Now()
+	}
+
+	if err := client.Status().Update(ctx, metallb); err != nil {
+		return errors.Wrapf(err, "could not update status for object %+v", metallb)
+	}
+	return nil
+}
+
+// UpdateUnhealthySince records since when the MetalLB workloads are unhealthy,
+// a zero time clears it.
+func UpdateUnhealthySince(ctx context.Context, client k8sclient.Client, metallb *metallbv1beta1.MetalLB, since metav1.Time) error {
+	if metallb.Status.UnhealthySince.Equal(&since) {
+		return nil
+	}
+	metallb.Status.UnhealthySince = since
+
+	if err := client.Status().Update(ctx, metallb); err != nil {
+		return errors.Wrapf(err, "could not update status for object %+v", metallb)
+	}
+	return nil
+}
+
+// UpdateConfigWriteUnstable sets the ConfigWriteUnstable condition of the given
+// MetalLB, from the number of consecutive failed writes of the MetalLB
+// ConfigMaps and the last write error.
+func UpdateConfigWriteUnstable(ctx context.Context, client k8sclient.Client, metallb *metallbv1beta1.MetalLB, unstable bool, failures int, writeErr error) error {
+	condition := metav1.Condition{
+		Type:   ConditionConfigWriteUnstable,
+		Status: metav1.ConditionFalse,
+		Reason: "ConfigWritesSucceeding",
+	}
+	if unstable {
+		condition.Status = metav1.ConditionTrue
+		condition.Reason = "ConfigWritesFailing"
+		condition.Message = fmt.Sprintf("%d consecutive writes of the MetalLB configuration failed, the last with: %v", failures, writeErr)
+	}
+	return setCondition(ctx, client, metallb, condition)
+}
+
+// UpdateCanaryHealthy sets the CanaryHealthy condition of the given MetalLB.
+func UpdateCanaryHealthy(ctx context.Context, client k8sclient.Client, metallb *metallbv1beta1.MetalLB, healthy metav1.ConditionStatus, reason, message string) error {
+	return setCondition(ctx, client, metallb, metav1.Condition{
+		Type:    ConditionCanaryHealthy,
+		Status:  healthy,
+		Reason:  reason,
+		Message: message,
+	})
+}
+
+// UpdateControllerSchedulable sets the ControllerSchedulable condition of the
+// given MetalLB, the message telling why the controller can't be scheduled.
+func UpdateControllerSchedulable(ctx context.Context, client k8sclient.Client, metallb *metallbv1beta1.MetalLB, schedulable bool, message string) error {
+	condition := metav1.Condition{
+		Type:   ConditionControllerSchedulable,
+		Status: metav1.ConditionTrue,
+		Reason: "NodesMatchSelector",
+	}
+	if !schedulable {
+		condition.Status = metav1.ConditionFalse
+		condition.Reason = "NoNodeMatchesSelector"
+		condition.Message = message
+	}
+	return setCondition(ctx, client, metallb, condition)
+}
+
+// UpdatePriorityClassesFound sets the PriorityClassesFound condition of the
+// given MetalLB, leaving the other ones untouched. The message lists the
+// missing PriorityClasses.
+func UpdatePriorityClassesFound(ctx context.Context, client k8sclient.Client, metallb *metallbv1beta1.MetalLB, found bool, message string) error {
+	condition := metav1.Condition{
+		Type:   ConditionPriorityClassesFound,
+		Status: metav1.ConditionTrue,
+		Reason: ConditionPriorityClassesFound,
+	}
+	if !found {
+		condition.Status = metav1.ConditionFalse
+		condition.Reason = "PriorityClassNotFound"
+		condition.Message = message
+	}
+	return setCondition(ctx, client, metallb, condition)
+}
+
+// RemoveCondition removes a single condition of the given MetalLB, leaving the other ones untouched.
+func RemoveCondition(ctx context.Context, client k8sclient.Client, metallb *metallbv1beta1.MetalLB, conditionType string) error {
+	if meta.FindStatusCondition(metallb.Status.Conditions, conditionType) == nil {
+		return nil
+	}
+	meta.RemoveStatusCondition(&metallb.Status.Conditions, conditionType)
+
+	if err := client.Status().Update(ctx, metallb); err != nil {
+		return errors.Wrapf(err, "could not update status for object %+v", metallb)
+	}
+	return nil
+}
+
+// setCondition sets a single condition of the given MetalLB, leaving the other ones untouched.
+func setCondition(ctx context.Context, client k8sclient.Client, metallb *metallbv1beta1.MetalLB, condition metav1.Condition) error {
+	conditions := make([]metav1.Condition, len(metallb.Status.Conditions))
+	copy(conditions, metallb.Status.Conditions)
+	meta.SetStatusCondition(&conditions, condition)
+	recordDegraded("MetalLB", metallb, conditions)
+	if equality.Semantic.DeepEqual(conditions, metallb.Status.Conditions) {
+		return nil
+	}
+	metallb.Status.Conditions = conditions
+
+	if err := client.Status().Update(ctx, metallb); err != nil {
+		return errors.Wrapf(err, "could not update status for object %+v", metallb)
+	}
+	return nil
+}
+
+func getConfigValidCondition(configErr error) metav1.Condition {
+	if configErr != nil {
+		return metav1.Condition{
+			Type:    ConditionConfigValid,
+			Status:  metav1.ConditionFalse,
+			Reason:  "InvalidConfiguration",
+			Message: configErr.Error(),
+		}
+	}
+	return metav1.Condition{
+		Type:   ConditionConfigValid,
+		Status: metav1.ConditionTrue,
+		Reason: ConditionConfigValid,
+	}
+}
+
+// UpdateAddressPool sets the conditions of the given AddressPool. The transition time
+// of a condition is kept when its status does not change, and the resource is updated
+// only when the conditions differ from the current ones.
+func UpdateAddressPool(ctx context.Context, client k8sclient.Client, pool *metallbv1alpha1.AddressPool, condition string, reason string, message string) error {
+	conditions := make([]metav1.Condition, len(pool.Status.Conditions))
+	copy(conditions, pool.Status.Conditions)
+	for _, c := range getAddressPoolConditions(condition, reason, message) {
+		meta.SetStatusCondition(&conditions, c)
+	}
+	recordDegraded("AddressPool", pool, conditions)
+	if equality.Semantic.DeepEqual(conditions, pool.Status.Conditions) {
+		return nil
+	}
+	pool.Status.Conditions = conditions
+
+	if err := client.Status().Update(ctx, pool); err != nil {
+		return errors.Wrapf(err, "could not update status for object %+v", pool)
+	}
+	return nil
+}
+
+// UpdateAddressPoolExhausted sets the Exhausted condition and the address counts
+// of the given AddressPool, leaving the other conditions untouched.
+func UpdateAddressPoolExhausted(ctx context.Context, client k8sclient.Client, pool *metallbv1alpha1.AddressPool, allocated, total int, exhausted bool, message string) error {
+	condition := metav1.Condition{
+		Type:    ConditionExhausted,
+		Status:  metav1.ConditionFalse,
+		Reason:  "AddressesAvailable",
+		Message: message,
+	}
+	if exhausted {
+		condition.Status = metav1.ConditionTrue
+		condition.Reason = "PoolExhausted"
+	}
+	conditions := make([]metav1.Condition, len(pool.Status.Conditions))
+	copy(conditions, pool.Status.Conditions)
+	meta.SetStatusCondition(&conditions, condition)
+	recordDegraded("AddressPool", pool, conditions)
+	if equality.Semantic.DeepEqual(conditions, pool.Status.Conditions) &&
+		pool.Status.AllocatedAddresses == allocated && pool.Status.TotalAddresses == total {
+		return nil
+	}
+	pool.Status.Conditions = conditions
+	pool.Status.AllocatedAddresses = allocated
+	pool.Status.TotalAddresses = total
+
+	if err := client.Status().Update(ctx, pool); err != nil {
+		return errors.Wrapf(err, "could not update status for object %+v", pool)
+	}
+	return nil
+}
+
+// UpdateAddressPoolAllocation sets the AllocationStrategySupported condition of
+// the given AddressPool, leaving the other ones untouched. An error means the
+// requested strategy is not supported.
+func UpdateAddressPoolAllocation(ctx context.Context, client k8sclient.Client, pool *metallbv1alpha1.AddressPool, strategyErr error) error {
+	condition := metav1.Condition{
+		Type:   ConditionAllocationStrategy,
+		Status: metav1.ConditionTrue,
+		Reason: ConditionAllocationStrategy,
+	}
+	if strategyErr != nil {
+		condition.Status = metav1.ConditionFalse
+		condition.Reason = "UnsupportedAllocationStrategy"
+		condition.Message = strategyErr.Error()
+	}
+	return setAddressPoolCondition(ctx, client, pool, condition)
+}
+
+// UpdateAddressPoolIPFamilies sets the IPFamiliesSupported condition of the
+// given AddressPool, leaving the other ones untouched. The reason of the
+// condition is the family of the pool, IPv4, IPv6 or DualStack, and an error
+// means MetalLB does not announce some of its addresses.
+func UpdateAddressPoolIPFamilies(ctx context.Context, client k8sclient.Client, pool *metallbv1alpha1.AddressPool, family string, familyErr error) error {
+	condition := metav1.Condition{
+		Type:   ConditionIPFamilies,
+		Status: metav1.ConditionTrue,
+		Reason: family,
+	}
+	if familyErr != nil {
+		condition.Status = metav1.ConditionFalse
+		condition.Reason = "UnsupportedIPFamily"
+		condition.Message = familyErr.Error()
+	}
+	return setAddressPoolCondition(ctx, client, pool, condition)
+}
+
+// setAddressPoolCondition sets a single condition of the given AddressPool,
+// leaving the other ones untouched.
+func setAddressPoolCondition(ctx context.Context, client k8sclient.Client, pool *metallbv1alpha1.AddressPool, condition metav1.Condition) error {
+	conditions := make([]metav1.Condition, len(pool.Status.Conditions))
+	copy(conditions, pool.Status.Conditions)
+	meta.SetStatusCondition(&conditions, condition)
+	recordDegraded("AddressPool", pool, conditions)
+	if equality.Semantic.DeepEqual(conditions, pool.Status.Conditions) {
+		return nil
+	}
+	pool.Status.Conditions = conditions
+
+	if err := client.Status().Update(ctx, pool); err != nil {
+		return errors.Wrapf(err, "could not update status for object %+v", pool)
+	}
+	return nil
+}
+
+// UpdateBGPPeer sets the conditions of the given BGPPeer, as UpdateAddressPool
+// does for an AddressPool.
+func UpdateBGPPeer(ctx context.Context, client k8sclient.Client, peer *metallbv1alpha1.BGPPeer, condition string, reason string, message string) error {
+	conditions := make([]metav1.Condition, len(peer.Status.Conditions))
+	copy(conditions, peer.Status.Conditions)
+	for _, c := range getAddressPoolConditions(condition, reason, message) {
+		meta.SetStatusCondition(&conditions, c)
+	}
+	recordDegraded("BGPPeer", peer, conditions)
+	if equality.Semantic.DeepEqual(conditions, peer.Status.Conditions) {
+		return nil
+	}
+	peer.Status.Conditions = conditions
+
+	if err := client.Status().Update(ctx, peer); err != nil {
+		return errors.Wrapf(err, "could not update status for object %+v", peer)
+	}
+	return nil
+}
+
+// UpdateBFDProfile sets the conditions of the given BFDProfile, as UpdateAddressPool
+// does for an AddressPool.
+func UpdateBFDProfile(ctx context.Context, client k8sclient.Client, profile *metallbv1alpha1.BFDProfile, condition string, reason string, message string) error {
+	conditions := make([]metav1.Condition, len(profile.Status.Conditions))
+	copy(conditions, profile.Status.Conditions)
+	for _, c := range getAddressPoolConditions(condition, reason, message) {
+		meta.SetStatusCondition(&conditions, c)
+	}
+	recordDegraded("BFDProfile", profile, conditions)
+	if equality.Semantic.DeepEqual(conditions, profile.Status.Conditions) {
+		return nil
+	}
+	profile.Status.Conditions = conditions
+
+	if err := client.Status().Update(ctx, profile); err != nil {
+		return errors.Wrapf(err, "could not update status for object %+v", profile)
+	}
+	return nil
+}
+
+// UpdateCommunity sets the conditions of the given Community, as UpdateAddressPool
+// does for an AddressPool.
+func UpdateCommunity(ctx context.Context, client k8sclient.Client, community *metallbv1alpha1.Community, condition string, reason string, message string) error {
+	conditions := make([]metav1.Condition, len(community.Status.Conditions))
+	copy(conditions, community.Status.Conditions)
+	for _, c := range getAddressPoolConditions(condition, reason, message) {
+		meta.SetStatusCondition(&conditions, c)
+	}
+	recordDegraded("Community", community, conditions)
+	if equality.Semantic.DeepEqual(conditions, community.Status.Conditions) {
+		return nil
+	}
+	community.Status.Conditions = conditions
+
+	if err := client.Status().Update(ctx, community); err != nil {
+		return errors.Wrapf(err, "could not update status for object %+v", community)
+	}
+	return nil
+}
+
+func getAddressPoolConditions(condition string, reason string, message string) []metav1.Condition {
+	conditions := []metav1.Condition{
+		{
+			Type:   ConditionAvailable,
+			Status: metav1.ConditionFalse,
+			Reason: ConditionAvailable,
+		},
+		{
+			Type:   ConditionDegraded,
+			Status: metav1.ConditionFalse,
+			Reason: ConditionDegraded,
+		},
+	}
+	switch condition {
+	case ConditionAvailable:
+		conditions[0].Status = metav1.ConditionTrue
+	case ConditionDegraded:
+		conditions[1].Status = metav1.ConditionTrue
+		conditions[1].Reason = reason
+		conditions[1].Message = message
+	}
+	return conditions
+}
+
 func IsMetalLBAvailable(ctx context.Context, client k8sclient.Client, namespace string) error {
 
 	ds := &appsv1.DaemonSet{}
@@ -101,16 +509,23 @@ func IsMetalLBAvailable(ctx context.Context, client k8sclient.Client, namespace
 	if err != nil {
 		return err
 	}
-	if ds.Status.DesiredNumberScheduled != ds.Status.CurrentNumberScheduled {
-		return MetalLBResourcesNotReadyError{Message: "MetalLB speaker daemonset not ready"}
+	if ds.Status.NumberReady < ds.Status.DesiredNumberScheduled {
+		return MetalLBResourcesNotReadyError{Message: fmt.Sprintf("MetalLB speaker daemonset not ready, %d of %d pods ready",
+			ds.Status.NumberReady, ds.Status.DesiredNumberScheduled)}
 	}
 	deployment := &appsv1.Deployment{}
 	err = client.Get(ctx, types.NamespacedName{Name: "controller", Namespace: namespace}, deployment)
 	if err != nil {
 		return err
 	}
-	if deployment.Status.ReadyReplicas != *deployment.Spec.Replicas {
-		return MetalLBResourcesNotReadyError{Message: "MetalLB controller deployment not ready"}
+	// Replicas is defaulted to 1 by the API server
+	replicas := int32(1)
+	if deployment.Spec.Replicas != nil {
+		replicas = *deployment.Spec.Replicas
+	}
+	if deployment.Status.ReadyReplicas < replicas {
+		return MetalLBResourcesNotReadyError{Message: fmt.Sprintf("MetalLB controller deployment not ready, %d of %d pods ready",
+			deployment.Status.ReadyReplicas, replicas)}
 	}
 	return nil
 }
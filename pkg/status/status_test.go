@@ -1,6 +1,7 @@
 package status
 
 import (
+	"errors"
 	"testing"
 
 	. "github.com/onsi/gomega"
@@ -14,6 +15,12 @@ func TestGetConditionsAvailable(t *testing.T) {
 	validateConditionTypes(g, conditions)
 	g.Expect(conditions[0].Status).To(Equal(metav1.ConditionTrue))
 	g.Expect(conditions[1].Status).To(Equal(metav1.ConditionTrue))
+	g.Expect(conditions[0].Reason).To(Equal("testReason"))
+	g.Expect(conditions[0].Message).To(Equal("testMessage"))
+
+	conditions = getConditions(ConditionAvailable, "", "")
+	g.Expect(conditions[0].Status).To(Equal(metav1.ConditionTrue))
+	g.Expect(conditions[0].Reason).To(Equal(ConditionAvailable))
 }
 
 func TestGetConditionsProgressing(t *testing.T) {
@@ -24,6 +31,11 @@ func TestGetConditionsProgressing(t *testing.T) {
 	g.Expect(conditions[2].Status).To(Equal(metav1.ConditionTrue))
 	g.Expect(conditions[2].Message).To(Equal("testMessage"))
 	g.Expect(conditions[2].Reason).To(Equal("testReason"))
+
+	conditions = getConditions(ConditionProgressing, "", "testMessage")
+	g.Expect(conditions[2].Status).To(Equal(metav1.ConditionTrue))
+	g.Expect(conditions[2].Reason).To(Equal(ConditionProgressing))
+	g.Expect(conditions[2].Message).To(Equal("testMessage"))
 }
 
 func TestGetConditionsDegraded(t *testing.T) {
@@ -49,3 +61,17 @@ func validateConditionTypes(g *GomegaWithT, conditions []metav1.Condition) {
 	g.Expect(conditions[2].Type).To(Equal(ConditionProgressing))
 	g.Expect(conditions[3].Type).To(Equal(ConditionDegraded))
 }
+
+func TestGetConfigValidCondition(t *testing.T) {
+	g := NewGomegaWithT(t)
+	condition := getConfigValidCondition(nil)
+	g.Expect(condition.Type).To(Equal(ConditionConfigValid))
+	g.Expect(condition.Status).To(Equal(metav1.ConditionTrue))
+	g.Expect(condition.Message).To(Equal(""))
+
+	condition = getConfigValidCondition(errors.New("invalid pool sort order"))
+	g.Expect(condition.Type).To(Equal(ConditionConfigValid))
+	g.Expect(condition.Status).To(Equal(metav1.ConditionFalse))
+	g.Expect(condition.Reason).To(Equal("InvalidConfiguration"))
+	g.Expect(condition.Message).To(Equal("invalid pool sort order"))
+}
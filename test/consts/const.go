@@ -15,6 +15,12 @@ const (
 	MetalLBDaemonsetName = "speaker"
 	// MetalLBAddressPoolCRDName contains the name of MetallB AddressPool CRD
 	MetalLBAddressPoolCRDName = "addresspools.metallb.io"
+	// MetalLBBGPPeerCRDName contains the name of MetallB BGPPeer CRD
+	MetalLBBGPPeerCRDName = "bgppeers.metallb.io"
+	// MetalLBBFDProfileCRDName contains the name of MetallB BFDProfile CRD
+	MetalLBBFDProfileCRDName = "bfdprofiles.metallb.io"
+	// MetalLBCommunityCRDName contains the name of MetallB Community CRD
+	MetalLBCommunityCRDName = "communities.metallb.io"
 	// MetalLBConfigMapName contains created configmap
 	MetalLBConfigMapName = "config"
 	// DefaultOperatorNameSpace is the default operator namespace
@@ -5,6 +5,7 @@ import (
 	"flag"
 	"os"
 	"path"
+	"strings"
 	"testing"
 	"time"
 
@@ -15,13 +16,18 @@ import (
 
 	metallbv1alpha1 "github.com/metallb/metallb-operator/api/v1alpha1"
 	metallbv1beta1 "github.com/metallb/metallb-operator/api/v1beta1"
+	"github.com/metallb/metallb-operator/pkg/addresses"
+	"github.com/metallb/metallb-operator/pkg/config"
+	"github.com/metallb/metallb-operator/pkg/selftest"
 	"github.com/metallb/metallb-operator/pkg/status"
 	"github.com/metallb/metallb-operator/test/consts"
 	testclient "github.com/metallb/metallb-operator/test/e2e/client"
 	"github.com/metallb/metallb-operator/test/e2e/k8sreporter"
+	"github.com/metallb/metallb-operator/test/e2e/util"
 	metallbutils "github.com/metallb/metallb-operator/test/metallb"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
 	goclient "sigs.k8s.io/controller-runtime/pkg/client"
@@ -35,6 +41,7 @@ var OperatorNameSpace = consts.DefaultOperatorNameSpace
 
 var junitPath *string
 var reportPath *string
+var reportFormat *string
 
 func init() {
 	if len(os.Getenv("USE_LOCAL_RESOURCES")) != 0 {
@@ -44,9 +51,12 @@ func init() {
 	if ns := os.Getenv("OO_INSTALL_NAMESPACE"); len(ns) != 0 {
 		OperatorNameSpace = ns
 	}
+	// The expected configurations are rendered from the operator template
+	config.ManifestPath = "../../bindata/configuration/address-pool"
 
 	junitPath = flag.String("junit", "", "the path for the junit format report")
 	reportPath = flag.String("report", "", "the path of the report file containing details for failed tests")
+	reportFormat = flag.String("report-format", string(k8sreporter.FormatText), "the format of the objects dumped in the report, text or json")
 }
 
 func RunE2ETests(t *testing.T) {
@@ -61,7 +71,7 @@ func RunE2ETests(t *testing.T) {
 	clients := testclient.New("")
 
 	if *reportPath != "" {
-		rr = append(rr, k8sreporter.New(clients, OperatorNameSpace, *reportPath))
+		rr = append(rr, k8sreporter.New(clients, OperatorNameSpace, *reportPath, k8sreporter.Format(*reportFormat)))
 	}
 
 	RunSpecsWithDefaultAndCustomReporters(t, "Metallb Operator E2E Suite", rr)
@@ -181,6 +191,8 @@ var _ = Describe("metallb", func() {
 	})
 
 	Context("Creating AddressPool", func() {
+		withMetalLB()
+
 		table.DescribeTable("Testing creating addresspool CR successfully", func(addressPoolName string, addresspool *metallbv1alpha1.AddressPool, expectedConfigMap string) {
 			By("By creating AddressPool CR")
 
@@ -261,8 +273,120 @@ var _ = Describe("metallb", func() {
   - 2.2.2.1
   - 2.2.2.100
 
+`),
+			table.Entry("Test AddressPool object avoiding buggy IPs", "addresspool3", &metallbv1alpha1.AddressPool{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "addresspool3",
+					Namespace: OperatorNameSpace,
+				},
+				Spec: metallbv1alpha1.AddressPoolSpec{
+					Protocol: "layer2",
+					Addresses: []string{
+						"3.3.3.0/24",
+					},
+					AvoidBuggyIPs: true,
+				},
+			}, `address-pools:
+- name: addresspool3
+  protocol: layer2
+  avoid-buggy-ips: true
+  addresses:
+
+  - 3.3.3.0/24
+
 `))
 	})
+	Context("Creating AddressPool with an invalid protocol", func() {
+		It("should not render the pool into the ConfigMap", func() {
+			addresspool := &metallbv1alpha1.AddressPool{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "addresspool-layer3",
+					Namespace: OperatorNameSpace,
+				},
+				Spec: metallbv1alpha1.AddressPoolSpec{
+					Protocol: "layer3",
+					Addresses: []string{
+						"4.4.4.1",
+						"4.4.4.100",
+					},
+				},
+			}
+
+			By("By creating AddressPool CR")
+			err := testclient.Client.Create(context.Background(), addresspool)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring(`"layer3"`))
+
+			By("By checking the pool is not in the ConfigMap")
+			Consistently(func() string {
+				configmap, err := testclient.Client.ConfigMaps(OperatorNameSpace).Get(context.Background(), consts.MetalLBConfigMapName, metav1.GetOptions{})
+				if err != nil {
+					return ""
+				}
+				return configmap.Data[consts.MetalLBConfigMapName]
+			}, 30*time.Second, metallbutils.Interval).ShouldNot(ContainSubstring("addresspool-layer3"))
+		})
+	})
+	Context("Creating AddressPool without MetalLB", func() {
+		It("should wait for the MetalLB resource", func() {
+			metallb, err := metallbutils.Get(OperatorNameSpace, UseMetallbResourcesFromFile)
+			Expect(err).ToNot(HaveOccurred())
+			err = testclient.Client.Get(context.Background(), goclient.ObjectKey{Namespace: metallb.Namespace, Name: metallb.Name}, &metallbv1beta1.MetalLB{})
+			if err == nil {
+				Skip("the MetalLB resource was not created by the test")
+			}
+			Expect(errors.IsNotFound(err)).To(BeTrue())
+
+			addresspool := &metallbv1alpha1.AddressPool{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "addresspool-waiting",
+					Namespace: OperatorNameSpace,
+				},
+				Spec: metallbv1alpha1.AddressPoolSpec{
+					Protocol: "layer2",
+					Addresses: []string{
+						"5.5.5.1-5.5.5.100",
+					},
+				},
+			}
+			Expect(testclient.Client.Create(context.Background(), addresspool)).Should(Succeed())
+			defer func() {
+				Expect(testclient.Client.Delete(context.Background(), addresspool)).Should(Succeed())
+			}()
+			degradedReason := func() string {
+				pool := &metallbv1alpha1.AddressPool{}
+				err := testclient.Client.Get(context.Background(), goclient.ObjectKey{Namespace: OperatorNameSpace, Name: addresspool.Name}, pool)
+				if err != nil {
+					return ""
+				}
+				degraded := meta.FindStatusCondition(pool.Status.Conditions, status.ConditionDegraded)
+				if degraded == nil || degraded.Status != metav1.ConditionTrue {
+					return ""
+				}
+				return degraded.Reason
+			}
+
+			By("checking the pool waits for the MetalLB resource")
+			Eventually(degradedReason, metallbutils.Timeout, metallbutils.Interval).Should(Equal("WaitingForMetalLB"))
+			_, err = testclient.Client.ConfigMaps(OperatorNameSpace).Get(context.Background(), consts.MetalLBConfigMapName, metav1.GetOptions{})
+			Expect(errors.IsNotFound(err)).To(BeTrue())
+
+			By("creating the MetalLB resource")
+			Expect(testclient.Client.Create(context.Background(), metallb)).Should(Succeed())
+			defer metallbutils.Delete(metallb)
+
+			By("checking the pool is rendered")
+			Eventually(degradedReason, metallbutils.Timeout, metallbutils.Interval).Should(BeEmpty())
+			Eventually(func() string {
+				configmap, err := testclient.Client.ConfigMaps(OperatorNameSpace).Get(context.Background(), consts.MetalLBConfigMapName, metav1.GetOptions{})
+				if err != nil {
+					return ""
+				}
+				return configmap.Data[consts.MetalLBConfigMapName]
+			}, metallbutils.Timeout, metallbutils.Interval).Should(ContainSubstring("addresspool-waiting"))
+		})
+	})
+
 	Context("MetalLB contains incorrect data", func() {
 		Context("MetalLB has incorrect name", func() {
 
@@ -349,7 +473,57 @@ var _ = Describe("metallb", func() {
 			})
 		})
 	})
+	Context("Deleting MetalLB", func() {
+		It("should garbage collect the ConfigMap", func() {
+			metallb, err := metallbutils.Get(OperatorNameSpace, UseMetallbResourcesFromFile)
+			Expect(err).ToNot(HaveOccurred())
+			err = testclient.Client.Get(context.Background(), goclient.ObjectKey{Namespace: metallb.Namespace, Name: metallb.Name}, &metallbv1beta1.MetalLB{})
+			if err == nil {
+				Skip("the MetalLB resource was not created by the test")
+			}
+			Expect(errors.IsNotFound(err)).To(BeTrue())
+			Expect(testclient.Client.Create(context.Background(), metallb)).Should(Succeed())
+			defer metallbutils.Delete(metallb)
+
+			addresspool := &metallbv1alpha1.AddressPool{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "gc-addresspool",
+					Namespace: OperatorNameSpace,
+				},
+				Spec: metallbv1alpha1.AddressPoolSpec{
+					Protocol:  "layer2",
+					Addresses: []string{"4.4.4.0/24"},
+				},
+			}
+			Expect(testclient.Client.Create(context.Background(), addresspool)).Should(Succeed())
+			defer func() {
+				err := testclient.Client.Delete(context.Background(), addresspool)
+				Expect(goclient.IgnoreNotFound(err)).ToNot(HaveOccurred())
+			}()
+
+			By("checking the ConfigMap is owned by the MetalLB resource")
+			Eventually(func() []metav1.OwnerReference {
+				configmap, err := testclient.Client.ConfigMaps(OperatorNameSpace).Get(context.Background(), consts.MetalLBConfigMapName, metav1.GetOptions{})
+				if err != nil {
+					return nil
+				}
+				return configmap.OwnerReferences
+			}, metallbutils.Timeout, metallbutils.Interval).Should(ContainElement(WithTransform(func(owner metav1.OwnerReference) string {
+				return owner.Kind + "/" + owner.Name
+			}, Equal("MetalLB/"+metallb.Name))))
+
+			By("checking the ConfigMap is removed along with the MetalLB resource")
+			metallbutils.Delete(metallb)
+			Eventually(func() bool {
+				_, err := testclient.Client.ConfigMaps(OperatorNameSpace).Get(context.Background(), consts.MetalLBConfigMapName, metav1.GetOptions{})
+				return errors.IsNotFound(err)
+			}, metallbutils.Timeout, metallbutils.Interval).Should(BeTrue())
+		})
+	})
+
 	Context("Testing create/delete Multiple AddressPools", func() {
+		withMetalLB()
+
 		It("should have created, merged and deleted resources correctly", func() {
 			By("Creating first addresspool object ", func() {
 				addresspool := &metallbv1alpha1.AddressPool{
@@ -381,21 +555,15 @@ var _ = Describe("metallb", func() {
 
 				// Checking ConfigMap is created
 				By("By checking ConfigMap is created and matches addresspool1 configuration")
+				expected, err := config.Render([]metallbv1alpha1.AddressPool{*addresspool}, nil)
+				Expect(err).ToNot(HaveOccurred())
 				Eventually(func() (string, error) {
 					configmap, err := testclient.Client.ConfigMaps(OperatorNameSpace).Get(context.Background(), consts.MetalLBConfigMapName, metav1.GetOptions{})
 					if err != nil {
 						return "", err
 					}
 					return configmap.Data[consts.MetalLBConfigMapName], err
-				}, metallbutils.Timeout, metallbutils.Interval).Should(MatchYAML(`address-pools:
-- name: addresspool1
-  protocol: layer2
-  addresses:
-
-  - 1.1.1.1
-  - 1.1.1.100
-
-`))
+				}, metallbutils.Timeout, metallbutils.Interval).Should(MatchYAML(expected))
 
 			})
 
@@ -542,7 +710,186 @@ var _ = Describe("metallb", func() {
 		})
 	})
 
+	Context("Testing create/delete Multiple BGPPeers", func() {
+		withMetalLB()
+
+		It("should have created, merged and deleted resources correctly", func() {
+			configMapData := func() (string, error) {
+				configmap, err := testclient.Client.ConfigMaps(OperatorNameSpace).Get(context.Background(), consts.MetalLBConfigMapName, metav1.GetOptions{})
+				if err != nil {
+					// if its notfound means that was the last bgppeer and configmap is deleted
+					if errors.IsNotFound(err) {
+						return "", nil
+					}
+					return "", err
+				}
+				return configmap.Data[consts.MetalLBConfigMapName], err
+			}
+
+			By("Creating first bgppeer object ", func() {
+				bgppeer := &metallbv1alpha1.BGPPeer{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "bgppeer1",
+						Namespace: OperatorNameSpace,
+					},
+					Spec: metallbv1alpha1.BGPPeerSpec{
+						MyASN:       64512,
+						PeerASN:     64513,
+						PeerAddress: "10.0.0.1",
+					},
+				}
+				Expect(testclient.Client.Create(context.Background(), bgppeer)).Should(Succeed())
+
+				By("By checking ConfigMap is created and matches bgppeer1 configuration")
+				Eventually(configMapData, metallbutils.Timeout, metallbutils.Interval).Should(MatchYAML(`peers:
+- my-asn: 64512
+  peer-asn: 64513
+  peer-address: 10.0.0.1
+address-pools:
+`))
+			})
+
+			By("Creating second bgppeer object ", func() {
+				bgppeer := &metallbv1alpha1.BGPPeer{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "bgppeer2",
+						Namespace: OperatorNameSpace,
+					},
+					Spec: metallbv1alpha1.BGPPeerSpec{
+						MyASN:       64512,
+						PeerASN:     64514,
+						PeerAddress: "10.0.0.2",
+						PeerPort:    1179,
+						HoldTime:    metav1.Duration{Duration: 90 * time.Second},
+					},
+				}
+				Expect(testclient.Client.Create(context.Background(), bgppeer)).Should(Succeed())
+
+				By("By checking ConfigMap matches bgppeer1 and bgppeer2 configuration")
+				Eventually(configMapData, metallbutils.Timeout, metallbutils.Interval).Should(MatchYAML(`peers:
+- my-asn: 64512
+  peer-asn: 64513
+  peer-address: 10.0.0.1
+- my-asn: 64512
+  peer-asn: 64514
+  peer-address: 10.0.0.2
+  peer-port: 1179
+  hold-time: 1m30s
+address-pools:
+`))
+			})
+
+			By("Deleting the first bgppeer object", func() {
+				bgppeer := &metallbv1alpha1.BGPPeer{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "bgppeer1",
+						Namespace: OperatorNameSpace,
+					},
+				}
+				Eventually(func() bool {
+					err := testclient.Client.Delete(context.Background(), bgppeer)
+					return errors.IsNotFound(err)
+				}, metallbutils.Timeout, metallbutils.Interval).Should(BeTrue(), "Failed to delete BGPPeer custom resource")
+
+				By("By checking ConfigMap matches the expected configuration")
+				Eventually(configMapData, metallbutils.Timeout, metallbutils.Interval).Should(MatchYAML(`peers:
+- my-asn: 64512
+  peer-asn: 64514
+  peer-address: 10.0.0.2
+  peer-port: 1179
+  hold-time: 1m30s
+address-pools:
+`))
+			})
+
+			By("Deleting the second bgppeer object", func() {
+				bgppeer := &metallbv1alpha1.BGPPeer{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "bgppeer2",
+						Namespace: OperatorNameSpace,
+					},
+				}
+				Eventually(func() bool {
+					err := testclient.Client.Delete(context.Background(), bgppeer)
+					return errors.IsNotFound(err)
+				}, metallbutils.Timeout, metallbutils.Interval).Should(BeTrue(), "Failed to delete BGPPeer custom resource")
+			})
+
+			// Make sure Configmap is deleted at the end of this test
+			By("By checking ConfigMap is deleted at the end of the test")
+			Eventually(func() bool {
+				_, err := testclient.Client.ConfigMaps(OperatorNameSpace).Get(context.Background(), consts.MetalLBConfigMapName, metav1.GetOptions{})
+				return errors.IsNotFound(err)
+			}, metallbutils.Timeout, metallbutils.Interval).Should(BeTrue())
+		})
+
+		It("should remove the peers and keep the address pools when the last bgppeer is deleted", func() {
+			addresspool := &metallbv1alpha1.AddressPool{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "addresspool1",
+					Namespace: OperatorNameSpace,
+				},
+				Spec: metallbv1alpha1.AddressPoolSpec{
+					Protocol:  "bgp",
+					Addresses: []string{"5.5.5.0/24"},
+				},
+			}
+			Expect(testclient.Client.Create(context.Background(), addresspool)).Should(Succeed())
+			bgppeer := &metallbv1alpha1.BGPPeer{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "bgppeer1",
+					Namespace: OperatorNameSpace,
+				},
+				Spec: metallbv1alpha1.BGPPeerSpec{
+					MyASN:       64512,
+					PeerASN:     64513,
+					PeerAddress: "10.0.0.1",
+				},
+			}
+			Expect(testclient.Client.Create(context.Background(), bgppeer)).Should(Succeed())
+
+			Eventually(func() (string, error) {
+				configmap, err := testclient.Client.ConfigMaps(OperatorNameSpace).Get(context.Background(), consts.MetalLBConfigMapName, metav1.GetOptions{})
+				if err != nil {
+					return "", err
+				}
+				return configmap.Data[consts.MetalLBConfigMapName], err
+			}, metallbutils.Timeout, metallbutils.Interval).Should(MatchYAML(`peers:
+- my-asn: 64512
+  peer-asn: 64513
+  peer-address: 10.0.0.1
+address-pools:
+- name: addresspool1
+  protocol: bgp
+  addresses:
+  - 5.5.5.0/24
+`))
+
+			Expect(testclient.Client.Delete(context.Background(), bgppeer)).Should(Succeed())
+			Eventually(func() (string, error) {
+				configmap, err := testclient.Client.ConfigMaps(OperatorNameSpace).Get(context.Background(), consts.MetalLBConfigMapName, metav1.GetOptions{})
+				if err != nil {
+					return "", err
+				}
+				return configmap.Data[consts.MetalLBConfigMapName], err
+			}, metallbutils.Timeout, metallbutils.Interval).Should(MatchYAML(`address-pools:
+- name: addresspool1
+  protocol: bgp
+  addresses:
+  - 5.5.5.0/24
+`))
+
+			Expect(testclient.Client.Delete(context.Background(), addresspool)).Should(Succeed())
+			Eventually(func() bool {
+				_, err := testclient.Client.ConfigMaps(OperatorNameSpace).Get(context.Background(), consts.MetalLBConfigMapName, metav1.GetOptions{})
+				return errors.IsNotFound(err)
+			}, metallbutils.Timeout, metallbutils.Interval).Should(BeTrue())
+		})
+	})
+
 	Context("Testing Update AddressPool", func() {
+		withMetalLB()
+
 		It("should have created, update and finally delete addresspool correctly", func() {
 			By("Creating addresspool object ", func() {
 				addresspool := &metallbv1alpha1.AddressPool{
@@ -680,4 +1027,198 @@ var _ = Describe("metallb", func() {
 			}, metallbutils.Timeout, metallbutils.Interval).Should(BeTrue())
 		})
 	})
+
+	Context("Self test", func() {
+		var metallb *metallbv1beta1.MetalLB
+		var metallbCRExisted bool
+		var addresspool *metallbv1alpha1.AddressPool
+
+		BeforeEach(func() {
+			var err error
+			metallb, err = metallbutils.Get(OperatorNameSpace, UseMetallbResourcesFromFile)
+			Expect(err).ToNot(HaveOccurred())
+			metallbCRExisted = true
+			err = testclient.Client.Get(context.Background(), goclient.ObjectKey{Namespace: metallb.Namespace, Name: metallb.Name}, metallb)
+			if errors.IsNotFound(err) {
+				metallbCRExisted = false
+				Expect(testclient.Client.Create(context.Background(), metallb)).Should(Succeed())
+			} else {
+				Expect(err).ToNot(HaveOccurred())
+			}
+
+			addresspool = &metallbv1alpha1.AddressPool{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "self-test",
+					Namespace: OperatorNameSpace,
+				},
+				Spec: metallbv1alpha1.AddressPoolSpec{
+					Protocol: "layer2",
+					Addresses: []string{
+						"3.3.3.1-3.3.3.100",
+					},
+				},
+			}
+		})
+
+		AfterEach(func() {
+			if !metallbCRExisted {
+				metallbutils.Delete(metallb)
+			}
+		})
+
+		It("should get an address from the pool", func() {
+			err := util.WithPools(testclient.Client, []*metallbv1alpha1.AddressPool{addresspool}, func() {
+				By("checking MetalLB controller deployment is in running state")
+				Eventually(func() bool {
+					deploy, err := testclient.Client.Deployments(metallb.Namespace).Get(context.Background(), consts.MetalLBDeploymentName, metav1.GetOptions{})
+					if err != nil {
+						return false
+					}
+					return deploy.Status.ReadyReplicas == deploy.Status.Replicas
+				}, metallbutils.Timeout, metallbutils.Interval).Should(BeTrue())
+
+				By("running the self test")
+				ip, err := selftest.Run(context.Background(), testclient.Client, OperatorNameSpace, addresspool, metallbutils.Interval, metallbutils.Timeout)
+				Expect(err).ToNot(HaveOccurred())
+				pool, found := addresses.FindPool(ip, []metallbv1alpha1.AddressPool{*addresspool})
+				Expect(found).To(BeTrue())
+				Expect(pool).To(Equal(addresspool.Name))
+
+				By("checking the self test service is deleted")
+				Eventually(func() bool {
+					_, err := testclient.Client.Services(OperatorNameSpace).Get(context.Background(), selftest.ServiceName, metav1.GetOptions{})
+					return errors.IsNotFound(err)
+				}, metallbutils.Timeout, metallbutils.Interval).Should(BeTrue())
+			})
+			Expect(err).ToNot(HaveOccurred())
+		})
+	})
+
+	Context("Canary", func() {
+		var metallb *metallbv1beta1.MetalLB
+		var metallbCRExisted bool
+
+		BeforeEach(func() {
+			operator, err := testclient.Client.Deployments(OperatorNameSpace).Get(context.Background(), consts.MetalLBOperatorDeploymentName, metav1.GetOptions{})
+			Expect(err).ToNot(HaveOccurred())
+			if !canaryEnabled(operator.Spec.Template.Spec.Containers) {
+				Skip("the operator does not run the canary, --canary-interval is not set")
+			}
+			metallb, err = metallbutils.Get(OperatorNameSpace, UseMetallbResourcesFromFile)
+			Expect(err).ToNot(HaveOccurred())
+			metallbCRExisted = true
+			err = testclient.Client.Get(context.Background(), goclient.ObjectKey{Namespace: metallb.Namespace, Name: metallb.Name}, metallb)
+			if errors.IsNotFound(err) {
+				metallbCRExisted = false
+				Expect(testclient.Client.Create(context.Background(), metallb)).Should(Succeed())
+			} else {
+				Expect(err).ToNot(HaveOccurred())
+			}
+		})
+
+		AfterEach(func() {
+			if !metallbCRExisted {
+				metallbutils.Delete(metallb)
+			}
+		})
+
+		It("should report the canary healthy and clean it up once disabled", func() {
+			key := goclient.ObjectKey{Namespace: metallb.Namespace, Name: metallb.Name}
+			setCanary := func(enabled bool) {
+				Eventually(func() error {
+					instance := &metallbv1beta1.MetalLB{}
+					if err := testclient.Client.Get(context.Background(), key, instance); err != nil {
+						return err
+					}
+					instance.Spec.EnableCanary = &enabled
+					instance.Spec.CanaryAddresses = "4.4.4.4/32"
+					return testclient.Client.Update(context.Background(), instance)
+				}, metallbutils.Timeout, metallbutils.Interval).Should(Succeed())
+			}
+			canaryCondition := func() *metav1.Condition {
+				instance := &metallbv1beta1.MetalLB{}
+				if err := testclient.Client.Get(context.Background(), key, instance); err != nil {
+					return nil
+				}
+				for _, condition := range instance.Status.Conditions {
+					if condition.Type == status.ConditionCanaryHealthy {
+						return condition.DeepCopy()
+					}
+				}
+				return nil
+			}
+
+			By("enabling the canary")
+			setCanary(true)
+
+			By("checking the canary is healthy")
+			Eventually(func() metav1.ConditionStatus {
+				condition := canaryCondition()
+				if condition == nil {
+					return ""
+				}
+				return condition.Status
+			}, metallbutils.Timeout, metallbutils.Interval).Should(Equal(metav1.ConditionTrue))
+			svc, err := testclient.Client.Services(OperatorNameSpace).Get(context.Background(), selftest.CanaryName, metav1.GetOptions{})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(svc.Status.LoadBalancer.Ingress).To(ContainElement(corev1.LoadBalancerIngress{IP: "4.4.4.4"}))
+
+			By("disabling the canary")
+			setCanary(false)
+
+			By("checking the canary resources are deleted")
+			Eventually(func() bool {
+				_, err := testclient.Client.Services(OperatorNameSpace).Get(context.Background(), selftest.CanaryName, metav1.GetOptions{})
+				if !errors.IsNotFound(err) {
+					return false
+				}
+				err = testclient.Client.Get(context.Background(), goclient.ObjectKey{Namespace: OperatorNameSpace, Name: selftest.CanaryName}, &metallbv1alpha1.AddressPool{})
+				return errors.IsNotFound(err)
+			}, metallbutils.Timeout, metallbutils.Interval).Should(BeTrue())
+			Eventually(canaryCondition, metallbutils.Timeout, metallbutils.Interval).Should(BeNil())
+		})
+	})
 })
+
+// withMetalLB creates the MetalLB resource before each spec of the calling
+// container unless it exists, and deletes it afterwards, as the AddressPools
+// are only rendered once there is a MetalLB instance.
+func withMetalLB() {
+	var metallb *metallbv1beta1.MetalLB
+	var metallbCRExisted bool
+
+	BeforeEach(func() {
+		var err error
+		metallb, err = metallbutils.Get(OperatorNameSpace, UseMetallbResourcesFromFile)
+		Expect(err).ToNot(HaveOccurred())
+		metallbCRExisted = true
+		err = testclient.Client.Get(context.Background(), goclient.ObjectKey{Namespace: metallb.Namespace, Name: metallb.Name}, metallb)
+		if errors.IsNotFound(err) {
+			metallbCRExisted = false
+			Expect(testclient.Client.Create(context.Background(), metallb)).Should(Succeed())
+		} else {
+			Expect(err).ToNot(HaveOccurred())
+		}
+	})
+
+	AfterEach(func() {
+		if !metallbCRExisted {
+			metallbutils.Delete(metallb)
+		}
+	})
+}
+
+// canaryEnabled returns whether the operator containers run the canary, that
+// is set a non-zero --canary-interval.
+func canaryEnabled(containers []corev1.Container) bool {
+	for _, container := range containers {
+		for _, arg := range container.Args {
+			if !strings.HasPrefix(arg, "--canary-interval=") {
+				continue
+			}
+			interval, err := time.ParseDuration(strings.TrimPrefix(arg, "--canary-interval="))
+			return err == nil && interval > 0
+		}
+	}
+	return false
+}
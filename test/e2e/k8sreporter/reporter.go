@@ -4,31 +4,73 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"os"
 	"path"
 	"strings"
 	"sync"
 
 	"github.com/kennygrant/sanitize"
+	metallbv1alpha1 "github.com/metallb/metallb-operator/api/v1alpha1"
+	metallbv1beta1 "github.com/metallb/metallb-operator/api/v1beta1"
+	"github.com/metallb/metallb-operator/test/consts"
 	testclient "github.com/metallb/metallb-operator/test/e2e/client"
 	"github.com/onsi/ginkgo/config"
 	"github.com/onsi/ginkgo/types"
 	corev1 "k8s.io/api/core/v1"
 	v1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8stypes "k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
 var operatorNameSpace string
 
+// Format is the serialization format of the dumped objects
+type Format string
+
+const (
+	// FormatText dumps the objects as indented JSON, separated by a dashed line
+	FormatText Format = "text"
+	// FormatJSON dumps each object as a JSON document on its own line
+	FormatJSON Format = "json"
+)
+
 type KubernetesReporter struct {
 	sync.Mutex
 	clients    *testclient.ClientSet
 	reportPath string
+	format     Format
 }
 
-func New(clients *testclient.ClientSet, nameSpace string, reportPath string) *KubernetesReporter {
+// New returns a reporter dumping the cluster state on failures. Any format but
+// FormatJSON is treated as FormatText.
+func New(clients *testclient.ClientSet, nameSpace string, reportPath string, format Format) *KubernetesReporter {
 	operatorNameSpace = nameSpace
-	return &KubernetesReporter{clients: clients, reportPath: reportPath}
+	if format != FormatJSON {
+		format = FormatText
+	}
+	return &KubernetesReporter{clients: clients, reportPath: reportPath, format: format}
+}
+
+// writeObject serializes obj to w in the reporter format.
+func (r *KubernetesReporter) writeObject(w io.Writer, obj interface{}) error {
+	if r.format == FormatJSON {
+		j, err := json.Marshal(obj)
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintln(w, string(j))
+		return err
+	}
+
+	j, err := json.MarshalIndent(obj, "", "    ")
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "-----------------------------------\n")
+	_, err = fmt.Fprintln(w, string(j))
+	return err
 }
 
 func (r *KubernetesReporter) SpecSuiteWillBegin(config config.GinkgoConfigType, summary *types.SuiteSummary) {
@@ -70,6 +112,8 @@ func (r *KubernetesReporter) Dump(dirName string) {
 
 	r.logNodes(dirName)
 	r.logPods(operatorNameSpace, dirName)
+	r.logMetalLBResources(dirName)
+	r.logConfigMap(dirName)
 	r.logLogs(func(p *corev1.Pod) bool {
 		return !strings.Contains(p.Namespace, "metallb")
 	}, dirName)
@@ -93,13 +137,59 @@ func (r *KubernetesReporter) logPods(namespace string, dirName string) {
 			return
 		}
 		defer f.Close()
-		fmt.Fprintf(f, "-----------------------------------\n")
-		j, err := json.MarshalIndent(pod, "", "    ")
-		if err != nil {
+		if err := r.writeObject(f, pod); err != nil {
 			fmt.Println("Failed to marshal pods", err)
 			return
 		}
-		fmt.Fprintln(f, string(j))
+	}
+}
+
+// metalLBResources returns the lists of the MetalLB operator kinds dumped on
+// failure. New CRDs must be added here to show up in the dump.
+func metalLBResources() []client.ObjectList {
+	return []client.ObjectList{
+		&metallbv1beta1.MetalLBList{},
+		&metallbv1alpha1.AddressPoolList{},
+	}
+}
+
+func (r *KubernetesReporter) logMetalLBResources(dirName string) {
+	f, err := logFileFor(r.reportPath, dirName, "metallb_resources")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to open metallb_resources file: %v\n", dirName)
+		return
+	}
+	defer f.Close()
+
+	for _, list := range metalLBResources() {
+		if err := r.clients.List(context.Background(), list); err != nil {
+			fmt.Fprintf(os.Stderr, "failed to fetch %T: %v\n", list, err)
+			continue
+		}
+		if err := r.writeObject(f, list); err != nil {
+			fmt.Printf("Failed to marshal %T %v\n", list, err)
+			continue
+		}
+	}
+}
+
+// logConfigMap dumps the ConfigMap generated by the operator from the AddressPools.
+func (r *KubernetesReporter) logConfigMap(dirName string) {
+	f, err := logFileFor(r.reportPath, dirName, "configmap")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to open configmap file: %v\n", dirName)
+		return
+	}
+	defer f.Close()
+
+	configMap := &corev1.ConfigMap{}
+	err = r.clients.Get(context.Background(), k8stypes.NamespacedName{Name: consts.MetalLBConfigMapName, Namespace: operatorNameSpace}, configMap)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to fetch configmap: %v\n", err)
+		return
+	}
+	if err := r.writeObject(f, configMap); err != nil {
+		fmt.Println("Failed to marshal configmap", err)
 	}
 }
 
@@ -110,7 +200,6 @@ func (r *KubernetesReporter) logNodes(dirName string) {
 		return
 	}
 	defer f.Close()
-	fmt.Fprintf(f, "-----------------------------------\n")
 
 	nodes, err := r.clients.Nodes().List(context.Background(), metav1.ListOptions{})
 	if err != nil {
@@ -118,12 +207,9 @@ func (r *KubernetesReporter) logNodes(dirName string) {
 		return
 	}
 
-	j, err := json.MarshalIndent(nodes, "", "    ")
-	if err != nil {
+	if err := r.writeObject(f, nodes); err != nil {
 		fmt.Println("Failed to marshal nodes")
-		return
 	}
-	fmt.Fprintln(f, string(j))
 }
 
 func (r *KubernetesReporter) logLogs(filterPods func(*corev1.Pod) bool, dirName string) {
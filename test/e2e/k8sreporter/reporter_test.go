@@ -0,0 +1,100 @@
+package k8sreporter
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	metallbv1alpha1 "github.com/metallb/metallb-operator/api/v1alpha1"
+	metallbv1beta1 "github.com/metallb/metallb-operator/api/v1beta1"
+	"github.com/metallb/metallb-operator/test/consts"
+	testclient "github.com/metallb/metallb-operator/test/e2e/client"
+)
+
+// testReporter returns a reporter dumping a fake cluster with a single
+// AddressPool and the MetalLB ConfigMap into a temporary directory.
+func testReporter(g *WithT, format Format) (*KubernetesReporter, string) {
+	s := runtime.NewScheme()
+	g.Expect(clientgoscheme.AddToScheme(s)).To(Succeed())
+	g.Expect(metallbv1alpha1.AddToScheme(s)).To(Succeed())
+	g.Expect(metallbv1beta1.AddToScheme(s)).To(Succeed())
+
+	pool := &metallbv1alpha1.AddressPool{
+		ObjectMeta: metav1.ObjectMeta{Name: "gold", Namespace: consts.DefaultOperatorNameSpace},
+		Spec: metallbv1alpha1.AddressPoolSpec{
+			Protocol:  "layer2",
+			Addresses: []string{"172.20.0.100/24"},
+		},
+	}
+	configMap := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: consts.MetalLBConfigMapName, Namespace: consts.DefaultOperatorNameSpace},
+		Data:       map[string]string{"config": "address-pools:\n- name: gold\n"},
+	}
+	clients := &testclient.ClientSet{
+		Client: fake.NewClientBuilder().WithScheme(s).WithObjects(pool, configMap).Build(),
+	}
+
+	reportPath, err := ioutil.TempDir("", "k8sreporter")
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(os.Mkdir(path.Join(reportPath, "spec"), 0755)).To(Succeed())
+
+	return New(clients, consts.DefaultOperatorNameSpace, reportPath, format), reportPath
+}
+
+func TestDumpMetalLBResources(t *testing.T) {
+	g := NewGomegaWithT(t)
+	r, reportPath := testReporter(g, FormatText)
+	defer os.RemoveAll(reportPath)
+
+	r.logMetalLBResources("spec")
+	r.logConfigMap("spec")
+
+	resources, err := ioutil.ReadFile(path.Join(reportPath, "spec", "metallb_resources.log"))
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(string(resources)).To(ContainSubstring(`"name": "gold"`))
+	g.Expect(string(resources)).To(ContainSubstring(`"172.20.0.100/24"`))
+
+	dumpedConfigMap, err := ioutil.ReadFile(path.Join(reportPath, "spec", "configmap.log"))
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(string(dumpedConfigMap)).To(ContainSubstring(`address-pools:\n- name: gold\n`))
+}
+
+func TestDumpJSON(t *testing.T) {
+	g := NewGomegaWithT(t)
+	r, reportPath := testReporter(g, FormatJSON)
+	defer os.RemoveAll(reportPath)
+
+	r.logMetalLBResources("spec")
+	r.logConfigMap("spec")
+
+	resources, err := ioutil.ReadFile(path.Join(reportPath, "spec", "metallb_resources.log"))
+	g.Expect(err).ToNot(HaveOccurred())
+	lines := strings.Split(strings.TrimSpace(string(resources)), "\n")
+	g.Expect(lines).To(HaveLen(2))
+
+	metallbs := &metallbv1beta1.MetalLBList{}
+	g.Expect(json.Unmarshal([]byte(lines[0]), metallbs)).To(Succeed())
+	g.Expect(metallbs.Items).To(BeEmpty())
+
+	pools := &metallbv1alpha1.AddressPoolList{}
+	g.Expect(json.Unmarshal([]byte(lines[1]), pools)).To(Succeed())
+	g.Expect(pools.Items).To(HaveLen(1))
+	g.Expect(pools.Items[0].Name).To(Equal("gold"))
+	g.Expect(pools.Items[0].Spec.Addresses).To(Equal([]string{"172.20.0.100/24"}))
+
+	dumpedConfigMap, err := ioutil.ReadFile(path.Join(reportPath, "spec", "configmap.log"))
+	g.Expect(err).ToNot(HaveOccurred())
+	configMap := &corev1.ConfigMap{}
+	g.Expect(json.Unmarshal(dumpedConfigMap, configMap)).To(Succeed())
+	g.Expect(configMap.Data["config"]).To(Equal("address-pools:\n- name: gold\n"))
+}
@@ -0,0 +1,70 @@
+package util
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	metallbv1alpha1 "github.com/metallb/metallb-operator/api/v1alpha1"
+)
+
+var (
+	// DeletionTimeout is how long the cleanup waits for a resource to be gone.
+	DeletionTimeout = 3 * time.Minute
+	// DeletionInterval is how often the cleanup checks a resource is gone.
+	DeletionInterval = 2 * time.Second
+)
+
+// WithPools creates the address pools, runs fn and deletes the pools, waiting
+// until they are gone. The pools are deleted even when fn panics, as it does
+// when a gomega assertion fails, so the tests using it can run in parallel
+// without leaking pools into each other.
+func WithPools(c client.Client, pools []*metallbv1alpha1.AddressPool, fn func()) error {
+	objs := make([]client.Object, 0, len(pools))
+	for _, pool := range pools {
+		objs = append(objs, pool)
+	}
+	return withObjects(c, objs, fn)
+}
+
+// withObjects creates objs, runs fn and deletes the objects created, in the
+// reverse order, once fn returns or panics. The first error met is returned.
+func withObjects(c client.Client, objs []client.Object, fn func()) (err error) {
+	created := []client.Object{}
+	defer func() {
+		for i := len(created) - 1; i >= 0; i-- {
+			if cleanupErr := deleteAndWait(c, created[i]); cleanupErr != nil && err == nil {
+				err = cleanupErr
+			}
+		}
+	}()
+
+	for _, obj := range objs {
+		if err := c.Create(context.Background(), obj); err != nil {
+			return errors.Wrapf(err, "failed to create %s/%s", obj.GetNamespace(), obj.GetName())
+		}
+		created = append(created, obj)
+	}
+	fn()
+	return nil
+}
+
+func deleteAndWait(c client.Client, obj client.Object) error {
+	err := c.Delete(context.Background(), obj)
+	if err != nil && !k8serrors.IsNotFound(err) {
+		return errors.Wrapf(err, "failed to delete %s/%s", obj.GetNamespace(), obj.GetName())
+	}
+	key := client.ObjectKeyFromObject(obj)
+	err = wait.PollImmediate(DeletionInterval, DeletionTimeout, func() (bool, error) {
+		err := c.Get(context.Background(), key, obj)
+		if k8serrors.IsNotFound(err) {
+			return true, nil
+		}
+		return false, err
+	})
+	return errors.Wrapf(err, "failed waiting for %s to be deleted", key)
+}
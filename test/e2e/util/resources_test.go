@@ -0,0 +1,98 @@
+package util
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"github.com/pkg/errors"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	metallbv1alpha1 "github.com/metallb/metallb-operator/api/v1alpha1"
+)
+
+func testPools() []*metallbv1alpha1.AddressPool {
+	return []*metallbv1alpha1.AddressPool{
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "pool1", Namespace: "metallb-system"},
+			Spec: metallbv1alpha1.AddressPoolSpec{
+				Protocol:  "layer2",
+				Addresses: []string{"1.1.1.1-1.1.1.100"},
+			},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "pool2", Namespace: "metallb-system"},
+			Spec: metallbv1alpha1.AddressPoolSpec{
+				Protocol:  "layer2",
+				Addresses: []string{"2.2.2.1-2.2.2.100"},
+			},
+		},
+	}
+}
+
+func testClient(g *WithT) client.Client {
+	scheme := runtime.NewScheme()
+	g.Expect(clientgoscheme.AddToScheme(scheme)).To(Succeed())
+	g.Expect(metallbv1alpha1.AddToScheme(scheme)).To(Succeed())
+	return fake.NewClientBuilder().WithScheme(scheme).Build()
+}
+
+func expectPools(g *WithT, c client.Client, exist bool) {
+	pools := &metallbv1alpha1.AddressPoolList{}
+	g.Expect(c.List(context.Background(), pools)).To(Succeed())
+	if exist {
+		g.Expect(pools.Items).To(HaveLen(2))
+	} else {
+		g.Expect(pools.Items).To(BeEmpty())
+	}
+}
+
+func TestWithPools(t *testing.T) {
+	g := NewGomegaWithT(t)
+	c := testClient(g)
+
+	called := false
+	err := WithPools(c, testPools(), func() {
+		called = true
+		expectPools(g, c, true)
+	})
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(called).To(BeTrue())
+	expectPools(g, c, false)
+}
+
+func TestWithPoolsCleanupOnPanic(t *testing.T) {
+	g := NewGomegaWithT(t)
+	c := testClient(g)
+
+	func() {
+		defer func() {
+			g.Expect(recover()).To(Equal("assertion failed"))
+		}()
+		_ = WithPools(c, testPools(), func() {
+			expectPools(g, c, true)
+			panic("assertion failed")
+		})
+	}()
+	expectPools(g, c, false)
+}
+
+func TestWithPoolsCreateFailure(t *testing.T) {
+	g := NewGomegaWithT(t)
+	c := testClient(g)
+
+	pools := testPools()
+	pools[1].Name = pools[0].Name
+	called := false
+	err := WithPools(c, pools, func() {
+		called = true
+	})
+	g.Expect(k8serrors.IsAlreadyExists(errors.Cause(err))).To(BeTrue())
+	g.Expect(called).To(BeFalse())
+	expectPools(g, c, false)
+}
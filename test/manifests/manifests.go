@@ -0,0 +1,106 @@
+package manifests
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	openapi_v2 "github.com/googleapis/gnostic/openapiv2"
+	"github.com/pkg/errors"
+	uns "k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	kjson "k8s.io/apimachinery/pkg/runtime/serializer/json"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/kube-openapi/pkg/util/proto"
+	"k8s.io/kube-openapi/pkg/util/proto/validation"
+)
+
+// openAPISchema is the Kubernetes OpenAPI schema, trimmed to the kinds of the
+// MetalLB manifests by hack/generate-test-openapi.sh.
+//
+//go:embed testdata/swagger.json
+var openAPISchema []byte
+
+const gvkExtension = "x-kubernetes-group-version-kind"
+
+var (
+	loadOnce sync.Once
+	schemas  map[schema.GroupVersionKind]proto.Schema
+	loadErr  error
+)
+
+// Validate checks the rendered objects the way the API server would: each
+// object is strictly decoded into its typed counterpart, which rejects unknown
+// and duplicated fields, and validated against the Kubernetes OpenAPI schema.
+// All the errors found are returned.
+func Validate(objs []*uns.Unstructured) []error {
+	loadOnce.Do(func() {
+		schemas, loadErr = loadSchemas(openAPISchema)
+	})
+	if loadErr != nil {
+		return []error{loadErr}
+	}
+
+	errs := []error{}
+	for _, obj := range objs {
+		name := fmt.Sprintf("%s %s/%s", obj.GetKind(), obj.GetNamespace(), obj.GetName())
+		if err := strictDecode(obj); err != nil {
+			errs = append(errs, errors.Wrapf(err, "failed to decode %s", name))
+		}
+
+		s, ok := schemas[obj.GroupVersionKind()]
+		if !ok {
+			errs = append(errs, fmt.Errorf("no schema for %s", name))
+			continue
+		}
+		for _, err := range validation.ValidateModel(obj.Object, s, obj.GetKind()) {
+			errs = append(errs, errors.Wrapf(err, "invalid %s", name))
+		}
+	}
+	return errs
+}
+
+func strictDecode(obj *uns.Unstructured) error {
+	data, err := json.Marshal(obj.Object)
+	if err != nil {
+		return err
+	}
+	decoder := kjson.NewSerializerWithOptions(kjson.DefaultMetaFactory, scheme.Scheme, scheme.Scheme,
+		kjson.SerializerOptions{Strict: true})
+	_, _, err = decoder.Decode(data, nil, nil)
+	return err
+}
+
+// loadSchemas parses the OpenAPI document and indexes its definitions by the
+// kinds they describe.
+func loadSchemas(data []byte) (map[schema.GroupVersionKind]proto.Schema, error) {
+	doc, err := openapi_v2.ParseDocument(data)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to parse the OpenAPI schema")
+	}
+	models, err := proto.NewOpenAPIData(doc)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to load the OpenAPI schema")
+	}
+
+	res := map[schema.GroupVersionKind]proto.Schema{}
+	for _, name := range models.ListModels() {
+		s := models.LookupModel(name)
+		gvks, ok := s.GetExtensions()[gvkExtension].([]interface{})
+		if !ok {
+			continue
+		}
+		for _, gvk := range gvks {
+			fields, ok := gvk.(map[interface{}]interface{})
+			if !ok {
+				continue
+			}
+			group, _ := fields["group"].(string)
+			version, _ := fields["version"].(string)
+			kind, _ := fields["kind"].(string)
+			res[schema.GroupVersionKind{Group: group, Version: version, Kind: kind}] = s
+		}
+	}
+	return res, nil
+}
@@ -0,0 +1,83 @@
+package manifests
+
+import (
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v2"
+)
+
+// metalLBConfig mirrors the configuration file read by MetalLB v0.10
+// (internal/config in the MetalLB repository), the BFD profiles and the
+// ebgp-multihop of the peers read since v0.11, their keepalive-time read
+// since v0.12, and their graceful-restart and connect-time read with the frr
+// BGP backend.
+type metalLBConfig struct {
+	Peers          []metalLBPeer        `yaml:"peers"`
+	BGPCommunities map[string]string    `yaml:"bgp-communities"`
+	Pools          []metalLBAddressPool `yaml:"address-pools"`
+	BFDProfiles    []metalLBBFDProfile  `yaml:"bfd-profiles"`
+}
+
+type metalLBPeer struct {
+	MyASN           uint32                `yaml:"my-asn"`
+	ASN             uint32                `yaml:"peer-asn"`
+	Addr            string                `yaml:"peer-address"`
+	SrcAddr         string                `yaml:"source-address"`
+	Port            uint16                `yaml:"peer-port"`
+	HoldTime        string                `yaml:"hold-time"`
+	KeepaliveTime   string                `yaml:"keepalive-time"`
+	RouterID        string                `yaml:"router-id"`
+	EBGPMultiHop    bool                  `yaml:"ebgp-multihop"`
+	NodeSelectors   []metalLBNodeSelector `yaml:"node-selectors"`
+	Password        string                `yaml:"password"`
+	BFDProfile      string                `yaml:"bfd-profile"`
+	GracefulRestart bool                  `yaml:"graceful-restart"`
+	ConnectTime     string                `yaml:"connect-time"`
+}
+
+type metalLBBFDProfile struct {
+	Name             string  `yaml:"name"`
+	ReceiveInterval  *uint32 `yaml:"receive-interval"`
+	TransmitInterval *uint32 `yaml:"transmit-interval"`
+	DetectMultiplier *uint32 `yaml:"detect-multiplier"`
+	EchoInterval     *uint32 `yaml:"echo-interval"`
+	EchoMode         bool    `yaml:"echo-mode"`
+	PassiveMode      bool    `yaml:"passive-mode"`
+	MinimumTTL       *uint32 `yaml:"minimum-ttl"`
+}
+
+type metalLBNodeSelector struct {
+	MatchLabels      map[string]string            `yaml:"match-labels"`
+	MatchExpressions []metalLBSelectorRequirement `yaml:"match-expressions"`
+}
+
+type metalLBSelectorRequirement struct {
+	Key      string   `yaml:"key"`
+	Operator string   `yaml:"operator"`
+	Values   []string `yaml:"values"`
+}
+
+type metalLBAddressPool struct {
+	Protocol          string                 `yaml:"protocol"`
+	Name              string                 `yaml:"name"`
+	Addresses         []string               `yaml:"addresses"`
+	AvoidBuggyIPs     bool                   `yaml:"avoid-buggy-ips"`
+	AutoAssign        *bool                  `yaml:"auto-assign"`
+	BGPAdvertisements []metalLBAdvertisement `yaml:"bgp-advertisements"`
+}
+
+type metalLBAdvertisement struct {
+	AggregationLength *int     `yaml:"aggregation-length"`
+	LocalPref         *uint32  `yaml:"localpref"`
+	Communities       []string `yaml:"communities"`
+}
+
+// ValidateMetalLBConfig checks the configuration is read by MetalLB: it is
+// strictly decoded into a mirror of the MetalLB configuration file, which
+// rejects the fields MetalLB does not know.
+func ValidateMetalLBConfig(config string) error {
+	var c metalLBConfig
+	if err := yaml.UnmarshalStrict([]byte(config), &c); err != nil {
+		return errors.Wrap(err, "invalid MetalLB configuration")
+	}
+	return nil
+}
@@ -77,6 +77,7 @@ func Get(operatorNamespace string, useMetallbResourcesFromFile bool) (*metallbv1
 
 func CheckConditionStatus(instance *metallbv1beta1.MetalLB) string {
 	availableStatus := false
+	progressingStatus := false
 	degradedStatus := false
 	for _, condition := range instance.Status.Conditions {
 		if condition.Type == status.ConditionDegraded && condition.Status == metav1.ConditionTrue {
@@ -85,6 +86,9 @@ func CheckConditionStatus(instance *metallbv1beta1.MetalLB) string {
 		if condition.Type == status.ConditionAvailable && condition.Status == metav1.ConditionTrue {
 			availableStatus = true
 		}
+		if condition.Type == status.ConditionProgressing && condition.Status == metav1.ConditionTrue {
+			progressingStatus = true
+		}
 	}
 	if availableStatus && !degradedStatus {
 		return status.ConditionAvailable
@@ -92,6 +96,9 @@ func CheckConditionStatus(instance *metallbv1beta1.MetalLB) string {
 	if !availableStatus && degradedStatus {
 		return status.ConditionDegraded
 	}
+	if !availableStatus && progressingStatus {
+		return status.ConditionProgressing
+	}
 	return ""
 }
 
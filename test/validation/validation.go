@@ -14,6 +14,7 @@ import (
 	"github.com/onsi/ginkgo/reporters"
 	. "github.com/onsi/gomega"
 
+	metallbv1beta1 "github.com/metallb/metallb-operator/api/v1beta1"
 	"github.com/metallb/metallb-operator/pkg/platform"
 	"github.com/metallb/metallb-operator/test/consts"
 	testclient "github.com/metallb/metallb-operator/test/e2e/client"
@@ -31,6 +32,7 @@ var OperatorNameSpace = consts.DefaultOperatorNameSpace
 
 var junitPath *string
 var reportPath *string
+var reportFormat *string
 
 func init() {
 	if len(os.Getenv("IS_OPENSHIFT")) != 0 {
@@ -43,6 +45,7 @@ func init() {
 
 	junitPath = flag.String("junit", "", "the path for the junit format report")
 	reportPath = flag.String("report", "", "the path of the report file containing details for failed tests")
+	reportFormat = flag.String("report-format", string(k8sreporter.FormatText), "the format of the objects dumped in the report, text or json")
 }
 
 func RunValidationTests(t *testing.T) {
@@ -57,7 +60,7 @@ func RunValidationTests(t *testing.T) {
 	clients := testclient.New("")
 
 	if *reportPath != "" {
-		rr = append(rr, k8sreporter.New(clients, OperatorNameSpace, *reportPath))
+		rr = append(rr, k8sreporter.New(clients, OperatorNameSpace, *reportPath, k8sreporter.Format(*reportFormat)))
 	}
 
 	RunSpecsWithDefaultAndCustomReporters(t, "Metallb Operator Validation Suite", rr)
@@ -74,6 +77,10 @@ var _ = Describe("metallb", func() {
 			platforminfo, err := platform.GetPlatformInfo(cfg)
 			Expect(err).ToNot(HaveOccurred())
 			Expect(platforminfo.IsOpenShift()).Should(Equal(TestIsOpenShift))
+			Expect(platforminfo.K8SVersion).ToNot(BeEmpty())
+			if TestIsOpenShift {
+				Expect(platforminfo.OCPVersion).ToNot(BeEmpty())
+			}
 		})
 	})
 
@@ -100,6 +107,22 @@ var _ = Describe("metallb", func() {
 			}
 		})
 
+		It("should have the speaker DaemonSet run on the nodes of the speakerNodeSelector", func() {
+			metallbs := &metallbv1beta1.MetalLBList{}
+			err := testclient.Client.List(context.Background(), metallbs, goclient.InNamespace(OperatorNameSpace))
+			Expect(err).ToNot(HaveOccurred())
+			if len(metallbs.Items) == 0 {
+				Skip("No MetalLB resource deployed")
+			}
+
+			ds, err := testclient.Client.DaemonSets(OperatorNameSpace).Get(context.Background(), consts.MetalLBDaemonsetName, metav1.GetOptions{})
+			Expect(err).ToNot(HaveOccurred())
+			for key, value := range metallbs.Items[0].Spec.SpeakerNodeSelector {
+				Expect(ds.Spec.Template.Spec.NodeSelector).To(HaveKeyWithValue(key, value))
+			}
+			Expect(ds.Spec.Template.Spec.NodeSelector).To(HaveKeyWithValue("kubernetes.io/os", "linux"))
+		})
+
 		It("should have the MetalLB CRD available in the cluster", func() {
 			crd := &apiext.CustomResourceDefinition{}
 			err := testclient.Client.Get(context.Background(), goclient.ObjectKey{Name: consts.MetalLBOperatorCRDName}, crd)
@@ -111,5 +134,23 @@ var _ = Describe("metallb", func() {
 			err := testclient.Client.Get(context.Background(), goclient.ObjectKey{Name: consts.MetalLBAddressPoolCRDName}, crd)
 			Expect(err).ToNot(HaveOccurred())
 		})
+
+		It("should have the MetalLB BGPPeer CRD available in the cluster", func() {
+			crd := &apiext.CustomResourceDefinition{}
+			err := testclient.Client.Get(context.Background(), goclient.ObjectKey{Name: consts.MetalLBBGPPeerCRDName}, crd)
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		It("should have the MetalLB BFDProfile CRD available in the cluster", func() {
+			crd := &apiext.CustomResourceDefinition{}
+			err := testclient.Client.Get(context.Background(), goclient.ObjectKey{Name: consts.MetalLBBFDProfileCRDName}, crd)
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		It("should have the MetalLB Community CRD available in the cluster", func() {
+			crd := &apiext.CustomResourceDefinition{}
+			err := testclient.Client.Get(context.Background(), goclient.ObjectKey{Name: consts.MetalLBCommunityCRDName}, crd)
+			Expect(err).ToNot(HaveOccurred())
+		})
 	})
 })